@@ -27,10 +27,14 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
+	"time"
 
+	"github.com/superkkt/cherry"
 	"github.com/superkkt/cherry/api"
+	"github.com/superkkt/cherry/network"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/davecgh/go-spew/spew"
@@ -43,13 +47,45 @@ var (
 
 type API struct {
 	api.Server
+	Monitor Monitor
+	// Names of the enabled north-bound applications.
+	Applications []string
+	// Shared token that authorizes the admin APIs to install and remove static flows. Empty token
+	// disables the admin APIs.
+	AdminToken string
+	// Cookie range of the static flows installed by the admin APIs, which should be registered before the
+	// north-bound applications.
+	AdminCookie network.AppCookie
+}
+
+type Monitor interface {
+	Stats() (network.Stats, error)
+	Devices() []*network.Device
+	// Device returns nil if the device does not exist.
+	Device(id string) *network.Device
+	// AllNodes returns the known locations of all the discovered nodes sorted by the MAC address.
+	AllNodes() ([]*network.Node, error)
 }
 
 func (r *API) Serve() error {
+	if r.Monitor == nil {
+		return errors.New("nil monitor")
+	}
+	if r.AdminToken != "" && r.AdminCookie == 0 {
+		return errors.New("nil admin cookie")
+	}
+
 	return r.Server.Serve(
+		rest.Get("/api/v1/info", api.ResponseHandler(r.info)),
 		rest.Post("/api/v1/status", api.ResponseHandler(r.status)),
 		rest.Post("/api/v1/remove", api.ResponseHandler(r.remove)),
 		rest.Post("/api/v1/announce", api.ResponseHandler(r.announce)),
+		rest.Post("/api/v1/quarantine", api.ResponseHandler(r.adminHandler(r.quarantine))),
+		rest.Get("/api/v1/devices", api.ResponseHandler(r.listDevices)),
+		rest.Get("/api/v1/devices/:dpid/ports", api.ResponseHandler(r.listPorts)),
+		rest.Get("/api/v1/hosts", api.ResponseHandler(r.listHosts)),
+		rest.Post("/api/v1/devices/:dpid/flows", api.ResponseHandler(r.adminHandler(r.addFlow))),
+		rest.Delete("/api/v1/devices/:dpid/flows", api.ResponseHandler(r.adminHandler(r.removeFlow))),
 	)
 }
 
@@ -66,6 +102,44 @@ func (r *API) status(w api.ResponseWriter, req *rest.Request) {
 	})
 }
 
+func (r *API) info(w api.ResponseWriter, req *rest.Request) {
+	logger.Debugf("info request from %v", req.RemoteAddr)
+
+	stats, err := r.Monitor.Stats()
+	if err != nil {
+		w.Write(api.Response{Status: api.StatusInternalServerError, Message: fmt.Sprintf("failed to query the controller stats: %v", err.Error())})
+		return
+	}
+
+	v := struct {
+		Version      string         `json:"version"`
+		Build        string         `json:"build"`
+		StartTime    time.Time      `json:"start_time"`
+		Uptime       uint64         `json:"uptime"` // Seconds
+		Applications []string       `json:"applications"`
+		OFVersions   map[string]int `json:"of_versions"`
+		Devices      int            `json:"devices"`
+		Nodes        int            `json:"nodes"`
+		PacketIn     struct {
+			NewFlow    uint64 `json:"new_flow"`
+			RepeatMiss uint64 `json:"repeat_miss"`
+		} `json:"packet_in"`
+	}{
+		Version:      cherry.Version,
+		Build:        cherry.Build,
+		StartTime:    stats.StartTime,
+		Uptime:       uint64(time.Since(stats.StartTime).Seconds()),
+		Applications: r.Applications,
+		OFVersions:   stats.OFVersions,
+		Devices:      stats.Devices,
+		Nodes:        stats.Nodes,
+	}
+	v.PacketIn.NewFlow = stats.NewFlowMisses
+	v.PacketIn.RepeatMiss = stats.RepeatMisses
+
+	w.Write(api.Response{Status: api.StatusOkay, Data: v})
+}
+
 func (r *API) remove(w api.ResponseWriter, req *rest.Request) {
 	p := new(removeParam)
 	if err := req.DecodeJsonPayload(p); err != nil {
@@ -158,3 +232,46 @@ func (r *announceParam) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+func (r *API) quarantine(w api.ResponseWriter, req *rest.Request) {
+	p := new(quarantineParam)
+	if err := req.DecodeJsonPayload(p); err != nil {
+		w.Write(api.Response{Status: api.StatusInvalidParameter, Message: fmt.Sprintf("failed to decode param: %v", err.Error())})
+		return
+	}
+	logger.Debugf("quarantine request from %v: %v", req.RemoteAddr, spew.Sdump(p))
+
+	if err := r.Controller.Quarantine(p.MAC, p.Timeout); err != nil {
+		w.Write(api.Response{Status: api.StatusInternalServerError, Message: fmt.Sprintf("failed to quarantine the host: %v", err.Error())})
+		return
+	}
+
+	w.Write(api.Response{Status: api.StatusOkay})
+}
+
+type quarantineParam struct {
+	MAC     net.HardwareAddr
+	Timeout time.Duration
+}
+
+func (r *quarantineParam) UnmarshalJSON(data []byte) error {
+	v := struct {
+		MAC     string `json:"mac"`
+		Timeout uint16 `json:"timeout"` // Seconds
+	}{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	mac, err := net.ParseMAC(v.MAC)
+	if err != nil {
+		return err
+	}
+	if v.Timeout == 0 {
+		return errors.New("zero quarantine timeout")
+	}
+	r.MAC = mac
+	r.Timeout = time.Duration(v.Timeout) * time.Second
+
+	return nil
+}
@@ -0,0 +1,52 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestQuarantineParam(t *testing.T) {
+	src := []struct {
+		param   string
+		valid   bool
+		timeout time.Duration
+	}{
+		{`{"mac":"00:11:22:33:44:55","timeout":300}`, true, 300 * time.Second},
+		{`{"mac":"00:11:22:33:44:55"}`, false, 0},
+		{`{"mac":"invalid","timeout":300}`, false, 0},
+		{`{"mac":"00:11:22:33:44:55","timeout":65536}`, false, 0},
+	}
+
+	for i, v := range src {
+		p := new(quarantineParam)
+		err := json.Unmarshal([]byte(v.param), p)
+		if (err == nil) != v.valid {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if err == nil && (p.Timeout != v.timeout || p.MAC.String() != "00:11:22:33:44:55") {
+			t.Fatalf("#%v: unexpected param: %+v", i, p)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/superkkt/cherry/api"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+type device struct {
+	DPID         string `json:"dpid"`
+	Site         string `json:"site"`
+	NumBuffers   uint32 `json:"n_buffers"`
+	NumTables    uint8  `json:"n_tables"`
+	Capabilities uint32 `json:"capabilities"`
+	NumPorts     int    `json:"n_ports"`
+	NumAux       int    `json:"n_aux_connections"`
+	// Number of the outbound messages waiting to be written to the device.
+	WriteQueueDepth int         `json:"write_queue_depth"`
+	Description     description `json:"description"`
+}
+
+// description is the description reply of a device. The fields are empty if the device has not replied yet.
+type description struct {
+	Manufacturer string `json:"manufacturer"`
+	Hardware     string `json:"hardware"`
+	Software     string `json:"software"`
+	Serial       string `json:"serial"`
+	Datapath     string `json:"datapath"`
+}
+
+func (r *API) listDevices(w api.ResponseWriter, req *rest.Request) {
+	logger.Debugf("device list request from %v", req.RemoteAddr)
+
+	result := []device{}
+	for _, d := range r.Monitor.Devices() {
+		if d.IsClosed() {
+			continue
+		}
+		features := d.Features()
+		desc := d.Descriptions()
+		result = append(result, device{
+			DPID:            d.ID(),
+			Site:            d.Site(),
+			NumBuffers:      features.NumBuffers,
+			NumTables:       features.NumTables,
+			Capabilities:    features.Capabilities,
+			NumPorts:        len(d.Ports()),
+			NumAux:          d.AuxChannels(),
+			WriteQueueDepth: d.WriteQueueDepth(),
+			Description: description{
+				Manufacturer: desc.Manufacturer,
+				Hardware:     desc.Hardware,
+				Software:     desc.Software,
+				Serial:       desc.Serial,
+				Datapath:     desc.Description,
+			},
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].DPID < result[j].DPID })
+
+	w.Write(api.Response{Status: api.StatusOkay, Data: result})
+}
+
+type port struct {
+	Number uint32 `json:"number"`
+	MAC    string `json:"mac"`
+	Name   string `json:"name"`
+	// Whether the port is administratively up.
+	AdminUp bool `json:"admin_up"`
+	// Whether the physical link of the port is up.
+	LinkUp bool `json:"link_up"`
+	// Last sample of the port statistics. Nil if the port has not been polled yet.
+	Stats *portStats `json:"stats,omitempty"`
+}
+
+type portStats struct {
+	RxPackets    uint64 `json:"rx_packets"`
+	TxPackets    uint64 `json:"tx_packets"`
+	RxBytes      uint64 `json:"rx_bytes"`
+	TxBytes      uint64 `json:"tx_bytes"`
+	RxDropped    uint64 `json:"rx_dropped"`
+	TxDropped    uint64 `json:"tx_dropped"`
+	RxErrors     uint64 `json:"rx_errors"`
+	TxErrors     uint64 `json:"tx_errors"`
+	RxBitsPerSec uint64 `json:"rx_bps"`
+	TxBitsPerSec uint64 `json:"tx_bps"`
+}
+
+func (r *API) listPorts(w api.ResponseWriter, req *rest.Request) {
+	dpid := req.PathParam("dpid")
+	logger.Debugf("port list request from %v: dpid=%v", req.RemoteAddr, dpid)
+
+	d := r.Monitor.Device(dpid)
+	if d == nil || d.IsClosed() {
+		w.Write(api.Response{Status: api.StatusNotFound, Message: fmt.Sprintf("unknown device: %v", dpid)})
+		return
+	}
+
+	result := []port{}
+	for _, v := range d.PortValues() {
+		item := port{
+			Number:  v.Number(),
+			MAC:     v.MAC().String(),
+			Name:    v.Name(),
+			AdminUp: !v.IsPortDown(),
+			LinkUp:  !v.IsLinkDown(),
+		}
+		if s, ok := d.PortStats(v.Number()); ok {
+			item.Stats = &portStats{
+				RxPackets:    s.RxPackets,
+				TxPackets:    s.TxPackets,
+				RxBytes:      s.RxBytes,
+				TxBytes:      s.TxBytes,
+				RxDropped:    s.RxDropped,
+				TxDropped:    s.TxDropped,
+				RxErrors:     s.RxErrors,
+				TxErrors:     s.TxErrors,
+				RxBitsPerSec: s.RxBitsPerSec,
+				TxBitsPerSec: s.TxBitsPerSec,
+			}
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Number < result[j].Number })
+
+	w.Write(api.Response{Status: api.StatusOkay, Data: result})
+}
+
+type host struct {
+	MAC  string `json:"mac"`
+	DPID string `json:"dpid"`
+	Port uint32 `json:"port"`
+	// Last time the host has been seen on the port.
+	LastSeen time.Time `json:"last_seen"`
+}
+
+func (r *API) listHosts(w api.ResponseWriter, req *rest.Request) {
+	logger.Debugf("host list request from %v", req.RemoteAddr)
+
+	nodes, err := r.Monitor.AllNodes()
+	if err != nil {
+		w.Write(api.Response{Status: api.StatusInternalServerError, Message: fmt.Sprintf("failed to query the hosts: %v", err.Error())})
+		return
+	}
+
+	result := []host{}
+	for _, v := range nodes {
+		result = append(result, host{
+			MAC:      v.MAC().String(),
+			DPID:     v.Port().Device().ID(),
+			Port:     v.Port().Number(),
+			LastSeen: v.LastSeen(),
+		})
+	}
+
+	w.Write(api.Response{Status: api.StatusOkay, Data: result})
+}
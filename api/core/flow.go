@@ -0,0 +1,419 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package core
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/superkkt/cherry/api"
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/openflow"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// adminHandler wraps f so that it is only called for the requests that have the admin token in the
+// Authorization header, e.g., "Authorization: Bearer <token>". The admin APIs are disabled if the
+// admin token is empty.
+func (r *API) adminHandler(f func(api.ResponseWriter, *rest.Request)) func(api.ResponseWriter, *rest.Request) {
+	return func(w api.ResponseWriter, req *rest.Request) {
+		if r.AdminToken == "" {
+			w.Write(api.Response{Status: api.StatusPermissionDenied, Message: "admin API is disabled"})
+			return
+		}
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			logger.Warningf("admin request without a bearer token from %v", req.RemoteAddr)
+			w.Write(api.Response{Status: api.StatusIncorrectCredential, Message: "invalid admin token"})
+			return
+		}
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(r.AdminToken)) != 1 {
+			logger.Warningf("admin request with an invalid token from %v", req.RemoteAddr)
+			w.Write(api.Response{Status: api.StatusIncorrectCredential, Message: "invalid admin token"})
+			return
+		}
+		f(w, req)
+	}
+}
+
+func (r *API) addFlow(w api.ResponseWriter, req *rest.Request) {
+	dpid := req.PathParam("dpid")
+	p := new(flowParam)
+	if err := req.DecodeJsonPayload(p); err != nil {
+		w.Write(api.Response{Status: api.StatusInvalidParameter, Message: fmt.Sprintf("failed to decode param: %v", err.Error())})
+		return
+	}
+	logger.Debugf("add flow request from %v: dpid=%v, param=%v", req.RemoteAddr, dpid, spew.Sdump(p))
+
+	r.installFlow(w, dpid, p, openflow.FlowAdd)
+}
+
+func (r *API) removeFlow(w api.ResponseWriter, req *rest.Request) {
+	dpid := req.PathParam("dpid")
+	p := new(flowParam)
+	if err := req.DecodeJsonPayload(p); err != nil {
+		w.Write(api.Response{Status: api.StatusInvalidParameter, Message: fmt.Sprintf("failed to decode param: %v", err.Error())})
+		return
+	}
+	logger.Debugf("remove flow request from %v: dpid=%v, param=%v", req.RemoteAddr, dpid, spew.Sdump(p))
+
+	r.installFlow(w, dpid, p, openflow.FlowDelete)
+}
+
+func (r *API) installFlow(w api.ResponseWriter, dpid string, p *flowParam, cmd openflow.FlowModCmd) {
+	d := r.Monitor.Device(dpid)
+	if d == nil || d.IsClosed() || d.Factory() == nil {
+		w.Write(api.Response{Status: api.StatusNotFound, Message: fmt.Sprintf("unknown device: %v", dpid)})
+		return
+	}
+	// OpenFlow 1.0 has no cookie mask, so a delete flow-mod would also remove the flows of the applications.
+	if cmd == openflow.FlowDelete && d.Factory().ProtocolVersion() == openflow.OF10_VERSION {
+		w.Write(api.Response{Status: api.StatusInvalidParameter, Message: "removing static flows is not supported on OpenFlow 1.0 devices"})
+		return
+	}
+	if err := p.validatePorts(d); err != nil {
+		w.Write(api.Response{Status: api.StatusInvalidParameter, Message: err.Error()})
+		return
+	}
+
+	flow, err := p.flowMod(d.Factory(), cmd, r.AdminCookie)
+	if err == nil {
+		if cmd == openflow.FlowAdd {
+			flow.SetTableID(d.FlowTableID())
+		}
+		err = openflow.ValidateFlowMod(flow)
+	}
+	if err != nil {
+		w.Write(api.Response{Status: api.StatusInvalidParameter, Message: fmt.Sprintf("invalid flow: %v", err.Error())})
+		return
+	}
+
+	// The static flows are installed again when the device reconnects.
+	if err := d.InstallStaticFlow(flow); err != nil {
+		// The flows rejected by the switch are also caused by the client's parameters.
+		if _, ok := err.(*openflow.FlowModError); ok {
+			w.Write(api.Response{Status: api.StatusInvalidParameter, Message: fmt.Sprintf("flow rejected by the device: %v", err.Error())})
+			return
+		}
+		w.Write(api.Response{Status: api.StatusInternalServerError, Message: fmt.Sprintf("failed to install the flow: %v", err.Error())})
+		return
+	}
+
+	w.Write(api.Response{Status: api.StatusOkay})
+}
+
+// DefaultFlowPriority is the priority of the static flows whose priority is not specified. It is in the middle
+// of network.PriorityBandPolicy so that the static flows override the forwarding flows of the applications.
+var DefaultFlowPriority = (network.PriorityBandPolicy.Min + network.PriorityBandPolicy.Max) / 2
+
+// flowParam is a static flow specified by an operator. Empty actions mean an explicit drop.
+type flowParam struct {
+	// Zero means DefaultFlowPriority.
+	Priority    uint16        `json:"priority"`
+	IdleTimeout uint16        `json:"idle_timeout"` // Seconds
+	HardTimeout uint16        `json:"hard_timeout"` // Seconds
+	Match       matchParam    `json:"match"`
+	Actions     []actionParam `json:"actions"`
+}
+
+// matchParam is the match of a flowParam. Nil fields are wildcards.
+type matchParam struct {
+	InPort       *uint32 `json:"in_port"`
+	EtherType    *uint16 `json:"eth_type"`
+	SrcMAC       *string `json:"src_mac"`
+	DstMAC       *string `json:"dst_mac"`
+	VLANID       *uint16 `json:"vlan_id"`
+	VLANPriority *uint8  `json:"vlan_priority"`
+	IPProtocol   *uint8  `json:"ip_proto"`
+	IPDSCP       *uint8  `json:"ip_dscp"`
+	// IPv4 or IPv6 address with an optional prefix length, e.g., 10.0.0.0/8.
+	SrcIP   *string `json:"src_ip"`
+	DstIP   *string `json:"dst_ip"`
+	SrcPort *uint16 `json:"src_port"`
+	DstPort *uint16 `json:"dst_port"`
+}
+
+// actionParam is an action of a flowParam. Type is one of output, set_vlan_id, strip_vlan, set_src_mac,
+// set_dst_mac, and set_queue.
+type actionParam struct {
+	Type string `json:"type"`
+	// Port number, or one of controller, flood, all, and in_port.
+	Port   portParam `json:"port"`
+	VLANID uint16    `json:"vlan_id"`
+	MAC    string    `json:"mac"`
+	Queue  uint32    `json:"queue"`
+}
+
+// portParam is an output port that is specified by its number or name.
+type portParam string
+
+func (r *portParam) UnmarshalJSON(data []byte) error {
+	var num uint32
+	if err := json.Unmarshal(data, &num); err == nil {
+		*r = portParam(strconv.FormatUint(uint64(num), 10))
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("invalid port: %v", string(data))
+	}
+	*r = portParam(name)
+
+	return nil
+}
+
+func (r portParam) outPort() (openflow.OutPort, error) {
+	port := openflow.NewOutPort()
+	switch strings.ToLower(string(r)) {
+	case "controller":
+		port.SetController()
+	case "flood":
+		port.SetFlood()
+	case "all":
+		port.SetAll()
+	case "in_port":
+		port.SetInPort()
+	default:
+		num, err := strconv.ParseUint(string(r), 10, 32)
+		if err != nil || num == 0 {
+			return port, fmt.Errorf("invalid output port: %v", r)
+		}
+		port.SetValue(uint32(num))
+	}
+
+	return port, nil
+}
+
+// validatePorts checks that the physical ports referenced by the flow exist on the device.
+func (r *flowParam) validatePorts(d *network.Device) error {
+	if r.Match.InPort != nil && d.Port(*r.Match.InPort) == nil {
+		return fmt.Errorf("unknown input port: %v", *r.Match.InPort)
+	}
+	for _, v := range r.Actions {
+		if v.Type != "output" {
+			continue
+		}
+		port, err := v.Port.outPort()
+		if err != nil {
+			return err
+		}
+		if port.IsPhysical() && d.Port(port.Value()) == nil {
+			return fmt.Errorf("unknown output port: %v", port.Value())
+		}
+	}
+
+	return nil
+}
+
+// flowMod returns a flow-mod of the flow created by f. The flow-mod has the cookie of owner, so a delete
+// flow-mod only removes the flows of owner that match the flow. The actions are ignored for the delete.
+func (r *flowParam) flowMod(f openflow.Factory, cmd openflow.FlowModCmd, owner network.AppCookie) (openflow.FlowMod, error) {
+	match, err := r.Match.match(f)
+	if err != nil {
+		return nil, err
+	}
+	flow, err := network.NewAppFlowMod(f, cmd, owner)
+	if err != nil {
+		return nil, err
+	}
+	flow.SetFlowMatch(match)
+
+	if cmd == openflow.FlowDelete {
+		flow.SetTableID(0xFF) // ALL
+		port := openflow.NewOutPort()
+		port.SetNone()
+		flow.SetOutPort(port)
+		return flow, nil
+	}
+
+	// The idle timeout never expires the flow if it is longer than the hard timeout.
+	if r.HardTimeout > 0 && r.IdleTimeout > r.HardTimeout {
+		return nil, fmt.Errorf("idle timeout %v is longer than hard timeout %v", r.IdleTimeout, r.HardTimeout)
+	}
+	priority := r.Priority
+	if priority == 0 {
+		priority = DefaultFlowPriority
+	}
+	flow.SetPriority(priority)
+	flow.SetIdleTimeout(r.IdleTimeout)
+	flow.SetHardTimeout(r.HardTimeout)
+	// No instruction means an explicit drop.
+	if len(r.Actions) == 0 {
+		return flow, nil
+	}
+	action, err := f.NewAction()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range r.Actions {
+		if err := v.apply(action); err != nil {
+			return nil, err
+		}
+	}
+	inst, err := f.NewInstruction()
+	if err != nil {
+		return nil, err
+	}
+	inst.ApplyAction(action)
+	flow.SetFlowInstruction(inst)
+
+	return flow, nil
+}
+
+func (r *matchParam) match(f openflow.Factory) (openflow.Match, error) {
+	match, err := f.NewMatch()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.InPort != nil {
+		port := openflow.NewInPort()
+		port.SetValue(*r.InPort)
+		match.SetInPort(port)
+	}
+	if r.EtherType != nil {
+		match.SetEtherType(*r.EtherType)
+	}
+	if r.SrcMAC != nil {
+		mac, err := net.ParseMAC(*r.SrcMAC)
+		if err != nil {
+			return nil, err
+		}
+		match.SetSrcMAC(mac)
+	}
+	if r.DstMAC != nil {
+		mac, err := net.ParseMAC(*r.DstMAC)
+		if err != nil {
+			return nil, err
+		}
+		match.SetDstMAC(mac)
+	}
+	if r.VLANID != nil {
+		match.SetVLANID(*r.VLANID)
+	}
+	if r.VLANPriority != nil {
+		match.SetVLANPriority(*r.VLANPriority)
+	}
+	if r.IPProtocol != nil {
+		match.SetIPProtocol(*r.IPProtocol)
+	}
+	if r.IPDSCP != nil {
+		match.SetIPDSCP(*r.IPDSCP)
+	}
+	if r.SrcIP != nil {
+		ip, err := parseIPNet(*r.SrcIP)
+		if err != nil {
+			return nil, err
+		}
+		if ip.IP.To4() != nil {
+			match.SetSrcIP(ip)
+		} else {
+			match.SetIPv6Src(ip)
+		}
+	}
+	if r.DstIP != nil {
+		ip, err := parseIPNet(*r.DstIP)
+		if err != nil {
+			return nil, err
+		}
+		if ip.IP.To4() != nil {
+			match.SetDstIP(ip)
+		} else {
+			match.SetIPv6Dst(ip)
+		}
+	}
+	if r.SrcPort != nil {
+		match.SetSrcPort(*r.SrcPort)
+	}
+	if r.DstPort != nil {
+		match.SetDstPort(*r.DstPort)
+	}
+	// Unsupported match fields of the OpenFlow version are reported by the match.
+	if err := match.Error(); err != nil {
+		return nil, err
+	}
+
+	return match, nil
+}
+
+// parseIPNet parses an IP address with an optional prefix length. The address without a prefix length
+// is a host address.
+func parseIPNet(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		ip, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		ipnet.IP = ip.Mask(ipnet.Mask)
+		return ipnet, nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address: %v", s)
+	}
+	if v := ip.To4(); v != nil {
+		return &net.IPNet{IP: v, Mask: net.CIDRMask(32, 32)}, nil
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+func (r *actionParam) apply(action openflow.Action) error {
+	switch r.Type {
+	case "output":
+		port, err := r.Port.outPort()
+		if err != nil {
+			return err
+		}
+		action.SetOutPort(port)
+	case "set_vlan_id":
+		action.SetVLANID(r.VLANID)
+	case "strip_vlan":
+		action.SetStripVLAN()
+	case "set_src_mac", "set_dst_mac":
+		mac, err := net.ParseMAC(r.MAC)
+		if err != nil {
+			return err
+		}
+		if r.Type == "set_src_mac" {
+			action.SetSrcMAC(mac)
+		} else {
+			action.SetDstMAC(mac)
+		}
+	case "set_queue":
+		action.SetQueue(r.Queue)
+	default:
+		return errors.New("unknown action type: " + r.Type)
+	}
+
+	// Unsupported actions of the OpenFlow version are reported by the action.
+	return action.Error()
+}
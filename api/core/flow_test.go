@@ -0,0 +1,141 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package core
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestFlowParam(t *testing.T) {
+	src := []struct {
+		factory openflow.Factory
+		param   string
+		valid   bool
+	}{
+		{of13.NewFactory(), `{"priority":100,"match":{"eth_type":2048,"dst_ip":"10.0.0.0/8"},"actions":[]}`, true},
+		{of13.NewFactory(), `{"priority":100,"match":{"in_port":1,"dst_mac":"00:00:00:00:00:02"},"actions":[{"type":"output","port":2},{"type":"output","port":"controller"}]}`, true},
+		{of13.NewFactory(), `{"match":{"eth_type":34525,"src_ip":"2001:db8::1"},"actions":[{"type":"set_dst_mac","mac":"00:00:00:00:00:01"},{"type":"output","port":"flood"}]}`, true},
+		{of10.NewFactory(), `{"match":{"eth_type":2048,"src_ip":"10.0.0.1","ip_proto":6,"dst_port":80},"actions":[{"type":"output","port":3}]}`, true},
+		// IP address without the IP ethernet type.
+		{of13.NewFactory(), `{"match":{"dst_ip":"10.0.0.0/8"}}`, false},
+		// L4 port without the IP protocol.
+		{of13.NewFactory(), `{"match":{"eth_type":2048,"dst_port":80}}`, false},
+		// OpenFlow 1.0 does not support IPv6.
+		{of10.NewFactory(), `{"match":{"eth_type":34525,"src_ip":"2001:db8::1"}}`, false},
+		{of13.NewFactory(), `{"match":{"src_mac":"invalid"}}`, false},
+		{of13.NewFactory(), `{"match":{},"actions":[{"type":"unknown"}]}`, false},
+		{of13.NewFactory(), `{"match":{},"actions":[{"type":"output","port":0}]}`, false},
+		{of13.NewFactory(), `{"match":{},"actions":[{"type":"output","port":"nowhere"}]}`, false},
+	}
+
+	for i, v := range src {
+		p := new(flowParam)
+		if err := json.Unmarshal([]byte(v.param), p); err != nil {
+			t.Fatalf("#%v: failed to decode the param: %v", i, err)
+		}
+		flow, err := p.flowMod(v.factory, openflow.FlowAdd, 0)
+		if err == nil {
+			err = openflow.ValidateFlowMod(flow)
+		}
+		if v.valid && err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if !v.valid && err == nil {
+			t.Fatalf("#%v: expected an error, but got nil", i)
+		}
+	}
+}
+
+func TestFlowParamDelete(t *testing.T) {
+	p := new(flowParam)
+	if err := json.Unmarshal([]byte(`{"match":{"dst_mac":"00:00:00:00:00:02"},"actions":[{"type":"unknown"}]}`), p); err != nil {
+		t.Fatalf("failed to decode the param: %v", err)
+	}
+	// The actions are ignored for the delete.
+	flow, err := p.flowMod(of13.NewFactory(), openflow.FlowDelete, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := openflow.ValidateFlowMod(flow); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if flow.TableID() != 0xFF {
+		t.Fatalf("unexpected table ID: expected=255, got=%v", flow.TableID())
+	}
+	if flow.FlowInstruction() != nil {
+		t.Fatalf("unexpected instruction for the delete")
+	}
+}
+
+func TestFlowParamPriorityAndTimeouts(t *testing.T) {
+	src := []struct {
+		factory openflow.Factory
+		param   string
+		// Offsets of the idle timeout, hard timeout and priority in the flow-mod message.
+		offset   int
+		idle     uint16
+		hard     uint16
+		priority uint16
+		valid    bool
+	}{
+		{of13.NewFactory(), `{"priority":100,"idle_timeout":30,"hard_timeout":300,"match":{}}`, 26, 30, 300, 100, true},
+		{of10.NewFactory(), `{"priority":100,"idle_timeout":30,"hard_timeout":300,"match":{}}`, 58, 30, 300, 100, true},
+		// Default priority.
+		{of13.NewFactory(), `{"hard_timeout":60,"match":{}}`, 26, 0, 60, DefaultFlowPriority, true},
+		{of10.NewFactory(), `{"idle_timeout":60,"match":{}}`, 58, 60, 0, DefaultFlowPriority, true},
+		// Idle timeout longer than the hard timeout.
+		{of13.NewFactory(), `{"idle_timeout":60,"hard_timeout":30,"match":{}}`, 26, 0, 0, 0, false},
+	}
+
+	for i, v := range src {
+		p := new(flowParam)
+		if err := json.Unmarshal([]byte(v.param), p); err != nil {
+			t.Fatalf("#%v: failed to decode the param: %v", i, err)
+		}
+		flow, err := p.flowMod(v.factory, openflow.FlowAdd, 0)
+		if !v.valid {
+			if err == nil {
+				t.Fatalf("#%v: expected an error, but got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		packet, err := flow.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal the flow: %v", i, err)
+		}
+		idle := binary.BigEndian.Uint16(packet[v.offset : v.offset+2])
+		hard := binary.BigEndian.Uint16(packet[v.offset+2 : v.offset+4])
+		priority := binary.BigEndian.Uint16(packet[v.offset+4 : v.offset+6])
+		if idle != v.idle || hard != v.hard || priority != v.priority {
+			t.Fatalf("#%v: unexpected flow-mod: expected=(idle=%v, hard=%v, priority=%v), got=(idle=%v, hard=%v, priority=%v)", i, v.idle, v.hard, v.priority, idle, hard, priority)
+		}
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/superkkt/go-logging"
@@ -40,13 +41,18 @@ var (
 )
 
 type Server struct {
-	Port uint16
-	TLS  struct {
+	// IP address to listen on. Empty string means all interfaces.
+	Address string
+	Port    uint16
+	TLS     struct {
 		Cert string // Path for a TLS certification file.
 		Key  string // Path for a TLS private key file.
 	}
 	Observer   Observer
 	Controller Controller
+	// Handler that serves the metrics at /metrics for the monitoring systems. Unlike the other APIs, the
+	// metrics are also served by the standby controllers. Nil means no metrics.
+	Metrics http.Handler
 }
 
 type Observer interface {
@@ -57,6 +63,8 @@ type Controller interface {
 	Announce(net.IP, net.HardwareAddr) error
 	RemoveFlows() error
 	RemoveFlowsByMAC(net.HardwareAddr) error
+	// Quarantine drops all the packets from the host until the timeout expires.
+	Quarantine(net.HardwareAddr, time.Duration) error
 }
 
 func (r *Server) validate() error {
@@ -99,12 +107,17 @@ func (r *Server) Serve(routes ...*rest.Route) error {
 	}
 	api.SetApp(router)
 
-	// Listen on all interfaces.
-	addr := fmt.Sprintf(":%v", r.Port)
+	mux := http.NewServeMux()
+	mux.Handle("/", api.MakeHandler())
+	if r.Metrics != nil {
+		mux.Handle("/metrics", r.Metrics)
+	}
+
+	addr := net.JoinHostPort(r.Address, fmt.Sprintf("%v", r.Port))
 	if r.TLS.Cert != "" && r.TLS.Key != "" {
-		err = http.ListenAndServeTLS(addr, r.TLS.Cert, r.TLS.Key, api.MakeHandler())
+		err = http.ListenAndServeTLS(addr, r.TLS.Cert, r.TLS.Key, mux)
 	} else {
-		err = http.ListenAndServe(addr, api.MakeHandler())
+		err = http.ListenAndServe(addr, mux)
 	}
 
 	return err
@@ -0,0 +1,171 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a clock that only moves forward by Advance. It is intended to be used in tests.
+type Fake struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFake returns a fake clock whose current time is now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (r *Fake) Now() time.Time {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.now
+}
+
+func (r *Fake) Since(t time.Time) time.Duration {
+	return r.Now().Sub(t)
+}
+
+// Advance moves the current time forward by d, and then fires the timers and tickers that have expired.
+func (r *Fake) Advance(d time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.now = r.now.Add(d)
+	for _, w := range r.waiters {
+		w.fire(r.now)
+	}
+}
+
+func (r *Fake) NewTimer(d time.Duration) Timer {
+	return r.newWaiter(d, false)
+}
+
+func (r *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	return &fakeTicker{r.newWaiter(d, true)}
+}
+
+func (r *Fake) newWaiter(d time.Duration, repeat bool) *fakeWaiter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	w := &fakeWaiter{
+		clock:    r,
+		c:        make(chan time.Time, 1),
+		deadline: r.now.Add(d),
+		interval: d,
+		repeat:   repeat,
+		active:   true,
+	}
+	r.waiters = append(r.waiters, w)
+	// Expired already?
+	w.fire(r.now)
+
+	return w
+}
+
+func (r *Fake) removeWaiter(w *fakeWaiter) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, v := range r.waiters {
+		if v == w {
+			r.waiters = append(r.waiters[:i], r.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// fakeWaiter implements the Timer interface, and also the Ticker interface via fakeTicker.
+type fakeWaiter struct {
+	clock    *Fake
+	c        chan time.Time
+	deadline time.Time
+	interval time.Duration
+	repeat   bool
+	active   bool
+}
+
+// XXX: Caller should lock the clock's mutex.
+func (r *fakeWaiter) fire(now time.Time) {
+	if !r.active || now.Before(r.deadline) {
+		return
+	}
+
+	// Drop the tick if the previous one has not been consumed yet, like time.Ticker does.
+	select {
+	case r.c <- now:
+	default:
+	}
+
+	if !r.repeat {
+		r.active = false
+		return
+	}
+	for !now.Before(r.deadline) {
+		r.deadline = r.deadline.Add(r.interval)
+	}
+}
+
+func (r *fakeWaiter) C() <-chan time.Time {
+	return r.c
+}
+
+func (r *fakeWaiter) Stop() bool {
+	r.clock.mutex.Lock()
+	active := r.active
+	r.active = false
+	r.clock.mutex.Unlock()
+
+	r.clock.removeWaiter(r)
+
+	return active
+}
+
+func (r *fakeWaiter) Reset(d time.Duration) bool {
+	active := r.Stop()
+
+	r.clock.mutex.Lock()
+	r.deadline = r.clock.now.Add(d)
+	r.active = true
+	r.clock.waiters = append(r.clock.waiters, r)
+	r.fire(r.clock.now)
+	r.clock.mutex.Unlock()
+
+	return active
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (r *fakeTicker) Stop() {
+	r.fakeWaiter.Stop()
+}
@@ -0,0 +1,83 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeTimer(t *testing.T) {
+	c := NewFake(time.Unix(0, 0))
+	timer := c.NewTimer(10 * time.Second)
+
+	c.Advance(9 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatalf("timer fired too early")
+	default:
+	}
+
+	c.Advance(1 * time.Second)
+	select {
+	case v := <-timer.C():
+		if !v.Equal(time.Unix(10, 0)) {
+			t.Fatalf("unexpected fired time: %v", v)
+		}
+	default:
+		t.Fatalf("timer did not fire")
+	}
+
+	// Stopped timer should not fire.
+	timer.Reset(5 * time.Second)
+	if timer.Stop() == false {
+		t.Fatalf("expected an active timer")
+	}
+	c.Advance(10 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatalf("stopped timer fired")
+	default:
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	c := NewFake(time.Unix(0, 0))
+	ticker := c.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for i := 1; i <= 3; i++ {
+		c.Advance(time.Second)
+		select {
+		case v := <-ticker.C():
+			if !v.Equal(time.Unix(int64(i), 0)) {
+				t.Fatalf("unexpected tick: %v", v)
+			}
+		default:
+			t.Fatalf("ticker did not tick: #%v", i)
+		}
+	}
+
+	if d := c.Since(time.Unix(0, 0)); d != 3*time.Second {
+		t.Fatalf("unexpected elapsed time: %v", d)
+	}
+}
@@ -23,13 +23,17 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"net"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -39,8 +43,10 @@ import (
 	"github.com/superkkt/cherry/database"
 	"github.com/superkkt/cherry/election"
 	"github.com/superkkt/cherry/log"
+	"github.com/superkkt/cherry/metrics"
 	"github.com/superkkt/cherry/network"
 	"github.com/superkkt/cherry/northbound"
+	"github.com/superkkt/cherry/openflow/transceiver"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/pkg/errors"
@@ -59,6 +65,8 @@ var (
 	loggerLeveled     logging.LeveledBackend
 	showVersion       = flag.Bool("version", false, "Show program version and exit")
 	defaultConfigFile = flag.String("config", fmt.Sprintf("/usr/local/etc/%v.yaml", programName), "absolute path of the configuration file")
+	// Source addresses of the switches allowed to connect, which is reloaded whenever the config file changes.
+	allowList atomic.Value // *network.AllowList
 )
 
 func main() {
@@ -82,6 +90,11 @@ func main() {
 
 	observer := initElectionObserver(ctx, db)
 	controller := network.NewController(db)
+	resolver, err := network.NewSiteResolver(viper.GetStringSlice("default.sites"), viper.GetBool("default.reverse_dns"))
+	if err != nil {
+		logger.Fatalf("invalid default.sites: %v", err)
+	}
+	controller.SetResolver(resolver)
 	initAPIServer(observer, controller)
 	manager, err := createAppManager(db)
 	if err != nil {
@@ -91,7 +104,11 @@ func main() {
 
 	initSignalHandler(controller, manager, cancel)
 
-	listen(ctx, viper.GetInt("default.port"), controller, observer)
+	configs, err := getListenConfigs()
+	if err != nil {
+		logger.Fatalf("invalid listeners in the config file: %v", err)
+	}
+	listen(ctx, configs, controller, observer)
 }
 
 func initConfig() {
@@ -111,11 +128,29 @@ func initConfig() {
 			// Set log level for all modules
 			loggerLeveled.SetLevel(getLogLevel(viper.GetString("default.log_level")), "")
 		}
+		if list, err := network.ParseAllowList(viper.GetStringSlice("default.allowed_sources")); err != nil {
+			// Keep the previous one.
+			logger.Errorf("invalid default.allowed_sources in the config file: %v", err)
+		} else {
+			allowList.Store(list)
+			logger.Infof("switch connections are allowed from %v", list)
+		}
+		if _, err := network.ParseFloodMode(viper.GetString("flood.mode")); err != nil {
+			logger.Errorf("invalid flood.mode in the config file: packets will be flooded in the switch mode: %v", err)
+		}
+		if _, err := network.ParseFragHandling(viper.GetString("default.frag_handling")); err != nil {
+			logger.Errorf("invalid default.frag_handling in the config file: IP fragments will be handled normally: %v", err)
+		}
 	})
 	viper.WatchConfig()
 	if err := validateConfig(); err != nil {
 		logger.Fatalf("failed to validate the configuration: %v", err)
 	}
+	list, err := network.ParseAllowList(viper.GetStringSlice("default.allowed_sources"))
+	if err != nil {
+		logger.Fatalf("invalid default.allowed_sources: %v", err)
+	}
+	allowList.Store(list)
 }
 
 func validateConfig() error {
@@ -135,6 +170,108 @@ func validateConfig() error {
 	if vlanID < 0 || vlanID > 4095 {
 		return errors.New("invalid default.vlan_id in the config file")
 	}
+	if _, err := network.ParsePortConfig(viper.GetStringSlice("default.port_config")); err != nil {
+		return fmt.Errorf("invalid default.port_config: %v", err)
+	}
+	if _, err := network.ParseDHCPTrustedPorts(viper.GetStringSlice("default.dhcp_trusted_ports")); err != nil {
+		return fmt.Errorf("invalid default.dhcp_trusted_ports: %v", err)
+	}
+	if _, err := network.NewSiteResolver(viper.GetStringSlice("default.sites"), false); err != nil {
+		return fmt.Errorf("invalid default.sites: %v", err)
+	}
+	if _, err := network.ParseDPIDAllowList(viper.GetStringSlice("default.allowed_dpids")); err != nil {
+		return fmt.Errorf("invalid default.allowed_dpids: %v", err)
+	}
+	if viper.GetInt("default.read_timeout") < 0 {
+		return errors.New("invalid default.read_timeout")
+	}
+	if viper.GetInt("default.write_timeout") < 0 {
+		return errors.New("invalid default.write_timeout")
+	}
+	if viper.GetInt("default.confirm_timeout") < 0 {
+		return errors.New("invalid default.confirm_timeout")
+	}
+	if viper.GetInt("default.echo_interval") < 0 {
+		return errors.New("invalid default.echo_interval")
+	}
+	// The read timeout paces the liveness checks of the idle switches, so the echo requests would be delayed by
+	// a read timeout that is not shorter than the echo interval. Zero values mean the defaults.
+	readTimeout := time.Duration(viper.GetInt("default.read_timeout")) * time.Second
+	if readTimeout == 0 {
+		readTimeout = transceiver.DefaultReadTimeout
+	}
+	echoInterval := time.Duration(viper.GetInt("default.echo_interval")) * time.Second
+	if echoInterval == 0 {
+		echoInterval = transceiver.DefaultEchoInterval
+	}
+	if readTimeout >= echoInterval {
+		return errors.New("invalid default.read_timeout: it should be shorter than default.echo_interval")
+	}
+	if viper.GetInt("default.echo_max_misses") < 0 {
+		return errors.New("invalid default.echo_max_misses")
+	}
+	if viper.IsSet("default.max_message_size") {
+		if v := viper.GetInt("default.max_message_size"); v < transceiver.MinMessageSize || v > transceiver.MaxMessageSize {
+			return fmt.Errorf("invalid default.max_message_size: it should be between %v and %v", transceiver.MinMessageSize, transceiver.MaxMessageSize)
+		}
+	}
+	if viper.GetInt("default.app_flow_leak_threshold") < 0 {
+		return errors.New("invalid default.app_flow_leak_threshold")
+	}
+	if viper.GetInt("default.flow_stats_interval") < 0 {
+		return errors.New("invalid default.flow_stats_interval")
+	}
+	if viper.GetInt("default.packet_in_rate") < 0 {
+		return errors.New("invalid default.packet_in_rate")
+	}
+	if viper.GetInt("default.packet_in_burst") < 0 {
+		return errors.New("invalid default.packet_in_burst")
+	}
+	if v := viper.GetInt("default.packet_in_cooldown"); v < 0 || v > 0xFFFF {
+		return errors.New("invalid default.packet_in_cooldown")
+	}
+	if viper.GetInt("default.resume_grace") < 0 {
+		return errors.New("invalid default.resume_grace")
+	}
+	if viper.GetInt("default.port_stats_interval") < 0 {
+		return errors.New("invalid default.port_stats_interval")
+	}
+	if viper.GetInt("default.node_aging_timeout") < 0 {
+		return errors.New("invalid default.node_aging_timeout")
+	}
+	if viper.IsSet("default.miss_send_len") {
+		if v := viper.GetInt("default.miss_send_len"); v < 0 || v > 0xFFFF {
+			return errors.New("invalid default.miss_send_len")
+		}
+	}
+	if viper.IsSet("default.table_miss_max_len") {
+		// OpenFlow 1.3 reserves 0xFFE6-0xFFFE.
+		if v := viper.GetInt("default.table_miss_max_len"); v < 0 || (v > 0xFFE5 && v != 0xFFFF) {
+			return errors.New("invalid default.table_miss_max_len")
+		}
+	}
+	if _, err := network.ParseFloodMode(viper.GetString("flood.mode")); err != nil {
+		return fmt.Errorf("invalid flood.mode: %v", err)
+	}
+	if _, err := network.ParseFragHandling(viper.GetString("default.frag_handling")); err != nil {
+		return fmt.Errorf("invalid default.frag_handling: %v", err)
+	}
+	if addr := viper.GetString("rest.address"); addr != "" && net.ParseIP(addr) == nil {
+		return errors.New("invalid rest.address")
+	}
+	probe := viper.GetInt("lldp.probe_interval")
+	if probe < 0 {
+		return errors.New("invalid lldp.probe_interval")
+	}
+	if timeout := viper.GetInt("lldp.link_timeout"); timeout < 0 || (timeout > 0 && timeout <= probe) {
+		return errors.New("invalid lldp.link_timeout: it should be longer than lldp.probe_interval")
+	}
+	if viper.GetInt("flood.large_frame") < 0 {
+		return errors.New("invalid flood.large_frame")
+	}
+	if viper.GetInt("flood.min_speed") < 0 {
+		return errors.New("invalid flood.min_speed")
+	}
 
 	return nil
 }
@@ -152,8 +289,16 @@ func initElectionObserver(ctx context.Context, db *database.MySQL) *election.Obs
 }
 
 func initAPIServer(observer *election.Observer, controller *network.Controller) {
+	// The cookie of the admin APIs is registered before the applications so that the cookie ranges of the
+	// applications do not depend on the start of the API server.
+	adminCookie, err := network.RegisterAppCookie("AdminAPI")
+	if err != nil {
+		logger.Fatalf("failed to register the cookie of the admin APIs: %v", err)
+	}
+
 	go func() {
 		s := api.Server{}
+		s.Address = viper.GetString("rest.address")
 		s.Port = uint16(viper.GetInt("rest.port"))
 		if viper.GetBool("rest.tls") == true {
 			s.TLS.Cert = viper.GetString("rest.cert_file")
@@ -161,8 +306,18 @@ func initAPIServer(observer *election.Observer, controller *network.Controller)
 		}
 		s.Observer = observer
 		s.Controller = controller
-
-		srv := &core.API{Server: s}
+		metrics.DefaultRegistry.Register(controller)
+		s.Metrics = metrics.DefaultRegistry
+
+		srv := &core.API{
+			Server:      s,
+			Monitor:     controller,
+			AdminToken:  viper.GetString("rest.admin_token"),
+			AdminCookie: adminCookie,
+		}
+		for _, app := range strings.Split(viper.GetString("default.applications"), ",") {
+			srv.Applications = append(srv.Applications, strings.TrimSpace(app))
+		}
 		if err := srv.Serve(); err != nil {
 			logger.Fatalf("failed to run the API server: %v", err)
 		}
@@ -181,6 +336,10 @@ func initSignalHandler(controller *network.Controller, manager *northbound.Manag
 			if s == syscall.SIGTERM || s == syscall.SIGINT {
 				// Graceful shutdown
 				logger.Warning("Shutting down...")
+				// Flush the messages in flight before closing the switch connections.
+				if err := controller.DrainAll(5 * time.Second); err != nil {
+					logger.Errorf("failed to drain the devices: %v", err)
+				}
 				cancel()
 				// Timeout for cancelation
 				time.Sleep(5 * time.Second)
@@ -221,28 +380,124 @@ func getLogLevel(level string) logging.Level {
 	return ret
 }
 
-func listen(ctx context.Context, port int, controller *network.Controller, observer *election.Observer) {
-	type KeepAliver interface {
-		SetKeepAlive(keepalive bool) error
-		SetKeepAlivePeriod(d time.Duration) error
+type listenConfig struct {
+	Addr     string `mapstructure:"addr"`
+	TLS      bool   `mapstructure:"tls"`
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// CAFile is the CA bundle to verify the switch certificates. Mutual authentication is disabled if it is empty.
+	CAFile string `mapstructure:"ca_file"`
+}
+
+// getListenConfigs returns the default listen endpoint and the additional ones in the config file.
+func getListenConfigs() ([]listenConfig, error) {
+	v := []listenConfig{{Addr: fmt.Sprintf(":%v", viper.GetInt("default.port"))}}
+
+	extra := make([]listenConfig, 0)
+	if err := viper.UnmarshalKey("listeners", &extra); err != nil {
+		return nil, err
 	}
+	for _, c := range extra {
+		if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+			return nil, fmt.Errorf("invalid listen address: %v", c.Addr)
+		}
+		if c.TLS && (len(c.CertFile) == 0 || len(c.KeyFile) == 0) {
+			return nil, fmt.Errorf("missing TLS cert_file or key_file for %v", c.Addr)
+		}
+		if !c.TLS && len(c.CAFile) > 0 {
+			return nil, fmt.Errorf("ca_file requires TLS for %v", c.Addr)
+		}
+	}
+
+	return append(v, extra...), nil
+}
+
+func newListener(c listenConfig) (net.Listener, error) {
+	if !c.TLS {
+		return net.Listen("tcp", c.Addr)
+	}
+
+	config, err := newTLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return tls.Listen("tcp", c.Addr, config)
+}
 
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
+func newTLSConfig(c listenConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
 	if err != nil {
-		logger.Errorf("failed to listen on %v port: %v", port, err)
-		return
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if len(c.CAFile) == 0 {
+		logger.Warningf("switch certificates will not be verified on %v because ca_file is not specified", c.Addr)
+		return config, nil
+	}
+
+	ca, err := os.ReadFile(c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no valid CA certificate in %v", c.CAFile)
+	}
+	// Mutual authentication: reject the switches whose certificate is not signed by the CA.
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return config, nil
+}
+
+// handshake performs the TLS handshake if conn is a TLS connection so that we can reject the switches that fail
+// the certificate verification before handing the connection to the controller.
+func handshake(conn net.Conn) error {
+	c, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	defer c.SetDeadline(time.Time{})
+	if err := c.Handshake(); err != nil {
+		return err
+	}
+
+	state := c.ConnectionState()
+	subject := "none"
+	if len(state.PeerCertificates) > 0 {
+		subject = state.PeerCertificates[0].Subject.String()
+	}
+	logger.Infof("TLS handshake with %v succeeded: version=%v, cipher=%v, subject=%v", conn.RemoteAddr(), tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite), subject)
+
+	return nil
+}
+
+func listen(ctx context.Context, configs []listenConfig, controller *network.Controller, observer *election.Observer) {
+	type KeepAliver interface {
+		SetKeepAlive(keepalive bool) error
+		SetKeepAlivePeriod(d time.Duration) error
 	}
-	defer listener.Close()
 
 	// Connection dispatcher.
-	f := func(c chan<- net.Conn) {
+	f := func(listener net.Listener, c chan<- net.Conn) {
+		// Number of the accepted connections on this listener.
+		var count uint64
 		for {
 			conn, err := listener.Accept()
 			if err != nil {
-				logger.Errorf("failed to accept a new connection: %v", err)
+				logger.Errorf("failed to accept a new connection on %v: %v", listener.Addr(), err)
 				continue
 			}
-			logger.Infof("new device is connected from %v", conn.RemoteAddr())
+			if !allowList.Load().(*network.AllowList).Allowed(conn.RemoteAddr()) {
+				logger.Warningf("disconnecting the newly connected device (%v) because its address is not allowed", conn.RemoteAddr())
+				conn.Close()
+				continue
+			}
+			count++
+			logger.Infof("new device is connected from %v via %v (%v connections on this listener)", conn.RemoteAddr(), listener.Addr(), count)
 
 			// Only the master controller can serve the connections!
 			if observer.IsMaster() == false {
@@ -251,12 +506,31 @@ func listen(ctx context.Context, port int, controller *network.Controller, obser
 				continue
 			}
 
-			// Pass the new connection into the backlog queue.
-			c <- conn
+			// Handshake in a separate goroutine not to block the other switches that are connecting.
+			go func(conn net.Conn) {
+				if err := handshake(conn); err != nil {
+					logger.Errorf("disconnecting the newly connected device (%v) due to the TLS handshake failure: %v", conn.RemoteAddr(), err)
+					conn.Close()
+					return
+				}
+				// Pass the new connection into the backlog queue.
+				c <- conn
+			}(conn)
 		}
 	}
 	backlog := make(chan net.Conn, 32)
-	go f(backlog)
+
+	for _, c := range configs {
+		listener, err := newListener(c)
+		if err != nil {
+			logger.Errorf("failed to listen on %v: %v", c.Addr, err)
+			return
+		}
+		defer listener.Close()
+		logger.Infof("listening on %v (TLS=%v)", c.Addr, c.TLS)
+
+		go f(listener, backlog)
+	}
 
 	// Infinite loop
 	for {
@@ -266,7 +540,11 @@ func listen(ctx context.Context, port int, controller *network.Controller, obser
 			return
 		case conn := <-backlog:
 			logger.Debug("fetching a new connection from the backlog..")
-			if v, ok := conn.(KeepAliver); ok {
+			raw := conn
+			if v, ok := conn.(*tls.Conn); ok {
+				raw = v.NetConn()
+			}
+			if v, ok := raw.(KeepAliver); ok {
 				logger.Debug("trying to enable socket keepalive..")
 				if err := v.SetKeepAlive(true); err == nil {
 					logger.Debug("setting socket keepalive period...")
@@ -293,20 +571,41 @@ func createAppManager(db *database.MySQL) (*northbound.Manager, error) {
 		return nil, errors.Wrap(err, "failed to parse applications")
 	}
 	for _, v := range apps {
-		if err := manager.Enable(v); err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("enabling %v", v))
+		if err := manager.EnableWithPriority(v.name, v.priority); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("enabling %v", v.name))
 		}
 	}
 
 	return manager, nil
 }
 
-func parseApplications() ([]string, error) {
+type appConfig struct {
+	name     string
+	priority int
+}
+
+// parseApplications parses the application list whose elements are an application name optionally
+// followed by a colon and its priority, e.g., "Firewall:10, L2Switch".
+func parseApplications() ([]appConfig, error) {
 	// Remove spaces, and then split it using comma
 	tokens := strings.Split(strings.Replace(viper.GetString("default.applications"), " ", "", -1), ",")
 	if len(tokens) == 0 {
 		return nil, errors.New("empty application")
 	}
 
-	return tokens, nil
+	result := make([]appConfig, 0, len(tokens))
+	for _, v := range tokens {
+		app := appConfig{name: v, priority: northbound.DefaultPriority}
+		if i := strings.Index(v, ":"); i >= 0 {
+			priority, err := strconv.Atoi(v[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid application priority: %v", v)
+			}
+			app.name = v[:i]
+			app.priority = priority
+		}
+		result = append(result, app)
+	}
+
+	return result, nil
 }
@@ -167,7 +167,7 @@ func initDatabase() *database.MySQL {
 }
 
 func initCoreSDK() *coreSDK {
-	client, err := newCoreSDK(viper.GetString("core_api_url"))
+	client, err := newCoreSDK(viper.GetString("core_api_url"), viper.GetString("core_admin_token"))
 	if err != nil {
 		logger.Fatalf("failed to init the core API's SDK: %v", err)
 	}
@@ -24,16 +24,19 @@ import (
 
 type coreSDK struct {
 	baseURL string
-	client  *http.Client
+	// Bearer token for the admin APIs of the core, such as the quarantine. Empty means no token.
+	adminToken string
+	client     *http.Client
 }
 
-func newCoreSDK(baseURL string) (*coreSDK, error) {
+func newCoreSDK(baseURL, adminToken string) (*coreSDK, error) {
 	if _, err := url.Parse(baseURL); err != nil {
 		return nil, err
 	}
 
 	return &coreSDK{
-		baseURL: baseURL,
+		baseURL:    baseURL,
+		adminToken: adminToken,
 		client: &http.Client{
 			Transport: &http.Transport{
 				TLSHandshakeTimeout: 10 * time.Second,
@@ -70,6 +73,22 @@ func (r *coreSDK) RemoveFlowsByMAC(mac net.HardwareAddr) error {
 	return r.call("POST", "/api/v1/remove", arg, nil)
 }
 
+func (r *coreSDK) Quarantine(mac net.HardwareAddr, timeout time.Duration) error {
+	sec := timeout / time.Second
+	if sec <= 0 || sec > 0xFFFF {
+		return fmt.Errorf("invalid quarantine timeout: %v", timeout)
+	}
+	arg := &struct {
+		MAC     string `json:"mac"`
+		Timeout uint16 `json:"timeout"`
+	}{
+		MAC:     mac.String(),
+		Timeout: uint16(sec),
+	}
+
+	return r.call("POST", "/api/v1/quarantine", arg, nil)
+}
+
 func (r *coreSDK) IsMaster() bool {
 	res := new(struct {
 		Master bool `json:"master"`
@@ -94,6 +113,9 @@ func (r *coreSDK) call(method, command string, param interface{}, result interfa
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	if r.adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+r.adminToken)
+	}
 
 	resp, err := r.client.Do(req)
 	if err != nil {
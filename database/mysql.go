@@ -90,6 +90,9 @@ func NewMySQL() (*MySQL, error) {
 	if err := db.Ping(); err != nil {
 		return nil, err
 	}
+	if err := upgradeSchema(db); err != nil {
+		return nil, err
+	}
 
 	return &MySQL{
 		db:     db,
@@ -97,6 +100,28 @@ func NewMySQL() (*MySQL, error) {
 	}, nil
 }
 
+// upgradeSchema adds the columns that are missing in the database created from an older schema. See
+// mysql_upgrade.sql for the statements to upgrade the database manually.
+func upgradeSchema(db *sql.DB) error {
+	var n int
+	qry := "SELECT COUNT(*) FROM `information_schema`.`COLUMNS` "
+	qry += "WHERE `TABLE_SCHEMA` = DATABASE() AND `TABLE_NAME` = 'host' AND `COLUMN_NAME` = 'vlan_id'"
+	if err := db.QueryRow(qry).Scan(&n); err != nil {
+		return fmt.Errorf("failed to query the columns of the host table: %v", err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	logger.Warning("host.vlan_id column does not exist: adding it to the host table")
+	qry = "ALTER TABLE `host` ADD COLUMN `vlan_id` smallint(5) unsigned NOT NULL DEFAULT '0' AFTER `port_id`"
+	if _, err := db.Exec(qry); err != nil {
+		return fmt.Errorf("failed to add host.vlan_id column (apply database/mysql_upgrade.sql manually): %v", err)
+	}
+
+	return nil
+}
+
 func validateClusterAddr(addr string) error {
 	if len(addr) == 0 {
 		return errors.New("empty cluster address")
@@ -268,49 +293,65 @@ func (r *MySQL) MAC(ip net.IP) (mac net.HardwareAddr, ok bool, err error) {
 	return mac, ok, err
 }
 
-func (r *MySQL) Location(mac net.HardwareAddr) (dpid string, port uint32, status network.LocationStatus, err error) {
+// Locations returns all the discovered locations of the hosts whose MAC address is mac in the order
+// of the most recently updated one first.
+func (r *MySQL) Locations(mac net.HardwareAddr) (result []network.Location, status network.LocationStatus, err error) {
 	if mac == nil {
 		panic("MAC address is nil")
 	}
 
 	f := func(tx *sql.Tx) error {
 		// Initial value.
+		result = nil
 		status = network.LocationUnregistered
 
-		var portID sql.NullInt64
-		qry := "SELECT `port_id` FROM `host` WHERE `mac` = ? ORDER BY `port_id` DESC LOCK IN SHARE MODE"
-		if err := tx.QueryRow(qry, []byte(mac)).Scan(&portID); err != nil {
-			// Unregistered host?
-			if err == sql.ErrNoRows {
-				return nil
-			} else {
-				return err
-			}
-		}
-		// NULL port ID?
-		if portID.Valid == false {
-			// The node is registered, but we don't know its physical location yet.
-			status = network.LocationUndiscovered
-			return nil
+		qry := "SELECT C.`dpid`, B.`number`, A.`vlan_id`, A.`last_updated_timestamp` "
+		qry += "FROM `host` A "
+		qry += "LEFT JOIN `port` B ON A.`port_id` = B.`id` "
+		qry += "LEFT JOIN `switch` C ON B.`switch_id` = C.`id` "
+		qry += "WHERE A.`mac` = ? "
+		qry += "ORDER BY A.`last_updated_timestamp` DESC, A.`id` DESC "
+		qry += "LOCK IN SHARE MODE"
+
+		rows, err := tx.Query(qry, []byte(mac))
+		if err != nil {
+			return err
 		}
+		defer rows.Close()
 
-		qry = "SELECT B.`dpid`, A.`number` FROM `port` A JOIN `switch` B ON A.`switch_id` = B.`id` WHERE A.`id` = ?"
-		if err := tx.QueryRow(qry, portID.Int64).Scan(&dpid, &port); err != nil {
-			if err == sql.ErrNoRows { // FIXME: Is this possible?
-				return nil
-			} else {
+		for rows.Next() {
+			// The node is registered at least.
+			if status == network.LocationUnregistered {
+				status = network.LocationUndiscovered
+			}
+
+			var dpid sql.NullString
+			var port sql.NullInt64
+			var vlanID uint16
+			var timestamp time.Time
+			if err := rows.Scan(&dpid, &port, &vlanID, &timestamp); err != nil {
 				return err
 			}
+			// NULL port ID means that we don't know its physical location yet.
+			if dpid.Valid == false || port.Valid == false {
+				continue
+			}
+			result = append(result, network.Location{
+				DPID:      dpid.String,
+				Port:      uint32(port.Int64),
+				VLANID:    vlanID,
+				Timestamp: timestamp,
+			})
+			status = network.LocationDiscovered
 		}
-		status = network.LocationDiscovered
 
-		return nil
+		return rows.Err()
 	}
 	if err := r.query(f); err != nil {
-		return "", 0, network.LocationUnregistered, err
+		return nil, network.LocationUnregistered, err
 	}
 
-	return dpid, port, status, nil
+	return result, status, nil
 }
 
 func (r *MySQL) TogglePortVIP(swDPID uint64, portNum uint16) (result []virtualip.Address, err error) {
@@ -558,8 +599,9 @@ func (r *MySQL) GetUndiscoveredHosts(expiration time.Duration) (result []net.IPN
 
 // UpdateHostLocation updates the physical location of a host, whose MAC and IP
 // addresses are matched with mac and ip, to the port identified by swDPID and
-// portNum. updated will be true if its location has been actually updated.
-func (r *MySQL) UpdateHostLocation(mac net.HardwareAddr, ip net.IP, swDPID uint64, portNum uint16) (updated bool, err error) {
+// portNum in the VLAN whose ID is vlanID, which is zero for the untagged host.
+// updated will be true if its location has been actually updated.
+func (r *MySQL) UpdateHostLocation(mac net.HardwareAddr, ip net.IP, swDPID uint64, portNum, vlanID uint16) (updated bool, err error) {
 	f := func(tx *sql.Tx) error {
 		hostID, ok, err := getHostID(tx, mac, ip)
 		if err != nil {
@@ -576,7 +618,7 @@ func (r *MySQL) UpdateHostLocation(mac net.HardwareAddr, ip net.IP, swDPID uint6
 			return err
 		}
 
-		updated, err = updateLocation(tx, hostID, portID)
+		updated, err = updateLocation(tx, hostID, portID, vlanID)
 		if err != nil {
 			return err
 		}
@@ -615,10 +657,10 @@ func getHostID(tx *sql.Tx, mac net.HardwareAddr, ip net.IP) (hostID uint64, ok b
 	return hostID, true, nil
 }
 
-func updateLocation(tx *sql.Tx, hostID, portID uint64) (updated bool, err error) {
+func updateLocation(tx *sql.Tx, hostID, portID uint64, vlanID uint16) (updated bool, err error) {
 	var id uint64
-	qry := "SELECT `id` FROM `host` WHERE `id` = ? AND `port_id` = ?"
-	err = tx.QueryRow(qry, hostID, portID).Scan(&id)
+	qry := "SELECT `id` FROM `host` WHERE `id` = ? AND `port_id` = ? AND `vlan_id` = ?"
+	err = tx.QueryRow(qry, hostID, portID, vlanID).Scan(&id)
 	// Real error?
 	if err != nil && err != sql.ErrNoRows {
 		return false, err
@@ -628,8 +670,8 @@ func updateLocation(tx *sql.Tx, hostID, portID uint64) (updated bool, err error)
 	if err == sql.ErrNoRows {
 		updated = true
 	}
-	qry = "UPDATE `host` SET `port_id` = ?, `last_updated_timestamp` = NOW() WHERE `id` = ?"
-	_, err = tx.Exec(qry, portID, hostID)
+	qry = "UPDATE `host` SET `port_id` = ?, `vlan_id` = ?, `last_updated_timestamp` = NOW() WHERE `id` = ?"
+	_, err = tx.Exec(qry, portID, vlanID, hostID)
 	if err != nil {
 		return false, err
 	}
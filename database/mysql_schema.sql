@@ -242,6 +242,7 @@ CREATE TABLE IF NOT EXISTS `host` (
   `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
   `ip_id` bigint(20) unsigned NOT NULL,
   `port_id` bigint(20) unsigned default NULL,
+  `vlan_id` smallint(5) unsigned NOT NULL DEFAULT '0',
   `group_id` bigint(20) unsigned default NULL,
   `mac` binary(6) NOT NULL,
   `description` varchar(255) NOT NULL,
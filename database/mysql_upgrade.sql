@@ -0,0 +1,8 @@
+-- Statements to upgrade a database that has been created from an older mysql_schema.sql. The controller applies
+-- them on startup if it has the privileges, otherwise apply them manually before starting the controller.
+
+--
+-- host.vlan_id: VLAN ID of the host location (0 for untagged).
+--
+
+ALTER TABLE `host` ADD COLUMN `vlan_id` smallint(5) unsigned NOT NULL DEFAULT '0' AFTER `port_id`;
@@ -200,6 +200,48 @@ func (r *Graph) RemoveEdge(p Point) {
 	logger.Debugf("removed an edge: id=%v", e.value.ID())
 }
 
+// UpdateEdge recalculates the minimum spanning tree after the weight of the
+// edge on p has been changed. It returns false if p is not on an edge.
+func (r *Graph) UpdateEdge(p Point) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if p == nil {
+		panic("nil point")
+	}
+
+	e, ok := r.points[p.ID()]
+	if !ok {
+		return false
+	}
+	r.calculateMST()
+	logger.Debugf("updated an edge: id=%v, weight=%v", e.value.ID(), e.value.Weight())
+
+	return true
+}
+
+// Edges returns all the edges of v, including the ones disabled by the minimum spanning tree.
+func (r *Graph) Edges(v Vertex) []Edge {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if v == nil {
+		panic("nil vertex")
+	}
+
+	vertex, ok := r.vertexies[v.ID()]
+	if !ok {
+		return nil
+	}
+	result := make([]Edge, 0, len(vertex.edges))
+	for _, e := range vertex.edges {
+		result = append(result, e.value)
+	}
+
+	return result
+}
+
 // IsEdge returns whether p is on an edge between two vertexeis.
 func (r *Graph) IsEdge(p Point) bool {
 	// Read lock
@@ -214,6 +256,25 @@ func (r *Graph) IsEdge(p Point) bool {
 	return ok
 }
 
+// IsFreshEdge returns whether p is on an edge between two vertexies that has been updated within expiration,
+// i.e., the edge would not be removed by RemoveStaleEdges.
+func (r *Graph) IsFreshEdge(p Point, expiration time.Duration) bool {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if p == nil {
+		panic("nil point")
+	}
+
+	v, ok := r.points[p.ID()]
+	if !ok {
+		return false
+	}
+
+	return time.Now().Sub(v.timestamp) < expiration
+}
+
 // IsEnabledPoint returns whether p is an active point that is not disabled by the minimum spanning tree.
 func (r *Graph) IsEnabledPoint(p Point) bool {
 	// Read lock
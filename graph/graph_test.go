@@ -24,6 +24,7 @@ package graph
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 type node struct {
@@ -169,6 +170,41 @@ func TestRemoveEdges(t *testing.T) {
 	}
 }
 
+func TestIsFreshEdge(t *testing.T) {
+	graph := New()
+	graph.AddVertex(node{"a"})
+	graph.AddVertex(node{"b"})
+	e := link{
+		points: [2]point{point{"a", 1}, point{"b", 1}},
+		weight: 2,
+	}
+	if _, err := graph.AddEdge(e); err != nil {
+		t.Fatal(err)
+	}
+
+	if !graph.IsFreshEdge(point{"a", 1}, time.Minute) || !graph.IsFreshEdge(point{"b", 1}, time.Minute) {
+		t.Fatal("Expected fresh edge, but not")
+	}
+	if graph.IsFreshEdge(point{"a", 2}, time.Minute) {
+		t.Fatal("Expected no edge on a:2, but found")
+	}
+	// Not updated within the expiration.
+	graph.edges[e.ID()].timestamp = time.Now().Add(-2 * time.Minute)
+	if graph.IsFreshEdge(point{"a", 1}, time.Minute) {
+		t.Fatal("Expected stale edge, but fresh")
+	}
+	if !graph.IsEdge(point{"a", 1}) {
+		t.Fatal("Expected edge until the stale edge is removed, but not")
+	}
+	// Updated again by a new LLDP packet.
+	if _, err := graph.AddEdge(e); err != nil {
+		t.Fatal(err)
+	}
+	if !graph.IsFreshEdge(point{"a", 1}, time.Minute) {
+		t.Fatal("Expected fresh edge after the update, but not")
+	}
+}
+
 func TestDuplicatedEdges(t *testing.T) {
 	graph := New()
 	graph.AddVertex(node{"a"})
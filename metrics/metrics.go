@@ -0,0 +1,228 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+// Package metrics exports the metrics of the controller in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/superkkt/go-logging"
+)
+
+var (
+	logger = logging.MustGetLogger("metrics")
+)
+
+type Type string
+
+const (
+	TypeCounter Type = "counter"
+	TypeGauge   Type = "gauge"
+)
+
+// Metric is a family of the samples that have the same name and different labels.
+type Metric struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Collector returns its metrics when they are scraped.
+type Collector interface {
+	Collect() []Metric
+}
+
+// CollectorFunc is an adapter to use an ordinary function as a Collector.
+type CollectorFunc func() []Metric
+
+func (r CollectorFunc) Collect() []Metric {
+	return r()
+}
+
+// Registry is a set of the collectors that are exported together. It implements http.Handler to serve
+// the metrics.
+type Registry struct {
+	mutex      sync.Mutex
+	collectors []Collector
+}
+
+// DefaultRegistry is the registry of the counters created by NewCounter.
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) Register(c Collector) {
+	if c == nil {
+		panic("Collector is nil")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+// Gather returns the metrics of all the collectors sorted by their names.
+func (r *Registry) Gather() []Metric {
+	r.mutex.Lock()
+	collectors := append([]Collector{}, r.collectors...)
+	r.mutex.Unlock()
+
+	result := make([]Metric, 0)
+	for _, c := range collectors {
+		result = append(result, c.Collect()...)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result
+}
+
+// WriteTo writes the metrics of all the collectors to w in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	buf := bufio.NewWriter(cw)
+	for _, m := range r.Gather() {
+		if len(m.Help) > 0 {
+			fmt.Fprintf(buf, "# HELP %v %v\n", m.Name, escape(m.Help, false))
+		}
+		fmt.Fprintf(buf, "# TYPE %v %v\n", m.Name, m.Type)
+		for _, s := range m.Samples {
+			buf.WriteString(m.Name)
+			if len(s.Labels) > 0 {
+				labels := make([]string, len(s.Labels))
+				for i, l := range s.Labels {
+					labels[i] = fmt.Sprintf("%v=\"%v\"", l.Name, escape(l.Value, true))
+				}
+				fmt.Fprintf(buf, "{%v}", strings.Join(labels, ","))
+			}
+			fmt.Fprintf(buf, " %v\n", formatValue(s.Value))
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return cw.n, err
+	}
+
+	return cw.n, nil
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := r.WriteTo(w); err != nil {
+		logger.Errorf("failed to write the metrics: %v", err)
+	}
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (r *countWriter) Write(p []byte) (int, error) {
+	n, err := r.w.Write(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// escape escapes the backslashes and line feeds, and also the double quotes if quote is true.
+func escape(s string, quote bool) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	if quote {
+		s = strings.Replace(s, `"`, `\"`, -1)
+	}
+
+	return s
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// Counter is a monotonically increasing value without labels. It is safe to update the counter from
+// multiple goroutines without locking.
+type Counter struct {
+	name  string
+	help  string
+	value uint64
+}
+
+// NewCounter returns a new counter registered in DefaultRegistry.
+func NewCounter(name, help string) *Counter {
+	v := &Counter{name: name, help: help}
+	DefaultRegistry.Register(v)
+
+	return v
+}
+
+func (r *Counter) Inc() {
+	atomic.AddUint64(&r.value, 1)
+}
+
+func (r *Counter) Add(n uint64) {
+	atomic.AddUint64(&r.value, n)
+}
+
+func (r *Counter) Value() uint64 {
+	return atomic.LoadUint64(&r.value)
+}
+
+func (r *Counter) Collect() []Metric {
+	return []Metric{
+		{
+			Name:    r.name,
+			Help:    r.help,
+			Type:    TypeCounter,
+			Samples: []Sample{{Value: float64(r.Value())}},
+		},
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	registry := NewRegistry()
+	counter := &Counter{name: "b_total", help: "Counter."}
+	counter.Add(41)
+	counter.Inc()
+	registry.Register(counter)
+	registry.Register(CollectorFunc(func() []Metric {
+		return []Metric{
+			{
+				Name: "a",
+				Help: "Gauge with \\ and\nnewline.",
+				Type: TypeGauge,
+				Samples: []Sample{
+					{Labels: []Label{{Name: "dpid", Value: "1"}}, Value: 0.5},
+					{Labels: []Label{{Name: "dpid", Value: "\"2\""}, {Name: "port", Value: "3"}}, Value: math.Inf(1)},
+				},
+			},
+			// No sample.
+			{Name: "c", Type: TypeGauge},
+		}
+	}))
+
+	expected := `# HELP a Gauge with \\ and\nnewline.
+# TYPE a gauge
+a{dpid="1"} 0.5
+a{dpid="\"2\"",port="3"} +Inf
+# HELP b_total Counter.
+# TYPE b_total counter
+b_total 42
+# TYPE c gauge
+`
+	buf := new(bytes.Buffer)
+	n, err := registry.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if buf.String() != expected {
+		t.Fatalf("unexpected output: expected=%q, got=%q", expected, buf.String())
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("unexpected length: expected=%v, got=%v", buf.Len(), n)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+
+	"github.com/superkkt/viper"
+)
+
+// nodeAgingTimeout returns the time after which a node location that has not been updated expires.
+// Zero means that the locations never expire.
+func nodeAgingTimeout() time.Duration {
+	if v := viper.GetInt("default.node_aging_timeout"); v > 0 {
+		return time.Duration(v) * time.Second
+	}
+
+	return 0
+}
+
+// nodeAging expires the node locations that have not been updated within the timeout, and remembers
+// the expired locations whose flows have been purged so that they are purged only once.
+type nodeAging struct {
+	mutex sync.Mutex
+	// Zero timeout disables the aging.
+	timeout time.Duration
+	clock   clock.Clock
+	// Key is the MAC address of a node, and value is the timestamp of its expired location that has been purged.
+	purged map[string]time.Time
+}
+
+func newNodeAging(timeout time.Duration) *nodeAging {
+	return &nodeAging{
+		timeout: timeout,
+		clock:   clock.New(),
+		purged:  make(map[string]time.Time),
+	}
+}
+
+func (r *nodeAging) isExpired(l Location) bool {
+	if r.timeout == 0 {
+		return false
+	}
+
+	return r.clock.Since(l.Timestamp) > r.timeout
+}
+
+// filter returns the locations that have not been expired.
+func (r *nodeAging) filter(locations []Location) []Location {
+	if r.timeout == 0 {
+		return locations
+	}
+
+	v := make([]Location, 0, len(locations))
+	for _, l := range locations {
+		if r.isExpired(l) {
+			continue
+		}
+		v = append(v, l)
+	}
+
+	return v
+}
+
+// purge returns whether the flows toward mac should be removed because all of its locations, which
+// are ordered by the most recently updated one first, have been expired. It returns true only once
+// for the same expired location.
+func (r *nodeAging) purge(mac net.HardwareAddr, locations []Location) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := mac.String()
+	if len(locations) == 0 || !r.isExpired(locations[0]) {
+		// Not expired yet, or updated again after the expiration.
+		delete(r.purged, key)
+		return false
+	}
+	if t, ok := r.purged[key]; ok && t.Equal(locations[0].Timestamp) {
+		// Already purged.
+		return false
+	}
+	r.purged[key] = locations[0].Timestamp
+
+	return true
+}
@@ -0,0 +1,111 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+)
+
+func TestNodeAgingExpiry(t *testing.T) {
+	d1 := &Device{id: "1", ports: make(map[uint32]*Port)}
+	d1.ports[1] = newTestPort(d1, 1, 0)
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+
+	clk := clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	db := &dummyLocationDB{
+		locations: []Location{{DPID: "1", Port: 1, Timestamp: clk.Now()}},
+		status:    LocationDiscovered,
+	}
+	topo := newTestTopology(d1)
+	topo.db = db
+	topo.aging = newNodeAging(time.Minute)
+	topo.aging.clock = clk
+
+	src := []struct {
+		Advance  time.Duration
+		Status   LocationStatus
+		Purge    bool
+		Location bool // Update the location after advancing the clock?
+	}{
+		{Advance: 30 * time.Second, Status: LocationDiscovered, Purge: false},
+		{Advance: 30 * time.Second, Status: LocationDiscovered, Purge: false},
+		// Not seen for more than the timeout.
+		{Advance: time.Second, Status: LocationUndiscovered, Purge: true},
+		// Purged only once.
+		{Advance: time.Minute, Status: LocationUndiscovered, Purge: false},
+		// Seen again.
+		{Advance: time.Second, Status: LocationDiscovered, Purge: false, Location: true},
+		// Expired again.
+		{Advance: 2 * time.Minute, Status: LocationUndiscovered, Purge: true},
+	}
+
+	for i, v := range src {
+		clk.Advance(v.Advance)
+		if v.Location {
+			db.locations = []Location{{DPID: "1", Port: 1, Timestamp: clk.Now()}}
+		}
+
+		_, status, err := topo.Nodes(mac)
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if status != v.Status {
+			t.Fatalf("#%v: unexpected status: expected=%v, got=%v", i, v.Status, status)
+		}
+		if purge := topo.aging.purge(mac, db.locations); purge != v.Purge {
+			t.Fatalf("#%v: unexpected purge: expected=%v, got=%v", i, v.Purge, purge)
+		}
+	}
+}
+
+func TestNodeAgingFilter(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	locations := []Location{
+		{DPID: "1", Port: 1, Timestamp: start},
+		{DPID: "2", Port: 1, Timestamp: start.Add(-2 * time.Minute)},
+	}
+
+	src := []struct {
+		Timeout  time.Duration
+		Advance  time.Duration
+		Expected int
+	}{
+		// Disabled.
+		{Timeout: 0, Advance: time.Hour, Expected: 2},
+		{Timeout: time.Minute, Advance: 0, Expected: 1},
+		{Timeout: time.Minute, Advance: 2 * time.Minute, Expected: 0},
+		{Timeout: 5 * time.Minute, Advance: time.Minute, Expected: 2},
+	}
+
+	for i, v := range src {
+		aging := newNodeAging(v.Timeout)
+		aging.clock = clock.NewFake(start.Add(v.Advance))
+
+		if got := len(aging.filter(locations)); got != v.Expected {
+			t.Fatalf("#%v: unexpected number of locations: expected=%v, got=%v", i, v.Expected, got)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// AllowList is a list of the IPv4 and IPv6 ranges from which switches are allowed to connect to the
+// controller. An empty list allows all the addresses.
+type AllowList struct {
+	nets []*net.IPNet
+}
+
+// ParseAllowList parses the ranges in CIDR notation, e.g., "10.0.0.0/8" or "2001:db8::/32". A range without
+// the prefix length is a single address.
+func ParseAllowList(ranges []string) (*AllowList, error) {
+	v := &AllowList{nets: make([]*net.IPNet, 0, len(ranges))}
+	for _, s := range ranges {
+		n, err := parseIPRange(s)
+		if err != nil {
+			return nil, err
+		}
+		v.nets = append(v.nets, n)
+	}
+
+	return v, nil
+}
+
+// parseIPRange parses a range in CIDR notation. A range without the prefix length is a single address.
+func parseIPRange(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address: %v", s)
+		}
+		if ip.To4() != nil {
+			s += "/32"
+		} else {
+			s += "/128"
+		}
+	}
+
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR range: %v", s)
+	}
+
+	return n, nil
+}
+
+// Allowed returns whether addr, which is the remote address of a switch connection, is in the list.
+func (r *AllowList) Allowed(addr net.Addr) bool {
+	if len(r.nets) == 0 {
+		return true
+	}
+
+	var ip net.IP
+	switch v := addr.(type) {
+	case *net.TCPAddr:
+		ip = v.IP
+	default:
+		ip = net.ParseIP(sourceIP(addr))
+	}
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range r.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *AllowList) String() string {
+	if len(r.nets) == 0 {
+		return "any"
+	}
+
+	v := make([]string, len(r.nets))
+	for i, n := range r.nets {
+		v[i] = n.String()
+	}
+
+	return strings.Join(v, ", ")
+}
@@ -0,0 +1,73 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAllowList(t *testing.T) {
+	list, err := ParseAllowList([]string{"10.0.0.0/8", "192.168.1.10", " 2001:db8::/32 ", "fe80::1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src := []struct {
+		Addr     net.Addr
+		Expected bool
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 51234}, true},
+		{&net.TCPAddr{IP: net.ParseIP("11.1.2.3"), Port: 51234}, false},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 6633}, true},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.11"), Port: 6633}, false},
+		// IPv4-mapped IPv6 address of an IPv4 range.
+		{&net.TCPAddr{IP: net.ParseIP("::ffff:10.0.0.1"), Port: 51234}, true},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8:1::1"), Port: 51234}, true},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db9::1"), Port: 51234}, false},
+		{&net.TCPAddr{IP: net.ParseIP("fe80::1"), Port: 51234, Zone: "eth0"}, true},
+		{&net.TCPAddr{IP: net.ParseIP("fe80::2"), Port: 51234, Zone: "eth0"}, false},
+		// Non-TCP addresses.
+		{&net.UDPAddr{IP: net.ParseIP("10.1.2.3"), Port: 51234}, true},
+		{&net.UnixAddr{Name: "/tmp/cherry.sock", Net: "unix"}, false},
+	}
+	for i, v := range src {
+		if allowed := list.Allowed(v.Addr); allowed != v.Expected {
+			t.Fatalf("#%v: unexpected result for %v: expected=%v, got=%v", i, v.Addr, v.Expected, allowed)
+		}
+	}
+
+	// Empty list allows all the addresses.
+	any, err := ParseAllowList(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !any.Allowed(&net.TCPAddr{IP: net.ParseIP("11.1.2.3")}) || !any.Allowed(&net.TCPAddr{IP: net.ParseIP("2001:db9::1")}) {
+		t.Fatal("empty allow list denies an address")
+	}
+
+	for _, v := range []string{"10.0.0.0/33", "not-an-ip", "2001:db8::/129", ""} {
+		if _, err := ParseAllowList([]string{v}); err == nil {
+			t.Fatalf("expected an error for %q, but not occurred", v)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/superkkt/cherry/openflow"
+
+	"github.com/superkkt/viper"
+)
+
+// Default number of the flows that an application can install on a device without removing any of them
+// before we suspect a flow leak.
+const defaultAppFlowLeakThreshold = 10000
+
+// appFlowLeakThreshold returns the number of the flows that an application can install on a device in a row,
+// without any of its flows being removed, before a possible flow leak is logged.
+func appFlowLeakThreshold() int {
+	if v := viper.GetInt("default.app_flow_leak_threshold"); v > 0 {
+		return v
+	}
+
+	return defaultAppFlowLeakThreshold
+}
+
+// appFlowCounter keeps track of the flows installed on a device by each application. A flow is counted when
+// it is added by a FLOW_MOD, and uncounted when the device reports its removal by a FLOW_REMOVED, which is
+// sent for both the expired and deleted flows. The flows added without the SendFlowRemoved flag are not
+// counted because their removals are never reported.
+type appFlowCounter struct {
+	mutex sync.Mutex
+	// ID of the device, which is only used for logging. The counter does not ask the device for its ID
+	// because the flows are counted while the device lock is held.
+	deviceID  string
+	threshold int
+	apps      map[AppCookie]*appFlows
+}
+
+type appFlows struct {
+	name string
+	// Key is the table ID, priority, and match of a flow, which identify a flow entry in the device. A flow
+	// added again with the same key replaces the existing one.
+	flows map[string]struct{}
+	// Number of the flows just after the last removal, which is the base of the monotonic growth.
+	low int
+	// Whether a possible leak has been logged since the last removal.
+	warned bool
+}
+
+func newAppFlowCounter(threshold int) *appFlowCounter {
+	return &appFlowCounter{
+		threshold: threshold,
+		apps:      make(map[AppCookie]*appFlows),
+	}
+}
+
+func appFlowKey(tableID uint8, priority uint16, match openflow.Match) string {
+	return fmt.Sprintf("%v/%v/%v", tableID, priority, openflow.MatchFields(match))
+}
+
+func (r *appFlowCounter) setDeviceID(id string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.deviceID = id
+}
+
+// added counts the flow if it is a new flow of an application.
+func (r *appFlowCounter) added(flow openflow.FlowMod) {
+	if flow.Command() != openflow.FlowAdd || !flow.Flags().SendFlowRemoved || flow.FlowMatch() == nil {
+		return
+	}
+	owner, name, ok := appCookieOf(flow.Cookie())
+	if !ok {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.apps[owner]
+	if !ok {
+		v = &appFlows{name: name, flows: make(map[string]struct{})}
+		r.apps[owner] = v
+	}
+	v.flows[appFlowKey(flow.TableID(), flow.Priority(), flow.FlowMatch())] = struct{}{}
+	// The application keeps installing new flows while none of its flows are removed.
+	if growth := len(v.flows) - v.low; growth >= r.threshold && !v.warned {
+		logger.Warningf("possible flow leak: %v has installed %v flows on device %v without removing any of them (total %v flows)", name, growth, r.deviceID, len(v.flows))
+		v.warned = true
+	}
+}
+
+// removed uncounts the removed flow of an application.
+func (r *appFlowCounter) removed(flow openflow.FlowRemoved) {
+	owner, _, ok := appCookieOf(flow.Cookie())
+	if !ok || flow.Match() == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.apps[owner]
+	if !ok {
+		return
+	}
+	delete(v.flows, appFlowKey(flow.TableID(), flow.Priority(), flow.Match()))
+	v.low = len(v.flows)
+	v.warned = false
+}
+
+// counts returns the number of the flows of each application. Key is the application name.
+func (r *appFlowCounter) counts() map[string]int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := make(map[string]int)
+	for _, v := range r.apps {
+		result[v.name] = len(v.flows)
+	}
+
+	return result
+}
@@ -0,0 +1,121 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"encoding"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+// removedFlow is the FLOW_REMOVED of a flow that has been added by a FLOW_MOD.
+type removedFlow struct {
+	openflow.FlowRemoved
+	flow   openflow.FlowMod
+	reason uint8
+}
+
+func (r *removedFlow) Cookie() uint64        { return r.flow.Cookie() }
+func (r *removedFlow) Priority() uint16      { return r.flow.Priority() }
+func (r *removedFlow) TableID() uint8        { return r.flow.TableID() }
+func (r *removedFlow) Match() openflow.Match { return r.flow.FlowMatch() }
+func (r *removedFlow) Reason() uint8         { return r.reason }
+func (r *removedFlow) DurationSec() uint32   { return 0 }
+func (r *removedFlow) PacketCount() uint64   { return 0 }
+func (r *removedFlow) ByteCount() uint64     { return 0 }
+
+func TestAppFlowLeak(t *testing.T) {
+	owner, err := RegisterAppCookie("LeakTestApp")
+	if err != nil {
+		t.Fatalf("failed to register the cookie: %v", err)
+	}
+	fake := NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	sw.appFlows.threshold = 5
+	sw.session.listener = nopControllerListener{}
+	sw.session.handler = newOF13Session(sw.Device)
+
+	newFlow := func(cmd openflow.FlowModCmd, host byte) openflow.FlowMod {
+		match, err := sw.Factory().NewMatch()
+		if err != nil {
+			t.Fatalf("failed to create a match: %v", err)
+		}
+		match.SetDstMAC(net.HardwareAddr{0, 0, 0, 0, 0, host})
+		flow, err := NewAppFlowMod(sw.Factory(), cmd, owner)
+		if err != nil {
+			t.Fatalf("failed to create a flow: %v", err)
+		}
+		flow.SetPriority(10)
+		flow.SetFlowMatch(match)
+		return flow
+	}
+
+	// The application keeps adding the flows that are never removed.
+	flows := make([]openflow.FlowMod, 0)
+	for i := 1; i <= 5; i++ {
+		flow := newFlow(openflow.FlowAdd, byte(i))
+		flows = append(flows, flow)
+		if err := sw.SendMessages([]encoding.BinaryMarshaler{flow}); err != nil {
+			t.Fatalf("failed to send the flow: %v", err)
+		}
+	}
+	// Neither the same flow added again nor a delete request is a new flow.
+	if err := sw.SendMessage(newFlow(openflow.FlowAdd, 1)); err != nil {
+		t.Fatalf("failed to send the flow: %v", err)
+	}
+	if err := sw.SendMessage(newFlow(openflow.FlowDelete, 2)); err != nil {
+		t.Fatalf("failed to send the flow: %v", err)
+	}
+
+	if n := sw.AppFlowCounts()["LeakTestApp"]; n != 5 {
+		t.Fatalf("unexpected number of the flows: expected=5, got=%v", n)
+	}
+	if v := sw.appFlows.apps[owner]; !v.warned {
+		t.Fatalf("possible leak is not detected")
+	}
+
+	// The removals reported by the device, e.g., the deleted flow, rearm the detector.
+	sw.session.OnFlowRemoved(sw.Factory(), nil, &removedFlow{flow: flows[1]})
+	if n := sw.AppFlowCounts()["LeakTestApp"]; n != 4 {
+		t.Fatalf("unexpected number of the flows: expected=4, got=%v", n)
+	}
+	if v := sw.appFlows.apps[owner]; v.warned || v.low != 4 {
+		t.Fatalf("the detector is not rearmed: warned=%v, low=%v", v.warned, v.low)
+	}
+	for i := 6; i < 10; i++ {
+		sw.SendMessage(newFlow(openflow.FlowAdd, byte(i)))
+	}
+	if v := sw.appFlows.apps[owner]; v.warned {
+		t.Fatalf("unexpected leak detection: growth=%v", len(v.flows)-v.low)
+	}
+
+	// The flows not owned by any application are not counted.
+	flow := newFlow(openflow.FlowAdd, 100)
+	flow.SetCookie(0)
+	sw.SendMessage(flow)
+	if counts := sw.AppFlowCounts(); len(counts) != 1 || counts["LeakTestApp"] != 8 {
+		t.Fatalf("unexpected flow counts: %v", counts)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"encoding"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow/transceiver"
+)
+
+type fakeAuxChannel struct {
+	id int
+}
+
+func (r *fakeAuxChannel) Write(msg encoding.BinaryMarshaler) error {
+	return nil
+}
+
+func (r *fakeAuxChannel) Close() error {
+	return nil
+}
+
+func TestNextAuxChannel(t *testing.T) {
+	d := new(Device)
+	if w := d.nextAuxChannel(); w != nil {
+		t.Fatalf("expected nil channel without auxiliary connections: %v", w)
+	}
+
+	channels := []transceiver.WriteCloser{&fakeAuxChannel{1}, &fakeAuxChannel{2}, &fakeAuxChannel{3}}
+	for _, v := range channels {
+		d.addAuxChannel(v)
+	}
+	if d.AuxChannels() != len(channels) {
+		t.Fatalf("unexpected number of channels: expected=%v, got=%v", len(channels), d.AuxChannels())
+	}
+
+	src := []struct {
+		remove   transceiver.WriteCloser
+		expected []int
+	}{
+		{nil, []int{1, 2, 3, 1, 2, 3}},
+		{channels[1], []int{1, 3, 1, 3}},
+		{channels[0], []int{3, 3}},
+		{channels[2], nil},
+	}
+	for i, v := range src {
+		if v.remove != nil {
+			d.removeAuxChannel(v.remove)
+			// Restart the rotation from the first channel to make the result predictable.
+			d.aux.next = 0
+		}
+		for j, id := range v.expected {
+			w := d.nextAuxChannel()
+			if w == nil || w.(*fakeAuxChannel).id != id {
+				t.Fatalf("#%v: unexpected channel at %v: expected=%v, got=%v", i, j, id, w)
+			}
+		}
+		if len(v.expected) == 0 && d.nextAuxChannel() != nil {
+			t.Fatalf("#%v: expected nil channel after removing all the channels", i)
+		}
+	}
+}
@@ -23,7 +23,11 @@ package network
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/superkkt/cherry/openflow"
 	"github.com/superkkt/cherry/protocol"
@@ -36,7 +40,19 @@ var (
 )
 
 type database interface {
-	Location(mac net.HardwareAddr) (dpid string, port uint32, status LocationStatus, err error)
+	// Locations returns all the discovered locations of mac in the order of the most recently
+	// updated one first.
+	Locations(mac net.HardwareAddr) ([]Location, LocationStatus, error)
+	MACAddrs() ([]net.HardwareAddr, error)
+}
+
+// Location is a physical location of a host.
+type Location struct {
+	DPID string
+	Port uint32
+	// VLAN ID of the host on the port. Zero means the untagged host.
+	VLANID    uint16
+	Timestamp time.Time // Last updated time
 }
 
 type LocationStatus int
@@ -56,12 +72,51 @@ type EventListener interface {
 }
 
 type ControllerEventListener interface {
-	OnPacketIn(Finder, *Port, *protocol.Ethernet) error
+	OnPacketIn(Finder, *Port, *protocol.Ethernet, PacketInInfo) error
 	OnPortUp(Finder, *Port) error
 	OnPortDown(Finder, *Port) error
 	OnDeviceUp(Finder, *Device) error
 	OnDeviceDown(Finder, *Device) error
-	OnFlowRemoved(Finder, openflow.FlowRemoved) error
+	// OnFlowRemoved is called when a flow is removed from the device by its timeouts or a delete request.
+	OnFlowRemoved(Finder, *Device, openflow.FlowRemoved) error
+}
+
+// PacketInInfo is the information of a PACKET_IN that is not carried by its packet. The zero value means
+// that the packet is not buffered and its physical ingress port is unknown.
+type PacketInInfo struct {
+	// Zero is a valid buffer ID, so whether the packet is buffered is kept separately.
+	buffered bool
+	bufferID uint32
+	phyPort  uint32
+}
+
+func newPacketInInfo(v openflow.PacketIn) PacketInInfo {
+	return PacketInInfo{
+		buffered: v.BufferID() != openflow.NoBuffer,
+		bufferID: v.BufferID(),
+		phyPort:  v.PhysicalInPort(),
+	}
+}
+
+// BufferID returns the ID of the switch buffer that holds the packet if the switch has buffered it.
+func (r PacketInInfo) BufferID() (bufferID uint32, ok bool) {
+	if !r.buffered {
+		return openflow.NoBuffer, false
+	}
+
+	return r.bufferID, true
+}
+
+// PhysicalPort returns the number of the physical port that the packet has been received on. It differs from the
+// ingress port only if the ingress port is a logical one, e.g., a link aggregation group on an OpenFlow 1.3
+// switch. The applications should use the ingress port to forward the packets, and the physical port only for
+// diagnostics.
+func (r PacketInInfo) PhysicalPort() (port uint32, ok bool) {
+	if r.phyPort == 0 {
+		return 0, false
+	}
+
+	return r.phyPort, true
 }
 
 type TopologyEventListener interface {
@@ -69,25 +124,80 @@ type TopologyEventListener interface {
 }
 
 type Controller struct {
-	topo     *topology
-	listener EventListener
+	topo      *topology
+	listener  EventListener
+	resolver  Resolver
+	tracker   *dpidTracker
+	intents   *intentStore
+	limiter   deviceLimiter
+	startTime time.Time
+}
+
+// Stats is the runtime state of the controller.
+type Stats struct {
+	StartTime time.Time
+	// Number of the connected devices.
+	Devices int
+	// Number of the connected devices for each negotiated OpenFlow version.
+	OFVersions map[string]int
+	// Number of the discovered nodes (hosts).
+	Nodes int
+	// Number of the PACKET_INs from genuine table misses (new flows).
+	NewFlowMisses uint64
+	// Number of the repeated PACKET_INs for the flows that have been recently programmed.
+	RepeatMisses uint64
 }
 
 func NewController(db database) *Controller {
 	return &Controller{
-		topo: newTopology(db),
+		topo:      newTopology(db),
+		resolver:  nopResolver{},
+		tracker:   newDPIDTracker(),
+		intents:   newIntentStore(resumeGrace()),
+		startTime: time.Now(),
 	}
 }
 
 func (r *Controller) AddConnection(ctx context.Context, c net.Conn) {
-	conf := sessionConfig{
-		conn:     c,
-		watcher:  r.topo,
-		finder:   r.topo,
-		listener: r.listener,
+	// Bound the connections before the handshake so that a connection flood cannot exhaust the memory.
+	if !admitConnection(&r.limiter, c) {
+		return
 	}
-	session := newSession(conf)
-	go session.Run(ctx)
+
+	// The resolver may be slow, e.g., a reverse DNS lookup, so it should not block accepting the connections.
+	go func() {
+		site := resolve(r.resolver, c.RemoteAddr())
+		logger.Infof("adding a new device connection from %v (site=%v)", c.RemoteAddr(), site)
+
+		conf := sessionConfig{
+			conn:     c,
+			site:     site,
+			watcher:  r.topo,
+			finder:   r.topo,
+			listener: r.listener,
+			tracker:  r.tracker,
+			intents:  r.intents,
+			limiter:  &r.limiter,
+		}
+		session := newSession(conf)
+		session.Run(ctx)
+	}()
+}
+
+// SubscribeDeviceEvents returns a channel that receives every DeviceConnected and DeviceDisconnected event in
+// order. The events are queued for the subscriber, so a slow subscriber does not block the controller. cancel
+// should be called to stop the subscription, and then the channel is closed.
+func (r *Controller) SubscribeDeviceEvents() (events <-chan DeviceEvent, cancel func()) {
+	return r.topo.feed.subscribe()
+}
+
+// SetResolver sets the resolver that annotates the source addresses of the switch connections.
+// It should be called before adding any connection.
+func (r *Controller) SetResolver(resolver Resolver) {
+	if resolver == nil {
+		panic("nil resolver")
+	}
+	r.resolver = resolver
 }
 
 func (r *Controller) SetEventListener(l EventListener) {
@@ -99,6 +209,115 @@ func (r *Controller) String() string {
 	return r.topo.String()
 }
 
+// Devices returns all the devices that are currently connected to the controller.
+func (r *Controller) Devices() []*Device {
+	return r.topo.Devices()
+}
+
+// Device returns the device whose ID is id, or nil if the device does not exist.
+func (r *Controller) Device(id string) *Device {
+	return r.topo.Device(id)
+}
+
+// AllNodes returns the known locations of all the discovered nodes sorted by the MAC address.
+func (r *Controller) AllNodes() ([]*Node, error) {
+	return r.topo.AllNodes()
+}
+
+func (r *Controller) Stats() (Stats, error) {
+	v := Stats{
+		StartTime:  r.startTime,
+		OFVersions: make(map[string]int),
+	}
+	for _, device := range r.topo.Devices() {
+		if device.IsClosed() {
+			continue
+		}
+		v.Devices++
+		newFlow, repeat := device.PacketInCounters()
+		v.NewFlowMisses += newFlow
+		v.RepeatMisses += repeat
+
+		switch ver := device.Factory().ProtocolVersion(); ver {
+		case openflow.OF10_VERSION:
+			v.OFVersions["1.0"]++
+		case openflow.OF13_VERSION:
+			v.OFVersions["1.3"]++
+		default:
+			v.OFVersions[fmt.Sprintf("0x%02X", ver)]++
+		}
+	}
+
+	nodes, err := r.topo.AllNodes()
+	if err != nil {
+		return Stats{}, err
+	}
+	v.Nodes = len(nodes)
+
+	return v, nil
+}
+
+// DrainAll drains all the connected devices concurrently so that the controller can be shut down without
+// losing the messages in flight. It returns an error listing the devices that have not been drained within
+// timeout.
+func (r *Controller) DrainAll(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type result struct {
+		id  string
+		err error
+	}
+	devices := r.topo.Devices()
+	c := make(chan result, len(devices))
+	for _, device := range devices {
+		go func(d *Device) {
+			c <- result{id: d.ID(), err: d.Drain(ctx)}
+		}(device)
+	}
+
+	failed := make([]string, 0)
+	for range devices {
+		v := <-c
+		if v.err == nil || v.err == ErrClosedDevice {
+			continue
+		}
+		logger.Errorf("failed to drain device %v: %v", v.id, v.err)
+		failed = append(failed, v.id)
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("devices not drained within %v: %v", timeout, strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// Quarantine drops all the packets from mac on all the devices until timeout expires. A device that fails does
+// not prevent the others from quarantining the host, and the failed devices are reported by the returned error.
+func (r *Controller) Quarantine(mac net.HardwareAddr, timeout time.Duration) error {
+	failed := make([]string, 0)
+	for _, device := range r.topo.Devices() {
+		if device.IsClosed() {
+			continue
+		}
+		logger.Infof("quarantining a host (MAC: %v) for %v on %v", mac, timeout, device.ID())
+		if err := device.Quarantine(mac, timeout); err != nil {
+			if err == ErrClosedDevice {
+				continue
+			}
+			logger.Errorf("failed to quarantine a host (MAC: %v) on %v: %v", mac, device.ID(), err)
+			failed = append(failed, device.ID())
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to quarantine %v on the devices: %v", mac, strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
 func (r *Controller) Announce(ip net.IP, mac net.HardwareAddr) error {
 	for _, device := range r.topo.Devices() {
 		logger.Debugf("sending ARP announcement for a host (IP: %v, MAC: %v) via %v", ip, mac, device.ID())
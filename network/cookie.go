@@ -0,0 +1,151 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"errors"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+const (
+	// The normal flows of an application have the ID of the application in the bits 48-62 of their
+	// cookie. The MSB is always zero so that the special flows are not affected by the applications.
+	appCookieShift = 48
+	appIDMask      = 0x7FFF
+	specialCookie  = 0x1 << 63
+)
+
+var (
+	ErrAppCookieExhausted = errors.New("no more application cookie range")
+
+	appCookies = struct {
+		sync.Mutex
+		// Key is the upper-cased application name.
+		ranges map[string]AppCookie
+		// Application names as they are registered.
+		names map[AppCookie]string
+		// Priority bands declared by the applications.
+		bands map[AppCookie]PriorityBand
+	}{ranges: make(map[string]AppCookie), names: make(map[AppCookie]string), bands: make(map[AppCookie]PriorityBand)}
+)
+
+// AppCookie is the cookie range reserved for the normal flows of an application, so that an application
+// can remove its own flows without clobbering the flows installed by the other applications.
+type AppCookie uint64
+
+// RegisterAppCookie reserves a cookie range for the application whose name is appName. It returns the
+// range that is already reserved if the application has been registered before. The range is derived from
+// the name so that the flows installed before a restart are still owned by the same application regardless
+// of the order of the registrations. The next free range is used if the derived one is already taken.
+func RegisterAppCookie(appName string) (AppCookie, error) {
+	appCookies.Lock()
+	defer appCookies.Unlock()
+
+	name := strings.ToUpper(appName)
+	if v, ok := appCookies.ranges[name]; ok {
+		return v, nil
+	}
+
+	id := appCookieID(name)
+	for i := 0; i < appIDMask; i++ {
+		v := AppCookie(id << appCookieShift)
+		if prev, ok := appCookies.names[v]; ok {
+			logger.Warningf("cookie range 0x%X of %v is already taken by %v", v.Value(), appName, prev)
+			// Zero ID is reserved for the flows that are not owned by any application.
+			id = id%appIDMask + 1
+			continue
+		}
+		appCookies.ranges[name] = v
+		appCookies.names[v] = appName
+		return v, nil
+	}
+
+	return 0, ErrAppCookieExhausted
+}
+
+// appCookieID returns the application ID, between 1 and appIDMask, derived from the upper-cased name.
+func appCookieID(name string) uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+
+	return uint64(h.Sum32())%appIDMask + 1
+}
+
+// appCookieOf returns the application that owns the flow whose cookie is cookie, and the name of the application.
+// ok is false if the flow is not owned by any registered application.
+func appCookieOf(cookie uint64) (owner AppCookie, name string, ok bool) {
+	if cookie&specialCookie != 0 {
+		return 0, "", false
+	}
+	owner = AppCookie(cookie & (appIDMask << appCookieShift))
+
+	appCookies.Lock()
+	defer appCookies.Unlock()
+
+	name, ok = appCookies.names[owner]
+	return owner, name, ok
+}
+
+// AppOf returns the name of the application that owns the flow whose cookie is cookie. ok is false if the
+// flow is not owned by any registered application.
+func AppOf(cookie uint64) (name string, ok bool) {
+	_, name, ok = appCookieOf(cookie)
+	return name, ok
+}
+
+// Value returns the cookie value that is used for the flows of the application.
+func (r AppCookie) Value() uint64 {
+	return uint64(r)
+}
+
+// Mask returns the cookie mask that matches all the normal flows of the application, and none of the
+// special flows whose MSB is 1.
+func (r AppCookie) Mask() uint64 {
+	return specialCookie | appIDMask<<appCookieShift
+}
+
+// Owns returns whether cookie belongs to a normal flow of the application.
+func (r AppCookie) Owns(cookie uint64) bool {
+	return cookie&r.Mask() == r.Value()
+}
+
+// NewAppFlowMod returns a flow-mod created by f whose cookie is set to owner. A delete flow-mod
+// also has the cookie mask of owner so that it only removes the flows of owner.
+//
+// NOTE: OpenFlow 1.0 does not have the cookie mask, so the delete flow-mods for OpenFlow 1.0 switches
+// remove the matched flows regardless of their owners.
+func NewAppFlowMod(f openflow.Factory, cmd openflow.FlowModCmd, owner AppCookie) (openflow.FlowMod, error) {
+	flow, err := f.NewFlowMod(cmd)
+	if err != nil {
+		return nil, err
+	}
+	flow.SetCookie(owner.Value())
+	if cmd == openflow.FlowDelete {
+		flow.SetCookieMask(owner.Mask())
+	}
+
+	return flow, nil
+}
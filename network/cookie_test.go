@@ -0,0 +1,299 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestAppCookie(t *testing.T) {
+	l2switch, err := RegisterAppCookie("TestL2Switch")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	firewall, err := RegisterAppCookie("TestFirewall")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if l2switch == firewall {
+		t.Fatalf("same cookie ranges for different applications: 0x%X", l2switch.Value())
+	}
+	again, err := RegisterAppCookie("testl2switch")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again != l2switch {
+		t.Fatalf("unexpected cookie range: expected=0x%X, got=0x%X", l2switch.Value(), again.Value())
+	}
+	// The range is derived from the name regardless of the order of the registrations.
+	if expected := appCookieID("TESTL2SWITCH") << appCookieShift; l2switch.Value() != expected {
+		t.Fatalf("unexpected cookie range: expected=0x%X, got=0x%X", expected, l2switch.Value())
+	}
+
+	src := []struct {
+		Cookie   uint64
+		Expected bool
+	}{
+		{Cookie: l2switch.Value(), Expected: true},
+		{Cookie: l2switch.Value() | 0xFFFF, Expected: true},
+		{Cookie: firewall.Value(), Expected: false},
+		{Cookie: 0, Expected: false},
+		{Cookie: 0x1 << 63, Expected: false},
+		{Cookie: l2switch.Value() | 0x1<<63, Expected: false},
+		{Cookie: quarantineCookie, Expected: false},
+		{Cookie: PuntCookie("TestL2Switch"), Expected: false},
+	}
+
+	for i, v := range src {
+		if owned := l2switch.Owns(v.Cookie); owned != v.Expected {
+			t.Fatalf("#%v: unexpected result: cookie=0x%X, expected=%v, got=%v", i, v.Cookie, v.Expected, owned)
+		}
+	}
+}
+
+func TestNewAppFlowMod(t *testing.T) {
+	owner, err := RegisterAppCookie("TestOwner")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src := []struct {
+		Command openflow.FlowModCmd
+		Mask    uint64
+	}{
+		{Command: openflow.FlowAdd, Mask: 0},
+		{Command: openflow.FlowModify, Mask: 0},
+		{Command: openflow.FlowDelete, Mask: owner.Mask()},
+	}
+
+	for i, v := range src {
+		flow, err := NewAppFlowMod(of13.NewFactory(), v.Command, owner)
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if flow.Cookie() != owner.Value() {
+			t.Fatalf("#%v: unexpected cookie: expected=0x%X, got=0x%X", i, owner.Value(), flow.Cookie())
+		}
+		if flow.CookieMask() != v.Mask {
+			t.Fatalf("#%v: unexpected cookie mask: expected=0x%X, got=0x%X", i, v.Mask, flow.CookieMask())
+		}
+	}
+}
+
+func TestRemoveFlowsByCookie(t *testing.T) {
+	src := []struct {
+		factory openflow.Factory
+		cookie  uint64
+		mask    uint64
+		err     bool
+	}{
+		// The MSB is added to the mask so that the special flows are not removed.
+		{of13.NewFactory(), 0x0001000000000000, 0x7FFF000000000000, false},
+		{of13.NewFactory(), 0x1234, 0xFFFFFFFFFFFFFFFF, false},
+		// Zero mask would remove all the flows.
+		{of13.NewFactory(), 0x1234, 0, true},
+		{of13.NewFactory(), 0x1234, 0x1 << 63, true},
+		// Cookie of the special flows.
+		{of13.NewFactory(), 0x1 << 63, 0xFFFFFFFFFFFFFFFF, true},
+		// OpenFlow 1.0 ignores the cookie of a delete request.
+		{of10.NewFactory(), 0x1234, 0xFFFFFFFFFFFFFFFF, true},
+	}
+
+	for i, v := range src {
+		fake := NewFakeNetwork()
+		sw := fake.AddSwitch("1", v.factory, 1, 2)
+		err := sw.RemoveFlowsByCookie(v.cookie, v.mask)
+		if (err != nil) != v.err {
+			t.Fatalf("#%v: unexpected result: expected error=%v, got=%v", i, v.err, err)
+		}
+
+		flows := sw.FlowMods()
+		if v.err {
+			if len(flows) != 0 {
+				t.Fatalf("#%v: unexpected flows: %v", i, flows)
+			}
+			continue
+		}
+		if len(flows) != 1 {
+			t.Fatalf("#%v: unexpected number of flows: %v", i, len(flows))
+		}
+		flow := flows[0]
+		if flow.Command() != openflow.FlowDelete || flow.Cookie() != v.cookie || flow.CookieMask() != v.mask|0x1<<63 || flow.TableID() != 0xFF {
+			t.Fatalf("#%v: unexpected flow: command=%v, cookie=0x%X, mask=0x%X, table=%v", i, flow.Command(), flow.Cookie(), flow.CookieMask(), flow.TableID())
+		}
+		if fields := flow.FlowMatch().Fields(); len(fields) != 0 {
+			t.Fatalf("#%v: unexpected match fields: %v", i, fields)
+		}
+		if out := flow.OutPort(); !out.IsNone() {
+			t.Fatalf("#%v: unexpected output port: %v", i, out)
+		}
+	}
+}
+
+func TestRemoveFlowsByCookieIntents(t *testing.T) {
+	owner, err := RegisterAppCookie("CookieIntentTestApp")
+	if err != nil {
+		t.Fatalf("failed to register the cookie: %v", err)
+	}
+	port := openflow.NewOutPort()
+	port.SetValue(2)
+
+	src := []struct {
+		mask     uint64
+		expected int // Number of the intents left
+	}{
+		// Mask that selects a single owner.
+		{owner.Mask(), 0},
+		{0x7FFF000000000000, 0},
+		// Mask that selects the flows of several owners or only some flows of an owner.
+		{0x0001000000000000, 1},
+		{0x7FFF00000000FFFF, 0},
+		{0x000000000000FFFF, 1},
+	}
+
+	for i, v := range src {
+		fake := NewFakeNetwork()
+		sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+		match, err := sw.Factory().NewMatch()
+		if err != nil {
+			t.Fatalf("#%v: failed to create a match: %v", i, err)
+		}
+		match.SetDstMAC(net.HardwareAddr{0, 0, 0, 0, 0, 1})
+		if err := sw.SetFlow(owner, match, port); err != nil {
+			t.Fatalf("#%v: failed to install the flow: %v", i, err)
+		}
+		if err := sw.RemoveFlowsByCookie(owner.Value(), v.mask); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if n := len(sw.intents); n != v.expected {
+			t.Fatalf("#%v: unexpected number of the intents: expected=%v, got=%v", i, v.expected, n)
+		}
+	}
+}
+
+func TestAppCookieCollision(t *testing.T) {
+	first := "TestCollision0"
+	second := ""
+	for i := 1; i < 1000000; i++ {
+		name := fmt.Sprintf("TestCollision%v", i)
+		if appCookieID(strings.ToUpper(name)) == appCookieID(strings.ToUpper(first)) {
+			second = name
+			break
+		}
+	}
+	if second == "" {
+		t.Skip("no colliding name")
+	}
+
+	a, err := RegisterAppCookie(first)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := RegisterAppCookie(second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a == b {
+		t.Fatalf("same cookie ranges for different applications: 0x%X", a.Value())
+	}
+	if name, ok := AppOf(b.Value()); !ok || name != second {
+		t.Fatalf("unexpected owner of 0x%X: %v", b.Value(), name)
+	}
+}
+
+func TestRemoveAppFlows(t *testing.T) {
+	owner, err := RegisterAppCookie("RemoveAppFlowsTestOwner")
+	if err != nil {
+		t.Fatalf("failed to register the cookie: %v", err)
+	}
+	other, err := RegisterAppCookie("RemoveAppFlowsTestOther")
+	if err != nil {
+		t.Fatalf("failed to register the cookie: %v", err)
+	}
+
+	src := []struct {
+		factory openflow.Factory
+		deletes int
+	}{
+		// A single delete request masked by the cookie of owner.
+		{of13.NewFactory(), 1},
+		// OpenFlow 1.0 ignores the cookie, so the flows of owner are removed one by one.
+		{of10.NewFactory(), 2},
+	}
+
+	for i, v := range src {
+		fake := NewFakeNetwork()
+		sw := fake.AddSwitch("1", v.factory, 1, 2)
+		port := openflow.NewOutPort()
+		port.SetValue(2)
+		newMatch := func(mac net.HardwareAddr) openflow.Match {
+			match, err := sw.Factory().NewMatch()
+			if err != nil {
+				t.Fatalf("#%v: failed to create a match: %v", i, err)
+			}
+			match.SetDstMAC(mac)
+			return match
+		}
+		for _, mac := range []net.HardwareAddr{{0, 0, 0, 0, 0, 1}, {0, 0, 0, 0, 0, 2}} {
+			if err := sw.SetFlow(owner, newMatch(mac), port); err != nil {
+				t.Fatalf("#%v: failed to install the flow: %v", i, err)
+			}
+		}
+		otherMAC := net.HardwareAddr{0, 0, 0, 0, 0, 3}
+		// The match is completed by SetFlow, so the same one is used to look up the flow cache.
+		otherMatch := newMatch(otherMAC)
+		if err := sw.SetFlow(other, otherMatch, port); err != nil {
+			t.Fatalf("#%v: failed to install the flow: %v", i, err)
+		}
+
+		sw.Reset()
+		if err := sw.RemoveAppFlows(owner); err != nil {
+			t.Fatalf("#%v: failed to remove the flows: %v", i, err)
+		}
+		flows := sw.FlowMods()
+		if len(flows) != v.deletes {
+			t.Fatalf("#%v: unexpected number of the delete requests: expected=%v, got=%v", i, v.deletes, len(flows))
+		}
+		for _, f := range flows {
+			if f.Command() != openflow.FlowDelete || f.Cookie() != owner.Value() {
+				t.Fatalf("#%v: unexpected flow: command=%v, cookie=0x%X", i, f.Command(), f.Cookie())
+			}
+		}
+		if n := len(sw.intents); n != 1 {
+			t.Fatalf("#%v: unexpected number of the intents: %v", i, n)
+		}
+		// The caches of the other application are kept.
+		if ok, err := sw.flowCache.InProgress(otherMatch, port, DefaultFlowOptions); err != nil || !ok {
+			t.Fatalf("#%v: flow cache of the other application has been removed: %v", i, err)
+		}
+		if _, ok := sw.programmed.cache.Peek(otherMAC.String()); !ok {
+			t.Fatalf("#%v: programmed flow of the other application has been removed", i)
+		}
+	}
+}
@@ -22,14 +22,22 @@
 package network
 
 import (
+	"bytes"
+	"context"
 	"encoding"
 	"errors"
 	"fmt"
+	"math"
 	"net"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
 	"github.com/superkkt/cherry/openflow/transceiver"
 	"github.com/superkkt/cherry/protocol"
 
@@ -45,14 +53,16 @@ type Descriptions struct {
 }
 
 type Features struct {
-	DPID       uint64
-	NumBuffers uint32
-	NumTables  uint8
+	DPID         uint64
+	NumBuffers   uint32
+	NumTables    uint8
+	Capabilities uint32
 }
 
 type Device struct {
 	mutex        sync.RWMutex
 	id           string
+	site         string // Resolved name of the connection's source address
 	session      *session
 	descriptions Descriptions
 	features     Features
@@ -60,8 +70,62 @@ type Device struct {
 	flowTableID  uint8 // Table IDs that we install flows
 	factory      openflow.Factory
 	closed       bool
-	flowCache    *flowCache
-	vlanID       uint16
+	// PACKET_INs are ignored while the device is being drained for shutdown.
+	draining   bool
+	flowCache  *flowCache
+	programmed *programmedSet
+	vlanID     uint16
+	// Auxiliary connections that share the load of PACKET_OUTs with the main connection.
+	aux struct {
+		channels []transceiver.WriteCloser
+		next     int
+	}
+	// Limits the rate of the PACKET_INs from each port. Nil if there is no limit.
+	limiter *packetInLimiter
+	// Serializes PACKET_INs received from the main and auxiliary connections.
+	packetInMutex sync.Mutex
+	flowStats     struct {
+		// Last complete snapshot of the flow statistics.
+		snapshot []openflow.FlowStat
+		// Statistics of snapshot keyed by the encoded match. A match can have several statistics of the flows
+		// in different tables or with different priorities.
+		matches map[string][]openflow.FlowStat
+		// Statistics of the reply that is not yet complete.
+		pending []openflow.FlowStat
+	}
+	tableStats struct {
+		// Last complete snapshot of the table statistics.
+		snapshot []openflow.TableStat
+		// Statistics of the reply that is not yet complete.
+		pending []openflow.TableStat
+	}
+	// Normal flows installed by the applications, which are retained when the device is disconnected.
+	// Key is same with the flow cache.
+	intents map[string]flowIntent
+	// Last sample of the port statistics for each port number.
+	portStats map[uint32]*PortStats
+	// IDs of the queues discovered by RequestQueueConfig for each port number.
+	queues map[uint32][]uint32
+	// Role of the controller confirmed by the device. Zero means no role has been confirmed, i.e., equal.
+	role openflow.ControllerRole
+	// Flows installed by each application, which has its own lock.
+	appFlows *appFlowCounter
+	// Message counters that are updated atomically without the device lock.
+	counters struct {
+		packetIns uint64
+		flowMods  uint64
+	}
+}
+
+// PortStats is the last sample of the statistics of a port with its utilization measured from the
+// previous sample.
+type PortStats struct {
+	openflow.PortStat
+	// Time when the sample has been received.
+	Timestamp time.Time
+	// Utilization in bits per second. Zero if there is no previous sample to compare with.
+	RxBitsPerSec uint64
+	TxBitsPerSec uint64
 }
 
 var (
@@ -79,11 +143,20 @@ func newDevice(s *session) *Device {
 		panic("invalid default.vlan_id in the config file")
 	}
 
+	var limiter *packetInLimiter
+	if rate := viper.GetInt("default.packet_in_rate"); rate > 0 {
+		limiter = newPacketInLimiter(rate, viper.GetInt("default.packet_in_burst"), packetInCooldown())
+	}
+
 	return &Device{
-		session:   s,
-		ports:     make(map[uint32]*Port),
-		flowCache: newFlowCache(5 * time.Second),
-		vlanID:    uint16(vlanID),
+		limiter:    limiter,
+		session:    s,
+		ports:      make(map[uint32]*Port),
+		flowCache:  newFlowCache(5 * time.Second),
+		programmed: newProgrammedSet(90 * time.Second), // Same as the idle timeout of the normal flows
+		vlanID:     uint16(vlanID),
+		intents:    make(map[string]flowIntent),
+		appFlows:   newAppFlowCounter(appFlowLeakThreshold()),
 	}
 }
 
@@ -92,7 +165,7 @@ func (r *Device) String() string {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	v := fmt.Sprintf("Device ID=%v, Descriptions=%+v, Features=%+v, # of ports=%v, FlowTableID=%v, Connected=%v\n", r.id, r.descriptions, r.features, len(r.ports), r.flowTableID, !r.closed)
+	v := fmt.Sprintf("Device ID=%v, Site=%v, Descriptions=%+v, Features=%+v, # of ports=%v, FlowTableID=%v, Connected=%v\n", r.id, r.site, r.descriptions, r.features, len(r.ports), r.flowTableID, !r.closed)
 	for _, p := range r.ports {
 		v += fmt.Sprintf("\t%v\n", p.String())
 	}
@@ -108,12 +181,22 @@ func (r *Device) ID() string {
 	return r.id
 }
 
+// Site returns the resolved name of the source address that this device is connected from.
+func (r *Device) Site() string {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.site
+}
+
 func (r *Device) setID(id string) {
 	// Write lock
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
 	r.id = id
+	r.appFlows.setDeviceID(id)
 }
 
 func (r *Device) isReady() bool {
@@ -205,6 +288,31 @@ func (r *Device) Ports() []*Port {
 	return p
 }
 
+// PortValue returns the last status of the port whose number is num reported by the device. The status is a
+// snapshot that is replaced, not modified, by the following PORT_STATUS messages, so the caller can keep it.
+func (r *Device) PortValue(num uint32) (openflow.Port, bool) {
+	port := r.Port(num)
+	if port == nil {
+		return nil, false
+	}
+	v := port.Value()
+
+	return v, v != nil
+}
+
+// PortValues returns the snapshots of the last status of all the ports in ascending order of the port numbers.
+func (r *Device) PortValues() []openflow.Port {
+	result := make([]openflow.Port, 0)
+	for _, p := range r.Ports() {
+		if v := p.Value(); v != nil {
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Number() < result[j].Number() })
+
+	return result
+}
+
 func (r *Device) setPort(num uint32, p openflow.Port) {
 	// Write lock
 	r.mutex.Lock()
@@ -233,6 +341,94 @@ func (r *Device) FlowTableID() uint8 {
 	return r.flowTableID
 }
 
+// NumTables returns the number of the flow tables supported by the device. Zero means that the device has
+// not reported its features yet.
+func (r *Device) NumTables() uint8 {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.features.NumTables
+}
+
+// TablePurpose is the purpose of the flows whose flow table is chosen by TableID.
+type TablePurpose int
+
+const (
+	// Flows that should be looked up before the others, such as ACLs and packet classifiers.
+	TableClassification TablePurpose = iota
+	// Normal flows for packet switching and routing.
+	TableForwarding
+)
+
+// TableID returns the ID of the flow table in which the flows of purpose should be installed. The
+// classification flows go to table 0 that is always looked up first, and the forwarding flows go to
+// FlowTableID, which is a higher table on a device with a multi-table pipeline and table 0 otherwise.
+func (r *Device) TableID(purpose TablePurpose) uint8 {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	switch purpose {
+	case TableForwarding:
+		return r.flowTableID
+	default:
+		return 0
+	}
+}
+
+// SetForwardingTable builds a two-stage pipeline on an OpenFlow 1.3 device: a table miss on table 0 continues
+// the lookup in the table whose ID is tableID, and the normal flows are installed in that table from now on.
+// Table 0 is then left for the flows that should be looked up first, such as ACLs. It returns an error if
+// the device already has its own pipeline, e.g., HP 2920.
+func (r *Device) SetForwardingTable(tableID uint8) error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+	if r.flowTableID == tableID {
+		return nil
+	}
+	if r.flowTableID != 0 {
+		return fmt.Errorf("device %v already has its own pipeline: flow table ID=%v", r.id, r.flowTableID)
+	}
+	if n := r.features.NumTables; n != 0 && tableID >= n {
+		return fmt.Errorf("device %v does not have the flow table %v: number of tables=%v", r.id, tableID, n)
+	}
+
+	gotoTable, err := r.factory.NewGotoTableInstruction(tableID)
+	if err != nil {
+		return err
+	}
+
+	// Forwarding table -> Controller
+	outPort := openflow.NewOutPort()
+	outPort.SetController()
+	action, err := r.factory.NewAction()
+	if err != nil {
+		return err
+	}
+	action.SetOutPort(outPort)
+	inst, err := r.factory.NewInstruction()
+	if err != nil {
+		return err
+	}
+	inst.ApplyAction(action)
+	if err := setTableMiss(r.factory, r.session, tableID, inst); err != nil {
+		return err
+	}
+	// 0 -> Forwarding table
+	if err := setTableMiss(r.factory, r.session, 0, gotoTable); err != nil {
+		return err
+	}
+	r.flowTableID = tableID
+
+	return nil
+}
+
 func (r *Device) setFlowTableID(id uint8) {
 	// Write lock
 	r.mutex.Lock()
@@ -253,9 +449,94 @@ func (r *Device) SendMessage(msg encoding.BinaryMarshaler) error {
 		return ErrClosedDevice
 	}
 
+	return r.write(msg)
+}
+
+// SendMessages sends msgs to the device in order using a single write operation. Unlike SendMessage, the
+// PACKET_OUTs in msgs are also sent through the main connection to keep the order.
+func (r *Device) SendMessages(msgs []encoding.BinaryMarshaler) error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, v := range msgs {
+		if v == nil {
+			panic("Message is nil")
+		}
+	}
+	if r.closed {
+		return ErrClosedDevice
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	return r.session.WriteBatch(msgs)
+}
+
+// write sends msg to the device. PACKET_OUTs are distributed across the auxiliary connections in a
+// round-robin fashion, and all other messages are sent through the main connection. The caller should
+// hold the write lock.
+func (r *Device) write(msg encoding.BinaryMarshaler) error {
+	if _, ok := msg.(openflow.PacketOut); ok {
+		if w := r.nextAuxChannel(); w != nil {
+			return handleWriteErr(w, msg, w.Write(msg))
+		}
+	}
+
 	return r.session.Write(msg)
 }
 
+// nextAuxChannel returns the next auxiliary connection to send a PACKET_OUT, or nil if there is no
+// auxiliary connection. The caller should hold the write lock.
+func (r *Device) nextAuxChannel() transceiver.WriteCloser {
+	if len(r.aux.channels) == 0 {
+		return nil
+	}
+	r.aux.next = r.aux.next % len(r.aux.channels)
+	w := r.aux.channels[r.aux.next]
+	r.aux.next++
+
+	return w
+}
+
+func (r *Device) addAuxChannel(w transceiver.WriteCloser) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.aux.channels = append(r.aux.channels, w)
+}
+
+func (r *Device) removeAuxChannel(w transceiver.WriteCloser) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, v := range r.aux.channels {
+		if v != w {
+			continue
+		}
+		r.aux.channels = append(r.aux.channels[:i], r.aux.channels[i+1:]...)
+		return
+	}
+}
+
+// WriteQueueDepth returns the number of the outbound messages that are waiting to be written to the main
+// connection of this device.
+func (r *Device) WriteQueueDepth() int {
+	return r.session.writer.QueueDepth()
+}
+
+// AuxChannels returns the number of the auxiliary connections attached to this device.
+func (r *Device) AuxChannels() int {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return len(r.aux.channels)
+}
+
 func (r *Device) IsClosed() bool {
 	// Read lock
 	r.mutex.RLock()
@@ -264,8 +545,113 @@ func (r *Device) IsClosed() bool {
 	return r.closed
 }
 
-// SetFlow installs a normal flow entry for packet switching and routing into the switch device.
-func (r *Device) SetFlow(match openflow.Match, port openflow.OutPort) error {
+// MessageCounters returns the number of the PACKET_INs received from this device, and the number of the
+// FLOW_MODs sent to this device.
+func (r *Device) MessageCounters() (packetIns, flowMods uint64) {
+	return atomic.LoadUint64(&r.counters.packetIns), atomic.LoadUint64(&r.counters.flowMods)
+}
+
+// AppFlowCounts returns the number of the flows installed on this device by each application whose name is
+// the key. The flows that do not report their removals are not counted.
+func (r *Device) AppFlowCounts() map[string]int {
+	return r.appFlows.counts()
+}
+
+// PacketInCounters returns the number of PACKET_INs from genuine table misses (new flows), and the
+// number of repeated PACKET_INs for the flows that have been recently programmed. A high repeat-miss
+// rate indicates flow install problems or flow table overflows.
+func (r *Device) PacketInCounters() (newFlow, repeatMiss uint64) {
+	return r.programmed.Counters()
+}
+
+// FlowOptions are the options of the normal flows installed by SetFlowWithOptions.
+type FlowOptions struct {
+	// Zero timeout means no timeout.
+	IdleTimeout uint16 // Seconds
+	HardTimeout uint16 // Seconds
+	// Each timeout is randomized within the timeout ± its jitter so that the flows installed in a burst
+	// do not expire at the same time. Zero jitter means the exact timeout.
+	IdleTimeoutJitter uint16 // Seconds
+	HardTimeoutJitter uint16 // Seconds
+	Priority          uint16
+	// Meter that rate limits the flow. Zero ID means no meter.
+	MeterID uint32
+	// Queue of the output port that the packets are enqueued into. It is used only if Enqueue is true
+	// because zero is a valid queue ID.
+	Enqueue bool
+	QueueID uint32
+	// Reset the packet and byte counts of the flow when it is installed again. It is only supported by
+	// OpenFlow 1.3 switches.
+	ResetCounts bool
+	// Additional output port that a copy of the packets is sent to, e.g., a SPAN port for monitoring. Zero
+	// means no mirror port. The copy is not enqueued even if Enqueue is true.
+	MirrorPort uint32
+}
+
+// DefaultFlowOptions are the options used by SetFlow.
+var DefaultFlowOptions = FlowOptions{
+	// This idle timeout is actually useless because we update the installed flows
+	// more frequently than this timeout.
+	IdleTimeout: 90,
+	Priority:    10,
+}
+
+// SetFlow installs a normal flow entry for packet switching and routing into the switch device. The
+// flow has the cookie of owner so that it can be removed by RemoveFlow and RemoveAppFlows of owner.
+func (r *Device) SetFlow(owner AppCookie, match openflow.Match, port openflow.OutPort) error {
+	return r.setFlow(owner, match, port, DefaultFlowOptions)
+}
+
+// SetMeteredFlow is same with SetFlow except that the flow is rate limited by the meter whose
+// ID is meterID. The meter should be installed in advance by SetMeter.
+func (r *Device) SetMeteredFlow(owner AppCookie, match openflow.Match, port openflow.OutPort, meterID uint32) error {
+	if meterID == 0 {
+		return errors.New("invalid meter ID: 0")
+	}
+	opts := DefaultFlowOptions
+	opts.MeterID = meterID
+
+	return r.setFlow(owner, match, port, opts)
+}
+
+// SetQueuedFlow is same with SetFlow except that the packets are enqueued into the queue whose ID is queueID
+// on the output port. The queue should be discovered in advance by RequestQueueConfig.
+func (r *Device) SetQueuedFlow(owner AppCookie, match openflow.Match, port openflow.OutPort, queueID uint32) error {
+	opts := DefaultFlowOptions
+	opts.Enqueue = true
+	opts.QueueID = queueID
+
+	return r.setFlow(owner, match, port, opts)
+}
+
+// SetFlowWithOptions is same with SetFlow except that the timeouts, priority, and meter of the flow are
+// specified by opts. The priority should be in the priority band of owner.
+func (r *Device) SetFlowWithOptions(owner AppCookie, match openflow.Match, port openflow.OutPort, opts FlowOptions) error {
+	return r.setFlow(owner, match, port, opts)
+}
+
+func (r *Device) setFlow(owner AppCookie, match openflow.Match, port openflow.OutPort, opts FlowOptions) error {
+	return r.SetFlows(owner, []FlowEntry{{Match: match, Port: port, Options: opts}})
+}
+
+// FlowEntry is a normal flow installed by SetFlows.
+type FlowEntry struct {
+	Match   openflow.Match
+	Port    openflow.OutPort
+	Options FlowOptions
+}
+
+// SetFlows installs the normal flows of owner, which are same with the ones of SetFlowWithOptions, and then
+// sends msgs, e.g., a PACKET_OUT that should be forwarded by the flows, to the device. All of them are sent in
+// order using a single write operation. The flows that are already being installed are skipped. Nothing is
+// sent if any of the flows is invalid.
+func (r *Device) SetFlows(owner AppCookie, flows []FlowEntry, msgs ...encoding.BinaryMarshaler) error {
+	for _, v := range flows {
+		if err := owner.ValidatePriority(v.Options.Priority); err != nil {
+			return err
+		}
+	}
+
 	// Write lock
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -274,20 +660,92 @@ func (r *Device) SetFlow(match openflow.Match, port openflow.OutPort) error {
 		return ErrClosedDevice
 	}
 
+	// All the flows are validated before any of them is written so that an invalid flow does not leave
+	// the others half-installed.
+	flowmods := make([]openflow.FlowMod, len(flows))
+	for i, v := range flows {
+		flow, err := r.newFlowMod(owner, v)
+		if err != nil {
+			return err
+		}
+		flowmods[i] = flow
+	}
+
+	batch := make([]encoding.BinaryMarshaler, 0, len(flows)+len(msgs)+1)
+	installed := make([]FlowEntry, 0, len(flows))
+	for i, v := range flows {
+		flow := flowmods[i]
+		ok, err := r.flowCache.InProgress(v.Match, v.Port, v.Options)
+		if err != nil {
+			return err
+		}
+		if ok {
+			logger.Debugf("skip to install a new flow: already installed one: deviceID=%v", r.id)
+			continue
+		}
+		batch = append(batch, flow)
+		installed = append(installed, v)
+	}
+	if len(installed) > 0 {
+		barrier, err := r.factory.NewBarrierRequest()
+		if err != nil {
+			return err
+		}
+		batch = append(batch, barrier)
+	}
+	batch = append(batch, msgs...)
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// Install the new flows.
+	if err := r.session.WriteBatch(batch); err != nil {
+		return err
+	}
+	// The flows have been written, so the bookkeeping of the remaining entries continues even if one of them fails.
+	for _, v := range installed {
+		if err := r.flowCache.Add(owner, v.Match, v.Port, v.Options); err != nil {
+			logger.Errorf("failed to add an installed flow to the flow cache on %v: %v", r.id, err)
+		}
+		if wildcard, mac := v.Match.DstMAC(); !wildcard {
+			r.programmed.Add(owner, mac)
+		}
+		if err := r.addFlowIntent(flowIntent{owner: owner, match: v.Match, port: v.Port, opts: v.Options, timestamp: time.Now()}); err != nil {
+			logger.Errorf("failed to record the intent of an installed flow on %v: %v", r.id, err)
+		}
+	}
+
+	return nil
+}
+
+// newFlowMod returns a validated flow-mod that adds the normal flow of owner. The caller should hold the write lock.
+func (r *Device) newFlowMod(owner AppCookie, entry FlowEntry) (openflow.FlowMod, error) {
+	match, port, opts := entry.Match, entry.Port, entry.Options
 	// Set the default VLAN ID. It is necessary to use the L2 MAC flow table of Dell SXXX switches.
-	match.SetVLANID(r.vlanID)
+	setDefaultVLAN(match, r.vlanID)
 
 	action, err := r.factory.NewAction()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	action.SetOutPort(port)
+	if opts.Enqueue {
+		action.SetQueue(opts.QueueID)
+	}
+	if opts.MirrorPort != 0 {
+		mirror := openflow.NewOutPort()
+		mirror.SetValue(opts.MirrorPort)
+		action.AddOutPort(mirror)
+	}
 
 	inst, err := r.factory.NewInstruction()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	inst.ApplyAction(action)
+	if opts.MeterID != 0 {
+		inst.SetMeter(opts.MeterID)
+	}
 
 	// For valid (non-overlapping) ADD requests, or those with no overlap checking,
 	// the switch must insert the flow entry at the lowest numbered table for which
@@ -295,112 +753,142 @@ func (r *Device) SetFlow(match openflow.Match, port openflow.OutPort) error {
 	// the priority would be observed during the matching process. If a flow entry
 	// with identical header fields and priority already resides in any table, then
 	// that entry, including its counters, must be removed, and the new flow entry added.
-	flow, err := r.factory.NewFlowMod(openflow.FlowAdd)
+	flow, err := NewAppFlowMod(r.factory, openflow.FlowAdd, owner)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	flow.SetTableID(r.flowTableID)
-	// This idle timeout is actually useless because we update the installed flows
-	// more frequently than this timeout.
-	flow.SetIdleTimeout(90)
-	flow.SetPriority(10)
+	flow.SetIdleTimeout(jitterTimeout(opts.IdleTimeout, opts.IdleTimeoutJitter))
+	flow.SetHardTimeout(jitterTimeout(opts.HardTimeout, opts.HardTimeoutJitter))
+	flow.SetPriority(opts.Priority)
+	if opts.ResetCounts {
+		flags := flow.Flags()
+		flags.ResetCounts = true
+		flow.SetFlags(flags)
+	}
 	flow.SetFlowMatch(match)
 	flow.SetFlowInstruction(inst)
-
-	ok, err := r.flowCache.InProgress(match, port)
-	if err != nil {
-		return err
-	}
-	if ok {
-		logger.Debugf("skip to install a new flow: already installed one: deviceID=%v", r.id)
-		return nil
-	}
-	// Install the new flow.
-	if err := r.session.Write(flow); err != nil {
-		return err
+	if err := r.validateFlowMod(flow); err != nil {
+		return nil, err
 	}
-	if err := r.flowCache.Add(match, port); err != nil {
-		return err
+
+	return flow, nil
+}
+
+// setDefaultVLAN sets vlanID to match unless match already has a VLAN ID, e.g., the VLAN ID of a tagged
+// packet that the flow is installed for.
+func setDefaultVLAN(match openflow.Match, vlanID uint16) {
+	if wildcard, _ := match.VLANID(); wildcard {
+		match.SetVLANID(vlanID)
 	}
+}
 
-	barrier, err := r.factory.NewBarrierRequest()
+// addFlowIntent records the intent so that its flow can be installed again when the device reconnects. The
+// caller should hold the write lock.
+func (r *Device) addFlowIntent(intent flowIntent) error {
+	key, err := r.flowCache.key(intent.match, intent.port)
 	if err != nil {
 		return err
 	}
+	r.intents[key] = intent
 
-	return r.session.Write(barrier)
+	return nil
 }
 
-// RemoveFlows removes all the normal flows except special ones for table miss and ARP packets.
-func (r *Device) RemoveFlows() error {
+// forgetFlow removes the states of the flow that has been removed from the device, so that the flow can be
+// installed again right away, is not installed again when the device reconnects, and its next PACKET_IN is
+// not counted as a repeat miss.
+func (r *Device) forgetFlow(flow openflow.FlowRemoved) {
 	// Write lock
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if r.closed {
-		return ErrClosedDevice
+	match := flow.Match()
+	// The other states are still removed even if the flow cache cannot be.
+	if err := r.flowCache.RemoveMatch(match); err != nil {
+		logger.Errorf("failed to remove the flow cache of the removed flow: %v", err)
 	}
-
-	match, err := r.factory.NewMatch()
+	if wildcard, mac := match.DstMAC(); !wildcard {
+		r.programmed.Remove(mac)
+	}
+	m, err := encodeMatch(match)
 	if err != nil {
-		return err
+		return
 	}
-	// Default VLAN ID specified for the normal flows.
-	match.SetVLANID(r.vlanID)
+	for k, v := range r.intents {
+		if !v.owner.Owns(flow.Cookie()) {
+			continue
+		}
+		if e, err := encodeMatch(v.match); err == nil && e == m {
+			delete(r.intents, k)
+		}
+	}
+}
 
-	// Set output port to OFPP_NONE
-	port := openflow.NewOutPort()
-	port.SetNone()
+// flowIntents returns the intents of the flows that are not yet expired at now.
+func (r *Device) flowIntents(now time.Time) []flowIntent {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
 
-	flowmod, err := r.factory.NewFlowMod(openflow.FlowDelete)
-	if err != nil {
-		return err
+	result := make([]flowIntent, 0, len(r.intents))
+	for _, v := range r.intents {
+		if v.expired(now) {
+			continue
+		}
+		result = append(result, v)
 	}
-	// Remove all the normal flows, except the special table miss and ARP flows whose MSB is 1.
-	flowmod.SetCookieMask(0x1 << 63)
-	flowmod.SetTableID(0xFF) // ALL
-	flowmod.SetFlowMatch(match)
-	flowmod.SetOutPort(port)
-	if err := r.session.Write(flowmod); err != nil {
-		return err
+
+	return result
+}
+
+// resumeFlows installs the flows of intents, which have been retained while the device was disconnected, and
+// returns the number of the installed flows.
+func (r *Device) resumeFlows(intents []flowIntent) int {
+	n := 0
+	for _, v := range intents {
+		if err := r.setFlow(v.owner, v.match, v.port, v.opts); err != nil {
+			logger.Errorf("failed to resume a flow on %v: %v", r.ID(), err)
+			continue
+		}
+		n++
 	}
-	r.flowCache.RemoveAll()
 
-	return nil
+	return n
 }
 
-// TODO:
-// Remove the flow caches that match the removed flows. This is not a critical
-// issue, but same flows cannot be installed until the caches are expired.
-func (r *Device) RemoveFlow(match openflow.Match, port openflow.OutPort) error {
+// resumeStaticFlows installs the static flows of the operators again, and returns the number of the installed
+// flows. The flows that reference the ports not existing anymore are skipped.
+func (r *Device) resumeStaticFlows(flows []openflow.FlowMod) int {
 	// Write lock
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
 	if r.closed {
-		return ErrClosedDevice
+		return 0
 	}
 
-	// Default VLAN ID specified for the normal flows.
-	match.SetVLANID(r.vlanID)
-
-	flowmod, err := r.factory.NewFlowMod(openflow.FlowDelete)
-	if err != nil {
-		return err
+	n := 0
+	for _, v := range flows {
+		if err := r.validateFlowMod(v); err != nil {
+			logger.Errorf("failed to reinstall a static flow on %v: %v", r.id, err)
+			continue
+		}
+		if err := r.session.Write(v); err != nil {
+			logger.Errorf("failed to reinstall a static flow on %v: %v", r.id, err)
+			continue
+		}
+		n++
 	}
-	// Remove all the normal flows, except the table miss and ARP flows whose MSB is 1.
-	flowmod.SetCookieMask(0x1 << 63)
-	flowmod.SetTableID(0xFF) // ALL
-	flowmod.SetFlowMatch(match)
-	flowmod.SetOutPort(port)
 
-	return r.session.Write(flowmod)
+	return n
 }
 
-// TODO:
-// Remove the flow caches that match the removed flows. This is not a critical
-// issue, but same flows cannot be installed until the caches are expired.
-func (r *Device) RemoveFlowByMAC(mac net.HardwareAddr) error {
+// SetMeter installs a meter into the switch device so that flows can be rate limited by the meter. The meter
+// that has the same ID is deleted first because the switch keeps its meters across reconnects and refuses
+// to add an existing meter. It should be called before installing the flows that use the meter because
+// deleting the meter also removes them.
+func (r *Device) SetMeter(meter openflow.Meter) error {
 	// Write lock
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -409,34 +897,1195 @@ func (r *Device) RemoveFlowByMAC(mac net.HardwareAddr) error {
 		return ErrClosedDevice
 	}
 
-	match, err := r.factory.NewMatch()
+	// The switch may reply an ERROR for the delete request if the meter does not exist, which is harmless.
+	del, err := r.factory.NewMeterMod(openflow.MeterDelete)
 	if err != nil {
 		return err
 	}
-	// Default VLAN ID specified for the normal flows.
-	match.SetVLANID(r.vlanID)
-	match.SetDstMAC(mac)
-
-	port := openflow.NewOutPort()
-	port.SetNone()
-
-	flowmod, err := r.factory.NewFlowMod(openflow.FlowDelete)
+	del.SetMeter(meter)
+	add, err := r.factory.NewMeterMod(openflow.MeterAdd)
+	if err != nil {
+		return err
+	}
+	add.SetMeter(meter)
+	barrier, err := r.factory.NewBarrierRequest()
 	if err != nil {
 		return err
 	}
-	// Remove all the normal flows, except the table miss and ARP flows whose MSB is 1.
-	flowmod.SetCookieMask(0x1 << 63)
-	flowmod.SetTableID(0xFF) // ALL
-	flowmod.SetFlowMatch(match)
-	flowmod.SetOutPort(port)
+
+	return r.session.WriteBatch([]encoding.BinaryMarshaler{del, add, barrier})
+}
+
+// SetSelectGroup adds or modifies, according to cmd, the select group whose ID is groupID so that the
+// switch device distributes the packets over ports by its own selection algorithm, e.g., ECMP. Each port
+// has a bucket with the same weight. OpenFlow 1.3 only.
+func (r *Device) SetSelectGroup(cmd openflow.GroupCommand, groupID uint32, ports []*Port) error {
+	if cmd != openflow.GroupAdd && cmd != openflow.GroupModify {
+		return fmt.Errorf("invalid group command: %v", cmd)
+	}
+	if len(ports) == 0 {
+		return errors.New("empty ports for a select group")
+	}
+
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	mod, err := r.factory.NewGroupMod(cmd)
+	if err != nil {
+		return err
+	}
+	mod.SetGroupType(openflow.GroupSelect)
+	mod.SetGroupID(groupID)
+	for _, p := range ports {
+		if _, ok := r.ports[p.Number()]; !ok || p.Device() != r {
+			return fmt.Errorf("invalid group bucket: unknown port %v on device %v", p.ID(), r.id)
+		}
+		action, err := r.factory.NewAction()
+		if err != nil {
+			return err
+		}
+		out := openflow.NewOutPort()
+		out.SetValue(p.Number())
+		action.SetOutPort(out)
+
+		bucket := openflow.NewBucket(action)
+		bucket.Weight = 1
+		mod.AddBucket(bucket)
+	}
+	if err := r.session.Write(mod); err != nil {
+		return err
+	}
+
+	barrier, err := r.factory.NewBarrierRequest()
+	if err != nil {
+		return err
+	}
+
+	return r.session.Write(barrier)
+}
+
+// RemoveGroup removes the group whose ID is groupID. The flows that refer to the group are also removed
+// by the switch device.
+func (r *Device) RemoveGroup(groupID uint32) error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	mod, err := r.factory.NewGroupMod(openflow.GroupDelete)
+	if err != nil {
+		return err
+	}
+	mod.SetGroupID(groupID)
+
+	return r.session.Write(mod)
+}
+
+// SetGroupFlow installs a normal flow entry that makes the matched packets processed by the group whose
+// ID is groupID. The group should be installed in advance, e.g., by SetSelectGroup. The priority in opts
+// should be in the priority band of owner.
+func (r *Device) SetGroupFlow(owner AppCookie, match openflow.Match, groupID uint32, opts FlowOptions) error {
+	if err := owner.ValidatePriority(opts.Priority); err != nil {
+		return err
+	}
+
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	// Set the default VLAN ID. It is necessary to use the L2 MAC flow table of Dell SXXX switches.
+	setDefaultVLAN(match, r.vlanID)
+
+	action, err := r.factory.NewAction()
+	if err != nil {
+		return err
+	}
+	action.SetGroup(groupID)
+
+	inst, err := r.factory.NewInstruction()
+	if err != nil {
+		return err
+	}
+	inst.ApplyAction(action)
+	if opts.MeterID != 0 {
+		inst.SetMeter(opts.MeterID)
+	}
+
+	flow, err := NewAppFlowMod(r.factory, openflow.FlowAdd, owner)
+	if err != nil {
+		return err
+	}
+	flow.SetTableID(r.flowTableID)
+	flow.SetIdleTimeout(jitterTimeout(opts.IdleTimeout, opts.IdleTimeoutJitter))
+	flow.SetHardTimeout(jitterTimeout(opts.HardTimeout, opts.HardTimeoutJitter))
+	flow.SetPriority(opts.Priority)
+	flow.SetFlowMatch(match)
+	flow.SetFlowInstruction(inst)
+	if err := r.validateFlowMod(flow); err != nil {
+		return err
+	}
+	if err := r.session.Write(flow); err != nil {
+		return err
+	}
+	if wildcard, mac := match.DstMAC(); !wildcard {
+		r.programmed.Add(owner, mac)
+	}
+
+	barrier, err := r.factory.NewBarrierRequest()
+	if err != nil {
+		return err
+	}
+
+	return r.session.Write(barrier)
+}
+
+// SetDropFlow installs a flow that drops the matched packets until timeout expires. The flow has the cookie of
+// owner so that it can be removed by RemoveAppFlows, and a forwarding flow of SetFlow with the same match and
+// priority replaces it. priority should be in the priority band of owner.
+func (r *Device) SetDropFlow(owner AppCookie, match openflow.Match, priority uint16, timeout time.Duration) error {
+	if err := owner.ValidatePriority(priority); err != nil {
+		return err
+	}
+
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+	if timeout < time.Second || timeout > maxHardTimeout {
+		return fmt.Errorf("invalid drop timeout: %v", timeout)
+	}
+
+	// Same VLAN ID as the flows of SetFlow.
+	setDefaultVLAN(match, r.vlanID)
+
+	flow, err := NewAppFlowMod(r.factory, openflow.FlowAdd, owner)
+	if err != nil {
+		return err
+	}
+	flow.SetTableID(r.flowTableID)
+	flow.SetHardTimeout(uint16(timeout / time.Second))
+	flow.SetPriority(priority)
+	// No instruction means dropping the matched packets.
+	flow.SetFlowMatch(match)
+	if err := r.validateFlowMod(flow); err != nil {
+		return err
+	}
+	if err := r.session.Write(flow); err != nil {
+		return err
+	}
+
+	barrier, err := r.factory.NewBarrierRequest()
+	if err != nil {
+		return err
+	}
+
+	return r.session.Write(barrier)
+}
+
+// SetClassificationFlow installs a permanent flow in the classification table, which is looked up before the
+// normal flows, e.g., an access control rule. The flow drops the matched packets if next is false. Otherwise,
+// it continues the lookup in the forwarding table so that the packets are forwarded by the normal flows, which
+// needs the two-stage pipeline built by SetForwardingTable. The flow has the cookie of owner so that it can be
+// removed by RemoveAppFlows, and priority should be in the priority band of owner.
+func (r *Device) SetClassificationFlow(owner AppCookie, match openflow.Match, priority uint16, next bool) error {
+	if err := owner.ValidatePriority(priority); err != nil {
+		return err
+	}
+
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	flow, err := NewAppFlowMod(r.factory, openflow.FlowAdd, owner)
+	if err != nil {
+		return err
+	}
+	flow.SetTableID(0)
+	flow.SetPriority(priority)
+	flow.SetFlowMatch(match)
+	if next {
+		if r.flowTableID == 0 {
+			return fmt.Errorf("device %v does not have the forwarding table to continue the lookup", r.id)
+		}
+		inst, err := r.factory.NewGotoTableInstruction(r.flowTableID)
+		if err != nil {
+			return err
+		}
+		flow.SetFlowInstruction(inst)
+	}
+	// No instruction means dropping the matched packets.
+	if err := r.validateFlowMod(flow); err != nil {
+		return err
+	}
+	if err := r.session.Write(flow); err != nil {
+		return err
+	}
+
+	barrier, err := r.factory.NewBarrierRequest()
+	if err != nil {
+		return err
+	}
+
+	return r.session.Write(barrier)
+}
+
+// SetPuntFlow installs a flow that forwards the matched packets to the controller. cookie should
+// be a punt cookie returned by PuntCookie so that the punted packets are delivered to its owner
+// application. priority should be higher than that of the normal flows to override them.
+func (r *Device) SetPuntFlow(match openflow.Match, priority uint16, cookie uint64) error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+	if !IsPuntCookie(cookie) {
+		return fmt.Errorf("invalid punt cookie: 0x%X", cookie)
+	}
+
+	outPort := openflow.NewOutPort()
+	outPort.SetController()
+	action, err := r.factory.NewAction()
+	if err != nil {
+		return err
+	}
+	action.SetOutPort(outPort)
+	inst, err := r.factory.NewInstruction()
+	if err != nil {
+		return err
+	}
+	inst.ApplyAction(action)
+
+	flow, err := r.factory.NewFlowMod(openflow.FlowAdd)
+	if err != nil {
+		return err
+	}
+	flow.SetCookie(cookie)
+	flow.SetTableID(r.flowTableID)
+	flow.SetPriority(priority)
+	flow.SetFlowMatch(match)
+	flow.SetFlowInstruction(inst)
+	if err := r.validateFlowMod(flow); err != nil {
+		return err
+	}
+	if err := r.session.Write(flow); err != nil {
+		return err
+	}
+
+	barrier, err := r.factory.NewBarrierRequest()
+	if err != nil {
+		return err
+	}
+
+	return r.session.Write(barrier)
+}
+
+// RemovePuntFlow removes the punt flows installed by SetPuntFlow with cookie, whose matches are same with or
+// more specific than match.
+func (r *Device) RemovePuntFlow(match openflow.Match, cookie uint64) error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+	if !IsPuntCookie(cookie) {
+		return fmt.Errorf("invalid punt cookie: 0x%X", cookie)
+	}
+
+	// OpenFlow 1.0 ignores the cookie of a delete request, so the output port keeps
+	// the normal flows that have the same match from being removed.
+	port := openflow.NewOutPort()
+	port.SetController()
+
+	flowmod, err := r.factory.NewFlowMod(openflow.FlowDelete)
+	if err != nil {
+		return err
+	}
+	flowmod.SetCookie(cookie)
+	flowmod.SetCookieMask(0xFFFFFFFFFFFFFFFF)
+	flowmod.SetTableID(0xFF) // ALL
+	flowmod.SetFlowMatch(match)
+	flowmod.SetOutPort(port)
+
+	return r.session.Write(flowmod)
+}
+
+// DefaultMulticastFlowOptions are the options of the multicast flows that are used unless configured.
+var DefaultMulticastFlowOptions = FlowOptions{
+	IdleTimeout: 90,
+	Priority:    PriorityBandMulticast.Min,
+}
+
+// SetMulticastFlow installs a flow that replicates the multicast packets from srcIP to group toward the ports.
+// Only the timeouts and the priority of opts are used, and the priority should be in PriorityBandMulticast.
+func (r *Device) SetMulticastFlow(srcIP, group net.IP, ports []*Port, opts FlowOptions) error {
+	if err := PriorityBandMulticast.Validate(opts.Priority); err != nil {
+		return err
+	}
+
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+	if len(ports) == 0 {
+		return errors.New("empty multicast output ports")
+	}
+
+	match, err := r.factory.NewMatch()
+	if err != nil {
+		return err
+	}
+	match.SetVLANID(r.vlanID)
+	match.SetEtherType(0x0800) // IPv4
+	match.SetSrcIP(&net.IPNet{IP: srcIP, Mask: net.CIDRMask(32, 32)})
+	match.SetDstIP(&net.IPNet{IP: group, Mask: net.CIDRMask(32, 32)})
+
+	action, err := r.factory.NewAction()
+	if err != nil {
+		return err
+	}
+	for i, p := range ports {
+		outPort := openflow.NewOutPort()
+		outPort.SetValue(p.Number())
+		if i == 0 {
+			action.SetOutPort(outPort)
+		} else {
+			action.AddOutPort(outPort)
+		}
+	}
+	inst, err := r.factory.NewInstruction()
+	if err != nil {
+		return err
+	}
+	inst.ApplyAction(action)
+
+	flow, err := r.factory.NewFlowMod(openflow.FlowAdd)
+	if err != nil {
+		return err
+	}
+	flow.SetTableID(r.flowTableID)
+	flow.SetIdleTimeout(opts.IdleTimeout)
+	flow.SetHardTimeout(opts.HardTimeout)
+	// Higher than the forwarding flows.
+	flow.SetPriority(opts.Priority)
+	flow.SetFlowMatch(match)
+	flow.SetFlowInstruction(inst)
+	if err := r.validateFlowMod(flow); err != nil {
+		return err
+	}
+	if err := r.session.Write(flow); err != nil {
+		return err
+	}
+
+	barrier, err := r.factory.NewBarrierRequest()
+	if err != nil {
+		return err
+	}
+
+	return r.session.Write(barrier)
+}
+
+// RemoveMulticastFlows removes all the multicast flows toward group regardless of their sources.
+func (r *Device) RemoveMulticastFlows(group net.IP) error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	match, err := r.factory.NewMatch()
+	if err != nil {
+		return err
+	}
+	match.SetVLANID(r.vlanID)
+	match.SetEtherType(0x0800) // IPv4
+	match.SetDstIP(&net.IPNet{IP: group, Mask: net.CIDRMask(32, 32)})
+
+	port := openflow.NewOutPort()
+	port.SetNone()
+
+	flowmod, err := r.factory.NewFlowMod(openflow.FlowDelete)
+	if err != nil {
+		return err
+	}
+	// Remove the normal flows only, except the special ones whose MSB is 1.
+	flowmod.SetCookieMask(0x1 << 63)
+	flowmod.SetTableID(0xFF) // ALL
+	flowmod.SetFlowMatch(match)
+	flowmod.SetOutPort(port)
 
 	return r.session.Write(flowmod)
 }
 
+// FlowStats returns the last snapshot of the flow statistics periodically polled from the switch device. It
+// returns nil if the statistics have not been polled yet.
+func (r *Device) FlowStats() []openflow.FlowStat {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.flowStats.snapshot == nil {
+		return nil
+	}
+
+	return append([]openflow.FlowStat{}, r.flowStats.snapshot...)
+}
+
+// MatchFlowStats returns the statistics of the flows whose match is match in the last snapshot of the flow
+// statistics. It returns nil if there is no such flow or the statistics have not been polled yet.
+func (r *Device) MatchFlowStats(match openflow.Match) ([]openflow.FlowStat, error) {
+	m, err := encodeMatch(match)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	stats, ok := r.flowStats.matches[m]
+	if !ok {
+		return nil, nil
+	}
+
+	return append([]openflow.FlowStat{}, stats...), nil
+}
+
+// updateFlowStats accumulates the stats of a FLOW_STATS reply, and replaces the snapshot with the accumulated
+// ones if more is false, i.e., the reply is the last one of the response.
+func (r *Device) updateFlowStats(stats []openflow.FlowStat, more bool) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.flowStats.pending = append(r.flowStats.pending, stats...)
+	if more {
+		return
+	}
+	r.flowStats.snapshot = r.flowStats.pending
+	if r.flowStats.snapshot == nil {
+		r.flowStats.snapshot = []openflow.FlowStat{}
+	}
+	r.flowStats.pending = nil
+	r.flowStats.matches = make(map[string][]openflow.FlowStat)
+	for _, v := range r.flowStats.snapshot {
+		// The match may not be decoded.
+		if v.Match == nil {
+			continue
+		}
+		m, err := encodeMatch(v.Match)
+		if err != nil {
+			logger.Errorf("failed to encode the match of the flow stats: %v", err)
+			continue
+		}
+		r.flowStats.matches[m] = append(r.flowStats.matches[m], v)
+	}
+}
+
+// RequestTableStats asks the switch device for the statistics of all its flow tables. The reply is
+// available through TableStats when it arrives.
+func (r *Device) RequestTableStats() error {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	msg, err := r.factory.NewTableStatsRequest()
+	if err != nil {
+		return err
+	}
+
+	return r.session.Write(msg)
+}
+
+// TableStats returns the last snapshot of the flow table statistics requested by RequestTableStats. It
+// returns nil if the statistics have not been received yet.
+func (r *Device) TableStats() []openflow.TableStat {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.tableStats.snapshot == nil {
+		return nil
+	}
+
+	return append([]openflow.TableStat{}, r.tableStats.snapshot...)
+}
+
+// updateTableStats accumulates the stats of a TABLE_STATS reply, and replaces the snapshot with the
+// accumulated ones if more is false, i.e., the reply is the last one of the response.
+func (r *Device) updateTableStats(stats []openflow.TableStat, more bool) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.tableStats.pending = append(r.tableStats.pending, stats...)
+	if more {
+		return
+	}
+	r.tableStats.snapshot = r.tableStats.pending
+	if r.tableStats.snapshot == nil {
+		r.tableStats.snapshot = []openflow.TableStat{}
+	}
+	r.tableStats.pending = nil
+}
+
+// SetTableConfig sets the config of the flow table whose ID is tableID, or all the tables if tableID
+// is openflow.TableAll. OpenFlow 1.0 does not support this.
+func (r *Device) SetTableConfig(tableID uint8, config uint32) error {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	msg, err := r.factory.NewTableMod()
+	if err != nil {
+		return err
+	}
+	msg.SetTableID(tableID)
+	msg.SetConfig(config)
+
+	return r.session.Write(msg)
+}
+
+// RequestRole asks the device to change the role of the controller to role for the multi-controller high
+// availability. generationID should increase whenever the master is elected so that the device rejects the
+// requests of a stale master. The role takes effect when the device confirms it, and then it is available from
+// Role. While the controller is a slave, the messages that modify the device, such as FLOW_MOD and PACKET_OUT,
+// fail with transceiver.ErrSlaveRole. OpenFlow 1.3 only.
+func (r *Device) RequestRole(role openflow.ControllerRole, generationID uint64) error {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	msg, err := r.factory.NewRoleRequest()
+	if err != nil {
+		return err
+	}
+	msg.SetRole(role)
+	msg.SetGenerationID(generationID)
+
+	return r.session.Write(msg)
+}
+
+// Role returns the role of the controller confirmed by the device.
+func (r *Device) Role() openflow.ControllerRole {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.role == openflow.RoleNoChange {
+		return openflow.RoleEqual
+	}
+
+	return r.role
+}
+
+func (r *Device) setRole(role openflow.ControllerRole) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.role = role
+}
+
+// RequestQueueConfig asks the device for the queues configured on the port whose number is portNo. The reply
+// is received asynchronously, and then the queues are available from Queues.
+func (r *Device) RequestQueueConfig(portNo uint32) error {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+	if _, ok := r.ports[portNo]; !ok {
+		return fmt.Errorf("unknown port %v on device %v", portNo, r.id)
+	}
+
+	msg, err := r.factory.NewQueueGetConfigRequest()
+	if err != nil {
+		return err
+	}
+	port := openflow.NewOutPort()
+	port.SetValue(portNo)
+	msg.SetPort(port)
+
+	return r.session.Write(msg)
+}
+
+// Queues returns the IDs of the queues configured on the port whose number is portNo. False is returned if
+// the queue configuration of the port has not been received yet.
+func (r *Device) Queues(portNo uint32) ([]uint32, bool) {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	v, ok := r.queues[portNo]
+	if !ok {
+		return nil, false
+	}
+
+	return append([]uint32{}, v...), true
+}
+
+func (r *Device) setQueues(portNo uint32, queues []uint32) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.queues == nil {
+		r.queues = make(map[uint32][]uint32)
+	}
+	r.queues[portNo] = queues
+}
+
+// hasQueue returns whether the queue whose ID is queueID has been discovered on the port. The caller should
+// hold the device lock.
+func (r *Device) hasQueue(portNo, queueID uint32) bool {
+	for _, v := range r.queues[portNo] {
+		if v == queueID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PortStats returns the last sample of the statistics of the port whose number is portNo, which is
+// periodically polled from the switch device. It returns false if the port has not been polled yet.
+func (r *Device) PortStats(portNo uint32) (PortStats, bool) {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	v, ok := r.portStats[portNo]
+	if !ok {
+		return PortStats{}, false
+	}
+
+	return *v, true
+}
+
+// updatePortStats replaces the samples of the ports in stats, and calculates their utilization from
+// the previous samples. now is the time when the stats have been received.
+func (r *Device) updatePortStats(stats []openflow.PortStat, now time.Time) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.portStats == nil {
+		r.portStats = make(map[uint32]*PortStats)
+	}
+
+	for _, s := range stats {
+		v := &PortStats{PortStat: s, Timestamp: now}
+		if prev, ok := r.portStats[s.PortNo]; ok {
+			elapsed := now.Sub(prev.Timestamp).Seconds()
+			if elapsed > 0 {
+				if delta, ok := counterDelta(prev.RxBytes, s.RxBytes); ok {
+					v.RxBitsPerSec = uint64(float64(delta*8) / elapsed)
+				}
+				if delta, ok := counterDelta(prev.TxBytes, s.TxBytes); ok {
+					v.TxBitsPerSec = uint64(float64(delta*8) / elapsed)
+				}
+			}
+		}
+		r.portStats[s.PortNo] = v
+	}
+}
+
+// counterDelta returns the increment of a counter from prev to cur. A counter that has gone backward
+// is assumed to be a 32-bit counter wrapped around if prev fits in 32 bits; otherwise, the counter has
+// been reset and false is returned. False is also returned if the counter is not supported by the switch.
+func counterDelta(prev, cur uint64) (delta uint64, ok bool) {
+	// All ones means the counter is not available.
+	if prev == math.MaxUint64 || cur == math.MaxUint64 {
+		return 0, false
+	}
+	if cur >= prev {
+		return cur - prev, true
+	}
+	if prev <= math.MaxUint32 && cur <= math.MaxUint32 {
+		return math.MaxUint32 - prev + cur + 1, true
+	}
+
+	return 0, false
+}
+
+// InstallFlowSync installs the flow into the switch device and blocks until the switch confirms that the flow
+// has been processed using a barrier request, so that the caller can install a chain of flows before sending
+// out the packet. It returns a *openflow.FlowModError if the switch rejected the flow with a FLOW_MOD_FAILED
+// error, whose cause is one of the openflow.ErrFlowXXX errors, or a *transceiver.RequestError for the other
+// errors replied by the switch.
+func (r *Device) InstallFlowSync(flow openflow.FlowMod) error {
+	barrier, err := r.prepareFlowSync(flow)
+	if err != nil {
+		return err
+	}
+
+	// Wait for the confirmation without holding the device lock.
+	ctx, cancel := r.session.transceiver.ConfirmContext(context.Background())
+	defer cancel()
+
+	if err := r.session.transceiver.WriteSync(ctx, barrier, flow); err != nil {
+		if e, ok := err.(*transceiver.RequestError); ok {
+			return flowModError(flow.Version(), e)
+		}
+		return err
+	}
+	atomic.AddUint64(&r.counters.flowMods, 1)
+
+	return nil
+}
+
+// InstallStaticFlow installs the static flow of an operator using InstallFlowSync, and keeps the flow so that
+// it is installed again whenever the device reconnects. A delete flow-mod also removes the kept flows that have
+// the same match, or all of them if it has no match field.
+func (r *Device) InstallStaticFlow(flow openflow.FlowMod) error {
+	if err := r.InstallFlowSync(flow); err != nil {
+		return err
+	}
+	r.session.intents.setStatic(r.ID(), flow)
+
+	return nil
+}
+
+// flowModError converts err into a *openflow.FlowModError if it is a FLOW_MOD_FAILED error of the OpenFlow
+// version. Otherwise, err is returned as it is.
+func flowModError(version uint8, err *transceiver.RequestError) error {
+	var e error
+	switch version {
+	case openflow.OF10_VERSION:
+		e = of10.NewFlowModError(err.XID, err.Class, err.Code)
+	case openflow.OF13_VERSION:
+		e = of13.NewFlowModError(err.XID, err.Class, err.Code)
+	}
+	if e == nil {
+		return err
+	}
+
+	return e
+}
+
+func (r *Device) prepareFlowSync(flow openflow.FlowMod) (openflow.BarrierRequest, error) {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.closed {
+		return nil, ErrClosedDevice
+	}
+	if err := r.validateFlowMod(flow); err != nil {
+		return nil, err
+	}
+
+	return r.factory.NewBarrierRequest()
+}
+
+// validateFlowMod checks the flow in addition to openflow.ValidateFlowMod to make sure that the ports
+// referenced by the flow are known ports of this device. The caller should hold the device lock.
+func (r *Device) validateFlowMod(flow openflow.FlowMod) error {
+	if err := openflow.ValidateFlowMod(flow); err != nil {
+		return err
+	}
+
+	if wildcard, inPort := flow.FlowMatch().InPort(); !wildcard && !inPort.IsController() {
+		if _, ok := r.ports[inPort.Value()]; !ok {
+			return fmt.Errorf("invalid flow-mod match: unknown input port %v on device %v", inPort.Value(), r.id)
+		}
+	}
+	if inst := flow.FlowInstruction(); inst != nil && inst.Action() != nil {
+		if ok, _ := inst.Action().Group(); ok {
+			// The output ports are ignored if a group is set.
+			return nil
+		}
+		for _, out := range inst.Action().OutPorts() {
+			if !out.IsPhysical() {
+				continue
+			}
+			if _, ok := r.ports[out.Value()]; !ok {
+				return fmt.Errorf("invalid flow-mod action: unknown output port %v on device %v", out.Value(), r.id)
+			}
+		}
+		if ok, queueID := inst.Action().Queue(); ok {
+			// Queue IDs are per port, so the queue is looked up on the first output port.
+			out := inst.Action().OutPort()
+			if !r.hasQueue(out.Value(), queueID) {
+				return fmt.Errorf("invalid flow-mod action: unknown queue %v of port %v on device %v", queueID, out.Value(), r.id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RemoveFlows removes all the normal flows except special ones for table miss and ARP packets.
+func (r *Device) RemoveFlows() error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	// The VLAN ID is wildcarded to remove the flows of the tagged packets as well as the ones that have the
+	// default VLAN ID.
+	match, err := r.factory.NewMatch()
+	if err != nil {
+		return err
+	}
+
+	// Set output port to OFPP_NONE
+	port := openflow.NewOutPort()
+	port.SetNone()
+
+	flowmod, err := r.factory.NewFlowMod(openflow.FlowDelete)
+	if err != nil {
+		return err
+	}
+	// Remove all the normal flows, except the special table miss and ARP flows whose MSB is 1.
+	flowmod.SetCookieMask(0x1 << 63)
+	flowmod.SetTableID(0xFF) // ALL
+	flowmod.SetFlowMatch(match)
+	flowmod.SetOutPort(port)
+	if err := r.session.Write(flowmod); err != nil {
+		return err
+	}
+	r.flowCache.RemoveAll()
+	r.programmed.RemoveAll()
+	r.intents = make(map[string]flowIntent)
+
+	return nil
+}
+
+// RemoveAppFlows removes all the normal flows of owner, and keeps the flows of the other applications.
+// OpenFlow 1.0 ignores the cookie of a delete request, so only the flows installed by SetFlow and SetFlows
+// are removed one by one on an OpenFlow 1.0 device, and the others are left until they expire.
+func (r *Device) RemoveAppFlows(owner AppCookie) error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+	if r.factory.ProtocolVersion() == openflow.OF10_VERSION {
+		return r.removeIntentFlows(owner)
+	}
+
+	// The VLAN ID is wildcarded to remove the flows of the tagged packets as well as the ones that have the
+	// default VLAN ID.
+	match, err := r.factory.NewMatch()
+	if err != nil {
+		return err
+	}
+
+	port := openflow.NewOutPort()
+	port.SetNone()
+
+	// The cookie mask of owner excludes the special flows whose MSB is 1.
+	flowmod, err := NewAppFlowMod(r.factory, openflow.FlowDelete, owner)
+	if err != nil {
+		return err
+	}
+	flowmod.SetTableID(0xFF) // ALL
+	flowmod.SetFlowMatch(match)
+	flowmod.SetOutPort(port)
+	if err := r.session.Write(flowmod); err != nil {
+		return err
+	}
+	r.flowCache.RemoveOwner(owner)
+	r.programmed.RemoveOwner(owner)
+	for k, v := range r.intents {
+		if v.owner == owner {
+			delete(r.intents, k)
+		}
+	}
+
+	return nil
+}
+
+// removeIntentFlows removes the flows of owner recorded as the flow intents by their matches and output ports.
+// The caller should hold the write lock.
+func (r *Device) removeIntentFlows(owner AppCookie) error {
+	batch := make([]encoding.BinaryMarshaler, 0)
+	keys := make([]string, 0)
+	for k, v := range r.intents {
+		if v.owner != owner {
+			continue
+		}
+		flowmod, err := NewAppFlowMod(r.factory, openflow.FlowDelete, owner)
+		if err != nil {
+			return err
+		}
+		flowmod.SetTableID(0xFF) // ALL
+		flowmod.SetFlowMatch(v.match)
+		flowmod.SetOutPort(v.port)
+		batch = append(batch, flowmod)
+		keys = append(keys, k)
+	}
+	if len(batch) > 0 {
+		if err := r.session.WriteBatch(batch); err != nil {
+			return err
+		}
+	}
+	for _, k := range keys {
+		delete(r.intents, k)
+	}
+	r.flowCache.RemoveOwner(owner)
+	r.programmed.RemoveOwner(owner)
+
+	return nil
+}
+
+// RemoveFlowsByCookie removes all the flows in all the tables whose cookie masked by mask is same with cookie
+// masked by mask, regardless of their matches, e.g., all the flows of an application using its AppCookie's
+// value and mask. The MSB of mask is always set and that of cookie should be zero so that the special flows
+// are never removed. Zero mask is not allowed because it would remove all the normal flows. It returns
+// openflow.ErrUnsupportedMessage on an OpenFlow 1.0 device.
+func (r *Device) RemoveFlowsByCookie(cookie, mask uint64) error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+	if mask&^specialCookie == 0 {
+		return errors.New("zero cookie mask: use RemoveFlows to remove all the normal flows")
+	}
+	if cookie&specialCookie != 0 {
+		return fmt.Errorf("cookie of the special flows: 0x%X", cookie)
+	}
+	mask |= specialCookie
+	if r.factory.ProtocolVersion() == openflow.OF10_VERSION {
+		// OpenFlow 1.0 ignores the cookie of a delete request, so it would remove all the flows.
+		return openflow.ErrUnsupportedMessage
+	}
+
+	match, err := r.factory.NewMatch()
+	if err != nil {
+		return err
+	}
+	port := openflow.NewOutPort()
+	port.SetNone()
+
+	flowmod, err := r.factory.NewFlowMod(openflow.FlowDelete)
+	if err != nil {
+		return err
+	}
+	flowmod.SetCookie(cookie)
+	flowmod.SetCookieMask(mask)
+	flowmod.SetTableID(0xFF) // ALL
+	flowmod.SetFlowMatch(match)
+	flowmod.SetOutPort(port)
+	if err := r.session.Write(flowmod); err != nil {
+		return err
+	}
+	// The caches do not know the cookies of the flows, so we just clear them all.
+	r.flowCache.RemoveAll()
+	r.programmed.RemoveAll()
+	// The intents only know their owners, so they are removed only if mask selects a single owner.
+	if ownerMask := uint64(appIDMask << appCookieShift); mask&ownerMask == ownerMask {
+		for k, v := range r.intents {
+			if uint64(v.owner)&mask == cookie&mask {
+				delete(r.intents, k)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RemoveFlow removes the normal flows of owner that match the match and port.
+func (r *Device) RemoveFlow(owner AppCookie, match openflow.Match, port openflow.OutPort) error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	// Default VLAN ID specified for the normal flows.
+	setDefaultVLAN(match, r.vlanID)
+
+	// Remove the flows of owner only. The cookie mask of owner excludes the special flows whose MSB is 1.
+	flowmod, err := NewAppFlowMod(r.factory, openflow.FlowDelete, owner)
+	if err != nil {
+		return err
+	}
+	flowmod.SetTableID(0xFF) // ALL
+	flowmod.SetFlowMatch(match)
+	flowmod.SetOutPort(port)
+	if err := r.session.Write(flowmod); err != nil {
+		return err
+	}
+	if key, err := r.flowCache.key(match, port); err == nil {
+		if v, ok := r.intents[key]; ok && v.owner == owner {
+			delete(r.intents, key)
+		}
+	}
+	// Allow the same flow to be installed again right away.
+	if err := r.flowCache.Remove(match, port); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *Device) RemoveFlowByMAC(mac net.HardwareAddr) error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	// The VLAN ID is wildcarded to remove the flows toward mac in all the VLANs, e.g., the flows of the tagged
+	// packets, as well as the ones that have the default VLAN ID.
+	match, err := r.factory.NewMatch()
+	if err != nil {
+		return err
+	}
+	match.SetDstMAC(mac)
+
+	port := openflow.NewOutPort()
+	port.SetNone()
+
+	flowmod, err := r.factory.NewFlowMod(openflow.FlowDelete)
+	if err != nil {
+		return err
+	}
+	// Remove all the normal flows, except the table miss and ARP flows whose MSB is 1.
+	flowmod.SetCookieMask(0x1 << 63)
+	flowmod.SetTableID(0xFF) // ALL
+	flowmod.SetFlowMatch(match)
+	flowmod.SetOutPort(port)
+	if err := r.session.Write(flowmod); err != nil {
+		return err
+	}
+	r.programmed.Remove(mac)
+	r.flowCache.RemoveDstMAC(mac)
+	for k, v := range r.intents {
+		if wildcard, dst := v.match.DstMAC(); !wildcard && bytes.Equal(dst, mac) {
+			delete(r.intents, k)
+		}
+	}
+
+	return nil
+}
+
+// allowPacketIn returns whether the PACKET_IN from ingress should be processed. If ingress exceeds the rate
+// limit of the PACKET_INs, this installs a temporary flow that drops the packets from ingress that would be
+// sent to the controller, until the cooldown period expires.
+func (r *Device) allowPacketIn(ingress *Port) bool {
+	if r.limiter == nil {
+		return true
+	}
+
+	ok, engaged := r.limiter.allow(ingress.Number())
+	if !engaged {
+		return ok
+	}
+	logger.Warningf("throttling PACKET_INs from %v: exceeded %v PACKET_INs per second (cooldown=%v)", ingress.ID(), r.limiter.rate, r.limiter.cooldown)
+	if err := r.setThrottleFlow(ingress.Number(), r.limiter.cooldown); err != nil {
+		logger.Errorf("failed to install the throttle flow on %v: %v", ingress.ID(), err)
+	}
+
+	return false
+}
+
+func (r *Device) setThrottleFlow(port uint32, timeout time.Duration) error {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	flow, err := newThrottleFlow(r.factory, port, r.flowTableID, timeout)
+	if err != nil {
+		return err
+	}
+
+	return r.session.Write(flow)
+}
+
+// Quarantine drops all the packets from mac on this device until timeout expires. The quarantine
+// is automatically lifted by the hard timeout of the flow, without any manual cleanup.
+func (r *Device) Quarantine(mac net.HardwareAddr, timeout time.Duration) error {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	flow, err := newQuarantineFlow(r.factory, mac, r.flowTableID, timeout)
+	if err != nil {
+		return err
+	}
+	if err := r.session.Write(flow); err != nil {
+		return err
+	}
+
+	barrier, err := r.factory.NewBarrierRequest()
+	if err != nil {
+		return err
+	}
+
+	return r.session.Write(barrier)
+}
+
 // NullMAC is a random local MAC address, which does not belong to any host, to disconnect a host from the network.
 var NullMAC = net.HardwareAddr([]byte{0x06, 0xff, 0x01, 0x21, 0x09, 0x03})
 
 func (r *Device) SendARPAnnouncement(ip net.IP, mac net.HardwareAddr) error {
+	blocked := r.blockedPorts()
+
 	// Write lock
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -450,10 +2099,12 @@ func (r *Device) SendARPAnnouncement(ip net.IP, mac net.HardwareAddr) error {
 		return err
 	}
 
-	return r.flood(nil, announcement)
+	return r.flood(nil, announcement, blocked)
 }
 
 func (r *Device) SendARPDiscovery(sha net.HardwareAddr, spa, tpa net.IP) error {
+	blocked := r.blockedPorts()
+
 	// Write lock
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -467,7 +2118,7 @@ func (r *Device) SendARPDiscovery(sha net.HardwareAddr, spa, tpa net.IP) error {
 		return err
 	}
 
-	return r.flood(nil, probe)
+	return r.flood(nil, probe, blocked)
 }
 
 func newARPRequestFrame(sha net.HardwareAddr, spa, tpa net.IP) ([]byte, error) {
@@ -488,6 +2139,23 @@ func newARPRequestFrame(sha net.HardwareAddr, spa, tpa net.IP) ([]byte, error) {
 
 // Flood broadcasts the packet to all ports of this device, except the ingress port if ingress is not nil.
 func (r *Device) Flood(ingress *Port, packet []byte) error {
+	blocked := r.blockedPorts()
+
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosedDevice
+	}
+
+	return r.flood(ingress, packet, blocked)
+}
+
+// ForwardNormal hands the packet received on ingress to the legacy L2/L3 pipeline of a hybrid switch using
+// OFPP_NORMAL, so that the switch forwards it using its own MAC learning. See OutPort.SetNormal for the
+// switches that support it.
+func (r *Device) ForwardNormal(ingress *Port, packet []byte) error {
 	// Write lock
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -496,11 +2164,30 @@ func (r *Device) Flood(ingress *Port, packet []byte) error {
 		return ErrClosedDevice
 	}
 
-	return r.flood(ingress, packet)
+	inPort := openflow.NewInPort()
+	inPort.SetValue(ingress.Number())
+	outPort := openflow.NewOutPort()
+	outPort.SetNormal()
+
+	return r.packetOut(inPort, packet, outPort)
+}
+
+// blockedPorts returns the numbers of the ports blocked by the spanning tree. It should be called without
+// the device lock because the finder looks up the ID of this device.
+func (r *Device) blockedPorts() map[uint32]bool {
+	blocked := make(map[uint32]bool)
+	for _, p := range r.Ports() {
+		if !r.session.finder.IsEnabledPort(p) {
+			blocked[p.Number()] = true
+		}
+	}
+
+	return blocked
 }
 
-// flood broadcasts the packet to all ports of this device, except the ingress port if ingress is not nil.
-func (r *Device) flood(ingress *Port, packet []byte) error {
+// flood broadcasts the packet to all ports of this device, except the ingress port if ingress is not nil
+// and the ports in blocked. The caller should hold the write lock.
+func (r *Device) flood(ingress *Port, packet []byte, blocked map[uint32]bool) error {
 	inPort := openflow.NewInPort()
 	if ingress != nil {
 		inPort.SetValue(ingress.Number())
@@ -508,15 +2195,132 @@ func (r *Device) flood(ingress *Port, packet []byte) error {
 		inPort.SetController()
 	}
 
+	// Large frames should not saturate slow links, so we enumerate the ports one by one
+	// and skip the slow ones instead of relying on the switch's FLOOD port.
+	if minSpeed := r.floodMinSpeed(); minSpeed > 0 && len(packet) > viper.GetInt("flood.large_frame") {
+		return r.floodPerPort(inPort, ingress, packet, minSpeed, blocked)
+	}
+	// The switch does not know the ports blocked by our spanning tree, so we also enumerate
+	// the ports if any of them is blocked. Otherwise, flooding will cause a broadcast storm.
+	if len(blocked) > 0 || floodMode() == FloodEnumerate {
+		return r.floodPerPort(inPort, ingress, packet, 0, blocked)
+	}
+
 	outPort := openflow.NewOutPort()
 	// FLOOD means all ports except the ingress one.
 	outPort.SetFlood()
 
+	return r.packetOut(inPort, packet, outPort)
+}
+
+// FloodMode is how a device floods a packet.
+type FloodMode int
+
+const (
+	// FloodSwitch sends the packet to the switch's FLOOD port, and lets the switch select the ports unless
+	// the controller has to skip some ports that the switch does not know, e.g., the ones blocked by our
+	// spanning tree.
+	FloodSwitch FloodMode = iota
+	// FloodEnumerate always sends the packet to the ports selected by the controller.
+	FloodEnumerate
+)
+
+// ParseFloodMode parses the name of a flood mode: switch or enumerate.
+func ParseFloodMode(name string) (FloodMode, error) {
+	switch strings.ToLower(name) {
+	case "", "switch":
+		return FloodSwitch, nil
+	case "enumerate":
+		return FloodEnumerate, nil
+	default:
+		return 0, fmt.Errorf("unknown flood mode: %v", name)
+	}
+}
+
+// floodMode returns the flood mode in the config file. It falls back to FloodSwitch if the mode is invalid,
+// e.g., the config file has been changed to have an invalid one after startup.
+func floodMode() FloodMode {
+	mode, err := ParseFloodMode(viper.GetString("flood.mode"))
+	if err != nil {
+		// The error has been already logged when the config file is loaded.
+		return FloodSwitch
+	}
+
+	return mode
+}
+
+// floodMinSpeed returns the minimum link speed (in Mbps) of the ports that large frames are flooded to.
+// Zero means there is no limit. The caller should hold the device lock.
+func (r *Device) floodMinSpeed() uint64 {
+	key := fmt.Sprintf("flood.devices.%v", r.features.DPID)
+	if viper.IsSet(key) {
+		return uint64(viper.GetInt(key))
+	}
+
+	return uint64(viper.GetInt("flood.min_speed"))
+}
+
+// floodPerPort sends the packet to the ports returned by floodPorts using a single PACKET_OUT that has an
+// output action for each port. The caller should hold the device lock.
+func (r *Device) floodPerPort(inPort openflow.InPort, ingress *Port, packet []byte, minSpeed uint64, blocked map[uint32]bool) error {
+	ports := r.floodPorts(ingress, len(packet), minSpeed, blocked)
+	if len(ports) == 0 {
+		return nil
+	}
+
+	outPorts := make([]openflow.OutPort, len(ports))
+	for i, num := range ports {
+		outPorts[i] = openflow.NewOutPort()
+		outPorts[i].SetValue(num)
+	}
+
+	return r.packetOut(inPort, packet, outPorts...)
+}
+
+// floodPorts returns the numbers of the ports of this device in ascending order, except the ingress one, the
+// ones that are down, excluded from flooding by OFPPC_NO_FLOOD, or blocked by the spanning tree, and the ones
+// whose link speed is lower than minSpeed. The ports whose speed is unknown are not skipped. length is the
+// size of the packet to be flooded, which is only used for logging. The caller should hold the device lock.
+func (r *Device) floodPorts(ingress *Port, length int, minSpeed uint64, blocked map[uint32]bool) []uint32 {
+	result := make([]uint32, 0, len(r.ports))
+	for num, port := range r.ports {
+		if ingress != nil && ingress.Number() == num {
+			continue
+		}
+		v := port.Value()
+		if v.IsPortDown() || v.IsLinkDown() || v.IsNoFlood() {
+			continue
+		}
+		if blocked[num] {
+			// Port.ID() cannot be used because it acquires the device lock again.
+			logger.Debugf("skip flooding to a port blocked by the spanning tree: %v:%v", r.id, num)
+			continue
+		}
+		if speed := v.Speed(); speed > 0 && speed < minSpeed {
+			logger.Debugf("skip flooding a large frame (%v bytes) to a slow port: %v:%v (speed=%vMbps)", length, r.id, num, speed)
+			continue
+		}
+		result = append(result, num)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+
+	return result
+}
+
+// packetOut sends the packet to outPorts in order using a single PACKET_OUT. The caller should hold the
+// device lock.
+func (r *Device) packetOut(inPort openflow.InPort, packet []byte, outPorts ...openflow.OutPort) error {
 	action, err := r.factory.NewAction()
 	if err != nil {
 		return err
 	}
-	action.SetOutPort(outPort)
+	for i, p := range outPorts {
+		if i == 0 {
+			action.SetOutPort(p)
+		} else {
+			action.AddOutPort(p)
+		}
+	}
 
 	out, err := r.factory.NewPacketOut()
 	if err != nil {
@@ -526,7 +2330,40 @@ func (r *Device) flood(ingress *Port, packet []byte) error {
 	out.SetAction(action)
 	out.SetData(packet)
 
-	return r.session.Write(out)
+	return r.write(out)
+}
+
+// Drain stops processing new PACKET_INs from the device, and then blocks until the device confirms that it
+// has processed all the messages we have sent using a barrier request, or ctx is done. The connection is not
+// closed, so the caller should cancel the session afterward.
+func (r *Device) Drain(ctx context.Context) error {
+	barrier, err := r.prepareDrain()
+	if err != nil {
+		return err
+	}
+
+	return r.session.transceiver.WriteSync(ctx, barrier)
+}
+
+func (r *Device) prepareDrain() (openflow.BarrierRequest, error) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return nil, ErrClosedDevice
+	}
+	r.draining = true
+
+	return r.factory.NewBarrierRequest()
+}
+
+func (r *Device) isDraining() bool {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.draining
 }
 
 func (r *Device) Close() {
@@ -0,0 +1,165 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+type DeviceEventType int
+
+const (
+	DeviceConnected DeviceEventType = iota
+	DeviceDisconnected
+)
+
+func (r DeviceEventType) String() string {
+	switch r {
+	case DeviceConnected:
+		return "DeviceConnected"
+	case DeviceDisconnected:
+		return "DeviceDisconnected"
+	default:
+		return "Unknown"
+	}
+}
+
+// DeviceEvent notifies that a device has been connected to or disconnected from the controller.
+type DeviceEvent struct {
+	Type DeviceEventType
+	DPID string
+	// Number of the auxiliary connections attached to the device at the time of the event.
+	AuxCount  int
+	Timestamp time.Time
+}
+
+// deviceEventFeed delivers every device event to all the subscribers. Each subscriber has its own unbounded
+// queue so that a slow subscriber blocks neither the publisher nor the other subscribers.
+type deviceEventFeed struct {
+	mutex       sync.Mutex
+	subscribers map[*deviceEventSubscriber]struct{}
+}
+
+func newDeviceEventFeed() *deviceEventFeed {
+	return &deviceEventFeed{
+		subscribers: make(map[*deviceEventSubscriber]struct{}),
+	}
+}
+
+func (r *deviceEventFeed) publish(t DeviceEventType, d *Device) {
+	event := DeviceEvent{
+		Type:      t,
+		DPID:      d.ID(),
+		AuxCount:  d.AuxChannels(),
+		Timestamp: time.Now(),
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for s := range r.subscribers {
+		s.push(event)
+	}
+}
+
+func (r *deviceEventFeed) subscribe() (<-chan DeviceEvent, func()) {
+	s := newDeviceEventSubscriber()
+
+	r.mutex.Lock()
+	r.subscribers[s] = struct{}{}
+	r.mutex.Unlock()
+	go s.run()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			r.mutex.Lock()
+			delete(r.subscribers, s)
+			r.mutex.Unlock()
+			s.close()
+		})
+	}
+
+	return s.events, cancel
+}
+
+type deviceEventSubscriber struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	queue  []DeviceEvent
+	closed bool
+	done   chan struct{}
+	events chan DeviceEvent
+}
+
+func newDeviceEventSubscriber() *deviceEventSubscriber {
+	v := &deviceEventSubscriber{
+		done:   make(chan struct{}),
+		events: make(chan DeviceEvent),
+	}
+	v.cond = sync.NewCond(&v.mutex)
+
+	return v
+}
+
+func (r *deviceEventSubscriber) push(e DeviceEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.queue = append(r.queue, e)
+	r.cond.Signal()
+}
+
+func (r *deviceEventSubscriber) close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.closed = true
+	close(r.done)
+	r.cond.Signal()
+}
+
+// run forwards the queued events to the events channel in order until the subscriber is closed.
+func (r *deviceEventSubscriber) run() {
+	defer close(r.events)
+
+	for {
+		r.mutex.Lock()
+		for len(r.queue) == 0 && !r.closed {
+			r.cond.Wait()
+		}
+		if r.closed {
+			r.mutex.Unlock()
+			return
+		}
+		e := r.queue[0]
+		r.queue = r.queue[1:]
+		r.mutex.Unlock()
+
+		select {
+		case r.events <- e:
+		case <-r.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+func receiveDeviceEvent(t *testing.T, events <-chan DeviceEvent) DeviceEvent {
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("event channel is closed")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timeout while waiting for a device event")
+	}
+
+	panic("not reached")
+}
+
+func TestDeviceEvents(t *testing.T) {
+	topo := newTestTopology()
+	// The second subscriber does not receive anything until the cycle completes, which should not block
+	// the topology nor the first subscriber.
+	events1, cancel1 := topo.feed.subscribe()
+	defer cancel1()
+	events2, cancel2 := topo.feed.subscribe()
+
+	start := time.Now()
+	d := &Device{id: "1"}
+	topo.DeviceAdded(d)
+	e := receiveDeviceEvent(t, events1)
+	if e.Type != DeviceConnected || e.DPID != "1" || e.AuxCount != 0 || e.Timestamp.Before(start) {
+		t.Fatalf("unexpected connect event: %+v", e)
+	}
+	topo.DeviceRemoved(d)
+	e = receiveDeviceEvent(t, events1)
+	if e.Type != DeviceDisconnected || e.DPID != "1" || e.Timestamp.Before(start) {
+		t.Fatalf("unexpected disconnect event: %+v", e)
+	}
+
+	for _, expected := range []DeviceEventType{DeviceConnected, DeviceDisconnected} {
+		if e := receiveDeviceEvent(t, events2); e.Type != expected || e.DPID != "1" {
+			t.Fatalf("unexpected event: expected=%v, got=%+v", expected, e)
+		}
+	}
+
+	// The canceled subscriber does not receive the events anymore.
+	cancel2()
+	topo.DeviceAdded(d)
+	if e := receiveDeviceEvent(t, events1); e.Type != DeviceConnected {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+	select {
+	case e, ok := <-events2:
+		if ok {
+			t.Fatalf("unexpected event after the cancellation: %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event channel is not closed after the cancellation")
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"sync"
+
+	"github.com/superkkt/viper"
+)
+
+// deviceLimiter bounds the number of the device connections that the controller serves at the same time so
+// that a connection flood cannot exhaust the memory. A slot is taken when a connection is accepted, and the
+// auxiliary connections of the connected devices give their slots back once they are identified.
+type deviceLimiter struct {
+	mutex   sync.Mutex
+	devices int
+	// Number of the devices refused so far because of the limit.
+	refused uint64
+}
+
+// acquire takes a slot for a new device and returns true, or returns false if there are already max devices.
+// Zero max means no limit.
+func (r *deviceLimiter) acquire(max int) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if max > 0 && r.devices >= max {
+		r.refused++
+		return false
+	}
+	r.devices++
+
+	return true
+}
+
+// release returns the slot taken by acquire.
+func (r *deviceLimiter) release() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.devices == 0 {
+		panic("releasing a device slot that has not been acquired")
+	}
+	r.devices--
+}
+
+func (r *deviceLimiter) counts() (devices int, refused uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.devices, r.refused
+}
+
+// admitConnection takes a slot of limiter for c and returns true, or closes c and returns false if there are
+// already the max number of connections. The slot should be released when c is closed.
+func admitConnection(limiter *deviceLimiter, c net.Conn) bool {
+	if limiter.acquire(maxDevices()) {
+		return true
+	}
+	// The OpenFlow version is not negotiated yet, so we cannot reply an ERROR.
+	logger.Errorf("refusing the connection from %v: already serving the max number of devices (%v)", c.RemoteAddr(), maxDevices())
+	c.Close()
+
+	return false
+}
+
+// maxDevices returns the max number of the devices connected at the same time. Zero means no limit.
+func maxDevices() int {
+	if v := viper.GetInt("default.max_devices"); v > 0 {
+		return v
+	}
+
+	return 0
+}
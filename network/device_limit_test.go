@@ -0,0 +1,123 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow/of13"
+
+	"github.com/superkkt/viper"
+)
+
+func TestDeviceLimit(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("default.max_devices", 2)
+
+	limiter := new(deviceLimiter)
+	var peers []net.Conn
+	for i := 0; i < 3; i++ {
+		c, peer := net.Pipe()
+		defer c.Close()
+		defer peer.Close()
+		peers = append(peers, peer)
+
+		// The third connection is beyond the limit.
+		if admitted := admitConnection(limiter, c); admitted != (i < 2) {
+			t.Fatalf("#%v: unexpected admission: %v", i, admitted)
+		}
+	}
+	// The refused connection has been closed.
+	if _, err := peers[2].Write([]byte{0}); err == nil {
+		t.Fatal("refused connection is not closed")
+	}
+	if devices, refused := limiter.counts(); devices != 2 || refused != 1 {
+		t.Fatalf("unexpected counts: devices=%v, refused=%v", devices, refused)
+	}
+
+	// A slot is available again after a connection is closed.
+	limiter.release()
+	if !limiter.acquire(maxDevices()) {
+		t.Fatal("failed to acquire the released slot")
+	}
+	if limiter.acquire(maxDevices()) {
+		t.Fatal("acquired a slot beyond the limit")
+	}
+	// Zero means no limit.
+	if !limiter.acquire(0) {
+		t.Fatal("failed to acquire a slot without the limit")
+	}
+}
+
+func TestDeviceLimitAuxChannel(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	network := NewFakeNetwork()
+	limiter := new(deviceLimiter)
+	newTestSession := func() *session {
+		if !limiter.acquire(0) {
+			t.Fatal("failed to acquire a slot")
+		}
+		s := &session{
+			negotiated: true,
+			watcher:    network.topology,
+			finder:     network,
+			listener:   nopControllerListener{},
+			tracker:    newDPIDTracker(),
+			intents:    newIntentStore(0),
+			writer:     new(messageRecorder),
+			limiter:    limiter,
+			admitted:   true,
+		}
+		s.device = newDevice(s)
+		s.device.setFactory(of13.NewFactory())
+		s.handler = newOF13Session(s.device)
+		return s
+	}
+
+	src := []struct {
+		dpid  uint64
+		auxID uint8
+	}{
+		{dpid: 1},
+		{dpid: 2},
+		// Auxiliary connection of a connected device gives its slot back.
+		{dpid: 1, auxID: 1},
+	}
+
+	for i, v := range src {
+		s := newTestSession()
+		w := s.writer.(*messageRecorder)
+		if err := s.OnFeaturesReply(of13.NewFactory(), w, newTestFeaturesReply(t, v.dpid, v.auxID)); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if s.admitted != (v.auxID == 0) {
+			t.Fatalf("#%v: unexpected admission: %v", i, s.admitted)
+		}
+	}
+	if devices, _ := limiter.counts(); devices != 2 {
+		t.Fatalf("unexpected number of the devices: %v", devices)
+	}
+}
@@ -0,0 +1,280 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+	"github.com/superkkt/cherry/protocol"
+
+	"github.com/superkkt/viper"
+)
+
+// dhcpClientTimeout is the time for which the port of a client is kept after its last DHCP request. The DHCP
+// exchange is usually completed within a few seconds.
+const dhcpClientTimeout = 60 * time.Second
+
+// ParseDHCPTrustedPorts parses the ports on which the DHCP servers or the relay agents are connected, in the
+// form of "DPID:port" where the DPID and the port number are decimal. It returns nil if ports is empty.
+func ParseDHCPTrustedPorts(ports []string) (map[string]bool, error) {
+	if len(ports) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]bool)
+	for _, v := range ports {
+		t := strings.Split(strings.TrimSpace(v), ":")
+		if len(t) != 2 {
+			return nil, fmt.Errorf("invalid DHCP trusted port: %v", v)
+		}
+		dpid, err := strconv.ParseUint(t[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DPID of DHCP trusted port: %v", v)
+		}
+		port, err := strconv.ParseUint(t[1], 10, 32)
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("invalid port number of DHCP trusted port: %v", v)
+		}
+		// Same with the form of Port.ID().
+		result[fmt.Sprintf("%v:%v", dpid, port)] = true
+	}
+
+	return result, nil
+}
+
+// dhcpTrustedPorts returns the ports from which the DHCP ACKs are accepted. Nil means all the ports.
+func dhcpTrustedPorts() map[string]bool {
+	ports, err := ParseDHCPTrustedPorts(viper.GetStringSlice("default.dhcp_trusted_ports"))
+	if err != nil {
+		// Should not happen because the config file is validated at startup.
+		logger.Errorf("invalid DHCP trusted ports: %v", err)
+		return nil
+	}
+
+	return ports
+}
+
+// DHCPBinding is an IP address assigned to a host by a DHCP server, which is learned by snooping the DHCP ACKs.
+type DHCPBinding struct {
+	IP  net.IP
+	MAC net.HardwareAddr
+	// Port is the location of the host, which is nil if it is unknown, e.g., the host has not sent its
+	// DHCP request through the controller or its port has been removed.
+	Port *Port
+	// Expiry is the time at which the lease expires. Zero means the infinite lease.
+	Expiry time.Time
+}
+
+type dhcpSnooping struct {
+	mutex sync.Mutex
+	clock clock.Clock
+	// Key is the ID of a port on which a DHCP server or a relay agent is connected. The DHCP ACKs received on
+	// the other ports are ignored because they may be spoofed by the hosts. Nil means all the ports.
+	trusted map[string]bool
+	// Key is the MAC address of a client.
+	clients map[string]dhcpClient
+	// Time when the stale clients have been purged last.
+	lastPurge time.Time
+	// Key is the assigned IP address.
+	bindings map[string]DHCPBinding
+}
+
+// dhcpClient is the port on which the DHCP request of a client has been received.
+type dhcpClient struct {
+	port      *Port
+	timestamp time.Time
+}
+
+func newDHCPSnooping(trusted map[string]bool) *dhcpSnooping {
+	return &dhcpSnooping{
+		clock:    clock.New(),
+		trusted:  trusted,
+		clients:  make(map[string]dhcpClient),
+		bindings: make(map[string]DHCPBinding),
+	}
+}
+
+// getDHCP returns the DHCP message carried by eth, or nil if eth is not a valid DHCP packet over UDP/IPv4.
+func getDHCP(eth *protocol.Ethernet) *protocol.DHCP {
+	if eth.Type != 0x0800 {
+		return nil
+	}
+	ip := new(protocol.IPv4)
+	if err := ip.UnmarshalBinary(eth.Payload); err != nil || ip.Protocol != 0x11 {
+		return nil
+	}
+	udp := new(protocol.UDP)
+	if err := udp.UnmarshalBinary(ip.Payload); err != nil {
+		return nil
+	}
+	// Between the client (68) and the server (67), or between the relay agent (67) and the server.
+	if !isDHCPPort(udp.SrcPort) || !isDHCPPort(udp.DstPort) || (udp.SrcPort == 68 && udp.DstPort == 68) {
+		return nil
+	}
+	dhcp := new(protocol.DHCP)
+	if err := dhcp.UnmarshalBinary(udp.Payload); err != nil {
+		return nil
+	}
+
+	return dhcp
+}
+
+func isDHCPPort(port uint16) bool {
+	return port == 67 || port == 68
+}
+
+// snoop records the binding assigned by a DHCP ACK. The location of the client is learned from its own DHCP
+// request because the ACK is received on the server side, or on the relay agent side if it is relayed.
+func (r *dhcpSnooping) snoop(ingress *Port, eth *protocol.Ethernet) {
+	dhcp := getDHCP(eth)
+	if dhcp == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	switch dhcp.MessageType() {
+	case protocol.DHCPMessageTypeDiscover, protocol.DHCPMessageTypeRequest:
+		// The request forwarded by a relay agent is not received on the client's port.
+		if !isUnspecifiedIP(dhcp.GIAddr) || eth.SrcMAC.String() != dhcp.CHAddr.String() {
+			return
+		}
+		now := r.clock.Now()
+		r.purgeClients(now)
+		r.clients[dhcp.CHAddr.String()] = dhcpClient{port: ingress, timestamp: now}
+	case protocol.DHCPMessageTypeACK:
+		// DHCPACK for DHCPINFORM does not assign any address.
+		if dhcp.Op != protocol.DHCPOpcodeReply || isUnspecifiedIP(dhcp.YIAddr) {
+			return
+		}
+		if r.trusted != nil && !r.trusted[ingress.ID()] {
+			logger.Warningf("ignoring DHCPACK received on the untrusted port: port=%v, ip=%v, mac=%v", ingress.ID(), dhcp.YIAddr, dhcp.CHAddr)
+			return
+		}
+		binding := DHCPBinding{
+			IP:  dhcp.YIAddr,
+			MAC: dhcp.CHAddr,
+		}
+		if c, ok := r.clients[dhcp.CHAddr.String()]; ok {
+			if r.clock.Since(c.timestamp) < dhcpClientTimeout {
+				binding.Port = c.port
+			}
+			// The exchange has been completed.
+			delete(r.clients, dhcp.CHAddr.String())
+		}
+		if lease, ok := dhcp.LeaseTime(); ok && lease != protocol.DHCPInfiniteLease {
+			binding.Expiry = r.clock.Now().Add(lease)
+		}
+		r.bindings[dhcp.YIAddr.String()] = binding
+		logger.Debugf("DHCP binding is learned: ip=%v, mac=%v, relay=%v", dhcp.YIAddr, dhcp.CHAddr, dhcp.GIAddr)
+	case protocol.DHCPMessageTypeRelease:
+		if b, ok := r.bindings[dhcp.CIAddr.String()]; ok && b.MAC.String() == dhcp.CHAddr.String() {
+			delete(r.bindings, dhcp.CIAddr.String())
+		}
+	}
+}
+
+// purgeClients removes the clients whose DHCP exchanges have not been completed within dhcpClientTimeout. It
+// scans the clients at most once per dhcpClientTimeout. XXX: Caller should lock the mutex.
+func (r *dhcpSnooping) purgeClients(now time.Time) {
+	if now.Sub(r.lastPurge) < dhcpClientTimeout {
+		return
+	}
+	r.lastPurge = now
+	for k, c := range r.clients {
+		if now.Sub(c.timestamp) >= dhcpClientTimeout {
+			delete(r.clients, k)
+		}
+	}
+}
+
+func isUnspecifiedIP(ip net.IP) bool {
+	return ip == nil || ip.IsUnspecified()
+}
+
+// XXX: Caller should lock the mutex.
+func (r *dhcpSnooping) isExpired(b DHCPBinding) bool {
+	return !b.Expiry.IsZero() && r.clock.Now().After(b.Expiry)
+}
+
+func (r *dhcpSnooping) binding(ip net.IP) (DHCPBinding, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	b, ok := r.bindings[ip.String()]
+	if !ok {
+		return DHCPBinding{}, false
+	}
+	if r.isExpired(b) {
+		delete(r.bindings, ip.String())
+		return DHCPBinding{}, false
+	}
+
+	return b, true
+}
+
+// all returns the unexpired bindings sorted by the IP address.
+func (r *dhcpSnooping) all() []DHCPBinding {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := make([]DHCPBinding, 0, len(r.bindings))
+	for k, b := range r.bindings {
+		if r.isExpired(b) {
+			delete(r.bindings, k)
+			continue
+		}
+		result = append(result, b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].IP.To16(), result[j].IP.To16()) < 0
+	})
+
+	return result
+}
+
+// forget removes the locations on the ports that match f, but keeps the IP to MAC bindings.
+func (r *dhcpSnooping) forget(f func(*Port) bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for k, c := range r.clients {
+		if f(c.port) {
+			delete(r.clients, k)
+		}
+	}
+	for k, b := range r.bindings {
+		if b.Port != nil && f(b.Port) {
+			b.Port = nil
+			r.bindings[k] = b
+		}
+	}
+}
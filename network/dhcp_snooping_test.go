@@ -0,0 +1,314 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+	"github.com/superkkt/cherry/protocol"
+)
+
+func newDHCPFrame(t *testing.T, srcMAC net.HardwareAddr, srcPort, dstPort uint16, dhcp *protocol.DHCP) *protocol.Ethernet {
+	payload, err := dhcp.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal a DHCP packet: %v", err)
+	}
+	udp := &protocol.UDP{SrcPort: srcPort, DstPort: dstPort, Payload: payload}
+	udp.SetPseudoHeader(net.IPv4zero, net.IPv4bcast)
+	datagram, err := udp.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal a UDP packet: %v", err)
+	}
+	packet, err := protocol.NewIPv4(net.IPv4zero, net.IPv4bcast, 0x11, datagram).MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal an IPv4 packet: %v", err)
+	}
+
+	return &protocol.Ethernet{
+		SrcMAC:  srcMAC,
+		DstMAC:  net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		Type:    0x0800,
+		Payload: packet,
+	}
+}
+
+func newDHCPMessage(op protocol.DHCPOpcode, t protocol.DHCPMessageType, client net.HardwareAddr, ciaddr, yiaddr, giaddr net.IP, lease uint32) *protocol.DHCP {
+	v := &protocol.DHCP{
+		Op:      op,
+		XID:     0x3d1e,
+		CIAddr:  ciaddr,
+		YIAddr:  yiaddr,
+		GIAddr:  giaddr,
+		CHAddr:  client,
+		Options: []protocol.DHCPOption{{Code: protocol.DHCPOptionMessageType, Value: []byte{byte(t)}}},
+	}
+	if lease > 0 {
+		v.Options = append(v.Options, protocol.DHCPOption{Code: protocol.DHCPOptionLeaseTime, Value: []byte{byte(lease >> 24), byte(lease >> 16), byte(lease >> 8), byte(lease)}})
+	}
+
+	return v
+}
+
+func TestDHCPSnooping(t *testing.T) {
+	d1 := &Device{id: "1", ports: make(map[uint32]*Port)}
+	for i := uint32(1); i <= 3; i++ {
+		d1.ports[i] = newTestPort(d1, i, 0)
+	}
+	topo := newTestTopology(d1)
+	clk := clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	topo.dhcp.clock = clk
+
+	client1 := net.HardwareAddr{0x00, 0x0b, 0x82, 0x01, 0xfc, 0x42}
+	client2 := net.HardwareAddr{0x00, 0x0e, 0x86, 0x11, 0xc0, 0x75}
+	server := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	relay := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x06}
+	ip1, ip2 := net.IPv4(192, 168, 0, 10), net.IPv4(10, 10, 8, 235)
+	relayIP := net.IPv4(10, 10, 8, 240)
+
+	src := []struct {
+		Ingress  uint32
+		Frame    *protocol.Ethernet
+		Advance  time.Duration
+		IP       net.IP
+		Found    bool
+		MAC      net.HardwareAddr
+		Port     uint32 // Zero means the unknown location.
+		Bindings int
+	}{
+		// DHCPACK without the client's request: the location is unknown.
+		{
+			Ingress:  2,
+			Frame:    newDHCPFrame(t, server, 67, 68, newDHCPMessage(protocol.DHCPOpcodeReply, protocol.DHCPMessageTypeACK, client1, nil, ip1, nil, 0)),
+			IP:       ip1,
+			Found:    true,
+			MAC:      client1,
+			Bindings: 1,
+		},
+		// DHCPREQUEST from the client, and then DHCPACK from the server with the 60 seconds lease.
+		{
+			Ingress:  1,
+			Frame:    newDHCPFrame(t, client1, 68, 67, newDHCPMessage(protocol.DHCPOpcodeRequest, protocol.DHCPMessageTypeRequest, client1, nil, nil, nil, 0)),
+			IP:       ip1,
+			Found:    true,
+			MAC:      client1,
+			Bindings: 1,
+		},
+		{
+			Ingress:  2,
+			Frame:    newDHCPFrame(t, server, 67, 68, newDHCPMessage(protocol.DHCPOpcodeReply, protocol.DHCPMessageTypeACK, client1, nil, ip1, nil, 60)),
+			IP:       ip1,
+			Found:    true,
+			MAC:      client1,
+			Port:     1,
+			Bindings: 1,
+		},
+		// DHCPREQUEST relayed by the relay agent does not locate the client.
+		{
+			Ingress:  3,
+			Frame:    newDHCPFrame(t, relay, 67, 67, newDHCPMessage(protocol.DHCPOpcodeRequest, protocol.DHCPMessageTypeRequest, client2, nil, nil, relayIP, 0)),
+			IP:       ip2,
+			Found:    false,
+			Bindings: 1,
+		},
+		// DHCPACK toward the relay agent.
+		{
+			Ingress:  2,
+			Frame:    newDHCPFrame(t, server, 67, 67, newDHCPMessage(protocol.DHCPOpcodeReply, protocol.DHCPMessageTypeACK, client2, nil, ip2, relayIP, 0xFFFFFFFF)),
+			IP:       ip2,
+			Found:    true,
+			MAC:      client2,
+			Bindings: 2,
+		},
+		// DHCPNAK does not assign any address.
+		{
+			Ingress:  2,
+			Frame:    newDHCPFrame(t, server, 67, 68, newDHCPMessage(protocol.DHCPOpcodeReply, protocol.DHCPMessageTypeNAK, client2, nil, nil, nil, 0)),
+			IP:       ip2,
+			Found:    true,
+			MAC:      client2,
+			Bindings: 2,
+		},
+		// Not a DHCP packet.
+		{
+			Ingress:  1,
+			Frame:    newDHCPFrame(t, client1, 68, 53, newDHCPMessage(protocol.DHCPOpcodeReply, protocol.DHCPMessageTypeACK, client1, nil, ip2, nil, 0)),
+			IP:       ip2,
+			Found:    true,
+			MAC:      client2,
+			Bindings: 2,
+		},
+		// The lease of ip1 expires, but the infinite lease of ip2 does not.
+		{
+			Advance:  61 * time.Second,
+			IP:       ip1,
+			Found:    false,
+			Bindings: 1,
+		},
+		// DHCPRELEASE from the client.
+		{
+			Ingress:  1,
+			Frame:    newDHCPFrame(t, client2, 68, 67, newDHCPMessage(protocol.DHCPOpcodeRequest, protocol.DHCPMessageTypeRelease, client2, ip2, nil, nil, 0)),
+			IP:       ip2,
+			Found:    false,
+			Bindings: 0,
+		},
+	}
+
+	for i, v := range src {
+		clk.Advance(v.Advance)
+		if v.Frame != nil {
+			topo.PacketReceived(d1.Port(v.Ingress), v.Frame)
+		}
+
+		b, ok := topo.DHCPBinding(v.IP)
+		if ok != v.Found {
+			t.Fatalf("#%v: unexpected binding of %v: expected=%v, actual=%v", i, v.IP, v.Found, ok)
+		}
+		if ok && b.MAC.String() != v.MAC.String() {
+			t.Fatalf("#%v: unexpected MAC address: expected=%v, actual=%v", i, v.MAC, b.MAC)
+		}
+		if ok && ((v.Port == 0 && b.Port != nil) || (v.Port != 0 && b.Port != d1.Port(v.Port))) {
+			t.Fatalf("#%v: unexpected binding port: expected=%v, actual=%v", i, v.Port, b.Port)
+		}
+		if n := len(topo.DHCPBindings()); n != v.Bindings {
+			t.Fatalf("#%v: unexpected number of bindings: expected=%v, actual=%v", i, v.Bindings, n)
+		}
+	}
+}
+
+func TestDHCPSnoopingPortRemoved(t *testing.T) {
+	d1 := &Device{id: "1", ports: make(map[uint32]*Port)}
+	for i := uint32(1); i <= 2; i++ {
+		d1.ports[i] = newTestPort(d1, i, 0)
+	}
+	topo := newTestTopology(d1)
+
+	client := net.HardwareAddr{0x00, 0x0b, 0x82, 0x01, 0xfc, 0x42}
+	server := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	ip := net.IPv4(192, 168, 0, 10)
+	topo.PacketReceived(d1.Port(1), newDHCPFrame(t, client, 68, 67, newDHCPMessage(protocol.DHCPOpcodeRequest, protocol.DHCPMessageTypeDiscover, client, nil, nil, nil, 0)))
+	topo.PacketReceived(d1.Port(2), newDHCPFrame(t, server, 67, 68, newDHCPMessage(protocol.DHCPOpcodeReply, protocol.DHCPMessageTypeACK, client, nil, ip, nil, 3600)))
+	if b, ok := topo.DHCPBinding(ip); !ok || b.Port != d1.Port(1) {
+		t.Fatalf("unexpected binding: %+v (ok=%v)", b, ok)
+	}
+
+	// The IP to MAC binding survives, but the location is forgotten.
+	topo.PortRemoved(d1.Port(1))
+	b, ok := topo.DHCPBinding(ip)
+	if !ok || b.Port != nil || b.MAC.String() != client.String() {
+		t.Fatalf("unexpected binding after the port removal: %+v (ok=%v)", b, ok)
+	}
+}
+
+func TestDHCPTrustedPorts(t *testing.T) {
+	src := []struct {
+		Ports    []string
+		Expected []string
+		Valid    bool
+	}{
+		{Ports: nil, Expected: nil, Valid: true},
+		{Ports: []string{"1:2", " 1234567890:48 "}, Expected: []string{"1:2", "1234567890:48"}, Valid: true},
+		{Ports: []string{"1"}, Valid: false},
+		{Ports: []string{"a:2"}, Valid: false},
+		{Ports: []string{"1:0"}, Valid: false},
+		{Ports: []string{"1:2:3"}, Valid: false},
+	}
+
+	for i, v := range src {
+		ports, err := ParseDHCPTrustedPorts(v.Ports)
+		if (err == nil) != v.Valid {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if !v.Valid {
+			continue
+		}
+		if len(ports) != len(v.Expected) {
+			t.Fatalf("#%v: unexpected ports: expected=%v, got=%v", i, v.Expected, ports)
+		}
+		for _, p := range v.Expected {
+			if !ports[p] {
+				t.Fatalf("#%v: missing port %v", i, p)
+			}
+		}
+	}
+
+	d1 := &Device{id: "1", ports: make(map[uint32]*Port)}
+	for i := uint32(1); i <= 3; i++ {
+		d1.ports[i] = newTestPort(d1, i, 0)
+	}
+	topo := newTestTopology(d1)
+	topo.dhcp.trusted = map[string]bool{d1.Port(2).ID(): true}
+
+	client := net.HardwareAddr{0x00, 0x0b, 0x82, 0x01, 0xfc, 0x42}
+	rogue := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x07}
+	server := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	ip := net.IPv4(192, 168, 0, 10)
+	topo.PacketReceived(d1.Port(1), newDHCPFrame(t, client, 68, 67, newDHCPMessage(protocol.DHCPOpcodeRequest, protocol.DHCPMessageTypeRequest, client, nil, nil, nil, 0)))
+	// Spoofed DHCPACK from a host.
+	topo.PacketReceived(d1.Port(3), newDHCPFrame(t, rogue, 67, 68, newDHCPMessage(protocol.DHCPOpcodeReply, protocol.DHCPMessageTypeACK, client, nil, ip, nil, 3600)))
+	if b, ok := topo.DHCPBinding(ip); ok {
+		t.Fatalf("unexpected binding from the untrusted port: %+v", b)
+	}
+	topo.PacketReceived(d1.Port(2), newDHCPFrame(t, server, 67, 68, newDHCPMessage(protocol.DHCPOpcodeReply, protocol.DHCPMessageTypeACK, client, nil, ip, nil, 3600)))
+	if b, ok := topo.DHCPBinding(ip); !ok || b.Port != d1.Port(1) {
+		t.Fatalf("unexpected binding: %+v (ok=%v)", b, ok)
+	}
+}
+
+func TestDHCPClientExpiry(t *testing.T) {
+	d1 := &Device{id: "1", ports: make(map[uint32]*Port)}
+	for i := uint32(1); i <= 2; i++ {
+		d1.ports[i] = newTestPort(d1, i, 0)
+	}
+	topo := newTestTopology(d1)
+	clk := clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	topo.dhcp.clock = clk
+
+	server := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	// Clients that never receive their DHCPACKs.
+	for i := 0; i < 10; i++ {
+		client := net.HardwareAddr{0x00, 0x0b, 0x82, 0x01, 0xfc, byte(i)}
+		topo.PacketReceived(d1.Port(1), newDHCPFrame(t, client, 68, 67, newDHCPMessage(protocol.DHCPOpcodeRequest, protocol.DHCPMessageTypeDiscover, client, nil, nil, nil, 0)))
+	}
+	if n := len(topo.dhcp.clients); n != 10 {
+		t.Fatalf("unexpected number of clients: %v", n)
+	}
+
+	// The stale client does not locate the binding.
+	clk.Advance(dhcpClientTimeout)
+	client := net.HardwareAddr{0x00, 0x0b, 0x82, 0x01, 0xfc, 0x00}
+	ip := net.IPv4(192, 168, 0, 10)
+	topo.PacketReceived(d1.Port(2), newDHCPFrame(t, server, 67, 68, newDHCPMessage(protocol.DHCPOpcodeReply, protocol.DHCPMessageTypeACK, client, nil, ip, nil, 3600)))
+	if b, ok := topo.DHCPBinding(ip); !ok || b.Port != nil {
+		t.Fatalf("unexpected binding: %+v (ok=%v)", b, ok)
+	}
+
+	// The next request purges the stale clients.
+	other := net.HardwareAddr{0x00, 0x0e, 0x86, 0x11, 0xc0, 0x75}
+	topo.PacketReceived(d1.Port(1), newDHCPFrame(t, other, 68, 67, newDHCPMessage(protocol.DHCPOpcodeRequest, protocol.DHCPMessageTypeDiscover, other, nil, nil, nil, 0)))
+	if n := len(topo.dhcp.clients); n != 1 {
+		t.Fatalf("unexpected number of clients after the purge: %v", n)
+	}
+}
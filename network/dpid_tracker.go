@@ -0,0 +1,111 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/superkkt/viper"
+)
+
+// dpidTracker remembers the last DPID reported by each connection source to detect a changed DPID
+// across reconnects, which is usually caused by a misconfiguration or a hardware swap on the same cable.
+// Silently accepting the changed DPID can corrupt the topology and the node locations.
+type dpidTracker struct {
+	mutex sync.Mutex
+	// Key is the source of a connection that is returned by trackerKey.
+	dpids map[string]uint64
+}
+
+func newDPIDTracker() *dpidTracker {
+	return &dpidTracker{
+		dpids: make(map[string]uint64),
+	}
+}
+
+// sourceIP returns the IP address of addr without its port number that changes on every reconnect.
+func sourceIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+
+	return host
+}
+
+// trackerKey returns the key of the connection source whose address is addr in the DPID tracker. The site
+// name is used if the resolver has named addr because several devices behind a NAT or a management
+// gateway can share a source IP address. Otherwise, it is the source IP address since the port number
+// changes on every reconnect.
+func trackerKey(site string, addr net.Addr) string {
+	if len(site) > 0 && site != addr.String() {
+		return site
+	}
+
+	return sourceIP(addr)
+}
+
+// update records dpid as the last one of source, and returns the previous DPID if it is different from dpid.
+func (r *dpidTracker) update(source string, dpid uint64) (prev uint64, changed bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	prev, ok := r.dpids[source]
+	r.dpids[source] = dpid
+
+	return prev, ok && prev != dpid
+}
+
+// ParseDPIDAllowList parses dpids, which are the decimal DPIDs of the devices allowed to connect. Nil
+// means all the DPIDs are allowed.
+func ParseDPIDAllowList(dpids []string) (map[uint64]bool, error) {
+	if len(dpids) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[uint64]bool)
+	for _, v := range dpids {
+		dpid, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DPID: %v", v)
+		}
+		result[dpid] = true
+	}
+
+	return result, nil
+}
+
+// isAllowedDPID returns whether the device whose DPID is dpid is allowed to connect.
+func isAllowedDPID(dpid uint64) bool {
+	allowed, err := ParseDPIDAllowList(viper.GetStringSlice("default.allowed_dpids"))
+	if err != nil {
+		// Should not happen because the config file is validated at startup.
+		logger.Errorf("invalid allowed DPIDs: %v", err)
+		return true
+	}
+
+	return allowed == nil || allowed[dpid]
+}
@@ -0,0 +1,154 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow/of13"
+
+	"github.com/superkkt/viper"
+)
+
+func TestDPIDTracker(t *testing.T) {
+	src := []struct {
+		Source  string
+		DPID    uint64
+		Prev    uint64
+		Changed bool
+	}{
+		// First connection.
+		{Source: "10.0.0.1", DPID: 1, Changed: false},
+		// Reconnect with the same DPID.
+		{Source: "10.0.0.1", DPID: 1, Changed: false},
+		// Another device.
+		{Source: "10.0.0.2", DPID: 2, Changed: false},
+		// Reconnect with a different DPID.
+		{Source: "10.0.0.1", DPID: 3, Prev: 1, Changed: true},
+		// The changed DPID becomes the last-seen one.
+		{Source: "10.0.0.1", DPID: 3, Changed: false},
+		{Source: "10.0.0.2", DPID: 1, Prev: 2, Changed: true},
+	}
+
+	tracker := newDPIDTracker()
+	for i, v := range src {
+		prev, changed := tracker.update(v.Source, v.DPID)
+		if changed != v.Changed {
+			t.Fatalf("#%v: unexpected result: expected=%v, got=%v", i, v.Changed, changed)
+		}
+		if changed && prev != v.Prev {
+			t.Fatalf("#%v: unexpected previous DPID: expected=%v, got=%v", i, v.Prev, prev)
+		}
+	}
+}
+
+func TestSourceIP(t *testing.T) {
+	src := []struct {
+		Addr     net.Addr
+		Expected string
+	}{
+		{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 51234}, Expected: "10.0.0.1"},
+		{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 40000}, Expected: "10.0.0.1"},
+		{Addr: &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 6633}, Expected: "2001:db8::1"},
+	}
+
+	for _, v := range src {
+		if ip := sourceIP(v.Addr); ip != v.Expected {
+			t.Fatalf("unexpected source IP: expected=%v, got=%v", v.Expected, ip)
+		}
+	}
+}
+
+func TestTrackerKey(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 51234}
+	src := []struct {
+		Site     string
+		Expected string
+	}{
+		// Not resolved.
+		{Site: "", Expected: "10.0.0.1"},
+		{Site: addr.String(), Expected: "10.0.0.1"},
+		// Resolved.
+		{Site: "rack-1", Expected: "rack-1"},
+	}
+
+	for i, v := range src {
+		if key := trackerKey(v.Site, addr); key != v.Expected {
+			t.Fatalf("#%v: unexpected key: expected=%v, got=%v", i, v.Expected, key)
+		}
+	}
+}
+
+func TestDPIDAllowList(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	if _, err := ParseDPIDAllowList([]string{"1", "abc"}); err == nil {
+		t.Fatal("expected an error for the invalid DPID")
+	}
+	if list, err := ParseDPIDAllowList(nil); err != nil || list != nil {
+		t.Fatalf("unexpected result of the empty list: list=%v, err=%v", list, err)
+	}
+
+	network := NewFakeNetwork()
+	newTestSession := func() *session {
+		s := &session{
+			negotiated: true,
+			watcher:    network.topology,
+			finder:     network,
+			listener:   nopControllerListener{},
+			tracker:    newDPIDTracker(),
+			intents:    newIntentStore(0),
+			writer:     new(messageRecorder),
+		}
+		s.device = newDevice(s)
+		s.device.setFactory(of13.NewFactory())
+		s.handler = newOF13Session(s.device)
+		return s
+	}
+
+	src := []struct {
+		allowed []string
+		dpid    uint64
+		err     error
+	}{
+		// Empty list allows all the DPIDs.
+		{allowed: nil, dpid: 1},
+		{allowed: []string{"2", " 3"}, dpid: 2},
+		{allowed: []string{"2", " 3"}, dpid: 3},
+		{allowed: []string{"2", " 3"}, dpid: 4, err: errDPIDNotAllowed},
+	}
+
+	for i, v := range src {
+		viper.Set("default.allowed_dpids", v.allowed)
+		s := newTestSession()
+		w := s.writer.(*messageRecorder)
+		err := s.OnFeaturesReply(of13.NewFactory(), w, newTestFeaturesReply(t, v.dpid, 0))
+		if err != v.err {
+			t.Fatalf("#%v: unexpected error: expected=%v, got=%v", i, v.err, err)
+		}
+		if s.added != (v.err == nil) {
+			t.Fatalf("#%v: unexpected addition: %v", i, s.added)
+		}
+	}
+}
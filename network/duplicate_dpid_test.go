@@ -0,0 +1,168 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+	"github.com/superkkt/cherry/protocol"
+)
+
+type nopControllerListener struct{}
+
+func (r nopControllerListener) OnPacketIn(Finder, *Port, *protocol.Ethernet, PacketInInfo) error {
+	return nil
+}
+func (r nopControllerListener) OnPortUp(Finder, *Port) error                              { return nil }
+func (r nopControllerListener) OnPortDown(Finder, *Port) error                            { return nil }
+func (r nopControllerListener) OnDeviceUp(Finder, *Device) error                          { return nil }
+func (r nopControllerListener) OnDeviceDown(Finder, *Device) error                        { return nil }
+func (r nopControllerListener) OnFlowRemoved(Finder, *Device, openflow.FlowRemoved) error { return nil }
+
+func newTestFeaturesReply(t *testing.T, dpid uint64, auxID uint8) openflow.FeaturesReply {
+	packet := make([]byte, 32)
+	packet[0] = openflow.OF13_VERSION
+	packet[1] = of13.OFPT_FEATURES_REPLY
+	binary.BigEndian.PutUint16(packet[2:4], 32)
+	binary.BigEndian.PutUint64(packet[8:16], dpid)
+	packet[20] = 1 // Number of tables
+	packet[21] = auxID
+
+	reply, err := of13.NewFactory().NewFeaturesReply()
+	if err != nil {
+		t.Fatalf("failed to create FEATURES_REPLY: %v", err)
+	}
+	if err := reply.UnmarshalBinary(packet); err != nil {
+		t.Fatalf("failed to decode FEATURES_REPLY: %v", err)
+	}
+
+	return reply
+}
+
+func TestDuplicateDPID(t *testing.T) {
+	network := NewFakeNetwork()
+	newTestSession := func(remote string) *session {
+		s := &session{
+			negotiated: true,
+			watcher:    network.topology,
+			finder:     network,
+			listener:   nopControllerListener{},
+			tracker:    newDPIDTracker(),
+			intents:    newIntentStore(0),
+			remote:     remote,
+			writer:     new(messageRecorder),
+		}
+		s.device = newDevice(s)
+		s.device.setFactory(of13.NewFactory())
+		s.handler = newOF13Session(s.device)
+		return s
+	}
+
+	src := []struct {
+		remote string
+		auxID  uint8
+		err    error
+	}{
+		{remote: "10.0.0.1:50001", auxID: 0},
+		// Another switch that has the same DPID.
+		{remote: "10.0.0.2:50001", auxID: 0, err: errDuplicateDPID},
+		// Auxiliary connection of the first switch.
+		{remote: "10.0.0.1:50002", auxID: 1},
+	}
+
+	var main *Device
+	for i, v := range src {
+		s := newTestSession(v.remote)
+		w := s.writer.(*messageRecorder)
+		err := s.OnFeaturesReply(of13.NewFactory(), w, newTestFeaturesReply(t, 1, v.auxID))
+		if err != v.err {
+			t.Fatalf("#%v: unexpected error: expected=%v, got=%v", i, v.err, err)
+		}
+		if i == 0 {
+			main = s.device
+		}
+		if d := network.Device("1"); d != main {
+			t.Fatalf("#%v: registered device has been replaced: %v", i, d)
+		}
+
+		var rejected bool
+		for _, msg := range w.get() {
+			m, ok := msg.(*openflow.Message)
+			if !ok || m.Type() != of13.OFPT_ERROR {
+				continue
+			}
+			payload := m.Payload()
+			if binary.BigEndian.Uint16(payload[0:2]) != of13.OFPET_HELLO_FAILED || binary.BigEndian.Uint16(payload[2:4]) != of13.OFPHFC_EPERM {
+				t.Fatalf("#%v: unexpected ERROR: %v", i, payload)
+			}
+			rejected = true
+		}
+		if rejected != (v.err != nil) {
+			t.Fatalf("#%v: unexpected ERROR reply: expected=%v, got=%v", i, v.err != nil, rejected)
+		}
+		if v.auxID != 0 && s.main != main {
+			t.Fatalf("#%v: auxiliary connection is not attached to the main device", i)
+		}
+	}
+	if main.AuxChannels() != 1 {
+		t.Fatalf("unexpected number of auxiliary channels: %v", main.AuxChannels())
+	}
+}
+
+type deviceUpRecorder struct {
+	nopControllerListener
+	numTables uint8
+}
+
+func (r *deviceUpRecorder) OnDeviceUp(finder Finder, device *Device) error {
+	r.numTables = device.NumTables()
+	return nil
+}
+
+func TestFeaturesBeforeDeviceUp(t *testing.T) {
+	network := NewFakeNetwork()
+	listener := new(deviceUpRecorder)
+	s := &session{
+		negotiated: true,
+		watcher:    network.topology,
+		finder:     network,
+		listener:   listener,
+		tracker:    newDPIDTracker(),
+		intents:    newIntentStore(0),
+		remote:     "10.0.0.1:50001",
+		writer:     new(messageRecorder),
+	}
+	s.device = newDevice(s)
+	s.device.setFactory(of13.NewFactory())
+	s.handler = newOF13Session(s.device)
+
+	if err := s.OnFeaturesReply(of13.NewFactory(), s.writer.(*messageRecorder), newTestFeaturesReply(t, 1, 0)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The device features should be visible to the apps in the device up event.
+	if listener.numTables != 1 {
+		t.Fatalf("unexpected number of tables in OnDeviceUp: expected=1, got=%v", listener.numTables)
+	}
+}
@@ -0,0 +1,299 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/superkkt/cherry/graph"
+	"github.com/superkkt/cherry/openflow"
+)
+
+// FakeNetwork is an in-memory network of fake switches for the unit tests of the applications. It implements
+// Finder using the same topology calculation as the controller, and the host locations are registered by
+// SetLocation instead of the database.
+type FakeNetwork struct {
+	*topology
+	db *fakeLocationDB
+}
+
+// NewFakeNetwork returns an empty fake network.
+func NewFakeNetwork() *FakeNetwork {
+	db := &fakeLocationDB{locations: make(map[string]*Location)}
+	return &FakeNetwork{
+		topology: &topology{
+			devices: make(map[string]*Device),
+			graph:   graph.New(),
+			db:      db,
+			aging:   newNodeAging(0),
+			dhcp:    newDHCPSnooping(nil),
+			flap:    newMACFlapDetector(0, 0, 0),
+			feed:    newDeviceEventFeed(),
+		},
+		db: db,
+	}
+}
+
+// AddSwitch adds a new fake switch whose ID is id and ports are numbered by ports. All the ports are up.
+func (r *FakeNetwork) AddSwitch(id string, f openflow.Factory, ports ...uint32) *FakeSwitch {
+	if len(id) == 0 {
+		panic("empty device ID")
+	}
+	if f == nil {
+		panic("Factory is nil")
+	}
+
+	s := newFakeSwitch(id, f, r)
+	for _, num := range ports {
+		s.AddPort(num)
+	}
+	r.DeviceAdded(s.Device)
+
+	return s
+}
+
+// Link connects the two ports of the different switches.
+func (r *FakeNetwork) Link(p1, p2 *Port) {
+	r.DeviceLinked([2]*Port{p1, p2})
+}
+
+// SetLocation registers port as the location of the host whose MAC address is mac.
+func (r *FakeNetwork) SetLocation(mac net.HardwareAddr, port *Port) {
+	r.db.set(mac, &Location{DPID: port.Device().ID(), Port: port.Number(), Timestamp: time.Now()})
+}
+
+// Register registers the host whose MAC address is mac without its location, i.e., the host has not been
+// discovered yet.
+func (r *FakeNetwork) Register(mac net.HardwareAddr) {
+	r.db.set(mac, nil)
+}
+
+type fakeLocationDB struct {
+	mutex sync.Mutex
+	// Key is the MAC address. Nil location means an undiscovered host.
+	locations map[string]*Location
+}
+
+func (r *fakeLocationDB) set(mac net.HardwareAddr, l *Location) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.locations[mac.String()] = l
+}
+
+func (r *fakeLocationDB) Locations(mac net.HardwareAddr) ([]Location, LocationStatus, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	l, ok := r.locations[mac.String()]
+	if !ok {
+		return nil, LocationUnregistered, nil
+	}
+	if l == nil {
+		return nil, LocationUndiscovered, nil
+	}
+
+	return []Location{*l}, LocationDiscovered, nil
+}
+
+func (r *fakeLocationDB) MACAddrs() ([]net.HardwareAddr, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := make([]net.HardwareAddr, 0, len(r.locations))
+	for k := range r.locations {
+		mac, err := net.ParseMAC(k)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mac)
+	}
+
+	return result, nil
+}
+
+// FakeSwitch is a device that is not connected to any real switch. The messages sent to the device are
+// recorded instead of being written to the socket so that the tests can assert which FLOW_MODs and
+// PACKET_OUTs have been produced. InstallFlowSync and Drain are not supported because nothing replies to
+// the barrier requests.
+type FakeSwitch struct {
+	*Device
+	recorder *messageRecorder
+}
+
+func newFakeSwitch(id string, f openflow.Factory, finder Finder) *FakeSwitch {
+	recorder := new(messageRecorder)
+	s := &session{negotiated: true, finder: finder, writer: recorder}
+	s.device = newDevice(s)
+	s.device.setID(id)
+	s.device.setFactory(f)
+
+	return &FakeSwitch{Device: s.device, recorder: recorder}
+}
+
+// AddPort adds a new port that is up and whose number is num, and then returns it.
+func (r *FakeSwitch) AddPort(num uint32) *Port {
+	r.setPort(num, &fakePort{number: num})
+	return r.Port(num)
+}
+
+// SetNumTables sets the number of the flow tables that the switch reports in its features.
+func (r *FakeSwitch) SetNumTables(n uint8) {
+	f := r.Features()
+	f.NumTables = n
+	r.setFeatures(f)
+}
+
+// Messages returns all the messages sent to the switch in order.
+func (r *FakeSwitch) Messages() []encoding.BinaryMarshaler {
+	return r.recorder.get()
+}
+
+// FlowMods returns the FLOW_MODs sent to the switch in order.
+func (r *FakeSwitch) FlowMods() []openflow.FlowMod {
+	result := make([]openflow.FlowMod, 0)
+	for _, v := range r.recorder.get() {
+		if flow, ok := v.(openflow.FlowMod); ok {
+			result = append(result, flow)
+		}
+	}
+
+	return result
+}
+
+// PacketOuts returns the PACKET_OUTs sent to the switch in order.
+func (r *FakeSwitch) PacketOuts() []openflow.PacketOut {
+	result := make([]openflow.PacketOut, 0)
+	for _, v := range r.recorder.get() {
+		if out, ok := v.(openflow.PacketOut); ok {
+			result = append(result, out)
+		}
+	}
+
+	return result
+}
+
+// Reset forgets the messages recorded so far.
+func (r *FakeSwitch) Reset() {
+	r.recorder.reset()
+}
+
+type messageRecorder struct {
+	mutex    sync.Mutex
+	messages []encoding.BinaryMarshaler
+}
+
+func (r *messageRecorder) Write(msg encoding.BinaryMarshaler) error {
+	// Make sure the message can be encoded as the transceiver does.
+	if _, err := msg.MarshalBinary(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func (r *messageRecorder) WriteBatch(msgs []encoding.BinaryMarshaler) error {
+	for _, v := range msgs {
+		if err := r.Write(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// QueueDepth always returns zero because the messages are recorded right away.
+func (r *messageRecorder) QueueDepth() int {
+	return 0
+}
+
+func (r *messageRecorder) get() []encoding.BinaryMarshaler {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]encoding.BinaryMarshaler{}, r.messages...)
+}
+
+func (r *messageRecorder) reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.messages = nil
+}
+
+// fakePort is a 10 Gbps copper port that is always up.
+type fakePort struct {
+	number  uint32
+	noFlood bool
+}
+
+func (r *fakePort) Number() uint32 {
+	return r.number
+}
+
+func (r *fakePort) MAC() net.HardwareAddr {
+	return net.HardwareAddr{0x02, 0, byte(r.number >> 24), byte(r.number >> 16), byte(r.number >> 8), byte(r.number)}
+}
+
+func (r *fakePort) Name() string {
+	return fmt.Sprintf("fake%v", r.number)
+}
+
+func (r *fakePort) IsPortDown() bool {
+	return false
+}
+
+func (r *fakePort) IsLinkDown() bool {
+	return false
+}
+
+func (r *fakePort) IsNoFlood() bool {
+	return r.noFlood
+}
+
+func (r *fakePort) IsCopper() bool {
+	return true
+}
+
+func (r *fakePort) IsFiber() bool {
+	return false
+}
+
+func (r *fakePort) IsAutoNego() bool {
+	return false
+}
+
+func (r *fakePort) Speed() uint64 {
+	return 10000
+}
+
+func (r *fakePort) UnmarshalBinary(data []byte) error {
+	return fmt.Errorf("fake port %v cannot be unmarshaled", r.number)
+}
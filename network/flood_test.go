@@ -0,0 +1,95 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"testing"
+
+	"github.com/superkkt/cherry/openflow/of10"
+
+	"github.com/superkkt/viper"
+)
+
+func TestFloodPorts(t *testing.T) {
+	defer viper.Reset()
+
+	src := []struct {
+		Mode    string
+		Blocked map[uint32]bool
+		// Nil means the switch's FLOOD port.
+		Expected []uint32
+	}{
+		{Mode: "switch", Expected: nil},
+		// The ingress port 1 and the NO_FLOOD port 3 are excluded.
+		{Mode: "enumerate", Expected: []uint32{2, 4, 5}},
+		{Mode: "enumerate", Blocked: map[uint32]bool{5: true}, Expected: []uint32{2, 4}},
+		// Ports blocked by the spanning tree are enumerated regardless of the mode.
+		{Mode: "switch", Blocked: map[uint32]bool{2: true}, Expected: []uint32{4, 5}},
+		// Nothing to flood.
+		{Mode: "enumerate", Blocked: map[uint32]bool{2: true, 4: true, 5: true}, Expected: []uint32{}},
+		// Invalid mode falls back to the switch mode.
+		{Mode: "unknown", Expected: nil},
+	}
+
+	for i, v := range src {
+		viper.Reset()
+		viper.Set("flood.mode", v.Mode)
+		fake := NewFakeNetwork()
+		sw := fake.AddSwitch("1", of10.NewFactory(), 1, 2, 3, 4, 5)
+		sw.setPort(3, &fakePort{number: 3, noFlood: true})
+
+		ingress := sw.Port(1)
+		sw.mutex.Lock()
+		err := sw.flood(ingress, make([]byte, 64), v.Blocked)
+		sw.mutex.Unlock()
+		if err != nil {
+			t.Fatalf("#%v: failed to flood: %v", i, err)
+		}
+
+		outs := sw.PacketOuts()
+		if len(v.Expected) == 0 && v.Expected != nil {
+			if len(outs) != 0 {
+				t.Fatalf("#%v: unexpected PACKET_OUTs: %v", i, len(outs))
+			}
+			continue
+		}
+		// A single PACKET_OUT that has all the output ports.
+		if len(outs) != 1 {
+			t.Fatalf("#%v: unexpected number of PACKET_OUTs: %v", i, len(outs))
+		}
+		ports := outs[0].Action().OutPorts()
+		if v.Expected == nil {
+			if len(ports) != 1 || !ports[0].IsFlood() {
+				t.Fatalf("#%v: unexpected output ports: %v", i, ports)
+			}
+			continue
+		}
+		if len(ports) != len(v.Expected) {
+			t.Fatalf("#%v: unexpected output ports: expected=%v, got=%v", i, v.Expected, ports)
+		}
+		for j, p := range ports {
+			if !p.IsPhysical() || p.Value() != v.Expected[j] {
+				t.Fatalf("#%v: unexpected output ports: expected=%v, got=%v", i, v.Expected, ports)
+			}
+		}
+	}
+}
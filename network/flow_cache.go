@@ -22,19 +22,37 @@
 package network
 
 import (
+	"bytes"
 	"fmt"
+	"net"
+	"sync/atomic"
 	"time"
 
+	"github.com/superkkt/cherry/clock"
 	"github.com/superkkt/cherry/openflow"
 
 	lru "github.com/hashicorp/golang-lru"
 )
 
+// flowCache remembers the flows recently installed by setFlow to suppress the identical flows that would be
+// installed again by the PACKET_INs arriving before the installed flows take effect.
 type flowCache struct {
 	cache      *lru.Cache
+	clock      clock.Clock
 	expiration time.Duration
 }
 
+type flowCacheEntry struct {
+	timestamp time.Time
+	owner     AppCookie
+	// Encoded match of the flow.
+	match string
+	// Destination MAC address of the flow match, which is nil if it is a wildcard.
+	dstMAC net.HardwareAddr
+	// A flow that has the same match and output port, but different options is not a duplicate.
+	opts FlowOptions
+}
+
 func newFlowCache(expiration time.Duration) *flowCache {
 	c, err := lru.New(8192)
 	if err != nil {
@@ -43,34 +61,53 @@ func newFlowCache(expiration time.Duration) *flowCache {
 
 	return &flowCache{
 		cache:      c,
+		clock:      clock.New(),
 		expiration: expiration,
 	}
 }
 
-func (r *flowCache) Add(match openflow.Match, port openflow.OutPort) error {
-	key, err := r.key(match, port)
+func (r *flowCache) Add(owner AppCookie, match openflow.Match, port openflow.OutPort, opts FlowOptions) error {
+	m, err := encodeMatch(match)
 	if err != nil {
 		return err
 	}
+	key := flowCacheKey(m, port)
 
-	t := time.Now()
+	entry := flowCacheEntry{timestamp: r.clock.Now(), owner: owner, match: m, opts: opts}
+	if wildcard, mac := match.DstMAC(); !wildcard {
+		entry.dstMAC = mac
+	}
 	// Update if the key already exists.
-	r.cache.Add(key, t)
-	logger.Debugf("added a new flow cache: key=%v, timestamp=%v", key, t)
+	r.cache.Add(key, entry)
+	logger.Debugf("added a new flow cache: key=%v, timestamp=%v", key, entry.timestamp)
 
 	return nil
 }
 
-func (r *flowCache) key(match openflow.Match, port openflow.OutPort) (string, error) {
+func encodeMatch(match openflow.Match) (string, error) {
 	m, err := match.MarshalBinary()
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("%v/%v", m, port), nil
+	return fmt.Sprintf("%v", m), nil
 }
 
-func (r *flowCache) InProgress(match openflow.Match, port openflow.OutPort) (ok bool, err error) {
+func flowCacheKey(match string, port openflow.OutPort) string {
+	return fmt.Sprintf("%v/%v", match, port)
+}
+
+func (r *flowCache) key(match openflow.Match, port openflow.OutPort) (string, error) {
+	m, err := encodeMatch(match)
+	if err != nil {
+		return "", err
+	}
+
+	return flowCacheKey(m, port), nil
+}
+
+// InProgress returns whether the same flow that has the match, port, and opts has been installed recently.
+func (r *flowCache) InProgress(match openflow.Match, port openflow.OutPort, opts FlowOptions) (ok bool, err error) {
 	key, err := r.key(match, port)
 	if err != nil {
 		return false, err
@@ -80,19 +117,131 @@ func (r *flowCache) InProgress(match openflow.Match, port openflow.OutPort) (ok
 	if !ok {
 		return false, nil
 	}
-	timestamp := v.(time.Time)
+	entry := v.(flowCacheEntry)
 
 	// Timeout?
-	if time.Since(timestamp) > r.expiration {
+	if r.clock.Since(entry.timestamp) > r.expiration {
 		r.cache.Remove(key)
 		logger.Debugf("removed the timed-out flow cache: key=%v", key)
 		return false, nil
 	}
 
-	return true, nil
+	return entry.opts == opts, nil
+}
+
+// Remove removes the cache of the flow that has the match and port.
+func (r *flowCache) Remove(match openflow.Match, port openflow.OutPort) error {
+	key, err := r.key(match, port)
+	if err != nil {
+		return err
+	}
+	r.cache.Remove(key)
+
+	return nil
+}
+
+// RemoveMatch removes the caches of the flows that have the match regardless of their output ports, e.g., when
+// the switch notifies that the flow has been removed.
+func (r *flowCache) RemoveMatch(match openflow.Match) error {
+	m, err := encodeMatch(match)
+	if err != nil {
+		return err
+	}
+	r.removeIf(func(e flowCacheEntry) bool { return e.match == m })
+
+	return nil
+}
+
+// RemoveDstMAC removes the caches of the flows whose destination MAC address is mac.
+func (r *flowCache) RemoveDstMAC(mac net.HardwareAddr) {
+	r.removeIf(func(e flowCacheEntry) bool { return bytes.Equal(e.dstMAC, mac) })
+}
+
+// RemoveOwner removes the caches of the flows installed by owner.
+func (r *flowCache) RemoveOwner(owner AppCookie) {
+	r.removeIf(func(e flowCacheEntry) bool { return e.owner == owner })
+}
+
+func (r *flowCache) removeIf(cond func(flowCacheEntry) bool) {
+	for _, key := range r.cache.Keys() {
+		v, ok := r.cache.Peek(key)
+		if !ok || !cond(v.(flowCacheEntry)) {
+			continue
+		}
+		r.cache.Remove(key)
+		logger.Debugf("removed the flow cache: key=%v", key)
+	}
 }
 
 func (r *flowCache) RemoveAll() {
 	r.cache.Purge()
 	logger.Debug("removed all the flow caches")
 }
+
+// programmedSet remembers the destination MAC addresses of the recently programmed flows to classify
+// PACKET_INs into the genuine table misses (new flows) and the repeated misses for the flows that
+// should be already installed, which indicate flow install problems or flow table overflows.
+type programmedSet struct {
+	cache      *lru.Cache
+	clock      clock.Clock
+	expiration time.Duration
+	newFlow    uint64
+	repeatMiss uint64
+}
+
+type programmedEntry struct {
+	timestamp time.Time
+	// Application that has programmed the flow last.
+	owner AppCookie
+}
+
+func newProgrammedSet(expiration time.Duration) *programmedSet {
+	c, err := lru.New(8192)
+	if err != nil {
+		panic(fmt.Sprintf("failed to init a LRU programmed set: %v", err))
+	}
+
+	return &programmedSet{
+		cache:      c,
+		clock:      clock.New(),
+		expiration: expiration,
+	}
+}
+
+func (r *programmedSet) Add(owner AppCookie, mac net.HardwareAddr) {
+	r.cache.Add(mac.String(), programmedEntry{timestamp: r.clock.Now(), owner: owner})
+}
+
+func (r *programmedSet) Remove(mac net.HardwareAddr) {
+	r.cache.Remove(mac.String())
+}
+
+func (r *programmedSet) RemoveAll() {
+	r.cache.Purge()
+}
+
+// RemoveOwner removes the destination MAC addresses of the flows that owner has programmed last.
+func (r *programmedSet) RemoveOwner(owner AppCookie) {
+	for _, key := range r.cache.Keys() {
+		v, ok := r.cache.Peek(key)
+		if ok && v.(programmedEntry).owner == owner {
+			r.cache.Remove(key)
+		}
+	}
+}
+
+// Count classifies a PACKET_IN whose destination is mac, and then increases the corresponding counter.
+func (r *programmedSet) Count(mac net.HardwareAddr) (repeat bool) {
+	v, ok := r.cache.Get(mac.String())
+	if ok && r.clock.Since(v.(programmedEntry).timestamp) <= r.expiration {
+		atomic.AddUint64(&r.repeatMiss, 1)
+		return true
+	}
+	atomic.AddUint64(&r.newFlow, 1)
+
+	return false
+}
+
+func (r *programmedSet) Counters() (newFlow, repeatMiss uint64) {
+	return atomic.LoadUint64(&r.newFlow), atomic.LoadUint64(&r.repeatMiss)
+}
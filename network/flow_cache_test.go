@@ -0,0 +1,64 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+)
+
+func TestProgrammedSet(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	set := newProgrammedSet(90 * time.Second)
+	set.clock = c
+
+	mac := net.HardwareAddr([]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+	src := []struct {
+		Advance  time.Duration
+		Add      bool
+		Expected bool // repeat miss
+	}{
+		{Expected: false},
+		{Add: true, Expected: true},
+		{Advance: 90 * time.Second, Expected: true},
+		{Advance: time.Second, Expected: false},
+		{Add: true, Advance: 30 * time.Second, Expected: true},
+	}
+
+	for i, v := range src {
+		if v.Add {
+			set.Add(0, mac)
+		}
+		c.Advance(v.Advance)
+		if repeat := set.Count(mac); repeat != v.Expected {
+			t.Fatalf("#%v: unexpected classification: expected=%v, got=%v", i, v.Expected, repeat)
+		}
+	}
+
+	newFlow, repeatMiss := set.Counters()
+	if newFlow != 2 || repeatMiss != 3 {
+		t.Fatalf("unexpected counters: newFlow=%v, repeatMiss=%v", newFlow, repeatMiss)
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
+	"github.com/superkkt/cherry/openflow/transceiver"
+)
+
+func TestFlowModError(t *testing.T) {
+	src := []struct {
+		version  uint8
+		class    uint16
+		code     uint16
+		expected error
+	}{
+		{openflow.OF10_VERSION, of10.OFPET_FLOW_MOD_FAILED, of10.OFPFMFC_ALL_TABLES_FULL, openflow.ErrFlowTableFull},
+		{openflow.OF10_VERSION, of10.OFPET_FLOW_MOD_FAILED, of10.OFPFMFC_OVERLAP, openflow.ErrFlowOverlap},
+		{openflow.OF10_VERSION, of10.OFPET_FLOW_MOD_FAILED, of10.OFPFMFC_EPERM, openflow.ErrFlowPermission},
+		{openflow.OF10_VERSION, of10.OFPET_FLOW_MOD_FAILED, of10.OFPFMFC_UNSUPPORTED, openflow.ErrFlowBadActions},
+		{openflow.OF10_VERSION, of10.OFPET_FLOW_MOD_FAILED, 99, openflow.ErrFlowModFailed},
+		{openflow.OF13_VERSION, of13.OFPET_FLOW_MOD_FAILED, of13.OFPFMFC_TABLE_FULL, openflow.ErrFlowTableFull},
+		{openflow.OF13_VERSION, of13.OFPET_FLOW_MOD_FAILED, of13.OFPFMFC_BAD_TABLE_ID, openflow.ErrFlowBadTableID},
+		{openflow.OF13_VERSION, of13.OFPET_FLOW_MOD_FAILED, of13.OFPFMFC_OVERLAP, openflow.ErrFlowOverlap},
+		{openflow.OF13_VERSION, of13.OFPET_FLOW_MOD_FAILED, of13.OFPFMFC_BAD_TIMEOUT, openflow.ErrFlowBadTimeout},
+		{openflow.OF13_VERSION, of13.OFPET_FLOW_MOD_FAILED, of13.OFPFMFC_BAD_FLAGS, openflow.ErrFlowBadFlags},
+		{openflow.OF13_VERSION, of13.OFPET_FLOW_MOD_FAILED, of13.OFPFMFC_UNKNOWN, openflow.ErrFlowModFailed},
+		// The class 5 of OpenFlow 1.0 is not FLOW_MOD_FAILED.
+		{openflow.OF10_VERSION, 5, of13.OFPFMFC_TABLE_FULL, nil},
+		{openflow.OF13_VERSION, of13.OFPET_HELLO_FAILED, 0, nil},
+	}
+
+	for i, v := range src {
+		reqErr := &transceiver.RequestError{XID: 7, Class: v.class, Code: v.code}
+		err := flowModError(v.version, reqErr)
+		if v.expected == nil {
+			if err != reqErr {
+				t.Fatalf("#%v: unexpected error: expected=%v, got=%v", i, reqErr, err)
+			}
+			continue
+		}
+		e, ok := err.(*openflow.FlowModError)
+		if !ok {
+			t.Fatalf("#%v: unexpected error type: %T", i, err)
+		}
+		if e.XID != 7 || e.Code != v.code || errors.Cause(err) != v.expected {
+			t.Fatalf("#%v: unexpected error: expected=%v, got=%v", i, v.expected, err)
+		}
+	}
+}
+
+func readTestMessage(conn net.Conn) ([]byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[2:4])-8)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, err
+	}
+
+	return append(header, body...), nil
+}
+
+func TestInstallFlowSyncError(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	f := of13.NewFactory()
+	s := &session{negotiated: true}
+	s.transceiver = transceiver.NewTransceiver(transceiver.NewStream(local, 0xFFFF), s)
+	d := &Device{session: s, factory: f}
+	s.device = d
+	s.handler = &of13Session{device: d, checkpoint: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.transceiver.Run(ctx)
+
+	remote.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := remote.Write([]byte{openflow.OF13_VERSION, of13.OFPT_HELLO, 0, 8, 0, 0, 0, 0}); err != nil {
+		t.Fatalf("failed to send HELLO: %v", err)
+	}
+
+	// The switch rejects the flow-mod because its table is full.
+	go func() {
+		var flowXID uint32
+		for {
+			msg, err := readTestMessage(remote)
+			if err != nil {
+				return
+			}
+			xid := binary.BigEndian.Uint32(msg[4:8])
+			switch msg[1] {
+			case of13.OFPT_FLOW_MOD:
+				flowXID = xid
+			case of13.OFPT_BARRIER_REQUEST:
+				e := []byte{openflow.OF13_VERSION, of13.OFPT_ERROR, 0, 12, 0, 0, 0, 0, 0, of13.OFPET_FLOW_MOD_FAILED, 0, of13.OFPFMFC_TABLE_FULL}
+				binary.BigEndian.PutUint32(e[4:8], flowXID)
+				remote.Write(e)
+				reply := []byte{openflow.OF13_VERSION, of13.OFPT_BARRIER_REPLY, 0, 8, 0, 0, 0, 0}
+				binary.BigEndian.PutUint32(reply[4:8], xid)
+				remote.Write(reply)
+				return
+			}
+		}
+	}()
+
+	match, err := f.NewMatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow, err := f.NewFlowMod(openflow.FlowAdd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow.SetFlowMatch(match)
+
+	err = d.InstallFlowSync(flow)
+	e, ok := err.(*openflow.FlowModError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.XID != flow.TransactionID() || errors.Cause(err) != openflow.ErrFlowTableFull {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
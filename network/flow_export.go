@@ -0,0 +1,216 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+// exportedFlow is a flow entry written by ExportFlows as a JSON line.
+type exportedFlow struct {
+	DPID         string   `json:"dpid"`
+	TableID      uint8    `json:"table_id"`
+	Priority     uint16   `json:"priority"`
+	Cookie       uint64   `json:"cookie"`
+	Match        []string `json:"match"`
+	Actions      []string `json:"actions"`
+	WriteActions []string `json:"write_actions"`
+	Instructions []string `json:"instructions"`
+	PacketCount  uint64   `json:"packet_count"`
+	ByteCount    uint64   `json:"byte_count"`
+	DurationSec  uint32   `json:"duration_sec"`
+	DurationNSec uint32   `json:"duration_nsec"`
+	IdleTimeout  uint16   `json:"idle_timeout"`
+	HardTimeout  uint16   `json:"hard_timeout"`
+}
+
+// ExportFlows writes the last polled flow statistics of all the connected devices to w as JSON lines, one
+// flow entry per line in the order of the device IDs. The output is gzip-compressed if compress is true. The
+// flows are encoded one by one, so the output is streamed without holding the whole export in memory.
+func (r *Controller) ExportFlows(w io.Writer, compress bool) error {
+	if compress {
+		gz := gzip.NewWriter(w)
+		if err := exportFlows(gz, r.topo.Devices()); err != nil {
+			gz.Close()
+			return err
+		}
+		return gz.Close()
+	}
+
+	return exportFlows(w, r.topo.Devices())
+}
+
+func exportFlows(w io.Writer, devices []*Device) error {
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID() < devices[j].ID() })
+
+	enc := json.NewEncoder(w)
+	for _, device := range devices {
+		if device.IsClosed() {
+			continue
+		}
+		dpid := device.ID()
+		for _, s := range device.FlowStats() {
+			v := exportedFlow{
+				DPID:         dpid,
+				TableID:      s.TableID,
+				Priority:     s.Priority,
+				Cookie:       s.Cookie,
+				Match:        []string{},
+				Actions:      actionStrings(s.Actions),
+				WriteActions: actionStrings(s.WriteActions),
+				Instructions: instructionStrings(s),
+				PacketCount:  s.PacketCount,
+				ByteCount:    s.ByteCount,
+				DurationSec:  s.DurationSec,
+				DurationNSec: s.DurationNanoSec,
+				IdleTimeout:  s.IdleTimeout,
+				HardTimeout:  s.HardTimeout,
+			}
+			if s.Match != nil {
+				for _, f := range s.Match.Fields() {
+					v.Match = append(v.Match, f.String())
+				}
+			}
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// instructionStrings describes the instructions of s other than the actions, in the order that the switch
+// executes them regardless of their order in the flow.
+func instructionStrings(s openflow.FlowStat) []string {
+	v := []string{}
+	if s.MeterID != 0 {
+		v = append(v, fmt.Sprintf("meter:%v", s.MeterID))
+	}
+	if s.GotoTable {
+		v = append(v, fmt.Sprintf("goto_table:%v", s.GotoTableID))
+	}
+
+	return v
+}
+
+// actionStrings describes the actions of a. The decoded actions do not keep their order in the flow, so they
+// are described in the order that this controller encodes them, which may differ from the switch.
+func actionStrings(a openflow.Action) []string {
+	v := []string{}
+	if a == nil {
+		return v
+	}
+
+	if a.CopyTTLIn() {
+		v = append(v, "copy_ttl_in")
+	}
+	if ok, etherType := a.PopMPLS(); ok {
+		v = append(v, fmt.Sprintf("pop_mpls:0x%04x", etherType))
+	}
+	if a.PopVLAN() || a.StripVLAN() {
+		v = append(v, "strip_vlan")
+	}
+	if ok, etherType := a.PushMPLS(); ok {
+		v = append(v, fmt.Sprintf("push_mpls:0x%04x", etherType))
+	}
+	if ok, etherType := a.PushVLAN(); ok {
+		v = append(v, fmt.Sprintf("push_vlan:0x%04x", etherType))
+	}
+	if a.CopyTTLOut() {
+		v = append(v, "copy_ttl_out")
+	}
+	if a.DecNWTTL() {
+		v = append(v, "dec_ttl")
+	}
+	if ok, ttl := a.NWTTL(); ok {
+		v = append(v, fmt.Sprintf("set_nw_ttl:%v", ttl))
+	}
+	if ok, mac := a.SrcMAC(); ok {
+		v = append(v, fmt.Sprintf("mod_dl_src:%v", mac))
+	}
+	if ok, mac := a.DstMAC(); ok {
+		v = append(v, fmt.Sprintf("mod_dl_dst:%v", mac))
+	}
+	if ok, vid := a.VLANID(); ok {
+		v = append(v, fmt.Sprintf("mod_vlan_vid:%v", vid))
+	}
+	if ok, label := a.MPLSLabel(); ok {
+		v = append(v, fmt.Sprintf("set_mpls_label:%v", label))
+	}
+	if ok, ip := a.IPSrc(); ok {
+		v = append(v, fmt.Sprintf("mod_nw_src:%v", ip))
+	}
+	if ok, ip := a.IPDst(); ok {
+		v = append(v, fmt.Sprintf("mod_nw_dst:%v", ip))
+	}
+	if ok, port := a.L4SrcPort(); ok {
+		v = append(v, fmt.Sprintf("mod_tp_src:%v", port))
+	}
+	if ok, port := a.L4DstPort(); ok {
+		v = append(v, fmt.Sprintf("mod_tp_dst:%v", port))
+	}
+	if ok, queue := a.Queue(); ok {
+		v = append(v, fmt.Sprintf("set_queue:%v", queue))
+	}
+	if ok, id := a.Group(); ok {
+		return append(v, fmt.Sprintf("group:%v", id))
+	}
+	for _, port := range a.OutPorts() {
+		if s := outPortString(port); s != "" {
+			v = append(v, s)
+		}
+	}
+
+	return v
+}
+
+// outPortString returns the description of port, or an empty string if port does not output the packet.
+func outPortString(port openflow.OutPort) string {
+	switch {
+	case port.IsController():
+		return "output:controller"
+	case port.IsFlood():
+		return "output:flood"
+	case port.IsAll():
+		return "output:all"
+	case port.IsInPort():
+		return "output:in_port"
+	case port.IsNormal():
+		return "output:normal"
+	case port.IsTable():
+		return "output:table"
+	case port.IsNone():
+		return ""
+	case port.Value() == 0:
+		// The zero value of OutPort means no output.
+		return ""
+	default:
+		return fmt.Sprintf("output:%v", port.Value())
+	}
+}
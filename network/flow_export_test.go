@@ -0,0 +1,107 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestExportFlows(t *testing.T) {
+	f := of13.NewFactory()
+	match, err := f.NewMatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inPort := openflow.NewInPort()
+	inPort.SetValue(1)
+	match.SetInPort(inPort)
+	action, err := f.NewAction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	action.SetVLANID(10)
+	port := openflow.NewOutPort()
+	port.SetValue(2)
+	action.SetOutPort(port)
+
+	fake := NewFakeNetwork()
+	// Added in the reverse order of the IDs to check the order of the export.
+	sw2 := fake.AddSwitch("2", f, 1)
+	sw1 := fake.AddSwitch("1", f, 1, 2)
+	// A switch whose flows have not been polled yet.
+	fake.AddSwitch("3", f, 1)
+	sw1.updateFlowStats([]openflow.FlowStat{
+		{TableID: 1, Priority: 100, Cookie: 0x1234, DurationSec: 10, DurationNanoSec: 20, IdleTimeout: 30, PacketCount: 3, ByteCount: 300, Match: match, Actions: action},
+		// Metered flow that writes the action set and then goes to the next table.
+		{TableID: 1, Priority: 50, Match: match, WriteActions: action, MeterID: 7, GotoTable: true, GotoTableID: 2},
+	}, false)
+	sw2.updateFlowStats([]openflow.FlowStat{
+		// Table-miss flow without match fields and actions.
+		{Priority: 0, PacketCount: 5, ByteCount: 500},
+	}, false)
+	controller := &Controller{topo: fake.topology}
+
+	expected := []exportedFlow{
+		{DPID: "1", TableID: 1, Priority: 100, Cookie: 0x1234, Match: []string{"in_port=1"}, Actions: []string{"mod_vlan_vid:10", "output:2"}, WriteActions: []string{}, Instructions: []string{}, PacketCount: 3, ByteCount: 300, DurationSec: 10, DurationNSec: 20, IdleTimeout: 30},
+		{DPID: "1", TableID: 1, Priority: 50, Match: []string{"in_port=1"}, Actions: []string{}, WriteActions: []string{"mod_vlan_vid:10", "output:2"}, Instructions: []string{"meter:7", "goto_table:2"}},
+		{DPID: "2", Match: []string{}, Actions: []string{}, WriteActions: []string{}, Instructions: []string{}, PacketCount: 5, ByteCount: 500},
+	}
+	for _, compress := range []bool{false, true} {
+		buf := new(bytes.Buffer)
+		if err := controller.ExportFlows(buf, compress); err != nil {
+			t.Fatalf("compress=%v: unexpected error: %v", compress, err)
+		}
+
+		var r io.Reader = buf
+		if compress {
+			gz, err := gzip.NewReader(buf)
+			if err != nil {
+				t.Fatalf("compress=%v: invalid gzip stream: %v", compress, err)
+			}
+			r = gz
+		}
+		flows := make([]exportedFlow, 0)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var v exportedFlow
+			if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+				t.Fatalf("compress=%v: invalid JSON line: %v", compress, err)
+			}
+			flows = append(flows, v)
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("compress=%v: failed to read the export: %v", compress, err)
+		}
+		if !reflect.DeepEqual(flows, expected) {
+			t.Fatalf("compress=%v: unexpected flows: expected=%+v, got=%+v", compress, expected, flows)
+		}
+	}
+}
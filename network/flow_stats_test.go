@@ -0,0 +1,101 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestFlowStatsSnapshot(t *testing.T) {
+	d := new(Device)
+	if d.FlowStats() != nil {
+		t.Fatal("expected nil stats before polling")
+	}
+
+	// Incomplete response should not replace the snapshot.
+	d.updateFlowStats([]openflow.FlowStat{{PacketCount: 1}}, true)
+	if d.FlowStats() != nil {
+		t.Fatal("expected nil stats for the incomplete response")
+	}
+	d.updateFlowStats([]openflow.FlowStat{{PacketCount: 2}}, false)
+	stats := d.FlowStats()
+	if len(stats) != 2 || stats[0].PacketCount != 1 || stats[1].PacketCount != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// The returned snapshot should not be affected by the next response.
+	d.updateFlowStats(nil, false)
+	if len(stats) != 2 || len(d.FlowStats()) != 0 || d.FlowStats() == nil {
+		t.Fatalf("unexpected stats after the empty response: old=%+v, new=%+v", stats, d.FlowStats())
+	}
+}
+
+func TestMatchFlowStats(t *testing.T) {
+	f := of13.NewFactory()
+	newMatch := func(mac net.HardwareAddr) openflow.Match {
+		match, err := f.NewMatch()
+		if err != nil {
+			t.Fatalf("failed to create a match: %v", err)
+		}
+		match.SetDstMAC(mac)
+		return match
+	}
+	mac1 := net.HardwareAddr{0, 0, 0, 0, 0, 1}
+	mac2 := net.HardwareAddr{0, 0, 0, 0, 0, 2}
+
+	d := new(Device)
+	d.updateFlowStats([]openflow.FlowStat{
+		{Priority: 10, PacketCount: 1, Match: newMatch(mac1)},
+		{Priority: 20, PacketCount: 2, Match: newMatch(mac1)},
+		{Priority: 10, PacketCount: 3, Match: newMatch(mac2)},
+		// Undecoded match.
+		{Priority: 10, PacketCount: 4},
+	}, false)
+
+	src := []struct {
+		match    openflow.Match
+		expected []uint64 // Packet counts
+	}{
+		{newMatch(mac1), []uint64{1, 2}},
+		{newMatch(mac2), []uint64{3}},
+		{newMatch(net.HardwareAddr{0, 0, 0, 0, 0, 3}), nil},
+	}
+
+	for i, v := range src {
+		stats, err := d.MatchFlowStats(v.match)
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if len(stats) != len(v.expected) {
+			t.Fatalf("#%v: unexpected stats: %+v", i, stats)
+		}
+		for j, s := range stats {
+			if s.PacketCount != v.expected[j] {
+				t.Fatalf("#%v: unexpected packet count: expected=%v, got=%v", i, v.expected[j], s.PacketCount)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// jitterRandom is the random source of the timeout jitter. Tests replace it with a seeded one to get
+// deterministic timeouts.
+var jitterRandom = rand.New(&randomSource{src: rand.NewSource(time.Now().UnixNano())})
+
+// randomSource is safe for concurrent use by multiple goroutines.
+type randomSource struct {
+	sync.Mutex
+	src rand.Source
+}
+
+func (r *randomSource) Int63() (n int64) {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.src.Int63()
+}
+
+func (r *randomSource) Seed(seed int64) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.src.Seed(seed)
+}
+
+// jitterTimeout returns a random timeout within timeout ± jitter seconds so that the flows installed in
+// a burst do not expire at the same time. Zero timeout, which means no timeout, is returned as is, and
+// the result is never shorter than one second.
+func jitterTimeout(timeout, jitter uint16) uint16 {
+	if timeout == 0 || jitter == 0 {
+		return timeout
+	}
+
+	t := int(timeout) + jitterRandom.Intn(2*int(jitter)+1) - int(jitter)
+	if t < 1 {
+		return 1
+	}
+	if t > 0xFFFF {
+		return 0xFFFF
+	}
+
+	return uint16(t)
+}
@@ -0,0 +1,106 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestTimeoutJitter(t *testing.T) {
+	defer func(r *rand.Rand) { jitterRandom = r }(jitterRandom)
+	jitterRandom = rand.New(rand.NewSource(1))
+
+	src := []struct {
+		timeout, jitter uint16
+		min, max        uint16
+	}{
+		{30, 5, 25, 35},
+		{30, 0, 30, 30},
+		// Zero timeout means no timeout.
+		{0, 5, 0, 0},
+		{1, 3, 1, 4},
+		{0xFFFF, 10, 0xFFFF - 10, 0xFFFF},
+	}
+
+	for i, v := range src {
+		seen := make(map[uint16]bool)
+		for j := 0; j < 1000; j++ {
+			timeout := jitterTimeout(v.timeout, v.jitter)
+			if timeout < v.min || timeout > v.max {
+				t.Fatalf("#%v: timeout out of the band: expected=%v-%v, got=%v", i, v.min, v.max, timeout)
+			}
+			seen[timeout] = true
+		}
+		// The timeouts should be spread over the band.
+		if len(seen) != int(v.max-v.min)+1 {
+			t.Fatalf("#%v: unexpected number of the distinct timeouts: expected=%v, got=%v", i, v.max-v.min+1, len(seen))
+		}
+	}
+}
+
+func TestFlowTimeoutJitter(t *testing.T) {
+	defer func(r *rand.Rand) { jitterRandom = r }(jitterRandom)
+	jitterRandom = rand.New(rand.NewSource(1))
+
+	owner, err := RegisterAppCookie("JitterTestApp")
+	if err != nil {
+		t.Fatalf("failed to register the cookie: %v", err)
+	}
+	fake := NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	opts := DefaultFlowOptions
+	opts.IdleTimeout = 30
+	opts.IdleTimeoutJitter = 5
+	opts.HardTimeout = 300
+	opts.HardTimeoutJitter = 60
+
+	for i := 1; i <= 100; i++ {
+		match, err := sw.Factory().NewMatch()
+		if err != nil {
+			t.Fatalf("failed to create a match: %v", err)
+		}
+		match.SetDstMAC(net.HardwareAddr{0, 0, 0, 0, 0, byte(i)})
+		port := openflow.NewOutPort()
+		port.SetValue(2)
+		if err := sw.SetFlowWithOptions(owner, match, port, opts); err != nil {
+			t.Fatalf("failed to install the flow: %v", err)
+		}
+	}
+
+	flows := sw.FlowMods()
+	if len(flows) != 100 {
+		t.Fatalf("unexpected number of the flows: expected=100, got=%v", len(flows))
+	}
+	for i, v := range flows {
+		if idle := v.IdleTimeout(); idle < 25 || idle > 35 {
+			t.Fatalf("#%v: idle timeout out of the band: %v", i, idle)
+		}
+		if hard := v.HardTimeout(); hard < 240 || hard > 360 {
+			t.Fatalf("#%v: hard timeout out of the band: %v", i, hard)
+		}
+	}
+}
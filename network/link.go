@@ -28,6 +28,10 @@ import (
 	"github.com/superkkt/cherry/graph"
 )
 
+// Reference bandwidth in Mbps to calculate the link weight. A link whose speed
+// is equal to or faster than this value has the minimum weight 1.
+const referenceBandwidth = 100000
+
 type link struct {
 	ports [2]*Port
 }
@@ -49,7 +53,26 @@ func (r *link) Points() [2]graph.Point {
 	return [2]graph.Point{r.ports[0], r.ports[1]}
 }
 
+// Weight returns the link weight that is inversely proportional to the slower
+// speed of the two ports, so the link weight follows the port speed changes.
 func (r *link) Weight() float64 {
-	// TODO: Calculate weight dynamically based on the link speed among these two ports
-	return 0
+	speed := portSpeed(r.ports[0])
+	if s := portSpeed(r.ports[1]); s < speed {
+		speed = s
+	}
+	// Unknown speed?
+	if speed == 0 || speed >= referenceBandwidth {
+		return 1
+	}
+
+	return float64(referenceBandwidth) / float64(speed)
+}
+
+func portSpeed(p *Port) uint64 {
+	v := p.Value()
+	if v == nil {
+		return 0
+	}
+
+	return v.Speed()
 }
@@ -0,0 +1,179 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+
+	"github.com/superkkt/viper"
+)
+
+// macFlapConfig returns the number of the moves of a MAC address between the ports within window that is
+// regarded as a flap, and the duration for which a flapping MAC address is pinned. Zero threshold disables
+// the detection.
+func macFlapConfig() (threshold int, window, hold time.Duration) {
+	threshold = viper.GetInt("default.mac_flap_threshold")
+	if threshold < 0 {
+		threshold = 0
+	}
+	window = 10 * time.Second
+	if v := viper.GetInt("default.mac_flap_window"); v > 0 {
+		window = time.Duration(v) * time.Second
+	}
+	hold = 60 * time.Second
+	if v := viper.GetInt("default.mac_flap_hold"); v > 0 {
+		hold = time.Duration(v) * time.Second
+	}
+
+	return threshold, window, hold
+}
+
+// macFlapDetector detects the MAC addresses whose ingress ports change too often, which is usually caused by
+// a forwarding loop that the spanning tree misses, e.g., a loop through an unmanaged switch. A flapping MAC
+// address is pinned to the port on which it has been before the flap until the hold time passes, so that the
+// applications do not learn the conflicting locations and install the conflicting flows.
+type macFlapDetector struct {
+	mutex sync.Mutex
+	// Zero threshold disables the detection.
+	threshold int
+	window    time.Duration
+	hold      time.Duration
+	clock     clock.Clock
+	// Key is the MAC address.
+	hosts map[string]*macHistory
+	// Time when the idle histories have been pruned last.
+	lastPrune time.Time
+	// Number of the detected flaps.
+	flaps uint64
+}
+
+type macHistory struct {
+	port *Port
+	// Times of the recent moves within the window.
+	moves []time.Time
+	// The MAC address is pinned to port until this time if it is not zero.
+	pinned time.Time
+}
+
+func newMACFlapDetector(threshold int, window, hold time.Duration) *macFlapDetector {
+	return &macFlapDetector{
+		threshold: threshold,
+		window:    window,
+		hold:      hold,
+		clock:     clock.New(),
+		hosts:     make(map[string]*macHistory),
+	}
+}
+
+// observe records that a packet whose source MAC address is mac has been received on ingress, and returns
+// whether the packet can be used to learn the location of mac. It returns false if mac is pinned to another
+// port because of a flap.
+func (r *macFlapDetector) observe(ingress *Port, mac net.HardwareAddr) bool {
+	if r.threshold == 0 {
+		return true
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.clock.Now()
+	r.prune(now)
+	key := mac.String()
+	h, ok := r.hosts[key]
+	if !ok {
+		r.hosts[key] = &macHistory{port: ingress}
+		return true
+	}
+	if !h.pinned.IsZero() {
+		if now.Before(h.pinned) {
+			return ingress == h.port
+		}
+		logger.Infof("MAC address %v is unpinned from %v", mac, h.port.ID())
+		h.pinned = time.Time{}
+		h.moves = nil
+	}
+	if ingress == h.port {
+		return true
+	}
+
+	// Forget the moves out of the window.
+	i := 0
+	for i < len(h.moves) && now.Sub(h.moves[i]) > r.window {
+		i++
+	}
+	h.moves = append(h.moves[i:], now)
+	if len(h.moves) <= r.threshold {
+		h.port = ingress
+		return true
+	}
+
+	r.flaps++
+	h.pinned = now.Add(r.hold)
+	logger.Warningf("MAC address %v is flapping between %v and %v (%v moves within %v), so it is pinned to %v for %v: possible forwarding loop",
+		mac, h.port.ID(), ingress.ID(), len(h.moves), r.window, h.port.ID(), r.hold)
+
+	return false
+}
+
+// prune removes the histories of the MAC addresses that have not moved within the window and are not pinned,
+// so that the histories of all the MAC addresses ever seen are not kept forever. It scans the histories at
+// most once per window. XXX: Caller should lock the mutex.
+func (r *macFlapDetector) prune(now time.Time) {
+	if now.Sub(r.lastPrune) < r.window {
+		return
+	}
+	r.lastPrune = now
+	for k, h := range r.hosts {
+		if !h.pinned.IsZero() && now.Before(h.pinned) {
+			continue
+		}
+		if n := len(h.moves); n > 0 && now.Sub(h.moves[n-1]) <= r.window {
+			continue
+		}
+		delete(r.hosts, k)
+	}
+}
+
+// forget removes the histories of the MAC addresses whose last ports satisfy f, e.g., the ports that have
+// been removed.
+func (r *macFlapDetector) forget(f func(*Port) bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for k, h := range r.hosts {
+		if f(h.port) {
+			delete(r.hosts, k)
+		}
+	}
+}
+
+// count returns the number of the detected flaps.
+func (r *macFlapDetector) count() uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.flaps
+}
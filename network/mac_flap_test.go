@@ -0,0 +1,148 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestMACFlapDetector(t *testing.T) {
+	fake := NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	p1, p2 := sw.Port(1), sw.Port(2)
+
+	src := []struct {
+		mac     net.HardwareAddr
+		elapsed time.Duration // Since the start of the test
+		port    *Port
+		learn   bool
+		flaps   uint64
+	}{
+		{net.HardwareAddr{0, 0, 0, 0, 0, 1}, 0, p1, true, 0},
+		// Up to 3 moves within the window are allowed.
+		{net.HardwareAddr{0, 0, 0, 0, 0, 1}, 1 * time.Second, p2, true, 0},
+		{net.HardwareAddr{0, 0, 0, 0, 0, 1}, 2 * time.Second, p1, true, 0},
+		{net.HardwareAddr{0, 0, 0, 0, 0, 1}, 3 * time.Second, p2, true, 0},
+		{net.HardwareAddr{0, 0, 0, 0, 0, 1}, 3 * time.Second, p2, true, 0},
+		// The 4th move is a flap, and the MAC address is pinned to port 2.
+		{net.HardwareAddr{0, 0, 0, 0, 0, 1}, 4 * time.Second, p1, false, 1},
+		{net.HardwareAddr{0, 0, 0, 0, 0, 1}, 5 * time.Second, p2, true, 1},
+		{net.HardwareAddr{0, 0, 0, 0, 0, 1}, 6 * time.Second, p1, false, 1},
+		// Other MAC addresses are not affected.
+		{net.HardwareAddr{0, 0, 0, 0, 0, 2}, 6 * time.Second, p1, true, 1},
+		// Unpinned after the hold time.
+		{net.HardwareAddr{0, 0, 0, 0, 0, 1}, 65 * time.Second, p1, true, 1},
+		// The moves out of the window are not counted.
+		{net.HardwareAddr{0, 0, 0, 0, 0, 2}, 80 * time.Second, p2, true, 1},
+		{net.HardwareAddr{0, 0, 0, 0, 0, 2}, 100 * time.Second, p1, true, 1},
+		{net.HardwareAddr{0, 0, 0, 0, 0, 2}, 120 * time.Second, p2, true, 1},
+		{net.HardwareAddr{0, 0, 0, 0, 0, 2}, 140 * time.Second, p1, true, 1},
+		{net.HardwareAddr{0, 0, 0, 0, 0, 2}, 160 * time.Second, p2, true, 1},
+	}
+
+	start := time.Now()
+	clk := clock.NewFake(start)
+	detector := newMACFlapDetector(3, 10*time.Second, 60*time.Second)
+	detector.clock = clk
+	for i, v := range src {
+		clk.Advance(start.Add(v.elapsed).Sub(clk.Now()))
+		if learn := detector.observe(v.port, v.mac); learn != v.learn {
+			t.Fatalf("#%v: unexpected result: expected=%v, got=%v", i, v.learn, learn)
+		}
+		if flaps := detector.count(); flaps != v.flaps {
+			t.Fatalf("#%v: unexpected number of flaps: expected=%v, got=%v", i, v.flaps, flaps)
+		}
+	}
+
+	// Zero threshold disables the detection.
+	disabled := newMACFlapDetector(0, 10*time.Second, 60*time.Second)
+	for i := 0; i < 10; i++ {
+		port := p1
+		if i%2 == 1 {
+			port = p2
+		}
+		if !disabled.observe(port, net.HardwareAddr{0, 0, 0, 0, 0, 1}) {
+			t.Fatalf("#%v: unexpected flap on the disabled detector", i)
+		}
+	}
+}
+
+func TestMACFlapDetectorForget(t *testing.T) {
+	fake := NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	p1, p2 := sw.Port(1), sw.Port(2)
+	mac := net.HardwareAddr{0, 0, 0, 0, 0, 1}
+
+	detector := newMACFlapDetector(1, 10*time.Second, 60*time.Second)
+	detector.observe(p1, mac)
+	detector.observe(p2, mac)
+	if detector.observe(p1, mac) {
+		t.Fatal("expected a flap")
+	}
+
+	// The pin is released if its port is removed.
+	detector.forget(func(p *Port) bool { return p == p2 })
+	if !detector.observe(p1, mac) {
+		t.Fatal("unexpected flap after the port is removed")
+	}
+}
+
+func TestMACFlapDetectorPrune(t *testing.T) {
+	fake := NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	p1, p2 := sw.Port(1), sw.Port(2)
+	pinned := net.HardwareAddr{0, 0, 0, 0, 0, 1}
+
+	clk := clock.NewFake(time.Now())
+	detector := newMACFlapDetector(1, 10*time.Second, 60*time.Second)
+	detector.clock = clk
+	detector.observe(p1, pinned)
+	detector.observe(p2, pinned)
+	if detector.observe(p1, pinned) {
+		t.Fatal("expected a flap")
+	}
+	for i := 2; i < 10; i++ {
+		detector.observe(p1, net.HardwareAddr{0, 0, 0, 0, 0, byte(i)})
+	}
+
+	// The idle histories are pruned, but the pinned one is kept.
+	clk.Advance(30 * time.Second)
+	detector.observe(p1, net.HardwareAddr{0, 0, 0, 0, 0, 10})
+	if n := len(detector.hosts); n != 2 {
+		t.Fatalf("unexpected number of the histories: expected=2, got=%v", n)
+	}
+	if detector.observe(p1, pinned) {
+		t.Fatal("pinned MAC address has been pruned")
+	}
+
+	// The expired pin is also pruned.
+	clk.Advance(60 * time.Second)
+	detector.observe(p1, net.HardwareAddr{0, 0, 0, 0, 0, 10})
+	if n := len(detector.hosts); n != 1 {
+		t.Fatalf("unexpected number of the histories: expected=1, got=%v", n)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestSetMeter(t *testing.T) {
+	fake := NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	meter := openflow.Meter{
+		ID:    1,
+		Bands: []openflow.MeterBand{{Type: openflow.MeterBandDrop, Rate: 1000}},
+	}
+	// The meter is installed again on every reconnect.
+	for i := 0; i < 2; i++ {
+		sw.Reset()
+		if err := sw.SetMeter(meter); err != nil {
+			t.Fatalf("#%v: failed to set the meter: %v", i, err)
+		}
+
+		// The existing meter is deleted before adding the new one.
+		expected := []openflow.MeterCommand{openflow.MeterDelete, openflow.MeterAdd}
+		var commands []openflow.MeterCommand
+		for _, msg := range sw.Messages() {
+			mod, ok := msg.(openflow.MeterMod)
+			if !ok {
+				continue
+			}
+			if mod.Meter().ID != meter.ID {
+				t.Fatalf("#%v: unexpected meter ID: %v", i, mod.Meter().ID)
+			}
+			commands = append(commands, mod.Command())
+		}
+		if len(commands) != len(expected) || commands[0] != expected[0] || commands[1] != expected[1] {
+			t.Fatalf("#%v: unexpected meter commands: expected=%v, got=%v", i, expected, commands)
+		}
+	}
+}
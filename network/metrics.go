@@ -0,0 +1,104 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"github.com/superkkt/cherry/metrics"
+)
+
+// Collect returns the metrics of the connected devices. It implements metrics.Collector.
+func (r *Controller) Collect() []metrics.Metric {
+	devices := metrics.Metric{
+		Name: "cherry_devices",
+		Help: "Number of the connected devices.",
+		Type: metrics.TypeGauge,
+	}
+	packetIns := metrics.Metric{
+		Name: "cherry_packet_ins_total",
+		Help: "Number of the PACKET_INs received from the device.",
+		Type: metrics.TypeCounter,
+	}
+	flowMods := metrics.Metric{
+		Name: "cherry_flow_mods_total",
+		Help: "Number of the FLOW_MODs sent to the device.",
+		Type: metrics.TypeCounter,
+	}
+	flows := metrics.Metric{
+		Name: "cherry_device_flows",
+		Help: "Number of the flows installed in the device, which is available only if the flow statistics are polled.",
+		Type: metrics.TypeGauge,
+	}
+	appFlows := metrics.Metric{
+		Name: "cherry_app_flows",
+		Help: "Number of the flows installed in the device by the application.",
+		Type: metrics.TypeGauge,
+	}
+
+	count := 0
+	for _, device := range r.topo.Devices() {
+		if device.IsClosed() {
+			continue
+		}
+		count++
+
+		labels := []metrics.Label{{Name: "dpid", Value: device.ID()}}
+		p, f := device.MessageCounters()
+		packetIns.Samples = append(packetIns.Samples, metrics.Sample{Labels: labels, Value: float64(p)})
+		flowMods.Samples = append(flowMods.Samples, metrics.Sample{Labels: labels, Value: float64(f)})
+		if stats := device.FlowStats(); stats != nil {
+			flows.Samples = append(flows.Samples, metrics.Sample{Labels: labels, Value: float64(len(stats))})
+		}
+		for app, n := range device.AppFlowCounts() {
+			l := []metrics.Label{{Name: "dpid", Value: device.ID()}, {Name: "app", Value: app}}
+			appFlows.Samples = append(appFlows.Samples, metrics.Sample{Labels: l, Value: float64(n)})
+		}
+	}
+	devices.Samples = []metrics.Sample{{Value: float64(count)}}
+
+	connections, refused := r.limiter.counts()
+	conns := metrics.Metric{
+		Name:    "cherry_device_connections",
+		Help:    "Number of the device connections counted against the device limit.",
+		Type:    metrics.TypeGauge,
+		Samples: []metrics.Sample{{Value: float64(connections)}},
+	}
+	limit := metrics.Metric{
+		Name:    "cherry_device_limit",
+		Help:    "Max number of the devices connected at the same time. Zero means no limit.",
+		Type:    metrics.TypeGauge,
+		Samples: []metrics.Sample{{Value: float64(maxDevices())}},
+	}
+	refusedDevices := metrics.Metric{
+		Name:    "cherry_refused_devices_total",
+		Help:    "Number of the device connections refused because of the device limit.",
+		Type:    metrics.TypeCounter,
+		Samples: []metrics.Sample{{Value: float64(refused)}},
+	}
+	macFlaps := metrics.Metric{
+		Name:    "cherry_mac_flaps_total",
+		Help:    "Number of the MAC addresses detected flapping between the ports, which are possible forwarding loops.",
+		Type:    metrics.TypeCounter,
+		Samples: []metrics.Sample{{Value: float64(r.topo.flap.count())}},
+	}
+
+	return []metrics.Metric{devices, packetIns, flowMods, flows, appFlows, conns, limit, refusedDevices, macFlaps}
+}
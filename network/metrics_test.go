@@ -0,0 +1,101 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestControllerMetrics(t *testing.T) {
+	fake := NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	fake.AddSwitch("2", of13.NewFactory(), 1)
+	controller := &Controller{topo: fake.topology}
+
+	match, err := sw1.Factory().NewMatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	match.SetDstMAC(net.HardwareAddr{0, 0, 0, 0, 0, 1})
+	port := openflow.NewOutPort()
+	port.SetValue(2)
+	if err := sw1.SetFlow(AppCookie(0), match, port); err != nil {
+		t.Fatalf("failed to set a flow: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, m := range controller.Collect() {
+		for _, s := range m.Samples {
+			key := m.Name
+			for _, l := range s.Labels {
+				key += "/" + l.Value
+			}
+			values[key] = s.Value
+		}
+	}
+
+	expected := map[string]float64{
+		"cherry_devices":               2,
+		"cherry_flow_mods_total/1":     1,
+		"cherry_flow_mods_total/2":     0,
+		"cherry_packet_ins_total/1":    0,
+		"cherry_packet_ins_total/2":    0,
+		"cherry_device_connections":    0,
+		"cherry_device_limit":          0,
+		"cherry_refused_devices_total": 0,
+		"cherry_mac_flaps_total":       0,
+	}
+	if len(values) != len(expected) {
+		t.Fatalf("unexpected samples: %v", values)
+	}
+	for k, v := range expected {
+		if values[k] != v {
+			t.Fatalf("unexpected value of %v: expected=%v, got=%v", k, v, values[k])
+		}
+	}
+}
+
+func TestControllerStats(t *testing.T) {
+	fake := NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	controller := &Controller{topo: fake.topology}
+
+	fake.SetLocation(net.HardwareAddr{0, 0, 0, 0, 0, 1}, sw1.Port(1))
+	fake.SetLocation(net.HardwareAddr{0, 0, 0, 0, 0, 2}, sw1.Port(2))
+	// Registered host that has not been discovered yet.
+	fake.Register(net.HardwareAddr{0, 0, 0, 0, 0, 3})
+
+	stats, err := controller.Stats()
+	if err != nil {
+		t.Fatalf("failed to get the stats: %v", err)
+	}
+	if stats.Devices != 1 {
+		t.Fatalf("unexpected number of devices: %v", stats.Devices)
+	}
+	if stats.Nodes != 2 {
+		t.Fatalf("unexpected number of nodes: expected=2, got=%v", stats.Nodes)
+	}
+}
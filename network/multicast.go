@@ -0,0 +1,77 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+// DistributionTree is a multicast distribution tree. Key is a device ID, and value is the egress
+// ports of the device that a multicast packet should be replicated to.
+type DistributionTree map[string][]*Port
+
+func (r DistributionTree) add(p *Port) {
+	id := p.Device().ID()
+	for _, v := range r[id] {
+		if v.Number() == p.Number() {
+			return
+		}
+	}
+	r[id] = append(r[id], p)
+}
+
+// buildTree returns a distribution tree from source toward all the members. path should return
+// the shortest path between two devices over the spanning tree, so that the union of the paths
+// from the source is also a tree without any loop.
+func buildTree(source *Port, members []*Port, path func(srcDeviceID, dstDeviceID string) [][2]*Port) DistributionTree {
+	tree := make(DistributionTree)
+	srcID := source.Device().ID()
+
+	for _, m := range members {
+		dstID := m.Device().ID()
+		// The member is on the same port as the source.
+		if dstID == srcID && m.Number() == source.Number() {
+			continue
+		}
+
+		if dstID != srcID {
+			hops := path(srcID, dstID)
+			// Unreachable member?
+			if len(hops) == 0 {
+				logger.Debugf("skip an unreachable multicast member: source=%v, member=%v", source.ID(), m.ID())
+				continue
+			}
+			for _, hop := range hops {
+				// hop[0] is the egress port toward the member.
+				tree.add(hop[0])
+			}
+		}
+		tree.add(m)
+	}
+
+	return tree
+}
+
+func (r *topology) Tree(source *Port, members []*Port) DistributionTree {
+	path := func(srcDeviceID, dstDeviceID string) [][2]*Port {
+		p, _ := r.Path(srcDeviceID, dstDeviceID)
+		return p
+	}
+
+	return buildTree(source, members, path)
+}
@@ -0,0 +1,136 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow/of13"
+
+	"github.com/pkg/errors"
+)
+
+func TestDistributionTree(t *testing.T) {
+	// Topology: 1(p1) -- (p1)2(p2) -- (p1)3, and 2(p3) -- (p1)4.
+	d1, d2, d3, d4 := &Device{id: "1"}, &Device{id: "2"}, &Device{id: "3"}, &Device{id: "4"}
+	links := map[string][][2]*Port{
+		"1-2": {{NewPort(d1, 1), NewPort(d2, 1)}},
+		"1-3": {{NewPort(d1, 1), NewPort(d2, 1)}, {NewPort(d2, 2), NewPort(d3, 1)}},
+		"1-4": {{NewPort(d1, 1), NewPort(d2, 1)}, {NewPort(d2, 3), NewPort(d4, 1)}},
+	}
+	path := func(src, dst string) [][2]*Port {
+		return links[src+"-"+dst]
+	}
+
+	src := []struct {
+		Source   *Port
+		Members  []*Port
+		Expected map[string][]uint32
+	}{
+		// Source on switch 1 and members on several switches.
+		{
+			Source:  NewPort(d1, 10),
+			Members: []*Port{NewPort(d3, 10), NewPort(d4, 10), NewPort(d4, 11), NewPort(d1, 11)},
+			Expected: map[string][]uint32{
+				"1": {1, 11},
+				"2": {2, 3},
+				"3": {10},
+				"4": {10, 11},
+			},
+		},
+		// Member on the source port itself, and an unreachable member.
+		{
+			Source:  NewPort(d1, 10),
+			Members: []*Port{NewPort(d1, 10), NewPort(d2, 10), NewPort(&Device{id: "5"}, 10)},
+			Expected: map[string][]uint32{
+				"1": {1},
+				"2": {10},
+			},
+		},
+		// No member.
+		{
+			Source:   NewPort(d1, 10),
+			Members:  []*Port{},
+			Expected: map[string][]uint32{},
+		},
+	}
+
+	for i, v := range src {
+		tree := buildTree(v.Source, v.Members, path)
+		if len(tree) != len(v.Expected) {
+			t.Fatalf("#%v: unexpected number of devices: expected=%v, got=%v", i, len(v.Expected), len(tree))
+		}
+		for id, expected := range v.Expected {
+			ports := make([]int, 0)
+			for _, p := range tree[id] {
+				ports = append(ports, int(p.Number()))
+			}
+			sort.Ints(ports)
+			if len(ports) != len(expected) {
+				t.Fatalf("#%v: unexpected ports on device %v: expected=%v, got=%v", i, id, expected, ports)
+			}
+			for j := range ports {
+				if uint32(ports[j]) != expected[j] {
+					t.Fatalf("#%v: unexpected ports on device %v: expected=%v, got=%v", i, id, expected, ports)
+				}
+			}
+		}
+	}
+}
+
+func TestSetMulticastFlow(t *testing.T) {
+	src := []struct {
+		opts FlowOptions
+		err  bool
+	}{
+		{opts: DefaultMulticastFlowOptions},
+		{opts: FlowOptions{IdleTimeout: 30, HardTimeout: 300, Priority: 20}},
+		// Out of the multicast priority band.
+		{opts: FlowOptions{IdleTimeout: 90, Priority: 10}, err: true},
+		{opts: FlowOptions{IdleTimeout: 90, Priority: 21}, err: true},
+	}
+
+	for i, v := range src {
+		fake := NewFakeNetwork()
+		sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2, 3)
+		err := sw.SetMulticastFlow(net.IPv4(10, 0, 0, 1), net.IPv4(239, 0, 0, 1), []*Port{sw.Port(2), sw.Port(3)}, v.opts)
+		if (err != nil) != v.err {
+			t.Fatalf("#%v: unexpected result: expected error=%v, got=%v", i, v.err, err)
+		}
+		flows := sw.FlowMods()
+		if v.err {
+			if errors.Cause(err) != ErrPriorityOutOfBand || len(flows) != 0 {
+				t.Fatalf("#%v: unexpected error: %v", i, err)
+			}
+			continue
+		}
+		if len(flows) != 1 {
+			t.Fatalf("#%v: unexpected number of flows: %v", i, len(flows))
+		}
+		f := flows[0]
+		if f.IdleTimeout() != v.opts.IdleTimeout || f.HardTimeout() != v.opts.HardTimeout || f.Priority() != v.opts.Priority {
+			t.Fatalf("#%v: unexpected flow: idle=%v, hard=%v, priority=%v", i, f.IdleTimeout(), f.HardTimeout(), f.Priority())
+		}
+	}
+}
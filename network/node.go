@@ -24,11 +24,16 @@ package network
 import (
 	"fmt"
 	"net"
+	"time"
 )
 
 type Node struct {
 	port *Port
 	mac  net.HardwareAddr
+	// VLAN ID of the node. Zero means the untagged node.
+	vlanID uint16
+	// Last time the node has been seen on the port. Zero if it is unknown.
+	lastSeen time.Time
 }
 
 func NewNode(p *Port, mac net.HardwareAddr) *Node {
@@ -46,6 +51,16 @@ func (r *Node) Port() *Port {
 	return r.port
 }
 
+// VLANID returns the VLAN ID of the node, which is zero if the node is untagged.
+func (r *Node) VLANID() uint16 {
+	return r.vlanID
+}
+
 func (r *Node) MAC() net.HardwareAddr {
 	return r.mac
 }
+
+// LastSeen returns the last time the node has been seen on its port, which is zero if it is unknown.
+func (r *Node) LastSeen() time.Time {
+	return r.lastSeen
+}
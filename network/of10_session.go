@@ -35,11 +35,14 @@ type of10Session struct {
 	// installed flows on the device have been removed, and then the ACL flow for
 	// ARP packes has been installed.
 	checkpoint bool
+	// Connect-time port configuration.
+	portConfig *portConfigurator
 }
 
 func newOF10Session(d *Device) *of10Session {
 	return &of10Session{
-		device: d,
+		device:     d,
+		portConfig: newPortConfigurator(),
 	}
 }
 
@@ -47,9 +50,6 @@ func (r *of10Session) OnHello(f openflow.Factory, w transceiver.Writer, v openfl
 	if err := sendHello(f, w); err != nil {
 		return errors.Wrap(err, "failed to send HELLO")
 	}
-	if err := sendSetConfig(f, w); err != nil {
-		return errors.Wrap(err, "failed to send SET_CONFIG")
-	}
 	if err := sendRemoveAllFlows(f, w); err != nil {
 		return errors.Wrap(err, "failed to send FLOW_MOD to remove all flows")
 	}
@@ -95,7 +95,16 @@ func (r *of10Session) OnError(f openflow.Factory, w transceiver.Writer, v openfl
 }
 
 func (r *of10Session) OnFeaturesReply(f openflow.Factory, w transceiver.Writer, v openflow.FeaturesReply) error {
+	// OpenFlow 1.0 switches send the unmatched packets to the controller without any table-miss flow, but
+	// the explicit one limits the bytes of the packets by its max_len.
+	if of10TableMiss() {
+		if err := setControllerTableMiss(f, w, 0); err != nil {
+			return errors.Wrap(err, "failed to set table_miss flow entry")
+		}
+	}
+
 	ports := v.Ports()
+	valid := make([]openflow.Port, 0, len(ports))
 	for _, p := range ports {
 		logger.Debugf("PortNum=%v, AdminUp=%v, LinkUp=%v", p.Number(), !p.IsPortDown(), !p.IsLinkDown())
 
@@ -105,6 +114,7 @@ func (r *of10Session) OnFeaturesReply(f openflow.Factory, w transceiver.Writer,
 		}
 
 		r.device.setPort(p.Number(), p)
+		valid = append(valid, p)
 
 		if !p.IsPortDown() && !p.IsLinkDown() {
 			// Send LLDP to update network topology
@@ -116,6 +126,10 @@ func (r *of10Session) OnFeaturesReply(f openflow.Factory, w transceiver.Writer,
 		}
 	}
 
+	if err := r.portConfig.configure(f, w, valid); err != nil {
+		logger.Errorf("failed to configure ports of %v: %v", r.device.ID(), err)
+	}
+
 	return nil
 }
 
@@ -132,6 +146,26 @@ func (r *of10Session) OnPortDescReply(f openflow.Factory, w transceiver.Writer,
 	return nil
 }
 
+func (r *of10Session) OnFlowStatsReply(f openflow.Factory, w transceiver.Writer, v openflow.FlowStatsReply) error {
+	return nil
+}
+
+func (r *of10Session) OnPortStatsReply(f openflow.Factory, w transceiver.Writer, v openflow.PortStatsReply) error {
+	return nil
+}
+
+func (r *of10Session) OnTableStatsReply(f openflow.Factory, w transceiver.Writer, v openflow.TableStatsReply) error {
+	return nil
+}
+
+func (r *of10Session) OnQueueGetConfigReply(f openflow.Factory, w transceiver.Writer, v openflow.QueueGetConfigReply) error {
+	return nil
+}
+
+func (r *of10Session) OnRoleReply(f openflow.Factory, w transceiver.Writer, v openflow.RoleReply) error {
+	return nil
+}
+
 func (r *of10Session) OnPortStatus(f openflow.Factory, w transceiver.Writer, v openflow.PortStatus) error {
 	return nil
 }
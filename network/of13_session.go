@@ -37,11 +37,14 @@ type of13Session struct {
 	// installed flows on the device have been removed, and then the ACL flow for
 	// ARP packes has been installed.
 	checkpoint bool
+	// Connect-time port configuration.
+	portConfig *portConfigurator
 }
 
 func newOF13Session(d *Device) *of13Session {
 	return &of13Session{
-		device: d,
+		device:     d,
+		portConfig: newPortConfigurator(),
 	}
 }
 
@@ -49,9 +52,6 @@ func (r *of13Session) OnHello(f openflow.Factory, w transceiver.Writer, v openfl
 	if err := sendHello(f, w); err != nil {
 		return errors.Wrap(err, "failed to send HELLO")
 	}
-	if err := sendSetConfig(f, w); err != nil {
-		return errors.Wrap(err, "failed to send SET_CONFIG")
-	}
 	if err := sendRemoveAllFlows(f, w); err != nil {
 		return errors.Wrap(err, "failed to send FLOW_MOD to remove all flows")
 	}
@@ -113,7 +113,9 @@ func isAS460054_T(msg openflow.DescReply) bool {
 	return strings.Contains(msg.Hardware(), "AS4600-54T")
 }
 
-func (r *of13Session) setTableMiss(f openflow.Factory, w transceiver.Writer, tableID uint8, inst openflow.Instruction) error {
+// setTableMiss installs the table-miss flow entry, which has the lowest priority and matches all packets, of
+// the table whose ID is tableID.
+func setTableMiss(f openflow.Factory, w transceiver.Writer, tableID uint8, inst openflow.Instruction) error {
 	match, err := f.NewMatch() // Wildcard
 	if err != nil {
 		return err
@@ -137,6 +139,25 @@ func (r *of13Session) setTableMiss(f openflow.Factory, w transceiver.Writer, tab
 	return w.Write(msg)
 }
 
+// setControllerTableMiss installs the table-miss flow entry of the table whose ID is tableID, which sends the
+// unmatched packets to the controller.
+func setControllerTableMiss(f openflow.Factory, w transceiver.Writer, tableID uint8) error {
+	inst, err := f.NewInstruction()
+	if err != nil {
+		return err
+	}
+	outPort := openflow.NewOutPort()
+	outPort.SetControllerMaxLen(tableMissMaxLen())
+	action, err := f.NewAction()
+	if err != nil {
+		return err
+	}
+	action.SetOutPort(outPort)
+	inst.ApplyAction(action)
+
+	return setTableMiss(f, w, tableID, inst)
+}
+
 func (r *of13Session) setHP2920TableMiss(f openflow.Factory, w transceiver.Writer) error {
 	// Table-100 is a hardware table, and Table-200 is a software table
 	// that has very low performance.
@@ -147,26 +168,17 @@ func (r *of13Session) setHP2920TableMiss(f openflow.Factory, w transceiver.Write
 
 	// 0 -> 100
 	inst.GotoTable(100)
-	if err := r.setTableMiss(f, w, 0, inst); err != nil {
+	if err := setTableMiss(f, w, 0, inst); err != nil {
 		return errors.Wrap(err, "failed to set table_miss flow entry")
 	}
 	// 100 -> 200
 	inst.GotoTable(200)
-	if err := r.setTableMiss(f, w, 100, inst); err != nil {
+	if err := setTableMiss(f, w, 100, inst); err != nil {
 		return errors.Wrap(err, "failed to set table_miss flow entry")
 	}
 
 	// 200 -> Controller
-	outPort := openflow.NewOutPort()
-	outPort.SetController()
-	action, err := f.NewAction()
-	if err != nil {
-		return err
-	}
-	action.SetOutPort(outPort)
-
-	inst.ApplyAction(action)
-	if err := r.setTableMiss(f, w, 200, inst); err != nil {
+	if err := setControllerTableMiss(f, w, 200); err != nil {
 		return errors.Wrap(err, "failed to set table_miss flow entry")
 	}
 	r.device.setFlowTableID(200)
@@ -184,22 +196,8 @@ func (r *of13Session) setAS4600TableMiss(f openflow.Factory, w transceiver.Write
 }
 
 func (r *of13Session) setDefaultTableMiss(f openflow.Factory, w transceiver.Writer) error {
-	inst, err := f.NewInstruction()
-	if err != nil {
-		return err
-	}
-
 	// 0 -> Controller
-	outPort := openflow.NewOutPort()
-	outPort.SetController()
-	action, err := f.NewAction()
-	if err != nil {
-		return err
-	}
-	action.SetOutPort(outPort)
-
-	inst.ApplyAction(action)
-	if err := r.setTableMiss(f, w, 0, inst); err != nil {
+	if err := setControllerTableMiss(f, w, 0); err != nil {
 		return errors.Wrap(err, "failed to set table_miss flow entry")
 	}
 	r.device.setFlowTableID(0)
@@ -234,6 +232,7 @@ func (r *of13Session) OnDescReply(f openflow.Factory, w transceiver.Writer, v op
 
 func (r *of13Session) OnPortDescReply(f openflow.Factory, w transceiver.Writer, v openflow.PortDescReply) error {
 	ports := v.Ports()
+	valid := make([]openflow.Port, 0, len(ports))
 	for _, p := range ports {
 		logger.Debugf("PortNum=%v, AdminUp=%v, LinkUp=%v", p.Number(), !p.IsPortDown(), !p.IsLinkDown())
 
@@ -243,6 +242,7 @@ func (r *of13Session) OnPortDescReply(f openflow.Factory, w transceiver.Writer,
 		}
 
 		r.device.setPort(p.Number(), p)
+		valid = append(valid, p)
 
 		if !p.IsPortDown() && !p.IsLinkDown() {
 			// Send LLDP to update network topology
@@ -254,6 +254,30 @@ func (r *of13Session) OnPortDescReply(f openflow.Factory, w transceiver.Writer,
 		}
 	}
 
+	if err := r.portConfig.configure(f, w, valid); err != nil {
+		logger.Errorf("failed to configure ports of %v: %v", r.device.ID(), err)
+	}
+
+	return nil
+}
+
+func (r *of13Session) OnFlowStatsReply(f openflow.Factory, w transceiver.Writer, v openflow.FlowStatsReply) error {
+	return nil
+}
+
+func (r *of13Session) OnPortStatsReply(f openflow.Factory, w transceiver.Writer, v openflow.PortStatsReply) error {
+	return nil
+}
+
+func (r *of13Session) OnTableStatsReply(f openflow.Factory, w transceiver.Writer, v openflow.TableStatsReply) error {
+	return nil
+}
+
+func (r *of13Session) OnQueueGetConfigReply(f openflow.Factory, w transceiver.Writer, v openflow.QueueGetConfigReply) error {
+	return nil
+}
+
+func (r *of13Session) OnRoleReply(f openflow.Factory, w transceiver.Writer, v openflow.RoleReply) error {
 	return nil
 }
 
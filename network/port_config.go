@@ -0,0 +1,196 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"encoding"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/transceiver"
+
+	"github.com/pkg/errors"
+	"github.com/superkkt/viper"
+)
+
+var portConfigNames = map[string]openflow.PortConfig{
+	"port_down":    openflow.PortConfigDown,
+	"no_stp":       openflow.PortConfigNoSTP,
+	"no_recv":      openflow.PortConfigNoRecv,
+	"no_recv_stp":  openflow.PortConfigNoRecvSTP,
+	"no_flood":     openflow.PortConfigNoFlood,
+	"no_fwd":       openflow.PortConfigNoFwd,
+	"no_packet_in": openflow.PortConfigNoPacketIn,
+}
+
+// ParsePortConfig converts the port config names used in the configuration
+// file, such as no_stp and no_flood, into a port config bitmap.
+func ParsePortConfig(names []string) (openflow.PortConfig, error) {
+	var config openflow.PortConfig
+	for _, v := range names {
+		c, ok := portConfigNames[strings.ToLower(strings.TrimSpace(v))]
+		if !ok {
+			return 0, fmt.Errorf("unknown port config: %v", v)
+		}
+		config |= c
+	}
+
+	return config, nil
+}
+
+// connectPortConfig returns the port config that should be applied to all the
+// ports of a switch when it connects to us.
+func connectPortConfig() openflow.PortConfig {
+	config, err := ParsePortConfig(viper.GetStringSlice("default.port_config"))
+	if err != nil {
+		// Should not happen because the config file is validated at startup.
+		logger.Errorf("invalid port config: %v", err)
+		return 0
+	}
+
+	return config
+}
+
+// portConfigNamesOf returns the names of the port configs in config.
+func portConfigNamesOf(config openflow.PortConfig) []string {
+	names := make([]string, 0)
+	for k, v := range portConfigNames {
+		if config&v != 0 {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// splitPortConfig splits config into the port configs supported by f and the unsupported ones, e.g., no_stp
+// that is only supported by OpenFlow 1.0.
+func splitPortConfig(f openflow.Factory, config openflow.PortConfig) (supported, unsupported openflow.PortConfig, err error) {
+	for bit := openflow.PortConfig(1); bit != 0 && bit <= config; bit <<= 1 {
+		if config&bit == 0 {
+			continue
+		}
+		mod, err := f.NewPortMod()
+		if err != nil {
+			return 0, 0, err
+		}
+		mod.SetConfig(bit, bit)
+		switch err := mod.Error(); {
+		case err == nil:
+			supported |= bit
+		case errors.Cause(err) == openflow.ErrUnsupportedPortConfig:
+			unsupported |= bit
+		default:
+			return 0, 0, err
+		}
+	}
+
+	return supported, unsupported, nil
+}
+
+// configurePorts applies config to all the ports and then sends a single
+// barrier request. OpenFlow does not allow a PORT_MOD to modify more than one
+// port, so all the flags for a port are combined into one message and the
+// messages are coalesced into a single write if w supports batching.
+func configurePorts(f openflow.Factory, w transceiver.Writer, ports []openflow.Port, config openflow.PortConfig) error {
+	if config == 0 || len(ports) == 0 {
+		return nil
+	}
+
+	msgs := make([]encoding.BinaryMarshaler, 0, len(ports)+1)
+	for _, p := range ports {
+		mod, err := f.NewPortMod()
+		if err != nil {
+			return err
+		}
+		mod.SetPortNumber(p.Number())
+		mod.SetHWAddr(p.MAC())
+		mod.SetConfig(config, config)
+		if err := mod.Error(); err != nil {
+			return err
+		}
+		msgs = append(msgs, mod)
+	}
+
+	barrier, err := f.NewBarrierRequest()
+	if err != nil {
+		return err
+	}
+	msgs = append(msgs, barrier)
+
+	if bw, ok := w.(transceiver.BatchWriter); ok {
+		return bw.WriteBatch(msgs)
+	}
+	for _, v := range msgs {
+		if err := w.Write(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// portConfigurator applies the connect-time port config to the ports of a
+// switch. The ports are reported repeatedly by our device explorer, so it
+// remembers the configured ports and only sends PORT_MODs for new ones.
+type portConfigurator struct {
+	configured map[uint32]struct{}
+	// Whether the unsupported port configs have been reported.
+	warned bool
+}
+
+func newPortConfigurator() *portConfigurator {
+	return &portConfigurator{
+		configured: make(map[uint32]struct{}),
+	}
+}
+
+func (r *portConfigurator) configure(f openflow.Factory, w transceiver.Writer, ports []openflow.Port) error {
+	pending := make([]openflow.Port, 0, len(ports))
+	for _, p := range ports {
+		if _, ok := r.configured[p.Number()]; ok {
+			continue
+		}
+		pending = append(pending, p)
+	}
+
+	// The unsupported port configs are skipped, and reported only once for a switch.
+	config, unsupported, err := splitPortConfig(f, connectPortConfig())
+	if err != nil {
+		return err
+	}
+	if unsupported != 0 && !r.warned {
+		logger.Warningf("skipping the port configs unsupported by OpenFlow version 0x%X: %v", f.ProtocolVersion(), portConfigNamesOf(unsupported))
+		r.warned = true
+	}
+	if err := configurePorts(f, w, pending, config); err != nil {
+		return err
+	}
+	for _, p := range pending {
+		r.configured[p.Number()] = struct{}{}
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"encoding"
+	"encoding/binary"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
+
+	"github.com/superkkt/viper"
+)
+
+type batchRecorder struct {
+	writes [][]encoding.BinaryMarshaler
+}
+
+func (r *batchRecorder) Write(msg encoding.BinaryMarshaler) error {
+	r.writes = append(r.writes, []encoding.BinaryMarshaler{msg})
+	return nil
+}
+
+func (r *batchRecorder) WriteBatch(msgs []encoding.BinaryMarshaler) error {
+	r.writes = append(r.writes, msgs)
+	return nil
+}
+
+// newOF10FeaturesReply returns a FEATURES_REPLY packet that has nPorts ports
+// numbered from 1.
+func newOF10FeaturesReply(nPorts int) []byte {
+	length := 32 + nPorts*48
+	packet := make([]byte, length)
+	packet[0] = openflow.OF10_VERSION
+	packet[1] = of10.OFPT_FEATURES_REPLY
+	binary.BigEndian.PutUint16(packet[2:4], uint16(length))
+	binary.BigEndian.PutUint64(packet[8:16], 1)
+	for i := 0; i < nPorts; i++ {
+		p := packet[32+i*48:]
+		binary.BigEndian.PutUint16(p[0:2], uint16(i+1))
+		copy(p[2:8], []byte{0x00, 0x01, 0x02, 0x03, byte(i >> 8), byte(i)})
+	}
+
+	return packet
+}
+
+func TestConfigurePorts(t *testing.T) {
+	f := of10.NewFactory()
+	reply, err := f.NewFeaturesReply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reply.UnmarshalBinary(newOF10FeaturesReply(256)); err != nil {
+		t.Fatal(err)
+	}
+	ports := reply.Ports()
+	if len(ports) != 256 {
+		t.Fatalf("unexpected number of ports: expected=256, got=%v", len(ports))
+	}
+
+	w := new(batchRecorder)
+	config := openflow.PortConfigNoSTP | openflow.PortConfigNoFlood
+	if err := configurePorts(f, w, ports, config); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.writes) != 1 {
+		t.Fatalf("unexpected number of writes: expected=1, got=%v", len(w.writes))
+	}
+
+	msgs := w.writes[0]
+	if len(msgs) != len(ports)+1 {
+		t.Fatalf("unexpected number of messages: expected=%v, got=%v", len(ports)+1, len(msgs))
+	}
+	barriers := 0
+	for i, v := range msgs {
+		mod, ok := v.(openflow.PortMod)
+		if !ok {
+			if _, ok := v.(*of10.BarrierRequest); !ok {
+				t.Fatalf("#%v: unexpected message: %T", i, v)
+			}
+			barriers++
+			continue
+		}
+		if mod.PortNumber() != ports[i].Number() {
+			t.Fatalf("#%v: unexpected port number: expected=%v, got=%v", i, ports[i].Number(), mod.PortNumber())
+		}
+		if mod.HWAddr().String() != ports[i].MAC().String() {
+			t.Fatalf("#%v: unexpected hardware address: expected=%v, got=%v", i, ports[i].MAC(), mod.HWAddr())
+		}
+		if mod.Config() != config || mod.Mask() != config {
+			t.Fatalf("#%v: unexpected port config: config=%v, mask=%v", i, mod.Config(), mod.Mask())
+		}
+	}
+	if barriers != 1 {
+		t.Fatalf("unexpected number of barriers: expected=1, got=%v", barriers)
+	}
+	if _, ok := msgs[len(msgs)-1].(*of10.BarrierRequest); !ok {
+		t.Fatalf("barrier should be the last message: got=%T", msgs[len(msgs)-1])
+	}
+}
+
+func TestConfigurePortsNothing(t *testing.T) {
+	f := of10.NewFactory()
+	reply, err := f.NewFeaturesReply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reply.UnmarshalBinary(newOF10FeaturesReply(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	w := new(batchRecorder)
+	if err := configurePorts(f, w, reply.Ports(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.writes) != 0 {
+		t.Fatalf("unexpected writes: %v", len(w.writes))
+	}
+}
+
+func TestConfigurePortsUnsupported(t *testing.T) {
+	f := of13.NewFactory()
+	reply, err := of10.NewFactory().NewFeaturesReply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reply.UnmarshalBinary(newOF10FeaturesReply(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	w := new(batchRecorder)
+	// OpenFlow 1.3 does not have the NO_STP port config.
+	if err := configurePorts(f, w, reply.Ports(), openflow.PortConfigNoSTP); err == nil {
+		t.Fatal("expected an error for the unsupported port config")
+	}
+	if len(w.writes) != 0 {
+		t.Fatalf("unexpected writes: %v", len(w.writes))
+	}
+}
+
+func TestParsePortConfig(t *testing.T) {
+	src := []struct {
+		Names    []string
+		Expected openflow.PortConfig
+		Error    bool
+	}{
+		{Names: nil, Expected: 0},
+		{Names: []string{"no_stp"}, Expected: openflow.PortConfigNoSTP},
+		{Names: []string{"NO_STP", " no_flood "}, Expected: openflow.PortConfigNoSTP | openflow.PortConfigNoFlood},
+		{Names: []string{"no_packet_in", "port_down"}, Expected: openflow.PortConfigNoPacketIn | openflow.PortConfigDown},
+		{Names: []string{"no_stp", "unknown"}, Error: true},
+	}
+
+	for i, v := range src {
+		config, err := ParsePortConfig(v.Names)
+		if v.Error {
+			if err == nil {
+				t.Fatalf("#%v: expected an error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if config != v.Expected {
+			t.Fatalf("#%v: unexpected config: expected=%v, got=%v", i, v.Expected, config)
+		}
+	}
+}
+
+func TestPortConfiguratorUnsupported(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("default.port_config", []string{"no_stp", "no_packet_in"})
+
+	reply, err := of10.NewFactory().NewFeaturesReply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reply.UnmarshalBinary(newOF10FeaturesReply(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	src := []struct {
+		Factory  openflow.Factory
+		Expected openflow.PortConfig
+	}{
+		{Factory: of10.NewFactory(), Expected: openflow.PortConfigNoSTP | openflow.PortConfigNoPacketIn},
+		// OpenFlow 1.3 does not have the NO_STP port config, so it is skipped.
+		{Factory: of13.NewFactory(), Expected: openflow.PortConfigNoPacketIn},
+	}
+
+	for i, v := range src {
+		w := new(batchRecorder)
+		c := newPortConfigurator()
+		if err := c.configure(v.Factory, w, reply.Ports()); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if len(w.writes) != 1 || len(w.writes[0]) != 5 {
+			t.Fatalf("#%v: unexpected writes: %v", i, w.writes)
+		}
+		for _, msg := range w.writes[0][:4] {
+			mod, ok := msg.(openflow.PortMod)
+			if !ok {
+				t.Fatalf("#%v: unexpected message: %v", i, msg)
+			}
+			if config, mask := mod.Config(), mod.Mask(); config != v.Expected || mask != v.Expected {
+				t.Fatalf("#%v: unexpected port config: expected=%v, got=%v/%v", i, v.Expected, config, mask)
+			}
+		}
+		if v.Factory.ProtocolVersion() == openflow.OF13_VERSION && !c.warned {
+			t.Fatalf("#%v: unsupported port config is not reported", i)
+		}
+	}
+}
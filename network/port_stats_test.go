@@ -0,0 +1,78 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+func TestPortStatsUtilization(t *testing.T) {
+	d := new(Device)
+	if _, ok := d.PortStats(1); ok {
+		t.Fatal("expected no stats before polling")
+	}
+
+	now := time.Now()
+	d.updatePortStats([]openflow.PortStat{{PortNo: 1, RxBytes: 1000, TxBytes: 2000}}, now)
+	s, ok := d.PortStats(1)
+	if !ok || s.RxBytes != 1000 || s.RxBitsPerSec != 0 || s.TxBitsPerSec != 0 {
+		t.Fatalf("unexpected first sample: %+v", s)
+	}
+
+	d.updatePortStats([]openflow.PortStat{{PortNo: 1, RxBytes: 2000, TxBytes: 2500}}, now.Add(2*time.Second))
+	s, _ = d.PortStats(1)
+	if s.RxBitsPerSec != 4000 || s.TxBitsPerSec != 2000 {
+		t.Fatalf("unexpected utilization: rx=%v, tx=%v", s.RxBitsPerSec, s.TxBitsPerSec)
+	}
+	if _, ok := d.PortStats(2); ok {
+		t.Fatal("expected no stats for the unknown port")
+	}
+}
+
+func TestCounterDelta(t *testing.T) {
+	src := []struct {
+		prev, cur uint64
+		delta     uint64
+		ok        bool
+	}{
+		{10, 30, 20, true},
+		{10, 10, 0, true},
+		// 32-bit counter wrapped around.
+		{math.MaxUint32 - 9, 10, 20, true},
+		// 64-bit counter has been reset.
+		{math.MaxUint32 + 100, 10, 0, false},
+		// Unsupported counter.
+		{math.MaxUint64, math.MaxUint64, 0, false},
+		{10, math.MaxUint64, 0, false},
+	}
+
+	for i, v := range src {
+		delta, ok := counterDelta(v.prev, v.cur)
+		if ok != v.ok || delta != v.delta {
+			t.Fatalf("#%v: expected=(%v, %v), got=(%v, %v)", i, v.delta, v.ok, delta, ok)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestPortValues(t *testing.T) {
+	fake := NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 3, 1, 2)
+
+	values := sw.PortValues()
+	if len(values) != 3 {
+		t.Fatalf("unexpected number of ports: %v", len(values))
+	}
+	for i, v := range values {
+		if v.Number() != uint32(i+1) {
+			t.Fatalf("#%v: unexpected port order: %v", i, v.Number())
+		}
+	}
+	if _, ok := sw.PortValue(4); ok {
+		t.Fatalf("unknown port is found")
+	}
+
+	// The snapshot is not affected by the following updates.
+	v, ok := sw.PortValue(1)
+	if !ok {
+		t.Fatalf("port 1 is not found")
+	}
+	sw.setPort(1, &fakePort{number: 1})
+	if latest, _ := sw.PortValue(1); latest == v {
+		t.Fatalf("the port status is not replaced")
+	}
+}
+
+// TestConcurrentPortAccess should be run with the race detector.
+func TestConcurrentPortAccess(t *testing.T) {
+	fake := NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	// PORT_STATUS messages updating the existing ports and adding new ones.
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			num := uint32(i%8 + 1)
+			sw.setPort(num, &fakePort{number: num})
+		}
+	}()
+	// Readers such as the REST API and the statistics pollers.
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			for _, v := range sw.PortValues() {
+				v.Number()
+			}
+			sw.PortValue(uint32(i%8 + 1))
+			for _, p := range sw.Ports() {
+				p.Value()
+			}
+		}
+	}()
+	wg.Wait()
+
+	if n := len(sw.PortValues()); n != 8 {
+		t.Fatalf("unexpected number of ports: %v", n)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"encoding/binary"
+	"sort"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+type portUpRecorder struct {
+	nopControllerListener
+	ports []uint32
+}
+
+func (r *portUpRecorder) OnPortUp(finder Finder, port *Port) error {
+	r.ports = append(r.ports, port.Number())
+	return nil
+}
+
+func (r *portUpRecorder) get() []uint32 {
+	defer func() { r.ports = nil }()
+	sort.Slice(r.ports, func(i, j int) bool { return r.ports[i] < r.ports[j] })
+	return r.ports
+}
+
+// newTestPortDescReply returns a PORT_DESC reply that has the ports whose link state is specified by up.
+func newTestPortDescReply(t *testing.T, up map[uint32]bool) openflow.PortDescReply {
+	length := 16 + len(up)*64
+	packet := make([]byte, length)
+	packet[0] = openflow.OF13_VERSION
+	packet[1] = of13.OFPT_MULTIPART_REPLY
+	binary.BigEndian.PutUint16(packet[2:4], uint16(length))
+	binary.BigEndian.PutUint16(packet[8:10], of13.OFPMP_PORT_DESC)
+	i := 0
+	for num, v := range up {
+		port := packet[16+i*64:]
+		binary.BigEndian.PutUint32(port[0:4], num)
+		if !v {
+			binary.BigEndian.PutUint32(port[36:40], of13.OFPPS_LINK_DOWN)
+		}
+		i++
+	}
+
+	reply, err := of13.NewFactory().NewPortDescReply()
+	if err != nil {
+		t.Fatalf("failed to create PORT_DESC reply: %v", err)
+	}
+	if err := reply.UnmarshalBinary(packet); err != nil {
+		t.Fatalf("failed to decode PORT_DESC reply: %v", err)
+	}
+
+	return reply
+}
+
+func TestPortUpAfterPortDesc(t *testing.T) {
+	network := NewFakeNetwork()
+	listener := new(portUpRecorder)
+	s := &session{
+		negotiated: true,
+		watcher:    network.topology,
+		finder:     network,
+		listener:   listener,
+		tracker:    newDPIDTracker(),
+		intents:    newIntentStore(0),
+		remote:     "10.0.0.1:50001",
+		writer:     new(messageRecorder),
+	}
+	s.device = newDevice(s)
+	s.device.setFactory(of13.NewFactory())
+	s.device.setID("1")
+	s.handler = newOF13Session(s.device)
+
+	src := []struct {
+		ports    map[uint32]bool
+		expected []uint32
+	}{
+		// The ports are known after the device up event.
+		{ports: map[uint32]bool{1: true, 2: false, 3: true}, expected: []uint32{1, 3}},
+		// Periodic probe of the device explorer without any change.
+		{ports: map[uint32]bool{1: true, 2: false, 3: true}, expected: []uint32{}},
+		// Port 2 comes up and port 3 goes down.
+		{ports: map[uint32]bool{1: true, 2: true, 3: false}, expected: []uint32{2}},
+	}
+
+	for i, v := range src {
+		if err := s.OnPortDescReply(of13.NewFactory(), s.writer, newTestPortDescReply(t, v.ports)); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		ports := listener.get()
+		if len(ports) != len(v.expected) {
+			t.Fatalf("#%v: unexpected port up events: expected=%v, got=%v", i, v.expected, ports)
+		}
+		for j := range ports {
+			if ports[j] != v.expected[j] {
+				t.Fatalf("#%v: unexpected port up events: expected=%v, got=%v", i, v.expected, ports)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// PriorityBand is an inclusive range of the flow priorities that an application uses for its normal flows.
+type PriorityBand struct {
+	Min, Max uint16
+}
+
+// Standard priority bands. The priorities of the flows are ordered as follows so that a flow in a higher
+// band always overrides the overlapping flows in the lower bands regardless of which application installed
+// them first, e.g., the deny rules of a firewall override the forwarding rules of L2Switch.
+//
+//	0       Table-miss flow
+//	1       Flows dropping the PACKET_INs from a throttled port
+//	2-19    PriorityBandForwarding: L2Switch (10 by default) and ECMP (15 by default)
+//	20      PriorityBandMulticast: multicast flows
+//	21-29   PriorityBandPolicy: access control rules such as the deny rules of a firewall
+//	30      Quarantine flows
+//	50-100  Special flows such as the temporary drop-all flow and the ones for the LLDP, ARP and DHCP packets
+var (
+	PriorityBandForwarding = PriorityBand{Min: 2, Max: 19}
+	PriorityBandPolicy     = PriorityBand{Min: 21, Max: 29}
+	PriorityBandMulticast  = PriorityBand{Min: 20, Max: 20}
+)
+
+// ErrPriorityOutOfBand is the cause of the error returned when a flow has a priority out of the priority
+// band of its owner application.
+var ErrPriorityOutOfBand = errors.New("flow priority out of the priority band")
+
+// Contains returns whether priority is in this band.
+func (r PriorityBand) Contains(priority uint16) bool {
+	return priority >= r.Min && priority <= r.Max
+}
+
+// Validate returns an error whose cause is ErrPriorityOutOfBand if priority is out of this band.
+func (r PriorityBand) Validate(priority uint16) error {
+	if !r.Contains(priority) {
+		return errors.Wrapf(ErrPriorityOutOfBand, "priority %v (band %v)", priority, r)
+	}
+
+	return nil
+}
+
+func (r PriorityBand) String() string {
+	return fmt.Sprintf("%v-%v", r.Min, r.Max)
+}
+
+// SetPriorityBand declares the priority band of the normal flows of owner. The flows of an application that
+// has not declared its band should be in PriorityBandForwarding.
+func SetPriorityBand(owner AppCookie, band PriorityBand) error {
+	if band.Min == 0 || band.Min > band.Max {
+		return fmt.Errorf("invalid priority band: %v", band)
+	}
+
+	appCookies.Lock()
+	defer appCookies.Unlock()
+	appCookies.bands[owner] = band
+
+	return nil
+}
+
+// PriorityBand returns the priority band declared by SetPriorityBand for the application.
+func (r AppCookie) PriorityBand() PriorityBand {
+	appCookies.Lock()
+	defer appCookies.Unlock()
+
+	if v, ok := appCookies.bands[r]; ok {
+		return v
+	}
+
+	return PriorityBandForwarding
+}
+
+// ValidatePriority returns an error whose cause is ErrPriorityOutOfBand if priority is out of the priority
+// band of the application.
+func (r AppCookie) ValidatePriority(priority uint16) error {
+	band := r.PriorityBand()
+	if !band.Contains(priority) {
+		return errors.Wrapf(ErrPriorityOutOfBand, "priority %v (band %v, cookie 0x%X)", priority, band, r.Value())
+	}
+
+	return nil
+}
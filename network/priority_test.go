@@ -0,0 +1,114 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+
+	"github.com/pkg/errors"
+)
+
+func TestPriorityBand(t *testing.T) {
+	forwarding, err := RegisterAppCookie("TestBandForwarding")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	firewall, err := RegisterAppCookie("TestBandFirewall")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := SetPriorityBand(firewall, PriorityBandPolicy); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The firewall rules should override the forwarding rules.
+	if PriorityBandPolicy.Min <= PriorityBandForwarding.Max {
+		t.Fatalf("policy band %v is not above forwarding band %v", PriorityBandPolicy, PriorityBandForwarding)
+	}
+
+	fake := NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	port := openflow.NewOutPort()
+	port.SetValue(2)
+
+	src := []struct {
+		Owner    AppCookie
+		Priority uint16
+		Valid    bool
+	}{
+		// The applications that have not declared their bands are in the forwarding band.
+		{Owner: forwarding, Priority: DefaultFlowOptions.Priority, Valid: true},
+		{Owner: forwarding, Priority: PriorityBandForwarding.Max, Valid: true},
+		{Owner: forwarding, Priority: PriorityBandPolicy.Min, Valid: false},
+		{Owner: forwarding, Priority: 0, Valid: false},
+		{Owner: firewall, Priority: PriorityBandPolicy.Min, Valid: true},
+		{Owner: firewall, Priority: PriorityBandPolicy.Max, Valid: true},
+		{Owner: firewall, Priority: DefaultFlowOptions.Priority, Valid: false},
+		{Owner: firewall, Priority: 100, Valid: false},
+	}
+
+	for i, v := range src {
+		match, err := sw1.Factory().NewMatch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		match.SetDstMAC(net.HardwareAddr{0, 0, 0, 0, 0, byte(i + 1)})
+		opts := DefaultFlowOptions
+		opts.Priority = v.Priority
+
+		sw1.Reset()
+		err = sw1.SetFlowWithOptions(v.Owner, match, port, opts)
+		dropErr := sw1.SetDropFlow(v.Owner, match, v.Priority, time.Minute)
+		classErr := sw1.SetClassificationFlow(v.Owner, match, v.Priority, false)
+		if v.Valid {
+			if err != nil || dropErr != nil || classErr != nil {
+				t.Fatalf("#%v: unexpected error: %v, %v, %v", i, err, dropErr, classErr)
+			}
+			continue
+		}
+		if errors.Cause(err) != ErrPriorityOutOfBand || errors.Cause(dropErr) != ErrPriorityOutOfBand || errors.Cause(classErr) != ErrPriorityOutOfBand {
+			t.Fatalf("#%v: unexpected error: expected=%v, got=%v, %v, %v", i, ErrPriorityOutOfBand, err, dropErr, classErr)
+		}
+		if n := len(sw1.FlowMods()); n != 0 {
+			t.Fatalf("#%v: %v out-of-band flows are sent to the switch", i, n)
+		}
+	}
+}
+
+func TestInvalidPriorityBand(t *testing.T) {
+	owner, err := RegisterAppCookie("TestBandInvalid")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, v := range []PriorityBand{{Min: 0, Max: 10}, {Min: 20, Max: 10}} {
+		if err := SetPriorityBand(owner, v); err == nil {
+			t.Fatalf("#%v: expected an error for band %v, but got nil", i, v)
+		}
+	}
+	if band := owner.PriorityBand(); band != PriorityBandForwarding {
+		t.Fatalf("unexpected priority band: expected=%v, got=%v", PriorityBandForwarding, band)
+	}
+}
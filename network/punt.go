@@ -0,0 +1,56 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"hash/fnv"
+
+	"github.com/superkkt/cherry/protocol"
+)
+
+const (
+	// Punt flows have the MSB of their cookie so that RemoveFlows does not remove them.
+	puntCookieMask = 0x1<<63 | 0x1<<61
+	puntAppIDMask  = 0xFFFFFFFF
+)
+
+// PuntEventListener receives the PACKET_INs punted to the controller by the flows installed
+// by Device.SetPuntFlow. cookie is the one that was used to install the punt flow.
+//
+// NOTE: OpenFlow 1.0 does not have a cookie in PACKET_IN, so the punted packets from OpenFlow 1.0
+// switches are delivered through the normal OnPacketIn event.
+type PuntEventListener interface {
+	OnPuntedPacketIn(finder Finder, ingress *Port, eth *protocol.Ethernet, info PacketInInfo, cookie uint64) error
+}
+
+// PuntCookie returns the cookie for the punt flows of an application whose name is appName.
+func PuntCookie(appName string) uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(appName))
+
+	return puntCookieMask | uint64(h.Sum32())
+}
+
+// IsPuntCookie returns whether cookie belongs to a punt flow.
+func IsPuntCookie(cookie uint64) bool {
+	return cookie&puntCookieMask == puntCookieMask && cookie&^(puntCookieMask|puntAppIDMask) == 0
+}
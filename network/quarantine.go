@@ -0,0 +1,79 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+const (
+	// Quarantine flows have the MSB of their cookie so that RemoveFlows does not remove them
+	// on topology changes. They are only removed when their hard timeout expires.
+	quarantineCookie = 0x1<<63 | 0x1<<62
+	// Higher than the normal flows, and lower than the special flows for ARP, LLDP, and DHCP.
+	quarantinePriority = 30
+	// Maximum hard timeout that a flow entry can have.
+	maxHardTimeout = time.Duration(0xFFFF) * time.Second
+)
+
+// IsQuarantineFlow returns whether cookie belongs to a quarantine flow.
+func IsQuarantineFlow(cookie uint64) bool {
+	return cookie&quarantineCookie == quarantineCookie
+}
+
+// isQuarantineLifted returns whether the removed flow means that a quarantine is lifted by its hard timeout.
+func isQuarantineLifted(flow openflow.FlowRemoved) bool {
+	return IsQuarantineFlow(flow.Cookie()) && flow.Reason() == openflow.FlowRemovedHardTimeout
+}
+
+// newQuarantineFlow returns a flow that drops all the packets from mac until timeout expires. The VLAN ID is
+// wildcarded so that the tagged frames of mac, which are forwarded by the per-VLAN flows, are also dropped.
+func newQuarantineFlow(f openflow.Factory, mac net.HardwareAddr, tableID uint8, timeout time.Duration) (openflow.FlowMod, error) {
+	if timeout < time.Second || timeout > maxHardTimeout {
+		return nil, fmt.Errorf("invalid quarantine timeout: %v", timeout)
+	}
+
+	match, err := f.NewMatch()
+	if err != nil {
+		return nil, err
+	}
+	match.SetSrcMAC(mac)
+
+	flow, err := f.NewFlowMod(openflow.FlowAdd)
+	if err != nil {
+		return nil, err
+	}
+	flow.SetCookie(quarantineCookie)
+	flow.SetTableID(tableID)
+	flow.SetHardTimeout(uint16(timeout / time.Second))
+	flow.SetPriority(quarantinePriority)
+	// FLOW_REMOVED tells us that the quarantine is lifted.
+	flow.SetFlags(openflow.FlowModFlags{SendFlowRemoved: true})
+	// No instruction means dropping the matched packets.
+	flow.SetFlowMatch(match)
+
+	return flow, nil
+}
@@ -0,0 +1,187 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
+	"github.com/superkkt/cherry/protocol"
+)
+
+type dummyFlowRemoved struct {
+	openflow.FlowRemoved
+	cookie uint64
+	reason uint8
+}
+
+func (r *dummyFlowRemoved) Cookie() uint64 {
+	return r.cookie
+}
+
+func (r *dummyFlowRemoved) Reason() uint8 {
+	return r.reason
+}
+
+func TestQuarantineFlow(t *testing.T) {
+	mac := net.HardwareAddr([]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+
+	src := []struct {
+		Factory       openflow.Factory
+		Timeout       time.Duration
+		HardTimeout   uint16
+		ErrorExpected bool
+	}{
+		{Factory: of13.NewFactory(), Timeout: 5 * time.Minute, HardTimeout: 300},
+		{Factory: of10.NewFactory(), Timeout: 1500 * time.Millisecond, HardTimeout: 1},
+		{Factory: of13.NewFactory(), Timeout: maxHardTimeout, HardTimeout: 0xFFFF},
+		{Factory: of13.NewFactory(), Timeout: 0, ErrorExpected: true},
+		{Factory: of13.NewFactory(), Timeout: maxHardTimeout + time.Second, ErrorExpected: true},
+	}
+
+	for _, v := range src {
+		flow, err := newQuarantineFlow(v.Factory, mac, 0, v.Timeout)
+		if err != nil {
+			if v.ErrorExpected {
+				continue
+			}
+			t.Fatalf("unexpected error: timeout=%v, err=%v", v.Timeout, err)
+		}
+		if v.ErrorExpected {
+			t.Fatalf("expected error, but got nil: timeout=%v", v.Timeout)
+		}
+
+		if flow.HardTimeout() != v.HardTimeout {
+			t.Fatalf("unexpected hard timeout: expected=%v, got=%v", v.HardTimeout, flow.HardTimeout())
+		}
+		if flow.IdleTimeout() != 0 {
+			t.Fatalf("unexpected idle timeout: %v", flow.IdleTimeout())
+		}
+		if !IsQuarantineFlow(flow.Cookie()) {
+			t.Fatalf("unexpected cookie: %x", flow.Cookie())
+		}
+		// RemoveFlows should not remove the quarantine flows.
+		if flow.Cookie()&(0x1<<63) == 0 {
+			t.Fatalf("quarantine cookie should have the MSB: %x", flow.Cookie())
+		}
+		if flow.FlowInstruction() != nil {
+			t.Fatalf("quarantine flow should drop the packets")
+		}
+		if !flow.Flags().SendFlowRemoved {
+			t.Fatalf("quarantine flow should be reported when it is lifted")
+		}
+		if wildcard, v := flow.FlowMatch().SrcMAC(); wildcard || v.String() != mac.String() {
+			t.Fatalf("unexpected source MAC: wildcard=%v, mac=%v", wildcard, v)
+		}
+		if err := openflow.ValidateFlowMod(flow); err != nil {
+			t.Fatalf("invalid quarantine flow: %v", err)
+		}
+	}
+}
+
+func TestQuarantineTaggedFrame(t *testing.T) {
+	mac := net.HardwareAddr([]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+	// A tagged frame whose VLAN is not the default one of the device.
+	eth := &protocol.Ethernet{
+		SrcMAC: mac,
+		DstMAC: net.HardwareAddr([]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x66}),
+		VLANs:  []protocol.VLANTag{{ID: 10}},
+		Type:   0x0800,
+	}
+	tag, _ := eth.VLAN()
+
+	for i, f := range []openflow.Factory{of10.NewFactory(), of13.NewFactory()} {
+		fake := NewFakeNetwork()
+		sw := fake.AddSwitch("1", f, 1, 2)
+		if err := sw.Quarantine(eth.SrcMAC, time.Minute); err != nil {
+			t.Fatalf("#%v: failed to quarantine: %v", i, err)
+		}
+		flows := sw.FlowMods()
+		if len(flows) != 1 {
+			t.Fatalf("#%v: unexpected flows: %v", i, flows)
+		}
+		match := flows[0].FlowMatch()
+		if wildcard, vlanID := match.VLANID(); !wildcard && vlanID != tag.ID {
+			t.Fatalf("#%v: tagged frame of VLAN %v is not dropped: flow VLAN=%v", i, tag.ID, vlanID)
+		}
+		if wildcard, v := match.SrcMAC(); wildcard || v.String() != eth.SrcMAC.String() {
+			t.Fatalf("#%v: unexpected source MAC: wildcard=%v, mac=%v", i, wildcard, v)
+		}
+	}
+}
+
+func TestControllerQuarantine(t *testing.T) {
+	mac := net.HardwareAddr([]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+
+	fake := NewFakeNetwork()
+	switches := []*FakeSwitch{
+		fake.AddSwitch("1", of13.NewFactory(), 1),
+		fake.AddSwitch("2", of13.NewFactory(), 1),
+		fake.AddSwitch("3", of13.NewFactory(), 1),
+	}
+	// A closed device does not prevent the others from quarantining the host.
+	switches[1].Close()
+	controller := &Controller{topo: fake.topology}
+
+	if err := controller.Quarantine(mac, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, sw := range switches {
+		expected := 1
+		if sw.IsClosed() {
+			expected = 0
+		}
+		if n := len(sw.FlowMods()); n != expected {
+			t.Fatalf("#%v: unexpected number of the quarantine flows: expected=%v, got=%v", i, expected, n)
+		}
+	}
+
+	// An invalid timeout fails on all the devices.
+	if err := controller.Quarantine(mac, 0); err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+}
+
+func TestQuarantineExpiry(t *testing.T) {
+	src := []struct {
+		Cookie   uint64
+		Reason   uint8
+		Expected bool
+	}{
+		{Cookie: quarantineCookie, Reason: openflow.FlowRemovedHardTimeout, Expected: true},
+		{Cookie: quarantineCookie, Reason: openflow.FlowRemovedIdleTimeout, Expected: false},
+		{Cookie: quarantineCookie, Reason: openflow.FlowRemovedDelete, Expected: false},
+		{Cookie: 0, Reason: openflow.FlowRemovedHardTimeout, Expected: false},
+		{Cookie: 0x1 << 63, Reason: openflow.FlowRemovedHardTimeout, Expected: false},
+	}
+
+	for _, v := range src {
+		flow := &dummyFlowRemoved{cookie: v.Cookie, reason: v.Reason}
+		if isQuarantineLifted(flow) != v.Expected {
+			t.Fatalf("unexpected result: cookie=%x, reason=%v, expected=%v", v.Cookie, v.Reason, v.Expected)
+		}
+	}
+}
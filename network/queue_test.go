@@ -0,0 +1,91 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestQueueValidation(t *testing.T) {
+	f := of13.NewFactory()
+	d := &Device{
+		factory: f,
+		ports:   map[uint32]*Port{1: new(Port), 2: new(Port)},
+	}
+	d.setQueues(1, []uint32{0, 3})
+
+	src := []struct {
+		port    uint32
+		enqueue bool
+		queueID uint32
+		err     bool
+	}{
+		{1, false, 0, false},
+		{1, true, 0, false},
+		{1, true, 3, false},
+		{1, true, 4, true},
+		// Queues of port 2 have not been discovered.
+		{2, true, 0, true},
+	}
+
+	for i, v := range src {
+		action, err := f.NewAction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		port := openflow.NewOutPort()
+		port.SetValue(v.port)
+		action.SetOutPort(port)
+		if v.enqueue {
+			action.SetQueue(v.queueID)
+		}
+		inst, err := f.NewInstruction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		inst.ApplyAction(action)
+		match, err := f.NewMatch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		flow, err := f.NewFlowMod(openflow.FlowAdd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		flow.SetFlowMatch(match)
+		flow.SetFlowInstruction(inst)
+
+		if err := d.validateFlowMod(flow); (err != nil) != v.err {
+			t.Fatalf("#%v: unexpected result: expected error=%v, got=%v", i, v.err, err)
+		}
+	}
+
+	if ids, ok := d.Queues(1); !ok || len(ids) != 2 {
+		t.Fatalf("unexpected queues: ok=%v, ids=%v", ok, ids)
+	}
+	if _, ok := d.Queues(2); ok {
+		t.Fatal("unexpected queues of port 2")
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestRemoveTaggedFlows(t *testing.T) {
+	owner, err := RegisterAppCookie("TaggedFlowTestApp")
+	if err != nil {
+		t.Fatalf("failed to register the cookie: %v", err)
+	}
+	fake := NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	mac := net.HardwareAddr{0, 0, 0, 0, 0, 1}
+	port := openflow.NewOutPort()
+	port.SetValue(2)
+
+	remove := []func() error{
+		func() error { return sw.RemoveFlowByMAC(mac) },
+		func() error { return sw.RemoveAppFlows(owner) },
+		func() error { return sw.RemoveFlows() },
+	}
+	for i, f := range remove {
+		// Flow of a packet tagged with a VLAN other than the default one.
+		match, err := sw.Factory().NewMatch()
+		if err != nil {
+			t.Fatalf("#%v: failed to create a match: %v", i, err)
+		}
+		match.SetVLANID(100)
+		match.SetDstMAC(mac)
+		if err := sw.SetFlow(owner, match, port); err != nil {
+			t.Fatalf("#%v: failed to install the flow: %v", i, err)
+		}
+
+		sw.Reset()
+		if err := f(); err != nil {
+			t.Fatalf("#%v: failed to remove the flows: %v", i, err)
+		}
+		flows := sw.FlowMods()
+		if len(flows) != 1 {
+			t.Fatalf("#%v: unexpected number of the delete requests: %v", i, len(flows))
+		}
+		// The tagged flow should be removed as well.
+		if wildcard, id := flows[0].FlowMatch().VLANID(); !wildcard {
+			t.Fatalf("#%v: unexpected VLAN ID of the delete request: %v", i, id)
+		}
+		if n := len(sw.intents); n != 0 {
+			t.Fatalf("#%v: %v flow intents are left", i, n)
+		}
+	}
+}
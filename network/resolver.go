@@ -0,0 +1,123 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// resolveTimeout is the max time to wait for a resolver, after which the connection is served without the
+// name of its source address.
+var resolveTimeout = 2 * time.Second
+
+// Resolver annotates the source address of a switch connection with a human readable name, such as
+// a reverse DNS name or a site name from an operator-supplied site map, for auditing.
+type Resolver interface {
+	// Resolve returns the name of addr, or an empty string if addr does not have a name.
+	Resolve(addr net.Addr) string
+}
+
+type nopResolver struct{}
+
+func (r nopResolver) Resolve(addr net.Addr) string {
+	return ""
+}
+
+// resolve returns a name of addr for logging. It returns the address itself if it does not have a name or
+// resolver does not answer within resolveTimeout.
+func resolve(resolver Resolver, addr net.Addr) string {
+	// Buffered so that the resolver goroutine does not leak after the timeout.
+	c := make(chan string, 1)
+	go func() { c <- resolver.Resolve(addr) }()
+
+	var name string
+	select {
+	case name = <-c:
+	case <-time.After(resolveTimeout):
+		logger.Warningf("timed out resolving the name of %v", addr)
+	}
+	if len(name) > 0 {
+		return name
+	}
+
+	return addr.String()
+}
+
+// SiteResolver resolves the source addresses by an operator-supplied site map, and then by the reverse DNS
+// lookup if it is enabled.
+type SiteResolver struct {
+	sites      []siteRange
+	reverseDNS bool
+}
+
+type siteRange struct {
+	ipNet *net.IPNet
+	name  string
+}
+
+// NewSiteResolver returns a resolver whose site map is sites. A site is a range in CIDR notation and its name
+// separated by an equal sign, e.g., "10.1.0.0/16=site-tokyo", and the first matched site is used.
+func NewSiteResolver(sites []string, reverseDNS bool) (*SiteResolver, error) {
+	v := &SiteResolver{reverseDNS: reverseDNS}
+	for _, s := range sites {
+		t := strings.SplitN(s, "=", 2)
+		if len(t) != 2 || len(strings.TrimSpace(t[1])) == 0 {
+			return nil, fmt.Errorf("invalid site: %v", s)
+		}
+		n, err := parseIPRange(t[0])
+		if err != nil {
+			return nil, err
+		}
+		v.sites = append(v.sites, siteRange{ipNet: n, name: strings.TrimSpace(t[1])})
+	}
+
+	return v, nil
+}
+
+func (r *SiteResolver) Resolve(addr net.Addr) string {
+	host := sourceIP(addr)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+	for _, v := range r.sites {
+		if v.ipNet.Contains(ip) {
+			return v.name
+		}
+	}
+	if !r.reverseDNS {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, host)
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+
+	return strings.TrimSuffix(names[0], ".")
+}
@@ -0,0 +1,80 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSiteResolver(t *testing.T) {
+	if _, err := NewSiteResolver([]string{"10.0.0.0/8"}, false); err == nil {
+		t.Fatal("expected an error for the site without its name")
+	}
+	if _, err := NewSiteResolver([]string{"10.0.0.0/33=site"}, false); err == nil {
+		t.Fatal("expected an error for the invalid range")
+	}
+
+	resolver, err := NewSiteResolver([]string{"10.1.0.0/16=site-tokyo", "10.0.0.0/8 = site-seoul", "2001:db8::1=site-v6"}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	src := []struct {
+		Addr     net.Addr
+		Expected string
+	}{
+		{Addr: &net.TCPAddr{IP: net.IPv4(10, 1, 2, 3), Port: 51234}, Expected: "site-tokyo"},
+		{Addr: &net.TCPAddr{IP: net.IPv4(10, 2, 2, 3), Port: 51234}, Expected: "site-seoul"},
+		{Addr: &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 6633}, Expected: "site-v6"},
+		{Addr: &net.TCPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 51234}, Expected: ""},
+	}
+
+	for i, v := range src {
+		if name := resolver.Resolve(v.Addr); name != v.Expected {
+			t.Fatalf("#%v: unexpected name: expected=%v, got=%v", i, v.Expected, name)
+		}
+	}
+}
+
+type slowResolver struct {
+	delay time.Duration
+}
+
+func (r slowResolver) Resolve(addr net.Addr) string {
+	time.Sleep(r.delay)
+	return "slow"
+}
+
+func TestResolveTimeout(t *testing.T) {
+	defer func(v time.Duration) { resolveTimeout = v }(resolveTimeout)
+	resolveTimeout = 10 * time.Millisecond
+
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 51234}
+	if name := resolve(slowResolver{}, addr); name != "slow" {
+		t.Fatalf("unexpected name: %v", name)
+	}
+	// The address itself is used if the resolver is too slow.
+	if name := resolve(slowResolver{delay: time.Second}, addr); name != addr.String() {
+		t.Fatalf("unexpected name of the timed-out resolver: %v", name)
+	}
+}
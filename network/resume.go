@@ -0,0 +1,145 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+	"github.com/superkkt/cherry/openflow"
+)
+
+// flowIntent is a normal flow installed by an application, which can be installed again when its device
+// reconnects.
+type flowIntent struct {
+	owner AppCookie
+	match openflow.Match
+	port  openflow.OutPort
+	opts  FlowOptions
+	// Time when the flow has been installed last.
+	timestamp time.Time
+}
+
+// expired returns whether the flow would have been removed from the device by its timeouts at now. We
+// don't know whether the flow has been hit after the installation, so the idle timeout is regarded as
+// the hard timeout.
+func (r flowIntent) expired(now time.Time) bool {
+	elapsed := now.Sub(r.timestamp)
+	if r.opts.IdleTimeout != 0 && elapsed >= time.Duration(r.opts.IdleTimeout)*time.Second {
+		return true
+	}
+	if r.opts.HardTimeout != 0 && elapsed >= time.Duration(r.opts.HardTimeout)*time.Second {
+		return true
+	}
+
+	return false
+}
+
+// intentStore retains the flow intents of the disconnected devices for a grace period so that a flapping
+// device, which reconnects within the period, starts with its previous flows instead of flooding the
+// packets until the applications learn the flows again.
+type intentStore struct {
+	mutex sync.Mutex
+	clock clock.Clock
+	// Zero grace period disables the retention.
+	grace time.Duration
+	// Key is the DPID of a device.
+	entries map[string]retainedIntents
+	// Static flows of the operators, which are kept regardless of the grace period. Key is the DPID of a device.
+	statics map[string]staticFlows
+}
+
+type retainedIntents struct {
+	// OpenFlow version of the connection that the intents have been installed on.
+	version    uint8
+	intents    []flowIntent
+	expiration time.Time
+}
+
+func newIntentStore(grace time.Duration) *intentStore {
+	return &intentStore{
+		clock:   clock.New(),
+		grace:   grace,
+		entries: make(map[string]retainedIntents),
+		statics: make(map[string]staticFlows),
+	}
+}
+
+// retain keeps the intents of the device whose DPID is dpid until the grace period expires.
+func (r *intentStore) retain(dpid string, version uint8, intents []flowIntent) {
+	if r.grace == 0 || len(intents) == 0 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.clock.Now()
+	r.purge(now)
+	r.entries[dpid] = retainedIntents{
+		version:    version,
+		intents:    intents,
+		expiration: now.Add(r.grace),
+	}
+	logger.Infof("retained %v flow intents of the disconnected device for %v: DPID=%v", len(intents), r.grace, dpid)
+}
+
+// take removes the intents of the device whose DPID is dpid from the store, and returns the ones that are
+// still valid. It returns nil if the device has stayed down longer than the grace period, or it has
+// reconnected with a different OpenFlow version.
+func (r *intentStore) take(dpid string, version uint8) []flowIntent {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.clock.Now()
+	r.purge(now)
+	v, ok := r.entries[dpid]
+	if !ok {
+		return nil
+	}
+	delete(r.entries, dpid)
+	if v.version != version {
+		return nil
+	}
+
+	result := make([]flowIntent, 0, len(v.intents))
+	for _, i := range v.intents {
+		if i.expired(now) {
+			continue
+		}
+		result = append(result, i)
+	}
+
+	return result
+}
+
+// purge discards the intents whose grace period has expired. The caller should hold the lock.
+func (r *intentStore) purge(now time.Time) {
+	for dpid, v := range r.entries {
+		if now.Before(v.expiration) {
+			continue
+		}
+		logger.Debugf("discarded the flow intents of the device that stays down: DPID=%v", dpid)
+		delete(r.entries, dpid)
+	}
+}
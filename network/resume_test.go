@@ -0,0 +1,158 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func newIntentDevice(t *testing.T, now time.Time, macs ...string) *Device {
+	d := &Device{
+		flowCache: newFlowCache(5 * time.Second),
+		intents:   make(map[string]flowIntent),
+	}
+	f := of13.NewFactory()
+	for i, v := range macs {
+		mac, err := net.ParseMAC(v)
+		if err != nil {
+			t.Fatalf("invalid MAC address: %v", err)
+		}
+		match, err := f.NewMatch()
+		if err != nil {
+			t.Fatalf("failed to create a match: %v", err)
+		}
+		match.SetDstMAC(mac)
+		port := openflow.NewOutPort()
+		port.SetValue(uint32(i + 1))
+		if err := d.addFlowIntent(flowIntent{match: match, port: port, opts: DefaultFlowOptions, timestamp: now}); err != nil {
+			t.Fatalf("failed to add a flow intent: %v", err)
+		}
+	}
+
+	return d
+}
+
+func TestFlowIntentResumption(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	src := []struct {
+		age      time.Duration // Elapsed time since the flows have been installed when disconnected
+		downtime time.Duration
+		version  uint8
+		expected int
+	}{
+		// Reconnected within the grace period.
+		{0, 10 * time.Second, openflow.OF13_VERSION, 2},
+		// Stayed down longer than the grace period.
+		{0, 30 * time.Second, openflow.OF13_VERSION, 0},
+		// Reconnected with a different protocol version.
+		{0, 10 * time.Second, openflow.OF10_VERSION, 0},
+		// Within the grace period, but the flows have been expired by the idle timeout.
+		{85 * time.Second, 10 * time.Second, openflow.OF13_VERSION, 0},
+	}
+
+	for i, v := range src {
+		clk := clock.NewFake(now)
+		store := newIntentStore(20 * time.Second)
+		store.clock = clk
+
+		d := newIntentDevice(t, now.Add(-v.age), "00:00:00:00:00:01", "00:00:00:00:00:02")
+		// Disconnected.
+		store.retain("1", openflow.OF13_VERSION, d.flowIntents(now))
+		clk.Advance(v.downtime)
+		// Reconnected.
+		intents := store.take("1", v.version)
+		if len(intents) != v.expected {
+			t.Fatalf("#%v: expected %v intents, got %v", i, v.expected, len(intents))
+		}
+		// The intents are taken only once.
+		if store.take("1", v.version) != nil {
+			t.Fatalf("#%v: unexpected intents taken again", i)
+		}
+	}
+}
+
+func TestFlowIntentZeroGrace(t *testing.T) {
+	now := time.Now()
+	d := newIntentDevice(t, now, "00:00:00:00:00:01")
+
+	store := newIntentStore(0)
+	store.retain("1", openflow.OF13_VERSION, d.flowIntents(now))
+	if store.take("1", openflow.OF13_VERSION) != nil {
+		t.Fatal("expected no retention with zero grace period")
+	}
+}
+
+func TestRemovedFlowIntent(t *testing.T) {
+	owner, err := RegisterAppCookie("RemovedFlowTestApp")
+	if err != nil {
+		t.Fatalf("failed to register the cookie: %v", err)
+	}
+	reasons := []uint8{
+		openflow.FlowRemovedIdleTimeout,
+		openflow.FlowRemovedHardTimeout,
+		openflow.FlowRemovedDelete,
+		openflow.FlowRemovedGroupDelete,
+	}
+
+	for i, reason := range reasons {
+		fake := NewFakeNetwork()
+		sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+		sw.session.listener = nopControllerListener{}
+		sw.session.handler = newOF13Session(sw.Device)
+
+		mac := net.HardwareAddr{0, 0, 0, 0, 0, 1}
+		match, err := sw.Factory().NewMatch()
+		if err != nil {
+			t.Fatalf("#%v: failed to create a match: %v", i, err)
+		}
+		match.SetDstMAC(mac)
+		port := openflow.NewOutPort()
+		port.SetValue(2)
+		if err := sw.SetFlow(owner, match, port); err != nil {
+			t.Fatalf("#%v: failed to install the flow: %v", i, err)
+		}
+		if len(sw.flowIntents(time.Now())) != 1 || !sw.programmed.cache.Contains(mac.String()) {
+			t.Fatalf("#%v: the flow is not recorded", i)
+		}
+
+		flows := sw.FlowMods()
+		if err := sw.session.OnFlowRemoved(sw.Factory(), nil, &removedFlow{flow: flows[0], reason: reason}); err != nil {
+			t.Fatalf("#%v: failed to handle the removed flow: %v", i, err)
+		}
+		// The removed flow is neither resumed nor regarded as being installed.
+		if n := len(sw.flowIntents(time.Now())); n != 0 {
+			t.Fatalf("#%v: unexpected number of the flow intents: %v", i, n)
+		}
+		if ok, err := sw.flowCache.InProgress(match, port, DefaultFlowOptions); err != nil || ok {
+			t.Fatalf("#%v: the flow cache is not removed: %v", i, err)
+		}
+		if sw.programmed.cache.Contains(mac.String()) {
+			t.Fatalf("#%v: the destination is still programmed", i)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestRequestRole(t *testing.T) {
+	fake := NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 1)
+	if role := sw.Role(); role != openflow.RoleEqual {
+		t.Fatalf("unexpected initial role: %v", role)
+	}
+
+	if err := sw.RequestRole(openflow.RoleSlave, 3); err != nil {
+		t.Fatalf("failed to request the role: %v", err)
+	}
+	msgs := sw.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("unexpected messages: %v", msgs)
+	}
+	req, ok := msgs[0].(openflow.RoleRequest)
+	if !ok || req.Role() != openflow.RoleSlave || req.GenerationID() != 3 {
+		t.Fatalf("unexpected role request: %+v", msgs[0])
+	}
+	// The role is not changed until the device confirms it.
+	if role := sw.Role(); role != openflow.RoleEqual {
+		t.Fatalf("unexpected role before the reply: %v", role)
+	}
+
+	// Confirmed by the ROLE_REPLY.
+	sw.setRole(openflow.RoleSlave)
+	if role := sw.Role(); role != openflow.RoleSlave {
+		t.Fatalf("unexpected role after the reply: %v", role)
+	}
+
+	// OpenFlow 1.0 does not support the role request.
+	sw10 := fake.AddSwitch("2", of10.NewFactory(), 1)
+	if err := sw10.RequestRole(openflow.RoleMaster, 1); err != openflow.ErrUnsupportedMessage {
+		t.Fatalf("unexpected error on OpenFlow 1.0: %v", err)
+	}
+}
@@ -25,10 +25,13 @@ import (
 	"bytes"
 	"context"
 	"encoding"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/superkkt/cherry/openflow"
@@ -36,16 +39,132 @@ import (
 	"github.com/superkkt/cherry/openflow/of13"
 	"github.com/superkkt/cherry/openflow/transceiver"
 	"github.com/superkkt/cherry/protocol"
+
+	"github.com/superkkt/viper"
 )
 
 var (
-	errNotNegotiated = errors.New("invalid command on non-negotiated session")
+	errNotNegotiated  = errors.New("invalid command on non-negotiated session")
+	errDuplicateDPID  = errors.New("duplicated device DPID")
+	errDPIDNotAllowed = errors.New("device DPID not allowed")
 )
 
 const (
-	deviceExplorerInterval = 1 * time.Minute
+	defaultDeviceExplorerInterval = 1 * time.Minute
+	defaultPacketInCooldown       = 10 * time.Second
 )
 
+// deviceExplorerInterval returns how often the device explorer probes the ports of a device
+// using LLDP to discover the links among switches.
+func deviceExplorerInterval() time.Duration {
+	if v := viper.GetInt("lldp.probe_interval"); v > 0 {
+		return time.Duration(v) * time.Second
+	}
+
+	return defaultDeviceExplorerInterval
+}
+
+// missSendLength returns the max bytes of a packet that a switch sends to the controller in PACKET_IN.
+func missSendLength() uint16 {
+	if !viper.IsSet("default.miss_send_len") {
+		return 0xFFFF
+	}
+
+	return uint16(viper.GetInt("default.miss_send_len"))
+}
+
+// tableMissMaxLen returns the max bytes of an unmatched packet that the table-miss flow sends to the controller.
+func tableMissMaxLen() uint16 {
+	if !viper.IsSet("default.table_miss_max_len") {
+		return openflow.NoBufferMaxLen
+	}
+
+	return uint16(viper.GetInt("default.table_miss_max_len"))
+}
+
+// of10TableMiss returns whether the table-miss flow is also installed on OpenFlow 1.0 switches, which send
+// the unmatched packets to the controller by default.
+func of10TableMiss() bool {
+	return viper.GetBool("default.of10_table_miss")
+}
+
+// ParseFragHandling parses the name of an IP fragment handling: normal, drop, or reasm.
+func ParseFragHandling(name string) (openflow.ConfigFlag, error) {
+	switch strings.ToLower(name) {
+	case "", "normal":
+		return openflow.FragNormal, nil
+	case "drop":
+		return openflow.FragDrop, nil
+	case "reasm":
+		return openflow.FragReasm, nil
+	default:
+		return 0, fmt.Errorf("unknown IP fragment handling: %v", name)
+	}
+}
+
+// fragHandling returns the IP fragment handling that a switch whose capabilities are capabilities should use. It
+// falls back to FragNormal if default.frag_handling is invalid, e.g., the config file has been changed after startup.
+func fragHandling(capabilities uint32) openflow.ConfigFlag {
+	flag, err := ParseFragHandling(viper.GetString("default.frag_handling"))
+	if err != nil {
+		logger.Errorf("invalid default.frag_handling in the config file: fall back to the normal handling: %v", err)
+		return openflow.FragNormal
+	}
+	if flag == openflow.FragReasm && capabilities&openflow.CapabilityIPReasm == 0 {
+		logger.Warning("IP fragment reassembly is not supported by the device: fall back to the normal handling")
+		return openflow.FragNormal
+	}
+
+	return flag
+}
+
+// flowStatsInterval returns how often the flow statistics of a device are polled. Zero disables the polling.
+func flowStatsInterval() time.Duration {
+	if v := viper.GetInt("default.flow_stats_interval"); v > 0 {
+		return time.Duration(v) * time.Second
+	}
+
+	return 0
+}
+
+// packetInCooldown returns how long a port that has exceeded the rate limit of the PACKET_INs is throttled.
+func packetInCooldown() time.Duration {
+	if v := viper.GetInt("default.packet_in_cooldown"); v > 0 {
+		return time.Duration(v) * time.Second
+	}
+
+	return defaultPacketInCooldown
+}
+
+// resumeGrace returns how long the flows of a disconnected device are retained to be installed again when it
+// reconnects. Zero disables the retention.
+func resumeGrace() time.Duration {
+	if v := viper.GetInt("default.resume_grace"); v > 0 {
+		return time.Duration(v) * time.Second
+	}
+
+	return 0
+}
+
+// portStatsInterval returns how often the port statistics of a device are polled. Zero disables the polling.
+func portStatsInterval() time.Duration {
+	if v := viper.GetInt("default.port_stats_interval"); v > 0 {
+		return time.Duration(v) * time.Second
+	}
+
+	return 0
+}
+
+// linkTimeout returns the expiration time of a link that has not been discovered again by the
+// device explorer.
+func linkTimeout() time.Duration {
+	if v := viper.GetInt("lldp.link_timeout"); v > 0 {
+		return time.Duration(v) * time.Second
+	}
+
+	return deviceExplorerInterval() * 3
+}
+
 type session struct {
 	negotiated  bool
 	device      *Device
@@ -54,13 +173,32 @@ type session struct {
 	watcher     watcher
 	finder      Finder
 	listener    ControllerEventListener
+	tracker     *dpidTracker
+	intents     *intentStore
+	source      string // Key of the connection source in the DPID tracker
+	remote      string // Remote address of the connection including the port number
+	// Main device that this session is attached to as an auxiliary connection. Nil if this is a main connection.
+	main *Device
+	// Writes the outbound messages of the device, which is the transceiver of the connection.
+	writer sessionWriter
+	// Limits the number of the connections served at the same time. Nil means no limit.
+	limiter *deviceLimiter
+	// Whether this session holds a slot of limiter, which has been taken when the connection is accepted.
+	admitted bool
+	// Whether the device of this session has been added to the watcher, which is false if the device has been
+	// rejected because another device with the same DPID is connected.
+	added bool
 }
 
 type sessionConfig struct {
 	conn     net.Conn
+	site     string // Resolved name of the connection's source address
 	watcher  watcher
 	finder   Finder
 	listener ControllerEventListener
+	tracker  *dpidTracker
+	intents  *intentStore
+	limiter  *deviceLimiter // Limiter whose slot has been taken for conn, or nil
 }
 
 func checkParam(c sessionConfig) {
@@ -76,6 +214,12 @@ func checkParam(c sessionConfig) {
 	if c.listener == nil {
 		panic("Listener is nil")
 	}
+	if c.tracker == nil {
+		panic("DPID tracker is nil")
+	}
+	if c.intents == nil {
+		panic("Intent store is nil")
+	}
 }
 
 func newSession(c sessionConfig) *session {
@@ -86,8 +230,22 @@ func newSession(c sessionConfig) *session {
 	v.watcher = c.watcher
 	v.finder = c.finder
 	v.listener = c.listener
+	v.tracker = c.tracker
+	v.intents = c.intents
+	v.limiter = c.limiter
+	v.admitted = c.limiter != nil
+	v.source = trackerKey(c.site, c.conn.RemoteAddr())
+	v.remote = c.conn.RemoteAddr().String()
 	v.device = newDevice(v)
+	v.device.site = c.site
 	v.transceiver = transceiver.NewTransceiver(stream, v)
+	v.writer = transceiverWriter{v.transceiver}
+	v.transceiver.SetReadTimeout(time.Duration(viper.GetInt("default.read_timeout")) * time.Second)
+	v.transceiver.SetWriteTimeout(time.Duration(viper.GetInt("default.write_timeout")) * time.Second)
+	v.transceiver.SetConfirmTimeout(time.Duration(viper.GetInt("default.confirm_timeout")) * time.Second)
+	v.transceiver.SetEchoInterval(time.Duration(viper.GetInt("default.echo_interval")) * time.Second)
+	v.transceiver.SetEchoMaxMisses(viper.GetInt("default.echo_max_misses"))
+	v.transceiver.SetMaxMessageSize(viper.GetInt("default.max_message_size"))
 
 	return v
 }
@@ -122,7 +280,7 @@ func (r *session) OnError(f openflow.Factory, w transceiver.Writer, v openflow.E
 		return nil
 	}
 
-	logger.Errorf("ERROR (DPID=%v, class=%v, code=%v, data=%v)", r.device.ID(), v.Class(), v.Code(), v.Data())
+	logger.Errorf("ERROR (DPID=%v, Site=%v, class=%v, code=%v, data=%v)", r.device.ID(), r.device.Site(), v.Class(), v.Code(), v.Data())
 	if !r.negotiated {
 		return errNotNegotiated
 	}
@@ -143,32 +301,65 @@ func (r *session) OnFeaturesReply(f openflow.Factory, w transceiver.Writer, v op
 		// FeaturesReply packet. This additional FeaturesReply packet is raised by our
 		// device explorer. So, we have to skip the following device initialization routine.
 		logger.Debug("received FEATURES_REPLY that is a response for our device explorer's probe")
-		return r.handler.OnFeaturesReply(f, w, v)
+		up := r.upPorts()
+		if err := r.handler.OnFeaturesReply(f, w, v); err != nil {
+			return err
+		}
+		r.raisePortUps(up)
+
+		return nil
 	}
 
 	// We got a first FeaturesReply packet! Let's initialize this device.
 	dpid := strconv.FormatUint(v.DPID(), 10)
-	// Already connected device?
-	if r.finder.Device(dpid) != nil {
-		return errors.New("duplicated device DPID (aux. connection is not supported yet)")
+	if v.AuxID() != 0 {
+		// Auxiliary connections of the connected devices do not count against the limit.
+		r.releaseSlot()
+		return r.attachAuxChannel(dpid, v.AuxID())
+	}
+	if !isAllowedDPID(v.DPID()) {
+		return r.rejectDisallowedDPID(f, w, dpid, v.TransactionID())
+	}
+	if prev, changed := r.tracker.update(r.source, v.DPID()); changed {
+		logger.Warningf("DPID of the device connected from %v (site=%v) has been changed from %v to %v: check the device configuration or hardware replacement", r.source, r.device.Site(), prev, dpid)
 	}
 	r.device.setID(dpid)
-	logger.Infof("device is ready: DPID=%v, Description=%+v", dpid, r.device.Descriptions())
+	// Already connected device? Two switches may be misconfigured to have the same DPID. The device is added
+	// only if it does not exist in a single step, so two devices that connect at the same time cannot be
+	// added together.
+	if !r.watcher.DeviceAdded(r.device) {
+		return r.rejectDuplicateDPID(f, w, dpid, v.TransactionID())
+	}
+	r.added = true
+	logger.Infof("device is ready: DPID=%v, Site=%v, Description=%+v", dpid, r.device.Site(), r.device.Descriptions())
+
+	// The features are set before the device up event so that the applications can use them, e.g., the
+	// number of the tables to choose their flow tables.
+	features := Features{
+		DPID:         v.DPID(),
+		NumBuffers:   v.NumBuffers(),
+		NumTables:    v.NumTables(),
+		Capabilities: v.Capabilities(),
+	}
+	r.device.setFeatures(features)
 
 	// We assume a device is up after setting its DPID
 	if err := r.listener.OnDeviceUp(r.finder, r.device); err != nil {
 		return err
 	}
-	r.watcher.DeviceAdded(r.device)
 
-	features := Features{
-		DPID:       v.DPID(),
-		NumBuffers: v.NumBuffers(),
-		NumTables:  v.NumTables(),
+	if err := sendSetConfig(f, w, fragHandling(v.Capabilities()), missSendLength()); err != nil {
+		return fmt.Errorf("failed to send SET_CONFIG: %v", err)
 	}
-	r.device.setFeatures(features)
 
-	return r.handler.OnFeaturesReply(f, w, v)
+	// OpenFlow 1.0 devices report their ports in FEATURES_REPLY.
+	up := r.upPorts()
+	if err := r.handler.OnFeaturesReply(f, w, v); err != nil {
+		return err
+	}
+	r.raisePortUps(up)
+
+	return nil
 }
 
 func (r *session) OnGetConfigReply(f openflow.Factory, w transceiver.Writer, v openflow.GetConfigReply) error {
@@ -199,7 +390,74 @@ func (r *session) OnDescReply(f openflow.Factory, w transceiver.Writer, v openfl
 	}
 	r.device.setDescriptions(desc)
 
-	return r.handler.OnDescReply(f, w, v)
+	if err := r.handler.OnDescReply(f, w, v); err != nil {
+		return err
+	}
+	// OpenFlow 1.0 devices report their ports in FEATURES_REPLY that precedes this.
+	if f.ProtocolVersion() == openflow.OF10_VERSION {
+		r.resumeFlows()
+	}
+
+	return nil
+}
+
+func (r *session) OnFlowStatsReply(f openflow.Factory, w transceiver.Writer, v openflow.FlowStatsReply) error {
+	logger.Debugf("FLOW_STATS_REPLY is received (device=%v, # of flows=%v, more=%v)", r.device.ID(), len(v.Stats()), v.More())
+
+	if !r.negotiated {
+		return errNotNegotiated
+	}
+	r.device.updateFlowStats(v.Stats(), v.More())
+
+	return r.handler.OnFlowStatsReply(f, w, v)
+}
+
+func (r *session) OnPortStatsReply(f openflow.Factory, w transceiver.Writer, v openflow.PortStatsReply) error {
+	logger.Debugf("PORT_STATS_REPLY is received (device=%v, # of ports=%v, more=%v)", r.device.ID(), len(v.Stats()), v.More())
+
+	if !r.negotiated {
+		return errNotNegotiated
+	}
+	r.device.updatePortStats(v.Stats(), time.Now())
+
+	return r.handler.OnPortStatsReply(f, w, v)
+}
+
+func (r *session) OnTableStatsReply(f openflow.Factory, w transceiver.Writer, v openflow.TableStatsReply) error {
+	logger.Debugf("TABLE_STATS_REPLY is received (device=%v, # of tables=%v, more=%v)", r.device.ID(), len(v.Stats()), v.More())
+
+	if !r.negotiated {
+		return errNotNegotiated
+	}
+	r.device.updateTableStats(v.Stats(), v.More())
+
+	return r.handler.OnTableStatsReply(f, w, v)
+}
+
+func (r *session) OnRoleReply(f openflow.Factory, w transceiver.Writer, v openflow.RoleReply) error {
+	logger.Infof("ROLE_REPLY is received (device=%v, role=%v, generationID=%v)", r.device.ID(), v.Role(), v.GenerationID())
+
+	if !r.negotiated {
+		return errNotNegotiated
+	}
+	r.device.setRole(v.Role())
+
+	return r.handler.OnRoleReply(f, w, v)
+}
+
+func (r *session) OnQueueGetConfigReply(f openflow.Factory, w transceiver.Writer, v openflow.QueueGetConfigReply) error {
+	logger.Debugf("QUEUE_GET_CONFIG_REPLY is received (device=%v, port=%v, # of queues=%v)", r.device.ID(), v.Port(), len(v.Queue()))
+
+	if !r.negotiated {
+		return errNotNegotiated
+	}
+	queues := make([]uint32, 0, len(v.Queue()))
+	for _, q := range v.Queue() {
+		queues = append(queues, q.ID())
+	}
+	r.device.setQueues(v.Port(), queues)
+
+	return r.handler.OnQueueGetConfigReply(f, w, v)
 }
 
 func (r *session) OnPortDescReply(f openflow.Factory, w transceiver.Writer, v openflow.PortDescReply) error {
@@ -209,7 +467,55 @@ func (r *session) OnPortDescReply(f openflow.Factory, w transceiver.Writer, v op
 		return errNotNegotiated
 	}
 
-	return r.handler.OnPortDescReply(f, w, v)
+	up := r.upPorts()
+	if err := r.handler.OnPortDescReply(f, w, v); err != nil {
+		return err
+	}
+	r.raisePortUps(up)
+	r.resumeFlows()
+
+	return nil
+}
+
+// upPorts returns the numbers of the ports of the device that are up.
+func (r *session) upPorts() map[uint32]bool {
+	up := make(map[uint32]bool)
+	for _, p := range r.device.Ports() {
+		if v := p.Value(); v != nil && !v.IsPortDown() && !v.IsLinkDown() {
+			up[p.Number()] = true
+		}
+	}
+
+	return up
+}
+
+// raisePortUps sends the port up events of the ports that are up now but were not in prev. The ports are known
+// after the device up event, so the applications that need a port on the device up event can start from this
+// port up event. The ports that were already up are skipped not to repeat the events on the device explorer's
+// periodic probes.
+func (r *session) raisePortUps(prev map[uint32]bool) {
+	for num := range r.upPorts() {
+		if !prev[num] {
+			r.sendPortEvent(num, true)
+		}
+	}
+}
+
+// resumeFlows installs the flows that the device had before it was disconnected within the grace period. It
+// should be called after the ports of the device are known.
+func (r *session) resumeFlows() {
+	version := r.device.Factory().ProtocolVersion()
+	if statics := r.intents.staticFlowsOf(r.device.ID(), version); len(statics) > 0 {
+		n := r.device.resumeStaticFlows(statics)
+		logger.Infof("reinstalled %v of %v static flows of the reconnected device: DPID=%v", n, len(statics), r.device.ID())
+	}
+
+	intents := r.intents.take(r.device.ID(), version)
+	if len(intents) == 0 {
+		return
+	}
+	n := r.device.resumeFlows(intents)
+	logger.Infof("resumed %v of %v flows of the reconnected device: DPID=%v", n, len(intents), r.device.ID())
 }
 
 func newLLDPEtherFrame(deviceID string, port openflow.Port) ([]byte, error) {
@@ -319,7 +625,18 @@ func (r *session) OnPortStatus(f openflow.Factory, w transceiver.Writer, v openf
 
 	port := v.Port()
 	logger.Debugf("Device=%v, PortNum=%v, AdminUp=%v, LinkUp=%v", r.device.ID(), port.Number(), !port.IsPortDown(), !port.IsLinkDown())
+	var prevSpeed uint64
+	prev := r.device.Port(port.Number())
+	if prev != nil {
+		prevSpeed = portSpeed(prev)
+	}
 	r.updatePort(v)
+	// Zero speed usually means the link is down, which is handled by the port removed event.
+	if prevSpeed != 0 && port.Speed() != 0 && prevSpeed != port.Speed() {
+		logger.Infof("port speed has been changed: Device=%v, PortNum=%v, Speed=%v Mbps -> %v Mbps", r.device.ID(), port.Number(), prevSpeed, port.Speed())
+		// Update the link weight and recalculate the paths.
+		r.watcher.PortSpeedChanged(prev)
+	}
 
 	// Send port event
 	up := !port.IsPortDown() && !port.IsLinkDown()
@@ -343,13 +660,26 @@ func (r *session) OnPortStatus(f openflow.Factory, w transceiver.Writer, v openf
 }
 
 func (r *session) OnFlowRemoved(f openflow.Factory, w transceiver.Writer, v openflow.FlowRemoved) error {
-	logger.Debugf("FLOW_REMOVED is received (cookie=%v)", v.Cookie())
+	logger.Debugf("FLOW_REMOVED is received (cookie=%v, reason=%v, duration=%vs, packets=%v, bytes=%v)", v.Cookie(), v.Reason(), v.DurationSec(), v.PacketCount(), v.ByteCount())
 
 	if !r.negotiated {
 		return errNotNegotiated
 	}
 
-	if err := r.listener.OnFlowRemoved(r.finder, v); err != nil {
+	r.device.forgetFlow(v)
+	r.device.appFlows.removed(v)
+
+	if isQuarantineLifted(v) {
+		_, mac := v.Match().SrcMAC()
+		logger.Infof("quarantine is lifted: deviceID=%v, MAC=%v", r.device.ID(), mac)
+		// The host may have been moved or aged out while its packets were dropped, so the flows toward the host
+		// are removed to learn its location again from the next packets.
+		if err := r.device.RemoveFlowByMAC(mac); err != nil {
+			logger.Errorf("failed to remove the flows toward the host whose quarantine is lifted: %v", err)
+		}
+	}
+
+	if err := r.listener.OnFlowRemoved(r.finder, r.device, v); err != nil {
 		logger.Errorf("error on OnFlowRemoved listeners: %v", err)
 		// Ignore this error and keep go on.
 	}
@@ -450,6 +780,15 @@ func (r *session) OnPacketIn(f openflow.Factory, w transceiver.Writer, v openflo
 	if !r.negotiated {
 		return errNotNegotiated
 	}
+	// PACKET_INs from an auxiliary connection are handled as if they are received from the main one.
+	if r.main != nil {
+		return r.main.session.OnPacketIn(f, w, v)
+	}
+	atomic.AddUint64(&r.device.counters.packetIns, 1)
+	// The main and auxiliary connections deliver PACKET_INs concurrently.
+	r.device.packetInMutex.Lock()
+	defer r.device.packetInMutex.Unlock()
+
 	logger.Debugf("PACKET_IN is received (device=%v, inport=%v, reason=%v, tableID=%v, cookie=%v)",
 		r.device.ID(), v.InPort(), v.Reason(), v.TableID(), v.Cookie())
 
@@ -459,6 +798,11 @@ func (r *session) OnPacketIn(f openflow.Factory, w transceiver.Writer, v openflo
 		// Drop the incoming packet.
 		return nil
 	}
+	if r.device.isDraining() {
+		logger.Debugf("ignoring PACKET_IN: device is being drained: device=%v, inPort=%v", r.device.ID(), v.InPort())
+		// Drop the incoming packet.
+		return nil
+	}
 
 	ethernet, err := getEthernet(v.Data())
 	if err != nil {
@@ -476,8 +820,17 @@ func (r *session) OnPacketIn(f openflow.Factory, w transceiver.Writer, v openflo
 	if isLLDP(ethernet) {
 		return r.handleLLDP(inPort, ethernet)
 	}
+	// Only the table misses are classified. The packets punted by the punt flows are sent to the
+	// controller on purpose, so they are neither new flows nor repeat misses.
+	if !IsPuntCookie(v.Cookie()) && r.device.programmed.Count(ethernet.DstMAC) {
+		logger.Debugf("repeated PACKET_IN for a recently programmed flow: device=%v, dst=%v", r.device.ID(), ethernet.DstMAC)
+	}
+	if !r.device.allowPacketIn(inPort) {
+		logger.Debugf("ignoring PACKET_IN from %v:%v by the rate limit", r.device.ID(), v.InPort())
+		return nil
+	}
 	// Do nothing if the ingress port is an edge between switches and is disabled by STP.
-	if r.finder.IsEdge(inPort) && !r.finder.IsEnabledBySTP(inPort) {
+	if !r.finder.IsEnabledPort(inPort) {
 		logger.Debugf("ignoring PACKET_IN from %v:%v by STP", r.device.ID(), v.InPort())
 		return nil
 	}
@@ -485,8 +838,20 @@ func (r *session) OnPacketIn(f openflow.Factory, w transceiver.Writer, v openflo
 	if err := r.handler.OnPacketIn(f, w, v); err != nil {
 		return err
 	}
+	if !r.watcher.PacketReceived(inPort, ethernet) {
+		logger.Debugf("ignoring PACKET_IN from %v:%v: source MAC address %v is pinned to another port", r.device.ID(), v.InPort(), ethernet.SrcMAC)
+		return nil
+	}
+
+	// The buffer ID is delivered with the packet so that the applications can send the buffered
+	// packet without its data.
+	info := newPacketInInfo(v)
+	// Deliver the packet only to its owner application if it is punted by a punt flow.
+	if l, ok := r.listener.(PuntEventListener); ok && IsPuntCookie(v.Cookie()) {
+		return l.OnPuntedPacketIn(r.finder, inPort, ethernet, info, v.Cookie())
+	}
 
-	return r.listener.OnPacketIn(r.finder, inPort, ethernet)
+	return r.listener.OnPacketIn(r.finder, inPort, ethernet, info)
 }
 
 func (r *session) OnBarrierReply(f openflow.Factory, w transceiver.Writer, v openflow.BarrierReply) error {
@@ -501,21 +866,96 @@ func (r *session) OnBarrierReply(f openflow.Factory, w transceiver.Writer, v ope
 func (r *session) Run(ctx context.Context) {
 	stopExplorer := r.runDeviceExplorer(ctx)
 	logger.Debugf("started a new device explorer")
+	stopPoller := r.runFlowStatsPoller(ctx)
+	stopPortPoller := r.runPortStatsPoller(ctx)
 
 	if err := r.transceiver.Run(ctx); err != nil {
 		logger.Errorf("openflow transceiver is unexpectedly closed: %v", err)
 	}
-	logger.Infof("disconnected device (DPID=%v)", r.device.ID())
+	logger.Infof("disconnected device (DPID=%v, Site=%v)", r.device.ID(), r.device.Site())
 
 	stopExplorer()
+	stopPoller()
+	stopPortPoller()
 	r.transceiver.Close()
+	if r.main != nil {
+		r.main.removeAuxChannel(r.transceiver)
+	}
 	r.device.Close()
-	if r.device.isReady() {
+	// Auxiliary connections do not have their own flows, and a device rejected because of its duplicated DPID
+	// should not touch the states of the connected device that has the same DPID.
+	if r.added {
+		r.intents.retain(r.device.ID(), r.device.Factory().ProtocolVersion(), r.device.flowIntents(time.Now()))
 		if err := r.listener.OnDeviceDown(r.finder, r.device); err != nil {
 			logger.Errorf("OnDeviceDown: %v", err)
 		}
 		r.watcher.DeviceRemoved(r.device)
 	}
+	r.releaseSlot()
+}
+
+// releaseSlot returns the slot of the device limiter taken when the connection has been accepted.
+func (r *session) releaseSlot() {
+	if !r.admitted {
+		return
+	}
+	r.limiter.release()
+	r.admitted = false
+}
+
+// rejectDuplicateDPID replies an ERROR to the main connection whose DPID is dpid, which is already connected,
+// and then returns an error to close the connection.
+func (r *session) rejectDuplicateDPID(f openflow.Factory, w transceiver.Writer, dpid string, xid uint32) error {
+	var prevRemote, prevSite string
+	// The connected device may have been removed in the meantime.
+	if prev := r.finder.Device(dpid); prev != nil {
+		prevSite = prev.Site()
+		if prev.session != nil {
+			prevRemote = prev.session.remote
+		}
+	}
+	logger.Errorf("rejecting the connection from %v (site=%v): device DPID=%v is already connected from %v (site=%v)", r.remote, r.device.Site(), dpid, prevRemote, prevSite)
+	sendPermissionError(f, w, xid, errDuplicateDPID)
+
+	return errDuplicateDPID
+}
+
+// rejectDisallowedDPID replies an ERROR to the main connection of the device whose DPID is dpid because the
+// DPID is not in the allow-list, and then returns an error to close the connection.
+func (r *session) rejectDisallowedDPID(f openflow.Factory, w transceiver.Writer, dpid string, xid uint32) error {
+	logger.Errorf("rejecting the connection from %v (site=%v): device DPID=%v is not allowed", r.remote, r.device.Site(), dpid)
+	sendPermissionError(f, w, xid, errDPIDNotAllowed)
+
+	return errDPIDNotAllowed
+}
+
+// sendPermissionError sends an ERROR whose data is the message of reason to reject a connection. The ERROR is
+// sent in best effort because the connection may be closed before it is written.
+func sendPermissionError(f openflow.Factory, w transceiver.Writer, xid uint32, reason error) {
+	text := []byte(reason.Error())
+	payload := make([]byte, 4, 4+len(text))
+	// HELLO_FAILED and EPERM are same in all the versions.
+	binary.BigEndian.PutUint16(payload[0:2], of13.OFPET_HELLO_FAILED)
+	binary.BigEndian.PutUint16(payload[2:4], of13.OFPHFC_EPERM)
+	msg := openflow.NewMessage(f.ProtocolVersion(), of13.OFPT_ERROR, xid)
+	msg.SetPayload(append(payload, text...))
+	if err := w.Write(&msg); err != nil {
+		logger.Errorf("failed to send ERROR (%v): %v", reason, err)
+	}
+}
+
+// attachAuxChannel attaches this session to the main device whose DPID is dpid as an auxiliary connection
+// so that the main device can send PACKET_OUTs through this session.
+func (r *session) attachAuxChannel(dpid string, auxID uint8) error {
+	main := r.finder.Device(dpid)
+	if main == nil || main.IsClosed() {
+		return fmt.Errorf("auxiliary connection (ID=%v) for an unknown device: DPID=%v", auxID, dpid)
+	}
+	main.addAuxChannel(r.transceiver)
+	r.main = main
+	logger.Infof("auxiliary connection is attached: DPID=%v, AuxID=%v, Site=%v", dpid, auxID, r.device.Site())
+
+	return nil
 }
 
 func (r *session) runDeviceExplorer(ctx context.Context) context.CancelFunc {
@@ -523,7 +963,7 @@ func (r *session) runDeviceExplorer(ctx context.Context) context.CancelFunc {
 
 	go func() {
 		// Note taht ticker will deliver the first tick after specified duration.
-		ticker := time.Tick(deviceExplorerInterval)
+		ticker := time.Tick(deviceExplorerInterval())
 
 		// Infinite loop.
 		for {
@@ -565,8 +1005,154 @@ func (r *session) runDeviceExplorer(ctx context.Context) context.CancelFunc {
 	return canceller
 }
 
+// runFlowStatsPoller periodically requests the statistics of all the flows in the device so that
+// the applications can read the last snapshot using Device.FlowStats.
+func (r *session) runFlowStatsPoller(ctx context.Context) context.CancelFunc {
+	subCtx, canceller := context.WithCancel(ctx)
+
+	interval := flowStatsInterval()
+	if interval == 0 {
+		return canceller
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-subCtx.Done():
+				logger.Debugf("terminating the flow stats poller: deviceID=%v", r.device.ID())
+				return
+			case <-ticker.C:
+				if r.device.isReady() == false {
+					continue
+				}
+				if err := sendFlowStatsRequest(r.device.Factory(), r.device.Writer()); err != nil {
+					logger.Errorf("failed to send a flow stats request: %v", err)
+					continue
+				}
+			}
+		}
+	}()
+
+	return canceller
+}
+
+// runPortStatsPoller periodically requests the statistics of all the ports in the device so that
+// the applications can read the utilization of a port using Device.PortStats.
+func (r *session) runPortStatsPoller(ctx context.Context) context.CancelFunc {
+	subCtx, canceller := context.WithCancel(ctx)
+
+	interval := portStatsInterval()
+	if interval == 0 {
+		return canceller
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-subCtx.Done():
+				logger.Debugf("terminating the port stats poller: deviceID=%v", r.device.ID())
+				return
+			case <-ticker.C:
+				if r.device.isReady() == false {
+					continue
+				}
+				if err := sendPortStatsRequest(r.device.Factory(), r.device.Writer()); err != nil {
+					logger.Errorf("failed to send a port stats request: %v", err)
+					continue
+				}
+			}
+		}
+	}()
+
+	return canceller
+}
+
 func (r *session) Write(msg encoding.BinaryMarshaler) error {
-	return r.transceiver.Write(msg)
+	err := r.writer.Write(msg)
+	if flow, ok := msg.(openflow.FlowMod); ok && err == nil {
+		r.countFlowMod(flow)
+	}
+
+	return err
+}
+
+// countFlowMod updates the counters of the device for the flow that has been sent to it.
+func (r *session) countFlowMod(flow openflow.FlowMod) {
+	atomic.AddUint64(&r.device.counters.flowMods, 1)
+	r.device.appFlows.added(flow)
+}
+
+// WriteBatch sends msgs in order using a single write operation.
+func (r *session) WriteBatch(msgs []encoding.BinaryMarshaler) error {
+	if err := r.writer.WriteBatch(msgs); err != nil {
+		return err
+	}
+	for _, v := range msgs {
+		if flow, ok := v.(openflow.FlowMod); ok {
+			r.countFlowMod(flow)
+		}
+	}
+
+	return nil
+}
+
+// sessionWriter writes the outbound messages of a session.
+type sessionWriter interface {
+	transceiver.BatchWriter
+	// QueueDepth returns the number of the messages that are waiting to be written.
+	QueueDepth() int
+}
+
+// transceiverWriter is a sessionWriter that writes the messages to the connection of a transceiver, and
+// disconnects the device that cannot keep up with them.
+type transceiverWriter struct {
+	*transceiver.Transceiver
+}
+
+func (r transceiverWriter) Write(msg encoding.BinaryMarshaler) error {
+	return handleWriteErr(r.Transceiver, msg, r.Transceiver.Write(msg))
+}
+
+func (r transceiverWriter) WriteBatch(msgs []encoding.BinaryMarshaler) error {
+	err := r.Transceiver.WriteBatch(msgs)
+	if err == nil || len(msgs) == 0 {
+		return err
+	}
+	// The device is disconnected if the batch has any message other than PACKET_OUTs.
+	msg := msgs[0]
+	for _, v := range msgs {
+		if _, ok := v.(openflow.PacketOut); !ok {
+			msg = v
+			break
+		}
+	}
+
+	return handleWriteErr(r.Transceiver, msg, err)
+}
+
+// handleWriteErr decides what to do when the device connected to w cannot keep up with our outbound messages.
+// A PACKET_OUT is just dropped, but the device is disconnected for the other messages because losing them,
+// such as FLOW_MODs, makes the device inconsistent with our view. The device will reconnect and be
+// initialized again.
+func handleWriteErr(w transceiver.WriteCloser, msg encoding.BinaryMarshaler, err error) error {
+	if err != transceiver.ErrWriteQueueFull {
+		return err
+	}
+	if _, ok := msg.(openflow.PacketOut); ok {
+		logger.Warningf("dropping PACKET_OUT: %v", err)
+		return err
+	}
+
+	logger.Errorf("disconnecting the device that cannot keep up with our messages: %v", err)
+	w.Close()
+
+	return err
 }
 
 func sendHello(f openflow.Factory, w transceiver.Writer) error {
@@ -578,13 +1164,15 @@ func sendHello(f openflow.Factory, w transceiver.Writer) error {
 	return w.Write(msg)
 }
 
-func sendSetConfig(f openflow.Factory, w transceiver.Writer) error {
+// sendSetConfig sends SET_CONFIG that sets the IP fragment handling to flags and the max bytes of a packet
+// sent to the controller in PACKET_IN to missSendLen.
+func sendSetConfig(f openflow.Factory, w transceiver.Writer, flags openflow.ConfigFlag, missSendLen uint16) error {
 	msg, err := f.NewSetConfig()
 	if err != nil {
 		return err
 	}
-	msg.SetFlags(openflow.FragNormal)
-	msg.SetMissSendLength(0xFFFF)
+	msg.SetFlags(flags)
+	msg.SetMissSendLength(missSendLen)
 
 	return w.Write(msg)
 }
@@ -616,6 +1204,31 @@ func sendBarrierRequest(f openflow.Factory, w transceiver.Writer) error {
 	return w.Write(msg)
 }
 
+func sendFlowStatsRequest(f openflow.Factory, w transceiver.Writer) error {
+	match, err := f.NewMatch()
+	if err != nil {
+		return err
+	}
+	msg, err := f.NewFlowStatsRequest()
+	if err != nil {
+		return err
+	}
+	// All the flows in all the tables.
+	msg.SetTableID(0xFF)
+	msg.SetMatch(match)
+
+	return w.Write(msg)
+}
+
+func sendPortStatsRequest(f openflow.Factory, w transceiver.Writer) error {
+	msg, err := f.NewPortStatsRequest()
+	if err != nil {
+		return err
+	}
+	// All the ports because the port number is not set.
+	return w.Write(msg)
+}
+
 func sendPortDescriptionRequest(f openflow.Factory, w transceiver.Writer) error {
 	msg, err := f.NewPortDescRequest()
 	if err != nil {
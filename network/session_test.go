@@ -0,0 +1,83 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/viper"
+)
+
+func TestSessionSocketTimeouts(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("default.read_timeout", 30)
+	viper.Set("default.write_timeout", 5)
+
+	controller, device := net.Pipe()
+	defer controller.Close()
+	defer device.Close()
+
+	network := NewFakeNetwork()
+	s := newSession(sessionConfig{
+		conn:     controller,
+		watcher:  network.topology,
+		finder:   network,
+		listener: nopControllerListener{},
+		tracker:  newDPIDTracker(),
+		intents:  newIntentStore(0),
+	})
+	if v := s.transceiver.ReadTimeout(); v != 30*time.Second {
+		t.Fatalf("unexpected read timeout: %v", v)
+	}
+	if v := s.transceiver.WriteTimeout(); v != 5*time.Second {
+		t.Fatalf("unexpected write timeout: %v", v)
+	}
+}
+
+func TestFragHandling(t *testing.T) {
+	defer viper.Reset()
+
+	src := []struct {
+		name         string
+		capabilities uint32
+		expected     openflow.ConfigFlag
+	}{
+		{"drop", 0, openflow.FragDrop},
+		{"reasm", openflow.CapabilityIPReasm, openflow.FragReasm},
+		// The device does not support the reassembly.
+		{"reasm", 0, openflow.FragNormal},
+		// The config file has been reloaded with an invalid value.
+		{"invalid", 0, openflow.FragNormal},
+	}
+
+	for i, v := range src {
+		viper.Reset()
+		viper.Set("default.frag_handling", v.name)
+		if flag := fragHandling(v.capabilities); flag != v.expected {
+			t.Fatalf("#%v: unexpected IP fragment handling: expected=%v, got=%v", i, v.expected, flag)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestSetFlowsValidation(t *testing.T) {
+	owner, err := RegisterAppCookie("TestSetFlowsValidation")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fake := NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	port := openflow.NewOutPort()
+	port.SetValue(2)
+	newEntry := func(valid bool) FlowEntry {
+		match, err := sw.Factory().NewMatch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		match.SetDstMAC(net.HardwareAddr{0, 0, 0, 0, 0, 1})
+		if !valid {
+			// IP address without the Ethernet type.
+			match.SetSrcIP(&net.IPNet{IP: net.IPv4(10, 0, 0, 1), Mask: net.CIDRMask(32, 32)})
+		}
+		return FlowEntry{Match: match, Port: port, Options: DefaultFlowOptions}
+	}
+
+	src := [][]bool{
+		{true, false},
+		{false, true},
+	}
+
+	for i, v := range src {
+		sw.Reset()
+		entries := []FlowEntry{newEntry(v[0]), newEntry(v[1])}
+		packetOut, err := sw.Factory().NewPacketOut()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sw.SetFlows(owner, entries, packetOut); err == nil {
+			t.Fatalf("#%v: expected an error, but got nil", i)
+		}
+		// Neither the valid flow nor the trailing message is sent.
+		if n := len(sw.Messages()); n != 0 {
+			t.Fatalf("#%v: %v messages are sent to the switch", i, n)
+		}
+		for _, e := range entries {
+			if ok, _ := sw.flowCache.InProgress(e.Match, e.Port, e.Options); ok {
+				t.Fatalf("#%v: flow cache of the unsent flow has been added", i)
+			}
+		}
+		if n := len(sw.intents); n != 0 {
+			t.Fatalf("#%v: unexpected number of the intents: %v", i, n)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+// staticFlow is a flow installed by an operator, which is installed again whenever its device reconnects
+// because the normal flows are removed from a device when it connects.
+type staticFlow struct {
+	flow openflow.FlowMod
+	// Time when the flow has been installed last.
+	timestamp time.Time
+}
+
+// expired returns whether the flow would have been removed from the device by its timeouts at now. The idle
+// timeout is regarded as the hard timeout as the one of flowIntent.
+func (r staticFlow) expired(now time.Time) bool {
+	elapsed := now.Sub(r.timestamp)
+	if v := r.flow.IdleTimeout(); v != 0 && elapsed >= time.Duration(v)*time.Second {
+		return true
+	}
+	if v := r.flow.HardTimeout(); v != 0 && elapsed >= time.Duration(v)*time.Second {
+		return true
+	}
+
+	return false
+}
+
+type staticFlows struct {
+	// OpenFlow version of the connection that the flows have been installed on.
+	version uint8
+	// Key is the table ID, priority, and match of a flow.
+	flows map[string]staticFlow
+}
+
+// setStatic records the static flow installed on the device whose DPID is dpid. An added flow replaces the
+// static flow that has the same table ID, priority, and match. A deleted flow removes the static flows whose
+// match is the same as or more specific than its match regardless of their priorities, as the device does for
+// a non-strict delete. So, all the static flows are removed if it has no match field.
+func (r *intentStore) setStatic(dpid string, flow openflow.FlowMod) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.statics[dpid]
+	if !ok || v.version != flow.Version() {
+		v = staticFlows{version: flow.Version(), flows: make(map[string]staticFlow)}
+		r.statics[dpid] = v
+	}
+
+	switch flow.Command() {
+	case openflow.FlowAdd, openflow.FlowModify:
+		v.flows[appFlowKey(flow.TableID(), flow.Priority(), flow.FlowMatch())] = staticFlow{flow: flow, timestamp: r.clock.Now()}
+	case openflow.FlowDelete:
+		fields := openflow.MatchFields(flow.FlowMatch())
+		for k, s := range v.flows {
+			if coversMatch(fields, openflow.MatchFields(s.flow.FlowMatch())) {
+				delete(v.flows, k)
+			}
+		}
+	}
+}
+
+// coversMatch returns whether every field of match is also in target with the same or a more specific value,
+// i.e., target is a subset of the packets matching match.
+func coversMatch(match, target []openflow.MatchField) bool {
+	values := make(map[openflow.MatchFieldType]openflow.MatchField)
+	for _, v := range target {
+		values[v.Type] = v
+	}
+
+	for _, m := range match {
+		t, ok := values[m.Type]
+		if !ok || !coversField(m, t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func coversField(match, target openflow.MatchField) bool {
+	// The IP prefixes cover their longer prefixes.
+	if m, ok := match.Value.(*net.IPNet); ok {
+		t, ok := target.Value.(*net.IPNet)
+		if !ok {
+			return false
+		}
+		mOnes, _ := m.Mask.Size()
+		tOnes, _ := t.Mask.Size()
+		return tOnes >= mOnes && m.Contains(t.IP)
+	}
+	// The target should match all the bits of the metadata mask with the same values.
+	if match.Type == openflow.MatchMetadata {
+		m, t := match.Value.(uint64), target.Value.(uint64)
+		return target.Mask&match.Mask == match.Mask && m&match.Mask == t&match.Mask
+	}
+
+	return fmt.Sprintf("%v", match.Value) == fmt.Sprintf("%v", target.Value)
+}
+
+// staticFlowsOf returns the static flows of the device whose DPID is dpid that are not yet expired. The static
+// flows are discarded if the device has reconnected with a different OpenFlow version.
+func (r *intentStore) staticFlowsOf(dpid string, version uint8) []openflow.FlowMod {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.statics[dpid]
+	if !ok {
+		return nil
+	}
+	if v.version != version {
+		logger.Warningf("discarded %v static flows of the device reconnected with a different OpenFlow version: DPID=%v", len(v.flows), dpid)
+		delete(r.statics, dpid)
+		return nil
+	}
+
+	now := r.clock.Now()
+	result := make([]openflow.FlowMod, 0, len(v.flows))
+	for k, s := range v.flows {
+		if s.expired(now) {
+			delete(v.flows, k)
+			continue
+		}
+		result = append(result, s.flow)
+	}
+
+	return result
+}
@@ -0,0 +1,169 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func newStaticFlow(t *testing.T, f openflow.Factory, cmd openflow.FlowModCmd, mac string, priority, hardTimeout uint16) openflow.FlowMod {
+	flow, err := f.NewFlowMod(cmd)
+	if err != nil {
+		t.Fatalf("failed to create a flow-mod: %v", err)
+	}
+	match, err := f.NewMatch()
+	if err != nil {
+		t.Fatalf("failed to create a match: %v", err)
+	}
+	if mac != "" {
+		v, err := net.ParseMAC(mac)
+		if err != nil {
+			t.Fatalf("invalid MAC address: %v", err)
+		}
+		match.SetDstMAC(v)
+	}
+	flow.SetFlowMatch(match)
+	flow.SetPriority(priority)
+	flow.SetHardTimeout(hardTimeout)
+
+	return flow
+}
+
+func TestStaticFlows(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+	// Zero grace period does not affect the static flows.
+	store := newIntentStore(0)
+	store.clock = clk
+
+	f := of13.NewFactory()
+	store.setStatic("1", newStaticFlow(t, f, openflow.FlowAdd, "00:00:00:00:00:01", 25, 0))
+	store.setStatic("1", newStaticFlow(t, f, openflow.FlowAdd, "00:00:00:00:00:01", 26, 0))
+	// Replaces the existing one.
+	store.setStatic("1", newStaticFlow(t, f, openflow.FlowAdd, "00:00:00:00:00:01", 26, 0))
+	store.setStatic("1", newStaticFlow(t, f, openflow.FlowAdd, "00:00:00:00:00:02", 25, 0))
+	store.setStatic("1", newStaticFlow(t, f, openflow.FlowAdd, "00:00:00:00:00:03", 25, 60))
+	if n := len(store.staticFlowsOf("1", openflow.OF13_VERSION)); n != 4 {
+		t.Fatalf("expected 4 static flows, got %v", n)
+	}
+	// The flows are kept after they have been reinstalled.
+	if n := len(store.staticFlowsOf("1", openflow.OF13_VERSION)); n != 4 {
+		t.Fatalf("expected 4 static flows again, got %v", n)
+	}
+
+	// Removes the flows of the match regardless of their priorities.
+	store.setStatic("1", newStaticFlow(t, f, openflow.FlowDelete, "00:00:00:00:00:01", 0, 0))
+	if n := len(store.staticFlowsOf("1", openflow.OF13_VERSION)); n != 2 {
+		t.Fatalf("expected 2 static flows after the delete, got %v", n)
+	}
+	// Expired by the hard timeout.
+	clk.Advance(time.Minute)
+	if n := len(store.staticFlowsOf("1", openflow.OF13_VERSION)); n != 1 {
+		t.Fatalf("expected 1 static flow after the expiration, got %v", n)
+	}
+	if n := len(store.staticFlowsOf("2", openflow.OF13_VERSION)); n != 0 {
+		t.Fatalf("expected no static flow of the other device, got %v", n)
+	}
+	// Reconnected with a different protocol version.
+	if n := len(store.staticFlowsOf("1", openflow.OF10_VERSION)); n != 0 {
+		t.Fatalf("expected no static flow of the different version, got %v", n)
+	}
+	if n := len(store.staticFlowsOf("1", openflow.OF13_VERSION)); n != 0 {
+		t.Fatalf("expected the static flows to be discarded, got %v", n)
+	}
+
+	// A delete without match fields removes all the static flows.
+	f10 := of10.NewFactory()
+	store.setStatic("3", newStaticFlow(t, f10, openflow.FlowAdd, "00:00:00:00:00:01", 25, 0))
+	store.setStatic("3", newStaticFlow(t, f10, openflow.FlowAdd, "00:00:00:00:00:02", 25, 0))
+	store.setStatic("3", newStaticFlow(t, f10, openflow.FlowDelete, "", 0, 0))
+	if n := len(store.staticFlowsOf("3", openflow.OF10_VERSION)); n != 0 {
+		t.Fatalf("expected no static flow after deleting all, got %v", n)
+	}
+}
+
+func TestStaticFlowNonStrictDelete(t *testing.T) {
+	f := of13.NewFactory()
+	mac1, _ := net.ParseMAC("00:00:00:00:00:01")
+	mac2, _ := net.ParseMAC("00:00:00:00:00:02")
+	_, prefix8, _ := net.ParseCIDR("10.0.0.0/8")
+	_, prefix24, _ := net.ParseCIDR("10.1.2.0/24")
+	_, other, _ := net.ParseCIDR("192.168.0.0/24")
+	inPort := openflow.NewInPort()
+	inPort.SetValue(1)
+
+	newFlow := func(cmd openflow.FlowModCmd, priority uint16, set func(openflow.Match)) openflow.FlowMod {
+		flow := newStaticFlow(t, f, cmd, "", priority, 0)
+		set(flow.FlowMatch())
+		return flow
+	}
+	stored := []openflow.FlowMod{
+		newFlow(openflow.FlowAdd, 10, func(m openflow.Match) { m.SetDstMAC(mac1) }),
+		newFlow(openflow.FlowAdd, 11, func(m openflow.Match) { m.SetDstMAC(mac1); m.SetInPort(inPort) }),
+		newFlow(openflow.FlowAdd, 12, func(m openflow.Match) { m.SetDstMAC(mac2) }),
+		newFlow(openflow.FlowAdd, 13, func(m openflow.Match) { m.SetEtherType(0x0800); m.SetDstIP(prefix24) }),
+		newFlow(openflow.FlowAdd, 14, func(m openflow.Match) { m.SetEtherType(0x0800); m.SetDstIP(other) }),
+	}
+
+	src := []struct {
+		set func(openflow.Match)
+		// Priorities of the remaining flows.
+		remaining []uint16
+	}{
+		// The flows whose match has more fields are also removed.
+		{func(m openflow.Match) { m.SetDstMAC(mac1) }, []uint16{12, 13, 14}},
+		// Less specific flows are kept.
+		{func(m openflow.Match) { m.SetDstMAC(mac1); m.SetInPort(inPort) }, []uint16{10, 12, 13, 14}},
+		// The shorter prefix covers the longer one.
+		{func(m openflow.Match) { m.SetEtherType(0x0800); m.SetDstIP(prefix8) }, []uint16{10, 11, 12, 14}},
+		// Every flow matches the empty match.
+		{func(m openflow.Match) {}, []uint16{}},
+	}
+
+	for i, v := range src {
+		store := newIntentStore(0)
+		for _, s := range stored {
+			store.setStatic("1", s)
+		}
+		store.setStatic("1", newFlow(openflow.FlowDelete, 0, v.set))
+
+		remaining := make(map[uint16]bool)
+		for _, s := range store.staticFlowsOf("1", openflow.OF13_VERSION) {
+			remaining[s.Priority()] = true
+		}
+		if len(remaining) != len(v.remaining) {
+			t.Fatalf("#%v: unexpected remaining flows: expected=%v, got=%v", i, v.remaining, remaining)
+		}
+		for _, p := range v.remaining {
+			if !remaining[p] {
+				t.Fatalf("#%v: unexpected remaining flows: expected=%v, got=%v", i, v.remaining, remaining)
+			}
+		}
+	}
+}
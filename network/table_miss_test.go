@@ -0,0 +1,84 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
+
+	"github.com/superkkt/viper"
+)
+
+func TestControllerTableMiss(t *testing.T) {
+	// Restore the default whole packet.
+	defer viper.Set("default.table_miss_max_len", int(openflow.NoBufferMaxLen))
+
+	src := []struct {
+		Factory openflow.Factory
+		TableID uint8
+		MaxLen  int // Negative means the default.
+	}{
+		{of13.NewFactory(), 0, -1},
+		{of13.NewFactory(), 200, 128},
+		{of10.NewFactory(), 0, 128},
+	}
+
+	for i, v := range src {
+		if v.MaxLen >= 0 {
+			viper.Set("default.table_miss_max_len", v.MaxLen)
+		}
+		expectedMaxLen := uint16(openflow.NoBufferMaxLen)
+		if v.MaxLen >= 0 {
+			expectedMaxLen = uint16(v.MaxLen)
+		}
+
+		recorder := new(messageRecorder)
+		if err := setControllerTableMiss(v.Factory, recorder, v.TableID); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		msgs := recorder.get()
+		if len(msgs) != 1 {
+			t.Fatalf("#%v: unexpected number of messages: %v", i, len(msgs))
+		}
+		flow, ok := msgs[0].(openflow.FlowMod)
+		if !ok {
+			t.Fatalf("#%v: unexpected message: %T", i, msgs[0])
+		}
+		if flow.Priority() != 0 {
+			t.Fatalf("#%v: unexpected priority: %v", i, flow.Priority())
+		}
+		// The MSB marker protects the flow from RemoveFlows.
+		if flow.Cookie()&(0x1<<63) == 0 {
+			t.Fatalf("#%v: cookie marker is not set: %#x", i, flow.Cookie())
+		}
+		if flow.TableID() != v.TableID {
+			t.Fatalf("#%v: unexpected table ID: expected=%v, actual=%v", i, v.TableID, flow.TableID())
+		}
+		outPort := flow.FlowInstruction().Action().OutPort()
+		if !outPort.IsController() || outPort.MaxLen() != expectedMaxLen {
+			t.Fatalf("#%v: unexpected output: controller=%v, maxLen=%v", i, outPort.IsController(), outPort.MaxLen())
+		}
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+	"github.com/superkkt/cherry/openflow"
+)
+
+const (
+	// Throttle flows have the MSB of their cookie so that RemoveFlows does not remove them. They are
+	// only removed when their hard timeout expires.
+	throttleCookie = 0x1<<63 | 0x1<<60
+	// Just above the table-miss flow so that only the packets that would be sent to the controller are
+	// dropped, and the flows already installed for the port keep working.
+	throttlePriority = 1
+)
+
+// tokenBucket allows rate events per second on average with bursts of up to burst events.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (r *tokenBucket) take(now time.Time) bool {
+	if r.last.IsZero() {
+		r.tokens = r.burst
+	} else if elapsed := now.Sub(r.last).Seconds(); elapsed > 0 {
+		r.tokens += elapsed * r.rate
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+	}
+	r.last = now
+
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+
+	return true
+}
+
+// packetInLimiter limits the rate of the PACKET_INs from each ingress port of a device. A port that
+// exceeds the rate is throttled during the cooldown period.
+type packetInLimiter struct {
+	mutex    sync.Mutex
+	clock    clock.Clock
+	rate     float64
+	burst    float64
+	cooldown time.Duration
+	// Key is a port number.
+	buckets map[uint32]*tokenBucket
+	// Key is a port number, and value is the time when the throttling is lifted.
+	throttled map[uint32]time.Time
+}
+
+func newPacketInLimiter(rate, burst int, cooldown time.Duration) *packetInLimiter {
+	if rate <= 0 {
+		panic("invalid PACKET_IN rate")
+	}
+	if burst < rate {
+		burst = rate
+	}
+
+	return &packetInLimiter{
+		clock:     clock.New(),
+		rate:      float64(rate),
+		burst:     float64(burst),
+		cooldown:  cooldown,
+		buckets:   make(map[uint32]*tokenBucket),
+		throttled: make(map[uint32]time.Time),
+	}
+}
+
+// allow returns whether a PACKET_IN from the port whose number is port should be processed. engaged is true
+// if the port has just exceeded the rate and the throttling begins.
+func (r *packetInLimiter) allow(port uint32) (ok, engaged bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.clock.Now()
+	if until, ok := r.throttled[port]; ok {
+		if now.Before(until) {
+			return false, false
+		}
+		delete(r.throttled, port)
+		// Start again with a full bucket.
+		delete(r.buckets, port)
+	}
+
+	bucket, ok := r.buckets[port]
+	if !ok {
+		bucket = &tokenBucket{rate: r.rate, burst: r.burst}
+		r.buckets[port] = bucket
+	}
+	if bucket.take(now) {
+		return true, false
+	}
+	r.throttled[port] = now.Add(r.cooldown)
+
+	return false, true
+}
+
+// newThrottleFlow returns a flow that drops the packets from port that do not match any other flow,
+// i.e., the ones that would be sent to the controller, until timeout expires.
+func newThrottleFlow(f openflow.Factory, port uint32, tableID uint8, timeout time.Duration) (openflow.FlowMod, error) {
+	inPort := openflow.NewInPort()
+	inPort.SetValue(port)
+
+	match, err := f.NewMatch()
+	if err != nil {
+		return nil, err
+	}
+	match.SetInPort(inPort)
+
+	flow, err := f.NewFlowMod(openflow.FlowAdd)
+	if err != nil {
+		return nil, err
+	}
+	flow.SetCookie(throttleCookie)
+	flow.SetTableID(tableID)
+	flow.SetHardTimeout(uint16(timeout / time.Second))
+	flow.SetPriority(throttlePriority)
+	// No instruction means dropping the matched packets.
+	flow.SetFlowMatch(match)
+
+	return flow, nil
+}
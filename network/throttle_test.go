@@ -0,0 +1,139 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+	"github.com/superkkt/cherry/openflow/of13"
+	"github.com/superkkt/cherry/openflow/transceiver"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &tokenBucket{rate: 2, burst: 4}
+
+	src := []struct {
+		elapsed time.Duration
+		ok      bool
+	}{
+		// Burst.
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, false},
+		// Refilled by the rate.
+		{500 * time.Millisecond, true},
+		{0, false},
+		// Not more than the burst.
+		{10 * time.Second, true},
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, false},
+	}
+	for i, v := range src {
+		now = now.Add(v.elapsed)
+		if ok := b.take(now); ok != v.ok {
+			t.Fatalf("#%v: expected=%v, got=%v", i, v.ok, ok)
+		}
+	}
+}
+
+func TestPacketInThrottling(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	s := new(session)
+	s.transceiver = transceiver.NewTransceiver(transceiver.NewStream(local, 0xFFFF), s)
+	s.writer = transceiverWriter{s.transceiver}
+	d := &Device{
+		session: s,
+		factory: of13.NewFactory(),
+		limiter: newPacketInLimiter(10, 10, 10*time.Second),
+	}
+	clk := clock.NewFake(time.Now())
+	d.limiter.clock = clk
+	s.device = d
+	port := NewPort(d, 3)
+
+	// PACKET_INs within the burst are allowed.
+	for i := 0; i < 10; i++ {
+		if !d.allowPacketIn(port) {
+			t.Fatalf("#%v: unexpected throttling", i)
+		}
+	}
+	// The PACKET_IN exceeding the rate engages the throttling.
+	if d.allowPacketIn(port) {
+		t.Fatal("expected throttling")
+	}
+
+	// A drop flow should be installed.
+	remote.SetReadDeadline(time.Now().Add(5 * time.Second))
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(remote, header); err != nil {
+		t.Fatalf("failed to read the drop flow: %v", err)
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[2:4])-8)
+	if _, err := io.ReadFull(remote, body); err != nil {
+		t.Fatalf("failed to read the drop flow: %v", err)
+	}
+	msg := append(header, body...)
+	if msg[1] != of13.OFPT_FLOW_MOD {
+		t.Fatalf("unexpected message type: %v", msg[1])
+	}
+	if cookie := binary.BigEndian.Uint64(msg[8:16]); cookie != throttleCookie {
+		t.Fatalf("unexpected cookie: %x", cookie)
+	}
+	if timeout := binary.BigEndian.Uint16(msg[28:30]); timeout != 10 {
+		t.Fatalf("unexpected hard timeout: %v", timeout)
+	}
+	if priority := binary.BigEndian.Uint16(msg[30:32]); priority != throttlePriority {
+		t.Fatalf("unexpected priority: %v", priority)
+	}
+	// The padded match of the input port, and no instruction to drop the packets.
+	if len(msg) != 48+16 || binary.BigEndian.Uint32(msg[56:60]) != 3 {
+		t.Fatalf("unexpected length of the drop flow: %v", len(msg))
+	}
+
+	// Still throttled without installing the flow again.
+	clk.Advance(5 * time.Second)
+	if d.allowPacketIn(port) {
+		t.Fatal("expected throttling during the cooldown")
+	}
+	// The other ports are not affected.
+	if !d.allowPacketIn(NewPort(d, 4)) {
+		t.Fatal("unexpected throttling of the other port")
+	}
+	// Released after the cooldown.
+	clk.Advance(5 * time.Second)
+	if !d.allowPacketIn(port) {
+		t.Fatal("unexpected throttling after the cooldown")
+	}
+}
@@ -25,19 +25,28 @@ import (
 	"bytes"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/superkkt/cherry/graph"
+	"github.com/superkkt/cherry/protocol"
 
 	"github.com/pkg/errors"
 )
 
 type watcher interface {
-	DeviceAdded(*Device)
+	// DeviceAdded adds the device and returns true, or returns false without adding the device if another
+	// device that has the same ID already exists.
+	DeviceAdded(*Device) bool
 	DeviceLinked([2]*Port)
 	DeviceRemoved(*Device)
 	PortRemoved(*Port)
+	PortSpeedChanged(*Port)
+	// PacketReceived is called for each PACKET_IN before it is delivered to the applications, and returns
+	// whether the packet should be delivered. It returns false if the source MAC address is pinned to
+	// another port because of a flap.
+	PacketReceived(*Port, *protocol.Ethernet) bool
 }
 
 type Finder interface {
@@ -47,17 +56,47 @@ type Finder interface {
 	IsEnabledBySTP(p *Port) bool
 	// IsEdge returns whether p is an edge among two switches
 	IsEdge(p *Port) bool
+	// IsEnabledPort returns whether p can be used to forward packets, which is false only if p is
+	// an edge among two switches that is blocked by the spanning tree to prevent a loop.
+	IsEnabledPort(p *Port) bool
 	Node(mac net.HardwareAddr) (*Node, LocationStatus, error)
-	Path(srcDeviceID, dstDeviceID string) [][2]*Port
+	// Nodes returns all the known locations of mac, the most recently updated one first.
+	Nodes(mac net.HardwareAddr) ([]*Node, LocationStatus, error)
+	// AllNodes returns the known locations of all the discovered nodes sorted by the MAC address. The
+	// returned nodes are a snapshot that is not affected by the later changes.
+	AllNodes() ([]*Node, error)
+	// Path returns the path from the source device toward the destination device over the spanning tree,
+	// and its cost that is the sum of the link weights. The weights are inversely proportional to the port
+	// speeds, so the spanning tree, and thus the path, prefers the faster links even if it has more hops.
+	Path(srcDeviceID, dstDeviceID string) (path [][2]*Port, cost float64)
+	// EqualCostPorts returns the ports on the source device that have the same cost toward the
+	// destination device as the first hop of Path, including the parallel links blocked by the
+	// spanning tree.
+	EqualCostPorts(srcDeviceID, dstDeviceID string) []*Port
+	// Tree returns a multicast distribution tree from source toward all the members.
+	Tree(source *Port, members []*Port) DistributionTree
+	// DHCPBinding returns the host to which ip has been assigned by a DHCP server. ok is false
+	// if no DHCP ACK assigning ip has been seen or its lease has expired.
+	DHCPBinding(ip net.IP) (binding DHCPBinding, ok bool)
+	// DHCPBindings returns all the unexpired DHCP bindings sorted by the IP address.
+	DHCPBindings() []DHCPBinding
 }
 
+// topology is the set of the connected devices and the links among them. devices and graph are guarded by
+// mutex, and a device is added to and removed from both of them in the same critical section. The methods
+// that return devices, such as Devices, return a snapshot that the caller can iterate without any lock while
+// other devices are added or removed, so the returned devices may have been closed in the meantime.
 type topology struct {
 	mutex sync.RWMutex
-	// Key is the device ID
+	// Key is the device ID. A device ID is owned by the first added device until it is removed, and only its owner can remove it.
 	devices  map[string]*Device
 	graph    *graph.Graph
 	listener TopologyEventListener
 	db       database
+	aging    *nodeAging
+	dhcp     *dhcpSnooping
+	flap     *macFlapDetector
+	feed     *deviceEventFeed
 }
 
 func newTopology(db database) *topology {
@@ -65,8 +104,15 @@ func newTopology(db database) *topology {
 		devices: make(map[string]*Device),
 		graph:   graph.New(),
 		db:      db,
+		aging:   newNodeAging(nodeAgingTimeout()),
+		dhcp:    newDHCPSnooping(dhcpTrustedPorts()),
+		flap:    newMACFlapDetector(macFlapConfig()),
+		feed:    newDeviceEventFeed(),
 	}
 	go v.staleEdgeRemover()
+	if v.aging.timeout > 0 {
+		go v.expiredNodeRemover()
+	}
 
 	return v
 }
@@ -99,6 +145,7 @@ func (r *topology) sendEvent() {
 	}
 }
 
+// Devices returns a snapshot of the devices, which is not affected by the later changes of the topology.
 func (r *topology) Devices() []*Device {
 	// Read lock
 	r.mutex.RLock()
@@ -121,36 +168,65 @@ func (r *topology) Device(id string) *Device {
 	return r.devices[id]
 }
 
-func (r *topology) DeviceAdded(d *Device) {
+func (r *topology) DeviceAdded(d *Device) bool {
+	var added bool
 	// NOTE: This is an anonymous function (NOT a goroutine!) that has a critical section.
 	func() {
 		// Write lock
 		r.mutex.Lock()
 		defer r.mutex.Unlock()
 
-		r.devices[d.ID()] = d
+		// The check and the addition are done in the same critical section, so only one of the devices that
+		// have the same ID can be added even if they connect at the same time.
+		id := d.ID()
+		if _, ok := r.devices[id]; ok {
+			return
+		}
+		r.devices[id] = d
 		r.graph.AddVertex(d)
+		added = true
 	}()
+	if !added {
+		return false
+	}
+	r.feed.publish(DeviceConnected, d)
 	// XXX: Make sure the mutex is unlocked before calling sendEvent().
 	r.sendEvent()
+
+	return true
 }
 
+// removeDevice removes d from the device database and the graph, and returns whether d has been removed. It
+// does nothing if d has not been added, e.g., it has been rejected because of its duplicated ID.
 // XXX: Caller should lock the mutex
-func (r *topology) removeDevice(d *Device) {
-	// Remove from the device database
-	delete(r.devices, d.ID())
+func (r *topology) removeDevice(d *Device) bool {
+	id := d.ID()
+	if r.devices[id] != d {
+		return false
+	}
+	delete(r.devices, id)
+	r.graph.RemoveVertex(d)
+
+	return true
 }
 
 func (r *topology) DeviceRemoved(d *Device) {
+	var removed bool
 	// NOTE: This is an anonymous function (NOT a goroutine!) that has a critical section.
 	func() {
 		// Write lock
 		r.mutex.Lock()
 		defer r.mutex.Unlock()
 
-		r.removeDevice(d)
-		r.graph.RemoveVertex(d)
+		removed = r.removeDevice(d)
 	}()
+	if !removed {
+		logger.Debugf("ignoring the removal of the device that has not been added: DPID=%v", d.ID())
+		return
+	}
+	r.dhcp.forget(func(p *Port) bool { return p.Device() == d })
+	r.flap.forget(func(p *Port) bool { return p.Device() == d })
+	r.feed.publish(DeviceDisconnected, d)
 	// XXX: Make sure the mutex is unlocked before calling sendEvent().
 	r.sendEvent()
 }
@@ -181,30 +257,101 @@ func (r *topology) DeviceLinked(ports [2]*Port) {
 	}
 }
 
-// Node may return nil if the node is unregistered or still undiscovered.
+// Node may return nil if the node is unregistered or still undiscovered. If the node has several
+// locations, Node returns the most recently updated one.
 func (r *topology) Node(mac net.HardwareAddr) (*Node, LocationStatus, error) {
+	nodes, status, err := r.Nodes(mac)
+	if err != nil || status != LocationDiscovered {
+		return nil, status, err
+	}
+
+	return nodes[0], LocationDiscovered, nil
+}
+
+// Nodes returns all the known locations of mac in the order of the most recently updated one
+// first. The same MAC address can legitimately appear on several switches, e.g., virtualized hosts
+// in different VLANs. Nodes returns nil if the node is unregistered or still undiscovered.
+func (r *topology) Nodes(mac net.HardwareAddr) ([]*Node, LocationStatus, error) {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.nodes(mac)
+}
+
+// AllNodes returns the known locations of all the discovered nodes sorted by the MAC address. The nodes of
+// the same MAC address are in the order of the most recently updated one first.
+func (r *topology) AllNodes() ([]*Node, error) {
+	macs, err := r.db.MACAddrs()
+	if err != nil {
+		return nil, errors.Wrap(&networkErr{temporary: true, err: err}, "querying MAC addresses to the database")
+	}
+	sort.Slice(macs, func(i, j int) bool { return bytes.Compare(macs[i], macs[j]) < 0 })
+
 	// Read lock
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	dpid, portNum, status, err := r.db.Location(mac)
+	result := []*Node{}
+	for _, mac := range macs {
+		nodes, status, err := r.nodes(mac)
+		if err != nil {
+			return nil, err
+		}
+		if status != LocationDiscovered {
+			continue
+		}
+		result = append(result, nodes...)
+	}
+
+	return result, nil
+}
+
+// nodes should be called with the read lock held.
+func (r *topology) nodes(mac net.HardwareAddr) ([]*Node, LocationStatus, error) {
+	locations, status, err := r.db.Locations(mac)
 	if err != nil {
-		return nil, status, errors.Wrap(&networkErr{temporary: true, err: err}, "querying host location to the database")
+		return nil, status, errors.Wrap(&networkErr{temporary: true, err: err}, "querying host locations to the database")
 	}
 	if status != LocationDiscovered {
 		return nil, status, nil
 	}
+	// The node has not been seen for a long time, so we don't know its physical location anymore.
+	if locations = r.aging.filter(locations); len(locations) == 0 {
+		return nil, LocationUndiscovered, nil
+	}
 
-	device, ok := r.devices[dpid]
-	if !ok {
-		return nil, LocationUnregistered, nil
+	nodes := make([]*Node, 0, len(locations))
+	// Key is the device ID and the VLAN ID.
+	seen := make(map[string]bool)
+	for _, v := range locations {
+		// A host cannot be on two ports of a switch in the same VLAN at the same time, so the older locations
+		// on the same switch in the same VLAN are stale ones left after the host has moved to another port.
+		// The same MAC address can legitimately be in the different VLANs, e.g., a virtualized host.
+		key := fmt.Sprintf("%v/%v", v.DPID, v.VLANID)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		device, ok := r.devices[v.DPID]
+		if !ok {
+			continue
+		}
+		port := device.Port(v.Port)
+		if port == nil {
+			continue
+		}
+		node := NewNode(port, mac)
+		node.vlanID = v.VLANID
+		node.lastSeen = v.Timestamp
+		nodes = append(nodes, node)
 	}
-	port := device.Port(portNum)
-	if port == nil {
+	if len(nodes) == 0 {
 		return nil, LocationUnregistered, nil
 	}
 
-	return NewNode(port, mac), LocationDiscovered, nil
+	return nodes, LocationDiscovered, nil
 }
 
 func (r *topology) PortRemoved(p *Port) {
@@ -221,6 +368,8 @@ func (r *topology) PortRemoved(p *Port) {
 			r.graph.RemoveEdge(p)
 		}
 	}()
+	r.dhcp.forget(func(v *Port) bool { return v == p })
+	r.flap.forget(func(v *Port) bool { return v == p })
 
 	if edge {
 		// XXX: Make sure the mutex is unlocked before calling sendEvent().
@@ -228,28 +377,102 @@ func (r *topology) PortRemoved(p *Port) {
 	}
 }
 
-func (r *topology) Path(srcDeviceID, dstDeviceID string) [][2]*Port {
+func (r *topology) PacketReceived(ingress *Port, eth *protocol.Ethernet) bool {
+	r.dhcp.snoop(ingress, eth)
+	// The ports between switches receive the packets of all the hosts behind the other switches.
+	if r.IsEdge(ingress) {
+		return true
+	}
+
+	return r.flap.observe(ingress, eth.SrcMAC)
+}
+
+func (r *topology) DHCPBinding(ip net.IP) (DHCPBinding, bool) {
+	return r.dhcp.binding(ip)
+}
+
+func (r *topology) DHCPBindings() []DHCPBinding {
+	return r.dhcp.all()
+}
+
+// PortSpeedChanged updates the weight of the link on p, if any, and then
+// notifies the topology change so that the paths are calculated again.
+func (r *topology) PortSpeedChanged(p *Port) {
+	edge := false
+
+	// NOTE: This is an anonymous function (NOT a goroutine!) that has a critical section.
+	func() {
+		// Write lock
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		edge = r.graph.UpdateEdge(p)
+	}()
+
+	if edge {
+		// XXX: Make sure the mutex is unlocked before calling sendEvent().
+		r.sendEvent()
+	}
+}
+
+func (r *topology) Path(srcDeviceID, dstDeviceID string) (path [][2]*Port, cost float64) {
 	// Read lock
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	v := make([][2]*Port, 0)
+	return r.path(srcDeviceID, dstDeviceID)
+}
+
+// EqualCostPorts returns the ports on the source device that have the same cost toward the destination
+// device as the first hop of Path, sorted by their port numbers. They are the first hop port itself and
+// the ports of the parallel links toward the same next hop device with the same weight, which may be
+// blocked by the spanning tree. It returns nil if there is no path.
+func (r *topology) EqualCostPorts(srcDeviceID, dstDeviceID string) []*Port {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	path, _ := r.path(srcDeviceID, dstDeviceID)
+	if len(path) == 0 {
+		return nil
+	}
+	first := r.graph.Edges(path[0][0].Device())
+	next := path[0][1].Device().ID()
+	weight := newLink(path[0]).Weight()
+
+	result := make([]*Port, 0)
+	for _, e := range first {
+		l := e.(*link)
+		ports := pickPort(path[0][0].Device(), l)
+		if ports[1].Device().ID() != next || l.Weight() != weight {
+			continue
+		}
+		result = append(result, ports[0])
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Number() < result[j].Number() })
+
+	return result
+}
+
+// path should be called with the mutex locked.
+func (r *topology) path(srcDeviceID, dstDeviceID string) (path [][2]*Port, cost float64) {
+	path = make([][2]*Port, 0)
 	src := r.devices[srcDeviceID]
 	dst := r.devices[dstDeviceID]
 	// Unknown source or destination device?
 	if src == nil || dst == nil {
 		// Return empty path
-		return v
+		return path, 0
 	}
 
-	path := r.graph.FindPath(src, dst)
-	for _, p := range path {
+	for _, p := range r.graph.FindPath(src, dst) {
 		device := p.V.(*Device)
 		link := p.E.(*link)
-		v = append(v, pickPort(device, link))
+		path = append(path, pickPort(device, link))
+		cost += link.Weight()
 	}
 
-	return v
+	return path, cost
 }
 
 func pickPort(d *Device, l *link) [2]*Port {
@@ -261,14 +484,71 @@ func pickPort(d *Device, l *link) [2]*Port {
 	return [2]*Port{p[1].(*Port), p[0].(*Port)}
 }
 
+// IsEdge returns true only if p is on a link among two connected switches that has been confirmed by LLDP
+// within the link timeout. A port of a disconnected device, including the ports of its previous connection
+// that have the same IDs as the current ones, and a port whose link has not been seen for a while, which will
+// be removed as a stale one, are treated as host-facing ports.
 func (r *topology) IsEdge(p *Port) bool {
-	return r.graph.IsEdge(p)
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if d := p.Device(); d == nil || r.devices[d.ID()] != d {
+		return false
+	}
+
+	return r.graph.IsFreshEdge(p, linkTimeout())
 }
 
 func (r *topology) IsEnabledBySTP(p *Port) bool {
 	return r.graph.IsEnabledPoint(p)
 }
 
+func (r *topology) IsEnabledPort(p *Port) bool {
+	// Host-facing ports are always enabled. The spanning tree only blocks the links among
+	// switches that make a loop, and it always keeps the switches connected so that the
+	// unicast paths calculated over the tree are still available.
+	return !r.graph.IsEdge(p) || r.graph.IsEnabledPoint(p)
+}
+
+// expiredNodeRemover removes the flows toward the nodes whose locations have been expired, in the same
+// way as the flows heading to a port are removed when the port goes down.
+func (r *topology) expiredNodeRemover() {
+	ticker := time.Tick(r.aging.timeout / 2)
+
+	// Infinite loop.
+	for range ticker {
+		r.removeExpiredNodes()
+	}
+}
+
+func (r *topology) removeExpiredNodes() {
+	nodes, err := r.db.MACAddrs()
+	if err != nil {
+		logger.Errorf("failed to query the MAC addresses of the nodes: %v", err)
+		return
+	}
+
+	for _, mac := range nodes {
+		locations, status, err := r.db.Locations(mac)
+		if err != nil {
+			logger.Errorf("failed to query the locations of %v: %v", mac, err)
+			continue
+		}
+		if status != LocationDiscovered || !r.aging.purge(mac, locations) {
+			continue
+		}
+
+		logger.Infof("node %v has been expired: removing the flows toward the node", mac)
+		// XXX: Make sure the mutex is unlocked before removing the flows.
+		for _, device := range r.Devices() {
+			if err := device.RemoveFlowByMAC(mac); err != nil {
+				logger.Errorf("failed to remove flows for %v from %v: %v", mac, device.ID(), err)
+			}
+		}
+	}
+}
+
 // staleEdgeRemover removes stale edges that have not been updated for a long time.
 func (r *topology) staleEdgeRemover() {
 	ticker := time.Tick(10 * time.Second)
@@ -284,7 +564,7 @@ func (r *topology) staleEdgeRemover() {
 			defer r.mutex.Unlock()
 
 			logger.Debug("trying to remove stale edges from the topology...")
-			removed = r.graph.RemoveStaleEdges(deviceExplorerInterval * 3)
+			removed = r.graph.RemoveStaleEdges(linkTimeout())
 		}()
 
 		// Send the event only if the topology has been changed.
@@ -0,0 +1,661 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/graph"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+type dummyPort struct {
+	openflow.Port
+	number uint32
+	speed  uint64
+}
+
+func (r *dummyPort) Number() uint32 {
+	return r.number
+}
+
+func (r *dummyPort) MAC() net.HardwareAddr {
+	return net.HardwareAddr{0, 0, 0, 0, 0, byte(r.number)}
+}
+
+func (r *dummyPort) IsPortDown() bool {
+	return false
+}
+
+func (r *dummyPort) IsLinkDown() bool {
+	return false
+}
+
+func (r *dummyPort) Speed() uint64 {
+	return r.speed
+}
+
+type topologyEventCounter struct {
+	count int
+}
+
+func (r *topologyEventCounter) OnTopologyChange(Finder) error {
+	r.count++
+	return nil
+}
+
+func newTestPort(d *Device, num uint32, speed uint64) *Port {
+	p := NewPort(d, num)
+	p.SetValue(&dummyPort{number: num, speed: speed})
+
+	return p
+}
+
+func TestLinkWeight(t *testing.T) {
+	d1, d2 := &Device{id: "1"}, &Device{id: "2"}
+
+	src := []struct {
+		Speed    [2]uint64
+		Expected float64
+	}{
+		{Speed: [2]uint64{100000, 100000}, Expected: 1},
+		{Speed: [2]uint64{10000, 10000}, Expected: 10},
+		{Speed: [2]uint64{10000, 1000}, Expected: 100},
+		{Speed: [2]uint64{400000, 400000}, Expected: 1},
+		// Unknown speed.
+		{Speed: [2]uint64{0, 0}, Expected: 1},
+	}
+
+	for i, v := range src {
+		l := newLink([2]*Port{newTestPort(d1, 1, v.Speed[0]), newTestPort(d2, 1, v.Speed[1])})
+		if w := l.Weight(); w != v.Expected {
+			t.Fatalf("#%v: unexpected weight: expected=%v, got=%v", i, v.Expected, w)
+		}
+	}
+}
+
+func TestPortSpeedDowngrade(t *testing.T) {
+	counter := new(topologyEventCounter)
+	topo := &topology{
+		devices:  make(map[string]*Device),
+		graph:    graph.New(),
+		listener: counter,
+		feed:     newDeviceEventFeed(),
+	}
+
+	// Triangle of 10G links: 1(p1) -- (p1)2(p2) -- (p1)3(p2) -- (p2)1.
+	d1, d2, d3 := &Device{id: "1"}, &Device{id: "2"}, &Device{id: "3"}
+	for _, d := range []*Device{d1, d2, d3} {
+		topo.DeviceAdded(d)
+	}
+	p12, p21 := newTestPort(d1, 1, 10000), newTestPort(d2, 1, 10000)
+	p23, p32 := newTestPort(d2, 2, 10000), newTestPort(d3, 1, 10000)
+	p31, p13 := newTestPort(d3, 2, 10000), newTestPort(d1, 2, 10000)
+	topo.DeviceLinked([2]*Port{p12, p21})
+	topo.DeviceLinked([2]*Port{p23, p32})
+	topo.DeviceLinked([2]*Port{p31, p13})
+
+	// Find a link enabled by the spanning tree, and then downgrade it to 1G.
+	var enabled [2]*Port
+	for _, v := range [][2]*Port{{p12, p21}, {p23, p32}, {p31, p13}} {
+		if topo.IsEnabledBySTP(v[0]) {
+			enabled = v
+			break
+		}
+	}
+	if enabled[0] == nil {
+		t.Fatal("no link enabled by the spanning tree")
+	}
+
+	count := counter.count
+	enabled[0].SetValue(&dummyPort{number: enabled[0].Number(), speed: 1000})
+	topo.PortSpeedChanged(enabled[0])
+
+	if counter.count != count+1 {
+		t.Fatalf("expected a topology change event: count=%v", counter.count-count)
+	}
+	// The degraded link should be excluded from the spanning tree in favor of the other 10G links.
+	if topo.IsEnabledBySTP(enabled[0]) || topo.IsEnabledBySTP(enabled[1]) {
+		t.Fatalf("the degraded link is still enabled: %v", enabled[0].ID())
+	}
+	for _, v := range []*Port{p12, p23, p31} {
+		if v == enabled[0] || v == enabled[1] {
+			continue
+		}
+		if !topo.IsEnabledBySTP(v) {
+			t.Fatalf("the 10G link is not enabled: %v", v.ID())
+		}
+	}
+
+	// Speed change on a port that is not on a link does not change the topology.
+	count = counter.count
+	topo.PortSpeedChanged(newTestPort(d1, 10, 1000))
+	if counter.count != count {
+		t.Fatal("unexpected topology change event")
+	}
+}
+
+func newTestTopology(devices ...*Device) *topology {
+	topo := &topology{
+		devices:  make(map[string]*Device),
+		graph:    graph.New(),
+		listener: new(topologyEventCounter),
+		aging:    newNodeAging(0),
+		dhcp:     newDHCPSnooping(nil),
+		flap:     newMACFlapDetector(0, 0, 0),
+		feed:     newDeviceEventFeed(),
+	}
+	for _, d := range devices {
+		topo.DeviceAdded(d)
+	}
+
+	return topo
+}
+
+func countBlockedLinks(t *testing.T, topo *topology, links [][2]*Port) int {
+	blocked := 0
+	for _, v := range links {
+		if topo.IsEnabledPort(v[0]) != topo.IsEnabledPort(v[1]) {
+			t.Fatalf("only one side of the link is blocked: %v <-> %v", v[0].ID(), v[1].ID())
+		}
+		if !topo.IsEnabledPort(v[0]) {
+			blocked++
+		}
+	}
+
+	return blocked
+}
+
+func TestIsEnabledPort(t *testing.T) {
+	d1, d2, d3, d4 := &Device{id: "1"}, &Device{id: "2"}, &Device{id: "3"}, &Device{id: "4"}
+
+	src := []struct {
+		Name    string
+		Devices []*Device
+		Links   [][2]*Port
+		Blocked int
+	}{
+		{
+			Name:    "triangle",
+			Devices: []*Device{d1, d2, d3},
+			Links: [][2]*Port{
+				{newTestPort(d1, 1, 0), newTestPort(d2, 1, 0)},
+				{newTestPort(d2, 2, 0), newTestPort(d3, 1, 0)},
+				{newTestPort(d3, 2, 0), newTestPort(d1, 2, 0)},
+			},
+			Blocked: 1,
+		},
+		{
+			Name:    "diamond",
+			Devices: []*Device{d1, d2, d3, d4},
+			Links: [][2]*Port{
+				{newTestPort(d1, 1, 0), newTestPort(d2, 1, 0)},
+				{newTestPort(d1, 2, 0), newTestPort(d3, 1, 0)},
+				{newTestPort(d2, 2, 0), newTestPort(d4, 1, 0)},
+				{newTestPort(d3, 2, 0), newTestPort(d4, 2, 0)},
+			},
+			Blocked: 1,
+		},
+	}
+
+	for _, v := range src {
+		topo := newTestTopology(v.Devices...)
+		for _, l := range v.Links {
+			topo.DeviceLinked(l)
+		}
+
+		if blocked := countBlockedLinks(t, topo, v.Links); blocked != v.Blocked {
+			t.Fatalf("%v: unexpected number of blocked links: expected=%v, got=%v", v.Name, v.Blocked, blocked)
+		}
+		// Host-facing ports are always enabled.
+		for _, d := range v.Devices {
+			if !topo.IsEnabledPort(newTestPort(d, 10, 0)) {
+				t.Fatalf("%v: host port on device %v is blocked", v.Name, d.ID())
+			}
+		}
+		// Unicast paths are still available among all the devices.
+		for _, src := range v.Devices {
+			for _, dst := range v.Devices {
+				if src == dst {
+					continue
+				}
+				if path, _ := topo.Path(src.ID(), dst.ID()); len(path) == 0 {
+					t.Fatalf("%v: no path from %v to %v", v.Name, src.ID(), dst.ID())
+				}
+			}
+		}
+
+		// Removing an active link should unblock the blocked one.
+		for _, l := range v.Links {
+			if topo.IsEnabledPort(l[0]) {
+				topo.PortRemoved(l[0])
+				break
+			}
+		}
+		for _, l := range v.Links {
+			if topo.IsEdge(l[0]) && !topo.IsEnabledPort(l[0]) {
+				t.Fatalf("%v: link is still blocked after removing an active link: %v", v.Name, l[0].ID())
+			}
+		}
+	}
+}
+
+func TestIsEdge(t *testing.T) {
+	d1, d2, d3 := &Device{id: "1"}, &Device{id: "2"}, &Device{id: "3"}
+	topo := newTestTopology(d1, d2, d3)
+
+	// Trunk ports: 1(p1) -- (p1)2(p2) -- (p1)3, and the parallel link 1(p2) -- (p3)2.
+	trunks := [][2]*Port{
+		{newTestPort(d1, 1, 0), newTestPort(d2, 1, 0)},
+		{newTestPort(d2, 2, 0), newTestPort(d3, 1, 0)},
+		{newTestPort(d1, 2, 0), newTestPort(d2, 3, 0)},
+	}
+	for _, l := range trunks {
+		topo.DeviceLinked(l)
+	}
+	// Host-facing access ports.
+	hosts := []*Port{newTestPort(d1, 10, 0), newTestPort(d2, 10, 0), newTestPort(d3, 2, 0)}
+
+	src := []struct {
+		Port     *Port
+		Expected bool
+	}{
+		{trunks[0][0], true},
+		{trunks[0][1], true},
+		{trunks[1][0], true},
+		{trunks[1][1], true},
+		// Blocked by the spanning tree, but still a link among two switches.
+		{trunks[2][0], true},
+		{trunks[2][1], true},
+		{hosts[0], false},
+		{hosts[1], false},
+		{hosts[2], false},
+		// Same port number as a trunk port but on another device.
+		{newTestPort(d3, 3, 0), false},
+	}
+	for i, v := range src {
+		if edge := topo.IsEdge(v.Port); edge != v.Expected {
+			t.Fatalf("#%v: unexpected IsEdge of %v: expected=%v, got=%v", i, v.Port.ID(), v.Expected, edge)
+		}
+	}
+
+	// The link goes down.
+	topo.PortRemoved(trunks[1][0])
+	if topo.IsEdge(trunks[1][0]) || topo.IsEdge(trunks[1][1]) {
+		t.Fatal("removed link is still an edge")
+	}
+	if !topo.IsEdge(trunks[0][0]) {
+		t.Fatal("unrelated link is not an edge anymore")
+	}
+
+	// Device 2 reconnects: the ports of its previous connection are not edges, nor are their peers
+	// until LLDP confirms the links again.
+	topo.DeviceRemoved(d2)
+	newD2 := &Device{id: "2"}
+	topo.DeviceAdded(newD2)
+	for _, p := range []*Port{trunks[0][0], trunks[0][1], trunks[2][0], trunks[2][1], newTestPort(newD2, 1, 0)} {
+		if topo.IsEdge(p) {
+			t.Fatalf("port %v is still an edge after the device has been disconnected", p.ID())
+		}
+	}
+	l := [2]*Port{trunks[0][0], newTestPort(newD2, 1, 0)}
+	topo.DeviceLinked(l)
+	if !topo.IsEdge(l[0]) || !topo.IsEdge(l[1]) {
+		t.Fatal("link confirmed again is not an edge")
+	}
+	if topo.IsEdge(trunks[0][1]) {
+		t.Fatal("port of the previous connection is an edge")
+	}
+}
+
+type dummyLocationDB struct {
+	locations []Location
+	status    LocationStatus
+}
+
+func (r *dummyLocationDB) Locations(mac net.HardwareAddr) ([]Location, LocationStatus, error) {
+	return r.locations, r.status, nil
+}
+
+func (r *dummyLocationDB) MACAddrs() ([]net.HardwareAddr, error) {
+	return nil, nil
+}
+
+func TestNodes(t *testing.T) {
+	d1, d2 := &Device{id: "1", ports: make(map[uint32]*Port)}, &Device{id: "2", ports: make(map[uint32]*Port)}
+	for _, d := range []*Device{d1, d2} {
+		for _, num := range []uint32{1, 2} {
+			d.ports[num] = newTestPort(d, num, 0)
+		}
+	}
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	now := time.Now()
+
+	src := []struct {
+		Locations []Location
+		Status    LocationStatus
+		Expected  []string // Port IDs
+		Result    LocationStatus
+	}{
+		// Same MAC on two switches.
+		{
+			Locations: []Location{{DPID: "2", Port: 1, Timestamp: now}, {DPID: "1", Port: 2, Timestamp: now.Add(-time.Minute)}},
+			Status:    LocationDiscovered,
+			Expected:  []string{"2:1", "1:2"},
+			Result:    LocationDiscovered,
+		},
+		// Moved from port 1 to port 2 on the same switch: the stale entry is evicted.
+		{
+			Locations: []Location{{DPID: "1", Port: 2, Timestamp: now}, {DPID: "1", Port: 1, Timestamp: now.Add(-time.Minute)}},
+			Status:    LocationDiscovered,
+			Expected:  []string{"1:2"},
+			Result:    LocationDiscovered,
+		},
+		// Same MAC in two VLANs on the same switch: both are kept, but the stale entry of each VLAN is evicted.
+		{
+			Locations: []Location{{DPID: "1", Port: 2, VLANID: 10, Timestamp: now}, {DPID: "1", Port: 1, VLANID: 20, Timestamp: now.Add(-time.Second)}, {DPID: "1", Port: 1, VLANID: 10, Timestamp: now.Add(-time.Minute)}},
+			Status:    LocationDiscovered,
+			Expected:  []string{"1:2", "1:1"},
+			Result:    LocationDiscovered,
+		},
+		// Unknown device and unknown port are ignored, but the newer location on an unknown port
+		// still evicts the older one on the same switch.
+		{
+			Locations: []Location{{DPID: "3", Port: 1, Timestamp: now}, {DPID: "2", Port: 9, Timestamp: now}, {DPID: "2", Port: 2, Timestamp: now}},
+			Status:    LocationDiscovered,
+			Expected:  []string{},
+			Result:    LocationUnregistered,
+		},
+		{
+			Locations: []Location{{DPID: "3", Port: 1, Timestamp: now}, {DPID: "1", Port: 1, Timestamp: now.Add(-time.Minute)}},
+			Status:    LocationDiscovered,
+			Expected:  []string{"1:1"},
+			Result:    LocationDiscovered,
+		},
+		{
+			Status:   LocationUndiscovered,
+			Expected: []string{},
+			Result:   LocationUndiscovered,
+		},
+	}
+
+	for i, v := range src {
+		topo := newTestTopology(d1, d2)
+		topo.db = &dummyLocationDB{locations: v.Locations, status: v.Status}
+
+		nodes, status, err := topo.Nodes(mac)
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if status != v.Result {
+			t.Fatalf("#%v: unexpected status: expected=%v, got=%v", i, v.Result, status)
+		}
+		if len(nodes) != len(v.Expected) {
+			t.Fatalf("#%v: unexpected number of nodes: expected=%v, got=%v", i, len(v.Expected), len(nodes))
+		}
+		for j, n := range nodes {
+			if n.Port().ID() != v.Expected[j] {
+				t.Fatalf("#%v: unexpected node: expected=%v, got=%v", i, v.Expected[j], n.Port().ID())
+			}
+		}
+
+		// Node returns the most recently updated one.
+		node, status, err := topo.Node(mac)
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if status != v.Result {
+			t.Fatalf("#%v: unexpected status: expected=%v, got=%v", i, v.Result, status)
+		}
+		if len(v.Expected) > 0 && node.Port().ID() != v.Expected[0] {
+			t.Fatalf("#%v: unexpected node: expected=%v, got=%v", i, v.Expected[0], node.Port().ID())
+		}
+		if len(v.Expected) == 0 && node != nil {
+			t.Fatalf("#%v: unexpected node: %v", i, node)
+		}
+	}
+}
+
+func TestAllNodes(t *testing.T) {
+	fake := NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	sw2 := fake.AddSwitch("2", of13.NewFactory(), 1)
+	now := time.Now()
+
+	hosts := []struct {
+		mac      net.HardwareAddr
+		location *Location
+	}{
+		{net.HardwareAddr{0, 0, 0, 0, 0, 3}, &Location{DPID: "2", Port: 1, Timestamp: now}},
+		{net.HardwareAddr{0, 0, 0, 0, 0, 1}, &Location{DPID: "1", Port: 2, Timestamp: now.Add(-time.Minute)}},
+		{net.HardwareAddr{0, 0, 0, 0, 0, 2}, &Location{DPID: "1", Port: 1, Timestamp: now.Add(-time.Second)}},
+		// Undiscovered host.
+		{net.HardwareAddr{0, 0, 0, 0, 0, 4}, nil},
+		// Host on an unknown device.
+		{net.HardwareAddr{0, 0, 0, 0, 0, 5}, &Location{DPID: "9", Port: 1, Timestamp: now}},
+	}
+	for _, v := range hosts {
+		fake.db.set(v.mac, v.location)
+	}
+
+	expected := []struct {
+		mac      string
+		port     *Port
+		lastSeen time.Time
+	}{
+		{"00:00:00:00:00:01", sw1.Port(2), now.Add(-time.Minute)},
+		{"00:00:00:00:00:02", sw1.Port(1), now.Add(-time.Second)},
+		{"00:00:00:00:00:03", sw2.Port(1), now},
+	}
+	nodes, err := fake.AllNodes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != len(expected) {
+		t.Fatalf("unexpected number of nodes: expected=%v, got=%v", len(expected), len(nodes))
+	}
+	for i, v := range expected {
+		n := nodes[i]
+		if n.MAC().String() != v.mac || n.Port() != v.port || !n.LastSeen().Equal(v.lastSeen) {
+			t.Fatalf("#%v: unexpected node: expected=%v/%v/%v, got=%v/%v/%v", i, v.mac, v.port.ID(), v.lastSeen, n.MAC(), n.Port().ID(), n.LastSeen())
+		}
+	}
+
+	// The returned nodes are a snapshot.
+	fake.SetLocation(hosts[1].mac, sw2.Port(1))
+	if nodes[0].Port() != sw1.Port(2) {
+		t.Fatalf("unexpected node after the host has moved: %v", nodes[0])
+	}
+	if nodes, err := fake.AllNodes(); err != nil || nodes[0].Port() != sw2.Port(1) {
+		t.Fatalf("unexpected nodes after the host has moved: nodes=%v, err=%v", nodes, err)
+	}
+}
+
+func TestEqualCostPorts(t *testing.T) {
+	// 1(p1,p2,p3) == (p1,p2,p3)2(p4) -- (p1)3: three parallel links between 1 and 2, and the third one
+	// is slower than the others.
+	d1, d2, d3 := &Device{id: "1"}, &Device{id: "2"}, &Device{id: "3"}
+	topo := newTestTopology(d1, d2, d3)
+	topo.DeviceLinked([2]*Port{newTestPort(d1, 1, 10000), newTestPort(d2, 1, 10000)})
+	topo.DeviceLinked([2]*Port{newTestPort(d1, 2, 10000), newTestPort(d2, 2, 10000)})
+	topo.DeviceLinked([2]*Port{newTestPort(d1, 3, 1000), newTestPort(d2, 3, 1000)})
+	topo.DeviceLinked([2]*Port{newTestPort(d2, 4, 10000), newTestPort(d3, 1, 10000)})
+
+	src := []struct {
+		Src, Dst string
+		Expected []string // Port IDs
+	}{
+		{Src: "1", Dst: "3", Expected: []string{"1:1", "1:2"}},
+		{Src: "1", Dst: "2", Expected: []string{"1:1", "1:2"}},
+		{Src: "2", Dst: "1", Expected: []string{"2:1", "2:2"}},
+		{Src: "3", Dst: "1", Expected: []string{"3:1"}},
+		// Unknown device.
+		{Src: "1", Dst: "4", Expected: []string{}},
+	}
+
+	for i, v := range src {
+		ports := topo.EqualCostPorts(v.Src, v.Dst)
+		if len(ports) != len(v.Expected) {
+			t.Fatalf("#%v: unexpected number of ports: expected=%v, got=%v", i, len(v.Expected), len(ports))
+		}
+		for j, p := range ports {
+			if p.ID() != v.Expected[j] {
+				t.Fatalf("#%v: unexpected port: expected=%v, got=%v", i, v.Expected[j], p.ID())
+			}
+		}
+	}
+}
+
+func TestPathCost(t *testing.T) {
+	// 1(p1) -- (p1)2 is a direct 1G link, and 1(p2) -- (p1)3(p2) -- (p2)2 is a longer path of 100G links.
+	// 4(p1) -- (p1)5 is a link whose speed is unknown.
+	d1, d2, d3 := &Device{id: "1"}, &Device{id: "2"}, &Device{id: "3"}
+	d4, d5 := &Device{id: "4"}, &Device{id: "5"}
+	topo := newTestTopology(d1, d2, d3, d4, d5)
+	topo.DeviceLinked([2]*Port{newTestPort(d1, 1, 1000), newTestPort(d2, 1, 1000)})
+	topo.DeviceLinked([2]*Port{newTestPort(d1, 2, 100000), newTestPort(d3, 1, 100000)})
+	topo.DeviceLinked([2]*Port{newTestPort(d3, 2, 100000), newTestPort(d2, 2, 100000)})
+	topo.DeviceLinked([2]*Port{newTestPort(d4, 1, 0), newTestPort(d5, 1, 0)})
+
+	src := []struct {
+		Src, Dst string
+		Expected []string // Egress port IDs
+		Cost     float64
+	}{
+		// The faster path wins even though it has more hops.
+		{Src: "1", Dst: "2", Expected: []string{"1:2", "3:2"}, Cost: 2},
+		{Src: "2", Dst: "1", Expected: []string{"2:2", "3:1"}, Cost: 2},
+		{Src: "1", Dst: "3", Expected: []string{"1:2"}, Cost: 1},
+		// Unit weight for the unknown speed.
+		{Src: "4", Dst: "5", Expected: []string{"4:1"}, Cost: 1},
+		// Disconnected devices.
+		{Src: "1", Dst: "4", Expected: []string{}, Cost: 0},
+		// Unknown device.
+		{Src: "1", Dst: "6", Expected: []string{}, Cost: 0},
+	}
+
+	for i, v := range src {
+		path, cost := topo.Path(v.Src, v.Dst)
+		if len(path) != len(v.Expected) {
+			t.Fatalf("#%v: unexpected number of hops: expected=%v, got=%v", i, len(v.Expected), len(path))
+		}
+		for j, hop := range path {
+			if hop[0].ID() != v.Expected[j] {
+				t.Fatalf("#%v: unexpected egress port: expected=%v, got=%v", i, v.Expected[j], hop[0].ID())
+			}
+		}
+		if cost != v.Cost {
+			t.Fatalf("#%v: unexpected cost: expected=%v, got=%v", i, v.Cost, cost)
+		}
+	}
+}
+
+func TestDuplicatedDeviceRejection(t *testing.T) {
+	topo := newTestTopology()
+	events, cancel := topo.feed.subscribe()
+	defer cancel()
+
+	old, duplicate := &Device{id: "1"}, &Device{id: "1"}
+	if !topo.DeviceAdded(old) {
+		t.Fatal("failed to add the device")
+	}
+	// The old device is still connected, or closing, when another device connects with the same ID.
+	if topo.DeviceAdded(duplicate) {
+		t.Fatal("unexpected addition of the duplicated device")
+	}
+	// The removal of the rejected device does not remove the connected one.
+	topo.DeviceRemoved(duplicate)
+	if d := topo.Device("1"); d != old {
+		t.Fatalf("unexpected device after the removal of the duplicated device: %p", d)
+	}
+	if devices := topo.Devices(); len(devices) != 1 || devices[0] != old {
+		t.Fatalf("unexpected devices: %v", devices)
+	}
+
+	topo.DeviceRemoved(old)
+	if d := topo.Device("1"); d != nil {
+		t.Fatalf("unexpected device after the removal: %p", d)
+	}
+	// The device can connect again after the old one has been removed.
+	if !topo.DeviceAdded(duplicate) {
+		t.Fatal("failed to add the device again")
+	}
+
+	// Only the events of the added and removed devices are published.
+	for _, expected := range []DeviceEventType{DeviceConnected, DeviceDisconnected, DeviceConnected} {
+		if e := receiveDeviceEvent(t, events); e.Type != expected {
+			t.Fatalf("unexpected event: expected=%v, got=%+v", expected, e)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event: %+v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestConcurrentDeviceChurn should be run with the race detector.
+func TestConcurrentDeviceChurn(t *testing.T) {
+	topo := &topology{
+		devices: make(map[string]*Device),
+		graph:   graph.New(),
+		aging:   newNodeAging(0),
+		dhcp:    newDHCPSnooping(nil),
+		flap:    newMACFlapDetector(0, 0, 0),
+		feed:    newDeviceEventFeed(),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				d := &Device{id: id}
+				topo.DeviceAdded(d)
+				topo.DeviceRemoved(d)
+			}
+		}(fmt.Sprintf("%v", i%2))
+	}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				// The devices of the snapshot may have been removed in the meantime.
+				for _, d := range topo.Devices() {
+					topo.Device(d.ID())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if devices := topo.Devices(); len(devices) != 0 {
+		t.Fatalf("unexpected devices after the churn: %v", devices)
+	}
+}
@@ -0,0 +1,236 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+// Package acl implements a stateless access control list whose ordered rules allow or deny the packets.
+//
+// The rules are applied in order they appear in acl.rules, and the first rule that matches a packet decides
+// whether the packet is allowed or denied. The packets that match no rule are allowed. ACL installs a flow for
+// each rule in the classification table, table 0, with a priority in network.PriorityBandPolicy, which
+// overrides the flows of L2Switch and the other forwarding applications: the first rule has the highest
+// priority. A deny rule drops the matched packets, and an allow rule continues the lookup in the forwarding
+// table so that the packets are forwarded as usual. The allow rules thus need the two-stage pipeline built by
+// L2Switch with l2switch.forwarding_table if they are followed by deny rules, and Init fails if it is not
+// configured. On a device without the pipeline, e.g., an OpenFlow 1.0 switch, ACL fails closed: only the deny
+// rules are installed, so the packets allowed by an allow rule but matching a following deny rule are dropped.
+//
+// ACL also applies the rules to the PACKET_INs before the following applications, drops the denied packets,
+// and installs a short-lived flow that drops the following packets having the same fields as the denied one.
+// The denied packets are logged only if they reach the controller as PACKET_INs; the packets dropped by the
+// flows on the switches are not logged. ACL should precede L2Switch and the other forwarding applications in
+// default.applications.
+package acl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/northbound/app"
+	"github.com/superkkt/cherry/protocol"
+
+	"github.com/pkg/errors"
+	"github.com/superkkt/go-logging"
+	"github.com/superkkt/viper"
+)
+
+var (
+	logger = logging.MustGetLogger("acl")
+)
+
+const (
+	defaultDropTimeout = 10 * time.Second
+)
+
+type ACL struct {
+	app.BaseProcessor
+	cookie network.AppCookie
+	// Rules in order of precedence. They are not changed after Init.
+	rules []*rule
+	// Log the denied packets.
+	logDenied bool
+	// Timeout of the flows dropping the denied packets.
+	dropTimeout time.Duration
+}
+
+func New() *ACL {
+	return &ACL{}
+}
+
+func (r *ACL) Name() string {
+	return "ACL"
+}
+
+func (r *ACL) String() string {
+	return fmt.Sprintf("%v", r.Name())
+}
+
+func (r *ACL) Init() error {
+	configs := make([]ruleConfig, 0)
+	if err := viper.UnmarshalKey("acl.rules", &configs); err != nil {
+		return errors.Wrap(err, "invalid acl.rules in the config file")
+	}
+	band := network.PriorityBandPolicy
+	// Each rule has its own priority in the band.
+	if max := int(band.Max-band.Min) + 1; len(configs) > max {
+		return fmt.Errorf("too many acl.rules in the config file: %v rules (max %v)", len(configs), max)
+	}
+	rules := make([]*rule, 0, len(configs))
+	for i, c := range configs {
+		v, err := newRule(c)
+		if err != nil {
+			return fmt.Errorf("invalid acl.rules #%v in the config file: %v", i, err)
+		}
+		rules = append(rules, v)
+	}
+	// A deny rule following an allow rule would shadow the allow rule unless the allow rule continues the
+	// lookup in the forwarding table.
+	if shadowsAllowRule(rules) && viper.GetInt("l2switch.forwarding_table") == 0 {
+		return errors.New("acl.rules that have deny rules after an allow rule need l2switch.forwarding_table in the config file")
+	}
+	r.rules = rules
+	r.logDenied = viper.GetBool("acl.log")
+	r.dropTimeout = defaultDropTimeout
+	if viper.IsSet("acl.drop_timeout") {
+		v := viper.GetInt("acl.drop_timeout")
+		if v <= 0 || v > 0xFFFF {
+			return errors.New("invalid acl.drop_timeout in the config file")
+		}
+		r.dropTimeout = time.Duration(v) * time.Second
+	}
+
+	cookie, err := network.RegisterAppCookie(r.Name())
+	if err != nil {
+		return err
+	}
+	if err := network.SetPriorityBand(cookie, band); err != nil {
+		return err
+	}
+	r.cookie = cookie
+	for i, v := range r.rules {
+		logger.Infof("ACL rule #%v: %v (flow priority %v)", i, v, r.priority(i))
+	}
+
+	return nil
+}
+
+// shadowsAllowRule returns whether rules have a deny rule after an allow rule.
+func shadowsAllowRule(rules []*rule) bool {
+	allow := false
+	for _, v := range rules {
+		if !v.deny {
+			allow = true
+			continue
+		}
+		if allow {
+			return true
+		}
+	}
+
+	return false
+}
+
+// priority returns the flow priority of the i-th rule. The preceding rules have the higher priorities.
+func (r *ACL) priority(i int) uint16 {
+	return network.PriorityBandPolicy.Max - uint16(i)
+}
+
+// OnDeviceUp installs the flows of the rules after the following applications, e.g., L2Switch, have received
+// the event, so that the allow rules can continue the lookup in the forwarding table built by them.
+func (r *ACL) OnDeviceUp(finder network.Finder, device *network.Device) error {
+	err := r.BaseProcessor.OnDeviceUp(finder, device)
+	if e := r.installFlows(device); e != nil {
+		logger.Errorf("failed to install the ACL flows on %v: %v", device.ID(), e)
+		if err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// installFlows installs the flows of the rules on device. On a device without the forwarding table, the allow
+// rules are skipped and it returns an error if they are shadowed by the following deny rules.
+func (r *ACL) installFlows(device *network.Device) error {
+	pipelined := device.TableID(network.TableForwarding) != 0
+	for i, v := range r.rules {
+		if !v.deny && !pipelined {
+			continue
+		}
+		match, err := v.newMatch(device.Factory())
+		if err != nil {
+			return errors.Wrapf(err, "rule #%v", i)
+		}
+		if err := device.SetClassificationFlow(r.cookie, match, r.priority(i), !v.deny); err != nil {
+			return errors.Wrapf(err, "rule #%v", i)
+		}
+	}
+	if !pipelined && shadowsAllowRule(r.rules) {
+		return errors.New("no forwarding table to continue the lookup: the allow rules followed by deny rules are not applied")
+	}
+
+	return nil
+}
+
+func (r *ACL) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
+	if r.processPacket(ingress, eth) {
+		return nil
+	}
+
+	return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
+}
+
+// processPacket returns whether the packet is denied by the rules, in which case it should be dropped. It also
+// installs a flow that drops the following packets having the same fields as the denied one.
+func (r *ACL) processPacket(ingress *network.Port, eth *protocol.Ethernet) (drop bool) {
+	p := &packet{eth: eth}
+	for i, v := range r.rules {
+		if !v.matches(p) {
+			continue
+		}
+		if !v.deny {
+			return false
+		}
+		if r.logDenied {
+			logger.Infof("denied a packet by ACL rule #%v: ingress=%v, srcMAC=%v, dstMAC=%v, etherType=0x%04X", i, ingress.ID(), eth.SrcMAC, eth.DstMAC, eth.Type)
+		}
+		if err := r.setDropFlow(ingress.Device(), p, i); err != nil {
+			logger.Errorf("failed to install the drop flow on %v: %v", ingress.Device().ID(), err)
+		}
+		return true
+	}
+
+	return false
+}
+
+// setDropFlow installs a short-lived flow that drops the packets denied by the i-th rule. The flow matches the
+// values of p for all the fields referenced by the rules, so it never drops a packet that the rules allow.
+func (r *ACL) setDropFlow(device *network.Device, p *packet, i int) error {
+	match, ok, err := p.exactMatch(device.Factory(), r.rules)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// The packet does not have all the fields, so that a flow could drop the allowed packets.
+		return nil
+	}
+
+	return device.SetDropFlow(r.cookie, match, r.priority(i), r.dropTimeout)
+}
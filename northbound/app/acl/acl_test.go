@@ -0,0 +1,259 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package acl
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/northbound/app"
+	"github.com/superkkt/cherry/openflow/of13"
+	"github.com/superkkt/cherry/protocol"
+	"github.com/superkkt/viper"
+)
+
+// counter counts the PACKET_INs passed to the next application.
+type counter struct {
+	app.BaseProcessor
+	packetIns int
+}
+
+func (r *counter) String() string {
+	return "counter"
+}
+
+func (r *counter) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
+	r.packetIns++
+	return nil
+}
+
+func newTestPacket(t *testing.T, srcIP, dstIP string, dstPort uint16) *protocol.Ethernet {
+	tcp := protocol.TCP{SrcPort: 40000, DstPort: dstPort, Flags: protocol.TCPFlagSYN}
+	tcp.SetPseudoHeader(net.ParseIP(srcIP), net.ParseIP(dstIP))
+	segment, err := tcp.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal the TCP segment: %v", err)
+	}
+	payload, err := protocol.NewIPv4(net.ParseIP(srcIP), net.ParseIP(dstIP), 6, segment).MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal the IPv4 packet: %v", err)
+	}
+
+	return &protocol.Ethernet{
+		SrcMAC:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+		DstMAC:  net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02},
+		Type:    0x0800,
+		Payload: payload,
+	}
+}
+
+func newTestACL(t *testing.T, rules []map[string]interface{}) *ACL {
+	viper.Reset()
+	viper.Set("acl.rules", rules)
+	viper.Set("l2switch.forwarding_table", 2)
+	defer viper.Reset()
+
+	acl := New()
+	if err := acl.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	return acl
+}
+
+// SSH from a host is allowed to the subnet that is denied to the others.
+var testRules = []map[string]interface{}{
+	{
+		"action": "allow",
+		"match":  map[string]interface{}{"src_ip": "10.0.1.10", "dst_ip": "10.0.2.0/24", "ip_protocol": 6, "dst_port": 22},
+	},
+	{
+		"action": "deny",
+		"match":  map[string]interface{}{"dst_ip": "10.0.2.0/24"},
+	},
+}
+
+func TestRuleOrder(t *testing.T) {
+	acl := newTestACL(t, testRules)
+	next := new(counter)
+	acl.SetNext(next)
+
+	fake := network.NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+
+	src := []struct {
+		SrcIP, DstIP string
+		DstPort      uint16
+		Drop         bool
+	}{
+		{"10.0.1.10", "10.0.2.1", 22, false},
+		// Denied because the specific allow rule does not match.
+		{"10.0.1.10", "10.0.2.1", 80, true},
+		{"10.0.1.11", "10.0.2.1", 22, true},
+		// No rule matches.
+		{"10.0.1.11", "10.0.3.1", 80, false},
+	}
+	for i, v := range src {
+		eth := newTestPacket(t, v.SrcIP, v.DstIP, v.DstPort)
+		if drop := acl.processPacket(sw1.Port(1), eth); drop != v.Drop {
+			t.Fatalf("#%v: unexpected drop: expected=%v, got=%v", i, v.Drop, drop)
+		}
+
+		n := next.packetIns
+		if err := acl.OnPacketIn(fake, sw1.Port(1), eth, network.PacketInInfo{}); err != nil {
+			t.Fatalf("#%v: failed to process the packet: %v", i, err)
+		}
+		if passed := next.packetIns > n; passed == v.Drop {
+			t.Fatalf("#%v: unexpected packet passed to the next application: %v", i, passed)
+		}
+	}
+
+	// A denied packet installs a drop flow that matches the fields of the packet referenced by the rules.
+	sw1.Reset()
+	if !acl.processPacket(sw1.Port(1), newTestPacket(t, "10.0.1.10", "10.0.2.1", 80)) {
+		t.Fatal("expected the packet to be denied")
+	}
+	flows := sw1.FlowMods()
+	if len(flows) != 1 || flows[0].Priority() != network.PriorityBandPolicy.Max-1 || flows[0].HardTimeout() != uint16(defaultDropTimeout/time.Second) {
+		t.Fatalf("unexpected drop flows: %v", flows)
+	}
+	match := flows[0].FlowMatch()
+	if ip := match.SrcIP(); !ip.IP.Equal(net.ParseIP("10.0.1.10")) {
+		t.Fatalf("unexpected source IP of the drop flow: %v", ip)
+	}
+	// The drop flow should not cover SSH, which is allowed.
+	if wildcard, port := match.DstPort(); wildcard || port != 80 {
+		t.Fatalf("unexpected destination port of the drop flow: %v", port)
+	}
+	if inst := flows[0].FlowInstruction(); inst != nil && inst.Action() != nil {
+		t.Fatal("expected the flow to drop the packets")
+	}
+	// An allowed packet does not install a flow.
+	sw1.Reset()
+	if acl.processPacket(sw1.Port(1), newTestPacket(t, "10.0.1.10", "10.0.2.1", 22)) || len(sw1.FlowMods()) != 0 {
+		t.Fatal("unexpected drop of the allowed packet")
+	}
+
+	// Reversing the rules makes the broad deny shadow the specific allow.
+	acl = newTestACL(t, []map[string]interface{}{testRules[1], testRules[0]})
+	if !acl.processPacket(sw1.Port(1), newTestPacket(t, "10.0.1.10", "10.0.2.1", 22)) {
+		t.Fatal("expected the packet to be denied by the preceding deny rule")
+	}
+}
+
+func TestInstallFlows(t *testing.T) {
+	acl := newTestACL(t, testRules)
+
+	// The allow rule continues the lookup in the forwarding table.
+	fake := network.NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	sw1.SetNumTables(4)
+	if err := sw1.SetForwardingTable(2); err != nil {
+		t.Fatalf("failed to set the forwarding table: %v", err)
+	}
+	sw1.Reset()
+	if err := acl.OnDeviceUp(fake, sw1.Device); err != nil {
+		t.Fatalf("failed to process the device up event: %v", err)
+	}
+	flows := sw1.FlowMods()
+	if len(flows) != 2 {
+		t.Fatalf("unexpected number of flows: expected=2, got=%v", len(flows))
+	}
+	for i, v := range flows {
+		if v.TableID() != 0 || v.Priority() != network.PriorityBandPolicy.Max-uint16(i) || !acl.cookie.Owns(v.Cookie()) {
+			t.Fatalf("#%v: unexpected flow: table=%v, priority=%v, cookie=0x%X", i, v.TableID(), v.Priority(), v.Cookie())
+		}
+	}
+	if inst := flows[0].FlowInstruction(); inst == nil {
+		t.Fatal("expected the goto-table instruction of the allow rule")
+	} else if ok, id := inst.GotoTableID(); !ok || id != 2 {
+		t.Fatalf("unexpected goto-table instruction: ok=%v, tableID=%v", ok, id)
+	}
+	if inst := flows[1].FlowInstruction(); inst != nil {
+		if ok, _ := inst.GotoTableID(); ok || inst.Action() != nil {
+			t.Fatal("expected the deny rule to drop the packets")
+		}
+	}
+	if wildcard, port := flows[0].FlowMatch().DstPort(); wildcard || port != 22 {
+		t.Fatalf("unexpected destination port of the allow rule: %v", port)
+	}
+
+	// Without the pipeline, the allow rule shadowed by the following deny rule cannot be applied, so ACL fails
+	// closed: only the deny rule is installed.
+	sw2 := fake.AddSwitch("2", of13.NewFactory(), 1, 2)
+	if err := acl.OnDeviceUp(fake, sw2.Device); err == nil {
+		t.Fatal("expected an error for the device without the forwarding table")
+	}
+	if flows := sw2.FlowMods(); len(flows) != 1 || flows[0].Priority() != network.PriorityBandPolicy.Max-1 {
+		t.Fatalf("unexpected flows: %v", flows)
+	}
+	// The allow rule following the deny rule is not shadowed, so it is not needed.
+	acl = newTestACL(t, []map[string]interface{}{testRules[1], testRules[0]})
+	sw2.Reset()
+	if err := acl.OnDeviceUp(fake, sw2.Device); err != nil {
+		t.Fatalf("failed to process the device up event: %v", err)
+	}
+	if flows := sw2.FlowMods(); len(flows) != 1 || flows[0].Priority() != network.PriorityBandPolicy.Max {
+		t.Fatalf("unexpected flows: %v", flows)
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	src := []map[string]interface{}{
+		{"action": "reject"},
+		{"action": "deny", "match": map[string]interface{}{"unknown": "1"}},
+		{"action": "deny", "match": map[string]interface{}{"src_ip": "10.0.0.300"}},
+		// Ports without TCP or UDP.
+		{"action": "deny", "match": map[string]interface{}{"dst_port": 22}},
+		{"action": "deny", "match": map[string]interface{}{"ip_protocol": 1, "dst_port": 22}},
+		// IP fields with ARP.
+		{"action": "deny", "match": map[string]interface{}{"ether_type": "0x0806", "dst_ip": "10.0.0.1"}},
+	}
+	defer viper.Reset()
+	for i, v := range src {
+		viper.Reset()
+		viper.Set("acl.rules", []map[string]interface{}{v})
+		if err := New().Init(); err == nil {
+			t.Fatalf("#%v: expected an error for the invalid rule: %v", i, v)
+		}
+	}
+
+	// The allow rule followed by the deny rule needs the forwarding table.
+	viper.Reset()
+	viper.Set("acl.rules", testRules)
+	if err := New().Init(); err == nil {
+		t.Fatal("expected an error for the allow rule without the forwarding table")
+	}
+
+	// More rules than the priorities in the policy band.
+	rules := make([]map[string]interface{}, 10)
+	for i := range rules {
+		rules[i] = map[string]interface{}{"action": "deny", "match": map[string]interface{}{"dst_port": 1000 + i, "ip_protocol": 17}}
+	}
+	viper.Reset()
+	viper.Set("acl.rules", rules)
+	if err := New().Init(); err == nil {
+		t.Fatal("expected an error for too many rules")
+	}
+}
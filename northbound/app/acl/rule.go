@@ -0,0 +1,328 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package acl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/protocol"
+)
+
+// ruleConfig is a rule in the config file.
+type ruleConfig struct {
+	// "allow" or "deny".
+	Action string            `mapstructure:"action"`
+	Match  map[string]string `mapstructure:"match"`
+}
+
+// rule allows or denies the packets that match all of its fields. Zero value of a field matches any packet.
+type rule struct {
+	deny       bool
+	srcMAC     net.HardwareAddr
+	dstMAC     net.HardwareAddr
+	etherType  uint16
+	srcIP      *net.IPNet
+	dstIP      *net.IPNet
+	ipProtocol uint8
+	srcPort    uint16
+	dstPort    uint16
+}
+
+// newRule parses the rule whose match keys are src_mac, dst_mac, ether_type, src_ip, dst_ip, ip_protocol,
+// src_port and dst_port. The IP fields imply the IPv4 Ethernet type, and the ports need ip_protocol 6 (TCP)
+// or 17 (UDP).
+func newRule(c ruleConfig) (*rule, error) {
+	r := new(rule)
+	switch strings.ToLower(c.Action) {
+	case "allow":
+	case "deny":
+		r.deny = true
+	default:
+		return nil, fmt.Errorf("invalid action: %v", c.Action)
+	}
+
+	for k, v := range c.Match {
+		var err error
+		switch strings.ToLower(k) {
+		case "src_mac":
+			r.srcMAC, err = parseMAC(v)
+		case "dst_mac":
+			r.dstMAC, err = parseMAC(v)
+		case "ether_type":
+			var t uint64
+			t, err = strconv.ParseUint(v, 0, 16)
+			r.etherType = uint16(t)
+		case "src_ip":
+			r.srcIP, err = parseIPv4Net(v)
+		case "dst_ip":
+			r.dstIP, err = parseIPv4Net(v)
+		case "ip_protocol":
+			var p uint64
+			p, err = strconv.ParseUint(v, 0, 8)
+			r.ipProtocol = uint8(p)
+		case "src_port":
+			r.srcPort, err = parsePort(v)
+		case "dst_port":
+			r.dstPort, err = parsePort(v)
+		default:
+			err = errors.New("unknown field")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", k, err)
+		}
+	}
+
+	if (r.srcPort != 0 || r.dstPort != 0) && r.ipProtocol != 6 && r.ipProtocol != 17 {
+		return nil, fmt.Errorf("ports without TCP or UDP ip_protocol: %v", r.ipProtocol)
+	}
+	if r.srcIP != nil || r.dstIP != nil || r.ipProtocol != 0 {
+		if r.etherType != 0 && r.etherType != 0x0800 {
+			return nil, fmt.Errorf("IP fields with a non-IPv4 ether_type: 0x%04X", r.etherType)
+		}
+		r.etherType = 0x0800
+	}
+
+	return r, nil
+}
+
+func parseMAC(s string) (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid MAC address: %v", s)
+	}
+
+	return mac, nil
+}
+
+// parseIPv4Net parses an IPv4 address with or without its prefix length.
+func parseIPv4Net(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		s += "/32"
+	}
+	ip, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("not an IPv4 address: %v", s)
+	}
+
+	return ipnet, nil
+}
+
+func parsePort(s string) (uint16, error) {
+	v, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, err
+	}
+	if v == 0 {
+		return 0, errors.New("zero port number")
+	}
+
+	return uint16(v), nil
+}
+
+// newMatch returns a new flow match that has the fields of the rule.
+func (r *rule) newMatch(f openflow.Factory) (openflow.Match, error) {
+	match, err := f.NewMatch()
+	if err != nil {
+		return nil, err
+	}
+	if r.srcMAC != nil {
+		match.SetSrcMAC(r.srcMAC)
+	}
+	if r.dstMAC != nil {
+		match.SetDstMAC(r.dstMAC)
+	}
+	if r.etherType != 0 {
+		match.SetEtherType(r.etherType)
+	}
+	if r.srcIP != nil {
+		match.SetSrcIP(r.srcIP)
+	}
+	if r.dstIP != nil {
+		match.SetDstIP(r.dstIP)
+	}
+	if r.ipProtocol != 0 {
+		match.SetIPProtocol(r.ipProtocol)
+	}
+	if r.srcPort != 0 {
+		match.SetSrcPort(r.srcPort)
+	}
+	if r.dstPort != 0 {
+		match.SetDstPort(r.dstPort)
+	}
+
+	return match, nil
+}
+
+// packet is a PACKET_IN decoded up to its transport ports on demand, so that the rules share the decoding.
+type packet struct {
+	eth *protocol.Ethernet
+	// Decoded IPv4 packet and ports, which are valid only if decoded is true.
+	decoded          bool
+	ip               *protocol.IPv4
+	srcPort, dstPort uint16
+	hasPorts         bool
+}
+
+func (r *packet) decode() {
+	if r.decoded {
+		return
+	}
+	r.decoded = true
+
+	if r.eth.Type != 0x0800 {
+		return
+	}
+	ip := new(protocol.IPv4)
+	if err := ip.UnmarshalBinary(r.eth.Payload); err != nil {
+		return
+	}
+	r.ip = ip
+
+	switch ip.Protocol {
+	case 6:
+		if tcp, err := ip.TCP(); err == nil {
+			r.srcPort, r.dstPort, r.hasPorts = tcp.SrcPort, tcp.DstPort, true
+		}
+	case 17:
+		if udp, err := ip.UDP(); err == nil {
+			r.srcPort, r.dstPort, r.hasPorts = udp.SrcPort, udp.DstPort, true
+		}
+	}
+}
+
+// exactMatch returns a new flow match that has the values of p for all the fields referenced by rules, so that
+// the rules treat all the packets matching it in the same way as p. ok is false if p does not have some of
+// the fields, e.g., the ports of a non-first fragment.
+func (r *packet) exactMatch(f openflow.Factory, rules []*rule) (match openflow.Match, ok bool, err error) {
+	var srcMAC, dstMAC, etherType, ip, ports bool
+	for _, v := range rules {
+		srcMAC = srcMAC || v.srcMAC != nil
+		dstMAC = dstMAC || v.dstMAC != nil
+		etherType = etherType || v.etherType != 0
+		ip = ip || v.srcIP != nil || v.dstIP != nil || v.ipProtocol != 0
+		ports = ports || v.srcPort != 0 || v.dstPort != 0
+	}
+
+	match, err = f.NewMatch()
+	if err != nil {
+		return nil, false, err
+	}
+	if tag, ok := r.eth.VLAN(); ok {
+		match.SetVLANID(tag.ID)
+	}
+	if srcMAC {
+		match.SetSrcMAC(r.eth.SrcMAC)
+	}
+	if dstMAC {
+		match.SetDstMAC(r.eth.DstMAC)
+	}
+	if etherType || ip {
+		match.SetEtherType(r.eth.Type)
+	}
+	if !ip || r.eth.Type != 0x0800 {
+		return match, true, nil
+	}
+
+	r.decode()
+	if r.ip == nil {
+		return nil, false, nil
+	}
+	match.SetSrcIP(&net.IPNet{IP: r.ip.SrcIP, Mask: net.CIDRMask(32, 32)})
+	match.SetDstIP(&net.IPNet{IP: r.ip.DstIP, Mask: net.CIDRMask(32, 32)})
+	match.SetIPProtocol(r.ip.Protocol)
+	if ports && (r.ip.Protocol == 6 || r.ip.Protocol == 17) {
+		if !r.hasPorts {
+			return nil, false, nil
+		}
+		match.SetSrcPort(r.srcPort)
+		match.SetDstPort(r.dstPort)
+	}
+
+	return match, true, nil
+}
+
+// matches returns whether p matches all the fields of the rule. The packets whose fields cannot be decoded,
+// e.g., the non-first fragments for the ports, do not match the rules that have the fields.
+func (r *rule) matches(p *packet) bool {
+	if r.srcMAC != nil && !bytes.Equal(r.srcMAC, p.eth.SrcMAC) {
+		return false
+	}
+	if r.dstMAC != nil && !bytes.Equal(r.dstMAC, p.eth.DstMAC) {
+		return false
+	}
+	if r.etherType != 0 && r.etherType != p.eth.Type {
+		return false
+	}
+	if r.srcIP == nil && r.dstIP == nil && r.ipProtocol == 0 {
+		return true
+	}
+
+	p.decode()
+	if p.ip == nil {
+		return false
+	}
+	if r.srcIP != nil && !r.srcIP.Contains(p.ip.SrcIP) {
+		return false
+	}
+	if r.dstIP != nil && !r.dstIP.Contains(p.ip.DstIP) {
+		return false
+	}
+	if r.ipProtocol != 0 && r.ipProtocol != p.ip.Protocol {
+		return false
+	}
+	if r.srcPort == 0 && r.dstPort == 0 {
+		return true
+	}
+	if !p.hasPorts {
+		return false
+	}
+	if r.srcPort != 0 && r.srcPort != p.srcPort {
+		return false
+	}
+	if r.dstPort != 0 && r.dstPort != p.dstPort {
+		return false
+	}
+
+	return true
+}
+
+func (r *rule) String() string {
+	action := "allow"
+	if r.deny {
+		action = "deny"
+	}
+
+	return fmt.Sprintf("%v srcMAC=%v, dstMAC=%v, etherType=0x%04X, srcIP=%v, dstIP=%v, ipProtocol=%v, srcPort=%v, dstPort=%v",
+		action, r.srcMAC, r.dstMAC, r.etherType, r.srcIP, r.dstIP, r.ipProtocol, r.srcPort, r.dstPort)
+}
@@ -78,9 +78,9 @@ func (r *DHCP) String() string {
 	return fmt.Sprintf("%v", r.Name())
 }
 
-func (r *DHCP) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) error {
+func (r *DHCP) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
 	if eth.Type != 0x0800 /* IPv4 */ {
-		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
 	}
 
 	ip := new(protocol.IPv4)
@@ -89,7 +89,7 @@ func (r *DHCP) OnPacketIn(finder network.Finder, ingress *network.Port, eth *pro
 		return nil
 	}
 	if ip.Protocol != 0x11 /* UDP */ {
-		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
 	}
 
 	udp := new(protocol.UDP)
@@ -99,19 +99,19 @@ func (r *DHCP) OnPacketIn(finder network.Finder, ingress *network.Port, eth *pro
 	}
 	// DHCP client and server ports?
 	if udp.SrcPort != 68 || udp.DstPort != 67 {
-		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
 	}
 
 	dhcp := new(protocol.DHCP)
 	if err := dhcp.UnmarshalBinary(udp.Payload); err != nil {
 		logger.Debugf("bypass an invalid DHCP packet: %v", err)
-		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
 	}
 
 	logger.Debugf("processing a DHCP packet: ingress=%v, data=%v", ingress.ID(), spew.Sdump(dhcp))
 	if r.processDHCPPacket(ingress, dhcp) == true {
 		logger.Infof("bypass the DHCP packet: ingress=%v, data=%v", ingress.ID(), spew.Sdump(dhcp))
-		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
 	}
 
 	return nil
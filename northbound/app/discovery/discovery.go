@@ -63,8 +63,9 @@ type Database interface {
 
 	// UpdateHostLocation updates the physical location of a host, whose MAC and IP
 	// addresses are matched with mac and ip, to the port identified by swDPID and
-	// portNum. updated will be true if its location has been actually updated.
-	UpdateHostLocation(mac net.HardwareAddr, ip net.IP, swDPID uint64, portNum uint16) (updated bool, err error)
+	// portNum in the VLAN whose ID is vlanID, which is zero for the untagged host.
+	// updated will be true if its location has been actually updated.
+	UpdateHostLocation(mac net.HardwareAddr, ip net.IP, swDPID uint64, portNum, vlanID uint16) (updated bool, err error)
 
 	// ResetHostLocationsByPort sets NULL to the host locations that belong to the
 	// port specified by swDPID and portNum.
@@ -164,21 +165,23 @@ func (r *processor) stopARPSender(deviceID string) {
 	delete(r.canceller, deviceID)
 }
 
-func (r *processor) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) error {
+func (r *processor) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
 	// ARP?
 	if eth.Type != 0x0806 {
-		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
 	}
 
 	arp := new(protocol.ARP)
 	if err := arp.UnmarshalBinary(eth.Payload); err != nil {
-		return err
+		logger.Debugf("dropping the malformed ARP packet: ingress=%v, err=%v", ingress.ID(), err)
+		// Drop this packet. Do not pass it to the next processors.
+		return nil
 	}
 	logger.Debugf("received ARP packet: %v", arp)
 
 	switch arp.Operation {
 	case 1:
-		return r.processARPRequest(finder, ingress, eth, arp)
+		return r.processARPRequest(finder, ingress, eth, info, arp)
 	case 2:
 		return r.processARPReply(finder, ingress, eth, arp)
 	default:
@@ -188,7 +191,7 @@ func (r *processor) OnPacketIn(finder network.Finder, ingress *network.Port, eth
 	}
 }
 
-func (r *processor) processARPRequest(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, arp *protocol.ARP) error {
+func (r *processor) processARPRequest(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo, arp *protocol.ARP) error {
 	// Our ARP probe?
 	if bytes.Equal(arp.SHA, myMAC) {
 		// Drop this packet! This packet should not be propagated among switches.
@@ -196,7 +199,7 @@ func (r *processor) processARPRequest(finder network.Finder, ingress *network.Po
 		return nil
 	} else {
 		// Propagate this ARP request, wich is raised from a host, to the next processors.
-		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
 	}
 }
 
@@ -215,17 +218,22 @@ func (r *processor) processARPReply(finder network.Finder, ingress *network.Port
 	}
 
 	// This ARP reply packet has been processed. Do not pass it to the next processors.
-	return r.macLearning(finder, ingress, arp)
+	return r.macLearning(finder, ingress, eth, arp)
 }
 
-func (r *processor) macLearning(finder network.Finder, ingress *network.Port, arp *protocol.ARP) error {
+func (r *processor) macLearning(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, arp *protocol.ARP) error {
 	swDPID, err := strconv.ParseUint(ingress.Device().ID(), 10, 64)
 	if err != nil {
 		return fmt.Errorf("invalid device ID: %v", ingress.Device().ID())
 	}
 
 	// Update the host location in the database if SHA and SPA are matched.
-	updated, err := r.db.UpdateHostLocation(arp.SHA, arp.SPA, swDPID, uint16(ingress.Number()))
+	// The host is in the VLAN of the outermost tag, or untagged.
+	var vlanID uint16
+	if tag, ok := eth.VLAN(); ok {
+		vlanID = tag.ID
+	}
+	updated, err := r.db.UpdateHostLocation(arp.SHA, arp.SPA, swDPID, uint16(ingress.Number()), vlanID)
 	if err != nil {
 		return err
 	}
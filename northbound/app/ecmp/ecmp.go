@@ -0,0 +1,298 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+// Package ecmp load-balances the unicast packets over the equal-cost links among switches.
+//
+// The spanning tree keeps only one of the parallel links between two switches, so the L2Switch
+// application always uses a single path. ECMP installs a select group, whose buckets are the
+// equal-cost ports toward the next hop switch, and a flow that directs the packets destined to a
+// host to the group. The switch then hashes the packets of each flow across the links.
+//
+// ECMP should precede L2Switch in default.applications so that it handles the PACKET_INs first. It
+// passes the packets that have only one path toward their destination, e.g., the ones destined to
+// the hosts on the same switch, to the next application. Its flows have a higher priority than the
+// flows of L2Switch, so they override the L2Switch flows toward the same host on the same switch
+// instead of conflicting with them. Both applications remove their own flows when the topology
+// changes, and they do not touch the flows of each other because they have different cookie ranges.
+package ecmp
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/northbound/app"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/protocol"
+
+	"github.com/pkg/errors"
+	"github.com/superkkt/go-logging"
+	"github.com/superkkt/viper"
+)
+
+var (
+	logger = logging.MustGetLogger("ecmp")
+)
+
+const (
+	// Higher than the default priority of the L2Switch flows.
+	defaultPriority = 15
+)
+
+type ECMP struct {
+	app.BaseProcessor
+	mutex  sync.Mutex
+	cookie network.AppCookie
+	// Options of the flows that direct the packets to the groups.
+	flowOpts network.FlowOptions
+	// Key is the device ID.
+	devices map[string]*groupTable
+}
+
+func New() *ECMP {
+	return &ECMP{
+		devices: make(map[string]*groupTable),
+	}
+}
+
+func (r *ECMP) Name() string {
+	return "ECMP"
+}
+
+func (r *ECMP) String() string {
+	return fmt.Sprintf("%v", r.Name())
+}
+
+func (r *ECMP) Init() error {
+	cookie, err := network.RegisterAppCookie(r.Name())
+	if err != nil {
+		return err
+	}
+	r.cookie = cookie
+
+	r.flowOpts = network.DefaultFlowOptions
+	r.flowOpts.Priority = defaultPriority
+	if viper.IsSet("ecmp.priority") {
+		v := viper.GetInt("ecmp.priority")
+		if v <= 0 || v > 0xFFFF {
+			return errors.New("invalid ecmp.priority in the config file")
+		}
+		r.flowOpts.Priority = uint16(v)
+	}
+	if err := network.SetPriorityBand(cookie, network.PriorityBandForwarding); err != nil {
+		return err
+	}
+	if err := cookie.ValidatePriority(r.flowOpts.Priority); err != nil {
+		return fmt.Errorf("invalid ecmp.priority in the config file: %v", err)
+	}
+	logger.Infof("flow priority: %v", r.flowOpts.Priority)
+
+	return nil
+}
+
+func (r *ECMP) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
+	done, err := r.processPacket(finder, ingress, eth)
+	if done || err != nil {
+		return err
+	}
+
+	return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
+}
+
+func (r *ECMP) processPacket(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) (done bool, err error) {
+	// Broadcast or multicast?
+	if eth.DstMAC[0]&0x01 != 0 {
+		return false, nil
+	}
+	device := ingress.Device()
+	// Groups are only supported by OpenFlow 1.3.
+	if device.Factory().ProtocolVersion() != openflow.OF13_VERSION {
+		return false, nil
+	}
+
+	node, status, err := finder.Node(eth.DstMAC)
+	if err != nil || status != network.LocationDiscovered {
+		return false, err
+	}
+	if node.Port().Device().ID() == device.ID() {
+		// The single path on the same device is handled by the next application.
+		return false, nil
+	}
+
+	ports := availablePorts(finder, finder.EqualCostPorts(device.ID(), node.Port().Device().ID()), ingress)
+	if len(ports) < 2 {
+		return false, nil
+	}
+	groupID, err := r.setGroup(device, ports)
+	if err != nil {
+		return false, errors.Wrap(err, "installing a select group")
+	}
+
+	match, err := device.Factory().NewMatch()
+	if err != nil {
+		return false, err
+	}
+	match.SetDstMAC(eth.DstMAC)
+	if err := device.SetGroupFlow(r.cookie, match, groupID, r.flowOpts); err != nil {
+		return false, errors.Wrap(err, "installing a group flow")
+	}
+	logger.Debugf("installed a group flow: device=%v, dstMAC=%v, group=%v, ports=%v", device.ID(), eth.DstMAC, groupID, len(ports))
+
+	packet, err := eth.MarshalBinary()
+	if err != nil {
+		return true, err
+	}
+	// The following packets will be distributed by the switch.
+	return true, r.PacketOut(ports[0], packet)
+}
+
+// availablePorts returns the ports that can be used to forward packets, except the ingress port and the
+// ports blocked by the spanning tree.
+func availablePorts(finder network.Finder, ports []*network.Port, ingress *network.Port) []*network.Port {
+	result := make([]*network.Port, 0, len(ports))
+	for _, p := range ports {
+		if p.ID() == ingress.ID() || !finder.IsEnabledPort(p) {
+			continue
+		}
+		v := p.Value()
+		if v == nil || v.IsPortDown() || v.IsLinkDown() {
+			continue
+		}
+		result = append(result, p)
+	}
+
+	return result
+}
+
+// setGroup installs a select group whose buckets are ports on device if it does not exist, and returns the group ID.
+func (r *ECMP) setGroup(device *network.Device, ports []*network.Port) (groupID uint32, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	table, ok := r.devices[device.ID()]
+	if !ok {
+		table = newGroupTable()
+		r.devices[device.ID()] = table
+	}
+	if id, ok := table.lookup(ports); ok {
+		return id, nil
+	}
+
+	id := table.add(ports)
+	if err := device.SetSelectGroup(openflow.GroupAdd, id, ports); err != nil {
+		table.remove(id)
+		return 0, err
+	}
+	logger.Infof("installed a select group: device=%v, group=%v, ports=%v", device.ID(), id, len(ports))
+
+	return id, nil
+}
+
+// OnPortDown drops the bucket of the failed port from the groups on its device, so that the switch
+// distributes the packets over the remaining links.
+func (r *ECMP) OnPortDown(finder network.Finder, port *network.Port) error {
+	r.updateGroups(port)
+
+	return r.BaseProcessor.OnPortDown(finder, port)
+}
+
+func (r *ECMP) updateGroups(port *network.Port) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	device := port.Device()
+	table, ok := r.devices[device.ID()]
+	if !ok {
+		return
+	}
+
+	for _, g := range table.removePort(port) {
+		if len(g.ports) == 0 {
+			// The switch also removes the flows referring to the group.
+			if err := device.RemoveGroup(g.id); err != nil {
+				logger.Errorf("failed to remove the group %v on %v: %v", g.id, device.ID(), err)
+			}
+			table.remove(g.id)
+			continue
+		}
+		if err := device.SetSelectGroup(openflow.GroupModify, g.id, g.ports); err != nil {
+			logger.Errorf("failed to modify the group %v on %v: %v", g.id, device.ID(), err)
+			continue
+		}
+		logger.Infof("removed the bucket of port %v from the group %v", port.ID(), g.id)
+	}
+}
+
+// OnDeviceUp removes all the groups that the device has kept from its previous connection, because the group
+// IDs are allocated again from the beginning and adding a group whose ID already exists fails.
+func (r *ECMP) OnDeviceUp(finder network.Finder, device *network.Device) error {
+	r.mutex.Lock()
+	delete(r.devices, device.ID())
+	r.mutex.Unlock()
+
+	if device.Factory().ProtocolVersion() == openflow.OF13_VERSION {
+		if err := device.RemoveGroup(openflow.GroupAllID); err != nil {
+			logger.Errorf("failed to remove the groups on %v: %v", device.ID(), err)
+		}
+	}
+
+	return r.BaseProcessor.OnDeviceUp(finder, device)
+}
+
+func (r *ECMP) OnDeviceDown(finder network.Finder, device *network.Device) error {
+	r.mutex.Lock()
+	delete(r.devices, device.ID())
+	r.mutex.Unlock()
+
+	return r.BaseProcessor.OnDeviceDown(finder, device)
+}
+
+// OnTopologyChange removes our flows and groups from all devices because the equal-cost links may
+// have been changed.
+func (r *ECMP) OnTopologyChange(finder network.Finder) error {
+	r.removeAll(finder.Devices())
+
+	return r.BaseProcessor.OnTopologyChange(finder)
+}
+
+func (r *ECMP) removeAll(devices []*network.Device) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, d := range devices {
+		if d.IsClosed() {
+			continue
+		}
+		if err := d.RemoveAppFlows(r.cookie); err != nil {
+			logger.Errorf("failed to remove the flows on %v: %v", d.ID(), err)
+		}
+		table, ok := r.devices[d.ID()]
+		if !ok {
+			continue
+		}
+		for _, id := range table.ids() {
+			if err := d.RemoveGroup(id); err != nil {
+				logger.Errorf("failed to remove the group %v on %v: %v", id, d.ID(), err)
+			}
+		}
+	}
+	r.devices = make(map[string]*groupTable)
+}
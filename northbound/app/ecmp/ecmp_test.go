@@ -0,0 +1,82 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package ecmp
+
+import (
+	"testing"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestRemoveGroupsOnDeviceUp(t *testing.T) {
+	fake := network.NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+
+	r := New()
+	if err := r.OnDeviceUp(fake, sw.Device); err != nil {
+		t.Fatalf("OnDeviceUp: %v", err)
+	}
+	found := false
+	for _, v := range sw.Messages() {
+		mod, ok := v.(openflow.GroupMod)
+		if ok && mod.Command() == openflow.GroupDelete && mod.GroupID() == openflow.GroupAllID {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected a GROUP_MOD that removes all the groups")
+	}
+}
+
+func TestAvailablePortsExcludeBlocked(t *testing.T) {
+	fake := network.NewFakeNetwork()
+	f := of13.NewFactory()
+	s1 := fake.AddSwitch("1", f, 1, 2, 3)
+	s2 := fake.AddSwitch("2", f, 1, 2)
+	s3 := fake.AddSwitch("3", f, 1, 2)
+	// The spanning tree blocks one of the links in the loop.
+	fake.Link(s1.Port(1), s2.Port(1))
+	fake.Link(s2.Port(2), s3.Port(1))
+	fake.Link(s3.Port(2), s1.Port(2))
+
+	ports := []*network.Port{s1.Port(1), s1.Port(2), s2.Port(1), s2.Port(2), s3.Port(1), s3.Port(2)}
+	blocked := 0
+	for _, p := range ports {
+		if !fake.IsEnabledPort(p) {
+			blocked++
+		}
+	}
+	if blocked == 0 {
+		t.Fatal("expected a blocked port in the loop")
+	}
+	result := availablePorts(fake, ports, s1.Port(3))
+	if len(result) != len(ports)-blocked {
+		t.Fatalf("expected %v available ports, got %v", len(ports)-blocked, len(result))
+	}
+	for _, p := range result {
+		if !fake.IsEnabledPort(p) {
+			t.Fatalf("blocked port %v is available", p.ID())
+		}
+	}
+}
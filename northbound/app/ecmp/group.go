@@ -0,0 +1,111 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package ecmp
+
+import (
+	"github.com/superkkt/cherry/network"
+)
+
+type group struct {
+	id    uint32
+	ports []*network.Port
+}
+
+// groupTable is the select groups installed on a device.
+type groupTable struct {
+	lastID uint32
+	groups []*group
+}
+
+func newGroupTable() *groupTable {
+	return &groupTable{}
+}
+
+// lookup returns the ID of the group whose buckets are exactly ports.
+func (r *groupTable) lookup(ports []*network.Port) (id uint32, ok bool) {
+	for _, g := range r.groups {
+		if samePorts(g.ports, ports) {
+			return g.id, true
+		}
+	}
+
+	return 0, false
+}
+
+// add allocates a new group ID for ports.
+func (r *groupTable) add(ports []*network.Port) uint32 {
+	r.lastID++
+	r.groups = append(r.groups, &group{id: r.lastID, ports: append([]*network.Port{}, ports...)})
+
+	return r.lastID
+}
+
+func (r *groupTable) remove(id uint32) {
+	for i, g := range r.groups {
+		if g.id == id {
+			r.groups = append(r.groups[:i], r.groups[i+1:]...)
+			return
+		}
+	}
+}
+
+// removePort removes port from the groups, and returns the modified groups.
+func (r *groupTable) removePort(port *network.Port) []*group {
+	modified := make([]*group, 0)
+	for _, g := range r.groups {
+		ports := make([]*network.Port, 0, len(g.ports))
+		for _, p := range g.ports {
+			if p.ID() != port.ID() {
+				ports = append(ports, p)
+			}
+		}
+		if len(ports) == len(g.ports) {
+			continue
+		}
+		g.ports = ports
+		modified = append(modified, g)
+	}
+
+	return modified
+}
+
+func (r *groupTable) ids() []uint32 {
+	result := make([]uint32, 0, len(r.groups))
+	for _, g := range r.groups {
+		result = append(result, g.id)
+	}
+
+	return result
+}
+
+func samePorts(p1, p2 []*network.Port) bool {
+	if len(p1) != len(p2) {
+		return false
+	}
+	for i := range p1 {
+		if p1[i].ID() != p2[i].ID() {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,66 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package ecmp
+
+import (
+	"testing"
+
+	"github.com/superkkt/cherry/network"
+)
+
+func TestGroupTable(t *testing.T) {
+	d := new(network.Device)
+	p1, p2, p3 := network.NewPort(d, 1), network.NewPort(d, 2), network.NewPort(d, 3)
+
+	table := newGroupTable()
+	id1 := table.add([]*network.Port{p1, p2, p3})
+	id2 := table.add([]*network.Port{p1, p2})
+	if id1 == id2 {
+		t.Fatalf("duplicated group ID: %v", id1)
+	}
+	if id, ok := table.lookup([]*network.Port{p1, p2}); !ok || id != id2 {
+		t.Fatalf("unexpected lookup result: ok=%v, id=%v", ok, id)
+	}
+	if _, ok := table.lookup([]*network.Port{p2, p3}); ok {
+		t.Fatal("unexpected group for unknown ports")
+	}
+
+	// Drop the failed bucket from the groups.
+	modified := table.removePort(p2)
+	if len(modified) != 2 {
+		t.Fatalf("unexpected number of modified groups: %v", len(modified))
+	}
+	if id, ok := table.lookup([]*network.Port{p1, p3}); !ok || id != id1 {
+		t.Fatalf("unexpected lookup result after removing a port: ok=%v, id=%v", ok, id)
+	}
+	if id, ok := table.lookup([]*network.Port{p1}); !ok || id != id2 {
+		t.Fatalf("unexpected lookup result after removing a port: ok=%v, id=%v", ok, id)
+	}
+	if modified := table.removePort(p2); len(modified) != 0 {
+		t.Fatalf("unexpected modified groups: %v", len(modified))
+	}
+
+	table.remove(id2)
+	if ids := table.ids(); len(ids) != 1 || ids[0] != id1 {
+		t.Fatalf("unexpected group IDs: %v", ids)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package app
+
+import (
+	"net"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/protocol"
+)
+
+// NewICMPEchoReply returns the Ethernet frame of the ICMP echo reply for eth if eth is an ICMP echo request
+// destined to vip, otherwise nil. The reply swaps the Ethernet and IP addresses of the request, and its
+// checksum is recalculated. An error is returned if the request is corrupted.
+func NewICMPEchoReply(eth *protocol.Ethernet, vip net.IP) ([]byte, error) {
+	if eth.Type != 0x0800 {
+		return nil, nil
+	}
+	ip := new(protocol.IPv4)
+	if err := ip.UnmarshalBinary(eth.Payload); err != nil {
+		return nil, err
+	}
+	if ip.Protocol != 1 || !ip.DstIP.Equal(vip) {
+		return nil, nil
+	}
+	request, err := ip.ICMP()
+	if err != nil {
+		return nil, err
+	}
+	if request.Type != protocol.ICMPTypeEchoRequest || request.Code != 0 {
+		return nil, nil
+	}
+
+	// Identifier and sequence number in the rest of the header should be echoed back with the data.
+	reply := protocol.ICMP{
+		Type: protocol.ICMPTypeEchoReply,
+		Rest: request.Rest,
+		Data: request.Data,
+	}
+	icmp, err := reply.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := protocol.NewIPv4(vip, ip.SrcIP, 1, icmp).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return protocol.Ethernet{
+		SrcMAC:  eth.DstMAC,
+		DstMAC:  eth.SrcMAC,
+		Type:    0x0800,
+		Payload: payload,
+	}.MarshalBinary()
+}
+
+// ReplyICMPEcho sends the ICMP echo reply to the ingress port if eth is an ICMP echo request destined to vip
+// that is owned by the controller. It returns true if the reply has been sent.
+func (r *BaseProcessor) ReplyICMPEcho(ingress *network.Port, eth *protocol.Ethernet, vip net.IP) (bool, error) {
+	reply, err := NewICMPEchoReply(eth, vip)
+	if err != nil || reply == nil {
+		return false, err
+	}
+
+	return true, r.PacketOut(ingress, reply)
+}
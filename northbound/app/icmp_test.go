@@ -0,0 +1,102 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package app
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/protocol"
+)
+
+func TestNewICMPEchoReply(t *testing.T) {
+	vip := net.IPv4(10, 0, 0, 1)
+	hostIP := net.IPv4(10, 0, 0, 2)
+	vipMAC := net.HardwareAddr{0x06, 0, 0, 0, 0, 1}
+	hostMAC := net.HardwareAddr{0x06, 0, 0, 0, 0, 2}
+
+	newRequest := func(dst net.IP, icmp *protocol.ICMP, corrupt bool) *protocol.Ethernet {
+		data, err := icmp.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal ICMP: %v", err)
+		}
+		if corrupt {
+			data[len(data)-1] ^= 0xFF
+		}
+		ip, err := protocol.NewIPv4(hostIP, dst, 1, data).MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal IPv4: %v", err)
+		}
+		return &protocol.Ethernet{SrcMAC: hostMAC, DstMAC: vipMAC, Type: 0x0800, Payload: ip}
+	}
+	echo := &protocol.ICMP{Type: protocol.ICMPTypeEchoRequest, Rest: 0x12340001, Data: []byte("ping")}
+
+	src := []struct {
+		request *protocol.Ethernet
+		reply   bool
+		err     bool
+	}{
+		{newRequest(vip, echo, false), true, false},
+		// Not destined to the VIP.
+		{newRequest(net.IPv4(10, 0, 0, 3), echo, false), false, false},
+		// Not an echo request.
+		{newRequest(vip, &protocol.ICMP{Type: protocol.ICMPTypeEchoReply}, false), false, false},
+		// Corrupted checksum.
+		{newRequest(vip, echo, true), false, true},
+	}
+
+	for i, v := range src {
+		frame, err := NewICMPEchoReply(v.request, vip)
+		if (err != nil) != v.err {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if (frame != nil) != v.reply {
+			t.Fatalf("#%v: unexpected reply: %v", i, frame)
+		}
+		if frame == nil {
+			continue
+		}
+
+		eth := new(protocol.Ethernet)
+		if err := eth.UnmarshalBinary(frame); err != nil {
+			t.Fatalf("#%v: failed to unmarshal Ethernet: %v", i, err)
+		}
+		if !bytes.Equal(eth.SrcMAC, vipMAC) || !bytes.Equal(eth.DstMAC, hostMAC) {
+			t.Fatalf("#%v: unexpected MAC addresses: src=%v, dst=%v", i, eth.SrcMAC, eth.DstMAC)
+		}
+		ip := new(protocol.IPv4)
+		if err := ip.UnmarshalBinary(eth.Payload); err != nil {
+			t.Fatalf("#%v: failed to unmarshal IPv4: %v", i, err)
+		}
+		if !ip.SrcIP.Equal(vip) || !ip.DstIP.Equal(hostIP) {
+			t.Fatalf("#%v: unexpected IP addresses: src=%v, dst=%v", i, ip.SrcIP, ip.DstIP)
+		}
+		icmp, err := ip.ICMP()
+		if err != nil {
+			t.Fatalf("#%v: failed to parse ICMP: %v", i, err)
+		}
+		if icmp.Type != protocol.ICMPTypeEchoReply || icmp.Rest != echo.Rest || !bytes.Equal(icmp.Data, echo.Data) {
+			t.Fatalf("#%v: unexpected echo reply: %+v", i, icmp)
+		}
+	}
+}
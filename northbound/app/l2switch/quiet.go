@@ -0,0 +1,65 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package l2switch
+
+import (
+	"net"
+	"sync"
+)
+
+// quietHosts is a set of the hosts that have not received any packet for the idle timeout of their flows. The
+// flow manager does not refresh the flows toward a quiet host, so they expire as the switches have already
+// removed the flow toward the host on its own device. A host is no longer quiet once a packet from or to the
+// host is punted to the controller.
+type quietHosts struct {
+	mutex sync.Mutex
+	// Key is the MAC address of a host.
+	hosts map[string]struct{}
+}
+
+func newQuietHosts() *quietHosts {
+	return &quietHosts{
+		hosts: make(map[string]struct{}),
+	}
+}
+
+func (r *quietHosts) add(mac net.HardwareAddr) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.hosts[mac.String()] = struct{}{}
+}
+
+func (r *quietHosts) remove(mac net.HardwareAddr) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.hosts, mac.String())
+}
+
+func (r *quietHosts) contains(mac net.HardwareAddr) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, ok := r.hosts[mac.String()]
+	return ok
+}
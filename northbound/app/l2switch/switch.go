@@ -23,6 +23,7 @@ package l2switch
 
 import (
 	"bytes"
+	"encoding"
 	"fmt"
 	"net"
 	"sync"
@@ -36,17 +37,64 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/pkg/errors"
 	"github.com/superkkt/go-logging"
+	"github.com/superkkt/viper"
 )
 
 var (
 	logger = logging.MustGetLogger("l2switch")
 )
 
+const (
+	// Interval to update the installed flows. This interval should be shorter than the hard
+	// timeout of the flows, if any.
+	flowManagerInterval = 35 * time.Second
+	// Hard timeout of the flows that drop the packets toward an undiscovered node. It is short so that the
+	// packets are forwarded soon after the node is discovered.
+	unknownUnicastDropTimeout = 5 * time.Second
+)
+
 type L2Switch struct {
 	app.BaseProcessor
 	stormCtrl *stormController
 	db        Database
 	once      sync.Once
+	// Meter to rate limit the installed flows. Zero ID means no meter.
+	meter openflow.Meter
+	// Cookie range of the flows installed by this application.
+	cookie network.AppCookie
+	// Timeouts and priority of the installed flows.
+	flowOpts network.FlowOptions
+	// Table that the flows are installed in on the OpenFlow 1.3 devices. Zero means table 0 without
+	// any pipeline.
+	forwardingTable uint8
+	// What to do with the unicast packets toward an undiscovered node.
+	unknownUnicast unknownUnicastMode
+	// Hosts whose flows are not refreshed by the flow manager.
+	quiet *quietHosts
+}
+
+type unknownUnicastMode int
+
+const (
+	// Flood the packets to all the ports.
+	unknownUnicastFlood unknownUnicastMode = iota
+	// Drop the packets with a short-lived flow.
+	unknownUnicastDrop
+	// Hand the packets to the legacy pipeline of a hybrid switch using OFPP_NORMAL.
+	unknownUnicastNormal
+)
+
+func (r unknownUnicastMode) String() string {
+	switch r {
+	case unknownUnicastFlood:
+		return "flood"
+	case unknownUnicastDrop:
+		return "drop"
+	case unknownUnicastNormal:
+		return "normal"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(r))
+	}
 }
 
 type Database interface {
@@ -58,6 +106,7 @@ func New(db Database) *L2Switch {
 	return &L2Switch{
 		stormCtrl: newStormController(100, new(flooder)),
 		db:        db,
+		quiet:     newQuietHosts(),
 	}
 }
 
@@ -69,9 +118,129 @@ func (r *flooder) flood(ingress *network.Port, packet []byte) error {
 }
 
 func (r *L2Switch) Init() error {
+	cookie, err := network.RegisterAppCookie(r.Name())
+	if err != nil {
+		return err
+	}
+	r.cookie = cookie
+
+	opts, err := loadFlowOptions()
+	if err != nil {
+		return err
+	}
+	if err := network.SetPriorityBand(cookie, network.PriorityBandForwarding); err != nil {
+		return err
+	}
+	if err := cookie.ValidatePriority(opts.Priority); err != nil {
+		return fmt.Errorf("invalid l2switch.priority in the config file: %v", err)
+	}
+	if opts.IdleTimeout != 0 && opts.IdleTimeoutJitter >= opts.IdleTimeout {
+		return errors.New("l2switch.idle_timeout_jitter should be shorter than l2switch.idle_timeout")
+	}
+	if opts.HardTimeout != 0 && opts.HardTimeoutJitter >= opts.HardTimeout {
+		return errors.New("l2switch.hard_timeout_jitter should be shorter than l2switch.hard_timeout")
+	}
+	r.flowOpts = opts
+	logger.Infof("flow options: idle_timeout=%v±%v, hard_timeout=%v±%v, priority=%v", opts.IdleTimeout, opts.IdleTimeoutJitter, opts.HardTimeout, opts.HardTimeoutJitter, opts.Priority)
+	// The shortest hard timeout after the jitter should be longer than the update interval.
+	if opts.HardTimeout != 0 && time.Duration(opts.HardTimeout-opts.HardTimeoutJitter)*time.Second <= flowManagerInterval {
+		logger.Warningf("l2switch.hard_timeout (%v±%vs) is not longer than the flow update interval (%v): the flows will expire before they are updated", opts.HardTimeout, opts.HardTimeoutJitter, flowManagerInterval)
+	}
+
+	table := viper.GetInt("l2switch.forwarding_table")
+	if table < 0 || table > 254 {
+		return errors.New("invalid l2switch.forwarding_table in the config file")
+	}
+	r.forwardingTable = uint8(table)
+
+	switch mode := viper.GetString("l2switch.unknown_unicast"); mode {
+	case "", "flood":
+		r.unknownUnicast = unknownUnicastFlood
+	case "drop":
+		r.unknownUnicast = unknownUnicastDrop
+	case "normal":
+		r.unknownUnicast = unknownUnicastNormal
+	default:
+		return fmt.Errorf("invalid l2switch.unknown_unicast in the config file: %v", mode)
+	}
+	logger.Infof("unknown unicast mode: %v", r.unknownUnicast)
+
+	id := viper.GetInt("l2switch.meter.id")
+	if id < 0 || id > 0xFFFF0000 {
+		return errors.New("invalid l2switch.meter.id in the config file")
+	}
+	if id == 0 {
+		return nil
+	}
+	rate := viper.GetInt("l2switch.meter.rate")
+	if rate <= 0 {
+		return errors.New("invalid l2switch.meter.rate in the config file")
+	}
+	burst := viper.GetInt("l2switch.meter.burst")
+	if burst < 0 {
+		return errors.New("invalid l2switch.meter.burst in the config file")
+	}
+
+	r.meter = openflow.Meter{
+		ID:    uint32(id),
+		Burst: burst > 0,
+		Bands: []openflow.MeterBand{
+			{Type: openflow.MeterBandDrop, Rate: uint32(rate), BurstSize: uint32(burst)},
+		},
+	}
+
 	return nil
 }
 
+// loadFlowOptions reads the options of the installed flows from the config file. The default options
+// are used for the absent keys.
+func loadFlowOptions() (network.FlowOptions, error) {
+	opts := network.DefaultFlowOptions
+
+	keys := []struct {
+		name  string
+		value *uint16
+	}{
+		{"l2switch.idle_timeout", &opts.IdleTimeout},
+		{"l2switch.hard_timeout", &opts.HardTimeout},
+		{"l2switch.idle_timeout_jitter", &opts.IdleTimeoutJitter},
+		{"l2switch.hard_timeout_jitter", &opts.HardTimeoutJitter},
+		{"l2switch.priority", &opts.Priority},
+	}
+	for _, k := range keys {
+		if !viper.IsSet(k.name) {
+			continue
+		}
+		v := viper.GetInt(k.name)
+		if v < 0 || v > 0xFFFF {
+			return network.FlowOptions{}, fmt.Errorf("invalid %v in the config file", k.name)
+		}
+		*k.value = uint16(v)
+	}
+
+	return opts, nil
+}
+
+// isMetered returns whether the flows on device should be rate limited by our meter.
+// OpenFlow 1.0 does not support meters.
+func (r *L2Switch) isMetered(device *network.Device) bool {
+	return r.meter.ID != 0 && device.Factory().ProtocolVersion() != openflow.OF10_VERSION
+}
+
+// isPipelined returns whether the flows on device should be installed in the forwarding table instead of
+// table 0. OpenFlow 1.0 does not support multiple tables, and we fall back to table 0 if the device does
+// not have the forwarding table, e.g., a device that has only one table.
+func (r *L2Switch) isPipelined(device *network.Device) bool {
+	return r.forwardingTable != 0 && device.Factory().ProtocolVersion() != openflow.OF10_VERSION && hasTable(device, r.forwardingTable)
+}
+
+// hasTable returns whether device has the flow table whose ID is id. It is assumed to be true if the
+// device has not reported the number of its tables.
+func hasTable(device *network.Device, id uint8) bool {
+	n := device.NumTables()
+	return n == 0 || id < n
+}
+
 func (r *L2Switch) Name() string {
 	return "L2Switch"
 }
@@ -81,32 +250,99 @@ func isBroadcast(eth *protocol.Ethernet) bool {
 }
 
 type flowParam struct {
-	device  *network.Device
+	device *network.Device
+	// VLAN tag of the packets. Nil means the untagged packets, whose flow has the default VLAN ID.
+	vlan    *protocol.VLANTag
 	dstMAC  net.HardwareAddr
 	outPort uint32
 }
 
 func (r flowParam) String() string {
+	if r.vlan != nil {
+		return fmt.Sprintf("Device=%v, VLAN=%v, DstMAC=%v, OutPort=%v", r.device.ID(), r.vlan.ID, r.dstMAC, r.outPort)
+	}
 	return fmt.Sprintf("Device=%v, DstMAC=%v, OutPort=%v", r.device.ID(), r.dstMAC, r.outPort)
 }
 
+// packetVLAN returns the outermost VLAN tag of eth, or nil if eth is untagged.
+func packetVLAN(eth *protocol.Ethernet) *protocol.VLANTag {
+	tag, ok := eth.VLAN()
+	if !ok {
+		return nil
+	}
+
+	return &tag
+}
+
 func (r *L2Switch) setFlow(p flowParam) error {
-	f := p.device.Factory()
-	match, err := f.NewMatch()
+	entry, err := r.flowEntry(p)
 	if err != nil {
 		return err
 	}
+	if err := p.device.SetFlowWithOptions(r.cookie, entry.Match, entry.Port, entry.Options); err != nil {
+		return err
+	}
+	logger.Debugf("installed a new flow rule: %v", p)
+
+	return nil
+}
+
+func (r *L2Switch) flowEntry(p flowParam) (network.FlowEntry, error) {
+	match, err := p.device.Factory().NewMatch()
+	if err != nil {
+		return network.FlowEntry{}, err
+	}
+	if p.vlan != nil {
+		match.SetVLANID(p.vlan.ID)
+	}
 	match.SetDstMAC(p.dstMAC)
 
 	outPort := openflow.NewOutPort()
 	outPort.SetValue(p.outPort)
 
-	if err := p.device.SetFlow(match, outPort); err != nil {
+	opts := r.flowOpts
+	if r.isMetered(p.device) {
+		opts.MeterID = r.meter.ID
+	}
+
+	return network.FlowEntry{Match: match, Port: outPort, Options: opts}, nil
+}
+
+// setDropFlow installs a short-lived flow that drops the packets toward dstMAC on device so that they are not
+// punted to the controller again until the node is discovered.
+func (r *L2Switch) setDropFlow(device *network.Device, eth *protocol.Ethernet) error {
+	match, err := device.Factory().NewMatch()
+	if err != nil {
 		return err
 	}
-	logger.Debugf("installed a new flow rule: %v", p)
+	if tag, ok := eth.VLAN(); ok {
+		match.SetVLANID(tag.ID)
+	}
+	match.SetDstMAC(eth.DstMAC)
 
-	return nil
+	return device.SetDropFlow(r.cookie, match, r.flowOpts.Priority, unknownUnicastDropTimeout)
+}
+
+// pickNode returns the most recently updated node in the VLAN whose ID is vlanID, which is zero for the untagged
+// packets, among the nodes whose ports are connected. It returns the most recently updated one of the other
+// VLANs if there is no node in the VLAN, e.g., its location has been learned before the VLANs are recorded, or
+// nil if there is no connected node.
+func pickNode(nodes []*network.Node, vlanID uint16) *network.Node {
+	var fallback *network.Node
+	for _, v := range nodes {
+		port := v.Port().Value()
+		if port.IsPortDown() || port.IsLinkDown() {
+			continue
+		}
+		if v.VLANID() == vlanID {
+			return v
+		}
+		if fallback == nil {
+			fallback = v
+		}
+	}
+
+	return fallback
 }
 
 type switchParam struct {
@@ -115,34 +351,107 @@ type switchParam struct {
 	ingress   *network.Port
 	egress    *network.Port
 	rawPacket []byte
+	info      network.PacketInInfo
 }
 
-func (r *L2Switch) switching(p switchParam) error {
-	param := flowParam{
+// newFlowParams returns the forward flow parameter that forwards the packets toward the egress port, and the
+// backward one that forwards the reply packets toward the ingress port, on the ingress device.
+func newFlowParams(p switchParam) (forward, backward flowParam) {
+	// The reply packets are in the same VLAN.
+	vlan := packetVLAN(p.ethernet)
+	forward = flowParam{
 		device:  p.ingress.Device(),
+		vlan:    vlan,
 		dstMAC:  p.ethernet.DstMAC,
 		outPort: p.egress.Number(),
 	}
-	if err := r.setFlow(param); err != nil {
+	backward = flowParam{
+		device:  p.ingress.Device(),
+		vlan:    vlan,
+		dstMAC:  p.ethernet.SrcMAC,
+		outPort: p.ingress.Number(),
+	}
+
+	return forward, backward
+}
+
+// isLocatedAt returns whether the node whose MAC address is mac is located at port.
+func isLocatedAt(finder network.Finder, mac net.HardwareAddr, port *network.Port) bool {
+	node, status, err := finder.Node(mac)
+	if err != nil || status != network.LocationDiscovered || node == nil {
+		return false
+	}
+
+	return node.Port().ID() == port.ID()
+}
+
+func (r *L2Switch) switching(p switchParam) error {
+	device := p.ingress.Device()
+	forward, backward := newFlowParams(p)
+	entry, err := r.flowEntry(forward)
+	if err != nil {
 		return err
 	}
+	flows := []network.FlowEntry{entry}
+	// Install the backward flow for the reply packets so that they are not punted to the controller again.
+	// The source node should be located at the ingress port, otherwise the ingress port may not be the right
+	// direction toward the source node.
+	if isLocatedAt(p.finder, p.ethernet.SrcMAC, p.ingress) {
+		if entry, err := r.flowEntry(backward); err != nil {
+			// The reply packets will be just punted to the controller.
+			logger.Errorf("failed to make the backward flow: %v", err)
+		} else {
+			flows = append(flows, entry)
+		}
+	}
 
 	// Send this ethernet packet directly to the destination node
 	logger.Debugf("sending a packet (Src=%v, Dst=%v) to egress port %v..", p.ethernet.SrcMAC, p.ethernet.DstMAC, p.egress.ID())
-	return r.PacketOut(p.egress, p.rawPacket)
+	var out openflow.PacketOut
+	// Use the switch buffer if the switch has buffered this packet so that we don't need to send it back.
+	if bufferID, ok := p.info.BufferID(); ok && device == p.egress.Device() {
+		out, err = app.NewBufferedPacketOut(p.ingress, p.egress, bufferID)
+	} else {
+		out, err = app.NewPacketOut(p.egress, p.rawPacket)
+	}
+	if err != nil {
+		return err
+	}
+	// The egress port is the first hop on the ingress device, so the flows and the packet are sent together
+	// in order using a single write.
+	batch := []encoding.BinaryMarshaler{out}
+	if device != p.egress.Device() {
+		batch = nil
+	}
+	if err := device.SetFlows(r.cookie, flows, batch...); err != nil {
+		return err
+	}
+	logger.Debugf("installed new flow rules: forward=(%v), backward=%v", forward, len(flows) > 1)
+	if batch == nil {
+		return p.egress.Device().SendMessage(out)
+	}
+
+	return nil
 }
 
-func (r *L2Switch) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) error {
-	drop, err := r.processPacket(finder, ingress, eth)
+func (r *L2Switch) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
+	drop, err := r.processPacket(finder, ingress, eth, info)
 	if drop || err != nil {
 		return err
 	}
 
-	return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+	return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
 }
 
-func (r *L2Switch) processPacket(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) (drop bool, err error) {
+func (r *L2Switch) processPacket(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) (drop bool, err error) {
 	logger.Debugf("PACKET_IN.. Ingress=%v, SrcMAC=%v, DstMAC=%v", ingress.ID(), eth.SrcMAC, eth.DstMAC)
+	// We always learn and forward against the logical ingress port, e.g., a link aggregation group.
+	if port, ok := info.PhysicalPort(); ok && port != 0 && port != ingress.Number() {
+		logger.Debugf("PACKET_IN is received on the physical port %v of the logical ingress port %v", port, ingress.ID())
+	}
+	// The hosts are talking again.
+	r.quiet.remove(eth.SrcMAC)
+	r.quiet.remove(eth.DstMAC)
 
 	packet, err := eth.MarshalBinary()
 	if err != nil {
@@ -156,12 +465,20 @@ func (r *L2Switch) processPacket(finder network.Finder, ingress *network.Port, e
 	}
 
 	logger.Debugf("finding node for %v...", eth.DstMAC)
-	dstNode, status, err := finder.Node(eth.DstMAC)
+	dstNodes, status, err := finder.Nodes(eth.DstMAC)
 	if err != nil {
 		return true, errors.Wrap(err, fmt.Sprintf("locating a node (MAC=%v)", eth.DstMAC))
 	}
 	if status != network.LocationDiscovered {
 		if status == network.LocationUndiscovered {
+			switch r.unknownUnicast {
+			case unknownUnicastDrop:
+				logger.Debugf("undiscovered node! dropping.. SrcMAC=%v, DstMAC=%v", eth.SrcMAC, eth.DstMAC)
+				return true, r.setDropFlow(ingress.Device(), eth)
+			case unknownUnicastNormal:
+				logger.Debugf("undiscovered node! sending to NORMAL.. SrcMAC=%v, DstMAC=%v", eth.SrcMAC, eth.DstMAC)
+				return true, ingress.Device().ForwardNormal(ingress, packet)
+			}
 			// Broadcast!
 			logger.Debugf("undiscovered node! broadcasting.. SrcMAC=%v, DstMAC=%v", eth.SrcMAC, eth.DstMAC)
 			return true, ingress.Device().Flood(ingress, packet)
@@ -173,14 +490,17 @@ func (r *L2Switch) processPacket(finder network.Finder, ingress *network.Port, e
 			panic(fmt.Sprintf("unexpected location status: %v", status))
 		}
 	}
-	logger.Debugf("found the node for %v: deviceID=%v, portNum=%v", eth.DstMAC, dstNode.Port().Device().ID(), dstNode.Port().Number())
-
+	var vlanID uint16
+	if tag := packetVLAN(eth); tag != nil {
+		vlanID = tag.ID
+	}
+	dstNode := pickNode(dstNodes, vlanID)
 	// Disconnected node?
-	port := dstNode.Port().Value()
-	if port.IsPortDown() || port.IsLinkDown() {
+	if dstNode == nil {
 		logger.Debugf("disconnected node! dropping.. SrcMAC=%v, DstMAC=%v", eth.SrcMAC, eth.DstMAC)
 		return true, nil
 	}
+	logger.Debugf("found the node for %v: deviceID=%v, portNum=%v", eth.DstMAC, dstNode.Port().Device().ID(), dstNode.Port().Number())
 
 	param := switchParam{}
 	// Check whether src and dst nodes reside on a same switch device
@@ -191,13 +511,15 @@ func (r *L2Switch) processPacket(finder network.Finder, ingress *network.Port, e
 			ingress:   ingress,
 			egress:    dstNode.Port(),
 			rawPacket: packet,
+			info:      info,
 		}
 	} else {
-		path := finder.Path(ingress.Device().ID(), dstNode.Port().Device().ID())
+		path, cost := finder.Path(ingress.Device().ID(), dstNode.Port().Device().ID())
 		if len(path) == 0 {
 			logger.Debugf("empty path.. dropping SrcMAC=%v, DstMAC=%v", eth.SrcMAC, eth.DstMAC)
 			return true, nil
 		}
+		logger.Debugf("found the path for %v: hops=%v, cost=%v", eth.DstMAC, len(path), cost)
 		egress := path[0][0]
 		// Drop this packet if it goes back to the ingress port to avoid duplicated packet routing
 		if ingress.Number() == egress.Number() {
@@ -211,12 +533,38 @@ func (r *L2Switch) processPacket(finder network.Finder, ingress *network.Port, e
 			ingress:   ingress,
 			egress:    egress,
 			rawPacket: packet,
+			info:      info,
 		}
 	}
 
 	return true, r.switching(param)
 }
 
+// OnAppFlowRemoved marks the host as quiet if our flow toward the host has been removed from the device where
+// the host is located by its idle timeout, which means that no packet has been sent to the host for a while.
+// The flows removed by the hard timeout or a delete request are just installed again as usual.
+func (r *L2Switch) OnAppFlowRemoved(finder network.Finder, device *network.Device, flow openflow.FlowRemoved) error {
+	if flow.Reason() != openflow.FlowRemovedIdleTimeout {
+		return nil
+	}
+	wildcard, mac := flow.Match().DstMAC()
+	if wildcard {
+		return nil
+	}
+	node, status, err := finder.Node(mac)
+	if err != nil || status != network.LocationDiscovered || node == nil {
+		return err
+	}
+	// The flows on the other devices may be idle because of the other paths toward the host.
+	if node.Port().Device().ID() != device.ID() {
+		return nil
+	}
+	r.quiet.add(mac)
+	logger.Debugf("the flow toward %v has been idle on %v: stop refreshing its flows", mac, device.ID())
+
+	return nil
+}
+
 func (r *L2Switch) OnTopologyChange(finder network.Finder) error {
 	logger.Debug("OnTopologyChange..")
 
@@ -230,13 +578,13 @@ func (r *L2Switch) OnTopologyChange(finder network.Finder) error {
 }
 
 func (r *L2Switch) removeAllFlows(devices []*network.Device) error {
-	logger.Debug("removing all flows from all devices..")
+	logger.Debug("removing all flows of this application from all devices..")
 
 	for _, d := range devices {
 		if d.IsClosed() {
 			continue
 		}
-		if err := d.RemoveFlows(); err != nil {
+		if err := d.RemoveAppFlows(r.cookie); err != nil {
 			return err
 		}
 		logger.Debugf("removed all flows from DPID %v", d.ID())
@@ -262,7 +610,7 @@ func (r *L2Switch) OnPortDown(finder network.Finder, port *network.Port) error {
 	outPort := openflow.NewOutPort()
 	outPort.SetValue(port.Number())
 
-	if err := device.RemoveFlow(match, outPort); err != nil {
+	if err := device.RemoveFlow(r.cookie, match, outPort); err != nil {
 		return errors.Wrap(err, fmt.Sprintf("removing flows heading to port %v", port.ID()))
 	}
 	logger.Debugf("removed all flows heading to the port %v", port.ID())
@@ -277,14 +625,28 @@ func (r *L2Switch) OnDeviceUp(finder network.Finder, device *network.Device) err
 		go r.flowManager(finder)
 	})
 
+	if r.isMetered(device) {
+		if err := device.SetMeter(r.meter); err != nil {
+			return errors.Wrap(err, "failed to install the meter")
+		}
+	}
+	if r.forwardingTable != 0 && !hasTable(device, r.forwardingTable) {
+		logger.Infof("%v has only %v flow tables: installing the flows in table 0 instead of the forwarding table %v", device.ID(), device.NumTables(), r.forwardingTable)
+	}
+	if r.isPipelined(device) {
+		// Table 0 sends the packets to the forwarding table using a goto-table instruction.
+		if err := device.SetForwardingTable(r.forwardingTable); err != nil {
+			logger.Warningf("failed to set the forwarding table of %v: %v", device.ID(), err)
+		}
+	}
+
 	return r.BaseProcessor.OnDeviceUp(finder, device)
 }
 
 func (r *L2Switch) flowManager(finder network.Finder) {
 	logger.Debug("executed flow manager")
 
-	// This interval should be shorter than the flow hard-timeout specified by network.Device.SetFlow().
-	ticker := time.Tick(35 * time.Second)
+	ticker := time.Tick(flowManagerInterval)
 	// Infinite loop.
 	for range ticker {
 		mac, err := r.db.MACAddrs()
@@ -302,6 +664,11 @@ func (r *L2Switch) flowManager(finder network.Finder) {
 }
 
 func (r *L2Switch) modifyFlows(finder network.Finder, mac net.HardwareAddr) {
+	if r.quiet.contains(mac) {
+		logger.Debugf("skip flow management for %v: quiet host", mac)
+		return
+	}
+
 	// Locate the destination node for the address.
 	node, status, err := finder.Node(mac)
 	if err != nil {
@@ -330,7 +697,7 @@ func (r *L2Switch) modifyFlows(finder network.Finder, mac net.HardwareAddr) {
 			egress = node.Port()
 		} else {
 			// Find the shortest path from this device to an another device that is connected to the destination node.
-			path := finder.Path(device.ID(), node.Port().Device().ID())
+			path, _ := finder.Path(device.ID(), node.Port().Device().ID())
 			// No path to the destination node?
 			if len(path) == 0 {
 				logger.Debugf("skip flow management for %v on %v: no path", mac, device.ID())
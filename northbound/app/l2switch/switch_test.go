@@ -0,0 +1,562 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package l2switch
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+	"github.com/superkkt/cherry/protocol"
+	"github.com/superkkt/viper"
+)
+
+func TestLoadFlowOptions(t *testing.T) {
+	src := []struct {
+		Config        map[string]interface{}
+		Expected      network.FlowOptions
+		ErrorExpected bool
+	}{
+		{
+			Config:   map[string]interface{}{},
+			Expected: network.DefaultFlowOptions,
+		},
+		{
+			Config:   map[string]interface{}{"l2switch.hard_timeout": 300},
+			Expected: network.FlowOptions{IdleTimeout: 90, HardTimeout: 300, Priority: 10},
+		},
+		{
+			Config:   map[string]interface{}{"l2switch.idle_timeout": 0, "l2switch.hard_timeout": 0xFFFF, "l2switch.priority": 100},
+			Expected: network.FlowOptions{IdleTimeout: 0, HardTimeout: 0xFFFF, Priority: 100},
+		},
+		{
+			Config:        map[string]interface{}{"l2switch.idle_timeout": -1},
+			ErrorExpected: true,
+		},
+		{
+			Config:        map[string]interface{}{"l2switch.priority": 0x10000},
+			ErrorExpected: true,
+		},
+	}
+
+	for i, v := range src {
+		viper.Reset()
+		for key, value := range v.Config {
+			viper.Set(key, value)
+		}
+
+		opts, err := loadFlowOptions()
+		if err != nil {
+			if v.ErrorExpected {
+				continue
+			}
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if v.ErrorExpected {
+			t.Fatalf("#%v: expected error, but got nil", i)
+		}
+		if opts != v.Expected {
+			t.Fatalf("#%v: unexpected options: expected=%+v, got=%+v", i, v.Expected, opts)
+		}
+	}
+	viper.Reset()
+}
+
+func TestNewFlowParams(t *testing.T) {
+	src := []struct {
+		SrcMAC, DstMAC  net.HardwareAddr
+		Ingress, Egress uint32
+	}{
+		{
+			SrcMAC:  net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+			DstMAC:  net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xAA, 0xBB},
+			Ingress: 1,
+			Egress:  2,
+		},
+		{
+			SrcMAC:  net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xAA, 0xBB},
+			DstMAC:  net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+			Ingress: 48,
+			Egress:  3,
+		},
+	}
+
+	for i, v := range src {
+		p := switchParam{
+			ethernet: &protocol.Ethernet{SrcMAC: v.SrcMAC, DstMAC: v.DstMAC},
+			ingress:  network.NewPort(nil, v.Ingress),
+			egress:   network.NewPort(nil, v.Egress),
+		}
+		forward, backward := newFlowParams(p)
+		if forward.device != backward.device {
+			t.Fatalf("#%v: forward and backward flows are on different devices", i)
+		}
+		if !bytes.Equal(forward.dstMAC, v.DstMAC) || forward.outPort != v.Egress {
+			t.Fatalf("#%v: unexpected forward flow: dstMAC=%v, outPort=%v", i, forward.dstMAC, forward.outPort)
+		}
+		// The backward flow should be a mirror image of the forward one.
+		if !bytes.Equal(backward.dstMAC, v.SrcMAC) || backward.outPort != v.Ingress {
+			t.Fatalf("#%v: unexpected backward flow: dstMAC=%v, outPort=%v", i, backward.dstMAC, backward.outPort)
+		}
+	}
+}
+
+func TestSwitching(t *testing.T) {
+	viper.Reset()
+	app := New(nil)
+	if err := app.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	// host1 - (1)sw1(2) - (2)sw2(1) - host2
+	//                      (3)
+	//                       |
+	//                     host3
+	fake := network.NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	sw2 := fake.AddSwitch("2", of13.NewFactory(), 1, 2, 3)
+	fake.Link(sw1.Port(2), sw2.Port(2))
+	host1 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	host2 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	host3 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x03}
+	fake.SetLocation(host1, sw1.Port(1))
+	fake.SetLocation(host2, sw2.Port(1))
+	fake.SetLocation(host3, sw2.Port(3))
+
+	src := []struct {
+		Device          *network.FakeSwitch
+		Ingress         uint32
+		SrcMAC, DstMAC  net.HardwareAddr
+		Flows           map[string]uint32 // Destination MAC to output port
+		PacketOutDevice *network.FakeSwitch
+		PacketOutPort   uint32
+	}{
+		// Toward the other switch.
+		{
+			Device:          sw1,
+			Ingress:         1,
+			SrcMAC:          host1,
+			DstMAC:          host2,
+			Flows:           map[string]uint32{host2.String(): 2, host1.String(): 1},
+			PacketOutDevice: sw1,
+			PacketOutPort:   2,
+		},
+		// Within the same switch.
+		{
+			Device:          sw2,
+			Ingress:         3,
+			SrcMAC:          host3,
+			DstMAC:          host2,
+			Flows:           map[string]uint32{host2.String(): 1, host3.String(): 3},
+			PacketOutDevice: sw2,
+			PacketOutPort:   1,
+		},
+		// The source is not located at the ingress port, so no backward flow.
+		{
+			Device:          sw2,
+			Ingress:         1,
+			SrcMAC:          host3,
+			DstMAC:          host1,
+			Flows:           map[string]uint32{host1.String(): 2},
+			PacketOutDevice: sw2,
+			PacketOutPort:   2,
+		},
+	}
+
+	for i, v := range src {
+		sw1.Reset()
+		sw2.Reset()
+
+		eth := &protocol.Ethernet{SrcMAC: v.SrcMAC, DstMAC: v.DstMAC, Type: 0x0800, Payload: make([]byte, 46)}
+		drop, err := app.processPacket(fake, v.Device.Port(v.Ingress), eth, network.PacketInInfo{})
+		if err != nil {
+			t.Fatalf("#%v: failed to process the packet: %v", i, err)
+		}
+		if !drop {
+			t.Fatalf("#%v: the packet is passed to the next application", i)
+		}
+
+		flows := v.Device.FlowMods()
+		if len(flows) != len(v.Flows) {
+			t.Fatalf("#%v: unexpected number of flows: expected=%v, got=%v", i, len(v.Flows), len(flows))
+		}
+		for _, f := range flows {
+			_, dstMAC := f.FlowMatch().DstMAC()
+			port, ok := v.Flows[dstMAC.String()]
+			if !ok {
+				t.Fatalf("#%v: unexpected flow toward %v", i, dstMAC)
+			}
+			out := f.FlowInstruction().Action().OutPort()
+			if out.Value() != port {
+				t.Fatalf("#%v: unexpected output port toward %v: expected=%v, got=%v", i, dstMAC, port, out.Value())
+			}
+		}
+
+		outs := v.PacketOutDevice.PacketOuts()
+		if len(outs) != 1 {
+			t.Fatalf("#%v: unexpected number of PACKET_OUTs: %v", i, len(outs))
+		}
+		out := outs[0].Action().OutPort()
+		if out.Value() != v.PacketOutPort {
+			t.Fatalf("#%v: unexpected PACKET_OUT port: expected=%v, got=%v", i, v.PacketOutPort, out.Value())
+		}
+	}
+	viper.Reset()
+}
+
+func TestDuplicatePacketIns(t *testing.T) {
+	viper.Reset()
+	app := New(nil)
+	if err := app.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	// host1 - (1)sw1(2) - host2
+	fake := network.NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	host1 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	host2 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	fake.SetLocation(host1, sw1.Port(1))
+	fake.SetLocation(host2, sw1.Port(2))
+
+	// The second PACKET_IN arrives before the flows installed by the first one take effect.
+	eth := &protocol.Ethernet{SrcMAC: host1, DstMAC: host2, Type: 0x0800, Payload: make([]byte, 46)}
+	for i := 0; i < 2; i++ {
+		if _, err := app.processPacket(fake, sw1.Port(1), eth, network.PacketInInfo{}); err != nil {
+			t.Fatalf("#%v: failed to process the packet: %v", i, err)
+		}
+	}
+
+	// Only one FLOW_MOD for each direction, but both packets are forwarded.
+	flows := sw1.FlowMods()
+	if len(flows) != 2 {
+		t.Fatalf("unexpected number of flows: expected=2, got=%v", len(flows))
+	}
+	if _, dst1 := flows[0].FlowMatch().DstMAC(); bytes.Equal(dst1, host2) {
+		if _, dst2 := flows[1].FlowMatch().DstMAC(); !bytes.Equal(dst2, host1) {
+			t.Fatalf("unexpected backward flow toward %v", dst2)
+		}
+	} else {
+		t.Fatalf("unexpected forward flow toward %v", dst1)
+	}
+	if outs := sw1.PacketOuts(); len(outs) != 2 {
+		t.Fatalf("unexpected number of PACKET_OUTs: %v", len(outs))
+	}
+	viper.Reset()
+}
+
+func TestSwitchingBatch(t *testing.T) {
+	viper.Reset()
+	app := New(nil)
+	if err := app.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	// host1 - (1)sw1(2) - host2
+	fake := network.NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	host1 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	host2 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	fake.SetLocation(host1, sw1.Port(1))
+	fake.SetLocation(host2, sw1.Port(2))
+
+	eth := &protocol.Ethernet{SrcMAC: host1, DstMAC: host2, Type: 0x0800, Payload: make([]byte, 46)}
+	if _, err := app.processPacket(fake, sw1.Port(1), eth, network.PacketInInfo{}); err != nil {
+		t.Fatalf("failed to process the packet: %v", err)
+	}
+
+	// The flows are confirmed by the barrier before the packet is forwarded by them.
+	msgs := sw1.Messages()
+	if len(msgs) != 4 {
+		t.Fatalf("unexpected number of messages: expected=4, got=%v", len(msgs))
+	}
+	for i, v := range msgs {
+		var ok bool
+		switch i {
+		case 0, 1:
+			_, ok = v.(openflow.FlowMod)
+		case 2:
+			_, ok = v.(openflow.BarrierRequest)
+		case 3:
+			_, ok = v.(openflow.PacketOut)
+		}
+		if !ok {
+			t.Fatalf("#%v: unexpected message: %T", i, v)
+		}
+	}
+	viper.Reset()
+}
+
+func TestVLANFlows(t *testing.T) {
+	viper.Reset()
+	viper.Set("default.vlan_id", 1000)
+	app := New(nil)
+	if err := app.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	host1 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	host2 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	src := []struct {
+		VLANs    []protocol.VLANTag
+		Expected uint16
+	}{
+		// Untagged packets use the default VLAN ID.
+		{VLANs: nil, Expected: 1000},
+		{VLANs: []protocol.VLANTag{{TPID: 0x8100, Priority: 3, ID: 100}}, Expected: 100},
+		// The outermost tag of a QinQ packet.
+		{VLANs: []protocol.VLANTag{{TPID: 0x88A8, ID: 200}, {TPID: 0x8100, ID: 100}}, Expected: 200},
+	}
+
+	for i, v := range src {
+		// host1 - (1)sw1(2) - host2
+		fake := network.NewFakeNetwork()
+		sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+		fake.SetLocation(host1, sw1.Port(1))
+		fake.SetLocation(host2, sw1.Port(2))
+
+		eth := &protocol.Ethernet{SrcMAC: host1, DstMAC: host2, VLANs: v.VLANs, Type: 0x0800, Payload: make([]byte, 46)}
+		if _, err := app.processPacket(fake, sw1.Port(1), eth, network.PacketInInfo{}); err != nil {
+			t.Fatalf("#%v: failed to process the packet: %v", i, err)
+		}
+		flows := sw1.FlowMods()
+		if len(flows) != 2 {
+			t.Fatalf("#%v: unexpected number of flows: expected=2, got=%v", i, len(flows))
+		}
+		for _, f := range flows {
+			if wildcard, vid := f.FlowMatch().VLANID(); wildcard || vid != v.Expected {
+				t.Fatalf("#%v: unexpected VLAN ID: expected=%v, got=%v (wildcard=%v)", i, v.Expected, vid, wildcard)
+			}
+		}
+	}
+	viper.Reset()
+}
+
+func TestUnknownUnicast(t *testing.T) {
+	src := []struct {
+		Mode       string
+		Flows      int
+		PacketOuts int
+		Normal     bool
+	}{
+		// Flooded by a PACKET_OUT to the FLOOD port.
+		{Mode: "flood", Flows: 0, PacketOuts: 1},
+		{Mode: "drop", Flows: 1, PacketOuts: 0},
+		// Handed to the switch's own MAC learning.
+		{Mode: "normal", Flows: 0, PacketOuts: 1, Normal: true},
+	}
+
+	for i, v := range src {
+		viper.Reset()
+		viper.Set("l2switch.unknown_unicast", v.Mode)
+		app := New(nil)
+		if err := app.Init(); err != nil {
+			t.Fatalf("#%v: failed to initialize: %v", i, err)
+		}
+
+		fake := network.NewFakeNetwork()
+		sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2, 3)
+		src := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+		dst := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+		fake.SetLocation(src, sw.Port(1))
+		fake.Register(dst)
+
+		eth := &protocol.Ethernet{SrcMAC: src, DstMAC: dst, Type: 0x0800, Payload: make([]byte, 46)}
+		drop, err := app.processPacket(fake, sw.Port(1), eth, network.PacketInInfo{})
+		if err != nil {
+			t.Fatalf("#%v: failed to process the packet: %v", i, err)
+		}
+		if !drop {
+			t.Fatalf("#%v: the packet is passed to the next application", i)
+		}
+
+		flows := sw.FlowMods()
+		if len(flows) != v.Flows {
+			t.Fatalf("#%v: unexpected number of flows: expected=%v, got=%v", i, v.Flows, len(flows))
+		}
+		for _, f := range flows {
+			if _, mac := f.FlowMatch().DstMAC(); !bytes.Equal(mac, dst) {
+				t.Fatalf("#%v: unexpected destination of the drop flow: %v", i, mac)
+			}
+			if f.FlowInstruction() != nil {
+				t.Fatalf("#%v: the drop flow has an instruction", i)
+			}
+			if f.HardTimeout() == 0 {
+				t.Fatalf("#%v: the drop flow has no hard timeout", i)
+			}
+		}
+		outs := sw.PacketOuts()
+		if n := len(outs); n != v.PacketOuts {
+			t.Fatalf("#%v: unexpected number of PACKET_OUTs: expected=%v, got=%v", i, v.PacketOuts, n)
+		}
+		for _, out := range outs {
+			port := out.Action().OutPort()
+			if port.IsNormal() != v.Normal {
+				t.Fatalf("#%v: unexpected output port of the PACKET_OUT: %v", i, port)
+			}
+		}
+	}
+	viper.Reset()
+}
+
+// removedFlow is the FLOW_REMOVED of a flow that has been added by a FLOW_MOD.
+type removedFlow struct {
+	openflow.FlowRemoved
+	flow   openflow.FlowMod
+	reason uint8
+}
+
+func (r *removedFlow) Cookie() uint64        { return r.flow.Cookie() }
+func (r *removedFlow) Match() openflow.Match { return r.flow.FlowMatch() }
+func (r *removedFlow) Reason() uint8         { return r.reason }
+
+func TestFlowRemoved(t *testing.T) {
+	viper.Reset()
+	app := New(nil)
+	if err := app.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	host1 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	host2 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	src := []struct {
+		Device string
+		Reason uint8
+		Quiet  bool
+	}{
+		// Idle on the device where the host is located.
+		{Device: "2", Reason: openflow.FlowRemovedIdleTimeout, Quiet: true},
+		// Idle on the other device.
+		{Device: "1", Reason: openflow.FlowRemovedIdleTimeout, Quiet: false},
+		{Device: "2", Reason: openflow.FlowRemovedHardTimeout, Quiet: false},
+		{Device: "2", Reason: openflow.FlowRemovedDelete, Quiet: false},
+		{Device: "2", Reason: openflow.FlowRemovedGroupDelete, Quiet: false},
+	}
+
+	for i, v := range src {
+		app.quiet = newQuietHosts()
+		// host1 - (1)sw1(2) - (2)sw2(1) - host2
+		fake := network.NewFakeNetwork()
+		sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+		sw2 := fake.AddSwitch("2", of13.NewFactory(), 1, 2)
+		fake.Link(sw1.Port(2), sw2.Port(2))
+		fake.SetLocation(host1, sw1.Port(1))
+		fake.SetLocation(host2, sw2.Port(1))
+		device := sw1
+		if v.Device == sw2.ID() {
+			device = sw2
+		}
+
+		app.modifyFlows(fake, host2)
+		flows := device.FlowMods()
+		if len(flows) != 1 {
+			t.Fatalf("#%v: unexpected number of flows: %v", i, len(flows))
+		}
+		if err := app.OnAppFlowRemoved(fake, device.Device, &removedFlow{flow: flows[0], reason: v.Reason}); err != nil {
+			t.Fatalf("#%v: failed to handle the removed flow: %v", i, err)
+		}
+		if app.quiet.contains(host2) != v.Quiet {
+			t.Fatalf("#%v: unexpected quiet status: expected=%v, got=%v", i, v.Quiet, !v.Quiet)
+		}
+		if !v.Quiet {
+			continue
+		}
+
+		// The flows toward the quiet host are not refreshed.
+		sw1.Reset()
+		sw2.Reset()
+		app.modifyFlows(fake, host2)
+		if len(sw1.FlowMods()) != 0 || len(sw2.FlowMods()) != 0 {
+			t.Fatalf("#%v: the flows toward the quiet host are refreshed", i)
+		}
+		// A packet toward the host makes it active again.
+		eth := &protocol.Ethernet{SrcMAC: host1, DstMAC: host2, Type: 0x0800, Payload: make([]byte, 46)}
+		if _, err := app.processPacket(fake, sw1.Port(1), eth, network.PacketInInfo{}); err != nil {
+			t.Fatalf("#%v: failed to process the packet: %v", i, err)
+		}
+		if app.quiet.contains(host2) {
+			t.Fatalf("#%v: the host is still quiet after a packet", i)
+		}
+	}
+	viper.Reset()
+}
+
+func TestForwardingTable(t *testing.T) {
+	viper.Reset()
+	viper.Set("l2switch.forwarding_table", 2)
+	app := New(nil)
+	if err := app.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	src := []struct {
+		NumTables uint8
+		Pipelined bool
+		TableID   uint8
+	}{
+		// Fall back to table 0 if the device has only one table.
+		{1, false, 0},
+		{4, true, 2},
+	}
+
+	for i, v := range src {
+		fake := network.NewFakeNetwork()
+		sw := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+		sw.SetNumTables(v.NumTables)
+		host1 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+		host2 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+		fake.SetLocation(host1, sw.Port(1))
+		fake.SetLocation(host2, sw.Port(2))
+
+		if pipelined := app.isPipelined(sw.Device); pipelined != v.Pipelined {
+			t.Fatalf("#%v: unexpected pipeline: expected=%v, got=%v", i, v.Pipelined, pipelined)
+		}
+		if err := sw.SetForwardingTable(2); (err == nil) != v.Pipelined {
+			t.Fatalf("#%v: unexpected result of setting the forwarding table: %v", i, err)
+		}
+		if id := sw.TableID(network.TableForwarding); id != v.TableID {
+			t.Fatalf("#%v: unexpected forwarding table: expected=%v, got=%v", i, v.TableID, id)
+		}
+		if id := sw.TableID(network.TableClassification); id != 0 {
+			t.Fatalf("#%v: unexpected classification table: %v", i, id)
+		}
+		sw.Reset()
+
+		eth := &protocol.Ethernet{SrcMAC: host1, DstMAC: host2, Type: 0x0800, Payload: make([]byte, 46)}
+		if _, err := app.processPacket(fake, sw.Port(1), eth, network.PacketInInfo{}); err != nil {
+			t.Fatalf("#%v: failed to process the packet: %v", i, err)
+		}
+		flows := sw.FlowMods()
+		if len(flows) == 0 {
+			t.Fatalf("#%v: no flows are installed", i)
+		}
+		for _, f := range flows {
+			if f.TableID() != v.TableID {
+				t.Fatalf("#%v: unexpected flow table: expected=%v, got=%v", i, v.TableID, f.TableID())
+			}
+		}
+	}
+	viper.Reset()
+}
@@ -0,0 +1,298 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package multicast
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/northbound/app"
+	"github.com/superkkt/cherry/protocol"
+
+	"github.com/superkkt/go-logging"
+	"github.com/superkkt/viper"
+)
+
+var (
+	logger = logging.MustGetLogger("multicast")
+
+	// Link-local multicast addresses (224.0.0.0/24) such as IGMPv3 reports should be flooded.
+	linkLocal = net.IPNet{IP: net.IPv4(224, 0, 0, 0), Mask: net.CIDRMask(24, 32)}
+)
+
+// Multicast snoops IGMP membership reports, and delivers the multicast packets only to the subscribed
+// ports by installing the flows along the distribution tree from the source toward the members.
+type Multicast struct {
+	app.BaseProcessor
+	mutex sync.Mutex
+	// Key is a group address, and value is the member ports keyed by their port IDs.
+	groups   map[string]map[string]*network.Port
+	flowOpts network.FlowOptions
+}
+
+func New() *Multicast {
+	return &Multicast{
+		groups:   make(map[string]map[string]*network.Port),
+		flowOpts: network.DefaultMulticastFlowOptions,
+	}
+}
+
+func (r *Multicast) Init() error {
+	r.flowOpts = network.DefaultMulticastFlowOptions
+	if viper.IsSet("multicast.idle_timeout") {
+		v := viper.GetInt("multicast.idle_timeout")
+		if v < 0 || v > 0xFFFF {
+			return errors.New("invalid multicast.idle_timeout in the config file")
+		}
+		r.flowOpts.IdleTimeout = uint16(v)
+	}
+	if viper.IsSet("multicast.priority") {
+		v := viper.GetInt("multicast.priority")
+		if v <= 0 || v > 0xFFFF {
+			return errors.New("invalid multicast.priority in the config file")
+		}
+		r.flowOpts.Priority = uint16(v)
+	}
+	if err := network.PriorityBandMulticast.Validate(r.flowOpts.Priority); err != nil {
+		return fmt.Errorf("invalid multicast.priority in the config file: %v", err)
+	}
+	logger.Infof("flow idle timeout: %v, priority: %v", r.flowOpts.IdleTimeout, r.flowOpts.Priority)
+
+	return nil
+}
+
+func (r *Multicast) Name() string {
+	return "Multicast"
+}
+
+func (r *Multicast) String() string {
+	return fmt.Sprintf("%v", r.Name())
+}
+
+func (r *Multicast) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
+	if eth.Type != 0x0800 /* IPv4 */ {
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
+	}
+
+	ip := new(protocol.IPv4)
+	if err := ip.UnmarshalBinary(eth.Payload); err != nil {
+		logger.Debugf("drop an invalid IPv4 packet: %v", err)
+		return nil
+	}
+
+	if ip.Protocol == 2 /* IGMP */ {
+		igmp := new(protocol.IGMP)
+		if err := igmp.UnmarshalBinary(ip.Payload); err != nil {
+			logger.Debugf("bypass an invalid IGMP packet: %v", err)
+			return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
+		}
+		r.processIGMP(finder, ingress, igmp)
+		// Multicast routers (queriers) on the network may also need the IGMP packets.
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
+	}
+
+	if !ip.DstIP.IsMulticast() || linkLocal.Contains(ip.DstIP) {
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
+	}
+
+	return r.processMulticast(finder, ingress, ip, eth)
+}
+
+func (r *Multicast) processIGMP(finder network.Finder, ingress *network.Port, igmp *protocol.IGMP) {
+	switch igmp.Type {
+	case protocol.IGMPv1MembershipReport, protocol.IGMPv2MembershipReport:
+		r.join(finder, igmp.Group, ingress)
+	case protocol.IGMPv2LeaveGroup:
+		r.leave(finder, igmp.Group, ingress)
+	case protocol.IGMPv3MembershipReport:
+		for _, v := range igmp.Records {
+			if v.IsJoin() {
+				r.join(finder, v.Group, ingress)
+			} else if v.IsLeave() {
+				r.leave(finder, v.Group, ingress)
+			}
+		}
+	default:
+		// Ignore queries.
+	}
+}
+
+func (r *Multicast) join(finder network.Finder, group net.IP, p *network.Port) {
+	if !group.IsMulticast() || linkLocal.Contains(group) {
+		return
+	}
+
+	r.mutex.Lock()
+	members, ok := r.groups[group.String()]
+	if !ok {
+		members = make(map[string]*network.Port)
+		r.groups[group.String()] = members
+	}
+	_, exist := members[p.ID()]
+	members[p.ID()] = p
+	r.mutex.Unlock()
+
+	if exist {
+		return
+	}
+	logger.Infof("new multicast member: group=%v, port=%v", group, p.ID())
+	// The distribution trees will be rebuilt by the next multicast packet.
+	removeFlows(finder.Devices(), group)
+}
+
+func (r *Multicast) leave(finder network.Finder, group net.IP, p *network.Port) {
+	r.mutex.Lock()
+	members, ok := r.groups[group.String()]
+	if ok {
+		_, ok = members[p.ID()]
+		delete(members, p.ID())
+		if len(members) == 0 {
+			delete(r.groups, group.String())
+		}
+	}
+	r.mutex.Unlock()
+
+	if !ok {
+		return
+	}
+	logger.Infof("multicast member left: group=%v, port=%v", group, p.ID())
+	removeFlows(finder.Devices(), group)
+}
+
+func (r *Multicast) members(group net.IP) []*network.Port {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v := make([]*network.Port, 0)
+	for _, p := range r.groups[group.String()] {
+		v = append(v, p)
+	}
+
+	return v
+}
+
+func (r *Multicast) processMulticast(finder network.Finder, ingress *network.Port, ip *protocol.IPv4, eth *protocol.Ethernet) error {
+	members := r.members(ip.DstIP)
+	// Drop the packet if there is no member instead of flooding it.
+	if len(members) == 0 {
+		logger.Debugf("drop a multicast packet without any member: group=%v, source=%v", ip.DstIP, ip.SrcIP)
+		return nil
+	}
+
+	tree := finder.Tree(ingress, members)
+	for id, ports := range tree {
+		device := finder.Device(id)
+		if device == nil || device.IsClosed() {
+			continue
+		}
+		if err := device.SetMulticastFlow(ip.SrcIP, ip.DstIP, ports, r.flowOpts); err != nil {
+			logger.Errorf("failed to install a multicast flow on %v: %v", id, err)
+			continue
+		}
+		logger.Debugf("installed a multicast flow: device=%v, source=%v, group=%v, # of ports=%v", id, ip.SrcIP, ip.DstIP, len(ports))
+	}
+
+	// Deliver the current packet directly to the members.
+	packet, err := eth.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	for _, p := range members {
+		if p.ID() == ingress.ID() {
+			continue
+		}
+		if err := r.PacketOut(p, packet); err != nil {
+			logger.Errorf("failed to send a multicast packet to %v: %v", p.ID(), err)
+		}
+	}
+
+	return nil
+}
+
+func removeFlows(devices []*network.Device, group net.IP) {
+	for _, d := range devices {
+		if d.IsClosed() {
+			continue
+		}
+		if err := d.RemoveMulticastFlows(group); err != nil {
+			logger.Errorf("failed to remove multicast flows (group=%v) on %v: %v", group, d.ID(), err)
+		}
+	}
+}
+
+// removeMembers removes the members that match with f, and then removes the flows of the affected groups.
+func (r *Multicast) removeMembers(finder network.Finder, f func(*network.Port) bool) {
+	affected := make([]net.IP, 0)
+
+	r.mutex.Lock()
+	for group, members := range r.groups {
+		removed := false
+		for id, p := range members {
+			if f(p) {
+				delete(members, id)
+				removed = true
+			}
+		}
+		if len(members) == 0 {
+			delete(r.groups, group)
+		}
+		if removed {
+			affected = append(affected, net.ParseIP(group))
+		}
+	}
+	r.mutex.Unlock()
+
+	for _, group := range affected {
+		removeFlows(finder.Devices(), group)
+	}
+}
+
+func (r *Multicast) OnPortDown(finder network.Finder, port *network.Port) error {
+	r.removeMembers(finder, func(p *network.Port) bool { return p.ID() == port.ID() })
+
+	return r.BaseProcessor.OnPortDown(finder, port)
+}
+
+func (r *Multicast) OnDeviceDown(finder network.Finder, device *network.Device) error {
+	r.removeMembers(finder, func(p *network.Port) bool { return p.Device().ID() == device.ID() })
+
+	return r.BaseProcessor.OnDeviceDown(finder, device)
+}
+
+func (r *Multicast) OnTopologyChange(finder network.Finder) error {
+	// The distribution trees may not be valid anymore.
+	r.mutex.Lock()
+	groups := make([]net.IP, 0)
+	for group := range r.groups {
+		groups = append(groups, net.ParseIP(group))
+	}
+	r.mutex.Unlock()
+
+	for _, group := range groups {
+		removeFlows(finder.Devices(), group)
+	}
+
+	return r.BaseProcessor.OnTopologyChange(finder)
+}
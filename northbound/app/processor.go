@@ -41,6 +41,21 @@ type Processor interface {
 	SetNext(Processor)
 }
 
+// Tap is an optional interface of the processors that observe the PACKET_INs without consuming them, e.g., for
+// the intrusion detection. OnPacketTap of the enabled taps is called with a copy of each packet before the
+// application chain runs, so a tap can neither drop nor modify the packet that the chain receives.
+type Tap interface {
+	OnPacketTap(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet)
+}
+
+// FlowOwner is an optional interface of the processors that keep states of their own flows, e.g., the hosts
+// whose flows are refreshed. OnAppFlowRemoved is called with the FLOW_REMOVED of a flow that has the cookie
+// of the processor, before the application chain receives it. The reason of the removal, the match, duration
+// and counters are available from flow.
+type FlowOwner interface {
+	OnAppFlowRemoved(finder network.Finder, device *network.Device, flow openflow.FlowRemoved) error
+}
+
 type BaseProcessor struct {
 	next Processor
 }
@@ -57,13 +72,13 @@ func (r *BaseProcessor) Dependencies() []string {
 	return []string{}
 }
 
-func (r *BaseProcessor) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) error {
+func (r *BaseProcessor) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
 	// Do nothging and execute the next processor if it exists
 	next, ok := r.Next()
 	if !ok {
 		return nil
 	}
-	return next.OnPacketIn(finder, ingress, eth)
+	return next.OnPacketIn(finder, ingress, eth, info)
 }
 
 func (r *BaseProcessor) OnDeviceUp(finder network.Finder, device *network.Device) error {
@@ -111,13 +126,13 @@ func (r *BaseProcessor) OnTopologyChange(finder network.Finder) error {
 	return next.OnTopologyChange(finder)
 }
 
-func (r *BaseProcessor) OnFlowRemoved(finder network.Finder, flow openflow.FlowRemoved) error {
+func (r *BaseProcessor) OnFlowRemoved(finder network.Finder, device *network.Device, flow openflow.FlowRemoved) error {
 	// Do nothging and execute the next processor if it exists
 	next, ok := r.Next()
 	if !ok {
 		return nil
 	}
-	return next.OnFlowRemoved(finder, flow)
+	return next.OnFlowRemoved(finder, device, flow)
 }
 
 func (r *BaseProcessor) Next() (next Processor, ok bool) {
@@ -133,6 +148,17 @@ func (r *BaseProcessor) SetNext(next Processor) {
 }
 
 func (r *BaseProcessor) PacketOut(egress *network.Port, packet []byte) error {
+	out, err := NewPacketOut(egress, packet)
+	if err != nil {
+		return err
+	}
+
+	return egress.Device().SendMessage(out)
+}
+
+// NewPacketOut returns a PACKET_OUT that sends the packet to the egress port, which can be sent later,
+// e.g., with the flows by Device.SetFlows.
+func NewPacketOut(egress *network.Port, packet []byte) (openflow.PacketOut, error) {
 	f := egress.Device().Factory()
 
 	inPort := openflow.NewInPort()
@@ -143,17 +169,60 @@ func (r *BaseProcessor) PacketOut(egress *network.Port, packet []byte) error {
 
 	action, err := f.NewAction()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	action.SetOutPort(outPort)
 
 	out, err := f.NewPacketOut()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	out.SetInPort(inPort)
 	out.SetAction(action)
 	out.SetData(packet)
 
+	return out, nil
+}
+
+// BufferedPacketOut sends the packet held in the switch buffer, whose ID is bufferID, to the egress
+// port. ingress is the port that the buffered packet has been received from, and it should be on the
+// same device with the egress port.
+func (r *BaseProcessor) BufferedPacketOut(ingress, egress *network.Port, bufferID uint32) error {
+	out, err := NewBufferedPacketOut(ingress, egress, bufferID)
+	if err != nil {
+		return err
+	}
+
 	return egress.Device().SendMessage(out)
 }
+
+// NewBufferedPacketOut returns a PACKET_OUT that sends the packet held in the switch buffer to the egress
+// port, which can be sent later, e.g., with the flows by Device.SetFlows.
+func NewBufferedPacketOut(ingress, egress *network.Port, bufferID uint32) (openflow.PacketOut, error) {
+	if ingress.Device() != egress.Device() {
+		return nil, fmt.Errorf("buffered packet cannot be sent to another device: ingress=%v, egress=%v", ingress.ID(), egress.ID())
+	}
+	f := egress.Device().Factory()
+
+	inPort := openflow.NewInPort()
+	inPort.SetValue(ingress.Number())
+
+	outPort := openflow.NewOutPort()
+	outPort.SetValue(egress.Number())
+
+	action, err := f.NewAction()
+	if err != nil {
+		return nil, err
+	}
+	action.SetOutPort(outPort)
+
+	out, err := f.NewPacketOut()
+	if err != nil {
+		return nil, err
+	}
+	out.SetInPort(inPort)
+	out.SetAction(action)
+	out.SetBufferID(bufferID)
+
+	return out, nil
+}
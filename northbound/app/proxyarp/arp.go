@@ -25,6 +25,7 @@ import (
 	"bytes"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/superkkt/cherry/network"
 	"github.com/superkkt/cherry/northbound/app"
@@ -33,6 +34,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/superkkt/go-logging"
+	"github.com/superkkt/viper"
 )
 
 var (
@@ -42,6 +44,8 @@ var (
 type ProxyARP struct {
 	app.BaseProcessor
 	db database
+	// IP-to-MAC addresses learned from the gratuitous ARP packets. Nil if the learning is disabled.
+	learned *learnedTable
 }
 
 type database interface {
@@ -60,6 +64,15 @@ func New(db database) *ProxyARP {
 }
 
 func (r *ProxyARP) Init() error {
+	if !viper.GetBool("proxyarp.learn") {
+		return nil
+	}
+	timeout := viper.GetInt("proxyarp.learn_timeout")
+	if timeout <= 0 {
+		return errors.New("invalid proxyarp.learn_timeout in the config file")
+	}
+	r.learned = newLearnedTable(time.Duration(timeout) * time.Second)
+
 	return nil
 }
 
@@ -67,20 +80,25 @@ func (r *ProxyARP) Name() string {
 	return "ProxyARP"
 }
 
-func (r *ProxyARP) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) error {
+func (r *ProxyARP) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
 	// ARP?
 	if eth.Type != 0x0806 {
-		return r.BaseProcessor.OnPacketIn(finder, ingress, eth)
+		return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
 	}
 
 	logger.Debugf("received ARP packet.. ingress=%v, srcEthMAC=%v, dstEthMAC=%v", ingress.ID(), eth.SrcMAC, eth.DstMAC)
 
 	arp := new(protocol.ARP)
 	if err := arp.UnmarshalBinary(eth.Payload); err != nil {
-		return err
+		logger.Debugf("drop the malformed ARP packet.. ingress=%v, err=%v", ingress.ID(), err)
+		return nil
 	}
 	// Drop ARP announcement
 	if isARPAnnouncement(arp) {
+		if r.learned != nil && finder.IsEdge(ingress) == false && arp.SPA.IsUnspecified() == false {
+			logger.Debugf("learned %v (%v) from the ARP announcement.. ingress=%v", arp.SPA, arp.SHA, ingress.ID())
+			r.learned.update(arp.SPA, arp.SHA)
+		}
 		// We don't allow a host sends ARP announcement to the network. This controller only can send it,
 		// and we will flood the announcement to all switch devices using PACKET_OUT  when we need it.
 		logger.Debugf("drop ARP announcements.. ingress=%v (%v)", ingress.ID(), arp)
@@ -97,6 +115,9 @@ func (r *ProxyARP) OnPacketIn(finder network.Finder, ingress *network.Port, eth
 	if err != nil {
 		return errors.Wrap(&proxyarpErr{temporary: true, err: err}, "failed to query MAC")
 	}
+	if !ok {
+		mac, ok = r.lookupLearned(finder, arp.TPA)
+	}
 	if !ok {
 		logger.Debugf("drop the ARP request for unknown host (%v)", arp.TPA)
 		// Unknown hosts. Drop the packet.
@@ -113,6 +134,30 @@ func (r *ProxyARP) OnPacketIn(finder network.Finder, ingress *network.Port, eth
 	return sendARPReply(ingress, reply)
 }
 
+// lookupLearned returns the MAC address of ip learned from the ARP announcements if the host is still
+// attached to the network.
+func (r *ProxyARP) lookupLearned(finder network.Finder, ip net.IP) (mac net.HardwareAddr, ok bool) {
+	if r.learned == nil {
+		return nil, false
+	}
+	mac, ok = r.learned.lookup(ip)
+	if !ok {
+		return nil, false
+	}
+
+	node, _, err := finder.Node(mac)
+	if err != nil {
+		logger.Errorf("failed to find the learned host location: %v", err)
+		return nil, false
+	}
+	if node == nil {
+		logger.Debugf("ignore the learned host whose location is unknown (%v, %v)", ip, mac)
+		return nil, false
+	}
+
+	return mac, true
+}
+
 func sendARPReply(ingress *network.Port, packet []byte) error {
 	f := ingress.Device().Factory()
 
@@ -0,0 +1,70 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package proxyarp
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// learnedTable is the IP-to-MAC addresses learned from the gratuitous ARP packets sent by the hosts.
+type learnedTable struct {
+	mutex sync.RWMutex
+	// Key is the IPv4 address.
+	entries map[string]learnedEntry
+	timeout time.Duration
+}
+
+type learnedEntry struct {
+	mac       net.HardwareAddr
+	timestamp time.Time
+}
+
+func newLearnedTable(timeout time.Duration) *learnedTable {
+	return &learnedTable{
+		entries: make(map[string]learnedEntry),
+		timeout: timeout,
+	}
+}
+
+func (r *learnedTable) update(ip net.IP, mac net.HardwareAddr) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.entries[ip.String()] = learnedEntry{mac: mac, timestamp: time.Now()}
+}
+
+// lookup returns the MAC address of ip if it has been learned within the timeout.
+func (r *learnedTable) lookup(ip net.IP) (mac net.HardwareAddr, ok bool) {
+	// Read lock
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	v, ok := r.entries[ip.String()]
+	if !ok || time.Since(v.timestamp) > r.timeout {
+		return nil, false
+	}
+
+	return v.mac, true
+}
@@ -0,0 +1,52 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package proxyarp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLearnedTable(t *testing.T) {
+	mac1 := net.HardwareAddr{0x00, 0x0b, 0x82, 0x01, 0xfc, 0x42}
+	mac2 := net.HardwareAddr{0x00, 0x0b, 0x82, 0x01, 0xfc, 0x43}
+	ip := net.IPv4(10, 0, 0, 1)
+
+	table := newLearnedTable(time.Hour)
+	if _, ok := table.lookup(ip); ok {
+		t.Fatal("expected unknown IP address")
+	}
+	table.update(ip, mac1)
+	// The IP address moved to another host.
+	table.update(ip.To4(), mac2)
+	if mac, ok := table.lookup(ip); !ok || !bytes.Equal(mac, mac2) {
+		t.Fatalf("unexpected learned MAC: ok=%v, mac=%v", ok, mac)
+	}
+
+	expired := newLearnedTable(time.Hour)
+	expired.entries[ip.String()] = learnedEntry{mac: mac1, timestamp: time.Now().Add(-2 * time.Hour)}
+	if _, ok := expired.lookup(ip); ok {
+		t.Fatal("expected the expired entry to be ignored")
+	}
+}
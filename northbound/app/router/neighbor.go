@@ -0,0 +1,153 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package router
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/protocol"
+)
+
+const (
+	// Maximum number of the packets queued for each next hop whose MAC address is being resolved.
+	maxPendingPackets = 16
+	// Time after which a neighbor that has not been seen again should be resolved again.
+	neighborTimeout = 5 * time.Minute
+)
+
+// neighborTable is the ARP cache of the next hops, and holds the packets toward the next hops whose MAC
+// addresses are being resolved.
+type neighborTable struct {
+	mutex sync.Mutex
+	// Time to wait for the ARP reply before the queued packets are dropped.
+	resolveTimeout time.Duration
+	// Key is the IP address.
+	entries map[string]neighbor
+	pending map[string]*pendingQueue
+}
+
+type neighbor struct {
+	mac net.HardwareAddr
+	// Port that the neighbor's ARP packet has been received from.
+	port      *network.Port
+	timestamp time.Time
+}
+
+// queuedPacket is a packet that will be routed when the MAC address of its next hop is resolved.
+type queuedPacket struct {
+	ingress *network.Port
+	eth     *protocol.Ethernet
+	// Destination prefix of the flow.
+	prefix *net.IPNet
+}
+
+type pendingQueue struct {
+	packets  []queuedPacket
+	deadline time.Time
+}
+
+func newNeighborTable(resolveTimeout time.Duration) *neighborTable {
+	return &neighborTable{
+		resolveTimeout: resolveTimeout,
+		entries:        make(map[string]neighbor),
+		pending:        make(map[string]*pendingQueue),
+	}
+}
+
+// lookup returns the neighbor whose IP address is ip if it has been seen within the neighbor timeout.
+func (r *neighborTable) lookup(ip net.IP, now time.Time) (neighbor, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.entries[ip.String()]
+	if !ok || now.Sub(v.timestamp) > neighborTimeout {
+		return neighbor{}, false
+	}
+
+	return v, true
+}
+
+// update records the MAC address of ip that has been seen on port, and returns the packets waiting for it. moved
+// is true if ip has been seen with another MAC address or on another port before.
+func (r *neighborTable) update(ip net.IP, mac net.HardwareAddr, port *network.Port, now time.Time) (queued []queuedPacket, moved bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := ip.String()
+	if v, ok := r.entries[key]; ok {
+		moved = !bytes.Equal(v.mac, mac) || v.port.ID() != port.ID()
+	}
+	r.entries[key] = neighbor{mac: mac, port: port, timestamp: now}
+
+	q, ok := r.pending[key]
+	if !ok {
+		return nil, moved
+	}
+	delete(r.pending, key)
+	if now.After(q.deadline) {
+		return nil, moved
+	}
+
+	return q.packets, moved
+}
+
+// forget removes the neighbors that have been seen on port, and returns their IP addresses.
+func (r *neighborTable) forget(port *network.Port) []net.IP {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := make([]net.IP, 0)
+	for k, v := range r.entries {
+		if v.port.ID() != port.ID() {
+			continue
+		}
+		delete(r.entries, k)
+		result = append(result, net.ParseIP(k).To4())
+	}
+
+	return result
+}
+
+// enqueue holds the packet until the MAC address of nextHop is resolved. It returns true if the ARP request
+// for nextHop should be sent, i.e., no request is in progress. The packet is dropped if the queue is full.
+func (r *neighborTable) enqueue(nextHop net.IP, p queuedPacket, now time.Time) (resolve bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := nextHop.String()
+	q, ok := r.pending[key]
+	if !ok || now.After(q.deadline) {
+		// The previous request has not been answered. Drop its packets and try again.
+		q = &pendingQueue{deadline: now.Add(r.resolveTimeout)}
+		r.pending[key] = q
+		resolve = true
+	}
+	if len(q.packets) < maxPendingPackets {
+		q.packets = append(q.packets, p)
+	}
+
+	return resolve
+}
@@ -0,0 +1,458 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+// Package router routes the IPv4 packets among the subnets configured in the config file.
+//
+// The hosts use router.mac, a virtual MAC address owned by the controller, as the MAC address of their
+// default gateway, and Router answers the ARP requests and pings for the gateway addresses in router.interfaces.
+// When Router receives an IPv4 packet destined to the router MAC, it finds the next hop in the routing table,
+// which is the destination itself for a directly connected subnet or the next hop of the longest matching
+// route in router.routes. It then resolves the MAC address of the next hop by an ARP request, holding the
+// packets toward the next hop until the reply arrives, and installs a flow on the ingress switch that matches
+// the destination prefix, decrements the IP TTL, rewrites the source and destination MAC addresses to the
+// router MAC and the next hop MAC, and outputs the packets toward the next hop. Decrementing the TTL is only
+// supported by OpenFlow 1.3, so the packets received from OpenFlow 1.0 switches are not routed.
+//
+// Router and L2Switch do not handle the same traffic. Router only consumes the IPv4 packets destined to the
+// router MAC and the ARP packets from and to the router, and passes all the other packets to the next
+// application. L2Switch never installs a flow for the router MAC because it is not a host location, and the
+// routed packets, which are destined to the next hop MAC, are forwarded by the L2Switch flows on the following
+// switches. Router should be enabled with a priority higher than Discovery, ProxyARP and L2Switch, e.g.,
+// "Router:10" in default.applications, because Discovery and ProxyARP drop the ARP replies destined to others.
+package router
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/northbound/app"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/protocol"
+
+	"github.com/pkg/errors"
+	"github.com/superkkt/go-logging"
+	"github.com/superkkt/viper"
+)
+
+var (
+	logger = logging.MustGetLogger("router")
+)
+
+const (
+	// Higher than the default priorities of the L2Switch and ECMP flows, although they never overlap
+	// because the router flows match the router MAC.
+	defaultPriority = 16
+	// Default seconds to wait for the ARP reply of a next hop.
+	defaultARPTimeout = 3
+)
+
+type Router struct {
+	app.BaseProcessor
+	cookie    network.AppCookie
+	mac       net.HardwareAddr
+	priority  uint16
+	table     *routingTable
+	neighbors *neighborTable
+	clock     clock.Clock
+}
+
+func New() *Router {
+	return &Router{
+		clock: clock.New(),
+	}
+}
+
+func (r *Router) Name() string {
+	return "Router"
+}
+
+func (r *Router) String() string {
+	return fmt.Sprintf("%v", r.Name())
+}
+
+func (r *Router) Init() error {
+	mac, err := net.ParseMAC(viper.GetString("router.mac"))
+	if err != nil || len(mac) != 6 || mac[0]&0x01 != 0 {
+		return errors.New("invalid router.mac in the config file")
+	}
+	r.mac = mac
+
+	table, err := newRoutingTable(viper.GetStringSlice("router.interfaces"), viper.GetStringSlice("router.routes"))
+	if err != nil {
+		return errors.Wrap(err, "invalid router.interfaces or router.routes in the config file")
+	}
+	r.table = table
+
+	timeout := defaultARPTimeout
+	if viper.IsSet("router.arp_timeout") {
+		timeout = viper.GetInt("router.arp_timeout")
+		if timeout <= 0 {
+			return errors.New("invalid router.arp_timeout in the config file")
+		}
+	}
+	r.neighbors = newNeighborTable(time.Duration(timeout) * time.Second)
+
+	cookie, err := network.RegisterAppCookie(r.Name())
+	if err != nil {
+		return err
+	}
+	r.cookie = cookie
+	r.priority = defaultPriority
+	if viper.IsSet("router.priority") {
+		v := viper.GetInt("router.priority")
+		if v <= 0 || v > 0xFFFF {
+			return errors.New("invalid router.priority in the config file")
+		}
+		r.priority = uint16(v)
+	}
+	if err := network.SetPriorityBand(cookie, network.PriorityBandForwarding); err != nil {
+		return err
+	}
+	if err := cookie.ValidatePriority(r.priority); err != nil {
+		return fmt.Errorf("invalid router.priority in the config file: %v", err)
+	}
+	logger.Infof("router MAC: %v, flow priority: %v", r.mac, r.priority)
+
+	return nil
+}
+
+func (r *Router) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
+	switch eth.Type {
+	case 0x0806:
+		done, err := r.processARP(finder, ingress, eth)
+		if done || err != nil {
+			return err
+		}
+	case 0x0800:
+		if bytes.Equal(eth.DstMAC, r.mac) {
+			// Nobody else handles the packets destined to the router.
+			return r.processIPv4(finder, ingress, eth)
+		}
+	}
+
+	return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
+}
+
+func (r *Router) processARP(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) (done bool, err error) {
+	arp := new(protocol.ARP)
+	if err := arp.UnmarshalBinary(eth.Payload); err != nil {
+		// Let the next applications decide.
+		return false, nil
+	}
+	// Our ARP request propagated via an edge among switches?
+	if bytes.Equal(arp.SHA, r.mac) {
+		return true, nil
+	}
+
+	// Learn the neighbors from their ARP packets received from the hosts.
+	if !finder.IsEdge(ingress) && r.table.isConnected(arp.SPA) && !r.table.isGateway(arp.SPA) {
+		r.learn(finder, arp.SPA, arp.SHA, ingress)
+	}
+
+	switch {
+	case arp.Operation == 1 && r.table.isGateway(arp.TPA):
+		reply, err := newARPReply(arp, r.mac)
+		if err != nil {
+			return true, err
+		}
+		logger.Debugf("sending ARP reply for the gateway %v to %v", arp.TPA, ingress.ID())
+		return true, r.PacketOut(ingress, reply)
+	case arp.Operation == 2 && (bytes.Equal(arp.THA, r.mac) || bytes.Equal(eth.DstMAC, r.mac)):
+		// Reply for our request, which has been learned above.
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// learn records the MAC address of the neighbor, and routes the packets that have been waiting for it.
+func (r *Router) learn(finder network.Finder, ip net.IP, mac net.HardwareAddr, port *network.Port) {
+	queued, moved := r.neighbors.update(ip, mac, port, r.clock.Now())
+	if moved {
+		// The flows toward the previous location would blackhole the packets until they expire.
+		logger.Infof("next hop %v has moved to %v (%v): removing its routes", ip, port.ID(), mac)
+		r.removeRoutes(finder, ip)
+	}
+	if len(queued) == 0 {
+		return
+	}
+	logger.Debugf("resolved the next hop %v (%v): routing %v queued packet(s)", ip, mac, len(queued))
+
+	n := neighbor{mac: mac, port: port}
+	for _, v := range queued {
+		if err := r.forward(finder, v, n); err != nil {
+			logger.Errorf("failed to route the queued packet toward %v: %v", ip, err)
+		}
+	}
+}
+
+func (r *Router) processIPv4(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) error {
+	if ingress.Device().Factory().ProtocolVersion() != openflow.OF13_VERSION {
+		logger.Debugf("dropping the packet to be routed from an OpenFlow 1.0 switch: ingress=%v", ingress.ID())
+		return nil
+	}
+	ip := new(protocol.IPv4)
+	if err := ip.UnmarshalBinary(eth.Payload); err != nil {
+		logger.Debugf("dropping the malformed IPv4 packet: ingress=%v, err=%v", ingress.ID(), err)
+		return nil
+	}
+	if r.table.isGateway(ip.DstIP) {
+		_, err := r.ReplyICMPEcho(ingress, eth, ip.DstIP)
+		return err
+	}
+	if ip.TTL <= 1 {
+		logger.Debugf("dropping the packet whose TTL is expired: src=%v, dst=%v", ip.SrcIP, ip.DstIP)
+		return nil
+	}
+
+	nextHop, prefix, ok := r.table.lookup(ip.DstIP)
+	if !ok {
+		logger.Debugf("dropping the packet toward an unknown subnet: src=%v, dst=%v", ip.SrcIP, ip.DstIP)
+		return nil
+	}
+	p := queuedPacket{ingress: ingress, eth: eth, prefix: prefix}
+	now := r.clock.Now()
+	n, ok := r.neighbors.lookup(nextHop, now)
+	if !ok {
+		if r.neighbors.enqueue(nextHop, p, now) {
+			r.resolve(finder, nextHop)
+		}
+		return nil
+	}
+
+	return r.forward(finder, p, n)
+}
+
+// resolve floods the ARP request for ip to all the devices.
+func (r *Router) resolve(finder network.Finder, ip net.IP) {
+	gateway, ok := r.table.gatewayOf(ip)
+	if !ok {
+		return
+	}
+	for _, d := range finder.Devices() {
+		if d.IsClosed() {
+			continue
+		}
+		if err := d.SendARPDiscovery(r.mac, gateway, ip); err != nil {
+			logger.Errorf("failed to send the ARP request for %v on %v: %v", ip, d.ID(), err)
+		}
+	}
+	logger.Debugf("sent the ARP request for the next hop %v", ip)
+}
+
+// forward installs the flow that routes the packets toward the next hop n on the ingress device, and then
+// sends the packet p using the same actions.
+func (r *Router) forward(finder network.Finder, p queuedPacket, n neighbor) error {
+	device := p.ingress.Device()
+	if device.IsClosed() {
+		return nil
+	}
+	egress := r.egress(finder, device, n)
+	if egress == nil {
+		logger.Debugf("dropping the packet toward %v: no path from %v", n.mac, device.ID())
+		return nil
+	}
+	f := device.Factory()
+
+	match, err := f.NewMatch()
+	if err != nil {
+		return err
+	}
+	match.SetEtherType(0x0800)
+	match.SetDstMAC(r.mac)
+	match.SetDstIP(p.prefix)
+	action, err := r.newAction(f, n.mac, egress)
+	if err != nil {
+		return err
+	}
+	inst, err := f.NewInstruction()
+	if err != nil {
+		return err
+	}
+	inst.ApplyAction(action)
+	flow, err := network.NewAppFlowMod(f, openflow.FlowAdd, r.cookie)
+	if err != nil {
+		return err
+	}
+	flow.SetTableID(device.FlowTableID())
+	flow.SetIdleTimeout(network.DefaultFlowOptions.IdleTimeout)
+	flow.SetPriority(r.priority)
+	flow.SetFlowMatch(match)
+	flow.SetFlowInstruction(inst)
+
+	packet, err := p.eth.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	// The packet is rewritten by the switch in the same way as the following packets.
+	outAction, err := r.newAction(f, n.mac, egress)
+	if err != nil {
+		return err
+	}
+	inPort := openflow.NewInPort()
+	inPort.SetController()
+	out, err := f.NewPacketOut()
+	if err != nil {
+		return err
+	}
+	out.SetInPort(inPort)
+	out.SetAction(outAction)
+	out.SetData(packet)
+	logger.Debugf("routing %v toward %v (%v) via %v", p.prefix, n.mac, egress.ID(), device.ID())
+
+	return device.SendMessages([]encoding.BinaryMarshaler{flow, out})
+}
+
+func (r *Router) newAction(f openflow.Factory, dstMAC net.HardwareAddr, egress *network.Port) (openflow.Action, error) {
+	action, err := f.NewAction()
+	if err != nil {
+		return nil, err
+	}
+	action.SetDecNWTTL()
+	action.SetSrcMAC(r.mac)
+	action.SetDstMAC(dstMAC)
+	port := openflow.NewOutPort()
+	port.SetValue(egress.Number())
+	action.SetOutPort(port)
+
+	// Unsupported actions of the OpenFlow version are reported by the action.
+	return action, action.Error()
+}
+
+// egress returns the port on device toward the neighbor n, or nil if there is no path.
+func (r *Router) egress(finder network.Finder, device *network.Device, n neighbor) *network.Port {
+	port := n.port
+	// Prefer the current location of the neighbor if it has been discovered.
+	if node, status, err := finder.Node(n.mac); err == nil && status == network.LocationDiscovered {
+		port = node.Port()
+	}
+	if port.Device().ID() == device.ID() {
+		return port
+	}
+	path, _ := finder.Path(device.ID(), port.Device().ID())
+	if len(path) == 0 {
+		return nil
+	}
+
+	return path[0][0]
+}
+
+// OnPortDown removes the flows whose egress port is the port, and the flows toward the next hops located at the
+// port, which are forgotten until they are learned again. Otherwise, the flows would blackhole the packets as long
+// as the packets keep them alive.
+func (r *Router) OnPortDown(finder network.Finder, port *network.Port) error {
+	device := port.Device()
+	if !device.IsClosed() && device.Factory().ProtocolVersion() == openflow.OF13_VERSION {
+		if err := r.removeFlows(device, nil, port.Number()); err != nil {
+			logger.Errorf("failed to remove the flows toward %v: %v", port.ID(), err)
+		}
+	}
+	for _, ip := range r.neighbors.forget(port) {
+		logger.Infof("next hop %v is located at %v that is down: removing its routes", ip, port.ID())
+		r.removeRoutes(finder, ip)
+	}
+
+	return r.BaseProcessor.OnPortDown(finder, port)
+}
+
+// removeRoutes removes the flows toward the next hop from all the devices. The flows are installed again by the
+// following packets with the current location of the next hop.
+func (r *Router) removeRoutes(finder network.Finder, nextHop net.IP) {
+	prefixes := r.table.prefixesVia(nextHop)
+	for _, d := range finder.Devices() {
+		// Only the OpenFlow 1.3 devices have the routes.
+		if d.IsClosed() || d.Factory().ProtocolVersion() != openflow.OF13_VERSION {
+			continue
+		}
+		for _, v := range prefixes {
+			if err := r.removeFlows(d, v, 0); err != nil {
+				logger.Errorf("failed to remove the routes toward %v on %v: %v", v, d.ID(), err)
+			}
+		}
+	}
+}
+
+// removeFlows removes our flows on device whose destination prefix is the same with or more specific than
+// prefix, and whose egress port is port. Nil prefix and zero port match any.
+func (r *Router) removeFlows(device *network.Device, prefix *net.IPNet, port uint32) error {
+	f := device.Factory()
+	match, err := f.NewMatch()
+	if err != nil {
+		return err
+	}
+	match.SetEtherType(0x0800)
+	match.SetDstMAC(r.mac)
+	if prefix != nil {
+		match.SetDstIP(prefix)
+	}
+	outPort := openflow.NewOutPort()
+	outPort.SetNone()
+	if port != 0 {
+		outPort.SetValue(port)
+	}
+
+	// The cookie mask keeps the flows of the other applications.
+	flow, err := network.NewAppFlowMod(f, openflow.FlowDelete, r.cookie)
+	if err != nil {
+		return err
+	}
+	flow.SetTableID(0xFF) // ALL
+	flow.SetFlowMatch(match)
+	flow.SetOutPort(outPort)
+
+	return device.SendMessage(flow)
+}
+
+// OnTopologyChange removes our flows from all devices because the paths toward the next hops may have been
+// changed. The flows are installed again by the following packets.
+func (r *Router) OnTopologyChange(finder network.Finder) error {
+	for _, d := range finder.Devices() {
+		if d.IsClosed() {
+			continue
+		}
+		if err := d.RemoveAppFlows(r.cookie); err != nil {
+			logger.Errorf("failed to remove the flows on %v: %v", d.ID(), err)
+		}
+	}
+
+	return r.BaseProcessor.OnTopologyChange(finder)
+}
+
+func newARPReply(request *protocol.ARP, mac net.HardwareAddr) ([]byte, error) {
+	v := protocol.NewARPReply(mac, request.SHA, request.TPA, request.SPA)
+	reply, err := v.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	eth := protocol.Ethernet{
+		SrcMAC:  mac,
+		DstMAC:  request.SHA,
+		Type:    0x0806,
+		Payload: reply,
+	}
+
+	return eth.MarshalBinary()
+}
@@ -0,0 +1,204 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package router
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+	"github.com/superkkt/cherry/protocol"
+	"github.com/superkkt/viper"
+)
+
+func TestRouting(t *testing.T) {
+	viper.Reset()
+	routerMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0xFE}
+	viper.Set("router.mac", routerMAC.String())
+	viper.Set("router.interfaces", []string{"10.0.1.1/24", "10.0.2.1/24"})
+	viper.Set("router.routes", []string{"192.168.0.0/16 via 10.0.2.254"})
+	app := New()
+	if err := app.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	defer viper.Reset()
+
+	// host1 - (1)sw1(2) - (2)sw2(1) - gateway (10.0.2.254)
+	fake := network.NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	sw2 := fake.AddSwitch("2", of13.NewFactory(), 1, 2)
+	fake.Link(sw1.Port(2), sw2.Port(2))
+	host1 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	gateway := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	fake.SetLocation(host1, sw1.Port(1))
+	fake.SetLocation(gateway, sw2.Port(1))
+
+	// A packet from host1 toward a remote subnet behind the gateway.
+	payload, err := protocol.NewIPv4(net.ParseIP("10.0.1.10"), net.ParseIP("192.168.1.1"), 17, make([]byte, 26)).MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal the IPv4 packet: %v", err)
+	}
+	eth := &protocol.Ethernet{SrcMAC: host1, DstMAC: routerMAC, Type: 0x0800, Payload: payload}
+	if err := app.OnPacketIn(fake, sw1.Port(1), eth, network.PacketInInfo{}); err != nil {
+		t.Fatalf("failed to process the IPv4 packet: %v", err)
+	}
+	// The packet is queued until the next hop is resolved.
+	if len(sw1.FlowMods()) != 0 {
+		t.Fatalf("unexpected flows before the next hop is resolved")
+	}
+	for _, sw := range []*network.FakeSwitch{sw1, sw2} {
+		if len(sw.PacketOuts()) != 1 {
+			t.Fatalf("no ARP request for the next hop on %v", sw.ID())
+		}
+		sw.Reset()
+	}
+
+	// ARP reply from the gateway.
+	reply, err := protocol.NewARPReply(gateway, routerMAC, net.ParseIP("10.0.2.254"), net.ParseIP("10.0.2.1")).MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal the ARP reply: %v", err)
+	}
+	eth = &protocol.Ethernet{SrcMAC: gateway, DstMAC: routerMAC, Type: 0x0806, Payload: reply}
+	if err := app.OnPacketIn(fake, sw2.Port(1), eth, network.PacketInInfo{}); err != nil {
+		t.Fatalf("failed to process the ARP reply: %v", err)
+	}
+
+	flows := sw1.FlowMods()
+	if len(flows) != 1 {
+		t.Fatalf("unexpected number of flows: %v", len(flows))
+	}
+	f := flows[0]
+	if f.Priority() != defaultPriority {
+		t.Fatalf("unexpected flow priority: %v", f.Priority())
+	}
+	if dst := f.FlowMatch().DstIP(); dst == nil || dst.String() != "192.168.0.0/16" {
+		t.Fatalf("unexpected destination prefix: %v", dst)
+	}
+	action := f.FlowInstruction().Action()
+	if !action.DecNWTTL() {
+		t.Fatalf("the flow does not decrement TTL")
+	}
+	if ok, mac := action.SrcMAC(); !ok || !bytes.Equal(mac, routerMAC) {
+		t.Fatalf("unexpected source MAC: %v", mac)
+	}
+	if ok, mac := action.DstMAC(); !ok || !bytes.Equal(mac, gateway) {
+		t.Fatalf("unexpected destination MAC: %v", mac)
+	}
+	out := action.OutPort()
+	if out.Value() != 2 {
+		t.Fatalf("unexpected output port: %v", out.Value())
+	}
+	// The queued packet is sent by a PACKET_OUT.
+	outs := sw1.PacketOuts()
+	if len(outs) != 1 {
+		t.Fatalf("the queued packet is not sent")
+	}
+	out = outs[0].Action().OutPort()
+	if out.Value() != 2 {
+		t.Fatalf("unexpected PACKET_OUT port: %v", out.Value())
+	}
+}
+
+func TestRouteRemoval(t *testing.T) {
+	viper.Reset()
+	routerMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0xFE}
+	viper.Set("router.mac", routerMAC.String())
+	viper.Set("router.interfaces", []string{"10.0.1.1/24", "10.0.2.1/24"})
+	viper.Set("router.routes", []string{"192.168.0.0/16 via 10.0.2.254"})
+	app := New()
+	if err := app.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	defer viper.Reset()
+
+	// host1 - (1)sw1(2) - (2)sw2(1) - gateway (10.0.2.254), and sw2(3) where the gateway moves to.
+	fake := network.NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2)
+	sw2 := fake.AddSwitch("2", of13.NewFactory(), 1, 2, 3)
+	fake.Link(sw1.Port(2), sw2.Port(2))
+	gateway := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	fake.SetLocation(gateway, sw2.Port(1))
+
+	learn := func(port *network.Port) {
+		reply, err := protocol.NewARPReply(gateway, routerMAC, net.ParseIP("10.0.2.254"), net.ParseIP("10.0.2.1")).MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal the ARP reply: %v", err)
+		}
+		eth := &protocol.Ethernet{SrcMAC: gateway, DstMAC: routerMAC, Type: 0x0806, Payload: reply}
+		if err := app.OnPacketIn(fake, port, eth, network.PacketInInfo{}); err != nil {
+			t.Fatalf("failed to process the ARP reply: %v", err)
+		}
+	}
+	// isRouteRemoval returns whether flow removes the routes toward the gateway.
+	isRouteRemoval := func(flow openflow.FlowMod) bool {
+		dst := flow.FlowMatch().DstIP()
+		return flow.Command() == openflow.FlowDelete && app.cookie.Owns(flow.Cookie()) && dst != nil && (dst.String() == "192.168.0.0/16" || dst.String() == "10.0.2.254/32")
+	}
+
+	learn(sw2.Port(1))
+	for _, sw := range []*network.FakeSwitch{sw1, sw2} {
+		if n := len(sw.FlowMods()); n != 0 {
+			t.Fatalf("unexpected flows on %v: %v", sw.ID(), n)
+		}
+	}
+
+	// The next hop has moved, so the routes toward its previous location are removed from all the devices.
+	fake.SetLocation(gateway, sw2.Port(3))
+	learn(sw2.Port(3))
+	for _, sw := range []*network.FakeSwitch{sw1, sw2} {
+		flows := sw.FlowMods()
+		if len(flows) != 2 || !isRouteRemoval(flows[0]) || !isRouteRemoval(flows[1]) {
+			t.Fatalf("the routes toward the moved next hop are not removed on %v: %v", sw.ID(), flows)
+		}
+		sw.Reset()
+	}
+
+	// The port toward sw2 goes down: the flows whose egress is the port are removed.
+	if err := app.OnPortDown(fake, sw1.Port(2)); err != nil {
+		t.Fatalf("failed to process the port down event: %v", err)
+	}
+	flows := sw1.FlowMods()
+	if len(flows) != 1 || flows[0].Command() != openflow.FlowDelete {
+		t.Fatalf("the flows toward the down port are not removed: %v", flows)
+	}
+	if out := flows[0].OutPort(); out.Value() != 2 {
+		t.Fatalf("unexpected output port of the removal: %v", out.Value())
+	}
+	if n := len(sw2.FlowMods()); n != 0 {
+		t.Fatalf("unexpected flows on %v: %v", sw2.ID(), n)
+	}
+	sw1.Reset()
+
+	// The port where the next hop is located goes down: the next hop is forgotten and its routes are removed.
+	if err := app.OnPortDown(fake, sw2.Port(3)); err != nil {
+		t.Fatalf("failed to process the port down event: %v", err)
+	}
+	if flows := sw1.FlowMods(); len(flows) != 2 || !isRouteRemoval(flows[0]) {
+		t.Fatalf("the routes toward the next hop are not removed on %v: %v", sw1.ID(), flows)
+	}
+	if _, ok := app.neighbors.lookup(net.ParseIP("10.0.2.254"), app.clock.Now()); ok {
+		t.Fatal("the next hop at the down port is not forgotten")
+	}
+}
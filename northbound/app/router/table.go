@@ -0,0 +1,173 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package router
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// routingTable finds the next hop toward a destination IPv4 address.
+type routingTable struct {
+	// Gateway IP addresses of the router in the directly connected subnets.
+	gateways []net.IP
+	// Routes sorted in descending order of their prefix lengths for the longest prefix match.
+	routes []route
+}
+
+type route struct {
+	prefix *net.IPNet
+	// Nil means a directly connected subnet, where the next hop is the destination itself.
+	nextHop net.IP
+}
+
+// newRoutingTable returns a routing table of the router interfaces, e.g., "10.0.1.1/24" that is the gateway
+// address and the prefix length of a connected subnet, and the static routes toward the remote subnets, e.g.,
+// "192.168.0.0/16 via 10.0.1.254" whose next hop should be in one of the connected subnets.
+func newRoutingTable(interfaces, routes []string) (*routingTable, error) {
+	if len(interfaces) == 0 {
+		return nil, fmt.Errorf("no router interface")
+	}
+
+	v := new(routingTable)
+	for _, s := range interfaces {
+		ip, subnet, err := net.ParseCIDR(strings.TrimSpace(s))
+		if err != nil || ip.To4() == nil {
+			return nil, fmt.Errorf("invalid router interface: %v", s)
+		}
+		v.gateways = append(v.gateways, ip.To4())
+		v.routes = append(v.routes, route{prefix: subnet})
+	}
+	for _, s := range routes {
+		r, err := parseRoute(s)
+		if err != nil {
+			return nil, err
+		}
+		if !v.isConnected(r.nextHop) {
+			return nil, fmt.Errorf("next hop is not in the connected subnets: %v", s)
+		}
+		v.routes = append(v.routes, r)
+	}
+	sort.SliceStable(v.routes, func(i, j int) bool {
+		a, _ := v.routes[i].prefix.Mask.Size()
+		b, _ := v.routes[j].prefix.Mask.Size()
+		return a > b
+	})
+
+	return v, nil
+}
+
+func parseRoute(s string) (route, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 || fields[1] != "via" {
+		return route{}, fmt.Errorf("invalid route: %v", s)
+	}
+	_, prefix, err := net.ParseCIDR(fields[0])
+	if err != nil || prefix.IP.To4() == nil {
+		return route{}, fmt.Errorf("invalid route prefix: %v", s)
+	}
+	nextHop := net.ParseIP(fields[2]).To4()
+	if nextHop == nil {
+		return route{}, fmt.Errorf("invalid next hop: %v", s)
+	}
+
+	return route{prefix: prefix, nextHop: nextHop}, nil
+}
+
+func (r *routingTable) isConnected(ip net.IP) bool {
+	for _, v := range r.routes {
+		if v.nextHop == nil && v.prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// gatewayOf returns the gateway address of the connected subnet that ip belongs to.
+func (r *routingTable) gatewayOf(ip net.IP) (gateway net.IP, ok bool) {
+	for _, v := range r.gateways {
+		for _, route := range r.routes {
+			if route.nextHop == nil && route.prefix.Contains(v) && route.prefix.Contains(ip) {
+				return v, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// isGateway returns whether ip is one of the gateway addresses of the router.
+func (r *routingTable) isGateway(ip net.IP) bool {
+	for _, v := range r.gateways {
+		if v.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// prefixesVia returns the destination prefixes of the flows whose next hop is nextHop, which are the prefixes
+// of the static routes via nextHop and the host address of nextHop itself.
+func (r *routingTable) prefixesVia(nextHop net.IP) []*net.IPNet {
+	nextHop = nextHop.To4()
+	if nextHop == nil {
+		return nil
+	}
+
+	result := make([]*net.IPNet, 0)
+	for _, v := range r.routes {
+		if v.nextHop != nil && v.nextHop.Equal(nextHop) {
+			result = append(result, v.prefix)
+		}
+	}
+	if r.isConnected(nextHop) {
+		result = append(result, &net.IPNet{IP: nextHop, Mask: net.CIDRMask(32, 32)})
+	}
+
+	return result
+}
+
+// lookup returns the next hop toward dst and the destination prefix of the flow that forwards the packets to
+// the next hop, which is the host address itself for a directly connected subnet because each host has its
+// own MAC address.
+func (r *routingTable) lookup(dst net.IP) (nextHop net.IP, prefix *net.IPNet, ok bool) {
+	dst = dst.To4()
+	if dst == nil {
+		return nil, nil, false
+	}
+
+	for _, v := range r.routes {
+		if !v.prefix.Contains(dst) {
+			continue
+		}
+		if v.nextHop == nil {
+			return dst, &net.IPNet{IP: dst, Mask: net.CIDRMask(32, 32)}, true
+		}
+		return v.nextHop, v.prefix, true
+	}
+
+	return nil, nil, false
+}
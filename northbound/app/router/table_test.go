@@ -0,0 +1,93 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package router
+
+import (
+	"net"
+	"testing"
+)
+
+func TestRoutingTableLookup(t *testing.T) {
+	table, err := newRoutingTable(
+		[]string{"10.0.1.1/24", "10.0.2.1/24"},
+		[]string{"192.168.0.0/16 via 10.0.1.254", "192.168.10.0/24 via 10.0.2.254", "0.0.0.0/0 via 10.0.1.253"},
+	)
+	if err != nil {
+		t.Fatalf("failed to create the routing table: %v", err)
+	}
+
+	src := []struct {
+		Dst     string
+		NextHop string
+		Prefix  string
+	}{
+		// Directly connected subnets.
+		{Dst: "10.0.1.10", NextHop: "10.0.1.10", Prefix: "10.0.1.10/32"},
+		{Dst: "10.0.2.20", NextHop: "10.0.2.20", Prefix: "10.0.2.20/32"},
+		// Longest prefix match.
+		{Dst: "192.168.1.1", NextHop: "10.0.1.254", Prefix: "192.168.0.0/16"},
+		{Dst: "192.168.10.1", NextHop: "10.0.2.254", Prefix: "192.168.10.0/24"},
+		// Default route.
+		{Dst: "8.8.8.8", NextHop: "10.0.1.253", Prefix: "0.0.0.0/0"},
+	}
+
+	for i, v := range src {
+		nextHop, prefix, ok := table.lookup(net.ParseIP(v.Dst))
+		if !ok {
+			t.Fatalf("#%v: no route toward %v", i, v.Dst)
+		}
+		if nextHop.String() != v.NextHop || prefix.String() != v.Prefix {
+			t.Fatalf("#%v: unexpected route toward %v: expected=%v via %v, got=%v via %v", i, v.Dst, v.Prefix, v.NextHop, prefix, nextHop)
+		}
+	}
+}
+
+func TestNewRoutingTable(t *testing.T) {
+	src := []struct {
+		Interfaces    []string
+		Routes        []string
+		ErrorExpected bool
+	}{
+		{Interfaces: []string{"10.0.1.1/24"}, Routes: []string{"192.168.0.0/16 via 10.0.1.254"}},
+		{Interfaces: nil, ErrorExpected: true},
+		{Interfaces: []string{"10.0.1.1"}, ErrorExpected: true},
+		{Interfaces: []string{"2001:db8::1/64"}, ErrorExpected: true},
+		{Interfaces: []string{"10.0.1.1/24"}, Routes: []string{"192.168.0.0/16 10.0.1.254"}, ErrorExpected: true},
+		{Interfaces: []string{"10.0.1.1/24"}, Routes: []string{"192.168.0.0 via 10.0.1.254"}, ErrorExpected: true},
+		{Interfaces: []string{"10.0.1.1/24"}, Routes: []string{"192.168.0.0/16 via invalid"}, ErrorExpected: true},
+		// The next hop is not in the connected subnets.
+		{Interfaces: []string{"10.0.1.1/24"}, Routes: []string{"192.168.0.0/16 via 10.0.2.254"}, ErrorExpected: true},
+	}
+
+	for i, v := range src {
+		_, err := newRoutingTable(v.Interfaces, v.Routes)
+		if err != nil {
+			if v.ErrorExpected {
+				continue
+			}
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if v.ErrorExpected {
+			t.Fatalf("#%v: expected error, but got nil", i)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package span
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/protocol"
+)
+
+// criteria selects the packets to be mirrored. Zero value of a field matches any packet.
+type criteria struct {
+	srcMAC     net.HardwareAddr
+	dstMAC     net.HardwareAddr
+	etherType  uint16
+	srcIP      *net.IPNet
+	dstIP      *net.IPNet
+	ipProtocol uint8
+}
+
+// newCriteria parses the match criteria whose keys are src_mac, dst_mac, ether_type, src_ip, dst_ip and
+// ip_protocol. The IP fields imply the IPv4 Ethernet type.
+func newCriteria(fields map[string]string) (*criteria, error) {
+	c := new(criteria)
+	for k, v := range fields {
+		var err error
+		switch strings.ToLower(k) {
+		case "src_mac":
+			c.srcMAC, err = parseMAC(v)
+		case "dst_mac":
+			c.dstMAC, err = parseMAC(v)
+		case "ether_type":
+			var t uint64
+			t, err = strconv.ParseUint(v, 0, 16)
+			c.etherType = uint16(t)
+		case "src_ip":
+			c.srcIP, err = parseIPv4Net(v)
+		case "dst_ip":
+			c.dstIP, err = parseIPv4Net(v)
+		case "ip_protocol":
+			var p uint64
+			p, err = strconv.ParseUint(v, 0, 8)
+			c.ipProtocol = uint8(p)
+		default:
+			err = errors.New("unknown field")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", k, err)
+		}
+	}
+
+	if c.srcIP != nil || c.dstIP != nil || c.ipProtocol != 0 {
+		if c.etherType != 0 && c.etherType != 0x0800 {
+			return nil, fmt.Errorf("IP fields with a non-IPv4 ether_type: 0x%04X", c.etherType)
+		}
+		c.etherType = 0x0800
+	}
+
+	return c, nil
+}
+
+func parseMAC(s string) (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid MAC address: %v", s)
+	}
+
+	return mac, nil
+}
+
+// parseIPv4Net parses an IPv4 address with or without its prefix length.
+func parseIPv4Net(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		s += "/32"
+	}
+	ip, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("not an IPv4 address: %v", s)
+	}
+
+	return ipnet, nil
+}
+
+// newMatch returns a new flow match that has the criteria.
+func (r *criteria) newMatch(f openflow.Factory) (openflow.Match, error) {
+	match, err := f.NewMatch()
+	if err != nil {
+		return nil, err
+	}
+	if r.srcMAC != nil {
+		match.SetSrcMAC(r.srcMAC)
+	}
+	if r.dstMAC != nil {
+		match.SetDstMAC(r.dstMAC)
+	}
+	if r.etherType != 0 {
+		match.SetEtherType(r.etherType)
+	}
+	if r.srcIP != nil {
+		match.SetSrcIP(r.srcIP)
+	}
+	if r.dstIP != nil {
+		match.SetDstIP(r.dstIP)
+	}
+	if r.ipProtocol != 0 {
+		match.SetIPProtocol(r.ipProtocol)
+	}
+
+	return match, nil
+}
+
+// matches returns whether eth meets the criteria.
+func (r *criteria) matches(eth *protocol.Ethernet) bool {
+	if r.srcMAC != nil && !bytes.Equal(r.srcMAC, eth.SrcMAC) {
+		return false
+	}
+	if r.dstMAC != nil && !bytes.Equal(r.dstMAC, eth.DstMAC) {
+		return false
+	}
+	if r.etherType != 0 && r.etherType != eth.Type {
+		return false
+	}
+	if r.srcIP == nil && r.dstIP == nil && r.ipProtocol == 0 {
+		return true
+	}
+
+	ip := new(protocol.IPv4)
+	if err := ip.UnmarshalBinary(eth.Payload); err != nil {
+		return false
+	}
+	if r.srcIP != nil && !r.srcIP.Contains(ip.SrcIP) {
+		return false
+	}
+	if r.dstIP != nil && !r.dstIP.Contains(ip.DstIP) {
+		return false
+	}
+	if r.ipProtocol != 0 && r.ipProtocol != ip.Protocol {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,328 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+// Package span mirrors the selected traffic on a device to a monitor port, so-called SPAN port, for troubleshooting.
+//
+// SPAN installs a punt flow on span.device that sends the packets matching span.match to the controller. Its
+// priority is one less than span.priority, which overrides the L2Switch and ECMP flows. When SPAN receives a
+// matching packet toward a discovered host, it finds the egress port toward the host as L2Switch does, and
+// installs a flow that matches the mirror criteria, the VLAN and the destination MAC address with two output
+// actions: the egress port and the SPAN port. The flow has span.priority and the cookie of SPAN, so the switch
+// forwards and mirrors the following packets without the controller, and the flows of L2Switch are left
+// untouched. The packets that SPAN does not forward, e.g., broadcasts or the ones toward unknown hosts, are
+// passed to the next application, so they are forwarded as usual but not mirrored.
+//
+// Mirroring is suspended while the SPAN port is down: SPAN removes its flows and the punt flow, and installs
+// the punt flow again when the port comes up. SPAN should precede ECMP and L2Switch in default.applications.
+package span
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/northbound/app"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/protocol"
+
+	"github.com/pkg/errors"
+	"github.com/superkkt/go-logging"
+	"github.com/superkkt/viper"
+)
+
+var (
+	logger = logging.MustGetLogger("span")
+)
+
+const (
+	// Higher than the default priorities of the L2Switch and ECMP flows, and so is the punt flow whose
+	// priority is one less than this.
+	defaultPriority = 18
+)
+
+type SPAN struct {
+	app.BaseProcessor
+	mutex    sync.Mutex
+	cookie   network.AppCookie
+	deviceID string
+	port     uint32
+	criteria *criteria
+	flowOpts network.FlowOptions
+	// Whether the punt flow is installed on the device, which means the packets are being mirrored.
+	active bool
+}
+
+func New() *SPAN {
+	return &SPAN{}
+}
+
+func (r *SPAN) Name() string {
+	return "SPAN"
+}
+
+func (r *SPAN) String() string {
+	return fmt.Sprintf("%v", r.Name())
+}
+
+func (r *SPAN) Init() error {
+	r.deviceID = viper.GetString("span.device")
+	if r.deviceID == "" {
+		return errors.New("empty span.device in the config file")
+	}
+	port := viper.GetInt("span.port")
+	// The reserved ports, e.g., FLOOD, cannot be a SPAN port.
+	if port <= 0 || port >= 0xFFFFFF00 {
+		return errors.New("invalid span.port in the config file")
+	}
+	r.port = uint32(port)
+
+	c, err := newCriteria(viper.GetStringMapString("span.match"))
+	if err != nil {
+		return errors.Wrap(err, "invalid span.match in the config file")
+	}
+	r.criteria = c
+
+	cookie, err := network.RegisterAppCookie(r.Name())
+	if err != nil {
+		return err
+	}
+	r.cookie = cookie
+
+	r.flowOpts = network.DefaultFlowOptions
+	r.flowOpts.Priority = defaultPriority
+	if viper.IsSet("span.priority") {
+		v := viper.GetInt("span.priority")
+		if v <= 1 || v > 0xFFFF {
+			return errors.New("invalid span.priority in the config file")
+		}
+		r.flowOpts.Priority = uint16(v)
+	}
+	r.flowOpts.MirrorPort = r.port
+	if err := network.SetPriorityBand(cookie, network.PriorityBandForwarding); err != nil {
+		return err
+	}
+	if err := cookie.ValidatePriority(r.flowOpts.Priority); err != nil {
+		return fmt.Errorf("invalid span.priority in the config file: %v", err)
+	}
+	logger.Infof("SPAN port: device=%v, port=%v, flow priority: %v", r.deviceID, r.port, r.flowOpts.Priority)
+
+	return nil
+}
+
+// isSPANPort returns whether port is the SPAN port.
+func (r *SPAN) isSPANPort(port *network.Port) bool {
+	return port.Number() == r.port && port.Device().ID() == r.deviceID
+}
+
+// isReservedPort returns whether the SPAN port is a reserved port on device. The port numbers of OpenFlow 1.0 are
+// 16 bits, and the ones from 0xFF00 are reserved while they are physical ports on OpenFlow 1.3.
+func (r *SPAN) isReservedPort(device *network.Device) bool {
+	return device.Factory().ProtocolVersion() == openflow.OF10_VERSION && r.port >= 0xFF00
+}
+
+func (r *SPAN) OnDeviceUp(finder network.Finder, device *network.Device) error {
+	if device.ID() == r.deviceID {
+		if r.isReservedPort(device) {
+			logger.Errorf("SPAN port %v is a reserved port on the OpenFlow 1.0 device %v", r.port, device.ID())
+		} else if port := device.Port(r.port); port == nil {
+			// The ports are usually reported after the device up event. Mirroring starts on its port up event.
+			logger.Infof("SPAN port %v is not known yet on %v: mirroring starts when the port is up", r.port, device.ID())
+		} else if v := port.Value(); v != nil && !v.IsPortDown() && !v.IsLinkDown() {
+			r.start(device)
+		}
+	}
+
+	return r.BaseProcessor.OnDeviceUp(finder, device)
+}
+
+func (r *SPAN) OnDeviceDown(finder network.Finder, device *network.Device) error {
+	if device.ID() == r.deviceID {
+		r.mutex.Lock()
+		r.active = false
+		r.mutex.Unlock()
+	}
+
+	return r.BaseProcessor.OnDeviceDown(finder, device)
+}
+
+func (r *SPAN) OnPortUp(finder network.Finder, port *network.Port) error {
+	if r.isSPANPort(port) {
+		r.start(port.Device())
+	}
+
+	return r.BaseProcessor.OnPortUp(finder, port)
+}
+
+// OnPortDown suspends mirroring while the SPAN port is down.
+func (r *SPAN) OnPortDown(finder network.Finder, port *network.Port) error {
+	if r.isSPANPort(port) {
+		r.suspend(port.Device())
+	}
+
+	return r.BaseProcessor.OnPortDown(finder, port)
+}
+
+// OnTopologyChange removes our flows because their egress ports may have been changed. The punt flow is kept
+// so that the flows are installed again with the new egress ports.
+func (r *SPAN) OnTopologyChange(finder network.Finder) error {
+	if device := finder.Device(r.deviceID); device != nil && !device.IsClosed() {
+		if err := device.RemoveAppFlows(r.cookie); err != nil {
+			logger.Errorf("failed to remove the flows on %v: %v", device.ID(), err)
+		}
+	}
+
+	return r.BaseProcessor.OnTopologyChange(finder)
+}
+
+// start installs the punt flow that sends the packets to be mirrored to the controller.
+func (r *SPAN) start(device *network.Device) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	match, err := r.criteria.newMatch(device.Factory())
+	if err != nil {
+		logger.Errorf("failed to make the punt flow match: %v", err)
+		return
+	}
+	if err := device.SetPuntFlow(match, r.flowOpts.Priority-1, network.PuntCookie(r.Name())); err != nil {
+		logger.Errorf("failed to install the punt flow on %v: %v", device.ID(), err)
+		return
+	}
+	r.active = true
+	logger.Infof("started mirroring to the SPAN port %v on %v", r.port, device.ID())
+}
+
+// suspend removes the punt flow and the mirror flows, so that the packets are forwarded by the other applications.
+func (r *SPAN) suspend(device *network.Device) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.active {
+		return
+	}
+	r.active = false
+
+	match, err := r.criteria.newMatch(device.Factory())
+	if err != nil {
+		logger.Errorf("failed to make the punt flow match: %v", err)
+		return
+	}
+	if err := device.RemovePuntFlow(match, network.PuntCookie(r.Name())); err != nil {
+		logger.Errorf("failed to remove the punt flow on %v: %v", device.ID(), err)
+	}
+	if err := device.RemoveAppFlows(r.cookie); err != nil {
+		logger.Errorf("failed to remove the flows on %v: %v", device.ID(), err)
+	}
+	logger.Warningf("suspended mirroring because the SPAN port %v on %v is down", r.port, device.ID())
+}
+
+func (r *SPAN) isActive() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.active
+}
+
+func (r *SPAN) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
+	done, err := r.processPacket(finder, ingress, eth)
+	if done || err != nil {
+		return err
+	}
+
+	return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
+}
+
+func (r *SPAN) processPacket(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) (done bool, err error) {
+	// Broadcast or multicast?
+	if eth.DstMAC[0]&0x01 != 0 {
+		return false, nil
+	}
+	device := ingress.Device()
+	if device.ID() != r.deviceID || ingress.Number() == r.port || !r.criteria.matches(eth) || !r.isActive() {
+		return false, nil
+	}
+	egress, err := egressPort(finder, ingress, eth.DstMAC)
+	if egress == nil || err != nil {
+		return false, err
+	}
+	if egress.Number() == r.port {
+		return false, nil
+	}
+
+	match, err := r.criteria.newMatch(device.Factory())
+	if err != nil {
+		return false, err
+	}
+	if tag, ok := eth.VLAN(); ok {
+		match.SetVLANID(tag.ID)
+	}
+	match.SetDstMAC(eth.DstMAC)
+	outPort := openflow.NewOutPort()
+	outPort.SetValue(egress.Number())
+
+	packet, err := eth.MarshalBinary()
+	if err != nil {
+		return true, err
+	}
+	out, err := app.NewPacketOut(egress, packet)
+	if err != nil {
+		return true, err
+	}
+	mirror := openflow.NewOutPort()
+	mirror.SetValue(r.port)
+	out.Action().AddOutPort(mirror)
+
+	entry := network.FlowEntry{Match: match, Port: outPort, Options: r.flowOpts}
+	if err := device.SetFlows(r.cookie, []network.FlowEntry{entry}, []encoding.BinaryMarshaler{out}...); err != nil {
+		return true, errors.Wrap(err, "installing a mirror flow")
+	}
+	logger.Debugf("installed a mirror flow: device=%v, dstMAC=%v, egress=%v, SPAN=%v", device.ID(), eth.DstMAC, egress.Number(), r.port)
+
+	return true, nil
+}
+
+// egressPort returns the port on the ingress device toward the host whose MAC address is mac, or nil if the host
+// is not discovered, disconnected, or located at the ingress port.
+func egressPort(finder network.Finder, ingress *network.Port, mac net.HardwareAddr) (*network.Port, error) {
+	node, status, err := finder.Node(mac)
+	if err != nil || status != network.LocationDiscovered || node == nil {
+		return nil, err
+	}
+	if v := node.Port().Value(); v == nil || v.IsPortDown() || v.IsLinkDown() {
+		return nil, nil
+	}
+
+	egress := node.Port()
+	if device := ingress.Device(); device.ID() != egress.Device().ID() {
+		path, _ := finder.Path(device.ID(), egress.Device().ID())
+		if len(path) == 0 {
+			return nil, nil
+		}
+		egress = path[0][0]
+	}
+	if egress.Number() == ingress.Number() {
+		return nil, nil
+	}
+
+	return egress, nil
+}
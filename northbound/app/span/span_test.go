@@ -0,0 +1,215 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package span
+
+import (
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
+	"github.com/superkkt/cherry/protocol"
+	"github.com/superkkt/viper"
+)
+
+func newTestPacket(t *testing.T, src, dst net.HardwareAddr, srcIP string) *protocol.Ethernet {
+	payload, err := protocol.NewIPv4(net.ParseIP(srcIP), net.ParseIP("10.0.2.1"), 17, make([]byte, 26)).MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal the IPv4 packet: %v", err)
+	}
+
+	return &protocol.Ethernet{SrcMAC: src, DstMAC: dst, Type: 0x0800, Payload: payload}
+}
+
+func outPorts(action openflow.Action) []uint32 {
+	result := make([]uint32, 0)
+	for _, v := range action.OutPorts() {
+		result = append(result, v.Value())
+	}
+
+	return result
+}
+
+func TestMirror(t *testing.T) {
+	viper.Reset()
+	viper.Set("span.device", "1")
+	viper.Set("span.port", 3)
+	viper.Set("span.match", map[string]interface{}{"src_ip": "10.0.1.0/24"})
+	app := New()
+	if err := app.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	defer viper.Reset()
+
+	// host1 - (1)sw1(2) - host2, and the SPAN port 3.
+	fake := network.NewFakeNetwork()
+	sw1 := fake.AddSwitch("1", of13.NewFactory(), 1, 2, 3)
+	host1 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	host2 := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	fake.SetLocation(host1, sw1.Port(1))
+	fake.SetLocation(host2, sw1.Port(2))
+
+	// The packets matching the criteria are punted to the controller.
+	if err := app.OnDeviceUp(fake, sw1.Device); err != nil {
+		t.Fatalf("failed to process the device up event: %v", err)
+	}
+	flows := sw1.FlowMods()
+	if len(flows) != 1 || flows[0].Cookie() != network.PuntCookie(app.Name()) || flows[0].Priority() != defaultPriority-1 {
+		t.Fatalf("unexpected punt flow: %v", flows)
+	}
+	sw1.Reset()
+
+	// Packet that does not meet the criteria.
+	if err := app.OnPacketIn(fake, sw1.Port(1), newTestPacket(t, host1, host2, "10.0.9.1"), network.PacketInInfo{}); err != nil {
+		t.Fatalf("failed to process the packet: %v", err)
+	}
+	if len(sw1.Messages()) != 0 {
+		t.Fatalf("unexpected messages for the packet that is not mirrored: %v", sw1.Messages())
+	}
+
+	if err := app.OnPacketIn(fake, sw1.Port(1), newTestPacket(t, host1, host2, "10.0.1.1"), network.PacketInInfo{}); err != nil {
+		t.Fatalf("failed to process the packet: %v", err)
+	}
+	flows = sw1.FlowMods()
+	if len(flows) != 1 {
+		t.Fatalf("unexpected number of flows: %v", len(flows))
+	}
+	f := flows[0]
+	if f.Priority() != defaultPriority {
+		t.Fatalf("unexpected flow priority: %v", f.Priority())
+	}
+	if !app.cookie.Owns(f.Cookie()) {
+		t.Fatalf("unexpected flow cookie: 0x%X", f.Cookie())
+	}
+	if src := f.FlowMatch().SrcIP(); src == nil || src.String() != "10.0.1.0/24" {
+		t.Fatalf("unexpected source prefix: %v", src)
+	}
+	if ports := outPorts(f.FlowInstruction().Action()); len(ports) != 2 || ports[0] != 2 || ports[1] != 3 {
+		t.Fatalf("unexpected output ports of the flow: %v", ports)
+	}
+	outs := sw1.PacketOuts()
+	if len(outs) != 1 {
+		t.Fatalf("unexpected number of PACKET_OUTs: %v", len(outs))
+	}
+	if ports := outPorts(outs[0].Action()); len(ports) != 2 || ports[0] != 2 || ports[1] != 3 {
+		t.Fatalf("unexpected output ports of the PACKET_OUT: %v", ports)
+	}
+	sw1.Reset()
+
+	// Mirroring is suspended while the SPAN port is down.
+	if err := app.OnPortDown(fake, sw1.Port(3)); err != nil {
+		t.Fatalf("failed to process the port down event: %v", err)
+	}
+	flows = sw1.FlowMods()
+	if len(flows) != 2 || flows[0].Command() != openflow.FlowDelete || flows[1].Command() != openflow.FlowDelete {
+		t.Fatalf("the flows are not removed: %v", flows)
+	}
+	sw1.Reset()
+	if err := app.OnPacketIn(fake, sw1.Port(1), newTestPacket(t, host1, host2, "10.0.1.1"), network.PacketInInfo{}); err != nil {
+		t.Fatalf("failed to process the packet: %v", err)
+	}
+	if len(sw1.Messages()) != 0 {
+		t.Fatalf("unexpected messages while mirroring is suspended: %v", sw1.Messages())
+	}
+
+	// Resumed when the SPAN port comes up.
+	if err := app.OnPortUp(fake, sw1.Port(3)); err != nil {
+		t.Fatalf("failed to process the port up event: %v", err)
+	}
+	if flows := sw1.FlowMods(); len(flows) != 1 || flows[0].Cookie() != network.PuntCookie(app.Name()) {
+		t.Fatalf("the punt flow is not installed again: %v", flows)
+	}
+}
+
+func TestReservedPort(t *testing.T) {
+	viper.Reset()
+	viper.Set("span.device", "1")
+	viper.Set("span.port", 0xFF01)
+	viper.Set("span.match", map[string]interface{}{"src_ip": "10.0.1.0/24"})
+	app := New()
+	if err := app.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	defer viper.Reset()
+
+	src := []struct {
+		factory openflow.Factory
+		active  bool
+	}{
+		// 0xFF01 is a physical port on OpenFlow 1.3.
+		{of13.NewFactory(), true},
+		// 0xFF01 is a reserved port on OpenFlow 1.0.
+		{of10.NewFactory(), false},
+	}
+
+	for i, v := range src {
+		fake := network.NewFakeNetwork()
+		sw := fake.AddSwitch("1", v.factory, 1, 2, 0xFF01)
+		app.active = false
+		if err := app.OnDeviceUp(fake, sw.Device); err != nil {
+			t.Fatalf("#%v: failed to process the device up event: %v", i, err)
+		}
+		if app.isActive() != v.active {
+			t.Fatalf("#%v: expected active=%v, got=%v", i, v.active, app.isActive())
+		}
+		if n := len(sw.FlowMods()); v.active != (n > 0) {
+			t.Fatalf("#%v: unexpected number of the flows: %v", i, n)
+		}
+	}
+}
+
+func TestPortsAfterDeviceUp(t *testing.T) {
+	viper.Reset()
+	viper.Set("span.device", "1")
+	viper.Set("span.port", 3)
+	viper.Set("span.match", map[string]interface{}{"src_ip": "10.0.1.0/24"})
+	app := New()
+	if err := app.Init(); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	defer viper.Reset()
+
+	// The ports are not known yet on the device up event.
+	fake := network.NewFakeNetwork()
+	sw := fake.AddSwitch("1", of13.NewFactory())
+	if err := app.OnDeviceUp(fake, sw.Device); err != nil {
+		t.Fatalf("failed to process the device up event: %v", err)
+	}
+	if app.isActive() || len(sw.FlowMods()) != 0 {
+		t.Fatalf("mirroring is started without the SPAN port")
+	}
+
+	// Mirroring starts when the SPAN port is reported.
+	for _, num := range []uint32{1, 2, 3} {
+		if err := app.OnPortUp(fake, sw.AddPort(num)); err != nil {
+			t.Fatalf("failed to process the port up event: %v", err)
+		}
+	}
+	if !app.isActive() {
+		t.Fatalf("mirroring is not started after the SPAN port is up")
+	}
+	if flows := sw.FlowMods(); len(flows) != 1 || flows[0].Cookie() != network.PuntCookie(app.Name()) {
+		t.Fatalf("unexpected punt flow: %v", flows)
+	}
+}
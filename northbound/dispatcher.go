@@ -0,0 +1,105 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package northbound
+
+import (
+	"strings"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/northbound/app"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/protocol"
+)
+
+// dispatcher passes all the events to the head of the application chain, except the punted PACKET_INs
+// that are delivered directly to their owner applications. The taps receive a copy of every PACKET_IN
+// before the chain does, and the owner of a removed flow is notified before the chain is.
+type dispatcher struct {
+	app.Processor
+	// Key is the punt cookie of an application.
+	owners map[uint64]app.Processor
+	taps   []app.Tap
+	// Key is the upper-cased name of an application.
+	flowOwners map[string]app.FlowOwner
+}
+
+func newDispatcher(head app.Processor) *dispatcher {
+	v := &dispatcher{
+		Processor:  head,
+		owners:     make(map[uint64]app.Processor),
+		flowOwners: make(map[string]app.FlowOwner),
+	}
+
+	var p app.Processor = head
+	for p != nil {
+		v.owners[network.PuntCookie(p.Name())] = p
+		if tap, ok := p.(app.Tap); ok {
+			v.taps = append(v.taps, tap)
+		}
+		if owner, ok := p.(app.FlowOwner); ok {
+			v.flowOwners[strings.ToUpper(p.Name())] = owner
+		}
+		next, ok := p.Next()
+		if !ok {
+			break
+		}
+		p = next
+	}
+
+	return v
+}
+
+func (r *dispatcher) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
+	r.tap(finder, ingress, eth)
+	return r.Processor.OnPacketIn(finder, ingress, eth, info)
+}
+
+func (r *dispatcher) OnPuntedPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo, cookie uint64) error {
+	r.tap(finder, ingress, eth)
+
+	owner, ok := r.owners[cookie]
+	if !ok {
+		logger.Debugf("unknown punt cookie: 0x%X", cookie)
+		return r.Processor.OnPacketIn(finder, ingress, eth, info)
+	}
+
+	return owner.OnPacketIn(finder, ingress, eth, info)
+}
+
+func (r *dispatcher) OnFlowRemoved(finder network.Finder, device *network.Device, flow openflow.FlowRemoved) error {
+	if name, ok := network.AppOf(flow.Cookie()); ok {
+		if owner, ok := r.flowOwners[strings.ToUpper(name)]; ok {
+			if err := owner.OnAppFlowRemoved(finder, device, flow); err != nil {
+				logger.Errorf("failed to handle the removed flow of %v: %v", name, err)
+			}
+		}
+	}
+
+	return r.Processor.OnFlowRemoved(finder, device, flow)
+}
+
+func (r *dispatcher) tap(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) {
+	for _, v := range r.taps {
+		// Each tap has its own copy not to affect the others.
+		v.OnPacketTap(finder, ingress, eth.Clone())
+	}
+}
@@ -24,18 +24,24 @@ package northbound
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
 	"github.com/superkkt/cherry/database"
 	"github.com/superkkt/cherry/network"
 	"github.com/superkkt/cherry/northbound/app"
+	"github.com/superkkt/cherry/northbound/app/acl"
 	"github.com/superkkt/cherry/northbound/app/announcer"
 	"github.com/superkkt/cherry/northbound/app/dhcp"
 	"github.com/superkkt/cherry/northbound/app/discovery"
+	"github.com/superkkt/cherry/northbound/app/ecmp"
 	"github.com/superkkt/cherry/northbound/app/l2switch"
 	"github.com/superkkt/cherry/northbound/app/monitor"
+	"github.com/superkkt/cherry/northbound/app/multicast"
 	"github.com/superkkt/cherry/northbound/app/proxyarp"
+	"github.com/superkkt/cherry/northbound/app/router"
+	"github.com/superkkt/cherry/northbound/app/span"
 	"github.com/superkkt/cherry/northbound/app/virtualip"
 
 	"github.com/pkg/errors"
@@ -53,11 +59,17 @@ type EventSender interface {
 type application struct {
 	instance app.Processor
 	enabled  bool
+	// Applications that have higher priority receive the events earlier.
+	priority int
 }
 
+// DefaultPriority is the priority of the applications enabled by Enable.
+const DefaultPriority = 0
+
 type Manager struct {
 	mutex      sync.Mutex
 	apps       map[string]*application // Registered applications
+	chain      []*application          // Enabled applications in the order they receive the events
 	head, tail app.Processor
 	db         *database.MySQL
 }
@@ -75,11 +87,22 @@ func NewManager(db *database.MySQL) (*Manager, error) {
 	v.register(virtualip.New(db))
 	v.register(announcer.New(db))
 	v.register(dhcp.New(db))
+	v.register(multicast.New())
+	v.register(ecmp.New())
+	v.register(router.New())
+	v.register(span.New())
+	v.register(acl.New())
 
 	return v, nil
 }
 
 func (r *Manager) register(app app.Processor) {
+	// Reserve the cookie range of the application. The range is derived from the FNV hash of the
+	// name, so the applications have the same ranges whenever the controller restarts. The order of
+	// the registrations only matters when the hashes of two names collide.
+	if _, err := network.RegisterAppCookie(app.Name()); err != nil {
+		logger.Errorf("failed to reserve the cookie range of %v application: %v", app.Name(), err)
+	}
 	r.apps[strings.ToUpper(app.Name())] = &application{
 		instance: app,
 		enabled:  false,
@@ -104,7 +127,16 @@ func (r *Manager) checkDependencies(appNames []string) error {
 	return nil
 }
 
+// Enable enables the application whose name is appName with the default priority. The applications
+// that have the same priority receive the events in order they are enabled.
 func (r *Manager) Enable(appName string) error {
+	return r.EnableWithPriority(appName, DefaultPriority)
+}
+
+// EnableWithPriority enables the application whose name is appName. The applications receive the events
+// in descending order of their priorities, and an application can stop the propagation of an event to the
+// following applications by not passing it to the next processor.
+func (r *Manager) EnableWithPriority(appName string, priority int) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -126,19 +158,32 @@ func (r *Manager) Enable(appName string) error {
 		return errors.Wrap(err, "checking dependencies")
 	}
 	v.enabled = true
-	logger.Debugf("enabled %v application", appName)
+	v.priority = priority
+	logger.Debugf("enabled %v application (priority=%v)", appName, priority)
 
-	if r.head == nil {
-		r.head = app
-		r.tail = app
-	} else {
-		r.tail.SetNext(app)
-		r.tail = app
-	}
+	r.chain = append(r.chain, v)
+	r.relink()
 
 	return nil
 }
 
+// relink rebuilds the application chain in descending order of the priorities. The stable sort
+// preserves the enabled order of the applications that have the same priority.
+// XXX: Caller should lock the mutex before they call this function
+func (r *Manager) relink() {
+	sort.SliceStable(r.chain, func(i, j int) bool { return r.chain[i].priority > r.chain[j].priority })
+
+	for i, v := range r.chain {
+		if i+1 < len(r.chain) {
+			v.instance.SetNext(r.chain[i+1].instance)
+		} else {
+			v.instance.SetNext(nil)
+		}
+	}
+	r.head = r.chain[0].instance
+	r.tail = r.chain[len(r.chain)-1].instance
+}
+
 func (r *Manager) AddEventSender(sender EventSender) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -146,7 +191,7 @@ func (r *Manager) AddEventSender(sender EventSender) {
 	if r.head == nil {
 		return
 	}
-	sender.SetEventListener(r.head)
+	sender.SetEventListener(newDispatcher(r.head))
 }
 
 func (r *Manager) String() string {
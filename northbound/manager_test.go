@@ -0,0 +1,209 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package northbound
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/superkkt/cherry/network"
+	"github.com/superkkt/cherry/northbound/app"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/protocol"
+)
+
+type mockApp struct {
+	app.BaseProcessor
+	name string
+	// drop stops the propagation of PACKET_INs to the next application.
+	drop     bool
+	received *[]string
+}
+
+func (r *mockApp) Name() string {
+	return r.name
+}
+
+func (r *mockApp) String() string {
+	return r.name
+}
+
+func (r *mockApp) OnPacketIn(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet, info network.PacketInInfo) error {
+	*r.received = append(*r.received, r.name)
+	if r.drop {
+		return nil
+	}
+
+	return r.BaseProcessor.OnPacketIn(finder, ingress, eth, info)
+}
+
+func TestEnableWithPriority(t *testing.T) {
+	type app struct {
+		name     string
+		priority int
+		drop     bool
+	}
+	src := []struct {
+		apps     []app
+		expected []string
+	}{
+		// Same priority: in order they are enabled.
+		{[]app{{"A", 0, false}, {"B", 0, false}, {"C", 0, false}}, []string{"A", "B", "C"}},
+		// Higher priority first.
+		{[]app{{"A", 0, false}, {"B", 10, false}, {"C", 5, false}}, []string{"B", "C", "A"}},
+		{[]app{{"A", -1, false}, {"B", 0, false}, {"C", 0, false}}, []string{"B", "C", "A"}},
+		// The first application drops the packet.
+		{[]app{{"L2Switch", 0, false}, {"Firewall", 10, true}}, []string{"Firewall"}},
+		{[]app{{"A", 0, false}, {"B", 5, true}, {"C", 10, false}}, []string{"C", "B"}},
+	}
+
+	for i, v := range src {
+		received := []string{}
+		m := &Manager{apps: make(map[string]*application)}
+		for _, a := range v.apps {
+			m.register(&mockApp{name: a.name, drop: a.drop, received: &received})
+		}
+		for _, a := range v.apps {
+			if err := m.EnableWithPriority(a.name, a.priority); err != nil {
+				t.Fatalf("#%v: failed to enable %v: %v", i, a.name, err)
+			}
+		}
+
+		if err := newDispatcher(m.head).OnPacketIn(nil, nil, nil, network.PacketInInfo{}); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(received, v.expected) {
+			t.Fatalf("#%v: unexpected order: expected=%v, got=%v", i, v.expected, received)
+		}
+	}
+}
+
+type mockTap struct {
+	mockApp
+	tapped []*protocol.Ethernet
+}
+
+func (r *mockTap) OnPacketTap(finder network.Finder, ingress *network.Port, eth *protocol.Ethernet) {
+	r.tapped = append(r.tapped, eth)
+	// Modifying the copy should not affect the applications in the chain.
+	eth.Payload[0] = 0xFF
+	eth.DstMAC[0] = 0xFF
+}
+
+func TestTap(t *testing.T) {
+	src := []struct {
+		drop     bool
+		expected []string
+	}{
+		// L2Switch forwards the packet to the next application.
+		{drop: false, expected: []string{"L2Switch", "Tap"}},
+		// The tap receives the packet even if L2Switch consumes it.
+		{drop: true, expected: []string{"L2Switch"}},
+	}
+
+	for i, v := range src {
+		received := []string{}
+		m := &Manager{apps: make(map[string]*application)}
+		tap := &mockTap{mockApp: mockApp{name: "Tap", received: &received}}
+		m.register(tap)
+		m.register(&mockApp{name: "L2Switch", drop: v.drop, received: &received})
+		// The tap also runs in the chain after L2Switch.
+		if err := m.EnableWithPriority("Tap", -10); err != nil {
+			t.Fatalf("#%v: failed to enable Tap: %v", i, err)
+		}
+		if err := m.Enable("L2Switch"); err != nil {
+			t.Fatalf("#%v: failed to enable L2Switch: %v", i, err)
+		}
+
+		eth := &protocol.Ethernet{
+			SrcMAC:  []byte{0, 1, 2, 3, 4, 5},
+			DstMAC:  []byte{0, 1, 2, 3, 4, 6},
+			Type:    0x0800,
+			Payload: []byte{1, 2, 3},
+		}
+		if err := newDispatcher(m.head).OnPacketIn(nil, nil, eth, network.PacketInInfo{}); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(received, v.expected) {
+			t.Fatalf("#%v: unexpected order: expected=%v, got=%v", i, v.expected, received)
+		}
+		if len(tap.tapped) != 1 || tap.tapped[0] == eth {
+			t.Fatalf("#%v: tap did not receive a copy of the packet: %v", i, tap.tapped)
+		}
+		if eth.Payload[0] != 1 || eth.DstMAC[0] != 0 {
+			t.Fatalf("#%v: tap modified the original packet: %+v", i, eth)
+		}
+	}
+}
+
+type mockFlowOwner struct {
+	mockApp
+}
+
+func (r *mockFlowOwner) OnAppFlowRemoved(finder network.Finder, device *network.Device, flow openflow.FlowRemoved) error {
+	*r.received = append(*r.received, r.name)
+	return nil
+}
+
+type mockFlowRemoved struct {
+	openflow.FlowRemoved
+	cookie uint64
+}
+
+func (r *mockFlowRemoved) Cookie() uint64 {
+	return r.cookie
+}
+
+func TestFlowOwner(t *testing.T) {
+	received := []string{}
+	m := &Manager{apps: make(map[string]*application)}
+	m.register(&mockFlowOwner{mockApp{name: "FlowOwnerA", received: &received}})
+	m.register(&mockFlowOwner{mockApp{name: "FlowOwnerB", received: &received}})
+	for _, name := range []string{"FlowOwnerA", "FlowOwnerB"} {
+		if err := m.Enable(name); err != nil {
+			t.Fatalf("failed to enable %v: %v", name, err)
+		}
+	}
+	cookie, err := network.RegisterAppCookie("FlowOwnerB")
+	if err != nil {
+		t.Fatalf("failed to register the cookie: %v", err)
+	}
+
+	src := []struct {
+		cookie   uint64
+		expected []string
+	}{
+		{cookie.Value() | 0x1234, []string{"FlowOwnerB"}},
+		// Not owned by any application.
+		{0x1 << 63, []string{}},
+	}
+
+	for i, v := range src {
+		received = received[:0]
+		if err := newDispatcher(m.head).OnFlowRemoved(nil, nil, &mockFlowRemoved{cookie: v.cookie}); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(received, v.expected) {
+			t.Fatalf("#%v: unexpected owners: expected=%v, got=%v", i, v.expected, received)
+		}
+	}
+}
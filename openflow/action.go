@@ -29,36 +29,171 @@ import (
 )
 
 type Action interface {
+	// AddOutPort adds an additional output port to replicate the packet, e.g., for multicast.
+	AddOutPort(port OutPort)
+	// CopyTTLIn returns whether the TTL is copied from the outermost header to the next-to-outermost one.
+	CopyTTLIn() bool
+	// CopyTTLOut returns whether the TTL is copied from the next-to-outermost header to the outermost one.
+	CopyTTLOut() bool
+	// DecNWTTL returns whether the IP TTL is decremented.
+	DecNWTTL() bool
 	DstMAC() (ok bool, mac net.HardwareAddr)
 	encoding.BinaryMarshaler
 	encoding.BinaryUnmarshaler
+	// IPDst returns the destination IP address rewritten by this action.
+	IPDst() (ok bool, ip net.IP)
+	// IPSrc returns the source IP address rewritten by this action.
+	IPSrc() (ok bool, ip net.IP)
+	// L4DstPort returns the TCP, UDP, or SCTP destination port rewritten by this action.
+	L4DstPort() (ok bool, port uint16)
+	// L4Protocol returns the IP protocol of the L4 ports rewritten by this action.
+	L4Protocol() (ok bool, protocol uint8)
+	// L4SrcPort returns the TCP, UDP, or SCTP source port rewritten by this action.
+	L4SrcPort() (ok bool, port uint16)
+	// MPLSLabel returns the 20-bit label of the outermost MPLS label rewritten by this action. OpenFlow 1.3 only.
+	MPLSLabel() (ok bool, label uint32)
 	Queue() (ok bool, queue uint32)
 	// Error() returns last error message
 	Error() error
+	// Group returns the ID of the group that processes the packet. OpenFlow 1.3 only.
+	Group() (ok bool, id uint32)
 	OutPort() OutPort
+	// OutPorts returns all the output ports including the additional ones.
+	OutPorts() []OutPort
+	NWTTL() (ok bool, ttl uint8)
+	// PopMPLS returns the Ethernet type of the packet after the outermost MPLS label is popped. OpenFlow 1.3 only.
+	PopMPLS() (ok bool, etherType uint16)
+	// PopVLAN returns whether the outermost VLAN tag is popped. OpenFlow 1.0 treats it as StripVLAN.
+	PopVLAN() bool
+	// PushMPLS returns the Ethernet type of a new MPLS label pushed onto the packet. OpenFlow 1.3 only.
+	PushMPLS() (ok bool, etherType uint16)
+	// PushVLAN returns the Ethernet type of a new VLAN tag pushed onto the packet. OpenFlow 1.3 only.
+	PushVLAN() (ok bool, etherType uint16)
+	SetCopyTTLIn()
+	SetCopyTTLOut()
+	SetDecNWTTL()
 	SetDstMAC(mac net.HardwareAddr)
+	// SetGroup makes the packet processed by the group whose ID is id. The output ports are ignored if
+	// a group is set because the buckets of the group decide the output ports. OpenFlow 1.3 only.
+	SetGroup(id uint32)
+	// SetIPDst rewrites the destination IP address. The address family should be same with the Ethernet type of
+	// the flow match.
+	SetIPDst(ip net.IP)
+	// SetIPSrc rewrites the source IP address. The address family should be same with the Ethernet type of the
+	// flow match.
+	SetIPSrc(ip net.IP)
+	// SetL4DstPort rewrites the TCP, UDP, or SCTP destination port.
+	SetL4DstPort(port uint16)
+	// SetL4Protocol sets the IP protocol, TCP (6), UDP (17), or SCTP (132), of the L4 ports rewritten by
+	// SetL4SrcPort and SetL4DstPort. OpenFlow 1.3 requires this because it has a set-field for each protocol.
+	SetL4Protocol(protocol uint8)
+	// SetL4SrcPort rewrites the TCP, UDP, or SCTP source port.
+	SetL4SrcPort(port uint16)
+	// SetMPLSLabel rewrites the 20-bit label of the outermost MPLS label.
+	SetMPLSLabel(label uint32)
+	SetNWTTL(ttl uint8)
 	SetQueue(queue uint32)
 	SetOutPort(port OutPort)
+	// SetPopMPLS pops the outermost MPLS label. etherType is the Ethernet type of the packet after the label
+	// is popped, e.g., 0x0800 for IPv4 or 0x8847 if there are more labels.
+	SetPopMPLS(etherType uint16)
+	SetPopVLAN()
+	// SetPushMPLS pushes a new MPLS label whose Ethernet type should be either 0x8847 or 0x8848.
+	SetPushMPLS(etherType uint16)
+	// SetPushVLAN pushes a new VLAN tag whose Ethernet type should be either 0x8100 or 0x88a8.
+	SetPushVLAN(etherType uint16)
 	SetSrcMAC(mac net.HardwareAddr)
+	SetStripVLAN()
+	// SetVLANID sets the 12-bit VLAN ID of the outermost VLAN tag.
 	SetVLANID(vid uint16)
 	SrcMAC() (ok bool, mac net.HardwareAddr)
+	// StripVLAN returns whether the VLAN header is stripped. OpenFlow 1.3 treats it as PopVLAN.
+	StripVLAN() bool
 	VLANID() (ok bool, vid uint16)
 }
 
 type BaseAction struct {
 	err    error
 	output OutPort
-	srcMAC *net.HardwareAddr
-	dstMAC *net.HardwareAddr
-	queue  int64
-	vlanID int32
+	// Additional output ports.
+	outputs []OutPort
+	srcMAC  *net.HardwareAddr
+	dstMAC  *net.HardwareAddr
+	queue   int64
+	group   int64
+	vlanID  int32
+	vlan    struct {
+		strip, pop bool
+		push       int32
+	}
+	nwTTL int16
+	ttl   struct {
+		copyIn, copyOut, dec bool
+	}
+	nw struct {
+		src, dst net.IP
+	}
+	tp struct {
+		src, dst int32
+		protocol int16
+	}
+	mpls struct {
+		push, pop, label int32
+	}
 }
 
 func NewBaseAction() *BaseAction {
-	return &BaseAction{
+	r := &BaseAction{
 		queue:  -1,
+		group:  -1,
 		vlanID: -1,
+		nwTTL:  -1,
 	}
+	r.vlan.push = -1
+	r.tp.src = -1
+	r.tp.dst = -1
+	r.tp.protocol = -1
+	r.mpls.push = -1
+	r.mpls.pop = -1
+	r.mpls.label = -1
+
+	return r
+}
+
+func (r *BaseAction) SetNWTTL(ttl uint8) {
+	r.nwTTL = int16(ttl)
+}
+
+func (r *BaseAction) NWTTL() (ok bool, ttl uint8) {
+	if r.nwTTL == -1 {
+		return false, 0
+	}
+
+	return true, uint8(r.nwTTL)
+}
+
+func (r *BaseAction) SetDecNWTTL() {
+	r.ttl.dec = true
+}
+
+func (r *BaseAction) DecNWTTL() bool {
+	return r.ttl.dec
+}
+
+func (r *BaseAction) SetCopyTTLOut() {
+	r.ttl.copyOut = true
+}
+
+func (r *BaseAction) CopyTTLOut() bool {
+	return r.ttl.copyOut
+}
+
+func (r *BaseAction) SetCopyTTLIn() {
+	r.ttl.copyIn = true
+}
+
+func (r *BaseAction) CopyTTLIn() bool {
+	return r.ttl.copyIn
 }
 
 func (r *BaseAction) VLANID() (ok bool, vid uint16) {
@@ -70,9 +205,98 @@ func (r *BaseAction) VLANID() (ok bool, vid uint16) {
 }
 
 func (r *BaseAction) SetVLANID(vid uint16) {
+	if vid > 0xFFF {
+		r.err = errors.Wrapf(ErrInvalidVLANID, "SetVLANID: %v", vid)
+		return
+	}
+
 	r.vlanID = int32(vid)
 }
 
+func (r *BaseAction) SetStripVLAN() {
+	r.vlan.strip = true
+}
+
+func (r *BaseAction) StripVLAN() bool {
+	return r.vlan.strip
+}
+
+func (r *BaseAction) SetPopVLAN() {
+	r.vlan.pop = true
+}
+
+func (r *BaseAction) PopVLAN() bool {
+	return r.vlan.pop
+}
+
+func (r *BaseAction) SetPushVLAN(etherType uint16) {
+	if etherType != 0x8100 && etherType != 0x88a8 {
+		r.err = errors.Wrapf(ErrUnsupportedEtherType, "SetPushVLAN: 0x%x", etherType)
+		return
+	}
+
+	r.vlan.push = int32(etherType)
+}
+
+func (r *BaseAction) PushVLAN() (ok bool, etherType uint16) {
+	if r.vlan.push == -1 {
+		return false, 0
+	}
+
+	return true, uint16(r.vlan.push)
+}
+
+// IsMPLSEtherType returns whether t is the Ethernet type of the MPLS unicast (0x8847) or multicast (0x8848).
+func IsMPLSEtherType(t uint16) bool {
+	return t == 0x8847 || t == 0x8848
+}
+
+func (r *BaseAction) SetPushMPLS(etherType uint16) {
+	if !IsMPLSEtherType(etherType) {
+		r.err = errors.Wrapf(ErrUnsupportedEtherType, "SetPushMPLS: 0x%x", etherType)
+		return
+	}
+
+	r.mpls.push = int32(etherType)
+}
+
+func (r *BaseAction) PushMPLS() (ok bool, etherType uint16) {
+	if r.mpls.push == -1 {
+		return false, 0
+	}
+
+	return true, uint16(r.mpls.push)
+}
+
+func (r *BaseAction) SetPopMPLS(etherType uint16) {
+	r.mpls.pop = int32(etherType)
+}
+
+func (r *BaseAction) PopMPLS() (ok bool, etherType uint16) {
+	if r.mpls.pop == -1 {
+		return false, 0
+	}
+
+	return true, uint16(r.mpls.pop)
+}
+
+func (r *BaseAction) SetMPLSLabel(label uint32) {
+	if label > 0xFFFFF {
+		r.err = errors.Errorf("SetMPLSLabel: MPLS label %v exceeds 20 bits", label)
+		return
+	}
+
+	r.mpls.label = int32(label)
+}
+
+func (r *BaseAction) MPLSLabel() (ok bool, label uint32) {
+	if r.mpls.label == -1 {
+		return false, 0
+	}
+
+	return true, uint32(r.mpls.label)
+}
+
 func (r *BaseAction) Queue() (ok bool, queue uint32) {
 	if r.queue == -1 {
 		return false, 0
@@ -85,21 +309,42 @@ func (r *BaseAction) SetQueue(queue uint32) {
 	r.queue = int64(queue)
 }
 
+func (r *BaseAction) Group() (ok bool, id uint32) {
+	if r.group == -1 {
+		return false, 0
+	}
+
+	return true, uint32(r.group)
+}
+
+func (r *BaseAction) SetGroup(id uint32) {
+	r.group = int64(id)
+}
+
 func (r *BaseAction) SetOutPort(port OutPort) {
 	r.output = port
+	r.outputs = nil
+}
+
+func (r *BaseAction) AddOutPort(port OutPort) {
+	r.outputs = append(r.outputs, port)
 }
 
 func (r *BaseAction) OutPort() OutPort {
 	return r.output
 }
 
+func (r *BaseAction) OutPorts() []OutPort {
+	return append([]OutPort{r.output}, r.outputs...)
+}
+
 func (r *BaseAction) SetSrcMAC(mac net.HardwareAddr) {
 	if mac == nil || len(mac) < 6 {
 		r.err = errors.Wrap(ErrInvalidMACAddress, "SetSrcMAC")
 		return
 	}
 
-	r.srcMAC = &mac
+	r.srcMAC = copyMAC(mac)
 }
 
 func (r *BaseAction) SrcMAC() (ok bool, mac net.HardwareAddr) {
@@ -116,7 +361,7 @@ func (r *BaseAction) SetDstMAC(mac net.HardwareAddr) {
 		return
 	}
 
-	r.dstMAC = &mac
+	r.dstMAC = copyMAC(mac)
 }
 
 func (r *BaseAction) DstMAC() (ok bool, mac net.HardwareAddr) {
@@ -127,6 +372,91 @@ func (r *BaseAction) DstMAC() (ok bool, mac net.HardwareAddr) {
 	return true, *r.dstMAC
 }
 
+// copyMAC returns a copy of the first 6 bytes of mac so that the action does not refer to the caller's buffer,
+// e.g., the message buffer that the action is decoded from.
+func copyMAC(mac net.HardwareAddr) *net.HardwareAddr {
+	v := make(net.HardwareAddr, 6)
+	copy(v, mac)
+
+	return &v
+}
+
+func (r *BaseAction) SetIPSrc(ip net.IP) {
+	if ip.To16() == nil {
+		r.err = errors.Wrap(ErrInvalidIPAddress, "SetIPSrc")
+		return
+	}
+
+	r.nw.src = ip
+}
+
+func (r *BaseAction) IPSrc() (ok bool, ip net.IP) {
+	if r.nw.src == nil {
+		return false, nil
+	}
+
+	return true, r.nw.src
+}
+
+func (r *BaseAction) SetIPDst(ip net.IP) {
+	if ip.To16() == nil {
+		r.err = errors.Wrap(ErrInvalidIPAddress, "SetIPDst")
+		return
+	}
+
+	r.nw.dst = ip
+}
+
+func (r *BaseAction) IPDst() (ok bool, ip net.IP) {
+	if r.nw.dst == nil {
+		return false, nil
+	}
+
+	return true, r.nw.dst
+}
+
+func (r *BaseAction) SetL4SrcPort(port uint16) {
+	r.tp.src = int32(port)
+}
+
+func (r *BaseAction) L4SrcPort() (ok bool, port uint16) {
+	if r.tp.src == -1 {
+		return false, 0
+	}
+
+	return true, uint16(r.tp.src)
+}
+
+func (r *BaseAction) SetL4DstPort(port uint16) {
+	r.tp.dst = int32(port)
+}
+
+func (r *BaseAction) L4DstPort() (ok bool, port uint16) {
+	if r.tp.dst == -1 {
+		return false, 0
+	}
+
+	return true, uint16(r.tp.dst)
+}
+
+func (r *BaseAction) SetL4Protocol(protocol uint8) {
+	// TCP = 6, UDP = 17, and SCTP = 132.
+	if protocol != 6 && protocol != 17 && protocol != 132 {
+		r.err = errors.Wrapf(ErrUnsupportedIPProtocol, "SetL4Protocol: %v", protocol)
+		return
+	}
+
+	r.tp.protocol = int16(protocol)
+}
+
+func (r *BaseAction) L4Protocol() (ok bool, protocol uint8) {
+	if r.tp.protocol == -1 {
+		return false, 0
+	}
+
+	return true, uint8(r.tp.protocol)
+}
+
 func (r *BaseAction) Error() error {
 	return r.err
 }
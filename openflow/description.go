@@ -22,7 +22,9 @@
 package openflow
 
 import (
+	"bytes"
 	"encoding"
+	"strings"
 )
 
 // Description reqeust
@@ -41,3 +43,14 @@ type DescReply interface {
 	Description() string
 	encoding.BinaryUnmarshaler
 }
+
+// ParseDescString returns the string of a fixed-length, null-terminated field of the description reply.
+// Some switches do not fill the rest of the field with nulls after the terminating null, or pad the string
+// with spaces, so the bytes after the first null and the surrounding spaces are removed.
+func ParseDescString(field []byte) string {
+	if i := bytes.IndexByte(field, 0); i >= 0 {
+		field = field[:i]
+	}
+
+	return strings.TrimSpace(string(field))
+}
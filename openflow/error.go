@@ -24,8 +24,40 @@ package openflow
 import (
 	"encoding"
 	"encoding/binary"
+	"errors"
+	"fmt"
 )
 
+// Reasons of the FLOW_MOD failures replied by the switches. They are the causes of FlowModError.
+var (
+	ErrFlowModFailed  = errors.New("unspecified flow-mod failure")
+	ErrFlowTableFull  = errors.New("flow table is full")
+	ErrFlowOverlap    = errors.New("flow overlaps with an existing one")
+	ErrFlowPermission = errors.New("flow is not permitted")
+	ErrFlowBadTimeout = errors.New("unsupported flow timeout")
+	ErrFlowBadCommand = errors.New("unsupported flow-mod command")
+	ErrFlowBadTableID = errors.New("flow table does not exist")
+	ErrFlowBadFlags   = errors.New("unsupported flow-mod flags")
+	ErrFlowBadActions = errors.New("unsupported action list")
+)
+
+// FlowModError is a FLOW_MOD_FAILED error replied by a switch to a FLOW_MOD. Its cause is one of the
+// ErrFlowXXX errors, so the callers can branch on the reason using errors.Cause.
+type FlowModError struct {
+	XID  uint32 // Transaction ID of the failed FLOW_MOD
+	Code uint16 // Version-specific error code
+	// One of the ErrFlowXXX errors.
+	Reason error
+}
+
+func (r *FlowModError) Error() string {
+	return fmt.Sprintf("switch rejected the flow-mod: xid=%v, code=%v: %v", r.XID, r.Code, r.Reason)
+}
+
+func (r *FlowModError) Cause() error {
+	return r.Reason
+}
+
 type Error interface {
 	Header
 	Class() uint16 // Error type
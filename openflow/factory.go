@@ -37,6 +37,11 @@ var (
 	ErrMissingEtherType      = errors.New("missing Ethernet type")
 	ErrUnsupportedMatchType  = errors.New("unsupported flow match type")
 	ErrInvalidPropertyMethod = errors.New("invalid property method")
+	ErrUnsupportedAction     = errors.New("unsupported action")
+	ErrUnsupportedPortConfig = errors.New("unsupported port config")
+	ErrInvalidVLANID         = errors.New("invalid VLAN ID")
+	ErrInvalidPortNumber     = errors.New("invalid port number")
+	ErrInvalidControllerRole = errors.New("invalid controller role")
 )
 
 // Abstract factory
@@ -55,19 +60,33 @@ type Factory interface {
 	NewFlowMod(cmd FlowModCmd) (FlowMod, error)
 	NewFlowRemoved() (FlowRemoved, error)
 	NewFlowStatsRequest() (FlowStatsRequest, error)
-	// TODO: NewFlowStatsReply() (FlowStatsReply, error)
+	NewFlowStatsReply() (FlowStatsReply, error)
 	NewGetConfigRequest() (GetConfigRequest, error)
+	NewGroupMod(cmd GroupCommand) (GroupMod, error)
 	NewGetConfigReply() (GetConfigReply, error)
 	NewHello() (Hello, error)
 	NewInstruction() (Instruction, error)
 	NewMatch() (Match, error)
+	NewMeterMod(cmd MeterCommand) (MeterMod, error)
 	NewPacketIn() (PacketIn, error)
 	NewPacketOut() (PacketOut, error)
 	NewPortDescRequest() (PortDescRequest, error)
 	NewPortDescReply() (PortDescReply, error)
+	NewPortMod() (PortMod, error)
+	NewPortStatsRequest() (PortStatsRequest, error)
+	NewPortStatsReply() (PortStatsReply, error)
 	NewPortStatus() (PortStatus, error)
 	NewQueueGetConfigRequest() (QueueGetConfigRequest, error)
+	NewQueueGetConfigReply() (QueueGetConfigReply, error)
+	// NewRoleRequest returns a request that changes or queries the role of the controller. OpenFlow 1.3 only.
+	NewRoleRequest() (RoleRequest, error)
+	NewRoleReply() (RoleReply, error)
 	NewSetConfig() (SetConfig, error)
+	// NewGotoTableInstruction returns an instruction that continues the lookup in the table whose ID is tableID.
+	NewGotoTableInstruction(tableID uint8) (Instruction, error)
+	NewTableMod() (TableMod, error)
+	NewTableStatsRequest() (TableStatsRequest, error)
+	NewTableStatsReply() (TableStatsReply, error)
 	NewTableFeaturesRequest() (TableFeaturesRequest, error)
 	// TODO: NewTableFeaturesReply() (TableFeaturesReply, error)
 }
@@ -25,6 +25,15 @@ import (
 	"encoding"
 )
 
+// Capability flags of FeaturesReply that have the same values in OpenFlow 1.0 and 1.3.
+const (
+	CapabilityFlowStats  = 1 << 0
+	CapabilityTableStats = 1 << 1
+	CapabilityPortStats  = 1 << 2
+	CapabilityIPReasm    = 1 << 5
+	CapabilityQueueStats = 1 << 6
+)
+
 type FeaturesRequest interface {
 	Header
 	encoding.BinaryMarshaler
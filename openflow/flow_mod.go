@@ -23,6 +23,9 @@ package openflow
 
 import (
 	"encoding"
+	"net"
+
+	"github.com/pkg/errors"
 )
 
 type FlowModCmd uint8
@@ -33,11 +36,33 @@ const (
 	FlowDelete
 )
 
+// FlowModFlags are the OFPFF flags of a flow-mod.
+type FlowModFlags struct {
+	// Send a FLOW_REMOVED message when the flow expires or is deleted.
+	SendFlowRemoved bool
+	// Check for the overlapping flows first.
+	CheckOverlap bool
+	// Reset the packet and byte counts of the modified flow. OpenFlow 1.3 only.
+	ResetCounts bool
+	// Do not keep track of the packet count. OpenFlow 1.3 only.
+	NoPacketCounts bool
+	// Do not keep track of the byte count. OpenFlow 1.3 only.
+	NoByteCounts bool
+}
+
+// DefaultFlowModFlags are the flags of a new flow-mod.
+var DefaultFlowModFlags = FlowModFlags{SendFlowRemoved: true}
+
 type FlowMod interface {
+	// Command returns the command of the flow-mod. The strict variants are reported as the non-strict ones.
+	Command() FlowModCmd
 	Cookie() uint64
 	CookieMask() uint64
 	encoding.BinaryMarshaler
 	Error() error
+	// Flags returns the flags of the flow-mod. The flags that are not supported by the OpenFlow version are
+	// always false.
+	Flags() FlowModFlags
 	FlowInstruction() Instruction
 	FlowMatch() Match
 	HardTimeout() uint16
@@ -48,6 +73,9 @@ type FlowMod interface {
 	SetCookie(cookie uint64)
 	SetCookieMask(mask uint64)
 	SetFlowInstruction(action Instruction)
+	// SetFlags sets the flags of the flow-mod. The flags that are not supported by the OpenFlow version are
+	// ignored.
+	SetFlags(flags FlowModFlags)
 	SetFlowMatch(match Match)
 	SetHardTimeout(timeout uint16)
 	SetIdleTimeout(timeout uint16)
@@ -56,3 +84,172 @@ type FlowMod interface {
 	SetTableID(id uint8)
 	TableID() uint8
 }
+
+// ValidateFlowMod checks the internal consistency of the flow before it goes on the wire, so that we can
+// catch mistakes with descriptive error messages instead of opaque errors from switches.
+func ValidateFlowMod(flow FlowMod) error {
+	if err := flow.Error(); err != nil {
+		return err
+	}
+
+	match := flow.FlowMatch()
+	if match == nil {
+		// A delete request without a match removes all the flows, and its instruction is ignored.
+		if flow.Command() == FlowDelete {
+			return nil
+		}
+		return errors.New("invalid flow-mod: empty flow match")
+	}
+	if err := match.Error(); err != nil {
+		return errors.Wrap(err, "invalid flow-mod match")
+	}
+	if err := validateMatchPrerequisites(flow.Version(), match); err != nil {
+		return errors.Wrap(err, "invalid flow-mod match")
+	}
+
+	// Nil instruction means an explicit drop.
+	inst := flow.FlowInstruction()
+	if inst == nil {
+		return nil
+	}
+	if err := inst.Error(); err != nil {
+		return errors.Wrap(err, "invalid flow-mod instruction")
+	}
+	if ok, tableID := inst.GotoTableID(); ok {
+		// The pipeline can only go forward.
+		if tableID <= flow.TableID() {
+			return errors.Errorf("invalid flow-mod instruction: goto-table %v from table %v", tableID, flow.TableID())
+		}
+		return nil
+	}
+	action := inst.Action()
+	if action == nil {
+		return errors.New("invalid flow-mod instruction: empty action list (remove the instruction for an explicit drop)")
+	}
+	if err := action.Error(); err != nil {
+		return errors.Wrap(err, "invalid flow-mod action")
+	}
+	if err := validateRewrites(match, action); err != nil {
+		return errors.Wrap(err, "invalid flow-mod action")
+	}
+	// The output ports are ignored if a group is set.
+	if ok, _ := action.Group(); ok {
+		return nil
+	}
+	for _, out := range action.OutPorts() {
+		if out.IsPhysical() && out.Value() == 0 {
+			return errors.New("invalid flow-mod action: output to port number zero")
+		}
+		// OFPP_TABLE submits a packet to the pipeline, which is only meaningful for the packet from the controller.
+		if out.IsTable() {
+			return errors.New("invalid flow-mod action: output to TABLE is only allowed in PACKET_OUT")
+		}
+	}
+
+	return nil
+}
+
+// validateMatchPrerequisites checks that the fields of match have their prerequisites. version is the OpenFlow
+// version of the flow, because OpenFlow 1.0 matches the ARP sender and target IP addresses, and the lower 8 bits
+// of the ARP opcode, using the IP fields.
+func validateMatchPrerequisites(version uint8, match Match) error {
+	if wildcard, _ := match.PhysicalInPort(); !wildcard {
+		if wildcard, _ := match.InPort(); wildcard {
+			return errors.New("physical input port requires the input port")
+		}
+	}
+
+	wildcard, etherType := match.EtherType()
+	isIP := !wildcard && (etherType == 0x0800 || etherType == 0x86DD)
+	// SrcIP and DstIP are IPv4 addresses, and the IPv6 addresses have their own fields.
+	isIPv4 := !wildcard && etherType == 0x0800
+	isARP := !wildcard && etherType == 0x0806 && version == OF10_VERSION
+
+	if ones, _ := match.SrcIP().Mask.Size(); ones > 0 && !isIPv4 && !isARP {
+		return errors.New("source IP address requires the IPv4 ethernet type (or ARP on OpenFlow 1.0)")
+	}
+	if ones, _ := match.DstIP().Mask.Size(); ones > 0 && !isIPv4 && !isARP {
+		return errors.New("destination IP address requires the IPv4 ethernet type (or ARP on OpenFlow 1.0)")
+	}
+
+	isIPv6 := !wildcard && etherType == 0x86DD
+	if ones, _ := match.IPv6Src().Mask.Size(); ones > 0 && !isIPv6 {
+		return errors.New("IPv6 source address requires the IPv6 ethernet type")
+	}
+	if ones, _ := match.IPv6Dst().Mask.Size(); ones > 0 && !isIPv6 {
+		return errors.New("IPv6 destination address requires the IPv6 ethernet type")
+	}
+	if wildcard, _ := match.IPv6FlowLabel(); !wildcard && !isIPv6 {
+		return errors.New("IPv6 flow label requires the IPv6 ethernet type")
+	}
+
+	isMPLS := !wildcard && IsMPLSEtherType(etherType)
+	if wildcard, _ := match.MPLSLabel(); !wildcard && !isMPLS {
+		return errors.New("MPLS label requires the MPLS ethernet type")
+	}
+	if wildcard, _ := match.MPLSBOS(); !wildcard && !isMPLS {
+		return errors.New("MPLS BOS bit requires the MPLS ethernet type")
+	}
+
+	wildcard, protocol := match.IPProtocol()
+	if !wildcard && !isIP && !isARP {
+		return errors.New("IP protocol requires the IPv4 or IPv6 ethernet type (or ARP on OpenFlow 1.0)")
+	}
+	// TCP = 6, UDP = 17, and SCTP = 132.
+	isL4 := !wildcard && (protocol == 6 || protocol == 17 || protocol == 132)
+
+	if wildcard, _ := match.SrcPort(); !wildcard && !isL4 {
+		return errors.New("source port requires the TCP, UDP, or SCTP IP protocol")
+	}
+	if wildcard, _ := match.DstPort(); !wildcard && !isL4 {
+		return errors.New("destination port requires the TCP, UDP, or SCTP IP protocol")
+	}
+
+	return nil
+}
+
+// validateRewrites checks that the L3 and L4 headers rewritten by action exist in the packets matched by match.
+func validateRewrites(match Match, action Action) error {
+	wildcard, etherType := match.EtherType()
+	for _, v := range []struct {
+		name string
+		get  func() (bool, net.IP)
+	}{
+		{"source IP address", action.IPSrc},
+		{"destination IP address", action.IPDst},
+	} {
+		ok, ip := v.get()
+		if !ok {
+			continue
+		}
+		if ip.To4() != nil && (wildcard || etherType != 0x0800) {
+			return errors.Errorf("rewriting the IPv4 %v requires the IPv4 ethernet type", v.name)
+		}
+		if ip.To4() == nil && (wildcard || etherType != 0x86DD) {
+			return errors.Errorf("rewriting the IPv6 %v requires the IPv6 ethernet type", v.name)
+		}
+	}
+
+	if ok, _ := action.MPLSLabel(); ok {
+		pushed, _ := action.PushMPLS()
+		if !pushed && (wildcard || !IsMPLSEtherType(etherType)) {
+			return errors.New("rewriting the MPLS label requires the MPLS ethernet type or pushing a new MPLS label")
+		}
+	}
+
+	srcOK, _ := action.L4SrcPort()
+	dstOK, _ := action.L4DstPort()
+	if !srcOK && !dstOK {
+		return nil
+	}
+	wildcard, protocol := match.IPProtocol()
+	// TCP = 6, UDP = 17, and SCTP = 132.
+	if wildcard || (protocol != 6 && protocol != 17 && protocol != 132) {
+		return errors.New("rewriting the L4 ports requires the TCP, UDP, or SCTP IP protocol")
+	}
+	if ok, v := action.L4Protocol(); ok && v != protocol {
+		return errors.Errorf("IP protocol of the rewritten L4 ports (%v) is different from that of the flow match (%v)", v, protocol)
+	}
+
+	return nil
+}
@@ -40,3 +40,11 @@ type FlowRemoved interface {
 	Match() Match
 	encoding.BinaryUnmarshaler
 }
+
+// Reasons of the flow removed messages. They have the same values in both OpenFlow 1.0 and 1.3.
+const (
+	FlowRemovedIdleTimeout uint8 = iota
+	FlowRemovedHardTimeout
+	FlowRemovedDelete
+	FlowRemovedGroupDelete // OpenFlow 1.3 only
+)
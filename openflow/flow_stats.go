@@ -40,4 +40,35 @@ type FlowStatsRequest interface {
 	TableID() uint8
 }
 
-// TODO: Implement FlowStatsReply
+// FlowStat is the statistics of a flow entry in a FLOW_STATS reply.
+type FlowStat struct {
+	TableID         uint8
+	Priority        uint16
+	Cookie          uint64
+	DurationSec     uint32
+	DurationNanoSec uint32
+	IdleTimeout     uint16
+	HardTimeout     uint16
+	PacketCount     uint64
+	ByteCount       uint64
+	Match           Match
+	// Actions applied to the packets, which are the ones of the APPLY_ACTIONS instruction on OpenFlow 1.3.
+	// Nil if the flow has no such actions or they cannot be decoded.
+	Actions Action
+	// The fields below are the other instructions, which only exist on OpenFlow 1.3.
+	// Actions written into the action set by the WRITE_ACTIONS instruction. Nil if there is no such instruction.
+	WriteActions Action
+	// Next table of the GOTO_TABLE instruction if GotoTable is true.
+	GotoTable   bool
+	GotoTableID uint8
+	// Meter of the METER instruction, or zero if there is no meter.
+	MeterID uint32
+}
+
+type FlowStatsReply interface {
+	Header
+	// More returns whether more replies will follow this reply to complete the response.
+	More() bool
+	Stats() []FlowStat
+	encoding.BinaryUnmarshaler
+}
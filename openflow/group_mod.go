@@ -0,0 +1,88 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package openflow
+
+import (
+	"encoding"
+)
+
+type GroupCommand uint8
+
+const (
+	GroupAdd GroupCommand = iota
+	GroupModify
+	GroupDelete
+)
+
+type GroupType uint8
+
+const (
+	// All executes all the buckets, which is used for multicast or broadcast forwarding.
+	GroupAll GroupType = iota
+	// Select executes one bucket chosen by a switch-computed selection algorithm, e.g., ECMP.
+	GroupSelect
+	// Indirect executes the one defined bucket.
+	GroupIndirect
+	// FastFailover executes the first live bucket.
+	GroupFastFailover
+)
+
+const (
+	// WatchAny means that a bucket does not watch any port or group.
+	WatchAny = 0xFFFFFFFF
+	// GroupAllID is the group ID that represents all the groups for GroupDelete.
+	GroupAllID = 0xFFFFFFFC
+)
+
+// Bucket is an action bucket of a group.
+type Bucket struct {
+	// Weight is only meaningful for GroupSelect.
+	Weight uint16
+	// WatchPort and WatchGroup are only meaningful for GroupFastFailover.
+	WatchPort  uint32
+	WatchGroup uint32
+	Action     Action
+}
+
+// NewBucket returns a bucket that does not watch any port or group.
+func NewBucket(action Action) Bucket {
+	return Bucket{
+		WatchPort:  WatchAny,
+		WatchGroup: WatchAny,
+		Action:     action,
+	}
+}
+
+type GroupMod interface {
+	Header
+	// Error() returns last error message
+	Error() error
+	Command() GroupCommand
+	GroupType() GroupType
+	SetGroupType(t GroupType)
+	GroupID() uint32
+	SetGroupID(id uint32)
+	Buckets() []Bucket
+	AddBucket(b Bucket)
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
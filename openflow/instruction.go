@@ -26,9 +26,26 @@ import (
 )
 
 type Instruction interface {
+	// Action returns the action of this instruction, or nil if there is no action.
+	Action() Action
 	ApplyAction(act Action)
 	encoding.BinaryMarshaler
 	Error() error
 	GotoTable(tableID uint8)
+	// GotoTableID returns the next table ID if this instruction is a goto-table.
+	GotoTableID() (ok bool, tableID uint8)
+	// Meter returns the meter ID attached to this instruction, or zero if there is no meter.
+	Meter() uint32
+	// Metadata returns the metadata written by this instruction and its mask. ok is false if this instruction
+	// does not write the metadata.
+	Metadata() (ok bool, value, mask uint64)
+	// SetMeter attaches the meter whose ID is meterID so that the flow is rate limited by the meter.
+	SetMeter(meterID uint32)
 	WriteAction(act Action)
+	// WriteMetadata writes value into the bits of the metadata register that are set in mask, so that the
+	// following table in the pipeline can match them by Match.SetMetadata. It is combined with the other
+	// instruction, typically a goto-table. For example, l2switch could classify a packet in table 0 by
+	// writing its class into the low bits with the mask 0xFF and then going to its forwarding table, whose
+	// flows match the class instead of repeating the classification fields. OpenFlow 1.3 only.
+	WriteMetadata(value, mask uint64)
 }
@@ -23,6 +23,7 @@ package openflow
 
 import (
 	"encoding"
+	"fmt"
 	"net"
 )
 
@@ -35,9 +36,31 @@ type Match interface {
 	encoding.BinaryUnmarshaler
 	Error() error
 	EtherType() (wildcard bool, etherType uint16)
+	// Fields returns the fields that this match constrains in the order of their types.
+	Fields() []MatchField
 	// InPort returns switch port number
 	InPort() (wildcard bool, inport InPort)
+	// IPDSCP returns the 6-bit DSCP of the IP ToS (IPv4) or traffic class (IPv6) field.
+	IPDSCP() (wildcard bool, dscp uint8)
+	// IPECN returns the 2-bit ECN of the IP ToS (IPv4) or traffic class (IPv6) field.
+	IPECN() (wildcard bool, ecn uint8)
 	IPProtocol() (wildcard bool, protocol uint8)
+	// IPv6Dst returns the IPv6 destination address and its prefix. OpenFlow 1.3 only.
+	IPv6Dst() *net.IPNet
+	// IPv6FlowLabel returns the 20-bit IPv6 flow label. OpenFlow 1.3 only.
+	IPv6FlowLabel() (wildcard bool, label uint32)
+	// IPv6Src returns the IPv6 source address and its prefix. OpenFlow 1.3 only.
+	IPv6Src() *net.IPNet
+	// MPLSBOS returns the bottom of stack bit of the outermost MPLS label. OpenFlow 1.3 only.
+	MPLSBOS() (wildcard bool, bos bool)
+	// MPLSLabel returns the 20-bit label of the outermost MPLS label. OpenFlow 1.3 only.
+	MPLSLabel() (wildcard bool, label uint32)
+	// Metadata returns the metadata register and its mask, which has all the bits set if the whole register
+	// is matched. OpenFlow 1.3 only.
+	Metadata() (wildcard bool, value, mask uint64)
+	// PhysicalInPort returns the physical port that the packet has been received on, which may differ from
+	// InPort, e.g., a member port of a link aggregation group. OpenFlow 1.3 only.
+	PhysicalInPort() (wildcard bool, port uint32)
 	SetDstIP(ip *net.IPNet)
 	SetDstMAC(mac net.HardwareAddr)
 	// SetDstPort sets protocol (TCP or UDP) destination port number
@@ -45,7 +68,25 @@ type Match interface {
 	SetEtherType(t uint16)
 	// SetInPort sets switch port number
 	SetInPort(port InPort)
+	// SetIPDSCP sets the 6-bit DSCP of the IP ToS (IPv4) or traffic class (IPv6) field.
+	SetIPDSCP(dscp uint8)
+	// SetIPECN sets the 2-bit ECN of the IP ToS (IPv4) or traffic class (IPv6) field.
+	SetIPECN(ecn uint8)
 	SetIPProtocol(p uint8)
+	// SetIPv6Dst sets the IPv6 destination address. ip.Mask is used as a prefix, e.g., /64.
+	SetIPv6Dst(ip *net.IPNet)
+	SetIPv6FlowLabel(label uint32)
+	// SetIPv6Src sets the IPv6 source address. ip.Mask is used as a prefix, e.g., /64.
+	SetIPv6Src(ip *net.IPNet)
+	// SetMPLSBOS sets the bottom of stack bit. The Ethernet type should be 0x8847 or 0x8848.
+	SetMPLSBOS(bos bool)
+	// SetMPLSLabel sets the 20-bit MPLS label. The Ethernet type should be 0x8847 or 0x8848.
+	SetMPLSLabel(label uint32)
+	// SetMetadata matches the bits of the metadata register, which is written by Instruction.WriteMetadata of
+	// the previous table, that are set in mask. OpenFlow 1.3 only.
+	SetMetadata(value, mask uint64)
+	// SetPhysicalInPort sets the physical ingress port. InPort should be also set. OpenFlow 1.3 only.
+	SetPhysicalInPort(port uint32)
 	SetSrcIP(ip *net.IPNet)
 	SetSrcMAC(mac net.HardwareAddr)
 	// SetSrcPort sets protocol (TCP or UDP) source port number
@@ -61,7 +102,11 @@ type Match interface {
 	SetWildcardSrcPort()
 	// SetWildcardInPort sets switch port number as a wildcard
 	SetWildcardInPort()
+	SetWildcardIPDSCP()
+	SetWildcardIPECN()
 	SetWildcardIPProtocol()
+	SetWildcardMetadata()
+	SetWildcardPhysicalInPort()
 	SetWildcardVLANID()
 	SetWildcardVLANPriority()
 	SrcIP() *net.IPNet
@@ -71,3 +116,137 @@ type Match interface {
 	VLANID() (wildcard bool, vlanID uint16)
 	VLANPriority() (wildcard bool, priority uint8)
 }
+
+// MatchFieldType is a type of the fields that a match constrains, which is independent of the OpenFlow version.
+type MatchFieldType int
+
+const (
+	MatchInPort         MatchFieldType = iota // uint32
+	MatchSrcMAC                               // net.HardwareAddr
+	MatchDstMAC                               // net.HardwareAddr
+	MatchEtherType                            // uint16
+	MatchVLANID                               // uint16
+	MatchVLANPriority                         // uint8
+	MatchIPProtocol                           // uint8
+	MatchIPDSCP                               // uint8
+	MatchIPECN                                // uint8
+	MatchSrcIP                                // *net.IPNet
+	MatchDstIP                                // *net.IPNet
+	MatchIPv6Src                              // *net.IPNet
+	MatchIPv6Dst                              // *net.IPNet
+	MatchIPv6FlowLabel                        // uint32
+	MatchSrcPort                              // uint16
+	MatchDstPort                              // uint16
+	MatchMPLSLabel                            // uint32
+	MatchMPLSBOS                              // bool
+	MatchMetadata                             // uint64
+	MatchPhysicalInPort                       // uint32
+)
+
+var matchFieldNames = map[MatchFieldType]string{
+	MatchInPort:         "in_port",
+	MatchSrcMAC:         "eth_src",
+	MatchDstMAC:         "eth_dst",
+	MatchEtherType:      "eth_type",
+	MatchVLANID:         "vlan_vid",
+	MatchVLANPriority:   "vlan_pcp",
+	MatchIPProtocol:     "ip_proto",
+	MatchIPDSCP:         "ip_dscp",
+	MatchIPECN:          "ip_ecn",
+	MatchSrcIP:          "ipv4_src",
+	MatchDstIP:          "ipv4_dst",
+	MatchIPv6Src:        "ipv6_src",
+	MatchIPv6Dst:        "ipv6_dst",
+	MatchIPv6FlowLabel:  "ipv6_flabel",
+	MatchSrcPort:        "tp_src",
+	MatchDstPort:        "tp_dst",
+	MatchMPLSLabel:      "mpls_label",
+	MatchMPLSBOS:        "mpls_bos",
+	MatchMetadata:       "metadata",
+	MatchPhysicalInPort: "in_phy_port",
+}
+
+func (r MatchFieldType) String() string {
+	if v, ok := matchFieldNames[r]; ok {
+		return v
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(r))
+}
+
+// MatchField is a field that a match constrains. The type of Value depends on Type as commented on the
+// MatchFieldType constants, and the IP addresses have their prefixes in their masks.
+type MatchField struct {
+	Type  MatchFieldType
+	Value interface{}
+	// Mask of the metadata register, which has all the bits set if the whole register is matched. It is
+	// zero for the other fields.
+	Mask uint64
+}
+
+func (r MatchField) String() string {
+	if r.Type == MatchMetadata {
+		return fmt.Sprintf("%v=0x%x/0x%x", r.Type, r.Value, r.Mask)
+	}
+
+	return fmt.Sprintf("%v=%v", r.Type, r.Value)
+}
+
+// MatchFields returns the fields that m constrains in the order of their types using the accessors of m,
+// so that the Fields methods of the version-specific matches are consistent.
+func MatchFields(m Match) []MatchField {
+	fields := make([]MatchField, 0)
+	add := func(wildcard bool, t MatchFieldType, v interface{}) {
+		if !wildcard {
+			fields = append(fields, MatchField{Type: t, Value: v})
+		}
+	}
+	// Zero prefix length means a wildcard.
+	addIP := func(t MatchFieldType, ip *net.IPNet) {
+		if ip == nil {
+			return
+		}
+		ones, _ := ip.Mask.Size()
+		add(ones == 0, t, ip)
+	}
+
+	wildcard, inPort := m.InPort()
+	add(wildcard, MatchInPort, inPort.Value())
+	wildcard, srcMAC := m.SrcMAC()
+	add(wildcard, MatchSrcMAC, srcMAC)
+	wildcard, dstMAC := m.DstMAC()
+	add(wildcard, MatchDstMAC, dstMAC)
+	wildcard, etherType := m.EtherType()
+	add(wildcard, MatchEtherType, etherType)
+	wildcard, vlanID := m.VLANID()
+	add(wildcard, MatchVLANID, vlanID)
+	wildcard, vlanPriority := m.VLANPriority()
+	add(wildcard, MatchVLANPriority, vlanPriority)
+	wildcard, protocol := m.IPProtocol()
+	add(wildcard, MatchIPProtocol, protocol)
+	wildcard, dscp := m.IPDSCP()
+	add(wildcard, MatchIPDSCP, dscp)
+	wildcard, ecn := m.IPECN()
+	add(wildcard, MatchIPECN, ecn)
+	addIP(MatchSrcIP, m.SrcIP())
+	addIP(MatchDstIP, m.DstIP())
+	addIP(MatchIPv6Src, m.IPv6Src())
+	addIP(MatchIPv6Dst, m.IPv6Dst())
+	wildcard, label := m.IPv6FlowLabel()
+	add(wildcard, MatchIPv6FlowLabel, label)
+	wildcard, srcPort := m.SrcPort()
+	add(wildcard, MatchSrcPort, srcPort)
+	wildcard, dstPort := m.DstPort()
+	add(wildcard, MatchDstPort, dstPort)
+	wildcard, mplsLabel := m.MPLSLabel()
+	add(wildcard, MatchMPLSLabel, mplsLabel)
+	wildcard, bos := m.MPLSBOS()
+	add(wildcard, MatchMPLSBOS, bos)
+	if wildcard, value, mask := m.Metadata(); !wildcard {
+		fields = append(fields, MatchField{Type: MatchMetadata, Value: value, Mask: mask})
+	}
+	wildcard, phyPort := m.PhysicalInPort()
+	add(wildcard, MatchPhysicalInPort, phyPort)
+
+	return fields
+}
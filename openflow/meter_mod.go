@@ -0,0 +1,73 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package openflow
+
+import (
+	"encoding"
+)
+
+type MeterCommand uint8
+
+const (
+	MeterAdd MeterCommand = iota
+	MeterModify
+	MeterDelete
+)
+
+type MeterBandType uint8
+
+const (
+	// MeterBandDrop drops the packets exceeding the band rate.
+	MeterBandDrop MeterBandType = iota
+	// MeterBandDSCPRemark increases the drop precedence of the DSCP field of the packets exceeding the band rate.
+	MeterBandDSCPRemark
+)
+
+type MeterBand struct {
+	Type MeterBandType
+	// Rate in kb/s, or packets/s if the meter measures packet rates.
+	Rate      uint32
+	BurstSize uint32
+	// PrecLevel is the number of drop precedence level to add, which is only meaningful for MeterBandDSCPRemark.
+	PrecLevel uint8
+}
+
+// Meter is a rate limiter that can be attached to flows by Instruction.SetMeter().
+type Meter struct {
+	// Meter ID should be in the range from 1 to 0xFFFF0000.
+	ID uint32
+	// PacketRate means the band rates are in packets per second instead of kilobits per second.
+	PacketRate bool
+	// Burst means the burst sizes of the bands are used.
+	Burst bool
+	Bands []MeterBand
+}
+
+type MeterMod interface {
+	Header
+	// Error() returns last error message
+	Error() error
+	Command() MeterCommand
+	Meter() Meter
+	SetMeter(m Meter)
+	encoding.BinaryMarshaler
+}
@@ -26,6 +26,8 @@ import (
 	"net"
 
 	"github.com/superkkt/cherry/openflow"
+
+	"github.com/pkg/errors"
 )
 
 type Action struct {
@@ -57,16 +59,57 @@ func marshalOutPort(p openflow.OutPort) ([]byte, error) {
 		port = OFPP_IN_PORT
 	case p.IsNone():
 		port = OFPP_NONE
+	case p.IsNormal():
+		port = OFPP_NORMAL
 	default:
-		port = uint16(p.Value())
+		v, err := physicalPort(p.Value())
+		if err != nil {
+			return nil, err
+		}
+		port = v
 	}
 	binary.BigEndian.PutUint16(v[4:6], port)
-	// We don't support buffer ID and partial PACKET_IN
-	binary.BigEndian.PutUint16(v[6:8], 0xFFFF)
+	// Only meaningful for the controller, and we don't support buffer ID.
+	binary.BigEndian.PutUint16(v[6:8], p.MaxLen())
 
 	return v, nil
 }
 
+// unmarshalOutPort decodes the output port number, which may be one of the reserved ports, of an action.
+func unmarshalOutPort(port, maxLen uint16) openflow.OutPort {
+	v := openflow.NewOutPort()
+	switch port {
+	case OFPP_TABLE:
+		v.SetTable()
+	case OFPP_FLOOD:
+		v.SetFlood()
+	case OFPP_ALL:
+		v.SetAll()
+	case OFPP_CONTROLLER:
+		v.SetControllerMaxLen(maxLen)
+	case OFPP_IN_PORT:
+		v.SetInPort()
+	case OFPP_NONE:
+		v.SetNone()
+	case OFPP_NORMAL:
+		v.SetNormal()
+	default:
+		v.SetValue(uint32(port))
+	}
+
+	return v
+}
+
+// physicalPort returns the 16-bit port number of an output port set by OutPort.SetValue. The reserved ports
+// should be set by their own setters, except OFPP_LOCAL that has no setter.
+func physicalPort(port uint32) (uint16, error) {
+	if port > OFPP_MAX && port != OFPP_LOCAL {
+		return 0, errors.Errorf("invalid output port: reserved or too large port number %#x", port)
+	}
+
+	return uint16(port), nil
+}
+
 func marshalQueue(p openflow.OutPort, queue uint32) ([]byte, error) {
 	v := make([]byte, 16)
 	binary.BigEndian.PutUint16(v[0:2], uint16(OFPAT_ENQUEUE))
@@ -82,10 +125,18 @@ func marshalQueue(p openflow.OutPort, queue uint32) ([]byte, error) {
 		port = OFPP_ALL
 	case p.IsController():
 		port = OFPP_CONTROLLER
+	case p.IsInPort():
+		port = OFPP_IN_PORT
 	case p.IsNone():
 		port = OFPP_NONE
+	case p.IsNormal():
+		port = OFPP_NORMAL
 	default:
-		port = uint16(p.Value())
+		v, err := physicalPort(p.Value())
+		if err != nil {
+			return nil, err
+		}
+		port = v
 	}
 	binary.BigEndian.PutUint16(v[4:6], port)
 	// v[6:12] is padding
@@ -117,12 +168,64 @@ func marshalVLANID(vid uint16) ([]byte, error) {
 	return v, nil
 }
 
+func marshalStripVLAN() []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], uint16(OFPAT_STRIP_VLAN))
+	binary.BigEndian.PutUint16(v[2:4], 8)
+	// v[4:8] is padding
+
+	return v
+}
+
+func marshalIP(t uint16, ip net.IP) ([]byte, error) {
+	ipv4 := ip.To4()
+	if ipv4 == nil {
+		return nil, errors.Wrap(openflow.ErrUnsupportedAction, "OpenFlow 1.0 does not support rewriting IPv6 addresses")
+	}
+
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], t)
+	binary.BigEndian.PutUint16(v[2:4], 8)
+	copy(v[4:8], ipv4)
+
+	return v, nil
+}
+
+func marshalL4Port(t uint16, port uint16) []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], t)
+	binary.BigEndian.PutUint16(v[2:4], 8)
+	binary.BigEndian.PutUint16(v[4:6], port)
+	// v[6:8] is padding
+
+	return v
+}
+
 func (r *Action) MarshalBinary() ([]byte, error) {
 	if err := r.Error(); err != nil {
 		return nil, err
 	}
+	if ok, _ := r.NWTTL(); ok || r.DecNWTTL() || r.CopyTTLIn() || r.CopyTTLOut() {
+		return nil, errors.Wrap(openflow.ErrUnsupportedAction, "OpenFlow 1.0 does not support TTL actions")
+	}
+	if ok, _ := r.PushVLAN(); ok {
+		return nil, errors.Wrap(openflow.ErrUnsupportedAction, "OpenFlow 1.0 does not support the push VLAN action")
+	}
+	if ok, _ := r.Group(); ok {
+		return nil, errors.Wrap(openflow.ErrUnsupportedAction, "OpenFlow 1.0 does not support the group action")
+	}
+	pushed, _ := r.PushMPLS()
+	popped, _ := r.PopMPLS()
+	labeled, _ := r.MPLSLabel()
+	if pushed || popped || labeled {
+		return nil, errors.Wrap(openflow.ErrUnsupportedAction, "OpenFlow 1.0 does not support MPLS actions")
+	}
 
 	result := make([]byte, 0)
+	// Strip the VLAN header before setting a new VLAN ID that adds a new header.
+	if r.StripVLAN() || r.PopVLAN() {
+		result = append(result, marshalStripVLAN()...)
+	}
 	if ok, srcMAC := r.SrcMAC(); ok {
 		v, err := marshalMAC(OFPAT_SET_DL_SRC, srcMAC)
 		if err != nil {
@@ -146,6 +249,26 @@ func (r *Action) MarshalBinary() ([]byte, error) {
 		}
 		result = append(result, v...)
 	}
+	if ok, ip := r.IPSrc(); ok {
+		v, err := marshalIP(OFPAT_SET_NW_SRC, ip)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v...)
+	}
+	if ok, ip := r.IPDst(); ok {
+		v, err := marshalIP(OFPAT_SET_NW_DST, ip)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v...)
+	}
+	if ok, port := r.L4SrcPort(); ok {
+		result = append(result, marshalL4Port(OFPAT_SET_TP_SRC, port)...)
+	}
+	if ok, port := r.L4DstPort(); ok {
+		result = append(result, marshalL4Port(OFPAT_SET_TP_DST, port)...)
+	}
 
 	// XXX: Output action should be specified as a last element of this action command.
 	var buf []byte
@@ -161,16 +284,26 @@ func (r *Action) MarshalBinary() ([]byte, error) {
 		return nil, err
 	}
 	result = append(result, buf...)
+	// Additional output ports to replicate the packet.
+	for _, p := range r.OutPorts()[1:] {
+		buf, err := marshalOutPort(p)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, buf...)
+	}
 
 	return result, nil
 }
 
 func (r *Action) UnmarshalBinary(data []byte) error {
 	buf := data
+	hasOutput := false
 	for len(buf) >= 4 {
 		t := binary.BigEndian.Uint16(buf[0:2])
 		length := binary.BigEndian.Uint16(buf[2:4])
-		if len(buf) < int(length) {
+		// Every action is at least 8 bytes, and a zero length would never advance the buffer.
+		if length < 8 || len(buf) < int(length) {
 			return openflow.ErrInvalidPacketLength
 		}
 
@@ -179,9 +312,13 @@ func (r *Action) UnmarshalBinary(data []byte) error {
 			if len(buf) < 8 {
 				return openflow.ErrInvalidPacketLength
 			}
-			outPort := openflow.NewOutPort()
-			outPort.SetValue(uint32(binary.BigEndian.Uint16(buf[4:6])))
-			r.SetOutPort(outPort)
+			outPort := unmarshalOutPort(binary.BigEndian.Uint16(buf[4:6]), binary.BigEndian.Uint16(buf[6:8]))
+			if hasOutput {
+				r.AddOutPort(outPort)
+			} else {
+				r.SetOutPort(outPort)
+				hasOutput = true
+			}
 			if err := r.Error(); err != nil {
 				return err
 			}
@@ -205,9 +342,7 @@ func (r *Action) UnmarshalBinary(data []byte) error {
 			if len(buf) < 16 {
 				return openflow.ErrInvalidPacketLength
 			}
-			outPort := openflow.NewOutPort()
-			outPort.SetValue(uint32(binary.BigEndian.Uint16(buf[4:6])))
-			r.SetOutPort(outPort)
+			r.SetOutPort(unmarshalOutPort(binary.BigEndian.Uint16(buf[4:6]), 0))
 			r.SetQueue(binary.BigEndian.Uint32(buf[12:16]))
 			if err := r.Error(); err != nil {
 				return err
@@ -220,6 +355,28 @@ func (r *Action) UnmarshalBinary(data []byte) error {
 			if err := r.Error(); err != nil {
 				return err
 			}
+		case OFPAT_STRIP_VLAN:
+			r.SetStripVLAN()
+		case OFPAT_SET_NW_SRC, OFPAT_SET_NW_DST:
+			if len(buf) < 8 {
+				return openflow.ErrInvalidPacketLength
+			}
+			ip := net.IPv4(buf[4], buf[5], buf[6], buf[7])
+			if t == OFPAT_SET_NW_SRC {
+				r.SetIPSrc(ip)
+			} else {
+				r.SetIPDst(ip)
+			}
+		case OFPAT_SET_TP_SRC, OFPAT_SET_TP_DST:
+			if len(buf) < 8 {
+				return openflow.ErrInvalidPacketLength
+			}
+			port := binary.BigEndian.Uint16(buf[4:6])
+			if t == OFPAT_SET_TP_SRC {
+				r.SetL4SrcPort(port)
+			} else {
+				r.SetL4DstPort(port)
+			}
 		default:
 			// Do nothing
 		}
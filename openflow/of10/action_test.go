@@ -0,0 +1,435 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of10
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+
+	"github.com/pkg/errors"
+)
+
+func TestUnsupportedTTLAction(t *testing.T) {
+	src := []func(openflow.Action){
+		func(a openflow.Action) { a.SetNWTTL(64) },
+		func(a openflow.Action) { a.SetDecNWTTL() },
+		func(a openflow.Action) { a.SetCopyTTLOut() },
+		func(a openflow.Action) { a.SetCopyTTLIn() },
+	}
+
+	for i, set := range src {
+		action := NewAction()
+		set(action)
+		if _, err := action.MarshalBinary(); errors.Cause(err) != openflow.ErrUnsupportedAction {
+			t.Fatalf("#%v: expected ErrUnsupportedAction, but got %v", i, err)
+		}
+	}
+}
+
+func TestVLANActionEncoding(t *testing.T) {
+	// Output to the controller.
+	output := []byte{0x00, 0x00, 0x00, 0x08, 0xff, 0xfd, 0xff, 0xff}
+
+	src := []struct {
+		Set      func(openflow.Action)
+		Expected []byte
+	}{
+		{
+			Set:      func(a openflow.Action) { a.SetVLANID(100) },
+			Expected: []byte{0x00, 0x01, 0x00, 0x08, 0x00, 0x64, 0x00, 0x00},
+		},
+		{
+			Set:      func(a openflow.Action) { a.SetStripVLAN() },
+			Expected: []byte{0x00, 0x03, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			// The strip action should precede the set VLAN ID action regardless of the setter calls.
+			Set: func(a openflow.Action) {
+				a.SetVLANID(0xFFF)
+				a.SetStripVLAN()
+			},
+			Expected: []byte{
+				0x00, 0x03, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x01, 0x00, 0x08, 0x0f, 0xff, 0x00, 0x00,
+			},
+		},
+	}
+
+	for i, v := range src {
+		action := NewAction()
+		port := openflow.NewOutPort()
+		port.SetController()
+		action.SetOutPort(port)
+		v.Set(action)
+
+		data, err := action.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		expected := append(v.Expected, output...)
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, expected, data)
+		}
+
+		decoded := NewAction()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if decoded.StripVLAN() != action.StripVLAN() {
+			t.Fatalf("#%v: unexpected decoded strip VLAN: expected=%v, got=%v", i, action.StripVLAN(), decoded.StripVLAN())
+		}
+		ok1, vid1 := action.VLANID()
+		ok2, vid2 := decoded.VLANID()
+		if ok1 != ok2 || vid1 != vid2 {
+			t.Fatalf("#%v: unexpected decoded VLAN ID: expected=%v/%v, got=%v/%v", i, ok1, vid1, ok2, vid2)
+		}
+	}
+}
+
+func TestMACActionEncoding(t *testing.T) {
+	// Output to the controller.
+	output := []byte{0x00, 0x00, 0x00, 0x08, 0xff, 0xfd, 0xff, 0xff}
+	src := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	dst := net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb}
+
+	tests := []struct {
+		Set      func(openflow.Action)
+		Expected []byte
+	}{
+		{
+			// OFPAT_SET_DL_SRC followed by 6 bytes of padding.
+			Set:      func(a openflow.Action) { a.SetSrcMAC(src) },
+			Expected: []byte{0x00, 0x04, 0x00, 0x10, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			// OFPAT_SET_DL_DST.
+			Set:      func(a openflow.Action) { a.SetDstMAC(dst) },
+			Expected: []byte{0x00, 0x05, 0x00, 0x10, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			// The source comes first regardless of the setter calls.
+			Set: func(a openflow.Action) {
+				a.SetDstMAC(dst)
+				a.SetSrcMAC(src)
+			},
+			Expected: []byte{
+				0x00, 0x04, 0x00, 0x10, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x05, 0x00, 0x10, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+	}
+
+	for i, v := range tests {
+		action := NewAction()
+		port := openflow.NewOutPort()
+		port.SetController()
+		action.SetOutPort(port)
+		v.Set(action)
+
+		data, err := action.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		expected := append(v.Expected, output...)
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, expected, data)
+		}
+
+		decoded := NewAction()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		// The decoded action should not refer to the message buffer.
+		for j := range data {
+			data[j] = 0
+		}
+		ok1, mac1 := action.SrcMAC()
+		ok2, mac2 := decoded.SrcMAC()
+		if ok1 != ok2 || !bytes.Equal(mac1, mac2) {
+			t.Fatalf("#%v: unexpected decoded source MAC: expected=%v/%v, got=%v/%v", i, ok1, mac1, ok2, mac2)
+		}
+		ok1, mac1 = action.DstMAC()
+		ok2, mac2 = decoded.DstMAC()
+		if ok1 != ok2 || !bytes.Equal(mac1, mac2) {
+			t.Fatalf("#%v: unexpected decoded destination MAC: expected=%v/%v, got=%v/%v", i, ok1, mac1, ok2, mac2)
+		}
+		if out := decoded.OutPort(); !out.IsController() {
+			t.Fatalf("#%v: unexpected decoded output port: %v", i, out)
+		}
+	}
+}
+
+func TestInvalidVLANAction(t *testing.T) {
+	src := []struct {
+		Set      func(openflow.Action)
+		Expected error
+	}{
+		{
+			Set:      func(a openflow.Action) { a.SetVLANID(0x1000) },
+			Expected: openflow.ErrInvalidVLANID,
+		},
+		{
+			Set:      func(a openflow.Action) { a.SetPushVLAN(0x8100) },
+			Expected: openflow.ErrUnsupportedAction,
+		},
+	}
+
+	for i, v := range src {
+		action := NewAction()
+		v.Set(action)
+		if _, err := action.MarshalBinary(); errors.Cause(err) != v.Expected {
+			t.Fatalf("#%v: expected %v, but got %v", i, v.Expected, err)
+		}
+	}
+}
+
+func TestUnsupportedGroupAction(t *testing.T) {
+	action := NewAction()
+	action.SetGroup(1)
+	if _, err := action.MarshalBinary(); errors.Cause(err) != openflow.ErrUnsupportedAction {
+		t.Fatalf("expected ErrUnsupportedAction, but got %v", err)
+	}
+}
+
+func TestControllerOutputEncoding(t *testing.T) {
+	src := []struct {
+		Set      func(*openflow.OutPort)
+		Expected []byte
+		MaxLen   uint16
+	}{
+		// Whole packet by default.
+		{
+			Set:      func(p *openflow.OutPort) { p.SetController() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x08, 0xff, 0xfd, 0xff, 0xff},
+			MaxLen:   0xffff,
+		},
+		// Header-only copies.
+		{
+			Set:      func(p *openflow.OutPort) { p.SetControllerMaxLen(128) },
+			Expected: []byte{0x00, 0x00, 0x00, 0x08, 0xff, 0xfd, 0x00, 0x80},
+			MaxLen:   128,
+		},
+		// Max length is ignored for the other ports.
+		{
+			Set:      func(p *openflow.OutPort) { p.SetControllerMaxLen(128); p.SetValue(3) },
+			Expected: []byte{0x00, 0x00, 0x00, 0x08, 0x00, 0x03, 0xff, 0xff},
+			MaxLen:   0xffff,
+		},
+	}
+
+	for i, v := range src {
+		port := openflow.NewOutPort()
+		v.Set(&port)
+		action := NewAction()
+		action.SetOutPort(port)
+
+		data, err := action.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(data, v.Expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, v.Expected, data)
+		}
+
+		decoded := NewAction()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		out := decoded.OutPort()
+		if out.IsController() != port.IsController() || out.MaxLen() != v.MaxLen {
+			t.Fatalf("#%v: unexpected decoded output port: %v", i, out)
+		}
+	}
+}
+
+func TestSpecialOutputEncoding(t *testing.T) {
+	src := []struct {
+		Set      func(*openflow.OutPort)
+		Expected []byte
+		Is       func(*openflow.OutPort) bool
+	}{
+		{
+			Set:      func(p *openflow.OutPort) { p.SetTable() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x08, 0xff, 0xf9, 0xff, 0xff},
+			Is:       func(p *openflow.OutPort) bool { return p.IsTable() },
+		},
+		{
+			Set:      func(p *openflow.OutPort) { p.SetInPort() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x08, 0xff, 0xf8, 0xff, 0xff},
+			Is:       func(p *openflow.OutPort) bool { return p.IsInPort() },
+		},
+		{
+			Set:      func(p *openflow.OutPort) { p.SetFlood() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x08, 0xff, 0xfb, 0xff, 0xff},
+			Is:       func(p *openflow.OutPort) bool { return p.IsFlood() },
+		},
+		{
+			Set:      func(p *openflow.OutPort) { p.SetAll() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x08, 0xff, 0xfc, 0xff, 0xff},
+			Is:       func(p *openflow.OutPort) bool { return p.IsAll() },
+		},
+		{
+			Set:      func(p *openflow.OutPort) { p.SetNormal() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x08, 0xff, 0xfa, 0xff, 0xff},
+			Is:       func(p *openflow.OutPort) bool { return p.IsNormal() },
+		},
+	}
+
+	for i, v := range src {
+		port := openflow.NewOutPort()
+		v.Set(&port)
+		action := NewAction()
+		action.SetOutPort(port)
+
+		data, err := action.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(data, v.Expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, v.Expected, data)
+		}
+
+		decoded := NewAction()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		out := decoded.OutPort()
+		if !v.Is(&out) {
+			t.Fatalf("#%v: unexpected decoded output port: %v", i, out)
+		}
+	}
+}
+
+func TestReservedOutputPort(t *testing.T) {
+	src := []struct {
+		Port    uint32
+		IsValid bool
+	}{
+		{OFPP_MAX, true},
+		{OFPP_LOCAL, true},
+		// The reserved ports should be set by their own setters.
+		{OFPP_NORMAL, false},
+		{OFPP_CONTROLLER, false},
+		// OpenFlow 1.0 port numbers are 16 bits.
+		{0x10001, false},
+	}
+
+	for i, v := range src {
+		port := openflow.NewOutPort()
+		port.SetValue(v.Port)
+		action := NewAction()
+		action.SetOutPort(port)
+		if _, err := action.MarshalBinary(); (err == nil) != v.IsValid {
+			t.Fatalf("#%v: expected valid=%v, got err=%v", i, v.IsValid, err)
+		}
+	}
+}
+
+func TestEnqueueInPort(t *testing.T) {
+	port := openflow.NewOutPort()
+	port.SetInPort()
+	action := NewAction()
+	action.SetOutPort(port)
+	action.SetQueue(7)
+
+	data, err := action.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []byte{0x00, 0x0b, 0x00, 0x10, 0xff, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("unexpected encoding: expected=%x, got=%x", expected, data)
+	}
+}
+
+func TestUnsupportedMPLSAction(t *testing.T) {
+	src := []func(openflow.Action){
+		func(a openflow.Action) { a.SetPushMPLS(0x8847) },
+		func(a openflow.Action) { a.SetPopMPLS(0x0800) },
+		func(a openflow.Action) { a.SetMPLSLabel(100) },
+	}
+
+	for i, set := range src {
+		action := NewAction()
+		set(action)
+		if _, err := action.MarshalBinary(); errors.Cause(err) != openflow.ErrUnsupportedAction {
+			t.Fatalf("#%v: expected ErrUnsupportedAction, but got %v", i, err)
+		}
+	}
+}
+
+func TestNATActionEncoding(t *testing.T) {
+	// Output to the controller.
+	output := []byte{0x00, 0x00, 0x00, 0x08, 0xff, 0xfd, 0xff, 0xff}
+
+	action := NewAction()
+	port := openflow.NewOutPort()
+	port.SetController()
+	action.SetOutPort(port)
+	// The setter calls are in the reverse order of the encoding.
+	action.SetL4DstPort(80)
+	action.SetL4SrcPort(8080)
+	action.SetIPDst(net.ParseIP("10.0.0.2"))
+	action.SetIPSrc(net.ParseIP("10.0.0.1"))
+
+	data, err := action.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := append([]byte{
+		0x00, 0x06, 0x00, 0x08, 0x0a, 0x00, 0x00, 0x01,
+		0x00, 0x07, 0x00, 0x08, 0x0a, 0x00, 0x00, 0x02,
+		0x00, 0x09, 0x00, 0x08, 0x1f, 0x90, 0x00, 0x00,
+		0x00, 0x0a, 0x00, 0x08, 0x00, 0x50, 0x00, 0x00,
+	}, output...)
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("unexpected encoding: expected=%x, got=%x", expected, data)
+	}
+
+	decoded := NewAction()
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if ok, ip := decoded.IPSrc(); !ok || !ip.Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("unexpected decoded source IP: %v/%v", ok, ip)
+	}
+	if ok, ip := decoded.IPDst(); !ok || !ip.Equal(net.ParseIP("10.0.0.2")) {
+		t.Fatalf("unexpected decoded destination IP: %v/%v", ok, ip)
+	}
+	if ok, port := decoded.L4SrcPort(); !ok || port != 8080 {
+		t.Fatalf("unexpected decoded source port: %v/%v", ok, port)
+	}
+	if ok, port := decoded.L4DstPort(); !ok || port != 80 {
+		t.Fatalf("unexpected decoded destination port: %v/%v", ok, port)
+	}
+}
+
+func TestUnsupportedIPv6Rewrite(t *testing.T) {
+	action := NewAction()
+	action.SetIPSrc(net.ParseIP("2001:db8::1"))
+	if _, err := action.MarshalBinary(); errors.Cause(err) != openflow.ErrUnsupportedAction {
+		t.Fatalf("expected ErrUnsupportedAction, but got %v", err)
+	}
+}
@@ -35,7 +35,8 @@ type Config struct {
 }
 
 func (r *Config) Flags() openflow.ConfigFlag {
-	switch r.flags {
+	// Ignore the other flags that are not related with the fragment handling.
+	switch r.flags & OFPC_FRAG_MASK {
 	case OFPC_FRAG_NORMAL:
 		return openflow.FragNormal
 	case OFPC_FRAG_DROP:
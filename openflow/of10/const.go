@@ -66,9 +66,11 @@ const (
 	OFPP_MAX        = 0xff00
 	OFPP_IN_PORT    = 0xfff8
 	OFPP_TABLE      = 0xfff9
+	OFPP_NORMAL     = 0xfffa
 	OFPP_FLOOD      = 0xfffb
 	OFPP_ALL        = 0xfffc
 	OFPP_CONTROLLER = 0xfffd
+	OFPP_LOCAL      = 0xfffe
 	OFPP_NONE       = 0xffff
 )
 
@@ -169,6 +171,10 @@ const (
 	OFPST_VENDOR = 0xffff
 )
 
+const (
+	OFPSF_REPLY_MORE = 1 << 0 /* More replies to follow. */
+)
+
 const (
 	OFPC_FRAG_NORMAL = iota /* No special handling for fragments. */
 	OFPC_FRAG_DROP          /* Drop fragments. */
@@ -181,3 +187,21 @@ const (
 	OFPPR_DELETE = 1
 	OFPPR_MODIFY = 2
 )
+
+const (
+	OFPET_HELLO_FAILED    = iota /* Hello protocol failed. */
+	OFPET_BAD_REQUEST            /* Request was not understood. */
+	OFPET_BAD_ACTION             /* Error in action description. */
+	OFPET_FLOW_MOD_FAILED        /* Problem modifying flow entry. */
+	OFPET_PORT_MOD_FAILED        /* Port mod request failed. */
+	OFPET_QUEUE_OP_FAILED        /* Queue operation failed. */
+)
+
+const (
+	OFPFMFC_ALL_TABLES_FULL   = iota /* Flow not added because of full tables. */
+	OFPFMFC_OVERLAP                  /* Attempted to add overlapping flow with CHECK_OVERLAP flag set. */
+	OFPFMFC_EPERM                    /* Permissions error. */
+	OFPFMFC_BAD_EMERG_TIMEOUT        /* Flow not added because of non-zero idle/hard timeout. */
+	OFPFMFC_BAD_COMMAND              /* Unknown command. */
+	OFPFMFC_UNSUPPORTED              /* Unsupported action list - cannot process in the order specified. */
+)
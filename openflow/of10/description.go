@@ -23,7 +23,6 @@ package of10
 
 import (
 	"encoding/binary"
-	"strings"
 
 	"github.com/superkkt/cherry/openflow"
 )
@@ -86,11 +85,11 @@ func (r *DescReply) UnmarshalBinary(data []byte) error {
 		return openflow.ErrInvalidPacketLength
 	}
 	// payload[0:4] is type and flag of ofp_stats_reply
-	r.manufacturer = strings.TrimRight(string(payload[4:260]), "\x00")
-	r.hardware = strings.TrimRight(string(payload[260:516]), "\x00")
-	r.software = strings.TrimRight(string(payload[516:772]), "\x00")
-	r.serial = strings.TrimRight(string(payload[772:804]), "\x00")
-	r.description = strings.TrimRight(string(payload[804:1060]), "\x00")
+	r.manufacturer = openflow.ParseDescString(payload[4:260])
+	r.hardware = openflow.ParseDescString(payload[260:516])
+	r.software = openflow.ParseDescString(payload[516:772])
+	r.serial = openflow.ParseDescString(payload[772:804])
+	r.description = openflow.ParseDescString(payload[804:1060])
 
 	return nil
 }
@@ -115,10 +115,18 @@ func (r *Factory) NewFlowMod(cmd openflow.FlowModCmd) (openflow.FlowMod, error)
 	return NewFlowMod(r.getTransactionID(), getFlowModCmd(cmd)), nil
 }
 
+func (r *Factory) NewGroupMod(cmd openflow.GroupCommand) (openflow.GroupMod, error) {
+	return nil, errors.New("of10 does not support GroupMod")
+}
+
 func (r *Factory) NewFlowRemoved() (openflow.FlowRemoved, error) {
 	return new(FlowRemoved), nil
 }
 
+func (r *Factory) NewMeterMod(cmd openflow.MeterCommand) (openflow.MeterMod, error) {
+	return nil, openflow.ErrUnsupportedMessage
+}
+
 func (r *Factory) NewPacketIn() (openflow.PacketIn, error) {
 	return new(PacketIn), nil
 }
@@ -143,7 +151,17 @@ func (r *Factory) NewFlowStatsRequest() (openflow.FlowStatsRequest, error) {
 	return NewFlowStatsRequest(r.getTransactionID()), nil
 }
 
-// TODO: NewFlowStatsReply() (openflow.FlowStatsReply, error) {
+func (r *Factory) NewFlowStatsReply() (openflow.FlowStatsReply, error) {
+	return new(FlowStatsReply), nil
+}
+
+func (r *Factory) NewPortStatsRequest() (openflow.PortStatsRequest, error) {
+	return NewPortStatsRequest(r.getTransactionID()), nil
+}
+
+func (r *Factory) NewPortStatsReply() (openflow.PortStatsReply, error) {
+	return new(PortStatsReply), nil
+}
 
 func (r *Factory) NewPortDescRequest() (openflow.PortDescRequest, error) {
 	return nil, errors.New("of10 does not support PortDescRequest")
@@ -153,10 +171,26 @@ func (r *Factory) NewPortDescReply() (openflow.PortDescReply, error) {
 	return nil, errors.New("of10 does not support PortDescReply")
 }
 
+func (r *Factory) NewPortMod() (openflow.PortMod, error) {
+	return NewPortMod(r.getTransactionID()), nil
+}
+
 func (r *Factory) NewTableFeaturesRequest() (openflow.TableFeaturesRequest, error) {
 	return nil, errors.New("of10 does not support TableFeaturesRequest")
 }
 
+func (r *Factory) NewTableMod() (openflow.TableMod, error) {
+	return nil, errors.New("of10 does not support TableMod")
+}
+
+func (r *Factory) NewTableStatsRequest() (openflow.TableStatsRequest, error) {
+	return NewTableStatsRequest(r.getTransactionID()), nil
+}
+
+func (r *Factory) NewTableStatsReply() (openflow.TableStatsReply, error) {
+	return new(TableStatsReply), nil
+}
+
 func (r *Factory) NewError() (openflow.Error, error) {
 	return new(openflow.BaseError), nil
 }
@@ -167,6 +201,23 @@ func (r *Factory) NewInstruction() (openflow.Instruction, error) {
 	return new(Instruction), nil
 }
 
+func (r *Factory) NewGotoTableInstruction(tableID uint8) (openflow.Instruction, error) {
+	return nil, errors.New("of10 does not support GotoTable")
+}
+
 func (r *Factory) NewQueueGetConfigRequest() (openflow.QueueGetConfigRequest, error) {
 	return NewQueueGetConfigRequest(r.getTransactionID()), nil
 }
+
+func (r *Factory) NewQueueGetConfigReply() (openflow.QueueGetConfigReply, error) {
+	return new(QueueGetConfigReply), nil
+}
+
+// NewRoleRequest is not supported because OpenFlow 1.0 has the controller role only as a Nicira extension.
+func (r *Factory) NewRoleRequest() (openflow.RoleRequest, error) {
+	return nil, openflow.ErrUnsupportedMessage
+}
+
+func (r *Factory) NewRoleReply() (openflow.RoleReply, error) {
+	return nil, openflow.ErrUnsupportedMessage
+}
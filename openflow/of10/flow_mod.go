@@ -39,6 +39,7 @@ type FlowMod struct {
 	match       openflow.Match
 	instruction openflow.Instruction
 	outPort     openflow.OutPort
+	flags       openflow.FlowModFlags
 }
 
 func NewFlowMod(xid uint32, cmd uint16) openflow.FlowMod {
@@ -50,6 +51,7 @@ func NewFlowMod(xid uint32, cmd uint16) openflow.FlowMod {
 		Message: openflow.NewMessage(openflow.OF10_VERSION, OFPT_FLOW_MOD, xid),
 		command: cmd,
 		outPort: outPort,
+		flags:   openflow.DefaultFlowModFlags,
 	}
 }
 
@@ -57,6 +59,17 @@ func (r *FlowMod) Error() error {
 	return r.err
 }
 
+func (r *FlowMod) Command() openflow.FlowModCmd {
+	switch r.command {
+	case OFPFC_MODIFY, OFPFC_MODIFY_STRICT:
+		return openflow.FlowModify
+	case OFPFC_DELETE, OFPFC_DELETE_STRICT:
+		return openflow.FlowDelete
+	default:
+		return openflow.FlowAdd
+	}
+}
+
 func (r *FlowMod) Cookie() uint64 {
 	return r.cookie
 }
@@ -129,6 +142,18 @@ func (r *FlowMod) SetFlowInstruction(inst openflow.Instruction) {
 	r.instruction = inst
 }
 
+func (r *FlowMod) Flags() openflow.FlowModFlags {
+	return r.flags
+}
+
+func (r *FlowMod) SetFlags(flags openflow.FlowModFlags) {
+	// OpenFlow 1.0 does not have these flags
+	flags.ResetCounts = false
+	flags.NoPacketCounts = false
+	flags.NoByteCounts = false
+	r.flags = flags
+}
+
 func (r *FlowMod) OutPort() openflow.OutPort {
 	return r.outPort
 }
@@ -137,6 +162,18 @@ func (r *FlowMod) SetOutPort(p openflow.OutPort) {
 	r.outPort = p
 }
 
+func (r *FlowMod) marshalFlags() uint16 {
+	var v uint16
+	if r.flags.SendFlowRemoved {
+		v |= OFPFF_SEND_FLOW_REM
+	}
+	if r.flags.CheckOverlap {
+		v |= OFPFF_CHECK_OVERLAP
+	}
+
+	return v
+}
+
 func (r *FlowMod) MarshalBinary() ([]byte, error) {
 	if r.err != nil {
 		return nil, r.err
@@ -154,12 +191,17 @@ func (r *FlowMod) MarshalBinary() ([]byte, error) {
 	} else {
 		binary.BigEndian.PutUint16(v[20:22], uint16(r.outPort.Value()))
 	}
-	binary.BigEndian.PutUint16(v[22:24], OFPFF_SEND_FLOW_REM)
+	binary.BigEndian.PutUint16(v[22:24], r.marshalFlags())
 
-	if r.match == nil {
-		return nil, errors.New("empty flow match")
+	match := r.match
+	if match == nil {
+		if r.Command() != openflow.FlowDelete {
+			return nil, errors.New("empty flow match")
+		}
+		// A delete request without a match removes all the flows.
+		match = NewMatch()
 	}
-	result, err := r.match.MarshalBinary()
+	result, err := match.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
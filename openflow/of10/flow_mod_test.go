@@ -0,0 +1,99 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of10
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+func TestFlowModFlags(t *testing.T) {
+	src := []struct {
+		Flags    openflow.FlowModFlags
+		Expected uint16
+	}{
+		{Flags: openflow.DefaultFlowModFlags, Expected: OFPFF_SEND_FLOW_REM},
+		{Flags: openflow.FlowModFlags{}, Expected: 0},
+		{Flags: openflow.FlowModFlags{SendFlowRemoved: true, CheckOverlap: true}, Expected: OFPFF_SEND_FLOW_REM | OFPFF_CHECK_OVERLAP},
+		// OpenFlow 1.0 ignores the flags of OpenFlow 1.3.
+		{Flags: openflow.FlowModFlags{CheckOverlap: true, ResetCounts: true}, Expected: OFPFF_CHECK_OVERLAP},
+		{Flags: openflow.FlowModFlags{NoPacketCounts: true, NoByteCounts: true}, Expected: 0},
+	}
+
+	for i, v := range src {
+		flow := NewFlowMod(1, OFPFC_ADD)
+		flow.SetFlowMatch(NewMatch())
+		flow.SetFlags(v.Flags)
+		packet, err := flow.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal: %v", i, err)
+		}
+		if got := binary.BigEndian.Uint16(packet[70:72]); got != v.Expected {
+			t.Fatalf("#%v: unexpected flags: expected=0x%X, got=0x%X", i, v.Expected, got)
+		}
+		// The unsupported flags are not reported.
+		if flags := flow.Flags(); flags.ResetCounts || flags.NoPacketCounts || flags.NoByteCounts {
+			t.Fatalf("#%v: unexpected flags: %+v", i, flags)
+		}
+	}
+}
+
+func TestFlowModValidation(t *testing.T) {
+	ip := &net.IPNet{IP: net.IPv4(10, 0, 0, 1), Mask: net.CIDRMask(32, 32)}
+	src := []struct {
+		Command openflow.FlowModCmd
+		Match   func(openflow.Match)
+		Valid   bool
+	}{
+		// A delete request without a match removes all the flows.
+		{Command: openflow.FlowDelete, Match: nil, Valid: true},
+		{Command: openflow.FlowAdd, Match: nil, Valid: false},
+		// OpenFlow 1.0 matches the ARP sender and target IP addresses, and the ARP opcode.
+		{Command: openflow.FlowAdd, Match: func(m openflow.Match) { m.SetEtherType(0x0806); m.SetSrcIP(ip); m.SetDstIP(ip) }, Valid: true},
+		{Command: openflow.FlowAdd, Match: func(m openflow.Match) { m.SetEtherType(0x0806); m.SetIPProtocol(1) }, Valid: true},
+		{Command: openflow.FlowAdd, Match: func(m openflow.Match) { m.SetEtherType(0x0800); m.SetDstIP(ip) }, Valid: true},
+		// IPv4 addresses with the IPv6 ethernet type.
+		{Command: openflow.FlowAdd, Match: func(m openflow.Match) { m.SetEtherType(0x86DD); m.SetDstIP(ip) }, Valid: false},
+		{Command: openflow.FlowAdd, Match: func(m openflow.Match) { m.SetDstIP(ip) }, Valid: false},
+	}
+
+	for i, v := range src {
+		flow := NewFlowMod(1, OFPFC_ADD)
+		if v.Command == openflow.FlowDelete {
+			flow = NewFlowMod(1, OFPFC_DELETE)
+		}
+		if v.Match != nil {
+			match := NewMatch()
+			v.Match(match)
+			flow.SetFlowMatch(match)
+		}
+		if err := openflow.ValidateFlowMod(flow); (err == nil) != v.Valid {
+			t.Fatalf("#%v: expected valid=%v, got err=%v", i, v.Valid, err)
+		}
+		if _, err := flow.MarshalBinary(); v.Valid && err != nil {
+			t.Fatalf("#%v: unexpected marshal error: %v", i, err)
+		}
+	}
+}
@@ -109,4 +109,74 @@ func (r *FlowStatsRequest) MarshalBinary() ([]byte, error) {
 	return r.Message.MarshalBinary()
 }
 
-// TODO: Implement FlowStatsReply
+type FlowStatsReply struct {
+	openflow.Message
+	more  bool
+	stats []openflow.FlowStat
+}
+
+func (r FlowStatsReply) More() bool {
+	return r.more
+}
+
+func (r FlowStatsReply) Stats() []openflow.FlowStat {
+	return r.stats
+}
+
+func (r *FlowStatsReply) UnmarshalBinary(data []byte) error {
+	if err := r.Message.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	payload := r.Payload()
+	if payload == nil || len(payload) < 4 {
+		return openflow.ErrInvalidPacketLength
+	}
+	// payload[0:2] is type of ofp_stats_reply
+	r.more = binary.BigEndian.Uint16(payload[2:4])&OFPSF_REPLY_MORE != 0
+	r.stats = make([]openflow.FlowStat, 0)
+
+	buf := payload[4:]
+	for len(buf) >= 88 {
+		length := int(binary.BigEndian.Uint16(buf[0:2]))
+		if length < 88 || len(buf) < length {
+			return openflow.ErrInvalidPacketLength
+		}
+
+		match := NewMatch()
+		if err := match.UnmarshalBinary(buf[4:44]); err != nil {
+			return err
+		}
+		r.stats = append(r.stats, openflow.FlowStat{
+			TableID:         buf[2],
+			Match:           match,
+			DurationSec:     binary.BigEndian.Uint32(buf[44:48]),
+			DurationNanoSec: binary.BigEndian.Uint32(buf[48:52]),
+			Priority:        binary.BigEndian.Uint16(buf[52:54]),
+			IdleTimeout:     binary.BigEndian.Uint16(buf[54:56]),
+			HardTimeout:     binary.BigEndian.Uint16(buf[56:58]),
+			// buf[58:64] is padding
+			Cookie:      binary.BigEndian.Uint64(buf[64:72]),
+			PacketCount: binary.BigEndian.Uint64(buf[72:80]),
+			ByteCount:   binary.BigEndian.Uint64(buf[80:88]),
+			Actions:     unmarshalFlowActions(buf[88:length]),
+		})
+		buf = buf[length:]
+	}
+
+	return nil
+}
+
+// unmarshalFlowActions decodes the actions of a flow. It returns nil if there is no action or they cannot be
+// decoded.
+func unmarshalFlowActions(data []byte) openflow.Action {
+	if len(data) == 0 {
+		return nil
+	}
+	action := NewAction()
+	if err := action.UnmarshalBinary(data); err != nil {
+		return nil
+	}
+
+	return action
+}
@@ -0,0 +1,117 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of10
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+func newFlowStatsEntry(t *testing.T, v openflow.FlowStat) []byte {
+	match := NewMatch()
+	match.SetEtherType(0x0800)
+	m, err := match.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal the match: %v", err)
+	}
+	// An output action to port 1 that follows the fixed fields.
+	action := []byte{0x00, 0x00, 0x00, 0x08, 0x00, 0x01, 0xff, 0xff}
+
+	entry := make([]byte, 88)
+	binary.BigEndian.PutUint16(entry[0:2], uint16(len(entry)+len(action)))
+	entry[2] = v.TableID
+	copy(entry[4:44], m)
+	binary.BigEndian.PutUint32(entry[44:48], v.DurationSec)
+	binary.BigEndian.PutUint32(entry[48:52], v.DurationNanoSec)
+	binary.BigEndian.PutUint16(entry[52:54], v.Priority)
+	binary.BigEndian.PutUint16(entry[54:56], v.IdleTimeout)
+	binary.BigEndian.PutUint16(entry[56:58], v.HardTimeout)
+	binary.BigEndian.PutUint64(entry[64:72], v.Cookie)
+	binary.BigEndian.PutUint64(entry[72:80], v.PacketCount)
+	binary.BigEndian.PutUint64(entry[80:88], v.ByteCount)
+
+	return append(entry, action...)
+}
+
+func TestFlowStatsReply(t *testing.T) {
+	src := []struct {
+		Flags uint16
+		Stats []openflow.FlowStat
+	}{
+		{
+			Flags: 0,
+			Stats: []openflow.FlowStat{},
+		},
+		{
+			Flags: OFPSF_REPLY_MORE,
+			Stats: []openflow.FlowStat{
+				{TableID: 0, Priority: 10, DurationSec: 30, DurationNanoSec: 500, IdleTimeout: 90, PacketCount: 1000, ByteCount: 64000},
+				{TableID: 1, Priority: 100, Cookie: 7, DurationSec: 1, HardTimeout: 60, PacketCount: 1, ByteCount: 1500},
+			},
+		},
+	}
+
+	for i, v := range src {
+		body := []byte{0x00, 0x01, byte(v.Flags >> 8), byte(v.Flags)}
+		for _, s := range v.Stats {
+			body = append(body, newFlowStatsEntry(t, s)...)
+		}
+		msg := openflow.NewMessage(openflow.OF10_VERSION, OFPT_STATS_REPLY, 1)
+		msg.SetPayload(body)
+		packet, err := msg.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+
+		reply := new(FlowStatsReply)
+		if err := reply.UnmarshalBinary(packet); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if reply.More() != (v.Flags&OFPSF_REPLY_MORE != 0) {
+			t.Fatalf("#%v: unexpected more flag: %v", i, reply.More())
+		}
+		if len(reply.Stats()) != len(v.Stats) {
+			t.Fatalf("#%v: unexpected number of stats: expected=%v, got=%v", i, len(v.Stats), len(reply.Stats()))
+		}
+		for j, s := range reply.Stats() {
+			if s.Match == nil {
+				t.Fatalf("#%v-%v: missing match", i, j)
+			}
+			if wildcard, etherType := s.Match.EtherType(); wildcard || etherType != 0x0800 {
+				t.Fatalf("#%v-%v: unexpected match: wildcard=%v, etherType=%v", i, j, wildcard, etherType)
+			}
+			if s.Actions == nil {
+				t.Fatalf("#%v-%v: missing actions", i, j)
+			}
+			if out := s.Actions.OutPort(); out.Value() != 1 {
+				t.Fatalf("#%v-%v: unexpected output port: %v", i, j, out)
+			}
+			s.Match = nil
+			s.Actions = nil
+			if s != v.Stats[j] {
+				t.Fatalf("#%v-%v: unexpected stats: expected=%+v, got=%+v", i, j, v.Stats[j], s)
+			}
+		}
+	}
+}
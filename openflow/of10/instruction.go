@@ -54,6 +54,33 @@ func (r *Instruction) ApplyAction(act openflow.Action) {
 	r.action = act
 }
 
+func (r *Instruction) Action() openflow.Action {
+	return r.action
+}
+
+func (r *Instruction) GotoTableID() (ok bool, tableID uint8) {
+	// OpenFlow 1.0 does not support GotoTable
+	return false, 0
+}
+
+func (r *Instruction) Meter() uint32 {
+	// OpenFlow 1.0 does not support meters
+	return 0
+}
+
+func (r *Instruction) SetMeter(meterID uint32) {
+	r.err = openflow.ErrUnsupportedMessage
+}
+
+func (r *Instruction) Metadata() (ok bool, value, mask uint64) {
+	// OpenFlow 1.0 does not support metadata
+	return false, 0, 0
+}
+
+func (r *Instruction) WriteMetadata(value, mask uint64) {
+	r.err = openflow.ErrUnsupportedMessage
+}
+
 func (r *Instruction) MarshalBinary() ([]byte, error) {
 	if r.err != nil {
 		return nil, r.err
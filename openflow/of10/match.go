@@ -23,6 +23,7 @@ package of10
 
 import (
 	"encoding/binary"
+	"fmt"
 	"net"
 
 	"github.com/superkkt/cherry/openflow"
@@ -45,6 +46,7 @@ type Wildcard struct {
 	SrcIP        uint8
 	DstIP        uint8
 	VLANPriority bool /* VLAN priority. */
+	TOS          bool /* IP ToS (DSCP field, 6 bits). */
 }
 
 func newWildcardAll() *Wildcard {
@@ -60,6 +62,7 @@ func newWildcardAll() *Wildcard {
 		SrcIP:        32,
 		DstIP:        32,
 		VLANPriority: true,
+		TOS:          true,
 	}
 }
 
@@ -104,8 +107,9 @@ func (r *Wildcard) MarshalBinary() ([]byte, error) {
 	if r.VLANPriority {
 		v = v | OFPFW_DL_VLAN_PCP
 	}
-	// ToS is always wildcarded
-	v = v | OFPFW_NW_TOS
+	if r.TOS {
+		v = v | OFPFW_NW_TOS
+	}
 
 	data := make([]byte, 4)
 	binary.BigEndian.PutUint32(data[0:4], v)
@@ -148,6 +152,9 @@ func (r *Wildcard) UnmarshalBinary(data []byte) error {
 	if w&OFPFW_DL_VLAN_PCP != 0 {
 		r.VLANPriority = true
 	}
+	if w&OFPFW_NW_TOS != 0 {
+		r.TOS = true
+	}
 
 	return nil
 }
@@ -161,6 +168,7 @@ type Match struct {
 	vlanID       uint16
 	vlanPriority uint8
 	etherType    uint16
+	dscp         uint8 // DSCP of the IP ToS field. OpenFlow 1.0 cannot match the ECN bits.
 	protocol     uint8
 	srcIP        net.IP
 	dstIP        net.IP
@@ -261,14 +269,50 @@ func (r *Match) VLANPriority() (wildcard bool, priority uint8) {
 	return r.wildcards.VLANPriority, r.vlanPriority
 }
 
+func (r *Match) SetWildcardIPDSCP() {
+	r.dscp = 0
+	r.wildcards.TOS = true
+}
+
+func (r *Match) SetIPDSCP(dscp uint8) {
+	if dscp > 0x3F {
+		r.err = fmt.Errorf("SetIPDSCP: DSCP %v exceeds 6 bits", dscp)
+		return
+	}
+	// IPv4?
+	if r.etherType != 0x0800 {
+		r.err = errors.Wrap(openflow.ErrUnsupportedEtherType, "SetIPDSCP")
+		return
+	}
+
+	r.dscp = dscp
+	r.wildcards.TOS = false
+}
+
+func (r *Match) IPDSCP() (wildcard bool, dscp uint8) {
+	return r.wildcards.TOS, r.dscp
+}
+
+func (r *Match) SetWildcardIPECN() {
+	// Always wildcarded.
+}
+
+func (r *Match) SetIPECN(ecn uint8) {
+	r.err = errors.Wrap(openflow.ErrUnsupportedMatchType, "OpenFlow 1.0 does not support IP ECN match: SetIPECN")
+}
+
+func (r *Match) IPECN() (wildcard bool, ecn uint8) {
+	return true, 0
+}
+
 func (r *Match) SetWildcardIPProtocol() {
 	r.protocol = 0
 	r.wildcards.Protocol = true
 }
 
 func (r *Match) SetIPProtocol(p uint8) {
-	// IPv4?
-	if r.etherType != 0x0800 {
+	// IPv4 or ARP? OpenFlow 1.0 matches the ARP sender and target IP addresses, and the ARP opcode using the IP fields.
+	if r.etherType != 0x0800 && r.etherType != 0x0806 {
 		r.err = errors.Wrap(openflow.ErrUnsupportedEtherType, "SetIPProtocol")
 		return
 	}
@@ -343,8 +387,8 @@ func (r *Match) SetSrcIP(ip *net.IPNet) {
 		r.err = errors.Wrap(openflow.ErrInvalidIPAddress, "SetSrcIP")
 		return
 	}
-	// IPv4?
-	if r.etherType != 0x0800 {
+	// IPv4 or ARP? OpenFlow 1.0 matches the ARP sender and target IP addresses, and the ARP opcode using the IP fields.
+	if r.etherType != 0x0800 && r.etherType != 0x0806 {
 		r.err = errors.Wrap(openflow.ErrUnsupportedEtherType, "SetSrcIP")
 		return
 	}
@@ -370,6 +414,76 @@ func (r *Match) SrcIP() *net.IPNet {
 	}
 }
 
+func (r *Match) SetIPv6Src(ip *net.IPNet) {
+	r.err = errors.Wrap(openflow.ErrUnsupportedMatchType, "OpenFlow 1.0 does not support IPv6 match: SetIPv6Src")
+}
+
+func (r *Match) IPv6Src() *net.IPNet {
+	return &net.IPNet{
+		IP:   net.IPv6zero,
+		Mask: net.CIDRMask(0, 128),
+	}
+}
+
+func (r *Match) SetIPv6Dst(ip *net.IPNet) {
+	r.err = errors.Wrap(openflow.ErrUnsupportedMatchType, "OpenFlow 1.0 does not support IPv6 match: SetIPv6Dst")
+}
+
+func (r *Match) IPv6Dst() *net.IPNet {
+	return &net.IPNet{
+		IP:   net.IPv6zero,
+		Mask: net.CIDRMask(0, 128),
+	}
+}
+
+func (r *Match) SetIPv6FlowLabel(label uint32) {
+	r.err = errors.Wrap(openflow.ErrUnsupportedMatchType, "OpenFlow 1.0 does not support IPv6 match: SetIPv6FlowLabel")
+}
+
+func (r *Match) IPv6FlowLabel() (wildcard bool, label uint32) {
+	return true, 0
+}
+
+func (r *Match) SetMPLSLabel(label uint32) {
+	r.err = errors.Wrap(openflow.ErrUnsupportedMatchType, "OpenFlow 1.0 does not support MPLS match: SetMPLSLabel")
+}
+
+func (r *Match) MPLSLabel() (wildcard bool, label uint32) {
+	return true, 0
+}
+
+func (r *Match) SetMetadata(value, mask uint64) {
+	r.err = errors.Wrap(openflow.ErrUnsupportedMatchType, "OpenFlow 1.0 does not support metadata match: SetMetadata")
+}
+
+func (r *Match) SetWildcardMetadata() {
+	// Always wildcard
+}
+
+func (r *Match) Metadata() (wildcard bool, value, mask uint64) {
+	return true, 0, 0
+}
+
+func (r *Match) SetPhysicalInPort(port uint32) {
+	r.err = errors.Wrap(openflow.ErrUnsupportedMatchType, "OpenFlow 1.0 does not support physical input port match: SetPhysicalInPort")
+}
+
+func (r *Match) SetWildcardPhysicalInPort() {
+	// Always wildcard
+}
+
+func (r *Match) PhysicalInPort() (wildcard bool, port uint32) {
+	return true, 0
+}
+
+func (r *Match) SetMPLSBOS(bos bool) {
+	r.err = errors.Wrap(openflow.ErrUnsupportedMatchType, "OpenFlow 1.0 does not support MPLS match: SetMPLSBOS")
+}
+
+func (r *Match) MPLSBOS() (wildcard bool, bos bool) {
+	return true, false
+}
+
 func (r *Match) SetDstIP(ip *net.IPNet) {
 	if ip == nil {
 		panic("ip is nil")
@@ -378,8 +492,8 @@ func (r *Match) SetDstIP(ip *net.IPNet) {
 		r.err = errors.Wrap(openflow.ErrInvalidIPAddress, "SetDstIP")
 		return
 	}
-	// IPv4?
-	if r.etherType != 0x0800 {
+	// IPv4 or ARP? OpenFlow 1.0 matches the ARP sender and target IP addresses, and the ARP opcode using the IP fields.
+	if r.etherType != 0x0800 && r.etherType != 0x0806 {
 		r.err = errors.Wrap(openflow.ErrUnsupportedEtherType, "SetDstIP")
 		return
 	}
@@ -451,6 +565,8 @@ func (r *Match) MarshalBinary() ([]byte, error) {
 	data[20] = r.vlanPriority
 	// data[21] = padding
 	binary.BigEndian.PutUint16(data[22:24], r.etherType)
+	// nw_tos is the ToS byte whose lower 2 bits (ECN) should be zero.
+	data[24] = r.dscp << 2
 	data[25] = r.protocol
 	// data[26:28] = padding
 	srcIP := r.srcIP.To4()
@@ -487,6 +603,7 @@ func (r *Match) UnmarshalBinary(data []byte) error {
 	r.vlanPriority = data[20]
 	// data[21] = padding
 	r.etherType = binary.BigEndian.Uint16(data[22:24])
+	r.dscp = data[24] >> 2
 	r.protocol = data[25]
 	// data[26:28] = padding
 	r.srcIP = net.IPv4(data[28], data[29], data[30], data[31])
@@ -496,3 +613,7 @@ func (r *Match) UnmarshalBinary(data []byte) error {
 
 	return nil
 }
+
+func (r *Match) Fields() []openflow.MatchField {
+	return openflow.MatchFields(r)
+}
@@ -0,0 +1,184 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of10
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+
+	"github.com/pkg/errors"
+)
+
+func TestMatchTOS(t *testing.T) {
+	match := NewMatch()
+	match.SetEtherType(0x0800)
+	match.SetIPDSCP(46)
+	if err := match.Error(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := match.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	// nw_tos carries DSCP in its upper 6 bits.
+	if data[24] != 46<<2 {
+		t.Fatalf("unexpected nw_tos: expected=%x, got=%x", 46<<2, data[24])
+	}
+	if binary.BigEndian.Uint32(data[0:4])&OFPFW_NW_TOS != 0 {
+		t.Fatalf("nw_tos should not be wildcarded: %x", data[0:4])
+	}
+
+	decoded := NewMatch()
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if wildcard, dscp := decoded.IPDSCP(); wildcard || dscp != 46 {
+		t.Fatalf("unexpected decoded DSCP: wildcard=%v, dscp=%v", wildcard, dscp)
+	}
+
+	decoded.SetWildcardIPDSCP()
+	data, err = decoded.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if data[24] != 0 || binary.BigEndian.Uint32(data[0:4])&OFPFW_NW_TOS == 0 {
+		t.Fatalf("nw_tos should be wildcarded: wildcards=%x, nw_tos=%x", data[0:4], data[24])
+	}
+}
+
+func TestInvalidMatchTOS(t *testing.T) {
+	src := []struct {
+		EtherType uint16
+		Set       func(openflow.Match)
+		Expected  error
+	}{
+		{
+			EtherType: 0x0806,
+			Set:       func(m openflow.Match) { m.SetIPDSCP(1) },
+			Expected:  openflow.ErrUnsupportedEtherType,
+		},
+		{
+			EtherType: 0x0800,
+			Set:       func(m openflow.Match) { m.SetIPECN(1) },
+			Expected:  openflow.ErrUnsupportedMatchType,
+		},
+		// Out of range.
+		{
+			EtherType: 0x0800,
+			Set:       func(m openflow.Match) { m.SetIPDSCP(0x40) },
+		},
+	}
+
+	for i, v := range src {
+		match := NewMatch()
+		match.SetEtherType(v.EtherType)
+		v.Set(match)
+		if match.Error() == nil {
+			t.Fatalf("#%v: expected an error", i)
+		}
+		if v.Expected != nil && errors.Cause(match.Error()) != v.Expected {
+			t.Fatalf("#%v: expected %v, but got %v", i, v.Expected, match.Error())
+		}
+	}
+}
+
+func TestUnsupportedMetadata(t *testing.T) {
+	match := NewMatch()
+	match.SetMetadata(1, 0xff)
+	if errors.Cause(match.Error()) != openflow.ErrUnsupportedMatchType {
+		t.Fatalf("expected %v, but got %v", openflow.ErrUnsupportedMatchType, match.Error())
+	}
+
+	inst := new(Instruction)
+	inst.WriteMetadata(1, 0xff)
+	if inst.Error() != openflow.ErrUnsupportedMessage {
+		t.Fatalf("expected %v, but got %v", openflow.ErrUnsupportedMessage, inst.Error())
+	}
+	if ok, _, _ := inst.Metadata(); ok {
+		t.Fatal("unexpected metadata")
+	}
+}
+
+func TestUnsupportedPhysicalInPort(t *testing.T) {
+	match := NewMatch()
+	match.SetPhysicalInPort(3)
+	if errors.Cause(match.Error()) != openflow.ErrUnsupportedMatchType {
+		t.Fatalf("expected %v, but got %v", openflow.ErrUnsupportedMatchType, match.Error())
+	}
+	if wildcard, _ := match.PhysicalInPort(); !wildcard {
+		t.Fatal("unexpected physical in-port")
+	}
+}
+
+func TestMatchFields(t *testing.T) {
+	inPort := openflow.NewInPort()
+	inPort.SetValue(3)
+	match := NewMatch()
+	match.SetInPort(inPort)
+	match.SetSrcMAC(net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+	match.SetVLANID(100)
+	match.SetEtherType(0x0800)
+	match.SetIPProtocol(17)
+	match.SetSrcIP(&net.IPNet{IP: net.IPv4(10, 0, 0, 1).To4(), Mask: net.CIDRMask(32, 32)})
+	match.SetSrcPort(53)
+	if err := match.Error(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"in_port=3",
+		"eth_src=00:11:22:33:44:55",
+		"eth_type=2048",
+		"vlan_vid=100",
+		"ip_proto=17",
+		"ipv4_src=10.0.0.1/32",
+		"tp_src=53",
+	}
+
+	// The decoded match of an installed flow should have the same fields.
+	packet, err := match.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal the match: %v", err)
+	}
+	decoded := NewMatch()
+	if err := decoded.UnmarshalBinary(packet); err != nil {
+		t.Fatalf("failed to unmarshal the match: %v", err)
+	}
+
+	for i, m := range []openflow.Match{match, decoded} {
+		fields := m.Fields()
+		if len(fields) != len(expected) {
+			t.Fatalf("#%v: unexpected fields: expected=%v, got=%v", i, expected, fields)
+		}
+		for j, v := range fields {
+			if v.String() != expected[j] {
+				t.Fatalf("#%v: unexpected field at %v: expected=%v, got=%v", i, j, expected[j], v)
+			}
+		}
+	}
+	if fields := NewMatch().Fields(); len(fields) != 0 {
+		t.Fatalf("unexpected fields of the wildcard match: %v", fields)
+	}
+}
@@ -40,6 +40,11 @@ func (r PacketIn) BufferID() uint32 {
 	return r.bufferID
 }
 
+// PhysicalInPort returns InPort because OpenFlow 1.0 has no logical ports.
+func (r PacketIn) PhysicalInPort() uint32 {
+	return r.InPort()
+}
+
 func (r PacketIn) InPort() uint32 {
 	return uint32(r.inPort)
 }
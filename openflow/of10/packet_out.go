@@ -30,14 +30,16 @@ import (
 type PacketOut struct {
 	err error
 	openflow.Message
-	inPort openflow.InPort
-	action openflow.Action
-	data   []byte
+	inPort   openflow.InPort
+	actions  []openflow.Action
+	data     []byte
+	bufferID uint32
 }
 
 func NewPacketOut(xid uint32) openflow.PacketOut {
 	return &PacketOut{
-		Message: openflow.NewMessage(openflow.OF10_VERSION, OFPT_PACKET_OUT, xid),
+		Message:  openflow.NewMessage(openflow.OF10_VERSION, OFPT_PACKET_OUT, xid),
+		bufferID: OFP_NO_BUFFER,
 	}
 }
 
@@ -54,14 +56,38 @@ func (r *PacketOut) SetInPort(port openflow.InPort) {
 }
 
 func (r *PacketOut) Action() openflow.Action {
-	return r.action
+	if len(r.actions) == 0 {
+		return nil
+	}
+	return r.actions[0]
 }
 
 func (r *PacketOut) SetAction(action openflow.Action) {
 	if action == nil {
 		panic("action is nil")
 	}
-	r.action = action
+	r.actions = []openflow.Action{action}
+}
+
+func (r *PacketOut) Actions() []openflow.Action {
+	return r.actions
+}
+
+func (r *PacketOut) SetActions(actions []openflow.Action) {
+	for _, v := range actions {
+		if v == nil {
+			panic("action is nil")
+		}
+	}
+	r.actions = actions
+}
+
+func (r *PacketOut) BufferID() uint32 {
+	return r.bufferID
+}
+
+func (r *PacketOut) SetBufferID(id uint32) {
+	r.bufferID = id
 }
 
 func (r *PacketOut) Data() []byte {
@@ -83,9 +109,10 @@ func (r *PacketOut) MarshalBinary() ([]byte, error) {
 	// XXX:
 	// Dell S4810 switch does not support OFPAT_SET_DL_SRC and
 	// OFPAT_SET_DL_DST actions on a packet out message
+	// The switch applies the actions in order they are serialized.
 	action := make([]byte, 0)
-	if r.action != nil {
-		a, err := r.action.MarshalBinary()
+	for _, v := range r.actions {
+		a, err := v.MarshalBinary()
 		if err != nil {
 			return nil, err
 		}
@@ -93,7 +120,7 @@ func (r *PacketOut) MarshalBinary() ([]byte, error) {
 	}
 
 	v := make([]byte, 8)
-	binary.BigEndian.PutUint32(v[0:4], OFP_NO_BUFFER)
+	binary.BigEndian.PutUint32(v[0:4], r.bufferID)
 	port := uint16(r.inPort.Value())
 	if r.inPort.IsController() {
 		port = OFPP_CONTROLLER
@@ -101,7 +128,8 @@ func (r *PacketOut) MarshalBinary() ([]byte, error) {
 	binary.BigEndian.PutUint16(v[4:6], port)
 	binary.BigEndian.PutUint16(v[6:8], uint16(len(action)))
 	v = append(v, action...)
-	if r.data != nil && len(r.data) > 0 {
+	// The data is only meaningful if the packet is not buffered in the switch.
+	if r.bufferID == OFP_NO_BUFFER && len(r.data) > 0 {
 		v = append(v, r.data...)
 	}
 
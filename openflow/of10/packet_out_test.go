@@ -0,0 +1,98 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of10
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+func TestPacketOutBufferID(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04}
+
+	src := []struct {
+		BufferID uint32
+		Data     bool
+	}{
+		{BufferID: OFP_NO_BUFFER, Data: true},
+		{BufferID: 0x12345678, Data: false},
+	}
+
+	for i, v := range src {
+		out := NewPacketOut(1)
+		out.SetInPort(openflow.NewInPort())
+		out.SetBufferID(v.BufferID)
+		out.SetData(data)
+		packet, err := out.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal: %v", i, err)
+		}
+
+		if got := bytes.HasSuffix(packet, data); got != v.Data {
+			t.Fatalf("#%v: unexpected data: expected=%v, got=%v, packet=%x", i, v.Data, got, packet)
+		}
+		if !bytes.Equal(packet[8:12], []byte{byte(v.BufferID >> 24), byte(v.BufferID >> 16), byte(v.BufferID >> 8), byte(v.BufferID)}) {
+			t.Fatalf("#%v: unexpected buffer ID: expected=%x, got=%x", i, v.BufferID, packet[8:12])
+		}
+	}
+}
+
+func TestPacketOutActions(t *testing.T) {
+	port1 := openflow.NewOutPort()
+	port1.SetValue(1)
+	port2 := openflow.NewOutPort()
+	port2.SetValue(2)
+
+	first := NewAction()
+	first.SetStripVLAN()
+	first.SetOutPort(port1)
+	second := NewAction()
+	second.SetOutPort(port2)
+
+	out := NewPacketOut(1)
+	out.SetInPort(openflow.NewInPort())
+	out.SetActions([]openflow.Action{first, second})
+	packet, err := out.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	var expected []byte
+	for _, v := range []openflow.Action{first, second} {
+		a, err := v.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal the action: %v", err)
+		}
+		expected = append(expected, a...)
+	}
+	length := int(binary.BigEndian.Uint16(packet[14:16]))
+	if length != len(expected) {
+		t.Fatalf("unexpected actions length: expected=%v, got=%v", len(expected), length)
+	}
+	// The actions should be serialized in order.
+	if !bytes.Equal(packet[16:16+length], expected) {
+		t.Fatalf("unexpected actions: expected=%x, got=%x", expected, packet[16:16+length])
+	}
+}
@@ -75,6 +75,10 @@ func (r Port) IsCopper() bool {
 	return r.current&OFPPF_COPPER != 0
 }
 
+func (r Port) IsNoFlood() bool {
+	return r.config&OFPPC_NO_FLOOD != 0
+}
+
 func (r Port) IsFiber() bool {
 	return r.current&OFPPF_FIBER != 0
 }
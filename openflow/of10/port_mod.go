@@ -0,0 +1,154 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of10
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/superkkt/cherry/openflow"
+)
+
+var portConfigMap = []struct {
+	abstract openflow.PortConfig
+	native   uint32
+}{
+	{openflow.PortConfigDown, OFPPC_PORT_DOWN},
+	{openflow.PortConfigNoSTP, OFPPC_NO_STP},
+	{openflow.PortConfigNoRecv, OFPPC_NO_RECV},
+	{openflow.PortConfigNoRecvSTP, OFPPC_NO_RECV_STP},
+	{openflow.PortConfigNoFlood, OFPPC_NO_FLOOD},
+	{openflow.PortConfigNoFwd, OFPPC_NO_FWD},
+	{openflow.PortConfigNoPacketIn, OFPPC_NO_PACKET_IN},
+}
+
+type PortMod struct {
+	openflow.Message
+	err    error
+	port   uint32
+	hwAddr net.HardwareAddr
+	config uint32
+	mask   uint32
+}
+
+func NewPortMod(xid uint32) openflow.PortMod {
+	return &PortMod{
+		Message: openflow.NewMessage(openflow.OF10_VERSION, OFPT_PORT_MOD, xid),
+		hwAddr:  make(net.HardwareAddr, 6),
+	}
+}
+
+func (r *PortMod) Error() error {
+	return r.err
+}
+
+func (r *PortMod) PortNumber() uint32 {
+	return r.port
+}
+
+func (r *PortMod) SetPortNumber(port uint32) {
+	if port > OFPP_MAX {
+		r.err = fmt.Errorf("SetPortNumber: invalid port number: %v", port)
+		return
+	}
+	r.port = port
+}
+
+func (r *PortMod) HWAddr() net.HardwareAddr {
+	return r.hwAddr
+}
+
+func (r *PortMod) SetHWAddr(mac net.HardwareAddr) {
+	if mac == nil || len(mac) != 6 {
+		r.err = errors.Wrap(openflow.ErrInvalidMACAddress, "SetHWAddr")
+		return
+	}
+	r.hwAddr = mac
+}
+
+func (r *PortMod) Config() openflow.PortConfig {
+	return toAbstractPortConfig(r.config)
+}
+
+func (r *PortMod) Mask() openflow.PortConfig {
+	return toAbstractPortConfig(r.mask)
+}
+
+func (r *PortMod) SetConfig(config, mask openflow.PortConfig) {
+	c, err := toNativePortConfig(config)
+	if err != nil {
+		r.err = errors.Wrap(err, "SetConfig")
+		return
+	}
+	m, err := toNativePortConfig(mask)
+	if err != nil {
+		r.err = errors.Wrap(err, "SetConfig")
+		return
+	}
+	r.config = c
+	r.mask = m
+}
+
+func toNativePortConfig(config openflow.PortConfig) (uint32, error) {
+	var native uint32
+	for _, v := range portConfigMap {
+		if config&v.abstract == 0 {
+			continue
+		}
+		native |= v.native
+		config &^= v.abstract
+	}
+	if config != 0 {
+		return 0, openflow.ErrUnsupportedPortConfig
+	}
+
+	return native, nil
+}
+
+func toAbstractPortConfig(native uint32) openflow.PortConfig {
+	var config openflow.PortConfig
+	for _, v := range portConfigMap {
+		if native&v.native != 0 {
+			config |= v.abstract
+		}
+	}
+
+	return config
+}
+
+func (r *PortMod) MarshalBinary() ([]byte, error) {
+	if err := r.Error(); err != nil {
+		return nil, err
+	}
+
+	v := make([]byte, 24)
+	binary.BigEndian.PutUint16(v[0:2], uint16(r.port))
+	copy(v[2:8], r.hwAddr)
+	binary.BigEndian.PutUint32(v[8:12], r.config)
+	binary.BigEndian.PutUint32(v[12:16], r.mask)
+	// advertise (zero means no change) and 4 bytes padding
+	r.SetPayload(v)
+
+	return r.Message.MarshalBinary()
+}
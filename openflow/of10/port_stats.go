@@ -0,0 +1,128 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of10
+
+import (
+	"encoding/binary"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+type PortStatsRequest struct {
+	err error
+	openflow.Message
+	port *uint16
+}
+
+func NewPortStatsRequest(xid uint32) openflow.PortStatsRequest {
+	return &PortStatsRequest{
+		Message: openflow.NewMessage(openflow.OF10_VERSION, OFPT_STATS_REQUEST, xid),
+	}
+}
+
+func (r *PortStatsRequest) Error() error {
+	return r.err
+}
+
+func (r *PortStatsRequest) PortNumber() (ok bool, port uint32) {
+	if r.port == nil {
+		return false, 0
+	}
+	return true, uint32(*r.port)
+}
+
+func (r *PortStatsRequest) SetPortNumber(port uint32) {
+	if port > 0xFFFF {
+		r.err = openflow.ErrInvalidPortNumber
+		return
+	}
+	v := uint16(port)
+	r.port = &v
+}
+
+func (r *PortStatsRequest) MarshalBinary() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	v := make([]byte, 12)
+	binary.BigEndian.PutUint16(v[0:2], OFPST_PORT)
+	// v[2:4] is flags, but not yet defined
+	port := uint16(OFPP_NONE) // All ports
+	if r.port != nil {
+		port = *r.port
+	}
+	binary.BigEndian.PutUint16(v[4:6], port)
+	// v[6:12] is padding
+	r.SetPayload(v)
+
+	return r.Message.MarshalBinary()
+}
+
+type PortStatsReply struct {
+	openflow.Message
+	more  bool
+	stats []openflow.PortStat
+}
+
+func (r PortStatsReply) More() bool {
+	return r.more
+}
+
+func (r PortStatsReply) Stats() []openflow.PortStat {
+	return r.stats
+}
+
+func (r *PortStatsReply) UnmarshalBinary(data []byte) error {
+	if err := r.Message.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	payload := r.Payload()
+	if payload == nil || len(payload) < 4 {
+		return openflow.ErrInvalidPacketLength
+	}
+	// payload[0:2] is type of ofp_stats_reply
+	r.more = binary.BigEndian.Uint16(payload[2:4])&OFPSF_REPLY_MORE != 0
+	r.stats = make([]openflow.PortStat, 0)
+
+	// ofp_port_stats is 104 bytes long.
+	buf := payload[4:]
+	for len(buf) >= 104 {
+		r.stats = append(r.stats, openflow.PortStat{
+			PortNo: uint32(binary.BigEndian.Uint16(buf[0:2])),
+			// buf[2:8] is padding
+			RxPackets: binary.BigEndian.Uint64(buf[8:16]),
+			TxPackets: binary.BigEndian.Uint64(buf[16:24]),
+			RxBytes:   binary.BigEndian.Uint64(buf[24:32]),
+			TxBytes:   binary.BigEndian.Uint64(buf[32:40]),
+			RxDropped: binary.BigEndian.Uint64(buf[40:48]),
+			TxDropped: binary.BigEndian.Uint64(buf[48:56]),
+			RxErrors:  binary.BigEndian.Uint64(buf[56:64]),
+			TxErrors:  binary.BigEndian.Uint64(buf[64:72]),
+			// buf[72:104] is rx_frame_err, rx_over_err, rx_crc_err, and collisions
+		})
+		buf = buf[104:]
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of10
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestPortStatsReply(t *testing.T) {
+	packet := make([]byte, 8+4+104*2)
+	packet[0] = 0x01
+	packet[1] = OFPT_STATS_REPLY
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	binary.BigEndian.PutUint16(packet[8:10], OFPST_PORT)
+	for i := 0; i < 2; i++ {
+		entry := packet[12+104*i:]
+		binary.BigEndian.PutUint16(entry[0:2], uint16(i+1))
+		binary.BigEndian.PutUint64(entry[32:40], uint64(1000*(i+1)))
+		binary.BigEndian.PutUint64(entry[40:48], uint64(i+5))
+	}
+
+	reply := new(PortStatsReply)
+	if err := reply.UnmarshalBinary(packet); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if reply.More() {
+		t.Fatal("unexpected more flag")
+	}
+	stats := reply.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("unexpected number of stats: %v", len(stats))
+	}
+	for i, v := range stats {
+		if v.PortNo != uint32(i+1) || v.TxBytes != uint64(1000*(i+1)) || v.RxDropped != uint64(i+5) {
+			t.Fatalf("#%v: unexpected stat: %+v", i, v)
+		}
+	}
+}
@@ -98,6 +98,10 @@ func (r *QueueProperty) UnmarshalBinary(data []byte) error {
 	r.typ = openflow.PropertyType(binary.BigEndian.Uint16(data[0:2]))
 	r.length = binary.BigEndian.Uint16(data[2:4])
 	// data[4:8] is pad
+	if int(r.length) < 8 {
+		// Zero length would make the caller loop forever.
+		return openflow.ErrInvalidPacketLength
+	}
 	if r.typ == openflow.OFPQT_NONE {
 		return nil
 	}
@@ -0,0 +1,112 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of10
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+// queue returns an ofp_packet_queue with a min-rate property if rate is not zero.
+func queue(id uint32, rate uint16) []byte {
+	if rate == 0 {
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint32(v[0:4], id)
+		binary.BigEndian.PutUint16(v[4:6], 8)
+		return v
+	}
+
+	v := make([]byte, 24)
+	binary.BigEndian.PutUint32(v[0:4], id)
+	binary.BigEndian.PutUint16(v[4:6], 24)
+	binary.BigEndian.PutUint16(v[8:10], uint16(openflow.OFPQT_MIN_RATE))
+	binary.BigEndian.PutUint16(v[10:12], 16)
+	binary.BigEndian.PutUint16(v[16:18], rate)
+	return v
+}
+
+func queueGetConfigReply(port uint16, queues ...[]byte) []byte {
+	v := make([]byte, 16)
+	v[0] = 0x01
+	v[1] = OFPT_QUEUE_GET_CONFIG_REPLY
+	binary.BigEndian.PutUint16(v[8:10], port)
+	for _, q := range queues {
+		v = append(v, q...)
+	}
+	binary.BigEndian.PutUint16(v[2:4], uint16(len(v)))
+	return v
+}
+
+func TestQueueGetConfigReply(t *testing.T) {
+	zeroLength := queue(3, 100)
+	binary.BigEndian.PutUint16(zeroLength[10:12], 0)
+
+	src := []struct {
+		packet []byte
+		port   uint32
+		ids    []uint32
+		rates  []uint16
+		err    bool
+	}{
+		{queueGetConfigReply(1), 1, nil, nil, false},
+		{queueGetConfigReply(2, queue(0, 500), queue(1, 0)), 2, []uint32{0, 1}, []uint16{500, 0}, false},
+		{queueGetConfigReply(3, queue(7, 1000)[:16]), 0, nil, nil, true},
+		{queueGetConfigReply(4, zeroLength), 0, nil, nil, true},
+	}
+
+	for i, v := range src {
+		reply := new(QueueGetConfigReply)
+		err := reply.UnmarshalBinary(v.packet)
+		if v.err {
+			if err == nil {
+				t.Fatalf("#%v: expected error, but got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if reply.Port() != v.port {
+			t.Fatalf("#%v: unexpected port: expected=%v, got=%v", i, v.port, reply.Port())
+		}
+		if len(reply.Queue()) != len(v.ids) {
+			t.Fatalf("#%v: unexpected number of queues: expected=%v, got=%v", i, len(v.ids), len(reply.Queue()))
+		}
+		for j, q := range reply.Queue() {
+			if q.ID() != v.ids[j] {
+				t.Fatalf("#%v: unexpected queue ID: expected=%v, got=%v", i, v.ids[j], q.ID())
+			}
+			if v.rates[j] == 0 {
+				if len(q.Property()) != 0 {
+					t.Fatalf("#%v: unexpected properties: %v", i, len(q.Property()))
+				}
+				continue
+			}
+			rate, err := q.Property()[0].Rate()
+			if err != nil || rate != v.rates[j] {
+				t.Fatalf("#%v: unexpected rate: expected=%v, got=%v (err=%v)", i, v.rates[j], rate, err)
+			}
+		}
+	}
+}
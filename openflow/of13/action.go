@@ -25,6 +25,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"net"
 
 	"github.com/superkkt/cherry/openflow"
@@ -59,16 +60,52 @@ func marshalOutput(p openflow.OutPort) ([]byte, error) {
 		port = OFPP_IN_PORT
 	case p.IsNone():
 		port = OFPP_ANY
+	case p.IsNormal():
+		port = OFPP_NORMAL
 	default:
 		port = p.Value()
+		// The reserved ports should be set by their own setters, except OFPP_LOCAL that has no setter.
+		if port > OFPP_MAX && port != OFPP_LOCAL {
+			return nil, fmt.Errorf("invalid output port: reserved port number %#x", port)
+		}
 	}
 	binary.BigEndian.PutUint32(v[4:8], port)
-	// We don't support buffer ID and partial PACKET_IN
-	binary.BigEndian.PutUint16(v[8:10], 0xFFFF)
+	// Only meaningful for the controller, and we don't support buffer ID.
+	maxLen := p.MaxLen()
+	if maxLen > OFPCML_MAX && maxLen != OFPCML_NO_BUFFER {
+		return nil, fmt.Errorf("invalid max length of the controller output: %v", maxLen)
+	}
+	binary.BigEndian.PutUint16(v[8:10], maxLen)
 
 	return v, nil
 }
 
+// unmarshalOutPort decodes the output port number, which may be one of the reserved ports, of an output action.
+// OFPP_LOCAL is decoded as a port number because OutPort has no logical port for it.
+func unmarshalOutPort(port uint32, maxLen uint16) openflow.OutPort {
+	v := openflow.NewOutPort()
+	switch port {
+	case OFPP_TABLE:
+		v.SetTable()
+	case OFPP_FLOOD:
+		v.SetFlood()
+	case OFPP_ALL:
+		v.SetAll()
+	case OFPP_CONTROLLER:
+		v.SetControllerMaxLen(maxLen)
+	case OFPP_IN_PORT:
+		v.SetInPort()
+	case OFPP_ANY:
+		v.SetNone()
+	case OFPP_NORMAL:
+		v.SetNormal()
+	default:
+		v.SetValue(port)
+	}
+
+	return v
+}
+
 func marshalMAC(t uint8, mac net.HardwareAddr) ([]byte, error) {
 	if mac == nil || len(mac) < 6 {
 		return nil, openflow.ErrInvalidMACAddress
@@ -79,6 +116,20 @@ func marshalMAC(t uint8, mac net.HardwareAddr) ([]byte, error) {
 		return nil, err
 	}
 
+	return marshalSetField(tlv), nil
+}
+
+func marshalVLANID(vid uint16) ([]byte, error) {
+	// OFPVID_PRESENT should be set to match or set the VLAN ID of a tagged packet.
+	tlv, err := marshalUint16TLV(OFPXMT_OFB_VLAN_VID, vid|OFPVID_PRESENT)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalSetField(tlv), nil
+}
+
+func marshalSetField(tlv []byte) []byte {
 	v := make([]byte, 4+len(tlv))
 	binary.BigEndian.PutUint16(v[0:2], OFPAT_SET_FIELD)
 	// Add padding to align as a multiple of 8
@@ -89,12 +140,161 @@ func marshalMAC(t uint8, mac net.HardwareAddr) ([]byte, error) {
 	binary.BigEndian.PutUint16(v[2:4], uint16(len(v)))
 	copy(v[4:], tlv)
 
-	return v, nil
+	return v
+}
+
+// marshalHeaderOnly marshals an action that has no body, such as OFPAT_DEC_NW_TTL.
+func marshalHeaderOnly(t uint16) []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], t)
+	binary.BigEndian.PutUint16(v[2:4], 8)
+	// v[4:8] is padding
+
+	return v
+}
+
+func marshalNWTTL(ttl uint8) []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], OFPAT_SET_NW_TTL)
+	binary.BigEndian.PutUint16(v[2:4], 8)
+	v[4] = ttl
+	// v[5:8] is padding
+
+	return v
+}
+
+func marshalPushVLAN(etherType uint16) []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], OFPAT_PUSH_VLAN)
+	binary.BigEndian.PutUint16(v[2:4], 8)
+	binary.BigEndian.PutUint16(v[4:6], etherType)
+	// v[6:8] is padding
+
+	return v
+}
+
+// marshalEtherTypeAction marshals an action whose body is an Ethernet type, such as OFPAT_PUSH_MPLS and
+// OFPAT_POP_MPLS.
+func marshalEtherTypeAction(t uint16, etherType uint16) []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], t)
+	binary.BigEndian.PutUint16(v[2:4], 8)
+	binary.BigEndian.PutUint16(v[4:6], etherType)
+	// v[6:8] is padding
+
+	return v
+}
+
+func marshalMPLSLabel(label uint32) ([]byte, error) {
+	tlv, err := marshalUint32TLV(OFPXMT_OFB_MPLS_LABEL, label)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalSetField(tlv), nil
+}
+
+func marshalGroup(id uint32) []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], OFPAT_GROUP)
+	binary.BigEndian.PutUint16(v[2:4], 8)
+	binary.BigEndian.PutUint32(v[4:8], id)
+
+	return v
+}
+
+func marshalSetQueue(id uint32) []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], OFPAT_SET_QUEUE)
+	binary.BigEndian.PutUint16(v[2:4], 8)
+	binary.BigEndian.PutUint32(v[4:8], id)
+
+	return v
 }
 
-// TODO: Marshal Enqueue
+func marshalIP(src bool, ip net.IP) ([]byte, error) {
+	var tlv []byte
+	var err error
+	if ipv4 := ip.To4(); ipv4 != nil {
+		field := uint8(OFPXMT_OFB_IPV4_DST)
+		if src {
+			field = OFPXMT_OFB_IPV4_SRC
+		}
+		tlv, err = marshalUint32TLV(field, binary.BigEndian.Uint32(ipv4))
+	} else {
+		field := uint8(OFPXMT_OFB_IPV6_DST)
+		if src {
+			field = OFPXMT_OFB_IPV6_SRC
+		}
+		// The mask is omitted for the full mask.
+		tlv, err = marshalIPv6NetTLV(field, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalSetField(tlv), nil
+}
+
+func marshalL4Port(protocol uint8, src bool, port uint16) ([]byte, error) {
+	var field uint8
+	switch protocol {
+	case 6: // TCP
+		field = OFPXMT_OFB_TCP_DST
+		if src {
+			field = OFPXMT_OFB_TCP_SRC
+		}
+	case 17: // UDP
+		field = OFPXMT_OFB_UDP_DST
+		if src {
+			field = OFPXMT_OFB_UDP_SRC
+		}
+	case 132: // SCTP
+		field = OFPXMT_OFB_SCTP_DST
+		if src {
+			field = OFPXMT_OFB_SCTP_SRC
+		}
+	default:
+		return nil, openflow.ErrUnsupportedIPProtocol
+	}
+
+	tlv, err := marshalUint16TLV(field, port)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalSetField(tlv), nil
+}
 
-// TODO: Marshal SetVLANVID
+func (r *Action) marshalL4Ports() ([]byte, error) {
+	srcOK, src := r.L4SrcPort()
+	dstOK, dst := r.L4DstPort()
+	if !srcOK && !dstOK {
+		return nil, nil
+	}
+	ok, protocol := r.L4Protocol()
+	if !ok {
+		return nil, openflow.ErrMissingIPProtocol
+	}
+
+	result := make([]byte, 0)
+	if srcOK {
+		v, err := marshalL4Port(protocol, true, src)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v...)
+	}
+	if dstOK {
+		v, err := marshalL4Port(protocol, false, dst)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v...)
+	}
+
+	return result, nil
+}
 
 func (r *Action) MarshalBinary() ([]byte, error) {
 	if err := r.Error(); err != nil {
@@ -102,6 +302,32 @@ func (r *Action) MarshalBinary() ([]byte, error) {
 	}
 
 	result := make([]byte, 0)
+	// Same order as the action set: copy TTL inwards, pop, push-MPLS, push-VLAN, copy TTL outwards, decrement
+	// TTL, set-field, and group or output.
+	if r.CopyTTLIn() {
+		result = append(result, marshalHeaderOnly(OFPAT_COPY_TTL_IN)...)
+	}
+	if r.PopVLAN() || r.StripVLAN() {
+		result = append(result, marshalHeaderOnly(OFPAT_POP_VLAN)...)
+	}
+	if ok, etherType := r.PopMPLS(); ok {
+		result = append(result, marshalEtherTypeAction(OFPAT_POP_MPLS, etherType)...)
+	}
+	if ok, etherType := r.PushMPLS(); ok {
+		result = append(result, marshalEtherTypeAction(OFPAT_PUSH_MPLS, etherType)...)
+	}
+	if ok, etherType := r.PushVLAN(); ok {
+		result = append(result, marshalPushVLAN(etherType)...)
+	}
+	if r.CopyTTLOut() {
+		result = append(result, marshalHeaderOnly(OFPAT_COPY_TTL_OUT)...)
+	}
+	if r.DecNWTTL() {
+		result = append(result, marshalHeaderOnly(OFPAT_DEC_NW_TTL)...)
+	}
+	if ok, ttl := r.NWTTL(); ok {
+		result = append(result, marshalNWTTL(ttl)...)
+	}
 	if ok, srcMAC := r.SrcMAC(); ok {
 		v, err := marshalMAC(OFPXMT_OFB_ETH_SRC, srcMAC)
 		if err != nil {
@@ -116,40 +342,129 @@ func (r *Action) MarshalBinary() ([]byte, error) {
 		}
 		result = append(result, v...)
 	}
-
-	v, err := marshalOutput(r.OutPort())
+	if ok, vid := r.VLANID(); ok {
+		v, err := marshalVLANID(vid)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v...)
+	}
+	if ok, ip := r.IPSrc(); ok {
+		v, err := marshalIP(true, ip)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v...)
+	}
+	if ok, ip := r.IPDst(); ok {
+		v, err := marshalIP(false, ip)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v...)
+	}
+	if ok, label := r.MPLSLabel(); ok {
+		v, err := marshalMPLSLabel(label)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v...)
+	}
+	ports, err := r.marshalL4Ports()
 	if err != nil {
 		return nil, err
 	}
-	result = append(result, v...)
+	result = append(result, ports...)
+	// OpenFlow 1.3 does not have the enqueue action. Instead, the queue is set before the output.
+	if ok, id := r.Queue(); ok {
+		result = append(result, marshalSetQueue(id)...)
+	}
+
+	// The output is ignored if a group is set, which is same with the action set.
+	if ok, id := r.Group(); ok {
+		return append(result, marshalGroup(id)...), nil
+	}
+	for _, p := range r.OutPorts() {
+		v, err := marshalOutput(p)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v...)
+	}
 
 	return result, nil
 }
 
-// TODO: Unmarshal Enqueue
-
-// TODO: Unmarshal SetVLANVID
-
 func (r *Action) UnmarshalBinary(data []byte) error {
 	buf := data
+	hasOutput := false
 	for len(buf) >= 4 {
 		t := binary.BigEndian.Uint16(buf[0:2])
 		length := binary.BigEndian.Uint16(buf[2:4])
-		if len(buf) < int(length) {
+		// Every action is at least 8 bytes, and a zero length would never advance the buffer.
+		if length < 8 || len(buf) < int(length) {
 			return openflow.ErrInvalidPacketLength
 		}
 
 		switch t {
 		case OFPAT_OUTPUT:
+			if len(buf) < 10 {
+				return openflow.ErrInvalidPacketLength
+			}
+			outPort := unmarshalOutPort(binary.BigEndian.Uint32(buf[4:8]), binary.BigEndian.Uint16(buf[8:10]))
+			if hasOutput {
+				r.AddOutPort(outPort)
+			} else {
+				r.SetOutPort(outPort)
+				hasOutput = true
+			}
+			if err := r.Error(); err != nil {
+				return err
+			}
+		case OFPAT_COPY_TTL_IN:
+			r.SetCopyTTLIn()
+		case OFPAT_COPY_TTL_OUT:
+			r.SetCopyTTLOut()
+		case OFPAT_DEC_NW_TTL:
+			r.SetDecNWTTL()
+		case OFPAT_POP_VLAN:
+			r.SetPopVLAN()
+		case OFPAT_GROUP:
+			if len(buf) < 8 {
+				return openflow.ErrInvalidPacketLength
+			}
+			r.SetGroup(binary.BigEndian.Uint32(buf[4:8]))
+		case OFPAT_PUSH_VLAN:
+			if len(buf) < 8 {
+				return openflow.ErrInvalidPacketLength
+			}
+			r.SetPushVLAN(binary.BigEndian.Uint16(buf[4:6]))
+			if err := r.Error(); err != nil {
+				return err
+			}
+		case OFPAT_PUSH_MPLS:
 			if len(buf) < 8 {
 				return openflow.ErrInvalidPacketLength
 			}
-			outPort := openflow.NewOutPort()
-			outPort.SetValue(binary.BigEndian.Uint32(buf[4:8]))
-			r.SetOutPort(outPort)
+			r.SetPushMPLS(binary.BigEndian.Uint16(buf[4:6]))
 			if err := r.Error(); err != nil {
 				return err
 			}
+		case OFPAT_POP_MPLS:
+			if len(buf) < 8 {
+				return openflow.ErrInvalidPacketLength
+			}
+			r.SetPopMPLS(binary.BigEndian.Uint16(buf[4:6]))
+		case OFPAT_SET_QUEUE:
+			if len(buf) < 8 {
+				return openflow.ErrInvalidPacketLength
+			}
+			r.SetQueue(binary.BigEndian.Uint32(buf[4:8]))
+		case OFPAT_SET_NW_TTL:
+			if len(buf) < 8 {
+				return openflow.ErrInvalidPacketLength
+			}
+			r.SetNWTTL(buf[4])
 		case OFPAT_SET_FIELD:
 			if len(buf) < 8 {
 				return openflow.ErrInvalidPacketLength
@@ -178,6 +493,60 @@ func (r *Action) UnmarshalBinary(data []byte) error {
 				if err := r.Error(); err != nil {
 					return err
 				}
+			case OFPXMT_OFB_VLAN_VID:
+				if len(buf) < 10 {
+					return openflow.ErrInvalidPacketLength
+				}
+				r.SetVLANID(binary.BigEndian.Uint16(buf[8:10]) &^ OFPVID_PRESENT)
+				if err := r.Error(); err != nil {
+					return err
+				}
+			case OFPXMT_OFB_MPLS_LABEL:
+				if len(buf) < 12 {
+					return openflow.ErrInvalidPacketLength
+				}
+				r.SetMPLSLabel(binary.BigEndian.Uint32(buf[8:12]))
+				if err := r.Error(); err != nil {
+					return err
+				}
+			case OFPXMT_OFB_IPV4_SRC, OFPXMT_OFB_IPV4_DST:
+				if len(buf) < 12 {
+					return openflow.ErrInvalidPacketLength
+				}
+				ip := net.IPv4(buf[8], buf[9], buf[10], buf[11])
+				if field == OFPXMT_OFB_IPV4_SRC {
+					r.SetIPSrc(ip)
+				} else {
+					r.SetIPDst(ip)
+				}
+			case OFPXMT_OFB_IPV6_SRC, OFPXMT_OFB_IPV6_DST:
+				if len(buf) < 24 {
+					return openflow.ErrInvalidPacketLength
+				}
+				ip := net.IP(append([]byte{}, buf[8:24]...))
+				if field == OFPXMT_OFB_IPV6_SRC {
+					r.SetIPSrc(ip)
+				} else {
+					r.SetIPDst(ip)
+				}
+			case OFPXMT_OFB_TCP_SRC, OFPXMT_OFB_TCP_DST, OFPXMT_OFB_UDP_SRC, OFPXMT_OFB_UDP_DST, OFPXMT_OFB_SCTP_SRC, OFPXMT_OFB_SCTP_DST:
+				if len(buf) < 10 {
+					return openflow.ErrInvalidPacketLength
+				}
+				port := binary.BigEndian.Uint16(buf[8:10])
+				switch field {
+				case OFPXMT_OFB_TCP_SRC, OFPXMT_OFB_TCP_DST:
+					r.SetL4Protocol(6)
+				case OFPXMT_OFB_UDP_SRC, OFPXMT_OFB_UDP_DST:
+					r.SetL4Protocol(17)
+				default:
+					r.SetL4Protocol(132)
+				}
+				if field == OFPXMT_OFB_TCP_SRC || field == OFPXMT_OFB_UDP_SRC || field == OFPXMT_OFB_SCTP_SRC {
+					r.SetL4SrcPort(port)
+				} else {
+					r.SetL4DstPort(port)
+				}
 			default:
 				// Do nothing
 			}
@@ -0,0 +1,748 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+
+	"github.com/pkg/errors"
+)
+
+func TestTTLActionEncoding(t *testing.T) {
+	// Output to the controller.
+	output := []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfd, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	src := []struct {
+		Set      func(openflow.Action)
+		Expected []byte
+	}{
+		{
+			Set:      func(a openflow.Action) { a.SetNWTTL(64) },
+			Expected: []byte{0x00, 0x17, 0x00, 0x08, 0x40, 0x00, 0x00, 0x00},
+		},
+		{
+			Set:      func(a openflow.Action) { a.SetDecNWTTL() },
+			Expected: []byte{0x00, 0x18, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			Set:      func(a openflow.Action) { a.SetCopyTTLOut() },
+			Expected: []byte{0x00, 0x0b, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			Set:      func(a openflow.Action) { a.SetCopyTTLIn() },
+			Expected: []byte{0x00, 0x0c, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			// Actions should be ordered as the action set regardless of the setter calls.
+			Set: func(a openflow.Action) {
+				a.SetDecNWTTL()
+				a.SetCopyTTLOut()
+				a.SetCopyTTLIn()
+			},
+			Expected: []byte{
+				0x00, 0x0c, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x0b, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x18, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+	}
+
+	for i, v := range src {
+		action := NewAction()
+		port := openflow.NewOutPort()
+		port.SetController()
+		action.SetOutPort(port)
+		v.Set(action)
+
+		data, err := action.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		expected := append(v.Expected, output...)
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, expected, data)
+		}
+
+		decoded := NewAction()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if decoded.DecNWTTL() != action.DecNWTTL() || decoded.CopyTTLIn() != action.CopyTTLIn() || decoded.CopyTTLOut() != action.CopyTTLOut() {
+			t.Fatalf("#%v: unexpected decoded TTL actions", i)
+		}
+		ok1, ttl1 := action.NWTTL()
+		ok2, ttl2 := decoded.NWTTL()
+		if ok1 != ok2 || ttl1 != ttl2 {
+			t.Fatalf("#%v: unexpected decoded NW TTL: expected=%v/%v, got=%v/%v", i, ok1, ttl1, ok2, ttl2)
+		}
+	}
+}
+
+func TestVLANActionEncoding(t *testing.T) {
+	// Output to the controller.
+	output := []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfd, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	src := []struct {
+		Set      func(openflow.Action)
+		Expected []byte
+	}{
+		{
+			// The VLAN ID should be set with OFPVID_PRESENT.
+			Set:      func(a openflow.Action) { a.SetVLANID(100) },
+			Expected: []byte{0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x0c, 0x02, 0x10, 0x64, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			Set:      func(a openflow.Action) { a.SetPushVLAN(0x88a8) },
+			Expected: []byte{0x00, 0x11, 0x00, 0x08, 0x88, 0xa8, 0x00, 0x00},
+		},
+		{
+			Set:      func(a openflow.Action) { a.SetPopVLAN() },
+			Expected: []byte{0x00, 0x12, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			// Actions should be ordered as the action set regardless of the setter calls.
+			Set: func(a openflow.Action) {
+				a.SetVLANID(0xFFF)
+				a.SetPushVLAN(0x8100)
+				a.SetPopVLAN()
+			},
+			Expected: []byte{
+				0x00, 0x12, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x11, 0x00, 0x08, 0x81, 0x00, 0x00, 0x00,
+				0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x0c, 0x02, 0x1f, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+	}
+
+	for i, v := range src {
+		action := NewAction()
+		port := openflow.NewOutPort()
+		port.SetController()
+		action.SetOutPort(port)
+		v.Set(action)
+
+		data, err := action.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		expected := append(v.Expected, output...)
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, expected, data)
+		}
+
+		decoded := NewAction()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if decoded.PopVLAN() != action.PopVLAN() {
+			t.Fatalf("#%v: unexpected decoded pop VLAN: expected=%v, got=%v", i, action.PopVLAN(), decoded.PopVLAN())
+		}
+		ok1, etherType1 := action.PushVLAN()
+		ok2, etherType2 := decoded.PushVLAN()
+		if ok1 != ok2 || etherType1 != etherType2 {
+			t.Fatalf("#%v: unexpected decoded push VLAN: expected=%v/%v, got=%v/%v", i, ok1, etherType1, ok2, etherType2)
+		}
+		ok1, vid1 := action.VLANID()
+		ok2, vid2 := decoded.VLANID()
+		if ok1 != ok2 || vid1 != vid2 {
+			t.Fatalf("#%v: unexpected decoded VLAN ID: expected=%v/%v, got=%v/%v", i, ok1, vid1, ok2, vid2)
+		}
+	}
+}
+
+func TestMACActionEncoding(t *testing.T) {
+	// Output to the controller.
+	output := []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfd, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	src := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	dst := net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb}
+
+	tests := []struct {
+		Set      func(openflow.Action)
+		Expected []byte
+	}{
+		{
+			// OFPAT_SET_FIELD with the OXM of OFPXMT_OFB_ETH_SRC, which is padded to 16 bytes.
+			Set:      func(a openflow.Action) { a.SetSrcMAC(src) },
+			Expected: []byte{0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x08, 0x06, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x00},
+		},
+		{
+			// OFPAT_SET_FIELD with the OXM of OFPXMT_OFB_ETH_DST.
+			Set:      func(a openflow.Action) { a.SetDstMAC(dst) },
+			Expected: []byte{0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x06, 0x06, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0x00, 0x00},
+		},
+		{
+			// The source comes first regardless of the setter calls.
+			Set: func(a openflow.Action) {
+				a.SetDstMAC(dst)
+				a.SetSrcMAC(src)
+			},
+			Expected: []byte{
+				0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x08, 0x06, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x00,
+				0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x06, 0x06, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0x00, 0x00,
+			},
+		},
+	}
+
+	for i, v := range tests {
+		action := NewAction()
+		port := openflow.NewOutPort()
+		port.SetController()
+		action.SetOutPort(port)
+		v.Set(action)
+
+		data, err := action.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		expected := append(v.Expected, output...)
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, expected, data)
+		}
+
+		decoded := NewAction()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		// The decoded action should not refer to the message buffer.
+		for j := range data {
+			data[j] = 0
+		}
+		ok1, mac1 := action.SrcMAC()
+		ok2, mac2 := decoded.SrcMAC()
+		if ok1 != ok2 || !bytes.Equal(mac1, mac2) {
+			t.Fatalf("#%v: unexpected decoded source MAC: expected=%v/%v, got=%v/%v", i, ok1, mac1, ok2, mac2)
+		}
+		ok1, mac1 = action.DstMAC()
+		ok2, mac2 = decoded.DstMAC()
+		if ok1 != ok2 || !bytes.Equal(mac1, mac2) {
+			t.Fatalf("#%v: unexpected decoded destination MAC: expected=%v/%v, got=%v/%v", i, ok1, mac1, ok2, mac2)
+		}
+		if out := decoded.OutPort(); !out.IsController() {
+			t.Fatalf("#%v: unexpected decoded output port: %v", i, out)
+		}
+	}
+}
+
+func TestInvalidVLANAction(t *testing.T) {
+	src := []struct {
+		Set      func(openflow.Action)
+		Expected error
+	}{
+		{
+			Set:      func(a openflow.Action) { a.SetVLANID(0x1000) },
+			Expected: openflow.ErrInvalidVLANID,
+		},
+		{
+			Set:      func(a openflow.Action) { a.SetPushVLAN(0x0800) },
+			Expected: openflow.ErrUnsupportedEtherType,
+		},
+	}
+
+	for i, v := range src {
+		action := NewAction()
+		v.Set(action)
+		if _, err := action.MarshalBinary(); errors.Cause(err) != v.Expected {
+			t.Fatalf("#%v: expected %v, but got %v", i, v.Expected, err)
+		}
+	}
+}
+
+func TestMPLSActionEncoding(t *testing.T) {
+	// Output to the controller.
+	output := []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfd, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	src := []struct {
+		Set      func(openflow.Action)
+		Expected []byte
+	}{
+		{
+			Set:      func(a openflow.Action) { a.SetPushMPLS(0x8847) },
+			Expected: []byte{0x00, 0x13, 0x00, 0x08, 0x88, 0x47, 0x00, 0x00},
+		},
+		{
+			Set:      func(a openflow.Action) { a.SetPopMPLS(0x0800) },
+			Expected: []byte{0x00, 0x14, 0x00, 0x08, 0x08, 0x00, 0x00, 0x00},
+		},
+		{
+			Set:      func(a openflow.Action) { a.SetMPLSLabel(0x12345) },
+			Expected: []byte{0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x44, 0x04, 0x00, 0x01, 0x23, 0x45, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			// Actions should be ordered as the action set regardless of the setter calls.
+			Set: func(a openflow.Action) {
+				a.SetMPLSLabel(0xFFFFF)
+				a.SetPushMPLS(0x8848)
+				a.SetPopMPLS(0x8847)
+			},
+			Expected: []byte{
+				0x00, 0x14, 0x00, 0x08, 0x88, 0x47, 0x00, 0x00,
+				0x00, 0x13, 0x00, 0x08, 0x88, 0x48, 0x00, 0x00,
+				0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x44, 0x04, 0x00, 0x0f, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+	}
+
+	for i, v := range src {
+		action := NewAction()
+		port := openflow.NewOutPort()
+		port.SetController()
+		action.SetOutPort(port)
+		v.Set(action)
+
+		data, err := action.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		expected := append(v.Expected, output...)
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, expected, data)
+		}
+
+		decoded := NewAction()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		ok1, etherType1 := action.PushMPLS()
+		ok2, etherType2 := decoded.PushMPLS()
+		if ok1 != ok2 || etherType1 != etherType2 {
+			t.Fatalf("#%v: unexpected decoded push MPLS: expected=%v/%v, got=%v/%v", i, ok1, etherType1, ok2, etherType2)
+		}
+		ok1, etherType1 = action.PopMPLS()
+		ok2, etherType2 = decoded.PopMPLS()
+		if ok1 != ok2 || etherType1 != etherType2 {
+			t.Fatalf("#%v: unexpected decoded pop MPLS: expected=%v/%v, got=%v/%v", i, ok1, etherType1, ok2, etherType2)
+		}
+		ok1, label1 := action.MPLSLabel()
+		ok2, label2 := decoded.MPLSLabel()
+		if ok1 != ok2 || label1 != label2 {
+			t.Fatalf("#%v: unexpected decoded MPLS label: expected=%v/%v, got=%v/%v", i, ok1, label1, ok2, label2)
+		}
+	}
+}
+
+func TestInvalidMPLSAction(t *testing.T) {
+	src := []func(openflow.Action){
+		func(a openflow.Action) { a.SetPushMPLS(0x0800) },
+		func(a openflow.Action) { a.SetMPLSLabel(0x100000) },
+	}
+
+	for i, set := range src {
+		action := NewAction()
+		set(action)
+		if _, err := action.MarshalBinary(); err == nil {
+			t.Fatalf("#%v: expected an error", i)
+		}
+	}
+}
+
+func TestControllerOutputEncoding(t *testing.T) {
+	src := []struct {
+		Set      func(*openflow.OutPort)
+		Expected []byte
+		MaxLen   uint16
+	}{
+		// Whole packet by default.
+		{
+			Set:      func(p *openflow.OutPort) { p.SetController() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfd, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			MaxLen:   0xffff,
+		},
+		// Header-only copies.
+		{
+			Set:      func(p *openflow.OutPort) { p.SetControllerMaxLen(128) },
+			Expected: []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfd, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			MaxLen:   128,
+		},
+		{
+			Set:      func(p *openflow.OutPort) { p.SetControllerMaxLen(OFPCML_MAX) },
+			Expected: []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfd, 0xff, 0xe5, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			MaxLen:   0xffe5,
+		},
+		// Max length is ignored for the other ports.
+		{
+			Set:      func(p *openflow.OutPort) { p.SetControllerMaxLen(128); p.SetValue(3) },
+			Expected: []byte{0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x03, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			MaxLen:   0xffff,
+		},
+	}
+
+	for i, v := range src {
+		port := openflow.NewOutPort()
+		v.Set(&port)
+		action := NewAction()
+		action.SetOutPort(port)
+
+		data, err := action.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(data, v.Expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, v.Expected, data)
+		}
+
+		decoded := NewAction()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		out := decoded.OutPort()
+		if out.IsController() != port.IsController() || out.MaxLen() != v.MaxLen {
+			t.Fatalf("#%v: unexpected decoded output port: %v", i, out)
+		}
+	}
+
+	// Reserved max length values.
+	port := openflow.NewOutPort()
+	port.SetControllerMaxLen(0xfff0)
+	action := NewAction()
+	action.SetOutPort(port)
+	if _, err := action.MarshalBinary(); err == nil {
+		t.Fatal("expected an error for the reserved max length")
+	}
+}
+
+func TestSpecialOutputEncoding(t *testing.T) {
+	src := []struct {
+		Set      func(*openflow.OutPort)
+		Expected []byte
+		Is       func(*openflow.OutPort) bool
+	}{
+		{
+			Set:      func(p *openflow.OutPort) { p.SetTable() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xf9, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			Is:       func(p *openflow.OutPort) bool { return p.IsTable() },
+		},
+		{
+			Set:      func(p *openflow.OutPort) { p.SetInPort() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xf8, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			Is:       func(p *openflow.OutPort) bool { return p.IsInPort() },
+		},
+		{
+			Set:      func(p *openflow.OutPort) { p.SetFlood() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfb, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			Is:       func(p *openflow.OutPort) bool { return p.IsFlood() },
+		},
+		{
+			Set:      func(p *openflow.OutPort) { p.SetAll() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfc, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			Is:       func(p *openflow.OutPort) bool { return p.IsAll() },
+		},
+		{
+			Set:      func(p *openflow.OutPort) { p.SetNormal() },
+			Expected: []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfa, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			Is:       func(p *openflow.OutPort) bool { return p.IsNormal() },
+		},
+	}
+
+	for i, v := range src {
+		port := openflow.NewOutPort()
+		v.Set(&port)
+		action := NewAction()
+		action.SetOutPort(port)
+
+		data, err := action.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(data, v.Expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, v.Expected, data)
+		}
+
+		decoded := NewAction()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		out := decoded.OutPort()
+		if !v.Is(&out) {
+			t.Fatalf("#%v: unexpected decoded output port: %v", i, out)
+		}
+	}
+}
+
+func TestSpecialOutputValidation(t *testing.T) {
+	f := NewFactory()
+	newAction := func(set func(*openflow.OutPort)) openflow.Action {
+		port := openflow.NewOutPort()
+		set(&port)
+		action := NewAction()
+		action.SetOutPort(port)
+		return action
+	}
+	table := func(p *openflow.OutPort) { p.SetTable() }
+	inPort := func(p *openflow.OutPort) { p.SetInPort() }
+
+	// OFPP_TABLE is only allowed in PACKET_OUT.
+	for i, set := range []func(*openflow.OutPort){table, inPort} {
+		match, err := f.NewMatch()
+		if err != nil {
+			t.Fatalf("#%v: failed to create a match: %v", i, err)
+		}
+		inst, err := f.NewInstruction()
+		if err != nil {
+			t.Fatalf("#%v: failed to create an instruction: %v", i, err)
+		}
+		inst.ApplyAction(newAction(set))
+		flow, err := f.NewFlowMod(openflow.FlowAdd)
+		if err != nil {
+			t.Fatalf("#%v: failed to create a flow-mod: %v", i, err)
+		}
+		flow.SetFlowMatch(match)
+		flow.SetFlowInstruction(inst)
+		err = openflow.ValidateFlowMod(flow)
+		if valid := i != 0; (err == nil) != valid {
+			t.Fatalf("#%v: expected valid=%v, got err=%v", i, valid, err)
+		}
+	}
+
+	// OFPP_IN_PORT in PACKET_OUT requires a switch port as the ingress port.
+	src := []struct {
+		Set     func(*openflow.OutPort)
+		InPort  uint32 // Zero means the controller
+		IsValid bool
+	}{
+		{Set: table, InPort: 0, IsValid: true},
+		{Set: inPort, InPort: 0, IsValid: false},
+		{Set: inPort, InPort: 3, IsValid: true},
+	}
+	for i, v := range src {
+		in := openflow.NewInPort()
+		if v.InPort != 0 {
+			in.SetValue(v.InPort)
+		}
+		out := NewPacketOut(1)
+		out.SetInPort(in)
+		out.SetAction(newAction(v.Set))
+		if err := openflow.ValidatePacketOut(out); (err == nil) != v.IsValid {
+			t.Fatalf("#%v: expected valid=%v, got err=%v", i, v.IsValid, err)
+		}
+	}
+}
+
+func TestReservedOutputPort(t *testing.T) {
+	src := []struct {
+		Port    uint32
+		IsValid bool
+	}{
+		{OFPP_MAX, true},
+		{OFPP_LOCAL, true},
+		// The reserved ports should be set by their own setters.
+		{OFPP_NORMAL, false},
+		{OFPP_FLOOD, false},
+		{OFPP_CONTROLLER, false},
+	}
+
+	for i, v := range src {
+		port := openflow.NewOutPort()
+		port.SetValue(v.Port)
+		action := NewAction()
+		action.SetOutPort(port)
+		if _, err := action.MarshalBinary(); (err == nil) != v.IsValid {
+			t.Fatalf("#%v: expected valid=%v, got err=%v", i, v.IsValid, err)
+		}
+	}
+}
+
+func TestGroupActionEncoding(t *testing.T) {
+	action := NewAction()
+	port := openflow.NewOutPort()
+	port.SetValue(1)
+	action.SetOutPort(port)
+	action.SetDecNWTTL()
+	action.SetGroup(0x12345678)
+
+	data, err := action.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	// The output is ignored if a group is set.
+	expected := []byte{
+		0x00, 0x18, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x16, 0x00, 0x08, 0x12, 0x34, 0x56, 0x78,
+	}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("unexpected encoding: expected=%x, got=%x", expected, data)
+	}
+
+	decoded := NewAction()
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if ok, id := decoded.Group(); !ok || id != 0x12345678 {
+		t.Fatalf("unexpected group: ok=%v, id=0x%X", ok, id)
+	}
+}
+
+func TestNATActionEncoding(t *testing.T) {
+	// Output to the controller.
+	output := []byte{0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfd, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	src := []struct {
+		Set      func(openflow.Action)
+		Expected []byte
+	}{
+		{
+			Set:      func(a openflow.Action) { a.SetIPSrc(net.ParseIP("10.0.0.1")) },
+			Expected: []byte{0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x16, 0x04, 0x0a, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			Set:      func(a openflow.Action) { a.SetIPDst(net.ParseIP("10.0.0.2")) },
+			Expected: []byte{0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x18, 0x04, 0x0a, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			Set: func(a openflow.Action) { a.SetIPSrc(net.ParseIP("2001:db8::1")) },
+			Expected: []byte{
+				0x00, 0x19, 0x00, 0x18, 0x80, 0x00, 0x34, 0x10,
+				0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+			},
+		},
+		{
+			Set: func(a openflow.Action) {
+				a.SetL4Protocol(6)
+				a.SetL4SrcPort(8080)
+			},
+			Expected: []byte{0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x1a, 0x02, 0x1f, 0x90, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			Set: func(a openflow.Action) {
+				a.SetL4Protocol(17)
+				a.SetL4DstPort(53)
+			},
+			Expected: []byte{0x00, 0x19, 0x00, 0x10, 0x80, 0x00, 0x20, 0x02, 0x00, 0x35, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+	}
+
+	for i, v := range src {
+		action := NewAction()
+		port := openflow.NewOutPort()
+		port.SetController()
+		action.SetOutPort(port)
+		v.Set(action)
+
+		data, err := action.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		expected := append(v.Expected, output...)
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, expected, data)
+		}
+
+		decoded := NewAction()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		ok1, ip1 := action.IPSrc()
+		ok2, ip2 := decoded.IPSrc()
+		if ok1 != ok2 || !ip1.Equal(ip2) {
+			t.Fatalf("#%v: unexpected decoded source IP: expected=%v/%v, got=%v/%v", i, ok1, ip1, ok2, ip2)
+		}
+		ok1, ip1 = action.IPDst()
+		ok2, ip2 = decoded.IPDst()
+		if ok1 != ok2 || !ip1.Equal(ip2) {
+			t.Fatalf("#%v: unexpected decoded destination IP: expected=%v/%v, got=%v/%v", i, ok1, ip1, ok2, ip2)
+		}
+		ok1, port1 := action.L4SrcPort()
+		ok2, port2 := decoded.L4SrcPort()
+		if ok1 != ok2 || port1 != port2 {
+			t.Fatalf("#%v: unexpected decoded source port: expected=%v/%v, got=%v/%v", i, ok1, port1, ok2, port2)
+		}
+		ok1, port1 = action.L4DstPort()
+		ok2, port2 = decoded.L4DstPort()
+		if ok1 != ok2 || port1 != port2 {
+			t.Fatalf("#%v: unexpected decoded destination port: expected=%v/%v, got=%v/%v", i, ok1, port1, ok2, port2)
+		}
+		ok1, proto1 := action.L4Protocol()
+		ok2, proto2 := decoded.L4Protocol()
+		if ok1 != ok2 || proto1 != proto2 {
+			t.Fatalf("#%v: unexpected decoded L4 protocol: expected=%v/%v, got=%v/%v", i, ok1, proto1, ok2, proto2)
+		}
+	}
+}
+
+func TestMissingL4Protocol(t *testing.T) {
+	action := NewAction()
+	action.SetL4SrcPort(8080)
+	if _, err := action.MarshalBinary(); err != openflow.ErrMissingIPProtocol {
+		t.Fatalf("expected ErrMissingIPProtocol, but got %v", err)
+	}
+}
+
+func TestValidateRewrites(t *testing.T) {
+	f := NewFactory()
+	src := []struct {
+		etherType uint16 // Zero means wildcard
+		protocol  uint8  // Zero means wildcard
+		set       func(openflow.Action)
+		valid     bool
+	}{
+		{0x0800, 0, func(a openflow.Action) { a.SetIPSrc(net.ParseIP("10.0.0.1")) }, true},
+		{0x86DD, 0, func(a openflow.Action) { a.SetIPSrc(net.ParseIP("10.0.0.1")) }, false},
+		{0, 0, func(a openflow.Action) { a.SetIPDst(net.ParseIP("10.0.0.1")) }, false},
+		{0x86DD, 0, func(a openflow.Action) { a.SetIPDst(net.ParseIP("2001:db8::1")) }, true},
+		{0x0800, 0, func(a openflow.Action) { a.SetIPDst(net.ParseIP("2001:db8::1")) }, false},
+		{0x0800, 6, func(a openflow.Action) { a.SetL4Protocol(6); a.SetL4DstPort(80) }, true},
+		{0x0800, 0, func(a openflow.Action) { a.SetL4Protocol(6); a.SetL4DstPort(80) }, false},
+		{0x0800, 17, func(a openflow.Action) { a.SetL4Protocol(6); a.SetL4DstPort(80) }, false},
+		{0x0800, 1, func(a openflow.Action) { a.SetL4SrcPort(80) }, false},
+		{0x8847, 0, func(a openflow.Action) { a.SetMPLSLabel(100) }, true},
+		{0x0800, 0, func(a openflow.Action) { a.SetMPLSLabel(100) }, false},
+		{0x0800, 0, func(a openflow.Action) { a.SetPushMPLS(0x8847); a.SetMPLSLabel(100) }, true},
+	}
+
+	for i, v := range src {
+		match, err := f.NewMatch()
+		if err != nil {
+			t.Fatalf("#%v: failed to create a match: %v", i, err)
+		}
+		if v.etherType != 0 {
+			match.SetEtherType(v.etherType)
+		}
+		if v.protocol != 0 {
+			match.SetIPProtocol(v.protocol)
+		}
+		action, err := f.NewAction()
+		if err != nil {
+			t.Fatalf("#%v: failed to create an action: %v", i, err)
+		}
+		port := openflow.NewOutPort()
+		port.SetValue(1)
+		action.SetOutPort(port)
+		v.set(action)
+		inst, err := f.NewInstruction()
+		if err != nil {
+			t.Fatalf("#%v: failed to create an instruction: %v", i, err)
+		}
+		inst.ApplyAction(action)
+		flow, err := f.NewFlowMod(openflow.FlowAdd)
+		if err != nil {
+			t.Fatalf("#%v: failed to create a flow-mod: %v", i, err)
+		}
+		flow.SetFlowMatch(match)
+		flow.SetFlowInstruction(inst)
+		if err := openflow.ValidateFlowMod(flow); (err == nil) != v.valid {
+			t.Fatalf("#%v: expected valid=%v, got err=%v", i, v.valid, err)
+		}
+	}
+}
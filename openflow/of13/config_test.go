@@ -0,0 +1,105 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+func TestSetConfig(t *testing.T) {
+	src := []struct {
+		Flags       openflow.ConfigFlag
+		MissSendLen uint16
+		Expected    []byte
+	}{
+		{
+			Flags:       openflow.FragNormal,
+			MissSendLen: 0xFFFF,
+			Expected:    []byte{0x04, OFPT_SET_CONFIG, 0x00, 0x0C, 0x00, 0x00, 0x00, 0x01, 0x00, OFPC_FRAG_NORMAL, 0xFF, 0xFF},
+		},
+		{
+			Flags:       openflow.FragDrop,
+			MissSendLen: 128,
+			Expected:    []byte{0x04, OFPT_SET_CONFIG, 0x00, 0x0C, 0x00, 0x00, 0x00, 0x01, 0x00, OFPC_FRAG_DROP, 0x00, 0x80},
+		},
+		{
+			Flags:       openflow.FragReasm,
+			MissSendLen: 0x1234,
+			Expected:    []byte{0x04, OFPT_SET_CONFIG, 0x00, 0x0C, 0x00, 0x00, 0x00, 0x01, 0x00, OFPC_FRAG_REASM, 0x12, 0x34},
+		},
+	}
+
+	for i, v := range src {
+		msg := NewSetConfig(1)
+		msg.SetFlags(v.Flags)
+		msg.SetMissSendLength(v.MissSendLen)
+		packet, err := msg.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal: %v", i, err)
+		}
+		if !bytes.Equal(packet, v.Expected) {
+			t.Fatalf("#%v: unexpected packet: expected=%x, got=%x", i, v.Expected, packet)
+		}
+	}
+
+	msg := NewSetConfig(1)
+	msg.SetFlags(openflow.ConfigFlag(0xFF))
+	if _, err := msg.MarshalBinary(); err == nil {
+		t.Fatalf("expected error for an invalid flag, but got nil")
+	}
+}
+
+func TestGetConfigReply(t *testing.T) {
+	src := []struct {
+		Packet      []byte
+		Flags       openflow.ConfigFlag
+		MissSendLen uint16
+	}{
+		{
+			Packet:      []byte{0x04, OFPT_GET_CONFIG_REPLY, 0x00, 0x0C, 0x00, 0x00, 0x00, 0x01, 0x00, OFPC_FRAG_DROP, 0x00, 0x80},
+			Flags:       openflow.FragDrop,
+			MissSendLen: 128,
+		},
+		// Flags that are not related with the fragment handling should be ignored.
+		{
+			Packet:      []byte{0x04, OFPT_GET_CONFIG_REPLY, 0x00, 0x0C, 0x00, 0x00, 0x00, 0x01, 0x00, 0x04 | OFPC_FRAG_REASM, 0xFF, 0xFF},
+			Flags:       openflow.FragReasm,
+			MissSendLen: 0xFFFF,
+		},
+	}
+
+	for i, v := range src {
+		msg := new(GetConfigReply)
+		if err := msg.UnmarshalBinary(v.Packet); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if msg.Flags() != v.Flags {
+			t.Fatalf("#%v: unexpected flags: expected=%v, got=%v", i, v.Flags, msg.Flags())
+		}
+		if msg.MissSendLength() != v.MissSendLen {
+			t.Fatalf("#%v: unexpected miss send length: expected=%v, got=%v", i, v.MissSendLen, msg.MissSendLength())
+		}
+	}
+}
@@ -65,8 +65,23 @@ const (
 )
 
 const (
-	OFPAT_OUTPUT    = 0
-	OFPAT_SET_FIELD = 25
+	OFPAT_OUTPUT       = 0      /* Output to switch port. */
+	OFPAT_COPY_TTL_OUT = 11     /* Copy TTL "outwards" -- from next-to-outermost to outermost */
+	OFPAT_COPY_TTL_IN  = 12     /* Copy TTL "inwards" -- from outermost to next-to-outermost */
+	OFPAT_SET_MPLS_TTL = 15     /* MPLS TTL */
+	OFPAT_DEC_MPLS_TTL = 16     /* Decrement MPLS TTL */
+	OFPAT_PUSH_VLAN    = 17     /* Push a new VLAN tag */
+	OFPAT_POP_VLAN     = 18     /* Pop the outer VLAN tag */
+	OFPAT_PUSH_MPLS    = 19     /* Push a new MPLS tag */
+	OFPAT_POP_MPLS     = 20     /* Pop the outer MPLS tag */
+	OFPAT_SET_QUEUE    = 21     /* Set queue id when outputting to a port */
+	OFPAT_GROUP        = 22     /* Apply group. */
+	OFPAT_SET_NW_TTL   = 23     /* IP TTL. */
+	OFPAT_DEC_NW_TTL   = 24     /* Decrement IP TTL. */
+	OFPAT_SET_FIELD    = 25     /* Set a header field using OXM TLV format. */
+	OFPAT_PUSH_PBB     = 26     /* Push a new PBB service tag (I-TAG) */
+	OFPAT_POP_PBB      = 27     /* Pop the outer PBB service tag (I-TAG) */
+	OFPAT_EXPERIMENTER = 0xffff /* Experimenter action. */
 )
 
 const (
@@ -84,6 +99,11 @@ const (
 	OFPP_ANY        = 0xffffffff /* Wildcard */
 )
 
+const (
+	OFPVID_PRESENT = 0x1000 /* Bit that indicate that a VLAN id is set */
+	OFPVID_NONE    = 0x0000 /* No VLAN id was set. */
+)
+
 const (
 	OFPXMT_OFB_IN_PORT = iota
 	OFPXMT_OFB_IN_PHY_PORT
@@ -144,6 +164,11 @@ const (
 	OFP_NO_BUFFER = 0xffffffff
 )
 
+const (
+	OFPCML_MAX       = 0xffe5 /* maximum max_len value which can be used to request a specific byte length. */
+	OFPCML_NO_BUFFER = 0xffff /* indicates that no buffering should be applied and the whole packet is to be sent to the controller. */
+)
+
 const (
 	OFPFF_SEND_FLOW_REM = 1 << 0 /* Send flow removed message when flow expires or is deleted. */
 	OFPFF_CHECK_OVERLAP = 1 << 1 /* Check for overlapping entries first. */
@@ -152,6 +177,51 @@ const (
 	OFPFF_NO_BYT_COUNTS = 1 << 4 /* Don't keep track of byte count. */
 )
 
+const (
+	OFPGC_ADD    = 0 /* New group. */
+	OFPGC_MODIFY = 1 /* Modify all matching groups. */
+	OFPGC_DELETE = 2 /* Delete all matching groups. */
+)
+
+const (
+	OFPGT_ALL      = 0 /* All (multicast/broadcast) group. */
+	OFPGT_SELECT   = 1 /* Select group. */
+	OFPGT_INDIRECT = 2 /* Indirect group. */
+	OFPGT_FF       = 3 /* Fast failover group. */
+)
+
+const (
+	OFPG_MAX = 0xffffff00 /* Last usable group number. */
+	OFPG_ALL = 0xfffffffc /* Represents all groups for group delete commands. */
+	OFPG_ANY = 0xffffffff /* Wildcard group used only for flow stats requests. */
+)
+
+const (
+	OFPMC_ADD    = 0 /* New meter. */
+	OFPMC_MODIFY = 1 /* Modify specified meter. */
+	OFPMC_DELETE = 2 /* Delete specified meter. */
+)
+
+const (
+	OFPMF_KBPS  = 1 << 0 /* Rate value in kb/s (kilo-bit per second). */
+	OFPMF_PKTPS = 1 << 1 /* Rate value in packet/sec. */
+	OFPMF_BURST = 1 << 2 /* Do burst size. */
+	OFPMF_STATS = 1 << 3 /* Collect statistics. */
+)
+
+const (
+	OFPMBT_DROP         = 1      /* Drop packet. */
+	OFPMBT_DSCP_REMARK  = 2      /* Remark DSCP in the IP header. */
+	OFPMBT_EXPERIMENTER = 0xFFFF /* Experimenter meter band. */
+)
+
+const (
+	OFPM_MAX        = 0xffff0000 /* Last usable meter. */
+	OFPM_SLOWPATH   = 0xfffffffd /* Meter for slow datapath. */
+	OFPM_CONTROLLER = 0xfffffffe /* Meter for controller connection. */
+	OFPM_ALL        = 0xffffffff /* Represents all meters for stat requests commands. */
+)
+
 const (
 	OFPPC_PORT_DOWN    = 1 << 0 /* Port is administratively down. */
 	OFPPC_NO_RECV      = 1 << 2
@@ -252,7 +322,7 @@ const (
 )
 
 const (
-	OFPG_ANY = 0xffffffff
+	OFPMPF_REPLY_MORE = 1 << 0 /* More replies to follow. */
 )
 
 const (
@@ -268,6 +338,15 @@ const (
 	OFPPR_MODIFY = 2
 )
 
+const (
+	OFPTT_MAX = 0xfe /* Last usable table number */
+	OFPTT_ALL = 0xff /* Wildcard table used for table config, flow stats and flow deletes */
+)
+
+const (
+	OFPTC_DEPRECATED_MASK = 3 /* Deprecated bits */
+)
+
 const (
 	OFPIT_GOTO_TABLE     = 1      /* Setup the next table in the lookup pipeline */
 	OFPIT_WRITE_METADATA = 2      /* Setup the metadata field for use later in pipeline */
@@ -277,3 +356,28 @@ const (
 	OFPIT_METER          = 6      /* Apply meter (rate limiter) */
 	OFPIT_EXPERIMENTER   = 0xFFFF /* Experimenter instruction */
 )
+
+const (
+	OFPHET_VERSIONBITMAP = 1 /* Bitmap of version supported. */
+)
+
+const (
+	OFPET_HELLO_FAILED    = 0 /* Hello protocol failed. */
+	OFPET_FLOW_MOD_FAILED = 5 /* Problem modifying flow entry. */
+)
+
+const (
+	OFPFMFC_UNKNOWN      = 0 /* Unspecified error. */
+	OFPFMFC_TABLE_FULL   = 1 /* Flow not added because table was full. */
+	OFPFMFC_BAD_TABLE_ID = 2 /* Table does not exist */
+	OFPFMFC_OVERLAP      = 3 /* Attempted to add overlapping flow with CHECK_OVERLAP flag set. */
+	OFPFMFC_EPERM        = 4 /* Permissions error. */
+	OFPFMFC_BAD_TIMEOUT  = 5 /* Flow not added because of unsupported idle/hard timeout. */
+	OFPFMFC_BAD_COMMAND  = 6 /* Unsupported or unknown command. */
+	OFPFMFC_BAD_FLAGS    = 7 /* Unsupported or unknown flags. */
+)
+
+const (
+	OFPHFC_INCOMPATIBLE = 0 /* No compatible version. */
+	OFPHFC_EPERM        = 1 /* Permissions error. */
+)
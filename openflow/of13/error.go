@@ -0,0 +1,55 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"github.com/superkkt/cherry/openflow"
+)
+
+// NewFlowModError returns a *openflow.FlowModError if class is OFPET_FLOW_MOD_FAILED. Otherwise, it returns nil.
+func NewFlowModError(xid uint32, class, code uint16) error {
+	if class != OFPET_FLOW_MOD_FAILED {
+		return nil
+	}
+
+	var reason error
+	switch code {
+	case OFPFMFC_TABLE_FULL:
+		reason = openflow.ErrFlowTableFull
+	case OFPFMFC_BAD_TABLE_ID:
+		reason = openflow.ErrFlowBadTableID
+	case OFPFMFC_OVERLAP:
+		reason = openflow.ErrFlowOverlap
+	case OFPFMFC_EPERM:
+		reason = openflow.ErrFlowPermission
+	case OFPFMFC_BAD_TIMEOUT:
+		reason = openflow.ErrFlowBadTimeout
+	case OFPFMFC_BAD_COMMAND:
+		reason = openflow.ErrFlowBadCommand
+	case OFPFMFC_BAD_FLAGS:
+		reason = openflow.ErrFlowBadFlags
+	default:
+		reason = openflow.ErrFlowModFailed
+	}
+
+	return &openflow.FlowModError{XID: xid, Code: code, Reason: reason}
+}
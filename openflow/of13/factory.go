@@ -114,10 +114,18 @@ func (r *Factory) NewFlowMod(cmd openflow.FlowModCmd) (openflow.FlowMod, error)
 	return NewFlowMod(r.getTransactionID(), getFlowModCmd(cmd)), nil
 }
 
+func (r *Factory) NewGroupMod(cmd openflow.GroupCommand) (openflow.GroupMod, error) {
+	return NewGroupMod(r.getTransactionID(), cmd), nil
+}
+
 func (r *Factory) NewFlowRemoved() (openflow.FlowRemoved, error) {
 	return new(FlowRemoved), nil
 }
 
+func (r *Factory) NewMeterMod(cmd openflow.MeterCommand) (openflow.MeterMod, error) {
+	return NewMeterMod(r.getTransactionID(), cmd), nil
+}
+
 func (r *Factory) NewPacketIn() (openflow.PacketIn, error) {
 	return new(PacketIn), nil
 }
@@ -142,7 +150,17 @@ func (r *Factory) NewFlowStatsRequest() (openflow.FlowStatsRequest, error) {
 	return NewFlowStatsRequest(r.getTransactionID()), nil
 }
 
-// TODO: NewFlowStatsReply() (openflow.FlowStatsReply, error) {
+func (r *Factory) NewFlowStatsReply() (openflow.FlowStatsReply, error) {
+	return new(FlowStatsReply), nil
+}
+
+func (r *Factory) NewPortStatsRequest() (openflow.PortStatsRequest, error) {
+	return NewPortStatsRequest(r.getTransactionID()), nil
+}
+
+func (r *Factory) NewPortStatsReply() (openflow.PortStatsReply, error) {
+	return new(PortStatsReply), nil
+}
 
 func (r *Factory) NewPortDescRequest() (openflow.PortDescRequest, error) {
 	return NewPortDescRequest(r.getTransactionID()), nil
@@ -152,10 +170,26 @@ func (r *Factory) NewPortDescReply() (openflow.PortDescReply, error) {
 	return new(PortDescReply), nil
 }
 
+func (r *Factory) NewPortMod() (openflow.PortMod, error) {
+	return NewPortMod(r.getTransactionID()), nil
+}
+
 func (r *Factory) NewTableFeaturesRequest() (openflow.TableFeaturesRequest, error) {
 	return NewTableFeaturesRequest(r.getTransactionID()), nil
 }
 
+func (r *Factory) NewTableMod() (openflow.TableMod, error) {
+	return NewTableMod(r.getTransactionID()), nil
+}
+
+func (r *Factory) NewTableStatsRequest() (openflow.TableStatsRequest, error) {
+	return NewTableStatsRequest(r.getTransactionID()), nil
+}
+
+func (r *Factory) NewTableStatsReply() (openflow.TableStatsReply, error) {
+	return new(TableStatsReply), nil
+}
+
 func (r *Factory) NewError() (openflow.Error, error) {
 	return new(openflow.BaseError), nil
 }
@@ -166,6 +200,28 @@ func (r *Factory) NewInstruction() (openflow.Instruction, error) {
 	return new(Instruction), nil
 }
 
+func (r *Factory) NewGotoTableInstruction(tableID uint8) (openflow.Instruction, error) {
+	if tableID > OFPTT_MAX {
+		return nil, fmt.Errorf("invalid table ID for goto-table: %v", tableID)
+	}
+	inst := new(Instruction)
+	inst.GotoTable(tableID)
+
+	return inst, nil
+}
+
 func (r *Factory) NewQueueGetConfigRequest() (openflow.QueueGetConfigRequest, error) {
 	return NewQueueGetConfigRequest(r.getTransactionID()), nil
 }
+
+func (r *Factory) NewQueueGetConfigReply() (openflow.QueueGetConfigReply, error) {
+	return new(QueueGetConfigReply), nil
+}
+
+func (r *Factory) NewRoleRequest() (openflow.RoleRequest, error) {
+	return NewRoleRequest(r.getTransactionID()), nil
+}
+
+func (r *Factory) NewRoleReply() (openflow.RoleReply, error) {
+	return new(RoleReply), nil
+}
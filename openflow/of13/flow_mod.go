@@ -41,6 +41,7 @@ type FlowMod struct {
 	match       openflow.Match
 	instruction openflow.Instruction
 	outPort     openflow.OutPort
+	flags       openflow.FlowModFlags
 }
 
 func NewFlowMod(xid uint32, cmd uint8) openflow.FlowMod {
@@ -52,6 +53,7 @@ func NewFlowMod(xid uint32, cmd uint8) openflow.FlowMod {
 		Message: openflow.NewMessage(openflow.OF13_VERSION, OFPT_FLOW_MOD, xid),
 		command: cmd,
 		outPort: outPort,
+		flags:   openflow.DefaultFlowModFlags,
 	}
 }
 
@@ -59,6 +61,17 @@ func (r *FlowMod) Error() error {
 	return r.err
 }
 
+func (r *FlowMod) Command() openflow.FlowModCmd {
+	switch r.command {
+	case OFPFC_MODIFY, OFPFC_MODIFY_STRICT:
+		return openflow.FlowModify
+	case OFPFC_DELETE, OFPFC_DELETE_STRICT:
+		return openflow.FlowDelete
+	default:
+		return openflow.FlowAdd
+	}
+}
+
 func (r *FlowMod) Cookie() uint64 {
 	return r.cookie
 }
@@ -129,6 +142,14 @@ func (r *FlowMod) SetFlowInstruction(inst openflow.Instruction) {
 	r.instruction = inst
 }
 
+func (r *FlowMod) Flags() openflow.FlowModFlags {
+	return r.flags
+}
+
+func (r *FlowMod) SetFlags(flags openflow.FlowModFlags) {
+	r.flags = flags
+}
+
 func (r *FlowMod) OutPort() openflow.OutPort {
 	return r.outPort
 }
@@ -137,6 +158,27 @@ func (r *FlowMod) SetOutPort(p openflow.OutPort) {
 	r.outPort = p
 }
 
+func (r *FlowMod) marshalFlags() uint16 {
+	var v uint16
+	if r.flags.SendFlowRemoved {
+		v |= OFPFF_SEND_FLOW_REM
+	}
+	if r.flags.CheckOverlap {
+		v |= OFPFF_CHECK_OVERLAP
+	}
+	if r.flags.ResetCounts {
+		v |= OFPFF_RESET_COUNTS
+	}
+	if r.flags.NoPacketCounts {
+		v |= OFPFF_NO_PKT_COUNTS
+	}
+	if r.flags.NoByteCounts {
+		v |= OFPFF_NO_BYT_COUNTS
+	}
+
+	return v
+}
+
 func (r *FlowMod) MarshalBinary() ([]byte, error) {
 	if r.err != nil {
 		return nil, r.err
@@ -157,13 +199,18 @@ func (r *FlowMod) MarshalBinary() ([]byte, error) {
 		binary.BigEndian.PutUint32(v[28:32], r.outPort.Value())
 	}
 	binary.BigEndian.PutUint32(v[32:36], OFPP_ANY)
-	binary.BigEndian.PutUint16(v[36:38], OFPFF_SEND_FLOW_REM)
+	binary.BigEndian.PutUint16(v[36:38], r.marshalFlags())
 	// v[38:40] is padding
 
-	if r.match == nil {
-		return nil, errors.New("empty flow match")
+	m := r.match
+	if m == nil {
+		if r.Command() != openflow.FlowDelete {
+			return nil, errors.New("empty flow match")
+		}
+		// A delete request without a match removes all the flows.
+		m = NewMatch()
 	}
-	match, err := r.match.MarshalBinary()
+	match, err := m.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,253 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/superkkt/cherry/openflow"
+
+	"github.com/pkg/errors"
+)
+
+type GroupMod struct {
+	err error
+	openflow.Message
+	command   openflow.GroupCommand
+	groupType openflow.GroupType
+	groupID   uint32
+	buckets   []openflow.Bucket
+}
+
+func NewGroupMod(xid uint32, cmd openflow.GroupCommand) openflow.GroupMod {
+	return &GroupMod{
+		Message: openflow.NewMessage(openflow.OF13_VERSION, OFPT_GROUP_MOD, xid),
+		command: cmd,
+	}
+}
+
+func (r *GroupMod) Error() error {
+	return r.err
+}
+
+func (r *GroupMod) Command() openflow.GroupCommand {
+	return r.command
+}
+
+func (r *GroupMod) GroupType() openflow.GroupType {
+	return r.groupType
+}
+
+func (r *GroupMod) SetGroupType(t openflow.GroupType) {
+	r.groupType = t
+}
+
+func (r *GroupMod) GroupID() uint32 {
+	return r.groupID
+}
+
+func (r *GroupMod) SetGroupID(id uint32) {
+	r.groupID = id
+}
+
+func (r *GroupMod) Buckets() []openflow.Bucket {
+	return r.buckets
+}
+
+func (r *GroupMod) AddBucket(b openflow.Bucket) {
+	if b.Action == nil {
+		r.err = errors.New("AddBucket: nil bucket action")
+		return
+	}
+	r.buckets = append(r.buckets, b)
+}
+
+func getGroupCommand(cmd openflow.GroupCommand) (uint16, error) {
+	switch cmd {
+	case openflow.GroupAdd:
+		return OFPGC_ADD, nil
+	case openflow.GroupModify:
+		return OFPGC_MODIFY, nil
+	case openflow.GroupDelete:
+		return OFPGC_DELETE, nil
+	default:
+		return 0, fmt.Errorf("unexpected group command: %v", cmd)
+	}
+}
+
+func getGroupType(t openflow.GroupType) (uint8, error) {
+	switch t {
+	case openflow.GroupAll:
+		return OFPGT_ALL, nil
+	case openflow.GroupSelect:
+		return OFPGT_SELECT, nil
+	case openflow.GroupIndirect:
+		return OFPGT_INDIRECT, nil
+	case openflow.GroupFastFailover:
+		return OFPGT_FF, nil
+	default:
+		return 0, fmt.Errorf("unexpected group type: %v", t)
+	}
+}
+
+func (r *GroupMod) validate() error {
+	if r.command == openflow.GroupDelete {
+		if r.groupID > OFPG_MAX && r.groupID != OFPG_ALL {
+			return fmt.Errorf("invalid group ID: %v", r.groupID)
+		}
+		return nil
+	}
+	if r.groupID > OFPG_MAX {
+		return fmt.Errorf("invalid group ID: %v", r.groupID)
+	}
+
+	switch r.groupType {
+	case openflow.GroupIndirect:
+		if len(r.buckets) != 1 {
+			return fmt.Errorf("indirect group should have exactly one bucket: %v buckets", len(r.buckets))
+		}
+	case openflow.GroupFastFailover:
+		for i, b := range r.buckets {
+			if b.WatchPort == openflow.WatchAny && b.WatchGroup == openflow.WatchAny {
+				return fmt.Errorf("bucket #%v of the fast failover group does not watch any port or group", i)
+			}
+		}
+	}
+	for i, b := range r.buckets {
+		if r.groupType == openflow.GroupSelect && b.Weight == 0 {
+			return fmt.Errorf("bucket #%v of the select group has zero weight", i)
+		}
+		if r.groupType != openflow.GroupSelect && b.Weight != 0 {
+			return fmt.Errorf("bucket #%v has weight but the group is not a select group", i)
+		}
+	}
+
+	return nil
+}
+
+func marshalBucket(b openflow.Bucket) ([]byte, error) {
+	action, err := b.Action.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint16(v[0:2], uint16(16+len(action)))
+	binary.BigEndian.PutUint16(v[2:4], b.Weight)
+	binary.BigEndian.PutUint32(v[4:8], b.WatchPort)
+	binary.BigEndian.PutUint32(v[8:12], b.WatchGroup)
+	// v[12:16] is padding
+
+	return append(v, action...), nil
+}
+
+func (r *GroupMod) MarshalBinary() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	cmd, err := getGroupCommand(r.command)
+	if err != nil {
+		return nil, err
+	}
+	t, err := getGroupType(r.groupType)
+	if err != nil {
+		return nil, err
+	}
+
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], cmd)
+	v[2] = t
+	// v[3] is padding
+	binary.BigEndian.PutUint32(v[4:8], r.groupID)
+	for _, b := range r.buckets {
+		bucket, err := marshalBucket(b)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, bucket...)
+	}
+
+	r.SetPayload(v)
+	return r.Message.MarshalBinary()
+}
+
+func (r *GroupMod) UnmarshalBinary(data []byte) error {
+	if err := r.Message.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	payload := r.Payload()
+	if payload == nil || len(payload) < 8 {
+		return openflow.ErrInvalidPacketLength
+	}
+
+	switch binary.BigEndian.Uint16(payload[0:2]) {
+	case OFPGC_ADD:
+		r.command = openflow.GroupAdd
+	case OFPGC_MODIFY:
+		r.command = openflow.GroupModify
+	case OFPGC_DELETE:
+		r.command = openflow.GroupDelete
+	default:
+		return fmt.Errorf("unexpected group command: %v", binary.BigEndian.Uint16(payload[0:2]))
+	}
+	switch payload[2] {
+	case OFPGT_ALL:
+		r.groupType = openflow.GroupAll
+	case OFPGT_SELECT:
+		r.groupType = openflow.GroupSelect
+	case OFPGT_INDIRECT:
+		r.groupType = openflow.GroupIndirect
+	case OFPGT_FF:
+		r.groupType = openflow.GroupFastFailover
+	default:
+		return fmt.Errorf("unexpected group type: %v", payload[2])
+	}
+	r.groupID = binary.BigEndian.Uint32(payload[4:8])
+
+	r.buckets = nil
+	buf := payload[8:]
+	for len(buf) >= 16 {
+		length := binary.BigEndian.Uint16(buf[0:2])
+		if length < 16 || len(buf) < int(length) {
+			return openflow.ErrInvalidPacketLength
+		}
+		action := NewAction()
+		if err := action.UnmarshalBinary(buf[16:length]); err != nil {
+			return err
+		}
+		r.buckets = append(r.buckets, openflow.Bucket{
+			Weight:     binary.BigEndian.Uint16(buf[2:4]),
+			WatchPort:  binary.BigEndian.Uint32(buf[4:8]),
+			WatchGroup: binary.BigEndian.Uint32(buf[8:12]),
+			Action:     action,
+		})
+		buf = buf[length:]
+	}
+
+	return nil
+}
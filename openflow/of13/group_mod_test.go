@@ -0,0 +1,151 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+func newOutputBucket(port uint32) openflow.Bucket {
+	action := NewAction()
+	out := openflow.NewOutPort()
+	out.SetValue(port)
+	action.SetOutPort(out)
+
+	return openflow.NewBucket(action)
+}
+
+func TestGroupModRoundTrip(t *testing.T) {
+	src := []struct {
+		Command openflow.GroupCommand
+		Type    openflow.GroupType
+		ID      uint32
+		Buckets []openflow.Bucket
+	}{
+		{
+			Command: openflow.GroupAdd,
+			Type:    openflow.GroupAll,
+			ID:      1,
+			Buckets: []openflow.Bucket{newOutputBucket(1), newOutputBucket(2), newOutputBucket(3)},
+		},
+		{
+			Command: openflow.GroupModify,
+			Type:    openflow.GroupSelect,
+			ID:      2,
+			Buckets: func() []openflow.Bucket {
+				b1, b2 := newOutputBucket(1), newOutputBucket(2)
+				b1.Weight, b2.Weight = 10, 20
+				return []openflow.Bucket{b1, b2}
+			}(),
+		},
+		{
+			Command: openflow.GroupAdd,
+			Type:    openflow.GroupIndirect,
+			ID:      3,
+			Buckets: []openflow.Bucket{newOutputBucket(4)},
+		},
+		{
+			Command: openflow.GroupAdd,
+			Type:    openflow.GroupFastFailover,
+			ID:      OFPG_MAX,
+			Buckets: func() []openflow.Bucket {
+				b1, b2 := newOutputBucket(1), newOutputBucket(2)
+				b1.WatchPort, b2.WatchGroup = 1, 3
+				return []openflow.Bucket{b1, b2}
+			}(),
+		},
+		{
+			Command: openflow.GroupDelete,
+			Type:    openflow.GroupAll,
+			ID:      OFPG_ALL,
+		},
+	}
+
+	for i, v := range src {
+		mod := NewGroupMod(1, v.Command)
+		mod.SetGroupType(v.Type)
+		mod.SetGroupID(v.ID)
+		for _, b := range v.Buckets {
+			mod.AddBucket(b)
+		}
+		packet, err := mod.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal: %v", i, err)
+		}
+
+		got := new(GroupMod)
+		if err := got.UnmarshalBinary(packet); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if got.Type() != OFPT_GROUP_MOD {
+			t.Fatalf("#%v: unexpected message type: %v", i, got.Type())
+		}
+		if got.Command() != v.Command || got.GroupType() != v.Type || got.GroupID() != v.ID {
+			t.Fatalf("#%v: unexpected group: command=%v, type=%v, id=%v", i, got.Command(), got.GroupType(), got.GroupID())
+		}
+		if len(got.Buckets()) != len(v.Buckets) {
+			t.Fatalf("#%v: unexpected number of buckets: expected=%v, got=%v", i, len(v.Buckets), len(got.Buckets()))
+		}
+		for j, b := range got.Buckets() {
+			expected := v.Buckets[j]
+			if b.Weight != expected.Weight || b.WatchPort != expected.WatchPort || b.WatchGroup != expected.WatchGroup {
+				t.Fatalf("#%v: unexpected bucket #%v: expected=%+v, got=%+v", i, j, expected, b)
+			}
+			ports := b.Action.OutPorts()
+			if len(ports) != 1 || ports[0].Value() != expected.Action.OutPorts()[0].Value() {
+				t.Fatalf("#%v: unexpected output ports of bucket #%v: %v", i, j, ports)
+			}
+		}
+	}
+}
+
+func TestInvalidGroupMod(t *testing.T) {
+	src := []struct {
+		Command openflow.GroupCommand
+		Type    openflow.GroupType
+		ID      uint32
+		Buckets []openflow.Bucket
+	}{
+		// Reserved group ID.
+		{Command: openflow.GroupAdd, Type: openflow.GroupAll, ID: OFPG_ALL},
+		// Select group without weights.
+		{Command: openflow.GroupAdd, Type: openflow.GroupSelect, ID: 1, Buckets: []openflow.Bucket{newOutputBucket(1)}},
+		// Indirect group with two buckets.
+		{Command: openflow.GroupAdd, Type: openflow.GroupIndirect, ID: 1, Buckets: []openflow.Bucket{newOutputBucket(1), newOutputBucket(2)}},
+		// Fast failover group that does not watch anything.
+		{Command: openflow.GroupAdd, Type: openflow.GroupFastFailover, ID: 1, Buckets: []openflow.Bucket{newOutputBucket(1)}},
+	}
+
+	for i, v := range src {
+		mod := NewGroupMod(1, v.Command)
+		mod.SetGroupType(v.Type)
+		mod.SetGroupID(v.ID)
+		for _, b := range v.Buckets {
+			mod.AddBucket(b)
+		}
+		if _, err := mod.MarshalBinary(); err == nil {
+			t.Fatalf("#%v: expected an error", i)
+		}
+	}
+}
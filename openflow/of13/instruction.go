@@ -25,13 +25,16 @@ import (
 	"encoding"
 	"encoding/binary"
 	"errors"
+	"fmt"
 
 	"github.com/superkkt/cherry/openflow"
 )
 
 type Instruction struct {
-	err   error
-	value encoding.BinaryMarshaler
+	err      error
+	value    encoding.BinaryMarshaler
+	meter    uint32
+	metadata *writeMetadata
 }
 
 type gotoTable struct {
@@ -48,6 +51,22 @@ func (r *gotoTable) MarshalBinary() ([]byte, error) {
 	return v, nil
 }
 
+type writeMetadata struct {
+	value uint64
+	mask  uint64
+}
+
+func (r *writeMetadata) MarshalBinary() ([]byte, error) {
+	v := make([]byte, 24)
+	binary.BigEndian.PutUint16(v[0:2], OFPIT_WRITE_METADATA)
+	binary.BigEndian.PutUint16(v[2:4], 24)
+	// v[4:8] is padding
+	binary.BigEndian.PutUint64(v[8:16], r.value)
+	binary.BigEndian.PutUint64(v[16:24], r.mask)
+
+	return v, nil
+}
+
 type writeAction struct {
 	action openflow.Action
 }
@@ -114,14 +133,92 @@ func (r *Instruction) ApplyAction(act openflow.Action) {
 	r.value = &applyAction{action: act}
 }
 
+func (r *Instruction) Action() openflow.Action {
+	switch v := r.value.(type) {
+	case *writeAction:
+		return v.action
+	case *applyAction:
+		return v.action
+	default:
+		return nil
+	}
+}
+
+func (r *Instruction) GotoTableID() (ok bool, tableID uint8) {
+	v, ok := r.value.(*gotoTable)
+	if !ok {
+		return false, 0
+	}
+
+	return true, v.tableID
+}
+
+func (r *Instruction) Meter() uint32 {
+	return r.meter
+}
+
+func (r *Instruction) SetMeter(meterID uint32) {
+	if meterID == 0 || meterID > OFPM_MAX {
+		r.err = fmt.Errorf("SetMeter: invalid meter ID: %v", meterID)
+		return
+	}
+	r.meter = meterID
+}
+
+func (r *Instruction) Metadata() (ok bool, value, mask uint64) {
+	if r.metadata == nil {
+		return false, 0, 0
+	}
+
+	return true, r.metadata.value, r.metadata.mask
+}
+
+func (r *Instruction) WriteMetadata(value, mask uint64) {
+	if mask == 0 {
+		r.err = errors.New("WriteMetadata: empty mask")
+		return
+	}
+	// The bits out of the mask are not written anyway.
+	r.metadata = &writeMetadata{value: value & mask, mask: mask}
+}
+
+func marshalMeter(meterID uint32) []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], OFPIT_METER)
+	binary.BigEndian.PutUint16(v[2:4], 8)
+	binary.BigEndian.PutUint32(v[4:8], meterID)
+
+	return v
+}
+
 func (r *Instruction) MarshalBinary() ([]byte, error) {
 	if r.err != nil {
 		return nil, r.err
 	}
 
-	if r.value == nil {
+	if r.value == nil && r.metadata == nil {
 		return nil, errors.New("empty action of an instruction")
 	}
 
-	return r.value.MarshalBinary()
+	v := make([]byte, 0)
+	// The meter instruction should be executed before the other instructions.
+	if r.meter != 0 {
+		v = append(v, marshalMeter(r.meter)...)
+	}
+	if r.metadata != nil {
+		metadata, err := r.metadata.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, metadata...)
+	}
+	if r.value != nil {
+		value, err := r.value.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, value...)
+	}
+
+	return v, nil
 }
@@ -0,0 +1,138 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+func TestGotoTableInstruction(t *testing.T) {
+	f := NewFactory()
+	inst, err := f.NewGotoTableInstruction(1)
+	if err != nil {
+		t.Fatalf("failed to create a goto-table instruction: %v", err)
+	}
+	if ok, tableID := inst.GotoTableID(); !ok || tableID != 1 {
+		t.Fatalf("unexpected goto-table: ok=%v, tableID=%v", ok, tableID)
+	}
+	if inst.Action() != nil {
+		t.Fatalf("unexpected action: %v", inst.Action())
+	}
+
+	data, err := inst.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	expected := []byte{0x00, 0x01, 0x00, 0x08, 0x01, 0x00, 0x00, 0x00}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("unexpected goto-table instruction: expected=%v, got=%v", expected, data)
+	}
+
+	// The meter instruction precedes the goto-table.
+	inst.SetMeter(3)
+	data, err = inst.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if len(data) != 16 || binary.BigEndian.Uint16(data[0:2]) != OFPIT_METER || !bytes.Equal(data[8:], expected) {
+		t.Fatalf("unexpected metered goto-table instruction: %v", data)
+	}
+
+	if _, err := f.NewGotoTableInstruction(OFPTT_ALL); err == nil {
+		t.Fatal("expected an error for the invalid table ID")
+	}
+}
+
+func TestValidateGotoTable(t *testing.T) {
+	f := NewFactory()
+	src := []struct {
+		from, to uint8
+		valid    bool
+	}{
+		{0, 1, true},
+		{0, 200, true},
+		{1, 1, false},
+		{2, 1, false},
+	}
+
+	for i, v := range src {
+		inst, err := f.NewGotoTableInstruction(v.to)
+		if err != nil {
+			t.Fatalf("#%v: failed to create a goto-table instruction: %v", i, err)
+		}
+		match, err := f.NewMatch()
+		if err != nil {
+			t.Fatalf("#%v: failed to create a match: %v", i, err)
+		}
+		flow, err := f.NewFlowMod(openflow.FlowAdd)
+		if err != nil {
+			t.Fatalf("#%v: failed to create a flow-mod: %v", i, err)
+		}
+		flow.SetTableID(v.from)
+		flow.SetFlowMatch(match)
+		flow.SetFlowInstruction(inst)
+		if err := openflow.ValidateFlowMod(flow); (err == nil) != v.valid {
+			t.Fatalf("#%v: expected valid=%v, got err=%v", i, v.valid, err)
+		}
+	}
+}
+
+func TestWriteMetadataInstruction(t *testing.T) {
+	f := NewFactory()
+	inst, err := f.NewGotoTableInstruction(1)
+	if err != nil {
+		t.Fatalf("failed to create a goto-table instruction: %v", err)
+	}
+	// Bits out of the mask should be cleared.
+	inst.WriteMetadata(0x1234, 0xff)
+	if ok, value, mask := inst.Metadata(); !ok || value != 0x34 || mask != 0xff {
+		t.Fatalf("unexpected metadata: ok=%v, value=%#x, mask=%#x", ok, value, mask)
+	}
+	if ok, tableID := inst.GotoTableID(); !ok || tableID != 1 {
+		t.Fatalf("unexpected goto-table: ok=%v, tableID=%v", ok, tableID)
+	}
+
+	data, err := inst.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	expected := []byte{
+		// Write-metadata.
+		0x00, 0x02, 0x00, 0x18, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff,
+		// Goto-table.
+		0x00, 0x01, 0x00, 0x08, 0x01, 0x00, 0x00, 0x00,
+	}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("unexpected write-metadata instruction: expected=%x, got=%x", expected, data)
+	}
+
+	inst.WriteMetadata(1, 0)
+	if inst.Error() == nil {
+		t.Fatal("expected an error for the empty mask")
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 
 	"github.com/superkkt/cherry/openflow"
@@ -235,14 +236,8 @@ func (r *Match) SetIPProtocol(p uint8) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	etherType, ok := r.m[OFPXMT_OFB_ETH_TYPE]
-	if !ok {
-		r.err = errors.Wrap(openflow.ErrMissingEtherType, "SetIPProtocol")
-		return
-	}
-	// IPv4?
-	if etherType.(uint16) != 0x0800 {
-		r.err = errors.Wrap(openflow.ErrUnsupportedEtherType, "SetIPProtocol")
+	if err := r.checkIPEtherType("SetIPProtocol"); err != nil {
+		r.err = err
 		return
 	}
 
@@ -289,6 +284,32 @@ func (r *Match) InPort() (wildcard bool, inport openflow.InPort) {
 	return true, openflow.NewInPort()
 }
 
+func (r *Match) SetWildcardPhysicalInPort() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.m, OFPXMT_OFB_IN_PHY_PORT)
+}
+
+func (r *Match) SetPhysicalInPort(port uint32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.m[OFPXMT_OFB_IN_PHY_PORT] = port
+}
+
+func (r *Match) PhysicalInPort() (wildcard bool, port uint32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.m[OFPXMT_OFB_IN_PHY_PORT]
+	if !ok {
+		return true, 0
+	}
+
+	return false, v.(uint32)
+}
+
 func (r *Match) SetWildcardSrcMAC() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -437,6 +458,302 @@ func (r *Match) DstIP() *net.IPNet {
 	}
 }
 
+func (r *Match) setIPv6(field uint, name string, ip *net.IPNet) {
+	if ip == nil {
+		panic("ip is nil")
+	}
+	if ip.IP.To16() == nil || ip.IP.To4() != nil {
+		r.err = errors.Wrap(openflow.ErrInvalidIPAddress, name)
+		return
+	}
+	if ones, bits := ip.Mask.Size(); bits != 128 || ones == 0 {
+		r.err = errors.Wrapf(openflow.ErrInvalidIPAddress, "%v: invalid IPv6 prefix", name)
+		return
+	}
+
+	etherType, ok := r.m[OFPXMT_OFB_ETH_TYPE]
+	if !ok {
+		r.err = errors.Wrap(openflow.ErrMissingEtherType, name)
+		return
+	}
+	// IPv6?
+	if etherType.(uint16) != 0x86DD {
+		r.err = errors.Wrap(openflow.ErrUnsupportedEtherType, name)
+		return
+	}
+
+	r.m[field] = &net.IPNet{
+		IP:   ip.IP.To16().Mask(ip.Mask),
+		Mask: ip.Mask,
+	}
+}
+
+func (r *Match) ipv6(field uint) *net.IPNet {
+	v, ok := r.m[field]
+	if ok {
+		return v.(*net.IPNet)
+	}
+
+	return &net.IPNet{
+		IP:   net.IPv6zero,
+		Mask: net.CIDRMask(0, 128),
+	}
+}
+
+func (r *Match) SetIPv6Src(ip *net.IPNet) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.setIPv6(OFPXMT_OFB_IPV6_SRC, "SetIPv6Src", ip)
+}
+
+func (r *Match) IPv6Src() *net.IPNet {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.ipv6(OFPXMT_OFB_IPV6_SRC)
+}
+
+func (r *Match) SetIPv6Dst(ip *net.IPNet) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.setIPv6(OFPXMT_OFB_IPV6_DST, "SetIPv6Dst", ip)
+}
+
+func (r *Match) IPv6Dst() *net.IPNet {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.ipv6(OFPXMT_OFB_IPV6_DST)
+}
+
+func (r *Match) SetIPv6FlowLabel(label uint32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if label > 0xFFFFF {
+		r.err = fmt.Errorf("SetIPv6FlowLabel: flow label %v exceeds 20 bits", label)
+		return
+	}
+	etherType, ok := r.m[OFPXMT_OFB_ETH_TYPE]
+	if !ok {
+		r.err = errors.Wrap(openflow.ErrMissingEtherType, "SetIPv6FlowLabel")
+		return
+	}
+	// IPv6?
+	if etherType.(uint16) != 0x86DD {
+		r.err = errors.Wrap(openflow.ErrUnsupportedEtherType, "SetIPv6FlowLabel")
+		return
+	}
+
+	r.m[OFPXMT_OFB_IPV6_FLABEL] = label
+}
+
+func (r *Match) IPv6FlowLabel() (wildcard bool, label uint32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.m[OFPXMT_OFB_IPV6_FLABEL]
+	if ok {
+		return false, v.(uint32)
+	}
+
+	return true, 0
+}
+
+// metadata is the value of the METADATA field, whose bits out of mask are zero.
+type metadata struct {
+	value uint64
+	mask  uint64
+}
+
+func (r *Match) SetMetadata(value, mask uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if mask == 0 {
+		r.err = errors.New("SetMetadata: empty mask (use SetWildcardMetadata instead)")
+		return
+	}
+	// The switch rejects the value whose bits are set out of the mask.
+	r.m[OFPXMT_OFB_METADATA] = metadata{value: value & mask, mask: mask}
+}
+
+func (r *Match) SetWildcardMetadata() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.m, OFPXMT_OFB_METADATA)
+}
+
+func (r *Match) Metadata() (wildcard bool, value, mask uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.m[OFPXMT_OFB_METADATA]
+	if !ok {
+		return true, 0, 0
+	}
+	m := v.(metadata)
+
+	return false, m.value, m.mask
+}
+
+// checkMPLSEtherType returns an error if the ether type is not MPLS, which is the prerequisite of the MPLS_LABEL
+// and MPLS_BOS fields. The caller should lock the mutex.
+func (r *Match) checkMPLSEtherType(caller string) error {
+	etherType, ok := r.m[OFPXMT_OFB_ETH_TYPE]
+	if !ok {
+		return errors.Wrap(openflow.ErrMissingEtherType, caller)
+	}
+	if !openflow.IsMPLSEtherType(etherType.(uint16)) {
+		return errors.Wrap(openflow.ErrUnsupportedEtherType, caller)
+	}
+
+	return nil
+}
+
+func (r *Match) SetMPLSLabel(label uint32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if label > 0xFFFFF {
+		r.err = fmt.Errorf("SetMPLSLabel: MPLS label %v exceeds 20 bits", label)
+		return
+	}
+	if err := r.checkMPLSEtherType("SetMPLSLabel"); err != nil {
+		r.err = err
+		return
+	}
+
+	r.m[OFPXMT_OFB_MPLS_LABEL] = label
+}
+
+func (r *Match) MPLSLabel() (wildcard bool, label uint32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.m[OFPXMT_OFB_MPLS_LABEL]
+	if ok {
+		return false, v.(uint32)
+	}
+
+	return true, 0
+}
+
+func (r *Match) SetMPLSBOS(bos bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkMPLSEtherType("SetMPLSBOS"); err != nil {
+		r.err = err
+		return
+	}
+
+	var v uint8
+	if bos {
+		v = 1
+	}
+	r.m[OFPXMT_OFP_MPLS_BOS] = v
+}
+
+func (r *Match) MPLSBOS() (wildcard bool, bos bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.m[OFPXMT_OFP_MPLS_BOS]
+	if ok {
+		return false, v.(uint8) == 1
+	}
+
+	return true, false
+}
+
+func (r *Match) SetWildcardIPDSCP() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.m, OFPXMT_OFB_IP_DSCP)
+}
+
+func (r *Match) SetIPDSCP(dscp uint8) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if dscp > 0x3F {
+		r.err = fmt.Errorf("SetIPDSCP: DSCP %v exceeds 6 bits", dscp)
+		return
+	}
+	if err := r.checkIPEtherType("SetIPDSCP"); err != nil {
+		r.err = err
+		return
+	}
+
+	r.m[OFPXMT_OFB_IP_DSCP] = dscp
+}
+
+func (r *Match) IPDSCP() (wildcard bool, dscp uint8) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.m[OFPXMT_OFB_IP_DSCP]
+	if ok {
+		return false, v.(uint8)
+	}
+
+	return true, 0
+}
+
+func (r *Match) SetWildcardIPECN() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.m, OFPXMT_OFB_IP_ECN)
+}
+
+func (r *Match) SetIPECN(ecn uint8) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if ecn > 0x3 {
+		r.err = fmt.Errorf("SetIPECN: ECN %v exceeds 2 bits", ecn)
+		return
+	}
+	if err := r.checkIPEtherType("SetIPECN"); err != nil {
+		r.err = err
+		return
+	}
+
+	r.m[OFPXMT_OFB_IP_ECN] = ecn
+}
+
+func (r *Match) IPECN() (wildcard bool, ecn uint8) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.m[OFPXMT_OFB_IP_ECN]
+	if ok {
+		return false, v.(uint8)
+	}
+
+	return true, 0
+}
+
+// checkIPEtherType returns an error if the ether type is neither IPv4 nor IPv6, which is the prerequisite
+// of the IP_DSCP and IP_ECN fields. The caller should lock the mutex.
+func (r *Match) checkIPEtherType(caller string) error {
+	etherType, ok := r.m[OFPXMT_OFB_ETH_TYPE]
+	if !ok {
+		return errors.Wrap(openflow.ErrMissingEtherType, caller)
+	}
+	if t := etherType.(uint16); t != 0x0800 && t != 0x86DD {
+		return errors.Wrap(openflow.ErrUnsupportedEtherType, caller)
+	}
+
+	return nil
+}
+
 func (r *Match) SetWildcardEtherType() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -477,6 +794,30 @@ func marshalIPNetTLV(field uint8, ip *net.IPNet) ([]byte, error) {
 	return data, nil
 }
 
+func marshalIPv6NetTLV(field uint8, ip *net.IPNet) ([]byte, error) {
+	ipv6 := ip.IP.To16()
+	if ipv6 == nil {
+		return nil, openflow.ErrInvalidIPAddress
+	}
+
+	// Omit the mask if all the bits are matched.
+	if ones, _ := ip.Mask.Size(); ones == 128 {
+		data := make([]byte, 20)
+		var header uint32 = 0x8000<<16 | uint32(field)<<9 | 0x0<<8 | 16
+		binary.BigEndian.PutUint32(data[0:4], header)
+		copy(data[4:20], ipv6)
+		return data, nil
+	}
+
+	data := make([]byte, 36)
+	// TLV header
+	var header uint32 = 0x8000<<16 | uint32(field)<<9 | 0x1<<8 | 32
+	binary.BigEndian.PutUint32(data[0:4], header)
+	copy(data[4:20], ipv6)
+	copy(data[20:36], ip.Mask)
+	return data, nil
+}
+
 func marshalHardwareAddrTLV(field uint8, mac net.HardwareAddr) ([]byte, error) {
 	data := make([]byte, 10)
 	// TLV header
@@ -491,7 +832,7 @@ func marshalUint8TLV(field uint8, v uint8) ([]byte, error) {
 	// TLV header
 	var header uint32 = 0x8000<<16 | uint32(field)<<9 | 0x0<<8 | 1
 	binary.BigEndian.PutUint32(data[0:4], header)
-	data[5] = v
+	data[4] = v
 	return data, nil
 }
 
@@ -513,11 +854,34 @@ func marshalUint32TLV(field uint8, v uint32) ([]byte, error) {
 	return data, nil
 }
 
+func marshalMetadataTLV(v metadata) ([]byte, error) {
+	// Omit the mask if all the bits are matched.
+	if v.mask == ^uint64(0) {
+		data := make([]byte, 12)
+		var header uint32 = 0x8000<<16 | uint32(OFPXMT_OFB_METADATA)<<9 | 0x0<<8 | 8
+		binary.BigEndian.PutUint32(data[0:4], header)
+		binary.BigEndian.PutUint64(data[4:12], v.value)
+		return data, nil
+	}
+
+	data := make([]byte, 20)
+	// TLV header
+	var header uint32 = 0x8000<<16 | uint32(OFPXMT_OFB_METADATA)<<9 | 0x1<<8 | 16
+	binary.BigEndian.PutUint32(data[0:4], header)
+	binary.BigEndian.PutUint64(data[4:12], v.value)
+	binary.BigEndian.PutUint64(data[12:20], v.mask)
+	return data, nil
+}
+
 func marshalTLV(id uint, v interface{}) ([]byte, error) {
 	switch id {
 	case OFPXMT_OFB_IN_PORT:
 		port := v.(uint32)
 		return marshalUint32TLV(OFPXMT_OFB_IN_PORT, port)
+	case OFPXMT_OFB_IN_PHY_PORT:
+		return marshalUint32TLV(OFPXMT_OFB_IN_PHY_PORT, v.(uint32))
+	case OFPXMT_OFB_METADATA:
+		return marshalMetadataTLV(v.(metadata))
 	case OFPXMT_OFB_ETH_DST:
 		mac := v.(net.HardwareAddr)
 		return marshalHardwareAddrTLV(OFPXMT_OFB_ETH_DST, mac)
@@ -533,6 +897,12 @@ func marshalTLV(id uint, v interface{}) ([]byte, error) {
 	case OFPXMT_OFB_VLAN_PCP:
 		priority := v.(uint8)
 		return marshalUint8TLV(OFPXMT_OFB_VLAN_PCP, priority)
+	case OFPXMT_OFB_IP_DSCP:
+		dscp := v.(uint8)
+		return marshalUint8TLV(OFPXMT_OFB_IP_DSCP, dscp)
+	case OFPXMT_OFB_IP_ECN:
+		ecn := v.(uint8)
+		return marshalUint8TLV(OFPXMT_OFB_IP_ECN, ecn)
 	case OFPXMT_OFB_IP_PROTO:
 		protocol := v.(uint8)
 		return marshalUint8TLV(OFPXMT_OFB_IP_PROTO, protocol)
@@ -542,6 +912,21 @@ func marshalTLV(id uint, v interface{}) ([]byte, error) {
 	case OFPXMT_OFB_IPV4_DST:
 		ip := v.(*net.IPNet)
 		return marshalIPNetTLV(OFPXMT_OFB_IPV4_DST, ip)
+	case OFPXMT_OFB_IPV6_SRC:
+		ip := v.(*net.IPNet)
+		return marshalIPv6NetTLV(OFPXMT_OFB_IPV6_SRC, ip)
+	case OFPXMT_OFB_IPV6_DST:
+		ip := v.(*net.IPNet)
+		return marshalIPv6NetTLV(OFPXMT_OFB_IPV6_DST, ip)
+	case OFPXMT_OFB_IPV6_FLABEL:
+		label := v.(uint32)
+		return marshalUint32TLV(OFPXMT_OFB_IPV6_FLABEL, label)
+	case OFPXMT_OFB_MPLS_LABEL:
+		label := v.(uint32)
+		return marshalUint32TLV(OFPXMT_OFB_MPLS_LABEL, label)
+	case OFPXMT_OFP_MPLS_BOS:
+		bos := v.(uint8)
+		return marshalUint8TLV(OFPXMT_OFP_MPLS_BOS, bos)
 	case OFPXMT_OFB_TCP_SRC:
 		port := v.(uint16)
 		return marshalUint16TLV(OFPXMT_OFB_TCP_SRC, port)
@@ -567,10 +952,17 @@ func (r *Match) MarshalBinary() ([]byte, error) {
 		return nil, r.err
 	}
 
+	// Sort the fields to make the encoding deterministic so that the same matches have the same encoding.
+	fields := make([]uint, 0, len(r.m))
+	for k := range r.m {
+		fields = append(fields, k)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i] < fields[j] })
+
 	data := make([]byte, 4)
 	binary.BigEndian.PutUint16(data[0:2], OFPMT_OXM)
-	for k, v := range r.m {
-		tlv, err := marshalTLV(k, v)
+	for _, k := range fields {
+		tlv, err := marshalTLV(k, r.m[k])
 		if err != nil {
 			return nil, err
 		}
@@ -616,6 +1008,24 @@ func (r *Match) unmarshalUint32TLV(field uint8, data []byte) error {
 	return nil
 }
 
+func (r *Match) unmarshalMetadataTLV(hasmask uint8, data []byte) error {
+	length := 12
+	if hasmask == 1 {
+		length = 20
+	}
+	if len(data) < length {
+		return openflow.ErrInvalidPacketLength
+	}
+
+	v := metadata{value: binary.BigEndian.Uint64(data[4:12]), mask: ^uint64(0)}
+	if hasmask == 1 {
+		v.mask = binary.BigEndian.Uint64(data[12:20])
+	}
+	r.m[OFPXMT_OFB_METADATA] = v
+
+	return nil
+}
+
 func (r *Match) unmarshalHardwareAddrTLV(field uint8, data []byte) error {
 	if len(data) < 10 {
 		return openflow.ErrInvalidPacketLength
@@ -651,6 +1061,30 @@ func (r *Match) unmarshalIPNetTLV(field uint8, hasmask uint8, data []byte) error
 	return nil
 }
 
+func (r *Match) unmarshalIPv6NetTLV(field uint8, hasmask uint8, data []byte) error {
+	length := 20
+	if hasmask == 1 {
+		length = 36
+	}
+	if len(data) < length {
+		return openflow.ErrInvalidPacketLength
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, data[4:20])
+	mask := net.CIDRMask(128, 128)
+	if hasmask == 1 {
+		mask = make(net.IPMask, net.IPv6len)
+		copy(mask, data[20:36])
+	}
+	r.m[uint(field)] = &net.IPNet{
+		IP:   ip,
+		Mask: mask,
+	}
+
+	return nil
+}
+
 func (r *Match) unmarshalTLV(data []byte) error {
 	buf := data
 	// TLV header length is 4 bytes
@@ -673,6 +1107,14 @@ func (r *Match) unmarshalTLV(data []byte) error {
 			if err := r.unmarshalUint32TLV(OFPXMT_OFB_IN_PORT, buf); err != nil {
 				return err
 			}
+		case OFPXMT_OFB_IN_PHY_PORT:
+			if err := r.unmarshalUint32TLV(OFPXMT_OFB_IN_PHY_PORT, buf); err != nil {
+				return err
+			}
+		case OFPXMT_OFB_METADATA:
+			if err := r.unmarshalMetadataTLV(uint8(hasmask), buf); err != nil {
+				return err
+			}
 		case OFPXMT_OFB_ETH_DST:
 			if err := r.unmarshalHardwareAddrTLV(OFPXMT_OFB_ETH_DST, buf); err != nil {
 				return err
@@ -693,6 +1135,14 @@ func (r *Match) unmarshalTLV(data []byte) error {
 			if err := r.unmarshalUint8TLV(OFPXMT_OFB_VLAN_PCP, buf); err != nil {
 				return err
 			}
+		case OFPXMT_OFB_IP_DSCP:
+			if err := r.unmarshalUint8TLV(OFPXMT_OFB_IP_DSCP, buf); err != nil {
+				return err
+			}
+		case OFPXMT_OFB_IP_ECN:
+			if err := r.unmarshalUint8TLV(OFPXMT_OFB_IP_ECN, buf); err != nil {
+				return err
+			}
 		case OFPXMT_OFB_IP_PROTO:
 			if err := r.unmarshalUint8TLV(OFPXMT_OFB_IP_PROTO, buf); err != nil {
 				return err
@@ -705,6 +1155,27 @@ func (r *Match) unmarshalTLV(data []byte) error {
 			if err := r.unmarshalIPNetTLV(OFPXMT_OFB_IPV4_DST, uint8(hasmask), buf); err != nil {
 				return err
 			}
+		case OFPXMT_OFB_IPV6_SRC:
+			if err := r.unmarshalIPv6NetTLV(OFPXMT_OFB_IPV6_SRC, uint8(hasmask), buf); err != nil {
+				return err
+			}
+		case OFPXMT_OFB_IPV6_DST:
+			if err := r.unmarshalIPv6NetTLV(OFPXMT_OFB_IPV6_DST, uint8(hasmask), buf); err != nil {
+				return err
+			}
+		case OFPXMT_OFB_IPV6_FLABEL:
+			// Masked flow label is not supported.
+			if err := r.unmarshalUint32TLV(OFPXMT_OFB_IPV6_FLABEL, buf); err != nil {
+				return err
+			}
+		case OFPXMT_OFB_MPLS_LABEL:
+			if err := r.unmarshalUint32TLV(OFPXMT_OFB_MPLS_LABEL, buf); err != nil {
+				return err
+			}
+		case OFPXMT_OFP_MPLS_BOS:
+			if err := r.unmarshalUint8TLV(OFPXMT_OFP_MPLS_BOS, buf); err != nil {
+				return err
+			}
 		case OFPXMT_OFB_TCP_SRC:
 			if err := r.unmarshalUint16TLV(OFPXMT_OFB_TCP_SRC, buf); err != nil {
 				return err
@@ -748,3 +1219,7 @@ func (r *Match) UnmarshalBinary(data []byte) error {
 
 	return r.unmarshalTLV(data[4:length])
 }
+
+func (r *Match) Fields() []openflow.MatchField {
+	return openflow.MatchFields(r)
+}
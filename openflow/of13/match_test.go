@@ -0,0 +1,539 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+
+	"github.com/pkg/errors"
+)
+
+func mustParseCIDR(s string) *net.IPNet {
+	ip, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	ipnet.IP = ip
+
+	return ipnet
+}
+
+func TestIPv6MatchEncoding(t *testing.T) {
+	src := []struct {
+		Set      func(openflow.Match)
+		Field    uint
+		Expected []byte
+	}{
+		{
+			Set:   func(m openflow.Match) { m.SetIPv6Src(mustParseCIDR("2001:db8::1/128")) },
+			Field: OFPXMT_OFB_IPV6_SRC,
+			Expected: []byte{
+				0x80, 0x00, 0x34, 0x10,
+				0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+			},
+		},
+		{
+			// Host bits should be cleared by the prefix.
+			Set:   func(m openflow.Match) { m.SetIPv6Dst(mustParseCIDR("2001:db8:0:1::1/64")) },
+			Field: OFPXMT_OFB_IPV6_DST,
+			Expected: []byte{
+				0x80, 0x00, 0x37, 0x20,
+				0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			Set:      func(m openflow.Match) { m.SetIPv6FlowLabel(0xABCDE) },
+			Field:    OFPXMT_OFB_IPV6_FLABEL,
+			Expected: []byte{0x80, 0x00, 0x38, 0x04, 0x00, 0x0a, 0xbc, 0xde},
+		},
+	}
+
+	for i, v := range src {
+		match := NewMatch()
+		match.SetEtherType(0x86DD)
+		v.Set(match)
+		if err := match.Error(); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+
+		tlv, err := marshalTLV(v.Field, match.(*Match).m[v.Field])
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal TLV: %v", i, err)
+		}
+		if !bytes.Equal(tlv, v.Expected) {
+			t.Fatalf("#%v: unexpected TLV: expected=%x, got=%x", i, v.Expected, tlv)
+		}
+
+		data, err := match.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal: %v", i, err)
+		}
+		decoded := NewMatch()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if decoded.IPv6Src().String() != match.IPv6Src().String() || decoded.IPv6Dst().String() != match.IPv6Dst().String() {
+			t.Fatalf("#%v: unexpected decoded addresses: src=%v, dst=%v", i, decoded.IPv6Src(), decoded.IPv6Dst())
+		}
+		w1, l1 := match.IPv6FlowLabel()
+		w2, l2 := decoded.IPv6FlowLabel()
+		if w1 != w2 || l1 != l2 {
+			t.Fatalf("#%v: unexpected decoded flow label: expected=%v/%v, got=%v/%v", i, w1, l1, w2, l2)
+		}
+	}
+}
+
+func TestMPLSMatchEncoding(t *testing.T) {
+	src := []struct {
+		EtherType uint16
+		Set       func(openflow.Match)
+		Field     uint
+		Expected  []byte
+	}{
+		{
+			EtherType: 0x8847,
+			Set:       func(m openflow.Match) { m.SetMPLSLabel(0x12345) },
+			Field:     OFPXMT_OFB_MPLS_LABEL,
+			Expected:  []byte{0x80, 0x00, 0x44, 0x04, 0x00, 0x01, 0x23, 0x45},
+		},
+		{
+			EtherType: 0x8848,
+			Set:       func(m openflow.Match) { m.SetMPLSBOS(true) },
+			Field:     OFPXMT_OFP_MPLS_BOS,
+			Expected:  []byte{0x80, 0x00, 0x48, 0x01, 0x01},
+		},
+		{
+			EtherType: 0x8847,
+			Set:       func(m openflow.Match) { m.SetMPLSBOS(false) },
+			Field:     OFPXMT_OFP_MPLS_BOS,
+			Expected:  []byte{0x80, 0x00, 0x48, 0x01, 0x00},
+		},
+	}
+
+	for i, v := range src {
+		match := NewMatch()
+		match.SetEtherType(v.EtherType)
+		v.Set(match)
+		if err := match.Error(); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+
+		tlv, err := marshalTLV(v.Field, match.(*Match).m[v.Field])
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal TLV: %v", i, err)
+		}
+		if !bytes.Equal(tlv, v.Expected) {
+			t.Fatalf("#%v: unexpected TLV: expected=%x, got=%x", i, v.Expected, tlv)
+		}
+
+		data, err := match.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal: %v", i, err)
+		}
+		decoded := NewMatch()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		w1, l1 := match.MPLSLabel()
+		w2, l2 := decoded.MPLSLabel()
+		if w1 != w2 || l1 != l2 {
+			t.Fatalf("#%v: unexpected decoded MPLS label: expected=%v/%v, got=%v/%v", i, w1, l1, w2, l2)
+		}
+		w1, b1 := match.MPLSBOS()
+		w2, b2 := decoded.MPLSBOS()
+		if w1 != w2 || b1 != b2 {
+			t.Fatalf("#%v: unexpected decoded MPLS BOS: expected=%v/%v, got=%v/%v", i, w1, b1, w2, b2)
+		}
+	}
+}
+
+func TestPhysicalInPortMatchEncoding(t *testing.T) {
+	inPort := openflow.NewInPort()
+	inPort.SetValue(10)
+	match := NewMatch()
+	match.SetInPort(inPort)
+	match.SetPhysicalInPort(3)
+	if err := match.Error(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src := []struct {
+		Field    uint
+		Expected []byte
+	}{
+		{
+			Field:    OFPXMT_OFB_IN_PORT,
+			Expected: []byte{0x80, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x0a},
+		},
+		{
+			Field:    OFPXMT_OFB_IN_PHY_PORT,
+			Expected: []byte{0x80, 0x00, 0x02, 0x04, 0x00, 0x00, 0x00, 0x03},
+		},
+	}
+	for i, v := range src {
+		tlv, err := marshalTLV(v.Field, match.(*Match).m[v.Field])
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal TLV: %v", i, err)
+		}
+		if !bytes.Equal(tlv, v.Expected) {
+			t.Fatalf("#%v: unexpected TLV: expected=%x, got=%x", i, v.Expected, tlv)
+		}
+	}
+
+	data, err := match.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	decoded := NewMatch()
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if wildcard, port := decoded.InPort(); wildcard || port.Value() != 10 {
+		t.Fatalf("unexpected decoded in-port: wildcard=%v, port=%v", wildcard, port.Value())
+	}
+	if wildcard, port := decoded.PhysicalInPort(); wildcard || port != 3 {
+		t.Fatalf("unexpected decoded physical in-port: wildcard=%v, port=%v", wildcard, port)
+	}
+
+	decoded.SetWildcardPhysicalInPort()
+	if wildcard, _ := decoded.PhysicalInPort(); !wildcard {
+		t.Fatal("physical in-port should be wildcarded")
+	}
+}
+
+func TestInvalidMPLSMatch(t *testing.T) {
+	src := []struct {
+		EtherType uint16
+		Set       func(openflow.Match)
+		Expected  error
+	}{
+		{
+			Set:      func(m openflow.Match) { m.SetMPLSLabel(1) },
+			Expected: openflow.ErrMissingEtherType,
+		},
+		{
+			EtherType: 0x0800,
+			Set:       func(m openflow.Match) { m.SetMPLSLabel(1) },
+			Expected:  openflow.ErrUnsupportedEtherType,
+		},
+		{
+			EtherType: 0x86DD,
+			Set:       func(m openflow.Match) { m.SetMPLSBOS(true) },
+			Expected:  openflow.ErrUnsupportedEtherType,
+		},
+	}
+
+	for i, v := range src {
+		match := NewMatch()
+		if v.EtherType != 0 {
+			match.SetEtherType(v.EtherType)
+		}
+		v.Set(match)
+		if errors.Cause(match.Error()) != v.Expected {
+			t.Fatalf("#%v: expected %v, but got %v", i, v.Expected, match.Error())
+		}
+	}
+}
+
+func TestInvalidIPv6Match(t *testing.T) {
+	src := []struct {
+		EtherType uint16
+		Set       func(openflow.Match)
+		Expected  error
+	}{
+		{
+			Set:      func(m openflow.Match) { m.SetIPv6Src(mustParseCIDR("2001:db8::1/128")) },
+			Expected: openflow.ErrMissingEtherType,
+		},
+		{
+			EtherType: 0x0800,
+			Set:       func(m openflow.Match) { m.SetIPv6Dst(mustParseCIDR("2001:db8::1/128")) },
+			Expected:  openflow.ErrUnsupportedEtherType,
+		},
+		{
+			EtherType: 0x0800,
+			Set:       func(m openflow.Match) { m.SetIPv6FlowLabel(1) },
+			Expected:  openflow.ErrUnsupportedEtherType,
+		},
+		{
+			EtherType: 0x86DD,
+			Set:       func(m openflow.Match) { m.SetIPv6Src(mustParseCIDR("10.0.0.1/32")) },
+			Expected:  openflow.ErrInvalidIPAddress,
+		},
+	}
+
+	for i, v := range src {
+		match := NewMatch()
+		if v.EtherType != 0 {
+			match.SetEtherType(v.EtherType)
+		}
+		v.Set(match)
+		if errors.Cause(match.Error()) != v.Expected {
+			t.Fatalf("#%v: expected %v, but got %v", i, v.Expected, match.Error())
+		}
+	}
+
+	match := NewMatch()
+	match.SetEtherType(0x86DD)
+	match.SetIPv6FlowLabel(0x100000)
+	if match.Error() == nil {
+		t.Fatal("expected an error for the flow label exceeding 20 bits")
+	}
+}
+
+func TestIPDSCPECNMatchEncoding(t *testing.T) {
+	src := []struct {
+		EtherType uint16
+		Set       func(openflow.Match)
+		Field     uint
+		Expected  []byte
+	}{
+		// Expedited Forwarding (46).
+		{
+			EtherType: 0x0800,
+			Set:       func(m openflow.Match) { m.SetIPDSCP(46) },
+			Field:     OFPXMT_OFB_IP_DSCP,
+			Expected:  []byte{0x80, 0x00, 0x10, 0x01, 0x2e},
+		},
+		{
+			EtherType: 0x86DD,
+			Set:       func(m openflow.Match) { m.SetIPDSCP(0x3F) },
+			Field:     OFPXMT_OFB_IP_DSCP,
+			Expected:  []byte{0x80, 0x00, 0x10, 0x01, 0x3f},
+		},
+		// Congestion Experienced (3).
+		{
+			EtherType: 0x0800,
+			Set:       func(m openflow.Match) { m.SetIPECN(3) },
+			Field:     OFPXMT_OFB_IP_ECN,
+			Expected:  []byte{0x80, 0x00, 0x12, 0x01, 0x03},
+		},
+	}
+
+	for i, v := range src {
+		match := NewMatch()
+		match.SetEtherType(v.EtherType)
+		v.Set(match)
+		if err := match.Error(); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+
+		tlv, err := marshalTLV(v.Field, match.(*Match).m[v.Field])
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal TLV: %v", i, err)
+		}
+		if !bytes.Equal(tlv, v.Expected) {
+			t.Fatalf("#%v: unexpected TLV: expected=%x, got=%x", i, v.Expected, tlv)
+		}
+
+		data, err := match.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal: %v", i, err)
+		}
+		decoded := NewMatch()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		w1, d1 := match.IPDSCP()
+		w2, d2 := decoded.IPDSCP()
+		if w1 != w2 || d1 != d2 {
+			t.Fatalf("#%v: unexpected decoded DSCP: expected=%v/%v, got=%v/%v", i, w1, d1, w2, d2)
+		}
+		w1, e1 := match.IPECN()
+		w2, e2 := decoded.IPECN()
+		if w1 != w2 || e1 != e2 {
+			t.Fatalf("#%v: unexpected decoded ECN: expected=%v/%v, got=%v/%v", i, w1, e1, w2, e2)
+		}
+	}
+}
+
+func TestInvalidIPDSCPECNMatch(t *testing.T) {
+	src := []struct {
+		EtherType uint16
+		Set       func(openflow.Match)
+		Expected  error
+	}{
+		{
+			Set:      func(m openflow.Match) { m.SetIPDSCP(1) },
+			Expected: openflow.ErrMissingEtherType,
+		},
+		{
+			EtherType: 0x0806,
+			Set:       func(m openflow.Match) { m.SetIPECN(1) },
+			Expected:  openflow.ErrUnsupportedEtherType,
+		},
+		// Out of range.
+		{
+			EtherType: 0x0800,
+			Set:       func(m openflow.Match) { m.SetIPDSCP(0x40) },
+		},
+		{
+			EtherType: 0x0800,
+			Set:       func(m openflow.Match) { m.SetIPECN(4) },
+		},
+	}
+
+	for i, v := range src {
+		match := NewMatch()
+		if v.EtherType != 0 {
+			match.SetEtherType(v.EtherType)
+		}
+		v.Set(match)
+		if match.Error() == nil {
+			t.Fatalf("#%v: expected an error", i)
+		}
+		if v.Expected != nil && errors.Cause(match.Error()) != v.Expected {
+			t.Fatalf("#%v: expected %v, but got %v", i, v.Expected, match.Error())
+		}
+	}
+}
+
+func TestMetadataMatchEncoding(t *testing.T) {
+	src := []struct {
+		Value, Mask   uint64
+		ExpectedValue uint64
+		Expected      []byte
+	}{
+		{
+			Value:         0x0123456789abcdef,
+			Mask:          0xffffffffffffffff,
+			ExpectedValue: 0x0123456789abcdef,
+			Expected:      []byte{0x80, 0x00, 0x04, 0x08, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+		},
+		{
+			// Bits out of the mask should be cleared.
+			Value:         0x12ff,
+			Mask:          0xff,
+			ExpectedValue: 0xff,
+			Expected: []byte{
+				0x80, 0x00, 0x05, 0x10,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff,
+			},
+		},
+		{
+			Value:         0x8000000000000001,
+			Mask:          0xf000000000000001,
+			ExpectedValue: 0x8000000000000001,
+			Expected: []byte{
+				0x80, 0x00, 0x05, 0x10,
+				0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+				0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+			},
+		},
+	}
+
+	for i, v := range src {
+		match := NewMatch()
+		match.SetMetadata(v.Value, v.Mask)
+		if err := match.Error(); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+
+		tlv, err := marshalTLV(OFPXMT_OFB_METADATA, match.(*Match).m[OFPXMT_OFB_METADATA])
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal TLV: %v", i, err)
+		}
+		if !bytes.Equal(tlv, v.Expected) {
+			t.Fatalf("#%v: unexpected TLV: expected=%x, got=%x", i, v.Expected, tlv)
+		}
+
+		data, err := match.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal: %v", i, err)
+		}
+		decoded := NewMatch()
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		wildcard, value, mask := decoded.Metadata()
+		if wildcard || value != v.ExpectedValue || mask != v.Mask {
+			t.Fatalf("#%v: unexpected decoded metadata: wildcard=%v, value=%#x, mask=%#x", i, wildcard, value, mask)
+		}
+	}
+
+	match := NewMatch()
+	match.SetMetadata(1, 1)
+	match.SetWildcardMetadata()
+	if wildcard, _, _ := match.Metadata(); !wildcard {
+		t.Fatal("metadata is not a wildcard")
+	}
+	match.SetMetadata(1, 0)
+	if match.Error() == nil {
+		t.Fatal("expected an error for the empty mask")
+	}
+}
+
+func TestMatchFields(t *testing.T) {
+	inPort := openflow.NewInPort()
+	inPort.SetValue(3)
+	match := NewMatch()
+	match.SetInPort(inPort)
+	match.SetDstMAC(net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+	match.SetEtherType(0x0800)
+	match.SetIPProtocol(6)
+	match.SetDstIP(mustParseCIDR("10.1.2.0/24"))
+	match.SetDstPort(80)
+	match.SetMetadata(0x1, 0xFF)
+	if err := match.Error(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"in_port=3",
+		"eth_dst=00:11:22:33:44:55",
+		"eth_type=2048",
+		"ip_proto=6",
+		"ipv4_dst=10.1.2.0/24",
+		"tp_dst=80",
+		"metadata=0x1/0xff",
+	}
+
+	// The decoded match of an installed flow should have the same fields.
+	packet, err := match.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal the match: %v", err)
+	}
+	decoded := NewMatch()
+	if err := decoded.UnmarshalBinary(packet); err != nil {
+		t.Fatalf("failed to unmarshal the match: %v", err)
+	}
+
+	for i, m := range []openflow.Match{match, decoded} {
+		fields := m.Fields()
+		if len(fields) != len(expected) {
+			t.Fatalf("#%v: unexpected fields: expected=%v, got=%v", i, expected, fields)
+		}
+		for j, v := range fields {
+			if v.String() != expected[j] {
+				t.Fatalf("#%v: unexpected field at %v: expected=%v, got=%v", i, j, expected[j], v)
+			}
+		}
+	}
+	if fields := NewMatch().Fields(); len(fields) != 0 {
+		t.Fatalf("unexpected fields of the wildcard match: %v", fields)
+	}
+}
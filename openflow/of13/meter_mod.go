@@ -0,0 +1,136 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+type MeterMod struct {
+	err error
+	openflow.Message
+	command openflow.MeterCommand
+	meter   openflow.Meter
+}
+
+func NewMeterMod(xid uint32, cmd openflow.MeterCommand) openflow.MeterMod {
+	return &MeterMod{
+		Message: openflow.NewMessage(openflow.OF13_VERSION, OFPT_METER_MOD, xid),
+		command: cmd,
+	}
+}
+
+func (r *MeterMod) Error() error {
+	return r.err
+}
+
+func (r *MeterMod) Command() openflow.MeterCommand {
+	return r.command
+}
+
+func (r *MeterMod) Meter() openflow.Meter {
+	return r.meter
+}
+
+func (r *MeterMod) SetMeter(m openflow.Meter) {
+	if m.ID == 0 || (m.ID > OFPM_MAX && m.ID != OFPM_ALL) {
+		r.err = fmt.Errorf("SetMeter: invalid meter ID: %v", m.ID)
+		return
+	}
+	r.meter = m
+}
+
+func getMeterCommand(cmd openflow.MeterCommand) (uint16, error) {
+	switch cmd {
+	case openflow.MeterAdd:
+		return OFPMC_ADD, nil
+	case openflow.MeterModify:
+		return OFPMC_MODIFY, nil
+	case openflow.MeterDelete:
+		return OFPMC_DELETE, nil
+	default:
+		return 0, fmt.Errorf("unexpected meter command: %v", cmd)
+	}
+}
+
+func marshalMeterBand(b openflow.MeterBand) ([]byte, error) {
+	v := make([]byte, 16)
+	switch b.Type {
+	case openflow.MeterBandDrop:
+		binary.BigEndian.PutUint16(v[0:2], OFPMBT_DROP)
+		// v[12:16] is padding
+	case openflow.MeterBandDSCPRemark:
+		binary.BigEndian.PutUint16(v[0:2], OFPMBT_DSCP_REMARK)
+		v[12] = b.PrecLevel
+		// v[13:16] is padding
+	default:
+		return nil, fmt.Errorf("unexpected meter band type: %v", b.Type)
+	}
+	binary.BigEndian.PutUint16(v[2:4], 16)
+	binary.BigEndian.PutUint32(v[4:8], b.Rate)
+	binary.BigEndian.PutUint32(v[8:12], b.BurstSize)
+
+	return v, nil
+}
+
+func (r *MeterMod) MarshalBinary() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.meter.ID == 0 {
+		return nil, errors.New("empty meter ID")
+	}
+
+	cmd, err := getMeterCommand(r.command)
+	if err != nil {
+		return nil, err
+	}
+	var flags uint16 = OFPMF_KBPS
+	if r.meter.PacketRate {
+		flags = OFPMF_PKTPS
+	}
+	if r.meter.Burst {
+		flags |= OFPMF_BURST
+	}
+
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint16(v[0:2], cmd)
+	binary.BigEndian.PutUint16(v[2:4], flags)
+	binary.BigEndian.PutUint32(v[4:8], r.meter.ID)
+	// Bands are ignored by the delete command.
+	if r.command != openflow.MeterDelete {
+		for _, b := range r.meter.Bands {
+			band, err := marshalMeterBand(b)
+			if err != nil {
+				return nil, err
+			}
+			v = append(v, band...)
+		}
+	}
+
+	r.SetPayload(v)
+	return r.Message.MarshalBinary()
+}
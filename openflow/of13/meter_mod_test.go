@@ -0,0 +1,139 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+func TestMeterBandDropLayout(t *testing.T) {
+	band := openflow.MeterBand{Type: openflow.MeterBandDrop, Rate: 0x00010203, BurstSize: 0x04050607}
+	// struct ofp_meter_band_drop: type, len, rate, burst_size and 4 bytes padding.
+	expected := []byte{
+		0x00, 0x01, 0x00, 0x10,
+		0x00, 0x01, 0x02, 0x03,
+		0x04, 0x05, 0x06, 0x07,
+		0x00, 0x00, 0x00, 0x00,
+	}
+
+	v, err := marshalMeterBand(band)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, expected) {
+		t.Fatalf("unexpected drop band: expected=%x, got=%x", expected, v)
+	}
+}
+
+func TestMeterModEncoding(t *testing.T) {
+	src := []struct {
+		Command  openflow.MeterCommand
+		Meter    openflow.Meter
+		Expected []byte
+	}{
+		{
+			Command: openflow.MeterAdd,
+			Meter: openflow.Meter{
+				ID:    1,
+				Burst: true,
+				Bands: []openflow.MeterBand{{Type: openflow.MeterBandDrop, Rate: 1000, BurstSize: 100}},
+			},
+			Expected: []byte{
+				// Header
+				0x04, 0x1d, 0x00, 0x20, 0x00, 0x00, 0x00, 0x01,
+				// Command (ADD), flags (KBPS|BURST) and meter ID
+				0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x01,
+				// Drop band
+				0x00, 0x01, 0x00, 0x10, 0x00, 0x00, 0x03, 0xe8, 0x00, 0x00, 0x00, 0x64, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			Command: openflow.MeterModify,
+			Meter: openflow.Meter{
+				ID:         2,
+				PacketRate: true,
+				Bands:      []openflow.MeterBand{{Type: openflow.MeterBandDSCPRemark, Rate: 500, PrecLevel: 1}},
+			},
+			Expected: []byte{
+				// Header
+				0x04, 0x1d, 0x00, 0x20, 0x00, 0x00, 0x00, 0x01,
+				// Command (MODIFY), flags (PKTPS) and meter ID
+				0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02,
+				// DSCP remark band
+				0x00, 0x02, 0x00, 0x10, 0x00, 0x00, 0x01, 0xf4, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			Command: openflow.MeterDelete,
+			Meter: openflow.Meter{
+				ID:    3,
+				Bands: []openflow.MeterBand{{Type: openflow.MeterBandDrop, Rate: 1000}},
+			},
+			Expected: []byte{
+				// Header
+				0x04, 0x1d, 0x00, 0x10, 0x00, 0x00, 0x00, 0x01,
+				// Command (DELETE), flags (KBPS) and meter ID
+				0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0x03,
+			},
+		},
+	}
+
+	for i, v := range src {
+		mod := NewMeterMod(1, v.Command)
+		mod.SetMeter(v.Meter)
+		packet, err := mod.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: failed to marshal: %v", i, err)
+		}
+		if !bytes.Equal(packet, v.Expected) {
+			t.Fatalf("#%v: unexpected meter mod: expected=%x, got=%x", i, v.Expected, packet)
+		}
+	}
+}
+
+func TestMeterInstruction(t *testing.T) {
+	action := NewAction()
+	out := openflow.NewOutPort()
+	out.SetValue(1)
+	action.SetOutPort(out)
+
+	inst := new(Instruction)
+	inst.ApplyAction(action)
+	inst.SetMeter(7)
+	v, err := inst.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The meter instruction comes first.
+	expected := []byte{0x00, 0x06, 0x00, 0x08, 0x00, 0x00, 0x00, 0x07}
+	if !bytes.Equal(v[0:8], expected) {
+		t.Fatalf("unexpected meter instruction: expected=%x, got=%x", expected, v[0:8])
+	}
+
+	inst.SetMeter(0)
+	if inst.Error() == nil {
+		t.Fatal("expected an error for the invalid meter ID")
+	}
+}
@@ -23,7 +23,6 @@ package of13
 
 import (
 	"encoding/binary"
-	"strings"
 
 	"github.com/superkkt/cherry/openflow"
 )
@@ -86,11 +85,11 @@ func (r *DescReply) UnmarshalBinary(data []byte) error {
 	if payload == nil || len(payload) < 1064 {
 		return openflow.ErrInvalidPacketLength
 	}
-	r.manufacturer = strings.TrimRight(string(payload[8:264]), "\x00")
-	r.hardware = strings.TrimRight(string(payload[264:520]), "\x00")
-	r.software = strings.TrimRight(string(payload[520:776]), "\x00")
-	r.serial = strings.TrimRight(string(payload[776:808]), "\x00")
-	r.description = strings.TrimRight(string(payload[808:1064]), "\x00")
+	r.manufacturer = openflow.ParseDescString(payload[8:264])
+	r.hardware = openflow.ParseDescString(payload[264:520])
+	r.software = openflow.ParseDescString(payload[520:776])
+	r.serial = openflow.ParseDescString(payload[776:808])
+	r.description = openflow.ParseDescString(payload[808:1064])
 
 	return nil
 }
@@ -0,0 +1,66 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDescReplyPaddedStrings(t *testing.T) {
+	packet := make([]byte, 8+1064)
+	packet[0] = 0x04
+	packet[1] = OFPT_MULTIPART_REPLY
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	binary.BigEndian.PutUint16(packet[8:10], OFPMP_DESC)
+	payload := packet[8:]
+	// Null-terminated with garbage after the terminating null.
+	copy(payload[8:264], "Vendor\x00garbage")
+	// Padded with spaces.
+	copy(payload[264:520], "  Switch 9000   ")
+	// Not terminated with null.
+	for i := 520; i < 776; i++ {
+		payload[i] = 'a'
+	}
+	// payload[776:808] is empty.
+	copy(payload[808:1064], "dp0\x00")
+
+	reply := new(DescReply)
+	if err := reply.UnmarshalBinary(packet); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if reply.Manufacturer() != "Vendor" {
+		t.Fatalf("unexpected manufacturer: %q", reply.Manufacturer())
+	}
+	if reply.Hardware() != "Switch 9000" {
+		t.Fatalf("unexpected hardware: %q", reply.Hardware())
+	}
+	if len(reply.Software()) != 256 {
+		t.Fatalf("unexpected software length: %v", len(reply.Software()))
+	}
+	if reply.Serial() != "" {
+		t.Fatalf("unexpected serial: %q", reply.Serial())
+	}
+	if reply.Description() != "dp0" {
+		t.Fatalf("unexpected description: %q", reply.Description())
+	}
+}
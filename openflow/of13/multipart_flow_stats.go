@@ -111,4 +111,108 @@ func (r *FlowStatsRequest) MarshalBinary() ([]byte, error) {
 	return r.Message.MarshalBinary()
 }
 
-// TODO: Implement FlowStatsReply
+type FlowStatsReply struct {
+	openflow.Message
+	more  bool
+	stats []openflow.FlowStat
+}
+
+func (r FlowStatsReply) More() bool {
+	return r.more
+}
+
+func (r FlowStatsReply) Stats() []openflow.FlowStat {
+	return r.stats
+}
+
+func (r *FlowStatsReply) UnmarshalBinary(data []byte) error {
+	if err := r.Message.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	payload := r.Payload()
+	if payload == nil || len(payload) < 8 {
+		return openflow.ErrInvalidPacketLength
+	}
+	// payload[0:2] is type of ofp_multipart_reply, and payload[4:8] is padding.
+	r.more = binary.BigEndian.Uint16(payload[2:4])&OFPMPF_REPLY_MORE != 0
+	r.stats = make([]openflow.FlowStat, 0)
+
+	buf := payload[8:]
+	// 48 bytes of the fixed fields and at least 8 bytes of the match.
+	for len(buf) >= 56 {
+		length := int(binary.BigEndian.Uint16(buf[0:2]))
+		if length < 56 || len(buf) < length {
+			return openflow.ErrInvalidPacketLength
+		}
+
+		// Match length excluding the padding.
+		matchLength := int(binary.BigEndian.Uint16(buf[50:52]))
+		if matchLength < 4 || 48+matchLength > length {
+			return openflow.ErrInvalidPacketLength
+		}
+		match := NewMatch()
+		if err := match.UnmarshalBinary(buf[48 : 48+matchLength]); err != nil {
+			return err
+		}
+		stat := openflow.FlowStat{
+			TableID: buf[2],
+			// buf[3] is padding
+			DurationSec:     binary.BigEndian.Uint32(buf[4:8]),
+			DurationNanoSec: binary.BigEndian.Uint32(buf[8:12]),
+			Priority:        binary.BigEndian.Uint16(buf[12:14]),
+			IdleTimeout:     binary.BigEndian.Uint16(buf[14:16]),
+			HardTimeout:     binary.BigEndian.Uint16(buf[16:18]),
+			// buf[18:20] is flags, and buf[20:24] is padding
+			Cookie:      binary.BigEndian.Uint64(buf[24:32]),
+			PacketCount: binary.BigEndian.Uint64(buf[32:40]),
+			ByteCount:   binary.BigEndian.Uint64(buf[40:48]),
+			Match:       match,
+		}
+		// Instructions follow the match padded to a multiple of 8 bytes.
+		unmarshalInstructions(buf[minInt(48+(matchLength+7)/8*8, length):length], &stat)
+		r.stats = append(r.stats, stat)
+		buf = buf[length:]
+	}
+
+	return nil
+}
+
+// unmarshalInstructions decodes the encoded instructions of a flow into stat. The actions that cannot be decoded
+// are left nil, and the rest of the instructions are ignored if an instruction has an invalid length.
+func unmarshalInstructions(data []byte, stat *openflow.FlowStat) {
+	for len(data) >= 8 {
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 8 || len(data) < length {
+			return
+		}
+		switch binary.BigEndian.Uint16(data[0:2]) {
+		case OFPIT_GOTO_TABLE:
+			stat.GotoTable = true
+			stat.GotoTableID = data[4]
+		case OFPIT_WRITE_ACTIONS:
+			stat.WriteActions = unmarshalInstructionActions(data[8:length])
+		case OFPIT_APPLY_ACTIONS:
+			stat.Actions = unmarshalInstructionActions(data[8:length])
+		case OFPIT_METER:
+			stat.MeterID = binary.BigEndian.Uint32(data[4:8])
+		}
+		data = data[length:]
+	}
+}
+
+func unmarshalInstructionActions(data []byte) openflow.Action {
+	action := NewAction()
+	if err := action.UnmarshalBinary(data); err != nil {
+		return nil
+	}
+
+	return action
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,132 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+func newFlowStatsEntry(v openflow.FlowStat) []byte {
+	// OXM match with the IPv4 Ethernet type: 10 bytes plus 6 bytes of padding.
+	match := []byte{0x00, 0x01, 0x00, 0x0a, 0x80, 0x00, 0x0a, 0x02, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	// A METER instruction with meter 3, a CLEAR_ACTIONS instruction that should be skipped, APPLY_ACTIONS and
+	// WRITE_ACTIONS instructions whose action is an output to port 2, and a GOTO_TABLE instruction to table 4.
+	inst := []byte{
+		0x00, 0x06, 0x00, 0x08, 0x00, 0x00, 0x00, 0x03,
+		0x00, 0x05, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x04, 0x00, 0x18, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x02, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x03, 0x00, 0x18, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x02, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x01, 0x00, 0x08, 0x04, 0x00, 0x00, 0x00,
+	}
+
+	entry := make([]byte, 48)
+	binary.BigEndian.PutUint16(entry[0:2], uint16(48+len(match)+len(inst)))
+	entry[2] = v.TableID
+	binary.BigEndian.PutUint32(entry[4:8], v.DurationSec)
+	binary.BigEndian.PutUint32(entry[8:12], v.DurationNanoSec)
+	binary.BigEndian.PutUint16(entry[12:14], v.Priority)
+	binary.BigEndian.PutUint16(entry[14:16], v.IdleTimeout)
+	binary.BigEndian.PutUint16(entry[16:18], v.HardTimeout)
+	binary.BigEndian.PutUint64(entry[24:32], v.Cookie)
+	binary.BigEndian.PutUint64(entry[32:40], v.PacketCount)
+	binary.BigEndian.PutUint64(entry[40:48], v.ByteCount)
+
+	return append(append(entry, match...), inst...)
+}
+
+func TestFlowStatsReply(t *testing.T) {
+	src := []struct {
+		Flags uint16
+		Stats []openflow.FlowStat
+	}{
+		{
+			Flags: 0,
+			Stats: []openflow.FlowStat{},
+		},
+		{
+			Flags: OFPMPF_REPLY_MORE,
+			Stats: []openflow.FlowStat{
+				{TableID: 0, Priority: 10, Cookie: 1 << 63, DurationSec: 30, DurationNanoSec: 500, IdleTimeout: 90, PacketCount: 1000, ByteCount: 64000},
+				{TableID: 100, Priority: 100, DurationSec: 1, HardTimeout: 60, PacketCount: 1, ByteCount: 1500},
+			},
+		},
+	}
+
+	for i, v := range src {
+		body := []byte{0x00, 0x01, byte(v.Flags >> 8), byte(v.Flags), 0x00, 0x00, 0x00, 0x00}
+		for _, s := range v.Stats {
+			body = append(body, newFlowStatsEntry(s)...)
+		}
+		msg := openflow.NewMessage(openflow.OF13_VERSION, OFPT_MULTIPART_REPLY, 1)
+		msg.SetPayload(body)
+		packet, err := msg.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+
+		reply := new(FlowStatsReply)
+		if err := reply.UnmarshalBinary(packet); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if reply.More() != (v.Flags&OFPMPF_REPLY_MORE != 0) {
+			t.Fatalf("#%v: unexpected more flag: %v", i, reply.More())
+		}
+		if len(reply.Stats()) != len(v.Stats) {
+			t.Fatalf("#%v: unexpected number of stats: expected=%v, got=%v", i, len(v.Stats), len(reply.Stats()))
+		}
+		for j, s := range reply.Stats() {
+			if s.Match == nil {
+				t.Fatalf("#%v-%v: missing match", i, j)
+			}
+			if wildcard, etherType := s.Match.EtherType(); wildcard || etherType != 0x0800 {
+				t.Fatalf("#%v-%v: unexpected match: wildcard=%v, etherType=%v", i, j, wildcard, etherType)
+			}
+			if s.Actions == nil {
+				t.Fatalf("#%v-%v: missing actions", i, j)
+			}
+			if out := s.Actions.OutPort(); out.Value() != 2 {
+				t.Fatalf("#%v-%v: unexpected output port: %v", i, j, out)
+			}
+			if s.WriteActions == nil {
+				t.Fatalf("#%v-%v: missing write actions", i, j)
+			}
+			if out := s.WriteActions.OutPort(); out.Value() != 2 {
+				t.Fatalf("#%v-%v: unexpected output port of the write actions: %v", i, j, out)
+			}
+			if s.MeterID != 3 || !s.GotoTable || s.GotoTableID != 4 {
+				t.Fatalf("#%v-%v: unexpected instructions: meter=%v, gotoTable=%v/%v", i, j, s.MeterID, s.GotoTable, s.GotoTableID)
+			}
+			s.Match = nil
+			s.Actions = nil
+			s.WriteActions = nil
+			s.MeterID, s.GotoTable, s.GotoTableID = 0, false, 0
+			if s != v.Stats[j] {
+				t.Fatalf("#%v-%v: unexpected stats: expected=%+v, got=%+v", i, j, v.Stats[j], s)
+			}
+		}
+	}
+}
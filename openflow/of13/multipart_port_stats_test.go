@@ -0,0 +1,79 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestPortStatsRequest(t *testing.T) {
+	req := NewPortStatsRequest(1)
+	data, err := req.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if port := binary.BigEndian.Uint32(data[16:20]); port != OFPP_ANY {
+		t.Fatalf("unexpected port number: %v", port)
+	}
+
+	req.SetPortNumber(3)
+	data, err = req.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if port := binary.BigEndian.Uint32(data[16:20]); port != 3 {
+		t.Fatalf("unexpected port number: %v", port)
+	}
+}
+
+func TestPortStatsReply(t *testing.T) {
+	packet := make([]byte, 8+8+112*2)
+	packet[0] = 0x04
+	packet[1] = OFPT_MULTIPART_REPLY
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	binary.BigEndian.PutUint16(packet[8:10], OFPMP_PORT_STATS)
+	binary.BigEndian.PutUint16(packet[10:12], OFPMPF_REPLY_MORE)
+	for i := 0; i < 2; i++ {
+		entry := packet[16+112*i:]
+		binary.BigEndian.PutUint32(entry[0:4], uint32(i+1))
+		binary.BigEndian.PutUint64(entry[24:32], uint64(1000*(i+1)))
+		binary.BigEndian.PutUint64(entry[64:72], uint64(i+7))
+	}
+
+	reply := new(PortStatsReply)
+	if err := reply.UnmarshalBinary(packet); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if !reply.More() {
+		t.Fatal("expected more replies")
+	}
+	stats := reply.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("unexpected number of stats: %v", len(stats))
+	}
+	for i, v := range stats {
+		if v.PortNo != uint32(i+1) || v.RxBytes != uint64(1000*(i+1)) || v.TxErrors != uint64(i+7) {
+			t.Fatalf("#%v: unexpected stat: %+v", i, v)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"encoding/binary"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+type TableStatsRequest struct {
+	openflow.Message
+}
+
+func NewTableStatsRequest(xid uint32) openflow.TableStatsRequest {
+	return &TableStatsRequest{
+		Message: openflow.NewMessage(openflow.OF13_VERSION, OFPT_MULTIPART_REQUEST, xid),
+	}
+}
+
+func (r *TableStatsRequest) MarshalBinary() ([]byte, error) {
+	v := make([]byte, 8)
+	// Table stats request
+	binary.BigEndian.PutUint16(v[0:2], OFPMP_TABLE)
+	// No flags and body
+	r.SetPayload(v)
+
+	return r.Message.MarshalBinary()
+}
+
+type TableStatsReply struct {
+	openflow.Message
+	more  bool
+	stats []openflow.TableStat
+}
+
+func (r TableStatsReply) More() bool {
+	return r.more
+}
+
+func (r TableStatsReply) Stats() []openflow.TableStat {
+	return r.stats
+}
+
+func (r *TableStatsReply) UnmarshalBinary(data []byte) error {
+	if err := r.Message.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	payload := r.Payload()
+	if payload == nil || len(payload) < 8 {
+		return openflow.ErrInvalidPacketLength
+	}
+	// payload[0:2] is type of ofp_multipart_reply, and payload[4:8] is padding.
+	r.more = binary.BigEndian.Uint16(payload[2:4])&OFPMPF_REPLY_MORE != 0
+	r.stats = make([]openflow.TableStat, 0)
+
+	// ofp_table_stats is 24 bytes long.
+	buf := payload[8:]
+	for len(buf) >= 24 {
+		r.stats = append(r.stats, openflow.TableStat{
+			TableID: buf[0],
+			// buf[1:4] is padding
+			ActiveCount:  binary.BigEndian.Uint32(buf[4:8]),
+			LookupCount:  binary.BigEndian.Uint64(buf[8:16]),
+			MatchedCount: binary.BigEndian.Uint64(buf[16:24]),
+		})
+		buf = buf[24:]
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestTableStatsReply(t *testing.T) {
+	packet := make([]byte, 8+8+24*2)
+	packet[0] = 0x04
+	packet[1] = OFPT_MULTIPART_REPLY
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	binary.BigEndian.PutUint16(packet[8:10], OFPMP_TABLE)
+	for i := 0; i < 2; i++ {
+		entry := packet[16+24*i:]
+		entry[0] = uint8(i)
+		binary.BigEndian.PutUint32(entry[4:8], uint32(10*(i+1)))
+		binary.BigEndian.PutUint64(entry[8:16], uint64(100*(i+1)))
+		binary.BigEndian.PutUint64(entry[16:24], uint64(50*(i+1)))
+	}
+
+	reply := new(TableStatsReply)
+	if err := reply.UnmarshalBinary(packet); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if reply.More() {
+		t.Fatal("unexpected more flag")
+	}
+	stats := reply.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("unexpected number of stats: %v", len(stats))
+	}
+	for i, v := range stats {
+		if v.TableID != uint8(i) || v.ActiveCount != uint32(10*(i+1)) || v.LookupCount != uint64(100*(i+1)) || v.MatchedCount != uint64(50*(i+1)) {
+			t.Fatalf("#%v: unexpected stat: %+v", i, v)
+		}
+	}
+}
+
+func TestTableMod(t *testing.T) {
+	msg := NewTableMod(1)
+	msg.SetTableID(1)
+	msg.SetConfig(0xFFFFFFFF)
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if len(data) != 16 || data[1] != OFPT_TABLE_MOD || data[8] != 1 {
+		t.Fatalf("unexpected table-mod: %v", data)
+	}
+	// Only the deprecated bits are allowed.
+	if config := binary.BigEndian.Uint32(data[12:16]); config != OFPTC_DEPRECATED_MASK {
+		t.Fatalf("unexpected config: %v", config)
+	}
+}
@@ -32,10 +32,12 @@ type PacketIn struct {
 	bufferID uint32
 	length   uint16
 	inPort   uint32
-	tableID  uint8
-	reason   uint8
-	cookie   uint64
-	data     []byte
+	// Zero means the physical port is same with the in-port.
+	phyPort uint32
+	tableID uint8
+	reason  uint8
+	cookie  uint64
+	data    []byte
 }
 
 func (r PacketIn) BufferID() uint32 {
@@ -46,6 +48,15 @@ func (r PacketIn) InPort() uint32 {
 	return r.inPort
 }
 
+func (r PacketIn) PhysicalInPort() uint32 {
+	// The switch omits IN_PHY_PORT if it is same with IN_PORT.
+	if r.phyPort == 0 {
+		return r.inPort
+	}
+
+	return r.phyPort
+}
+
 func (r PacketIn) Data() []byte {
 	return r.data
 }
@@ -87,6 +98,7 @@ func (r *PacketIn) UnmarshalBinary(data []byte) error {
 	}
 	_, inport := match.InPort()
 	r.inPort = inport.Value()
+	_, r.phyPort = match.PhysicalInPort()
 
 	matchLength := binary.BigEndian.Uint16(payload[18:20])
 	// Calculate padding length
@@ -80,6 +80,11 @@ func (r Port) IsFiber() bool {
 	return r.current&OFPPF_FIBER != 0
 }
 
+// IsNoFlood always returns false because OpenFlow 1.3 does not have OFPPC_NO_FLOOD.
+func (r Port) IsNoFlood() bool {
+	return false
+}
+
 func (r Port) IsAutoNego() bool {
 	return r.current&OFPPF_AUTONEG != 0
 }
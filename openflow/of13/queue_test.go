@@ -0,0 +1,138 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+// queue returns an ofp_packet_queue with a min-rate and a max-rate property.
+func queue(id, port uint32, min, max uint16) []byte {
+	v := make([]byte, 48)
+	binary.BigEndian.PutUint32(v[0:4], id)
+	binary.BigEndian.PutUint32(v[4:8], port)
+	binary.BigEndian.PutUint16(v[8:10], 48)
+	binary.BigEndian.PutUint16(v[16:18], uint16(openflow.OFPQT_MIN_RATE))
+	binary.BigEndian.PutUint16(v[18:20], 16)
+	binary.BigEndian.PutUint16(v[24:26], min)
+	binary.BigEndian.PutUint16(v[32:34], uint16(openflow.OFPQT_MAX_RATE))
+	binary.BigEndian.PutUint16(v[34:36], 16)
+	binary.BigEndian.PutUint16(v[40:42], max)
+	return v
+}
+
+func queueGetConfigReply(port uint32, queues ...[]byte) []byte {
+	v := make([]byte, 16)
+	v[0] = 0x04
+	v[1] = OFPT_QUEUE_GET_CONFIG_REPLY
+	binary.BigEndian.PutUint32(v[8:12], port)
+	for _, q := range queues {
+		v = append(v, q...)
+	}
+	binary.BigEndian.PutUint16(v[2:4], uint16(len(v)))
+	return v
+}
+
+func TestQueueGetConfigReply(t *testing.T) {
+	src := []struct {
+		packet []byte
+		port   uint32
+		ids    []uint32
+		min    []uint16
+		max    []uint16
+		err    bool
+	}{
+		{queueGetConfigReply(1), 1, nil, nil, nil, false},
+		{queueGetConfigReply(2, queue(0, 2, 100, 200), queue(5, 2, 300, 1000)), 2, []uint32{0, 5}, []uint16{100, 300}, []uint16{200, 1000}, false},
+		{queueGetConfigReply(3, queue(1, 3, 100, 200)[:40]), 0, nil, nil, nil, true},
+	}
+
+	for i, v := range src {
+		reply := new(QueueGetConfigReply)
+		err := reply.UnmarshalBinary(v.packet)
+		if v.err {
+			if err == nil {
+				t.Fatalf("#%v: expected error, but got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if reply.Port() != v.port {
+			t.Fatalf("#%v: unexpected port: expected=%v, got=%v", i, v.port, reply.Port())
+		}
+		if len(reply.Queue()) != len(v.ids) {
+			t.Fatalf("#%v: unexpected number of queues: expected=%v, got=%v", i, len(v.ids), len(reply.Queue()))
+		}
+		for j, q := range reply.Queue() {
+			if q.ID() != v.ids[j] || q.Port() != v.port {
+				t.Fatalf("#%v: unexpected queue: id=%v, port=%v", i, q.ID(), q.Port())
+			}
+			if len(q.Property()) != 2 {
+				t.Fatalf("#%v: unexpected number of properties: %v", i, len(q.Property()))
+			}
+			min, err := q.Property()[0].Rate()
+			if err != nil || min != v.min[j] {
+				t.Fatalf("#%v: unexpected min rate: expected=%v, got=%v (err=%v)", i, v.min[j], min, err)
+			}
+			max, err := q.Property()[1].Rate()
+			if err != nil || max != v.max[j] {
+				t.Fatalf("#%v: unexpected max rate: expected=%v, got=%v (err=%v)", i, v.max[j], max, err)
+			}
+		}
+	}
+}
+
+func TestSetQueueAction(t *testing.T) {
+	action := NewAction()
+	port := openflow.NewOutPort()
+	port.SetValue(3)
+	action.SetOutPort(port)
+	action.SetQueue(7)
+
+	v, err := action.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	// set_queue (8 bytes) followed by output (16 bytes).
+	if len(v) != 24 {
+		t.Fatalf("unexpected length: %v", len(v))
+	}
+	if binary.BigEndian.Uint16(v[0:2]) != OFPAT_SET_QUEUE || binary.BigEndian.Uint32(v[4:8]) != 7 {
+		t.Fatalf("unexpected set_queue action: %x", v[0:8])
+	}
+	if binary.BigEndian.Uint16(v[8:10]) != OFPAT_OUTPUT || binary.BigEndian.Uint32(v[12:16]) != 3 {
+		t.Fatalf("unexpected output action: %x", v[8:24])
+	}
+
+	decoded := NewAction()
+	if err := decoded.UnmarshalBinary(v); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if ok, id := decoded.Queue(); !ok || id != 7 {
+		t.Fatalf("unexpected queue: ok=%v, id=%v", ok, id)
+	}
+}
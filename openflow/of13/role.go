@@ -0,0 +1,102 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"encoding/binary"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+type RoleRequest struct {
+	openflow.Message
+	role         openflow.ControllerRole
+	generationID uint64
+}
+
+func NewRoleRequest(xid uint32) openflow.RoleRequest {
+	return &RoleRequest{
+		Message: openflow.NewMessage(openflow.OF13_VERSION, OFPT_ROLE_REQUEST, xid),
+	}
+}
+
+func (r *RoleRequest) Role() openflow.ControllerRole {
+	return r.role
+}
+
+func (r *RoleRequest) SetRole(role openflow.ControllerRole) {
+	r.role = role
+}
+
+func (r *RoleRequest) GenerationID() uint64 {
+	return r.generationID
+}
+
+func (r *RoleRequest) SetGenerationID(id uint64) {
+	r.generationID = id
+}
+
+func (r *RoleRequest) MarshalBinary() ([]byte, error) {
+	if r.role > openflow.RoleSlave {
+		return nil, openflow.ErrInvalidControllerRole
+	}
+
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint32(v[0:4], uint32(r.role))
+	// v[4:8] is padding
+	binary.BigEndian.PutUint64(v[8:16], r.generationID)
+	r.SetPayload(v)
+
+	return r.Message.MarshalBinary()
+}
+
+type RoleReply struct {
+	openflow.Message
+	role         openflow.ControllerRole
+	generationID uint64
+}
+
+func (r *RoleReply) Role() openflow.ControllerRole {
+	return r.role
+}
+
+func (r *RoleReply) GenerationID() uint64 {
+	return r.generationID
+}
+
+func (r *RoleReply) UnmarshalBinary(data []byte) error {
+	if err := r.Message.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	payload := r.Payload()
+	if payload == nil || len(payload) < 16 {
+		return openflow.ErrInvalidPacketLength
+	}
+	r.role = openflow.ControllerRole(binary.BigEndian.Uint32(payload[0:4]))
+	if r.role > openflow.RoleSlave {
+		return openflow.ErrInvalidControllerRole
+	}
+	r.generationID = binary.BigEndian.Uint64(payload[8:16])
+
+	return nil
+}
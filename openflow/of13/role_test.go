@@ -0,0 +1,86 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+func TestRoleRequestEncoding(t *testing.T) {
+	src := []struct {
+		role         openflow.ControllerRole
+		generationID uint64
+		expected     []byte
+		err          bool
+	}{
+		{openflow.RoleNoChange, 0, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, false},
+		{openflow.RoleEqual, 0, []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, false},
+		{openflow.RoleMaster, 0x0102030405060708, []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}, false},
+		{openflow.RoleSlave, 0xFFFFFFFFFFFFFFFF, []byte{0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, false},
+		{openflow.ControllerRole(4), 0, nil, true},
+	}
+
+	for i, v := range src {
+		msg := NewRoleRequest(7)
+		msg.SetRole(v.role)
+		msg.SetGenerationID(v.generationID)
+		data, err := msg.MarshalBinary()
+		if (err != nil) != v.err {
+			t.Fatalf("#%v: unexpected result: expected error=%v, got=%v", i, v.err, err)
+		}
+		if v.err {
+			continue
+		}
+		header := []byte{openflow.OF13_VERSION, OFPT_ROLE_REQUEST, 0x00, 0x18, 0x00, 0x00, 0x00, 0x07}
+		if expected := append(header, v.expected...); !bytes.Equal(data, expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, expected, data)
+		}
+
+		// The reply has the same layout with the request.
+		data[1] = OFPT_ROLE_REPLY
+		reply := new(RoleReply)
+		if err := reply.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal the reply: %v", i, err)
+		}
+		if reply.Role() != v.role || reply.GenerationID() != v.generationID || reply.TransactionID() != 7 {
+			t.Fatalf("#%v: unexpected reply: role=%v, generationID=%v, xid=%v", i, reply.Role(), reply.GenerationID(), reply.TransactionID())
+		}
+	}
+}
+
+func TestInvalidRoleReply(t *testing.T) {
+	src := [][]byte{
+		// Too short.
+		{openflow.OF13_VERSION, OFPT_ROLE_REPLY, 0x00, 0x10, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00},
+		// Unknown role.
+		{openflow.OF13_VERSION, OFPT_ROLE_REPLY, 0x00, 0x18, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+
+	for i, v := range src {
+		if err := new(RoleReply).UnmarshalBinary(v); err == nil {
+			t.Fatalf("#%v: expected an error", i)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package of13
+
+import (
+	"encoding/binary"
+
+	"github.com/superkkt/cherry/openflow"
+)
+
+type TableMod struct {
+	openflow.Message
+	tableID uint8
+	config  uint32
+}
+
+func NewTableMod(xid uint32) openflow.TableMod {
+	return &TableMod{
+		Message: openflow.NewMessage(openflow.OF13_VERSION, OFPT_TABLE_MOD, xid),
+	}
+}
+
+func (r *TableMod) TableID() uint8 {
+	return r.tableID
+}
+
+func (r *TableMod) SetTableID(id uint8) {
+	r.tableID = id
+}
+
+func (r *TableMod) Config() uint32 {
+	return r.config
+}
+
+func (r *TableMod) SetConfig(config uint32) {
+	r.config = config
+}
+
+func (r *TableMod) MarshalBinary() ([]byte, error) {
+	v := make([]byte, 8)
+	v[0] = r.tableID
+	// v[1:4] is padding
+	binary.BigEndian.PutUint32(v[4:8], r.config&OFPTC_DEPRECATED_MASK)
+	r.SetPayload(v)
+
+	return r.Message.MarshalBinary()
+}
@@ -30,6 +30,9 @@ type PacketIn interface {
 	BufferID() uint32
 	Length() uint16
 	InPort() uint32
+	// PhysicalInPort returns the physical port that the packet has been received on. It is same with InPort
+	// unless InPort is a logical port, e.g., a link aggregation group, which is only reported by OpenFlow 1.3.
+	PhysicalInPort() uint32
 	TableID() uint8
 	Reason() uint8
 	Cookie() uint64
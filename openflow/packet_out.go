@@ -23,16 +23,54 @@ package openflow
 
 import (
 	"encoding"
+
+	"github.com/pkg/errors"
 )
 
+// NoBuffer is the buffer ID that means the packet is not buffered in a switch.
+const NoBuffer = 0xFFFFFFFF
+
 type PacketOut interface {
+	// Action returns the first action of this message, or nil if there is no action.
 	Action() Action
+	// Actions returns the actions in order they are applied by the switch.
+	Actions() []Action
+	// BufferID returns the ID of the switch buffer that holds the packet, or NoBuffer.
+	BufferID() uint32
 	Data() []byte
 	encoding.BinaryMarshaler
 	Error() error
 	Header
 	InPort() InPort
+	// SetAction replaces the actions of this message with the single action.
 	SetAction(action Action)
+	// SetActions replaces the actions of this message with actions. The switch applies them in order,
+	// e.g., strip the VLAN tag and then output the packet to several ports.
+	SetActions(actions []Action)
+	// SetBufferID makes the switch send the packet held in its buffer instead of
+	// the data of this message. The data is not sent unless id is NoBuffer.
+	SetBufferID(id uint32)
 	SetData(data []byte)
 	SetInPort(port InPort)
 }
+
+// ValidatePacketOut checks the output ports of the packet before it goes on the wire. OFPP_IN_PORT sends the
+// packet back out its ingress port, so the message should specify a switch port as the ingress port instead of
+// the controller.
+func ValidatePacketOut(out PacketOut) error {
+	for _, action := range out.Actions() {
+		if action == nil {
+			continue
+		}
+		for _, port := range action.OutPorts() {
+			if !port.IsInPort() {
+				continue
+			}
+			if in := out.InPort(); in.IsController() {
+				return errors.New("invalid packet-out action: output to IN_PORT requires a switch port as the ingress port")
+			}
+		}
+	}
+
+	return nil
+}
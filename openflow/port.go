@@ -34,11 +34,18 @@ const (
 	controller
 	inport
 	none
+	normal
 )
 
+// NoBufferMaxLen is the maximum number of bytes of a packet sent to the controller that means the whole packet
+// should be sent without buffering it on the switch.
+const NoBufferMaxLen = 0xFFFF
+
 type OutPort struct {
 	logical uint8
 	value   uint32
+	// Maximum number of bytes of the packet sent to the controller.
+	maxLen uint16
 }
 
 // NewOutPort returns output port whose default value is FLOOD
@@ -74,6 +81,25 @@ func (r *OutPort) IsAll() bool {
 
 func (r *OutPort) SetController() {
 	r.logical = 0x1 << controller
+	r.maxLen = NoBufferMaxLen
+}
+
+// SetControllerMaxLen sets the controller as the output port that receives at most maxLen bytes of the packet,
+// e.g., only the headers of the packet for monitoring. NoBufferMaxLen means the whole packet, which is same
+// with SetController.
+func (r *OutPort) SetControllerMaxLen(maxLen uint16) {
+	r.logical = 0x1 << controller
+	r.maxLen = maxLen
+}
+
+// MaxLen returns the maximum number of bytes of the packet sent to the controller. It is always NoBufferMaxLen
+// if the output port is not the controller.
+func (r *OutPort) MaxLen() uint16 {
+	if !r.IsController() {
+		return NoBufferMaxLen
+	}
+
+	return r.maxLen
 }
 
 func (r *OutPort) IsController() bool {
@@ -96,6 +122,22 @@ func (r *OutPort) IsNone() bool {
 	return r.logical&(0x1<<none) != 0
 }
 
+// SetNormal sets OFPP_NORMAL that hands the packet to the legacy L2/L3 pipeline of a hybrid switch, so that
+// the switch forwards it using its own MAC learning. Only the hybrid switches support it, e.g., Open vSwitch
+// and HP ProVision switches in the hybrid mode, and the pure OpenFlow switches reject it with an error.
+func (r *OutPort) SetNormal() {
+	r.logical = 0x1 << normal
+}
+
+func (r *OutPort) IsNormal() bool {
+	return r.logical&(0x1<<normal) != 0
+}
+
+// IsPhysical returns whether this output port is a physical switch port rather than a logical one.
+func (r *OutPort) IsPhysical() bool {
+	return r.logical == 0
+}
+
 func (r *OutPort) SetValue(port uint32) {
 	r.logical = 0x0
 	r.value = port
@@ -106,6 +148,10 @@ func (r *OutPort) Value() uint32 {
 }
 
 func (r OutPort) String() string {
+	if r.IsController() {
+		return fmt.Sprintf("logical: %v, value: %v, maxLen: %v", r.logical, r.value, r.maxLen)
+	}
+
 	return fmt.Sprintf("logical: %v, value: %v", r.logical, r.value)
 }
 
@@ -144,6 +190,8 @@ type Port interface {
 	Name() string
 	IsPortDown() bool // Is the port Administratively down?
 	IsLinkDown() bool // Is a physical link on the port down?
+	// IsNoFlood returns whether the port is excluded from the switch's FLOOD port. OpenFlow 1.0 only.
+	IsNoFlood() bool
 	IsCopper() bool
 	IsFiber() bool
 	IsAutoNego() bool
@@ -0,0 +1,59 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package openflow
+
+import (
+	"encoding"
+	"net"
+)
+
+// PortConfig is a bitmap of the administrative port settings that can be
+// changed by a PORT_MOD message.
+type PortConfig uint32
+
+const (
+	PortConfigDown PortConfig = 1 << iota
+	PortConfigNoSTP
+	PortConfigNoRecv
+	PortConfigNoRecvSTP
+	PortConfigNoFlood
+	PortConfigNoFwd
+	PortConfigNoPacketIn
+)
+
+type PortMod interface {
+	Header
+	// Error() returns last error message
+	Error() error
+	PortNumber() uint32
+	SetPortNumber(port uint32)
+	HWAddr() net.HardwareAddr
+	// SetHWAddr sets the hardware address of the port, which should be same
+	// with the one reported by FEATURES_REPLY or PORT_DESC_REPLY.
+	SetHWAddr(mac net.HardwareAddr)
+	Config() PortConfig
+	Mask() PortConfig
+	// SetConfig sets the config bits selected by mask. Bits not in mask are
+	// left unchanged by the switch.
+	SetConfig(config, mask PortConfig)
+	encoding.BinaryMarshaler
+}
@@ -0,0 +1,76 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package openflow
+
+import (
+	"encoding"
+	"fmt"
+)
+
+// ControllerRole is the role of the controller on a switch connection for the multi-controller high
+// availability. OpenFlow 1.3 only.
+type ControllerRole uint32
+
+const (
+	// RoleNoChange queries the current role without changing it.
+	RoleNoChange ControllerRole = iota
+	// RoleEqual is the default role that has full access to the switch, same as the master.
+	RoleEqual
+	// RoleMaster has full access to the switch, and the switch changes the other masters to slaves.
+	RoleMaster
+	// RoleSlave has read-only access to the switch: it cannot modify the state of the switch, such as
+	// the flows, and cannot send packets.
+	RoleSlave
+)
+
+func (r ControllerRole) String() string {
+	switch r {
+	case RoleNoChange:
+		return "nochange"
+	case RoleEqual:
+		return "equal"
+	case RoleMaster:
+		return "master"
+	case RoleSlave:
+		return "slave"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint32(r))
+	}
+}
+
+type RoleRequest interface {
+	Header
+	Role() ControllerRole
+	SetRole(role ControllerRole)
+	// GenerationID is the generation of the master election, which the switch uses to reject the requests of
+	// a stale master or slave. It is ignored for RoleEqual and RoleNoChange.
+	GenerationID() uint64
+	SetGenerationID(id uint64)
+	encoding.BinaryMarshaler
+}
+
+type RoleReply interface {
+	Header
+	Role() ControllerRole
+	GenerationID() uint64
+	encoding.BinaryUnmarshaler
+}
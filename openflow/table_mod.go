@@ -0,0 +1,40 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package openflow
+
+import (
+	"encoding"
+)
+
+// TableAll means all the tables in TableMod.SetTableID.
+const TableAll uint8 = 0xFF
+
+// TableMod configures the behavior of a flow table. The config bits are deprecated since OpenFlow 1.3,
+// but they are still used by some switches to decide what to do on a table miss.
+type TableMod interface {
+	encoding.BinaryMarshaler
+	Config() uint32
+	Header
+	SetConfig(config uint32)
+	SetTableID(id uint8)
+	TableID() uint8
+}
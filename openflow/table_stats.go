@@ -0,0 +1,50 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package openflow
+
+import (
+	"encoding"
+)
+
+type TableStatsRequest interface {
+	Header
+	encoding.BinaryMarshaler
+}
+
+// TableStat is the statistics of a flow table in a TABLE_STATS reply.
+type TableStat struct {
+	TableID uint8
+	// Number of active flow entries.
+	ActiveCount uint32
+	// Number of packets looked up in the table.
+	LookupCount uint64
+	// Number of packets that hit the table.
+	MatchedCount uint64
+}
+
+type TableStatsReply interface {
+	Header
+	// More returns whether more replies will follow this reply to complete the response.
+	More() bool
+	Stats() []TableStat
+	encoding.BinaryUnmarshaler
+}
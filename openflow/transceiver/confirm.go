@@ -0,0 +1,187 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+// RequestError is an ERROR message replied by a switch to a request that is waiting for the confirmation.
+type RequestError struct {
+	XID   uint32 // Transaction ID of the failed request
+	Class uint16 // Error type
+	Code  uint16
+}
+
+func (r *RequestError) Error() string {
+	return fmt.Sprintf("switch replied an error to the request: xid=%v, class=%v, code=%v", r.XID, r.Class, r.Code)
+}
+
+// confirmer correlates the BARRIER_REPLY and ERROR messages with the requests waiting for the confirmation.
+type confirmer struct {
+	mutex sync.Mutex
+	// Key is the transaction ID of the barrier request.
+	waiters map[uint32]*confirmWaiter
+	// Waiters of the barrier requests sent by SendBarrierRequest. They are kept even after the barrier reply
+	// arrives until WaitBarrier takes them. Key is the transaction ID of the barrier request.
+	barriers map[uint32]*confirmWaiter
+}
+
+type confirmWaiter struct {
+	// Transaction IDs of the requests sent before the barrier request.
+	requests map[uint32]struct{}
+	// First error replied to the requests.
+	err  error
+	done chan struct{}
+}
+
+func newConfirmer() *confirmer {
+	return &confirmer{
+		waiters:  make(map[uint32]*confirmWaiter),
+		barriers: make(map[uint32]*confirmWaiter),
+	}
+}
+
+// add registers a new waiter that will be done when the barrier reply whose transaction ID is barrier arrives.
+func (r *confirmer) add(barrier uint32, requests []uint32) *confirmWaiter {
+	w := &confirmWaiter{
+		requests: make(map[uint32]struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, xid := range requests {
+		w.requests[xid] = struct{}{}
+	}
+
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.waiters[barrier] = w
+
+	return w
+}
+
+// addBarrier registers a new waiter for the barrier that is not tied to any request. The waiter can be taken
+// by take regardless of whether the barrier reply has already arrived.
+func (r *confirmer) addBarrier(barrier uint32) {
+	w := r.add(barrier, nil)
+
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.barriers[barrier] = w
+}
+
+// take returns the waiter registered by addBarrier and unregisters it. It returns false if there is no such waiter.
+func (r *confirmer) take(barrier uint32) (*confirmWaiter, bool) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	w, ok := r.barriers[barrier]
+	if !ok {
+		return nil, false
+	}
+	delete(r.barriers, barrier)
+
+	return w, true
+}
+
+// remove removes the waiter of the barrier that we no longer wait for, e.g., due to the timeout.
+func (r *confirmer) remove(barrier uint32) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.waiters, barrier)
+	delete(r.barriers, barrier)
+}
+
+// handle inspects the incoming packet and notifies the waiters if the packet is a BARRIER_REPLY or ERROR message.
+func (r *confirmer) handle(packet []byte) {
+	if len(packet) < 8 {
+		return
+	}
+	xid := binary.BigEndian.Uint32(packet[4:8])
+
+	var barrierReply uint8
+	switch packet[0] {
+	case openflow.OF10_VERSION:
+		barrierReply = of10.OFPT_BARRIER_REPLY
+	case openflow.OF13_VERSION:
+		barrierReply = of13.OFPT_BARRIER_REPLY
+	default:
+		return
+	}
+
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	switch packet[1] {
+	case barrierReply:
+		w, ok := r.waiters[xid]
+		if !ok {
+			return
+		}
+		delete(r.waiters, xid)
+		close(w.done)
+	// OFPT_ERROR is same for both OF10 and OF13.
+	case of10.OFPT_ERROR:
+		if len(packet) < 12 {
+			return
+		}
+		for _, w := range r.waiters {
+			if _, ok := w.requests[xid]; !ok || w.err != nil {
+				continue
+			}
+			w.err = &RequestError{
+				XID:   xid,
+				Class: binary.BigEndian.Uint16(packet[8:10]),
+				Code:  binary.BigEndian.Uint16(packet[10:12]),
+			}
+		}
+	}
+}
+
+// closeAll makes all the waiters done with err, e.g., when the connection is closed. The waiters registered by
+// addBarrier are kept so that WaitBarrier can still report err.
+func (r *confirmer) closeAll(err error) {
+	// Write lock
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for xid, w := range r.waiters {
+		if w.err == nil {
+			w.err = err
+		}
+		close(w.done)
+		delete(r.waiters, xid)
+	}
+}
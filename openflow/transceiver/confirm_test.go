@@ -0,0 +1,279 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func newTestPacket(msgType uint8, xid uint32, body ...byte) []byte {
+	header := []byte{openflow.OF13_VERSION, msgType, 0, 0, byte(xid >> 24), byte(xid >> 16), byte(xid >> 8), byte(xid)}
+	packet := append(header, body...)
+	packet[2] = byte(len(packet) >> 8)
+	packet[3] = byte(len(packet))
+
+	return packet
+}
+
+func isDone(w *confirmWaiter) bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestConfirmer(t *testing.T) {
+	src := []struct {
+		Replies  [][]byte
+		Done     bool
+		Expected *RequestError
+	}{
+		// Barrier reply for another barrier request.
+		{
+			Replies: [][]byte{newTestPacket(of13.OFPT_BARRIER_REPLY, 99)},
+			Done:    false,
+		},
+		// Confirmed without an error.
+		{
+			Replies: [][]byte{newTestPacket(of13.OFPT_BARRIER_REPLY, 10)},
+			Done:    true,
+		},
+		// Error for an unrelated request.
+		{
+			Replies: [][]byte{
+				newTestPacket(of13.OFPT_ERROR, 3, 0x00, 0x05, 0x00, 0x01),
+				newTestPacket(of13.OFPT_BARRIER_REPLY, 10),
+			},
+			Done: true,
+		},
+		// The first error replied to the requests should be returned.
+		{
+			Replies: [][]byte{
+				newTestPacket(of13.OFPT_ERROR, 2, 0x00, 0x05, 0x00, 0x01),
+				newTestPacket(of13.OFPT_ERROR, 1, 0x00, 0x04, 0x00, 0x02),
+				newTestPacket(of13.OFPT_BARRIER_REPLY, 10),
+			},
+			Done:     true,
+			Expected: &RequestError{XID: 2, Class: 5, Code: 1},
+		},
+	}
+
+	for i, v := range src {
+		c := newConfirmer()
+		w := c.add(10, []uint32{1, 2})
+		for _, p := range v.Replies {
+			c.handle(p)
+		}
+		if isDone(w) != v.Done {
+			t.Fatalf("#%v: unexpected done status: expected=%v, got=%v", i, v.Done, !v.Done)
+		}
+		if !v.Done {
+			continue
+		}
+		if v.Expected == nil {
+			if w.err != nil {
+				t.Fatalf("#%v: unexpected error: %v", i, w.err)
+			}
+			continue
+		}
+		err, ok := w.err.(*RequestError)
+		if !ok || *err != *v.Expected {
+			t.Fatalf("#%v: unexpected error: expected=%v, got=%v", i, v.Expected, w.err)
+		}
+		if len(c.waiters) != 0 {
+			t.Fatalf("#%v: the confirmed waiter is not removed", i)
+		}
+	}
+}
+
+func TestConfirmerCloseAll(t *testing.T) {
+	c := newConfirmer()
+	w1 := c.add(10, []uint32{1})
+	w2 := c.add(20, []uint32{2})
+	c.remove(20)
+	closed := errors.New("closed")
+	c.closeAll(closed)
+
+	if !isDone(w1) || w1.err != closed {
+		t.Fatalf("unexpected waiter status after closing all: done=%v, err=%v", isDone(w1), w1.err)
+	}
+	if isDone(w2) {
+		t.Fatal("removed waiter should not be done")
+	}
+}
+
+// nopHandler is a Handler that is never called because the tests do not dispatch the packets.
+type nopHandler struct {
+	Handler
+}
+
+func TestWriteSync(t *testing.T) {
+	src := []struct {
+		Replies  [][]byte
+		Expected error
+	}{
+		{
+			Replies: [][]byte{newTestPacket(of13.OFPT_BARRIER_REPLY, 2)},
+		},
+		{
+			Replies: [][]byte{
+				newTestPacket(of13.OFPT_ERROR, 1, 0x00, 0x05, 0x00, 0x01),
+				newTestPacket(of13.OFPT_BARRIER_REPLY, 2),
+			},
+			Expected: &RequestError{XID: 1, Class: 5, Code: 1},
+		},
+		// No reply until the context is done.
+		{
+			Expected: context.DeadlineExceeded,
+		},
+	}
+
+	for i, v := range src {
+		controller, device := net.Pipe()
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		trans := NewTransceiver(NewStream(controller, 0xFFFF), nopHandler{})
+		trans.runReader(ctx)
+
+		go func(replies [][]byte) {
+			// Request and the barrier request.
+			if _, err := io.ReadFull(device, make([]byte, 16)); err != nil {
+				return
+			}
+			for _, p := range replies {
+				device.Write(p)
+			}
+		}(v.Replies)
+
+		err := trans.WriteSync(ctx, of13.NewBarrierRequest(2), of13.NewBarrierRequest(1))
+		switch expected := v.Expected.(type) {
+		case nil:
+			if err != nil {
+				t.Fatalf("#%v: unexpected error: %v", i, err)
+			}
+		case *RequestError:
+			if e, ok := err.(*RequestError); !ok || *e != *expected {
+				t.Fatalf("#%v: unexpected error: expected=%v, got=%v", i, expected, err)
+			}
+		default:
+			if errors.Cause(err) != expected {
+				t.Fatalf("#%v: unexpected error: expected=%v, got=%v", i, expected, err)
+			}
+		}
+
+		cancel()
+		controller.Close()
+		device.Close()
+	}
+}
+
+func TestWaitBarrier(t *testing.T) {
+	controller, device := net.Pipe()
+	defer device.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	trans := NewTransceiver(NewStream(controller, 0xFFFF), nopHandler{})
+	trans.version = openflow.OF13_VERSION
+	trans.factory = of13.NewFactory()
+	trans.runReader(ctx)
+
+	requests := make(chan uint32, 2)
+	go func() {
+		header := make([]byte, 8)
+		for {
+			if _, err := io.ReadFull(device, header); err != nil {
+				return
+			}
+			requests <- uint32(header[4])<<24 | uint32(header[5])<<16 | uint32(header[6])<<8 | uint32(header[7])
+		}
+	}()
+
+	xid1, err := trans.SendBarrierRequest()
+	if err != nil {
+		t.Fatalf("failed to send the first barrier request: %v", err)
+	}
+	xid2, err := trans.SendBarrierRequest()
+	if err != nil {
+		t.Fatalf("failed to send the second barrier request: %v", err)
+	}
+	for _, expected := range []uint32{xid1, xid2} {
+		if xid := <-requests; xid != expected {
+			t.Fatalf("unexpected barrier request: expected=%v, got=%v", expected, xid)
+		}
+	}
+
+	// Reply to the second barrier first.
+	device.Write(newTestPacket(of13.OFPT_BARRIER_REPLY, xid2))
+	if err := trans.WaitBarrier(ctx, xid2); err != nil {
+		t.Fatalf("unexpected error waiting for the second barrier: %v", err)
+	}
+	// The first barrier is still outstanding.
+	shortCtx, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer shortCancel()
+	if err := trans.WaitBarrier(shortCtx, xid1); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("unexpected error waiting for the unreplied barrier: %v", err)
+	}
+	// The canceled barrier cannot be waited again.
+	if err := trans.WaitBarrier(ctx, xid1); err == nil {
+		t.Fatal("expected an error waiting for the canceled barrier")
+	}
+
+	xid3, err := trans.SendBarrierRequest()
+	if err != nil {
+		t.Fatalf("failed to send the third barrier request: %v", err)
+	}
+	<-requests
+	xid4, err := trans.SendBarrierRequest()
+	if err != nil {
+		t.Fatalf("failed to send the fourth barrier request: %v", err)
+	}
+	<-requests
+	// Reply to the fourth barrier before anyone waits for it, and then to the third one.
+	device.Write(newTestPacket(of13.OFPT_BARRIER_REPLY, xid4))
+	device.Write(newTestPacket(of13.OFPT_BARRIER_REPLY, xid3))
+	if err := trans.WaitBarrier(ctx, xid3); err != nil {
+		t.Fatalf("unexpected error waiting for the third barrier: %v", err)
+	}
+	if err := trans.WaitBarrier(ctx, xid4); err != nil {
+		t.Fatalf("unexpected error waiting for the fourth barrier: %v", err)
+	}
+
+	// Disconnection fails the outstanding barriers.
+	xid5, err := trans.SendBarrierRequest()
+	if err != nil {
+		t.Fatalf("failed to send the fifth barrier request: %v", err)
+	}
+	<-requests
+	device.Close()
+	if err := trans.WaitBarrier(ctx, xid5); err == nil || errors.Cause(err) == context.DeadlineExceeded {
+		t.Fatalf("unexpected error waiting for the barrier after disconnection: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"fmt"
+	"time"
+)
+
+// liveness tracks the echo requests sent to a switch to detect a dead connection. An echo request is only
+// sent when nothing has been received from the switch for the interval, so a busy switch is not probed at
+// all, and a passive switch is probed every interval while it is idle.
+type liveness struct {
+	interval  time.Duration
+	maxMisses int
+	// Timestamp of the last packet received from the switch.
+	lastActivated time.Time
+	// Timestamp of the last echo request sent to the switch.
+	lastProbed time.Time
+	// Number of the consecutive echo requests that have not been replied.
+	misses int
+}
+
+func newLiveness(interval time.Duration, maxMisses int, now time.Time) *liveness {
+	return &liveness{
+		interval:      interval,
+		maxMisses:     maxMisses,
+		lastActivated: now,
+	}
+}
+
+// activate records that a packet has been received from the switch at now.
+func (r *liveness) activate(now time.Time) {
+	r.lastActivated = now
+}
+
+// probe returns whether an echo request should be sent to the switch at now, and counts it as a miss
+// until its reply is received. It returns an error if the switch has missed maxMisses consecutive replies.
+func (r *liveness) probe(now time.Time) (bool, error) {
+	if now.Sub(r.lastActivated) < r.interval || now.Sub(r.lastProbed) < r.interval {
+		return false, nil
+	}
+	if r.misses >= r.maxMisses {
+		return false, fmt.Errorf("device does not respond to %v consecutive echo requests", r.misses)
+	}
+	if r.misses == 1 {
+		logger.Warningf("device does not respond to our echo request: will give up after %v misses", r.maxMisses)
+	}
+	r.misses++
+	r.lastProbed = now
+
+	return true, nil
+}
+
+// reply records that an echo reply has been received from the switch.
+func (r *liveness) reply() {
+	if r.misses > 1 {
+		logger.Infof("device responds to our echo request again after %v misses", r.misses-1)
+	}
+	r.misses = 0
+}
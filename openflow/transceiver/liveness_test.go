@@ -0,0 +1,142 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+func TestLiveness(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	src := []struct {
+		Elapsed  time.Duration // Since the previous step.
+		Activate bool
+		Reply    bool
+		Probe    bool
+		Dead     bool
+	}{
+		// Not idle yet.
+		{Elapsed: 5 * time.Second, Probe: false},
+		// Idle for the interval.
+		{Elapsed: 5 * time.Second, Probe: true},
+		// Next probe is not due yet.
+		{Elapsed: 5 * time.Second, Probe: false},
+		// Missed the first reply.
+		{Elapsed: 5 * time.Second, Probe: true},
+		// The reply resets the misses.
+		{Elapsed: 1 * time.Second, Activate: true, Reply: true, Probe: false},
+		{Elapsed: 10 * time.Second, Probe: true},
+		{Elapsed: 10 * time.Second, Probe: true},
+		// Another packet from the switch defers the next probe, but does not reset the misses.
+		{Elapsed: 5 * time.Second, Activate: true, Probe: false},
+		{Elapsed: 5 * time.Second, Probe: false},
+		{Elapsed: 5 * time.Second, Dead: true},
+	}
+
+	l := newLiveness(10*time.Second, 2, now)
+	for i, v := range src {
+		now = now.Add(v.Elapsed)
+		if v.Activate {
+			l.activate(now)
+		}
+		if v.Reply {
+			l.reply()
+		}
+		probe, err := l.probe(now)
+		if v.Dead {
+			if err == nil {
+				t.Fatalf("#%v: expected an error, but got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if probe != v.Probe {
+			t.Fatalf("#%v: unexpected probe: expected=%v, got=%v", i, v.Probe, probe)
+		}
+	}
+}
+
+func TestKeepaliveDeadSwitch(t *testing.T) {
+	controller, device := net.Pipe()
+	defer controller.Close()
+	defer device.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	trans := NewTransceiver(NewStream(controller, 0xFFFF), nopHandler{})
+	trans.version = openflow.OF13_VERSION
+	trans.factory = of13.NewFactory()
+	trans.SetEchoInterval(50 * time.Millisecond)
+	trans.SetEchoMaxMisses(2)
+	trans.stream.SetReadTimeout(10 * time.Millisecond)
+	reader := trans.runReader(ctx)
+
+	// The switch replies to the first echo request only, and then stops replying.
+	echoes := make(chan int, 1)
+	go func() {
+		n := 0
+		defer func() { echoes <- n }()
+		for {
+			header := make([]byte, 8)
+			if _, err := io.ReadFull(device, header); err != nil {
+				return
+			}
+			body := make([]byte, int(header[2])<<8|int(header[3])-8)
+			if _, err := io.ReadFull(device, body); err != nil {
+				return
+			}
+			if header[1] != of13.OFPT_ECHO_REQUEST {
+				continue
+			}
+			n++
+			if n == 1 {
+				header[1] = of13.OFPT_ECHO_REPLY
+				device.Write(append(header, body...))
+			}
+		}
+	}()
+
+	select {
+	case _, ok := <-reader:
+		if ok {
+			t.Fatal("unexpected packet from the reader")
+		}
+	case <-ctx.Done():
+		t.Fatal("the connection of the dead switch is not closed")
+	}
+	controller.Close()
+
+	// The replied one and two missed ones.
+	if n := <-echoes; n != 3 {
+		t.Fatalf("unexpected number of echo requests: expected=3, got=%v", n)
+	}
+}
@@ -0,0 +1,217 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of10"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+// DefaultMultipartTimeout is the default time to wait for the remaining parts of a multipart reply.
+const DefaultMultipartTimeout = 30 * time.Second
+
+// multipart reassembles the statistics replies that span multiple messages with the REPLY_MORE flag so that
+// the handlers receive a single logical reply. It is only used by the dispatcher goroutine.
+type multipart struct {
+	timeout time.Duration
+	// Key is the transaction ID of the request.
+	pending map[uint32]*partialReply
+}
+
+type partialReply struct {
+	// Multipart (or stats) type of the reply.
+	typ      uint16
+	deadline time.Time
+	flows    []openflow.FlowStat
+	ports    []openflow.PortStat
+	tables   []openflow.TableStat
+	descs    []openflow.Port
+}
+
+func newMultipart(timeout time.Duration) *multipart {
+	return &multipart{
+		timeout: timeout,
+		pending: make(map[uint32]*partialReply),
+	}
+}
+
+// isMultipartMore returns whether the packet is a multipart (or stats) reply that has the REPLY_MORE flag.
+func isMultipartMore(packet []byte) bool {
+	if len(packet) < 12 {
+		return false
+	}
+
+	// The flags follow the type of the reply on both versions.
+	flags := binary.BigEndian.Uint16(packet[10:12])
+	switch packet[0] {
+	case openflow.OF10_VERSION:
+		return packet[1] == of10.OFPT_STATS_REPLY && flags&of10.OFPSF_REPLY_MORE != 0
+	case openflow.OF13_VERSION:
+		return packet[1] == of13.OFPT_MULTIPART_REPLY && flags&of13.OFPMPF_REPLY_MORE != 0
+	default:
+		return false
+	}
+}
+
+// add returns the parts accumulated so far for the reply whose transaction ID is xid, and whether the packet
+// is its last part. The reply is forgotten after the last part. A reply that has not been completed in the
+// timeout is discarded.
+func (r *multipart) add(packet []byte, xid uint32, now time.Time) (p *partialReply, last bool) {
+	r.expire(now)
+
+	typ := binary.BigEndian.Uint16(packet[8:10])
+	p, ok := r.pending[xid]
+	if !ok || p.typ != typ {
+		p = &partialReply{typ: typ}
+	}
+	if !isMultipartMore(packet) {
+		delete(r.pending, xid)
+		return p, true
+	}
+	p.deadline = now.Add(r.timeout)
+	r.pending[xid] = p
+
+	return p, false
+}
+
+func (r *multipart) expire(now time.Time) {
+	for xid, p := range r.pending {
+		if now.Before(p.deadline) {
+			continue
+		}
+		logger.Warningf("discarding the incomplete multipart reply: xid=%v, type=%v", xid, p.typ)
+		delete(r.pending, xid)
+	}
+}
+
+// flowStats returns the FLOW_STATS reply that has all the parts accumulated, or false if more parts are expected.
+func (r *multipart) flowStats(packet []byte, v openflow.FlowStatsReply, now time.Time) (openflow.FlowStatsReply, bool) {
+	p, last := r.add(packet, v.TransactionID(), now)
+	if last && p.flows == nil {
+		// Nothing to merge with, e.g., a single part reply.
+		return v, true
+	}
+	p.flows = append(p.flows, v.Stats()...)
+	if !last {
+		return nil, false
+	}
+
+	return flowStatsReply{FlowStatsReply: v, stats: p.flows}, true
+}
+
+// portStats returns the PORT_STATS reply that has all the parts accumulated, or false if more parts are expected.
+func (r *multipart) portStats(packet []byte, v openflow.PortStatsReply, now time.Time) (openflow.PortStatsReply, bool) {
+	p, last := r.add(packet, v.TransactionID(), now)
+	if last && p.ports == nil {
+		return v, true
+	}
+	p.ports = append(p.ports, v.Stats()...)
+	if !last {
+		return nil, false
+	}
+
+	return portStatsReply{PortStatsReply: v, stats: p.ports}, true
+}
+
+// tableStats returns the TABLE_STATS reply that has all the parts accumulated, or false if more parts are expected.
+func (r *multipart) tableStats(packet []byte, v openflow.TableStatsReply, now time.Time) (openflow.TableStatsReply, bool) {
+	p, last := r.add(packet, v.TransactionID(), now)
+	if last && p.tables == nil {
+		return v, true
+	}
+	p.tables = append(p.tables, v.Stats()...)
+	if !last {
+		return nil, false
+	}
+
+	return tableStatsReply{TableStatsReply: v, stats: p.tables}, true
+}
+
+// portDesc returns the PORT_DESC reply that has all the parts accumulated, or false if more parts are expected.
+func (r *multipart) portDesc(packet []byte, v openflow.PortDescReply, now time.Time) (openflow.PortDescReply, bool) {
+	p, last := r.add(packet, v.TransactionID(), now)
+	if last && p.descs == nil {
+		return v, true
+	}
+	p.descs = append(p.descs, v.Ports()...)
+	if !last {
+		return nil, false
+	}
+
+	return portDescReply{PortDescReply: v, ports: p.descs}, true
+}
+
+// flowStatsReply is the last part of a FLOW_STATS reply that carries the statistics of all the parts.
+type flowStatsReply struct {
+	openflow.FlowStatsReply
+	stats []openflow.FlowStat
+}
+
+func (r flowStatsReply) More() bool {
+	return false
+}
+
+func (r flowStatsReply) Stats() []openflow.FlowStat {
+	return r.stats
+}
+
+// portStatsReply is the last part of a PORT_STATS reply that carries the statistics of all the parts.
+type portStatsReply struct {
+	openflow.PortStatsReply
+	stats []openflow.PortStat
+}
+
+func (r portStatsReply) More() bool {
+	return false
+}
+
+func (r portStatsReply) Stats() []openflow.PortStat {
+	return r.stats
+}
+
+// tableStatsReply is the last part of a TABLE_STATS reply that carries the statistics of all the parts.
+type tableStatsReply struct {
+	openflow.TableStatsReply
+	stats []openflow.TableStat
+}
+
+func (r tableStatsReply) More() bool {
+	return false
+}
+
+func (r tableStatsReply) Stats() []openflow.TableStat {
+	return r.stats
+}
+
+// portDescReply is the last part of a PORT_DESC reply that carries the ports of all the parts.
+type portDescReply struct {
+	openflow.PortDescReply
+	ports []openflow.Port
+}
+
+func (r portDescReply) Ports() []openflow.Port {
+	return r.ports
+}
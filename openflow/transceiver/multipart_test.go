@@ -0,0 +1,113 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+// flowStatsHandler records the cookies of the flows in the FLOW_STATS replies.
+type flowStatsHandler struct {
+	nopHandler
+	replies [][]uint64
+}
+
+func (r *flowStatsHandler) OnFlowStatsReply(f openflow.Factory, w Writer, v openflow.FlowStatsReply) error {
+	if v.More() {
+		panic("reassembled reply has the more flag")
+	}
+	cookies := make([]uint64, 0)
+	for _, s := range v.Stats() {
+		cookies = append(cookies, s.Cookie)
+	}
+	r.replies = append(r.replies, cookies)
+
+	return nil
+}
+
+func newFlowStatsPart(xid uint32, more bool, cookies ...uint64) []byte {
+	body := []byte{0x00, of13.OFPMP_FLOW, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	if more {
+		body[3] = of13.OFPMPF_REPLY_MORE
+	}
+	for _, c := range cookies {
+		entry := make([]byte, 56)
+		binary.BigEndian.PutUint16(entry[0:2], 56)
+		binary.BigEndian.PutUint64(entry[24:32], c)
+		// Empty OXM match.
+		copy(entry[48:], []byte{0x00, 0x01, 0x00, 0x04})
+		body = append(body, entry...)
+	}
+
+	return newTestPacket(of13.OFPT_MULTIPART_REPLY, xid, body...)
+}
+
+func TestMultipartReassembly(t *testing.T) {
+	controller, device := net.Pipe()
+	defer controller.Close()
+	defer device.Close()
+
+	c := clock.NewFake(time.Now())
+	stream := NewStream(controller, 0xFFFF)
+	stream.SetClock(c)
+	handler := &flowStatsHandler{}
+	trans := NewTransceiver(stream, handler)
+	trans.version = openflow.OF13_VERSION
+	trans.factory = of13.NewFactory()
+
+	src := []struct {
+		Packet  []byte
+		Advance time.Duration
+		Replies [][]uint64
+	}{
+		// The first of the three parts.
+		{Packet: newFlowStatsPart(1, true, 1, 2)},
+		// Unrelated reply between the parts.
+		{Packet: newFlowStatsPart(2, false, 9), Replies: [][]uint64{{9}}},
+		{Packet: newFlowStatsPart(1, true, 3), Replies: [][]uint64{{9}}},
+		{Packet: newFlowStatsPart(1, false, 4), Replies: [][]uint64{{9}, {1, 2, 3, 4}}},
+		// Incomplete reply that is discarded by the timeout.
+		{Packet: newFlowStatsPart(3, true, 5), Replies: [][]uint64{{9}, {1, 2, 3, 4}}},
+		{Packet: newFlowStatsPart(3, false, 6), Advance: DefaultMultipartTimeout, Replies: [][]uint64{{9}, {1, 2, 3, 4}, {6}}},
+	}
+
+	for i, v := range src {
+		c.Advance(v.Advance)
+		if err := trans.dispatch(v.Packet); err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(handler.replies, v.Replies) {
+			t.Fatalf("#%v: unexpected replies: expected=%v, got=%v", i, v.Replies, handler.replies)
+		}
+	}
+	if len(trans.multipart.pending) != 0 {
+		t.Fatalf("unexpected pending replies: %v", len(trans.multipart.pending))
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Default number of the outbound packets that can wait to be written to a switch.
+	DefaultWriteQueueSize = 1024
+)
+
+var (
+	// ErrWriteQueueFull is returned when the switch cannot keep up with the outbound messages.
+	ErrWriteQueueFull = errors.New("write queue is full")
+	errClosedQueue    = errors.New("write queue is closed")
+)
+
+// writeQueue is a bounded queue of the outbound packets that are written to the stream by a dedicated
+// goroutine, so that a slow switch does not block the callers of Write indefinitely.
+type writeQueue struct {
+	stream  *Stream
+	packets chan []byte
+	quit    chan struct{}
+	once    sync.Once
+
+	mutex sync.Mutex
+	// Error that has stopped the writer goroutine.
+	err error
+}
+
+func newWriteQueue(stream *Stream, size int) *writeQueue {
+	if size <= 0 {
+		size = DefaultWriteQueueSize
+	}
+
+	return &writeQueue{
+		stream:  stream,
+		packets: make(chan []byte, size),
+		quit:    make(chan struct{}),
+	}
+}
+
+// push appends the packet to the queue without blocking. It returns ErrWriteQueueFull if the queue is full.
+func (r *writeQueue) push(packet []byte) error {
+	r.mutex.Lock()
+	err := r.err
+	r.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-r.quit:
+		return errClosedQueue
+	default:
+	}
+
+	select {
+	case r.packets <- packet:
+		return nil
+	default:
+		writeErrors.Inc()
+		return ErrWriteQueueFull
+	}
+}
+
+// depth returns the number of the packets waiting to be written.
+func (r *writeQueue) depth() int {
+	return len(r.packets)
+}
+
+// run writes the queued packets to the stream until the queue is closed or a write fails. The stream is
+// closed on a write failure so that the reader also notices the broken connection.
+func (r *writeQueue) run() {
+	for {
+		select {
+		case <-r.quit:
+			return
+		case packet := <-r.packets:
+			if _, err := r.stream.Write(packet); err != nil {
+				writeErrors.Inc()
+				logger.Errorf("failed to write a packet: %v", err)
+				r.mutex.Lock()
+				r.err = errors.Wrap(err, "writing the queued packet")
+				r.mutex.Unlock()
+				r.stream.Close()
+				return
+			}
+		}
+	}
+}
+
+func (r *writeQueue) close() {
+	r.once.Do(func() { close(r.quit) })
+}
@@ -0,0 +1,85 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+// throttledConn is a net.Conn whose writes are blocked until release is closed.
+type throttledConn struct {
+	net.Conn
+	release chan struct{}
+}
+
+func (r *throttledConn) Write(p []byte) (int, error) {
+	<-r.release
+	return r.Conn.Write(p)
+}
+
+func TestWriteQueueFull(t *testing.T) {
+	controller, device := net.Pipe()
+	defer device.Close()
+	conn := &throttledConn{Conn: controller, release: make(chan struct{})}
+	trans := NewTransceiver(NewStream(conn, 0xFFFF), nopHandler{})
+	defer trans.Close()
+
+	// The writer goroutine may take the first message and be blocked by the connection, and then the
+	// following messages fill the queue.
+	var err error
+	sent := 0
+	for ; sent < DefaultWriteQueueSize*2; sent++ {
+		if err = trans.Write(of13.NewBarrierRequest(uint32(sent))); err != nil {
+			break
+		}
+	}
+	if err != ErrWriteQueueFull {
+		t.Fatalf("expected ErrWriteQueueFull: %v", err)
+	}
+	if sent < DefaultWriteQueueSize || sent > DefaultWriteQueueSize+1 {
+		t.Fatalf("unexpected number of the queued messages: %v", sent)
+	}
+	if trans.QueueDepth() != DefaultWriteQueueSize {
+		t.Fatalf("unexpected queue depth: expected=%v, got=%v", DefaultWriteQueueSize, trans.QueueDepth())
+	}
+
+	// The switch catches up with the queued messages.
+	close(conn.release)
+	if _, err := io.ReadFull(device, make([]byte, 8*sent)); err != nil {
+		t.Fatalf("failed to read the queued messages: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for trans.QueueDepth() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue is not drained: depth=%v", trans.QueueDepth())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := trans.Write(of13.NewBarrierRequest(0)); err != nil {
+		t.Fatalf("unexpected error after draining the queue: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"bytes"
+	"encoding"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+)
+
+type roleHandler struct {
+	nopHandler
+	roles []openflow.ControllerRole
+}
+
+func (r *roleHandler) OnRoleReply(f openflow.Factory, w Writer, v openflow.RoleReply) error {
+	r.roles = append(r.roles, v.Role())
+	return nil
+}
+
+func newRoleReply(role openflow.ControllerRole, generationID uint64) []byte {
+	body := make([]byte, 16)
+	body[3] = byte(role)
+	body[15] = byte(generationID)
+
+	return newTestPacket(of13.OFPT_ROLE_REPLY, 1, body...)
+}
+
+func TestSendRoleRequest(t *testing.T) {
+	controller, device := net.Pipe()
+	defer device.Close()
+	defer controller.Close()
+	trans := NewTransceiver(NewStream(controller, 0xFFFF), nopHandler{})
+	if err := trans.SendRoleRequest(openflow.RoleMaster, 1); err == nil {
+		t.Fatal("expected an error before the version negotiation")
+	}
+	trans.version = openflow.OF13_VERSION
+	trans.factory = of13.NewFactory()
+
+	if err := trans.SendRoleRequest(openflow.RoleMaster, 0x0102030405060708); err != nil {
+		t.Fatalf("failed to send the role request: %v", err)
+	}
+	packet := make([]byte, 24)
+	if _, err := io.ReadFull(device, packet); err != nil {
+		t.Fatalf("failed to read the role request: %v", err)
+	}
+	expected := []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	if packet[0] != openflow.OF13_VERSION || packet[1] != of13.OFPT_ROLE_REQUEST || !bytes.Equal(packet[8:], expected) {
+		t.Fatalf("unexpected role request: %x", packet)
+	}
+}
+
+func TestSlaveRoleGuard(t *testing.T) {
+	controller, device := net.Pipe()
+	defer device.Close()
+	defer controller.Close()
+	// Discard the messages written to the switch.
+	go io.Copy(ioutil.Discard, device)
+
+	handler := new(roleHandler)
+	trans := NewTransceiver(NewStream(controller, 0xFFFF), handler)
+	trans.version = openflow.OF13_VERSION
+	trans.factory = of13.NewFactory()
+	if role := trans.Role(); role != openflow.RoleEqual {
+		t.Fatalf("unexpected initial role: %v", role)
+	}
+
+	f := trans.factory
+	flowMod, _ := f.NewFlowMod(openflow.FlowAdd)
+	packetOut, _ := f.NewPacketOut()
+	groupMod, _ := f.NewGroupMod(openflow.GroupAdd)
+	portMod, _ := f.NewPortMod()
+	tableMod, _ := f.NewTableMod()
+	barrier, _ := f.NewBarrierRequest()
+	stats, _ := f.NewFlowStatsRequest()
+	role, _ := f.NewRoleRequest()
+
+	src := []struct {
+		msg     encoding.BinaryMarshaler
+		blocked bool
+	}{
+		{flowMod, true},
+		{packetOut, true},
+		{groupMod, true},
+		{portMod, true},
+		{tableMod, true},
+		// A slave can still read the switch and change its role.
+		{barrier, false},
+		{stats, false},
+		{role, false},
+	}
+
+	if err := trans.dispatch(newRoleReply(openflow.RoleSlave, 1)); err != nil {
+		t.Fatalf("failed to handle the role reply: %v", err)
+	}
+	if r := trans.Role(); r != openflow.RoleSlave {
+		t.Fatalf("unexpected role after the reply: %v", r)
+	}
+	for i, v := range src {
+		if err := trans.Write(v.msg); (err == ErrSlaveRole) != v.blocked {
+			t.Fatalf("#%v: unexpected result of Write: blocked=%v, err=%v", i, v.blocked, err)
+		}
+		if err := trans.WriteBatch([]encoding.BinaryMarshaler{barrier, v.msg}); (err == ErrSlaveRole) != v.blocked {
+			t.Fatalf("#%v: unexpected result of WriteBatch: blocked=%v, err=%v", i, v.blocked, err)
+		}
+	}
+
+	// Writes are allowed again after the controller becomes the master.
+	if err := trans.dispatch(newRoleReply(openflow.RoleMaster, 2)); err != nil {
+		t.Fatalf("failed to handle the role reply: %v", err)
+	}
+	if err := trans.Write(tableMod); err != nil {
+		t.Fatalf("unexpected error after the role change: %v", err)
+	}
+	if len(handler.roles) != 2 || handler.roles[0] != openflow.RoleSlave || handler.roles[1] != openflow.RoleMaster {
+		t.Fatalf("unexpected roles delivered to the handler: %v", handler.roles)
+	}
+}
@@ -27,12 +27,15 @@ import (
 	"net"
 	"sync"
 	"time"
+
+	"github.com/superkkt/cherry/clock"
 )
 
 // Stream is a buffered I/O channel.
 type Stream struct {
 	// Underlying socket.
 	channel io.ReadWriteCloser
+	clock   clock.Clock
 
 	reader struct {
 		mutex sync.Mutex
@@ -66,12 +69,28 @@ type deadline interface {
 func NewStream(channel io.ReadWriteCloser, bufSize int) *Stream {
 	c := new(Stream)
 	c.channel = channel
+	c.clock = clock.New()
 	c.reader.rd = bufio.NewReaderSize(channel, bufSize)
 	c.writer.wr = channel
 
 	return c
 }
 
+// SetClock replaces the clock of this stream, which is used for the timestamps of the last read and write, with
+// c. The I/O deadlines of the socket always use the real time because the socket is not aware of the clock.
+func (r *Stream) SetClock(c clock.Clock) {
+	if c == nil {
+		panic("nil clock")
+	}
+
+	r.reader.mutex.Lock()
+	defer r.reader.mutex.Unlock()
+	r.writer.mutex.Lock()
+	defer r.writer.mutex.Unlock()
+
+	r.clock = c
+}
+
 type dummyAddr struct{}
 
 func (r dummyAddr) Network() string {
@@ -137,7 +156,7 @@ func (r *Stream) Read(p []byte) (n int, err error) {
 	if err != nil {
 		return n, err
 	}
-	r.reader.timestamp = time.Now()
+	r.reader.timestamp = r.clock.Now()
 
 	return n, nil
 }
@@ -152,6 +171,7 @@ func (r *Stream) setReadDeadline() {
 	}
 
 	if r.reader.timeout > 0 {
+		// The socket compares its deadline with the real time, not with the clock of this stream.
 		d.SetReadDeadline(time.Now().Add(r.reader.timeout))
 	} else {
 		d.SetReadDeadline(time.Time{})
@@ -202,7 +222,7 @@ func (r *Stream) ReadN(n int) (p []byte, err error) {
 	if c != n {
 		panic("insufficient read")
 	}
-	r.reader.timestamp = time.Now()
+	r.reader.timestamp = r.clock.Now()
 
 	return p, nil
 }
@@ -215,17 +235,29 @@ func (r *Stream) LastRead() time.Time {
 	return r.reader.timestamp
 }
 
-// Write is a wrapper function of net.Conn.Write().
+// Write is a wrapper function of net.Conn.Write(). A partial write that times out after
+// making some progress is retried with the remaining bytes, because abandoning it would
+// leave a truncated OpenFlow message on the connection.
 func (r *Stream) Write(p []byte) (n int, err error) {
 	r.writer.mutex.Lock()
 	defer r.writer.mutex.Unlock()
 
-	r.setWriteDeadline()
-	n, err = r.writer.wr.Write(p)
-	if err != nil {
-		return n, err
+	for n < len(p) {
+		r.setWriteDeadline()
+		written, err := r.writer.wr.Write(p[n:])
+		n += written
+		if err != nil {
+			if written > 0 && isTimeout(err) {
+				logger.Debugf("retrying a partial write: written=%v, remain=%v", n, len(p)-n)
+				continue
+			}
+			return n, err
+		}
+		if written == 0 {
+			return n, io.ErrShortWrite
+		}
 	}
-	r.writer.timestamp = time.Now()
+	r.writer.timestamp = r.clock.Now()
 
 	return n, nil
 }
@@ -240,6 +272,7 @@ func (r *Stream) setWriteDeadline() {
 	}
 
 	if r.writer.timeout > 0 {
+		// The socket compares its deadline with the real time, not with the clock of this stream.
 		d.SetWriteDeadline(time.Now().Add(r.writer.timeout))
 	} else {
 		d.SetWriteDeadline(time.Time{})
@@ -0,0 +1,134 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+)
+
+type timeoutError struct{}
+
+func (r timeoutError) Error() string { return "i/o timeout" }
+func (r timeoutError) Timeout() bool { return true }
+
+// partialConn accepts at most chunk bytes per write and returns err for every partial write.
+type partialConn struct {
+	bytes.Buffer
+	chunk  int
+	err    error
+	writes int
+}
+
+func (r *partialConn) Write(p []byte) (int, error) {
+	r.writes++
+	if len(p) <= r.chunk {
+		return r.Buffer.Write(p)
+	}
+	n, _ := r.Buffer.Write(p[:r.chunk])
+	return n, r.err
+}
+
+func (r *partialConn) Close() error {
+	return nil
+}
+
+func TestStreamPartialWrite(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	src := []struct {
+		Chunk    int
+		Err      error
+		Written  int
+		Writes   int
+		Expected bool
+	}{
+		// Written at once.
+		{Chunk: len(data), Err: timeoutError{}, Written: len(data), Writes: 1, Expected: true},
+		// Partial writes that time out are retried with the remaining bytes.
+		{Chunk: 3, Err: timeoutError{}, Written: len(data), Writes: 7, Expected: true},
+		// The other errors are not retried.
+		{Chunk: 3, Err: errors.New("broken pipe"), Written: 3, Writes: 1, Expected: false},
+		// A timeout without any progress is not retried.
+		{Chunk: 0, Err: timeoutError{}, Written: 0, Writes: 1, Expected: false},
+	}
+
+	for i, v := range src {
+		conn := &partialConn{chunk: v.Chunk, err: v.Err}
+		stream := NewStream(conn, 0xFFFF)
+		n, err := stream.Write(data)
+		if (err == nil) != v.Expected {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if n != v.Written || conn.writes != v.Writes {
+			t.Fatalf("#%v: unexpected write: expected=%v bytes in %v writes, got=%v bytes in %v writes", i, v.Written, v.Writes, n, conn.writes)
+		}
+		if !bytes.Equal(conn.Bytes(), data[:n]) {
+			t.Fatalf("#%v: unexpected written data: %q", i, conn.Bytes())
+		}
+	}
+}
+
+// deadlineConn records the deadlines set on the socket.
+type deadlineConn struct {
+	bytes.Buffer
+	read, write time.Time
+}
+
+func (r *deadlineConn) Close() error {
+	return nil
+}
+
+func (r *deadlineConn) SetReadDeadline(t time.Time) error {
+	r.read = t
+	return nil
+}
+
+func (r *deadlineConn) SetWriteDeadline(t time.Time) error {
+	r.write = t
+	return nil
+}
+
+func TestStreamDeadlineUsesRealTime(t *testing.T) {
+	conn := &deadlineConn{}
+	stream := NewStream(conn, 0xFFFF)
+	// The fake clock is far behind the real time, so the deadlines based on it would have already passed.
+	stream.SetClock(clock.NewFake(time.Now().Add(-time.Hour)))
+	stream.SetReadTimeout(time.Minute)
+	stream.SetWriteTimeout(time.Minute)
+
+	start := time.Now()
+	if _, err := stream.Write([]byte("data")); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if _, err := stream.Read(make([]byte, 4)); err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	for _, v := range []time.Time{conn.read, conn.write} {
+		if v.Before(start.Add(time.Minute)) || v.After(time.Now().Add(time.Minute)) {
+			t.Fatalf("unexpected deadline: %v", v)
+		}
+	}
+}
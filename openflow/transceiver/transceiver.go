@@ -22,12 +22,16 @@
 package transceiver
 
 import (
+	"bytes"
 	"context"
 	"encoding"
 	"encoding/binary"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/superkkt/cherry/metrics"
 	"github.com/superkkt/cherry/openflow"
 	"github.com/superkkt/cherry/openflow/of10"
 	"github.com/superkkt/cherry/openflow/of13"
@@ -38,32 +42,79 @@ import (
 
 var (
 	logger = logging.MustGetLogger("transceiver")
+
+	readErrors  = metrics.NewCounter("cherry_transceiver_read_errors_total", "Number of the connections closed by an error while reading a packet from the switch.")
+	writeErrors = metrics.NewCounter("cherry_transceiver_write_errors_total", "Number of the packets that could not be written to the switch, including the ones dropped by a full write queue.")
+)
+
+var (
+	// ErrMessageTooLarge is returned when the switch sends a message that is larger than the maximum message size.
+	ErrMessageTooLarge = errors.New("OpenFlow message exceeds the maximum message size")
+	// ErrSlaveRole is returned when a message that modifies the switch is written while the controller is a slave.
+	ErrSlaveRole = errors.New("controller is a slave of the switch")
 )
 
 const (
-	// Allowed idle time before we send an echo request to a switch.
-	maxIdleTime = 10 * time.Second
-	// I/O timeouts (These timeouts should be less than maxIdleTime).
-	readTimeout  = 1 * time.Second
-	writeTimeout = readTimeout * 2
+	// MinMessageSize is the size of the OpenFlow header, which is the smallest message.
+	MinMessageSize = 8
+	// MaxMessageSize is the largest size that the 16-bit length field of the OpenFlow header can represent.
+	MaxMessageSize = 0xFFFF
+	// Default idle time before we send an echo request to a switch, and between the echo requests
+	// while the switch is idle.
+	DefaultEchoInterval = 10 * time.Second
+	// Default number of the consecutive echo replies that a switch can miss before we close the connection.
+	DefaultEchoMaxMisses = 3
+	// Default I/O timeouts of the socket (These timeouts should be less than the echo interval).
+	DefaultReadTimeout  = 1 * time.Second
+	DefaultWriteTimeout = DefaultReadTimeout * 2
+	// Default time to wait for the reply of a request, such as a barrier
+	// request confirming that flows have been installed. This is independent
+	// of the socket I/O timeouts because slow switches may take much longer
+	// to process a request while the control channel is still alive.
+	DefaultConfirmTimeout = 10 * time.Second
 )
 
 type Writer interface {
 	Write(msg encoding.BinaryMarshaler) error
 }
 
+// BatchWriter is a Writer that can coalesce several messages into a single
+// write on the underlying stream.
+type BatchWriter interface {
+	Writer
+	WriteBatch(msgs []encoding.BinaryMarshaler) error
+}
+
 type WriteCloser interface {
 	Writer
 	Close() error
 }
 
 type Transceiver struct {
-	stream      *Stream
-	observer    Handler
-	version     uint8
-	factory     openflow.Factory
-	pingCounter uint
-	closed      bool
+	stream    *Stream
+	observer  Handler
+	version   uint8
+	factory   openflow.Factory
+	closeOnce sync.Once
+	closeErr  error
+	// Liveness of the switch that is probed by the echo requests.
+	echoInterval  time.Duration
+	echoMaxMisses int
+	liveness      *liveness
+	// I/O timeouts of the socket.
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	// Time to wait for the reply of a request.
+	confirmTimeout time.Duration
+	confirmer      *confirmer
+	// Outbound packets that are waiting to be written to the switch.
+	queue *writeQueue
+	// Largest message that we accept from the switch.
+	maxMessageSize int
+	// Statistics replies that are waiting for their remaining parts.
+	multipart *multipart
+	// Role of the controller confirmed by the switch, which is openflow.ControllerRole.
+	role uint32
 }
 
 type Handler interface {
@@ -72,11 +123,16 @@ type Handler interface {
 	OnFeaturesReply(openflow.Factory, Writer, openflow.FeaturesReply) error
 	OnGetConfigReply(openflow.Factory, Writer, openflow.GetConfigReply) error
 	OnDescReply(openflow.Factory, Writer, openflow.DescReply) error
+	OnFlowStatsReply(openflow.Factory, Writer, openflow.FlowStatsReply) error
+	OnPortStatsReply(openflow.Factory, Writer, openflow.PortStatsReply) error
+	OnTableStatsReply(openflow.Factory, Writer, openflow.TableStatsReply) error
+	OnQueueGetConfigReply(openflow.Factory, Writer, openflow.QueueGetConfigReply) error
 	OnPortDescReply(openflow.Factory, Writer, openflow.PortDescReply) error
 	OnPortStatus(openflow.Factory, Writer, openflow.PortStatus) error
 	OnFlowRemoved(openflow.Factory, Writer, openflow.FlowRemoved) error
 	OnPacketIn(openflow.Factory, Writer, openflow.PacketIn) error
 	OnBarrierReply(openflow.Factory, Writer, openflow.BarrierReply) error
+	OnRoleReply(openflow.Factory, Writer, openflow.RoleReply) error
 }
 
 func NewTransceiver(stream *Stream, handler Handler) *Transceiver {
@@ -87,10 +143,113 @@ func NewTransceiver(stream *Stream, handler Handler) *Transceiver {
 		panic("handler is nil")
 	}
 
-	return &Transceiver{
-		stream:   stream,
-		observer: handler,
+	v := &Transceiver{
+		stream:         stream,
+		observer:       handler,
+		echoInterval:   DefaultEchoInterval,
+		echoMaxMisses:  DefaultEchoMaxMisses,
+		readTimeout:    DefaultReadTimeout,
+		writeTimeout:   DefaultWriteTimeout,
+		confirmTimeout: DefaultConfirmTimeout,
+		confirmer:      newConfirmer(),
+		queue:          newWriteQueue(stream, DefaultWriteQueueSize),
+		maxMessageSize: MaxMessageSize,
+		multipart:      newMultipart(DefaultMultipartTimeout),
+		// The switch regards all the controllers as equal until they request their roles.
+		role: uint32(openflow.RoleEqual),
+	}
+	go v.queue.run()
+
+	return v
+}
+
+// QueueDepth returns the number of the outbound messages that are waiting to be written to the switch.
+func (r *Transceiver) QueueDepth() int {
+	return r.queue.depth()
+}
+
+// SetConfirmTimeout sets the time to wait for the reply of a request. The
+// default timeout is used if d is not positive.
+func (r *Transceiver) SetConfirmTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultConfirmTimeout
+	}
+	r.confirmTimeout = d
+}
+
+func (r *Transceiver) ConfirmTimeout() time.Duration {
+	return r.confirmTimeout
+}
+
+// SetReadTimeout sets the read timeout of the socket, which is also the interval
+// at which the liveness of an idle switch is checked. The default timeout is
+// used if d is not positive. It should be called before Run.
+func (r *Transceiver) SetReadTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultReadTimeout
+	}
+	r.readTimeout = d
+}
+
+func (r *Transceiver) ReadTimeout() time.Duration {
+	return r.readTimeout
+}
+
+// SetWriteTimeout sets the write timeout of the socket. The default timeout is
+// used if d is not positive. It should be called before Run.
+func (r *Transceiver) SetWriteTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultWriteTimeout
+	}
+	r.writeTimeout = d
+}
+
+func (r *Transceiver) WriteTimeout() time.Duration {
+	return r.writeTimeout
+}
+
+// SetEchoInterval sets the idle time before an echo request is sent to the
+// switch, which is also the interval between the echo requests while the
+// switch is idle. The default interval is used if d is not positive. It should
+// be called before Run.
+func (r *Transceiver) SetEchoInterval(d time.Duration) {
+	if d <= 0 {
+		d = DefaultEchoInterval
+	}
+	r.echoInterval = d
+}
+
+// SetEchoMaxMisses sets the number of the consecutive echo replies that the
+// switch can miss before the connection is closed. The default number is used
+// if n is not positive. It should be called before Run.
+func (r *Transceiver) SetEchoMaxMisses(n int) {
+	if n <= 0 {
+		n = DefaultEchoMaxMisses
+	}
+	r.echoMaxMisses = n
+}
+
+// SetMaxMessageSize sets the size of the largest message that we accept from the switch. The connection is closed
+// if the switch sends a larger one. MaxMessageSize is used if n is not positive, and n is clamped between
+// MinMessageSize and MaxMessageSize otherwise. It should be called before Run.
+func (r *Transceiver) SetMaxMessageSize(n int) {
+	switch {
+	case n <= 0:
+		n = MaxMessageSize
+	case n < MinMessageSize:
+		n = MinMessageSize
+	case n > MaxMessageSize:
+		n = MaxMessageSize
 	}
+	r.maxMessageSize = n
+}
+
+// ConfirmContext returns a context for a single request/reply operation that
+// is canceled when the confirm timeout elapses or ctx is done. The socket read
+// timeout is not affected.
+func (r *Transceiver) ConfirmContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	// The context expires in the real time regardless of the clock of the stream.
+	return context.WithTimeout(ctx, r.confirmTimeout)
 }
 
 func (r *Transceiver) Version() (negotiated bool, version uint8) {
@@ -115,16 +274,12 @@ func isTimeout(err error) bool {
 }
 
 func (r *Transceiver) sendEchoRequest() error {
-	if r.pingCounter > 2 {
-		return errors.New("device does not respond to our echo request")
-	}
-
 	echo, err := r.factory.NewEchoRequest()
 	if err != nil {
 		return err
 	}
 	// We use current timestamp to check network latency between our controller and a switch.
-	timestamp, err := time.Now().GobEncode()
+	timestamp, err := r.stream.clock.Now().GobEncode()
 	if err != nil {
 		return err
 	}
@@ -133,15 +288,14 @@ func (r *Transceiver) sendEchoRequest() error {
 	if err := r.Write(echo); err != nil {
 		return errors.Wrap(err, "failed to send ECHO_REQUEST message")
 	}
-	r.pingCounter++
 
 	return nil
 }
 
 func (r *Transceiver) Run(ctx context.Context) error {
 	defer logger.Info("transceiver is closed")
-	r.stream.SetReadTimeout(readTimeout)
-	r.stream.SetWriteTimeout(writeTimeout)
+	r.stream.SetReadTimeout(r.readTimeout)
+	r.stream.SetWriteTimeout(r.writeTimeout)
 
 	readerCtx, cancelReader := context.WithCancel(ctx)
 	defer cancelReader()
@@ -199,14 +353,25 @@ func (r *Transceiver) negotiate(ctx context.Context, reader <-chan []byte) (pack
 		}
 
 		// Version negotiation
-		if packet[0] < openflow.OF13_VERSION {
+		version, err := negotiateVersion(packet)
+		if err != nil {
+			// Write directly to the stream because we do not have the factory.
+			if _, err := r.stream.Write(newHelloFailed(packet)); err != nil {
+				logger.Errorf("failed to send HELLO_FAILED error: %v", err)
+			}
+			return nil, errors.Wrap(err, fmt.Sprintf("HELLO version=%v", packet[0]))
+		}
+		switch version {
+		case openflow.OF10_VERSION:
 			r.version = openflow.OF10_VERSION
 			r.factory = of10.NewFactory()
 			logger.Info("negotiated to openflow version 1.0")
-		} else {
+		case openflow.OF13_VERSION:
 			r.version = openflow.OF13_VERSION
 			r.factory = of13.NewFactory()
 			logger.Info("negotiated to openflow version 1.3")
+		default:
+			panic(fmt.Sprintf("unexpected negotiated version: %v", version))
 		}
 
 		// Return the initial packet to dispatch it.
@@ -221,8 +386,10 @@ func (r *Transceiver) runReader(ctx context.Context) <-chan []byte {
 		// The channel c will be closed when this goroutine returns in order to notice the connection has been closed.
 		defer close(c)
 		defer logger.Info("transceiver reader is closed")
+		// Nobody will receive the replies for the requests waiting for the confirmation.
+		defer r.confirmer.closeAll(errors.New("transceiver reader is closed"))
 
-		lastActivated := time.Now()
+		r.liveness = newLiveness(r.echoInterval, r.echoMaxMisses, r.stream.clock.Now())
 		for {
 			select {
 			case <-ctx.Done():
@@ -235,20 +402,19 @@ func (r *Transceiver) runReader(ctx context.Context) <-chan []byte {
 			packet, err := r.readPacket()
 			if err != nil {
 				if !isTimeout(err) {
+					readErrors.Inc()
 					logger.Errorf("failed to read the next packet: %v", err)
 					return
 				}
 				// Timeout occurrs. Send a ping request if necessary.
-				if time.Now().After(lastActivated.Add(maxIdleTime)) {
-					if err := r.sendEchoRequest(); err != nil {
-						logger.Errorf("failed to send an echo request: %v", err)
-						return
-					}
+				if err := r.keepalive(); err != nil {
+					logger.Errorf("closing the idle connection: %v", err)
+					return
 				}
 				continue
 			}
 			// Update the timestamp
-			lastActivated = time.Now()
+			r.liveness.activate(r.stream.clock.Now())
 
 			ok, err := r.handleEcho(packet)
 			if err != nil {
@@ -260,6 +426,9 @@ func (r *Transceiver) runReader(ctx context.Context) <-chan []byte {
 				// packets because this reader handles them.
 				continue
 			}
+			// Notify the requests waiting for the confirmation in this reader, instead of the
+			// dispatcher, so that the handlers can wait for the confirmation without a deadlock.
+			r.confirmer.handle(packet)
 
 			// Forward messages except the echo request and response.
 			select {
@@ -274,6 +443,25 @@ func (r *Transceiver) runReader(ctx context.Context) <-chan []byte {
 	return c
 }
 
+// keepalive sends an echo request to the switch if it has been idle for the echo interval. It returns an
+// error if the switch has missed too many echo replies. The echo request is sent only after the protocol
+// version has been negotiated.
+func (r *Transceiver) keepalive() error {
+	if negotiated, _ := r.Version(); !negotiated {
+		return nil
+	}
+
+	ok, err := r.liveness.probe(r.stream.clock.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	return r.sendEchoRequest()
+}
+
 func isTemporaryErr(err error) bool {
 	e, ok := errors.Cause(err).(interface {
 		Temporary() bool
@@ -288,9 +476,13 @@ func (r *Transceiver) readPacket() ([]byte, error) {
 	}
 
 	length := binary.BigEndian.Uint16(header[2:4])
-	if length < 8 {
+	if length < MinMessageSize {
 		return nil, openflow.ErrInvalidPacketLength
 	}
+	// We cannot skip the message without reading it, and its length may be corrupted anyway.
+	if int(length) > r.maxMessageSize {
+		return nil, errors.Wrapf(ErrMessageTooLarge, "type=%v, length=%v, max=%v", header[1], length, r.maxMessageSize)
+	}
 	packet, err := r.stream.ReadN(int(length))
 	if err != nil {
 		return nil, err
@@ -299,17 +491,168 @@ func (r *Transceiver) readPacket() ([]byte, error) {
 	return packet, nil
 }
 
+// Role returns the role of the controller that has been confirmed by the ROLE_REPLY of the switch.
+func (r *Transceiver) Role() openflow.ControllerRole {
+	return openflow.ControllerRole(atomic.LoadUint32(&r.role))
+}
+
+// SendRoleRequest sends a ROLE_REQUEST that changes the role of the controller to role, or queries the current
+// role if role is openflow.RoleNoChange. The new role takes effect when the switch replies with a ROLE_REPLY,
+// which is delivered to OnRoleReply of the handler. OpenFlow 1.3 only.
+func (r *Transceiver) SendRoleRequest(role openflow.ControllerRole, generationID uint64) error {
+	if negotiated, _ := r.Version(); !negotiated {
+		return errors.New("protocol version is not negotiated yet")
+	}
+	msg, err := r.factory.NewRoleRequest()
+	if err != nil {
+		return err
+	}
+	msg.SetRole(role)
+	msg.SetGenerationID(generationID)
+
+	return r.Write(msg)
+}
+
+// checkRole returns ErrSlaveRole if msg modifies the state of the switch or sends a packet while the controller
+// is a slave, which the switch would reject anyway.
+func (r *Transceiver) checkRole(msg encoding.BinaryMarshaler) error {
+	if r.Role() != openflow.RoleSlave {
+		return nil
+	}
+
+	switch msg.(type) {
+	case openflow.FlowMod, openflow.PacketOut, openflow.GroupMod, openflow.MeterMod, openflow.PortMod, openflow.TableMod:
+		return ErrSlaveRole
+	default:
+		return nil
+	}
+}
+
+// Write queues the message to send it to the switch. It returns ErrWriteQueueFull without blocking if the
+// switch cannot keep up with the outbound messages, and ErrSlaveRole if the controller is a slave that cannot
+// send msg.
 func (r *Transceiver) Write(msg encoding.BinaryMarshaler) error {
-	packet, err := msg.MarshalBinary()
+	if err := r.checkRole(msg); err != nil {
+		return err
+	}
+	packet, err := marshal(msg)
 	if err != nil {
 		return err
 	}
 
-	if _, err := r.stream.Write(packet); err != nil {
+	return r.queue.push(packet)
+}
+
+// WriteBatch marshals all the messages and sends them to the switch using a
+// single write operation. Nothing is sent if any of the messages is invalid.
+func (r *Transceiver) WriteBatch(msgs []encoding.BinaryMarshaler) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	buf := new(bytes.Buffer)
+	for _, msg := range msgs {
+		if err := r.checkRole(msg); err != nil {
+			return err
+		}
+		packet, err := marshal(msg)
+		if err != nil {
+			return err
+		}
+		buf.Write(packet)
+	}
+
+	return r.queue.push(buf.Bytes())
+}
+
+// Request is an OpenFlow message that can be confirmed by a barrier request.
+type Request interface {
+	openflow.Header
+	encoding.BinaryMarshaler
+}
+
+// WriteSync sends the requests followed by the barrier request using a single write operation, and then blocks
+// until the switch replies to the barrier request, which means the switch has processed all the requests. It
+// returns a *RequestError if the switch replied an ERROR to any of the requests, or an error if ctx is done
+// before the barrier reply arrives.
+func (r *Transceiver) WriteSync(ctx context.Context, barrier openflow.BarrierRequest, requests ...Request) error {
+	xids := make([]uint32, len(requests))
+	msgs := make([]encoding.BinaryMarshaler, 0, len(requests)+1)
+	for i, v := range requests {
+		xids[i] = v.TransactionID()
+		msgs = append(msgs, v)
+	}
+	msgs = append(msgs, barrier)
+
+	// Register the waiter before writing the requests not to miss the replies.
+	w := r.confirmer.add(barrier.TransactionID(), xids)
+	if err := r.WriteBatch(msgs); err != nil {
+		r.confirmer.remove(barrier.TransactionID())
 		return err
 	}
 
-	return nil
+	select {
+	case <-ctx.Done():
+		r.confirmer.remove(barrier.TransactionID())
+		return errors.Wrap(ctx.Err(), "waiting for the barrier reply")
+	case <-w.done:
+		return w.err
+	}
+}
+
+// SendBarrierRequest sends a barrier request to the switch, and then returns its transaction ID that should be
+// passed to WaitBarrier. The caller must call WaitBarrier with the returned ID; otherwise, the barrier stays
+// registered until the connection is closed.
+func (r *Transceiver) SendBarrierRequest() (xid uint32, err error) {
+	if negotiated, _ := r.Version(); !negotiated {
+		return 0, errors.New("protocol version is not negotiated yet")
+	}
+	barrier, err := r.factory.NewBarrierRequest()
+	if err != nil {
+		return 0, err
+	}
+
+	// Register the waiter before writing the request not to miss the reply.
+	r.confirmer.addBarrier(barrier.TransactionID())
+	if err := r.Write(barrier); err != nil {
+		r.confirmer.remove(barrier.TransactionID())
+		return 0, err
+	}
+
+	return barrier.TransactionID(), nil
+}
+
+// WaitBarrier blocks until the switch replies to the barrier request sent by SendBarrierRequest, which means the
+// switch has processed all the messages sent before the barrier request. It returns an error if ctx is done
+// before the barrier reply arrives, or if the connection is closed. Each transaction ID can be waited only once.
+func (r *Transceiver) WaitBarrier(ctx context.Context, xid uint32) error {
+	w, ok := r.confirmer.take(xid)
+	if !ok {
+		return fmt.Errorf("unknown barrier request: xid=%v", xid)
+	}
+
+	select {
+	case <-ctx.Done():
+		r.confirmer.remove(xid)
+		return errors.Wrap(ctx.Err(), "waiting for the barrier reply")
+	case <-w.done:
+		return w.err
+	}
+}
+
+func marshal(msg encoding.BinaryMarshaler) ([]byte, error) {
+	switch v := msg.(type) {
+	case openflow.FlowMod:
+		if err := openflow.ValidateFlowMod(v); err != nil {
+			return nil, err
+		}
+	case openflow.PacketOut:
+		if err := openflow.ValidatePacketOut(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return msg.MarshalBinary()
 }
 
 func (r *Transceiver) handleEcho(packet []byte) (ok bool, err error) {
@@ -348,7 +691,8 @@ func (r *Transceiver) handleOF13Echo(packet []byte) (handled bool, err error) {
 }
 
 func (r *Transceiver) dispatch(packet []byte) error {
-	if packet[0] != r.version {
+	// HELLO may have a version different from the negotiated one.
+	if packet[0] != r.version && packet[1] != of13.OFPT_HELLO {
 		return fmt.Errorf("mis-matched OpenFlow version: negotiated=%v, packet=%v", r.version, packet[0])
 	}
 
@@ -376,6 +720,12 @@ func (r *Transceiver) handleOF10Message(packet []byte) error {
 		switch binary.BigEndian.Uint16(packet[8:10]) {
 		case of10.OFPST_DESC:
 			return r.handleDescReply(packet)
+		case of10.OFPST_FLOW:
+			return r.handleFlowStatsReply(packet)
+		case of10.OFPST_TABLE:
+			return r.handleTableStatsReply(packet)
+		case of10.OFPST_PORT:
+			return r.handlePortStatsReply(packet)
 		default:
 			// Unsupported message. Do nothing.
 			return nil
@@ -388,6 +738,8 @@ func (r *Transceiver) handleOF10Message(packet []byte) error {
 		return r.handlePacketIn(packet)
 	case of10.OFPT_BARRIER_REPLY:
 		return r.handleBarrierReply(packet)
+	case of10.OFPT_QUEUE_GET_CONFIG_REPLY:
+		return r.handleQueueGetConfigReply(packet)
 	default:
 		// Unsupported message. Do nothing.
 		return nil
@@ -408,6 +760,12 @@ func (r *Transceiver) handleOF13Message(packet []byte) error {
 		switch binary.BigEndian.Uint16(packet[8:10]) {
 		case of13.OFPMP_DESC:
 			return r.handleDescReply(packet)
+		case of13.OFPMP_FLOW:
+			return r.handleFlowStatsReply(packet)
+		case of13.OFPMP_TABLE:
+			return r.handleTableStatsReply(packet)
+		case of13.OFPMP_PORT_STATS:
+			return r.handlePortStatsReply(packet)
 		case of13.OFPMP_PORT_DESC:
 			return r.handlePortDescReply(packet)
 		default:
@@ -422,6 +780,10 @@ func (r *Transceiver) handleOF13Message(packet []byte) error {
 		return r.handlePacketIn(packet)
 	case of13.OFPT_BARRIER_REPLY:
 		return r.handleBarrierReply(packet)
+	case of13.OFPT_QUEUE_GET_CONFIG_REPLY:
+		return r.handleQueueGetConfigReply(packet)
+	case of13.OFPT_ROLE_REPLY:
+		return r.handleRoleReply(packet)
 	default:
 		// Unsupported message. Do nothing.
 		return nil
@@ -475,12 +837,11 @@ func (r *Transceiver) handleEchoReply(packet []byte) error {
 			logger.Debug("unexpected timestamp data in the ECHO_REPLY packet")
 		} else {
 			// Network latency
-			logger.Debugf("transceiver latency: %v", time.Now().Sub(timestamp))
+			logger.Debugf("transceiver latency: %v", r.stream.clock.Since(timestamp))
 		}
 	}
 
-	// Reset the ping counter
-	r.pingCounter = 0
+	r.liveness.reply()
 
 	return nil
 }
@@ -545,6 +906,83 @@ func (r *Transceiver) handleDescReply(packet []byte) error {
 	return r.observer.OnDescReply(r.factory, r, msg)
 }
 
+func (r *Transceiver) handleFlowStatsReply(packet []byte) error {
+	msg, err := r.factory.NewFlowStatsReply()
+	if err != nil {
+		return err
+	}
+	if err := msg.UnmarshalBinary(packet); err != nil {
+		return err
+	}
+	reply, ok := r.multipart.flowStats(packet, msg, r.stream.clock.Now())
+	if !ok {
+		// Wait for the remaining parts.
+		return nil
+	}
+
+	return r.observer.OnFlowStatsReply(r.factory, r, reply)
+}
+
+func (r *Transceiver) handlePortStatsReply(packet []byte) error {
+	msg, err := r.factory.NewPortStatsReply()
+	if err != nil {
+		return err
+	}
+	if err := msg.UnmarshalBinary(packet); err != nil {
+		return err
+	}
+	reply, ok := r.multipart.portStats(packet, msg, r.stream.clock.Now())
+	if !ok {
+		// Wait for the remaining parts.
+		return nil
+	}
+
+	return r.observer.OnPortStatsReply(r.factory, r, reply)
+}
+
+func (r *Transceiver) handleTableStatsReply(packet []byte) error {
+	msg, err := r.factory.NewTableStatsReply()
+	if err != nil {
+		return err
+	}
+	if err := msg.UnmarshalBinary(packet); err != nil {
+		return err
+	}
+	reply, ok := r.multipart.tableStats(packet, msg, r.stream.clock.Now())
+	if !ok {
+		// Wait for the remaining parts.
+		return nil
+	}
+
+	return r.observer.OnTableStatsReply(r.factory, r, reply)
+}
+
+func (r *Transceiver) handleQueueGetConfigReply(packet []byte) error {
+	msg, err := r.factory.NewQueueGetConfigReply()
+	if err != nil {
+		return err
+	}
+	if err := msg.UnmarshalBinary(packet); err != nil {
+		return err
+	}
+
+	return r.observer.OnQueueGetConfigReply(r.factory, r, msg)
+}
+
+func (r *Transceiver) handleRoleReply(packet []byte) error {
+	msg, err := r.factory.NewRoleReply()
+	if err != nil {
+		return err
+	}
+	if err := msg.UnmarshalBinary(packet); err != nil {
+		return err
+	}
+	// The reply to RoleNoChange also tells the current role.
+	atomic.StoreUint32(&r.role, uint32(msg.Role()))
+
+	return r.observer.OnRoleReply(r.factory, r, msg)
+}
+
 func (r *Transceiver) handlePortDescReply(packet []byte) error {
 	msg, err := r.factory.NewPortDescReply()
 	if err != nil {
@@ -553,8 +991,13 @@ func (r *Transceiver) handlePortDescReply(packet []byte) error {
 	if err := msg.UnmarshalBinary(packet); err != nil {
 		return err
 	}
+	reply, ok := r.multipart.portDesc(packet, msg, r.stream.clock.Now())
+	if !ok {
+		// Wait for the remaining parts.
+		return nil
+	}
 
-	return r.observer.OnPortDescReply(r.factory, r, msg)
+	return r.observer.OnPortDescReply(r.factory, r, reply)
 }
 
 func (r *Transceiver) handlePortStatus(packet []byte) error {
@@ -606,14 +1049,10 @@ func (r *Transceiver) handleBarrierReply(packet []byte) error {
 }
 
 func (r *Transceiver) Close() error {
-	if r.closed {
-		return nil
-	}
-
-	if err := r.stream.Close(); err != nil {
-		return err
-	}
-	r.closed = true
+	r.closeOnce.Do(func() {
+		r.queue.close()
+		r.closeErr = r.stream.Close()
+	})
 
-	return nil
+	return r.closeErr
 }
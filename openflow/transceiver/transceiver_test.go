@@ -0,0 +1,134 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/superkkt/cherry/clock"
+	"github.com/superkkt/cherry/openflow"
+
+	"github.com/pkg/errors"
+)
+
+func TestMaxMessageSize(t *testing.T) {
+	src := []struct {
+		Max    int
+		Length uint16
+		Err    error
+	}{
+		{Max: 0, Length: 0xFFFF, Err: nil},
+		{Max: 128, Length: 128, Err: nil},
+		{Max: 128, Length: 129, Err: ErrMessageTooLarge},
+		// Clamped to the header size.
+		{Max: 1, Length: 9, Err: ErrMessageTooLarge},
+		{Max: 1, Length: 7, Err: openflow.ErrInvalidPacketLength},
+	}
+
+	for i, v := range src {
+		controller, device := net.Pipe()
+		trans := NewTransceiver(NewStream(controller, 0xFFFF), nopHandler{})
+		trans.SetMaxMessageSize(v.Max)
+
+		go func(length uint16) {
+			frame := make([]byte, length)
+			if length < 8 {
+				frame = make([]byte, 8)
+			}
+			frame[0] = openflow.OF13_VERSION
+			frame[1] = 10 // PACKET_IN
+			binary.BigEndian.PutUint16(frame[2:4], length)
+			device.Write(frame)
+		}(v.Length)
+
+		packet, err := trans.readPacket()
+		if errors.Cause(err) != v.Err {
+			t.Fatalf("#%v: unexpected error: expected=%v, got=%v", i, v.Err, err)
+		}
+		if err == nil && len(packet) != int(v.Length) {
+			t.Fatalf("#%v: unexpected packet length: expected=%v, got=%v", i, v.Length, len(packet))
+		}
+		controller.Close()
+		device.Close()
+	}
+}
+
+func TestSocketTimeouts(t *testing.T) {
+	src := []struct {
+		Read, Write                 time.Duration
+		ExpectedRead, ExpectedWrite time.Duration
+	}{
+		{Read: 0, Write: 0, ExpectedRead: DefaultReadTimeout, ExpectedWrite: DefaultWriteTimeout},
+		{Read: 30 * time.Second, Write: 5 * time.Second, ExpectedRead: 30 * time.Second, ExpectedWrite: 5 * time.Second},
+		{Read: -1, Write: 3 * time.Second, ExpectedRead: DefaultReadTimeout, ExpectedWrite: 3 * time.Second},
+	}
+
+	for i, v := range src {
+		controller, device := net.Pipe()
+		trans := NewTransceiver(NewStream(controller, 0xFFFF), nopHandler{})
+		trans.SetReadTimeout(v.Read)
+		trans.SetWriteTimeout(v.Write)
+
+		// Run applies the timeouts to the stream, and then returns immediately because the context is done.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		trans.Run(ctx)
+		if got := trans.stream.GetReadTimeout(); got != v.ExpectedRead {
+			t.Fatalf("#%v: unexpected read timeout: expected=%v, got=%v", i, v.ExpectedRead, got)
+		}
+		if got := trans.stream.GetWriteTimeout(); got != v.ExpectedWrite {
+			t.Fatalf("#%v: unexpected write timeout: expected=%v, got=%v", i, v.ExpectedWrite, got)
+		}
+		controller.Close()
+		device.Close()
+	}
+}
+
+func TestConfirmContextUsesRealTime(t *testing.T) {
+	controller, device := net.Pipe()
+	defer controller.Close()
+	defer device.Close()
+
+	stream := NewStream(controller, 0xFFFF)
+	// The fake clock is far behind the real time, so the deadline based on it would have already passed.
+	stream.SetClock(clock.NewFake(time.Now().Add(-time.Hour)))
+	trans := NewTransceiver(stream, nopHandler{})
+	trans.SetConfirmTimeout(time.Minute)
+
+	start := time.Now()
+	ctx, cancel := trans.ConfirmContext(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("confirm context without a deadline")
+	}
+	if deadline.Before(start.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+		t.Fatalf("unexpected deadline: %v", deadline)
+	}
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("confirm context is already done: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"encoding/binary"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+
+	"github.com/pkg/errors"
+)
+
+// supportedVersions is the OpenFlow versions that we support in descending order of the preference.
+var supportedVersions = []uint8{openflow.OF13_VERSION, openflow.OF10_VERSION}
+
+// ErrIncompatibleVersion is returned when there is no OpenFlow version supported by both of a switch and us.
+var ErrIncompatibleVersion = errors.New("no compatible OpenFlow version")
+
+// negotiateVersion returns the highest OpenFlow version supported by both of us and the switch that sent
+// the hello message. The version bitmap of the message is used if it exists. Otherwise, the lower one of
+// our highest version and the version of the message is used.
+func negotiateVersion(hello []byte) (uint8, error) {
+	if len(hello) < 8 {
+		return 0, openflow.ErrInvalidPacketLength
+	}
+
+	if bitmap, ok := versionBitmap(hello); ok {
+		for _, v := range supportedVersions {
+			word := int(v) / 32
+			if word < len(bitmap) && bitmap[word]&(1<<(uint(v)%32)) != 0 {
+				return v, nil
+			}
+		}
+		return 0, ErrIncompatibleVersion
+	}
+
+	version := hello[0]
+	if version > supportedVersions[0] {
+		version = supportedVersions[0]
+	}
+	for _, v := range supportedVersions {
+		if v == version {
+			return v, nil
+		}
+	}
+
+	return 0, ErrIncompatibleVersion
+}
+
+// versionBitmap returns the version bitmap of the hello message if it exists.
+func versionBitmap(hello []byte) (bitmap []uint32, ok bool) {
+	length := int(binary.BigEndian.Uint16(hello[2:4]))
+	if length > len(hello) {
+		length = len(hello)
+	}
+	elements := hello[8:length]
+
+	// Element header is 4 bytes: type and length that includes the header but not the padding.
+	for len(elements) >= 4 {
+		elemType := binary.BigEndian.Uint16(elements[0:2])
+		elemLen := int(binary.BigEndian.Uint16(elements[2:4]))
+		if elemLen < 4 || elemLen > len(elements) {
+			return nil, false
+		}
+		if elemType == of13.OFPHET_VERSIONBITMAP {
+			for b := elements[4:elemLen]; len(b) >= 4; b = b[4:] {
+				bitmap = append(bitmap, binary.BigEndian.Uint32(b[0:4]))
+			}
+			return bitmap, true
+		}
+		// Elements are padded to align as a multiple of 8.
+		padded := (elemLen + 7) / 8 * 8
+		if padded > len(elements) {
+			break
+		}
+		elements = elements[padded:]
+	}
+
+	return nil, false
+}
+
+// newHelloFailed returns the OFPT_ERROR message of OFPET_HELLO_FAILED and OFPHFC_INCOMPATIBLE in reply to
+// the hello message. The error and its header use the lower one of our highest version and the version of
+// the hello message, which is the version that the switch is most likely to understand.
+func newHelloFailed(hello []byte) []byte {
+	version := hello[0]
+	if version > supportedVersions[0] {
+		version = supportedVersions[0]
+	}
+	text := []byte("incompatible OpenFlow version")
+
+	packet := make([]byte, 12, 12+len(text))
+	packet[0] = version
+	packet[1] = of13.OFPT_ERROR // Same type in all the versions.
+	binary.BigEndian.PutUint16(packet[2:4], uint16(12+len(text)))
+	copy(packet[4:8], hello[4:8]) // Same transaction ID with the hello message.
+	binary.BigEndian.PutUint16(packet[8:10], of13.OFPET_HELLO_FAILED)
+	binary.BigEndian.PutUint16(packet[10:12], of13.OFPHFC_INCOMPATIBLE)
+
+	return append(packet, text...)
+}
@@ -0,0 +1,126 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package transceiver
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/superkkt/cherry/openflow"
+	"github.com/superkkt/cherry/openflow/of13"
+
+	"github.com/pkg/errors"
+)
+
+// newTestHello returns a hello message whose header version is version. The version bitmap element is
+// appended if bitmap is not nil.
+func newTestHello(version uint8, bitmap []uint32) []byte {
+	packet := []byte{version, 0x00, 0, 0, 0, 0, 0, 1}
+	if bitmap != nil {
+		elem := make([]byte, 4+4*len(bitmap))
+		binary.BigEndian.PutUint16(elem[0:2], of13.OFPHET_VERSIONBITMAP)
+		binary.BigEndian.PutUint16(elem[2:4], uint16(len(elem)))
+		for i, v := range bitmap {
+			binary.BigEndian.PutUint32(elem[4+4*i:], v)
+		}
+		// Padding to align as a multiple of 8.
+		for len(elem)%8 != 0 {
+			elem = append(elem, 0)
+		}
+		packet = append(packet, elem...)
+	}
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+
+	return packet
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	src := []struct {
+		Hello    []byte
+		Expected uint8
+		Err      error
+	}{
+		// OpenFlow 1.0 only.
+		{Hello: newTestHello(openflow.OF10_VERSION, nil), Expected: openflow.OF10_VERSION},
+		{Hello: newTestHello(openflow.OF10_VERSION, []uint32{1 << 1}), Expected: openflow.OF10_VERSION},
+		// OpenFlow 1.3 only.
+		{Hello: newTestHello(openflow.OF13_VERSION, nil), Expected: openflow.OF13_VERSION},
+		{Hello: newTestHello(openflow.OF13_VERSION, []uint32{1 << 4}), Expected: openflow.OF13_VERSION},
+		// Overlapping versions: the highest common one.
+		{Hello: newTestHello(0x06, []uint32{1<<1 | 1<<4 | 1<<5 | 1<<6}), Expected: openflow.OF13_VERSION},
+		{Hello: newTestHello(0x05, []uint32{1<<1 | 1<<2 | 1<<5}), Expected: openflow.OF10_VERSION},
+		// Higher version without the bitmap implies it supports the lower versions.
+		{Hello: newTestHello(0x05, nil), Expected: openflow.OF13_VERSION},
+		// No compatible version.
+		{Hello: newTestHello(0x03, []uint32{1<<2 | 1<<3}), Err: ErrIncompatibleVersion},
+		{Hello: newTestHello(0x02, nil), Err: ErrIncompatibleVersion},
+		{Hello: newTestHello(0x06, []uint32{1 << 6}), Err: ErrIncompatibleVersion},
+	}
+
+	for i, v := range src {
+		version, err := negotiateVersion(v.Hello)
+		if err != v.Err {
+			t.Fatalf("#%v: unexpected error: expected=%v, got=%v", i, v.Err, err)
+		}
+		if version != v.Expected {
+			t.Fatalf("#%v: unexpected version: expected=%v, got=%v", i, v.Expected, version)
+		}
+	}
+}
+
+func TestNegotiateIncompatibleVersion(t *testing.T) {
+	controller, device := net.Pipe()
+	defer device.Close()
+	trans := NewTransceiver(NewStream(controller, 0xFFFF), nopHandler{})
+	defer trans.Close()
+
+	reader := make(chan []byte, 1)
+	reader <- newTestHello(0x02, nil)
+	done := make(chan error, 1)
+	go func() {
+		_, err := trans.negotiate(context.Background(), reader)
+		done <- err
+	}()
+
+	reply := make([]byte, 12)
+	if _, err := io.ReadFull(device, reply); err != nil {
+		t.Fatalf("failed to read the error: %v", err)
+	}
+	if reply[1] != of13.OFPT_ERROR {
+		t.Fatalf("unexpected message type: %v", reply[1])
+	}
+	if class, code := binary.BigEndian.Uint16(reply[8:10]), binary.BigEndian.Uint16(reply[10:12]); class != of13.OFPET_HELLO_FAILED || code != of13.OFPHFC_INCOMPATIBLE {
+		t.Fatalf("unexpected error: class=%v, code=%v", class, code)
+	}
+	// Drain the error text.
+	io.ReadFull(device, make([]byte, int(binary.BigEndian.Uint16(reply[2:4]))-12))
+
+	if err := <-done; errors.Cause(err) != ErrIncompatibleVersion {
+		t.Fatalf("expected ErrIncompatibleVersion: %v", err)
+	}
+	if negotiated, _ := trans.Version(); negotiated {
+		t.Fatal("version should not be negotiated")
+	}
+}
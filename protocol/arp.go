@@ -99,20 +99,32 @@ func (r ARP) MarshalBinary() ([]byte, error) {
 	return v, nil
 }
 
+// UnmarshalBinary decodes an ARP packet for IPv4 over Ethernet. Other hardware and protocol types are rejected.
 func (r *ARP) UnmarshalBinary(data []byte) error {
 	if len(data) < 28 {
 		return errors.New("invalid ARP packet length")
 	}
 
-	r.HWType = binary.BigEndian.Uint16(data[0:2])
-	r.ProtoType = binary.BigEndian.Uint16(data[2:4])
+	hwType := binary.BigEndian.Uint16(data[0:2])
+	protoType := binary.BigEndian.Uint16(data[2:4])
+	if hwType != 1 || protoType != 0x0800 || data[4] != 6 || data[5] != 4 {
+		return fmt.Errorf("unsupported ARP packet: HWType=%v, ProtoType=%v, HWLength=%v, ProtoLength=%v", hwType, protoType, data[4], data[5])
+	}
+	operation := binary.BigEndian.Uint16(data[6:8])
+	if operation != 1 && operation != 2 {
+		return fmt.Errorf("unsupported ARP operation: %v", operation)
+	}
+
+	r.HWType = hwType
+	r.ProtoType = protoType
 	r.HWLength = data[4]
 	r.ProtoLength = data[5]
-	r.Operation = binary.BigEndian.Uint16(data[6:8])
-	r.SHA = data[8:14]
-	r.SPA = data[14:18]
-	r.THA = data[18:24]
-	r.TPA = data[24:28]
+	r.Operation = operation
+	// Copy the addresses not to refer to the packet buffer.
+	r.SHA = append(net.HardwareAddr{}, data[8:14]...)
+	r.SPA = append(net.IP{}, data[14:18]...)
+	r.THA = append(net.HardwareAddr{}, data[18:24]...)
+	r.TPA = append(net.IP{}, data[24:28]...)
 
 	return nil
 }
@@ -0,0 +1,89 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestARPCodec(t *testing.T) {
+	src := []*ARP{
+		NewARPRequest(net.HardwareAddr{0x00, 0x0b, 0x82, 0x01, 0xfc, 0x42}, net.HardwareAddr{0, 0, 0, 0, 0, 0}, net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2)),
+		NewARPReply(net.HardwareAddr{0x00, 0x0b, 0x82, 0x01, 0xfc, 0x43}, net.HardwareAddr{0x00, 0x0b, 0x82, 0x01, 0xfc, 0x42}, net.IPv4(10, 0, 0, 2), net.IPv4(10, 0, 0, 1)),
+	}
+
+	for i, v := range src {
+		data, err := v.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+
+		decoded := new(ARP)
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if decoded.Operation != v.Operation || !bytes.Equal(decoded.SHA, v.SHA) || !bytes.Equal(decoded.THA, v.THA) || !decoded.SPA.Equal(v.SPA) || !decoded.TPA.Equal(v.TPA) {
+			t.Fatalf("#%v: unexpected decoded ARP: expected=%v, got=%v", i, v, decoded)
+		}
+		// The decoded addresses should not refer to the packet buffer.
+		for j := range data {
+			data[j] = 0
+		}
+		if !bytes.Equal(decoded.SHA, v.SHA) || !decoded.TPA.Equal(v.TPA) {
+			t.Fatalf("#%v: decoded ARP refers to the packet buffer: %v", i, decoded)
+		}
+	}
+}
+
+func TestMalformedARP(t *testing.T) {
+	valid := []byte{
+		0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, 0x01,
+		0x00, 0x0b, 0x82, 0x01, 0xfc, 0x42, 0x0a, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x02,
+	}
+
+	src := []func([]byte) []byte{
+		// Truncated packet.
+		func(v []byte) []byte { return v[:27] },
+		// Non-Ethernet hardware type.
+		func(v []byte) []byte { v[1] = 0x06; return v },
+		// Non-IPv4 protocol type.
+		func(v []byte) []byte { v[2] = 0x86; v[3] = 0xdd; return v },
+		// Invalid address lengths.
+		func(v []byte) []byte { v[4] = 8; return v },
+		func(v []byte) []byte { v[5] = 16; return v },
+		// Invalid operation.
+		func(v []byte) []byte { v[7] = 3; return v },
+	}
+
+	if err := new(ARP).UnmarshalBinary(valid); err != nil {
+		t.Fatalf("unexpected error for the valid packet: %v", err)
+	}
+	for i, modify := range src {
+		packet := modify(append([]byte{}, valid...))
+		if err := new(ARP).UnmarshalBinary(packet); err == nil {
+			t.Fatalf("#%v: expected an error for the malformed packet %x", i, packet)
+		}
+	}
+}
@@ -42,7 +42,8 @@ func aroundCarry(sum uint32) uint32 {
 func calculateChecksum(header []byte) uint16 {
 	v := header
 	if len(v)%2 != 0 {
-		v = append(v, byte(0))
+		// Copy the header to pad it without overwriting the caller's buffer.
+		v = append(v[:len(v):len(v)], byte(0))
 	}
 
 	var sum uint32 = 0
@@ -26,6 +26,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"strings"
 	"sync"
@@ -58,6 +59,29 @@ const (
 	DHCPOpcodeReply   DHCPOpcode = 2
 )
 
+// DHCP option codes. See RFC 2132: DHCP Options and BOOTP Vendor Extensions.
+const (
+	DHCPOptionRequestedIP uint8 = 50
+	DHCPOptionLeaseTime   uint8 = 51
+	DHCPOptionMessageType uint8 = 53
+)
+
+// DHCPInfiniteLease is the lease time that never expires.
+const DHCPInfiniteLease time.Duration = math.MaxInt64
+
+type DHCPMessageType uint8
+
+const (
+	DHCPMessageTypeDiscover DHCPMessageType = 1
+	DHCPMessageTypeOffer    DHCPMessageType = 2
+	DHCPMessageTypeRequest  DHCPMessageType = 3
+	DHCPMessageTypeDecline  DHCPMessageType = 4
+	DHCPMessageTypeACK      DHCPMessageType = 5
+	DHCPMessageTypeNAK      DHCPMessageType = 6
+	DHCPMessageTypeRelease  DHCPMessageType = 7
+	DHCPMessageTypeInform   DHCPMessageType = 8
+)
+
 func (r *DHCP) MarshalBinary() ([]byte, error) {
 	if r.Op != DHCPOpcodeRequest && r.Op != DHCPOpcodeReply {
 		return nil, fmt.Errorf("invalid Op value: %v", r.Op)
@@ -163,9 +187,17 @@ func (r *DHCP) UnmarshalBinary(data []byte) (err error) {
 			}
 		}
 
+		// Zero-length option, e.g., Rapid Commit?
+		if len(opt) >= 2 && opt[1] == 0 {
+			opt = opt[2:]
+			continue
+		}
+
 		clv := DHCPOption{}
 		if err := clv.UnmarshalBinary(opt); err != nil {
-			return err
+			// The malformed option cannot be delimited, so ignore it and the following ones
+			// instead of dropping the whole packet that may still have the fields we need.
+			break
 		}
 		r.Options = append(r.Options, clv)
 		r.m.Store(clv.Code, clv)
@@ -185,6 +217,42 @@ func (r *DHCP) Option(code uint8) (opt DHCPOption, ok bool) {
 	return v.(DHCPOption), true
 }
 
+// MessageType returns the DHCP message type option, or zero if the option is missing or malformed.
+func (r *DHCP) MessageType() DHCPMessageType {
+	opt, ok := r.Option(DHCPOptionMessageType)
+	if !ok || len(opt.Value) != 1 {
+		return 0
+	}
+
+	return DHCPMessageType(opt.Value[0])
+}
+
+// RequestedIP returns the requested IP address option, or nil if the option is missing or malformed.
+func (r *DHCP) RequestedIP() net.IP {
+	opt, ok := r.Option(DHCPOptionRequestedIP)
+	if !ok || len(opt.Value) != 4 {
+		return nil
+	}
+
+	return net.IPv4(opt.Value[0], opt.Value[1], opt.Value[2], opt.Value[3])
+}
+
+// LeaseTime returns the IP address lease time option. ok is false if the option is missing or malformed.
+// DHCPInfiniteLease is returned for the infinite lease.
+func (r *DHCP) LeaseTime() (lease time.Duration, ok bool) {
+	opt, ok := r.Option(DHCPOptionLeaseTime)
+	if !ok || len(opt.Value) != 4 {
+		return 0, false
+	}
+
+	v := binary.BigEndian.Uint32(opt.Value)
+	if v == 0xFFFFFFFF {
+		return DHCPInfiniteLease, true
+	}
+
+	return time.Duration(v) * time.Second, true
+}
+
 func unmarshalCString(data []byte) string {
 	if len(data) == 0 {
 		return ""
@@ -256,3 +256,73 @@ func TestCodec(t *testing.T) {
 		}
 	}
 }
+
+func TestDHCPOptions(t *testing.T) {
+	samples := []struct {
+		Packet      string
+		MessageType DHCPMessageType
+		RequestedIP net.IP
+		LeaseTime   time.Duration
+		NumOptions  int
+	}{
+		{
+			// DHCPREQUEST.
+			Packet:      "0101060000003d1e0000000000000000000000000000000000000000000b8201fc4200000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000638253633501033d0701000b8201fc423204c0a8000a3604c0a8000137040103062aff0000000000000000000000000000000000000000000000000000000000000000000000000000000000",
+			MessageType: DHCPMessageTypeRequest,
+			RequestedIP: net.IPv4(192, 168, 0, 10),
+			NumOptions:  5,
+		},
+		{
+			// DHCPACK.
+			Packet:      "0201060000003d1e0000000000000000c0a8000a0000000000000000000b8201fc4200000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000638253633501053a04000007083b0400000c4e330400000e103604c0a800010104ffffff00ff0000000000000000000000000000000000000000000000000000000000000000000000000000",
+			MessageType: DHCPMessageTypeACK,
+			LeaseTime:   3600 * time.Second,
+			NumOptions:  6,
+		},
+		{
+			// DHCPOFFER relayed by a relay agent.
+			Packet:      "020106017771cf85000a0000000000000a0a08ebac16b2ea0a0a08f0000e8611c07500000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000638253633501020104ffffff003604ac16b2ea33040000a8c003040a0a08fe06088fd104018fd10501420e3137322e32322e3137382e323334780501ac16b2ea3d10006e617468616e31636c69656e7469645a1f010100c878c45256402081313233348fe0cce2ee8596abb25817c480b2fd305216011420504f4e20312f312f30372f30313a312e302e31ff",
+			MessageType: DHCPMessageTypeOffer,
+			LeaseTime:   43200 * time.Second,
+			NumOptions:  11,
+		},
+		{
+			// DHCPACK with an infinite lease and a zero-length Rapid Commit option.
+			Packet:      "0201060000003d1e0000000000000000c0a8000a0000000000000000000b8201fc42000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000006382536335010550003304ffffffffff",
+			MessageType: DHCPMessageTypeACK,
+			LeaseTime:   DHCPInfiniteLease,
+			NumOptions:  2,
+		},
+		{
+			// DHCPACK whose lease time option is truncated, and a malformed message type option.
+			Packet:      "0201060000003d1e0000000000000000c0a8000a0000000000000000000b8201fc4200000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000638253633502050532040a0a0800330400000e",
+			RequestedIP: net.IPv4(10, 10, 8, 0),
+			NumOptions:  2,
+		},
+	}
+
+	for i, v := range samples {
+		p, err := hex.DecodeString(v.Packet)
+		if err != nil {
+			panic("invalid sample DHCP packet")
+		}
+
+		d := DHCP{}
+		if err := d.UnmarshalBinary(p); err != nil {
+			t.Fatalf("#%v: unexpected DHCP unmarshal error: %v", i, err)
+		}
+		if len(d.Options) != v.NumOptions {
+			t.Fatalf("#%v: unexpected number of options: expected=%v, actual=%v", i, v.NumOptions, len(d.Options))
+		}
+		if d.MessageType() != v.MessageType {
+			t.Fatalf("#%v: unexpected message type: expected=%v, actual=%v", i, v.MessageType, d.MessageType())
+		}
+		if !d.RequestedIP().Equal(v.RequestedIP) {
+			t.Fatalf("#%v: unexpected requested IP: expected=%v, actual=%v", i, v.RequestedIP, d.RequestedIP())
+		}
+		lease, ok := d.LeaseTime()
+		if ok != (v.LeaseTime != 0) || lease != v.LeaseTime {
+			t.Fatalf("#%v: unexpected lease time: expected=%v, actual=%v (ok=%v)", i, v.LeaseTime, lease, ok)
+		}
+	}
+}
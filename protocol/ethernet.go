@@ -22,18 +22,93 @@
 package protocol
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"net"
 )
 
+// VLANTag is an IEEE 802.1Q VLAN tag.
+type VLANTag struct {
+	// Tag protocol identifier: 0x8100 for an 802.1Q tag, or 0x88A8 for an 802.1ad (QinQ) service tag.
+	TPID uint16
+	// 3-bit priority code point.
+	Priority uint8
+	// Drop eligible indicator.
+	DEI bool
+	// 12-bit VLAN ID.
+	ID uint16
+}
+
+func isVLANTPID(t uint16) bool {
+	return t == 0x8100 || t == 0x88A8
+}
+
 type Ethernet struct {
 	SrcMAC, DstMAC net.HardwareAddr
-	Type           uint16
-	Payload        []byte
+	// VLAN tags in order from the outermost one. Nil means an untagged frame.
+	VLANs []VLANTag
+	// Ethernet type of the payload, which is the inner one after the VLAN tags.
+	Type    uint16
+	Payload []byte
+	// Frame decoded by UnmarshalBinary. The MAC addresses and the payload above are the slices of this frame.
+	raw []byte
+}
+
+// VLAN returns the outermost VLAN tag. ok is false if the frame is untagged.
+func (r Ethernet) VLAN() (tag VLANTag, ok bool) {
+	if len(r.VLANs) == 0 {
+		return VLANTag{}, false
+	}
+
+	return r.VLANs[0], true
+}
+
+// Dirty returns whether the frame should be serialized again because it has not been decoded by UnmarshalBinary
+// or a field has been changed since then. The in-place modifications of the MAC addresses and the payload are
+// not changes because they are also applied to the decoded frame.
+func (r Ethernet) Dirty() bool {
+	if r.raw == nil {
+		return true
+	}
+	if !bytes.Equal(r.DstMAC, r.raw[0:6]) || !bytes.Equal(r.SrcMAC, r.raw[6:12]) {
+		return true
+	}
+
+	offset := 12
+	for _, tag := range r.VLANs {
+		if len(r.raw) < offset+4 || binary.BigEndian.Uint16(r.raw[offset:offset+2]) != tag.TPID {
+			return true
+		}
+		tci := binary.BigEndian.Uint16(r.raw[offset+2 : offset+4])
+		if tag.Priority != uint8(tci>>13) || tag.DEI != (tci&0x1000 != 0) || tag.ID != tci&0xFFF {
+			return true
+		}
+		offset += 4
+	}
+	// The number of the tags is same only if the next one is the ethernet type of the payload.
+	if len(r.raw) < offset+2 || binary.BigEndian.Uint16(r.raw[offset:offset+2]) != r.Type || isVLANTPID(r.Type) {
+		return true
+	}
+	payload := r.raw[offset+2:]
+	if len(r.Payload) != len(payload) {
+		return true
+	}
+	// Fast path for the payload that is still the slice of the decoded frame.
+	if len(payload) == 0 || &r.Payload[0] == &payload[0] {
+		return false
+	}
+
+	return !bytes.Equal(r.Payload, payload)
 }
 
+// MarshalBinary returns the frame decoded by UnmarshalBinary as is, without allocating a new one, if the frame
+// is not dirty. The caller should not modify the returned slice in that case.
 func (r Ethernet) MarshalBinary() ([]byte, error) {
+	if !r.Dirty() {
+		return r.raw, nil
+	}
 	if r.SrcMAC == nil || r.DstMAC == nil {
 		return nil, errors.New("invalid MAC address")
 	}
@@ -41,32 +116,93 @@ func (r Ethernet) MarshalBinary() ([]byte, error) {
 		return nil, errors.New("nil payload")
 	}
 
-	v := make([]byte, 14+len(r.Payload))
+	v := make([]byte, 14+len(r.VLANs)*4+len(r.Payload))
 	copy(v[0:6], r.DstMAC)
 	copy(v[6:12], r.SrcMAC)
-	binary.BigEndian.PutUint16(v[12:14], r.Type)
+	offset := 12
+	for _, tag := range r.VLANs {
+		if !isVLANTPID(tag.TPID) {
+			return nil, fmt.Errorf("invalid VLAN TPID: 0x%04x", tag.TPID)
+		}
+		if tag.Priority > 7 || tag.ID > 0xFFF {
+			return nil, fmt.Errorf("invalid VLAN tag: priority=%v, id=%v", tag.Priority, tag.ID)
+		}
+		tci := uint16(tag.Priority)<<13 | tag.ID
+		if tag.DEI {
+			tci |= 0x1000
+		}
+		binary.BigEndian.PutUint16(v[offset:offset+2], tag.TPID)
+		binary.BigEndian.PutUint16(v[offset+2:offset+4], tci)
+		offset += 4
+	}
+	binary.BigEndian.PutUint16(v[offset:offset+2], r.Type)
 	if len(r.Payload) > 0 {
-		copy(v[14:], r.Payload)
+		copy(v[offset+2:], r.Payload)
 	}
 
 	return v, nil
 }
 
+// Clone returns a deep copy of the frame that shares no buffer with r. The fields of the copy that are the slices
+// of the decoded frame of r become the slices of the copy's own decoded frame, so the in-place modifications of
+// the copy are not changes as the ones of r.
+func (r Ethernet) Clone() *Ethernet {
+	v := r
+	if r.raw != nil {
+		v.raw = append([]byte(nil), r.raw...)
+	}
+	v.DstMAC = net.HardwareAddr(cloneBytes(r.DstMAC, r.raw, v.raw, 0))
+	v.SrcMAC = net.HardwareAddr(cloneBytes(r.SrcMAC, r.raw, v.raw, 6))
+	v.Payload = cloneBytes(r.Payload, r.raw, v.raw, len(r.raw)-len(r.Payload))
+	if r.VLANs != nil {
+		v.VLANs = append([]VLANTag(nil), r.VLANs...)
+	}
+
+	return &v
+}
+
+// cloneBytes returns the slice of dst at offset if s is the slice of src at offset, or a copy of s otherwise.
+func cloneBytes(s, src, dst []byte, offset int) []byte {
+	if s == nil {
+		return nil
+	}
+	if len(s) > 0 && offset >= 0 && offset+len(s) <= len(src) && &s[0] == &src[offset] {
+		return dst[offset : offset+len(s)]
+	}
+	v := make([]byte, len(s))
+	copy(v, s)
+
+	return v
+}
+
 func (r *Ethernet) UnmarshalBinary(data []byte) error {
+	r.raw = nil
 	if len(data) < 14 {
 		return errors.New("invalid ethernet frame length")
 	}
 
 	r.DstMAC = data[0:6]
 	r.SrcMAC = data[6:12]
+	r.VLANs = nil
 	r.Type = binary.BigEndian.Uint16(data[12:14])
-	// IEEE 802.1Q-tagged frame?
-	if r.Type == 0x8100 {
-		r.Type = binary.BigEndian.Uint16(data[16:18])
-		r.Payload = data[18:]
-	} else {
-		r.Payload = data[14:]
+	offset := 14
+	// IEEE 802.1Q-tagged frame? QinQ frames have one more tag.
+	for isVLANTPID(r.Type) {
+		if len(data) < offset+4 {
+			return errors.New("invalid VLAN-tagged ethernet frame length")
+		}
+		tci := binary.BigEndian.Uint16(data[offset : offset+2])
+		r.VLANs = append(r.VLANs, VLANTag{
+			TPID:     r.Type,
+			Priority: uint8(tci >> 13),
+			DEI:      tci&0x1000 != 0,
+			ID:       tci & 0xFFF,
+		})
+		r.Type = binary.BigEndian.Uint16(data[offset+2 : offset+4])
+		offset += 4
 	}
+	r.Payload = data[offset:]
+	r.raw = data
 	// FIXME: Add routines for JumboFrame
 
 	return nil
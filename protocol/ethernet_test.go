@@ -0,0 +1,274 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestEthernetCodec(t *testing.T) {
+	src := []struct {
+		Frame Ethernet
+		Bytes []byte
+	}{
+		// Untagged frame.
+		{
+			Frame: Ethernet{Type: 0x0800, Payload: []byte{0xAA, 0xBB}},
+			Bytes: []byte{0x08, 0x00, 0xAA, 0xBB},
+		},
+		// Single-tagged frame: priority 5, VLAN 100.
+		{
+			Frame: Ethernet{VLANs: []VLANTag{{TPID: 0x8100, Priority: 5, ID: 100}}, Type: 0x0806, Payload: []byte{0xAA, 0xBB}},
+			Bytes: []byte{0x81, 0x00, 0xA0, 0x64, 0x08, 0x06, 0xAA, 0xBB},
+		},
+		// Double-tagged (QinQ) frame: service VLAN 4095 with DEI, and customer VLAN 1.
+		{
+			Frame: Ethernet{VLANs: []VLANTag{{TPID: 0x88A8, DEI: true, ID: 4095}, {TPID: 0x8100, Priority: 7, ID: 1}}, Type: 0x86DD, Payload: []byte{0xAA, 0xBB}},
+			Bytes: []byte{0x88, 0xA8, 0x1F, 0xFF, 0x81, 0x00, 0xE0, 0x01, 0x86, 0xDD, 0xAA, 0xBB},
+		},
+	}
+
+	dst := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	srcMAC := net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	for i, v := range src {
+		v.Frame.DstMAC = dst
+		v.Frame.SrcMAC = srcMAC
+		expected := append(append(append([]byte{}, dst...), srcMAC...), v.Bytes...)
+
+		data, err := v.Frame.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%v, got=%v", i, expected, data)
+		}
+
+		decoded := new(Ethernet)
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if !reflect.DeepEqual(decoded.VLANs, v.Frame.VLANs) || decoded.Type != v.Frame.Type || !bytes.Equal(decoded.Payload, v.Frame.Payload) {
+			t.Fatalf("#%v: unexpected decoded frame: expected=%+v, got=%+v", i, v.Frame, decoded)
+		}
+		tag, ok := decoded.VLAN()
+		if ok != (len(v.Frame.VLANs) > 0) || (ok && tag != v.Frame.VLANs[0]) {
+			t.Fatalf("#%v: unexpected outermost VLAN tag: ok=%v, tag=%+v", i, ok, tag)
+		}
+	}
+}
+
+func TestMalformedEthernet(t *testing.T) {
+	header := []byte{0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 1}
+	src := [][]byte{
+		// Truncated header.
+		header,
+		// Truncated VLAN tag.
+		append(append([]byte{}, header...), 0x81, 0x00, 0x00),
+		append(append([]byte{}, header...), 0x81, 0x00, 0x00, 0x64, 0x88),
+		// Truncated inner VLAN tag of a QinQ frame.
+		append(append([]byte{}, header...), 0x88, 0xA8, 0x00, 0x64, 0x81, 0x00, 0x00, 0x01),
+	}
+
+	for i, v := range src {
+		if err := new(Ethernet).UnmarshalBinary(v); err == nil {
+			t.Fatalf("#%v: expected an error, but got nil", i)
+		}
+	}
+
+	invalid := []VLANTag{
+		{TPID: 0x0800, ID: 1},
+		{TPID: 0x8100, ID: 4096},
+		{TPID: 0x8100, Priority: 8},
+	}
+	for i, v := range invalid {
+		eth := Ethernet{SrcMAC: header[6:], DstMAC: header[:6], VLANs: []VLANTag{v}, Type: 0x0800, Payload: []byte{}}
+		if _, err := eth.MarshalBinary(); err == nil {
+			t.Fatalf("#%v: expected an error for the invalid VLAN tag %+v, but got nil", i, v)
+		}
+	}
+}
+
+func newTestFrame() []byte {
+	// Single-tagged IPv4 frame.
+	return []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x81, 0x00, 0xA0, 0x64, 0x08, 0x00, 0xAA, 0xBB, 0xCC, 0xDD,
+	}
+}
+
+func TestEthernetDirty(t *testing.T) {
+	src := []struct {
+		Modify   func(*Ethernet)
+		Dirty    bool
+		Expected []byte // Nil means the original frame
+	}{
+		{Modify: func(e *Ethernet) {}, Dirty: false},
+		// In-place modifications are applied to the original frame.
+		{
+			Modify:   func(e *Ethernet) { e.Payload[0] = 0xFF },
+			Dirty:    false,
+			Expected: []byte{0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 1, 0x81, 0x00, 0xA0, 0x64, 0x08, 0x00, 0xFF, 0xBB, 0xCC, 0xDD},
+		},
+		// Same values assigned again.
+		{Modify: func(e *Ethernet) { e.DstMAC = net.HardwareAddr{0, 0, 0, 0, 0, 2} }, Dirty: false},
+		{
+			Modify:   func(e *Ethernet) { e.DstMAC = net.HardwareAddr{0, 0, 0, 0, 0, 3} },
+			Dirty:    true,
+			Expected: []byte{0, 0, 0, 0, 0, 3, 0, 0, 0, 0, 0, 1, 0x81, 0x00, 0xA0, 0x64, 0x08, 0x00, 0xAA, 0xBB, 0xCC, 0xDD},
+		},
+		{
+			Modify:   func(e *Ethernet) { e.VLANs[0].ID = 200 },
+			Dirty:    true,
+			Expected: []byte{0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 1, 0x81, 0x00, 0xA0, 0xC8, 0x08, 0x00, 0xAA, 0xBB, 0xCC, 0xDD},
+		},
+		{
+			Modify:   func(e *Ethernet) { e.VLANs = nil },
+			Dirty:    true,
+			Expected: []byte{0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 1, 0x08, 0x00, 0xAA, 0xBB, 0xCC, 0xDD},
+		},
+		{
+			Modify:   func(e *Ethernet) { e.Type = 0x0806 },
+			Dirty:    true,
+			Expected: []byte{0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 1, 0x81, 0x00, 0xA0, 0x64, 0x08, 0x06, 0xAA, 0xBB, 0xCC, 0xDD},
+		},
+		{
+			Modify:   func(e *Ethernet) { e.Payload = e.Payload[:2] },
+			Dirty:    true,
+			Expected: []byte{0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 1, 0x81, 0x00, 0xA0, 0x64, 0x08, 0x00, 0xAA, 0xBB},
+		},
+		{
+			Modify:   func(e *Ethernet) { e.Payload = []byte{0x11, 0x22, 0x33, 0x44} },
+			Dirty:    true,
+			Expected: []byte{0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 1, 0x81, 0x00, 0xA0, 0x64, 0x08, 0x00, 0x11, 0x22, 0x33, 0x44},
+		},
+	}
+
+	for i, v := range src {
+		frame := newTestFrame()
+		eth := new(Ethernet)
+		if err := eth.UnmarshalBinary(frame); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		v.Modify(eth)
+		if eth.Dirty() != v.Dirty {
+			t.Fatalf("#%v: unexpected dirty flag: expected=%v, got=%v", i, v.Dirty, eth.Dirty())
+		}
+
+		data, err := eth.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		expected := v.Expected
+		if expected == nil {
+			expected = newTestFrame()
+		}
+		if !bytes.Equal(data, expected) {
+			t.Fatalf("#%v: unexpected encoding: expected=%x, got=%x", i, expected, data)
+		}
+	}
+
+	// A frame that is not decoded is always dirty.
+	if !(Ethernet{}).Dirty() {
+		t.Fatalf("a new frame is not dirty")
+	}
+}
+
+func BenchmarkEthernetMarshal(b *testing.B) {
+	frame := append(newTestFrame(), make([]byte, 1400)...)
+
+	b.Run("Unmodified", func(b *testing.B) {
+		eth := new(Ethernet)
+		if err := eth.UnmarshalBinary(frame); err != nil {
+			b.Fatalf("failed to unmarshal: %v", err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := eth.MarshalBinary(); err != nil {
+				b.Fatalf("failed to marshal: %v", err)
+			}
+		}
+	})
+	b.Run("Modified", func(b *testing.B) {
+		eth := new(Ethernet)
+		if err := eth.UnmarshalBinary(frame); err != nil {
+			b.Fatalf("failed to unmarshal: %v", err)
+		}
+		eth.DstMAC = net.HardwareAddr{0, 0, 0, 0, 0, 3}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := eth.MarshalBinary(); err != nil {
+				b.Fatalf("failed to marshal: %v", err)
+			}
+		}
+	})
+}
+
+func TestEthernetClone(t *testing.T) {
+	frame := newTestFrame()
+	original := append([]byte(nil), frame...)
+	eth := new(Ethernet)
+	if err := eth.UnmarshalBinary(frame); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	clone := eth.Clone()
+	// In-place modifications of the clone are applied to its own frame, not to the original one.
+	clone.Payload[0] = 0xFF
+	clone.DstMAC[5] = 0x03
+	clone.VLANs[0].ID = 200
+	if !bytes.Equal(frame, original) {
+		t.Fatalf("original frame is modified: %v", frame)
+	}
+	if eth.Dirty() {
+		t.Fatal("original frame is dirty")
+	}
+	if eth.VLANs[0].ID != 100 {
+		t.Fatalf("original VLAN tag is modified: %v", eth.VLANs[0].ID)
+	}
+	data, err := clone.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal the clone: %v", err)
+	}
+	expected := []byte{0, 0, 0, 0, 0, 3, 0, 0, 0, 0, 0, 1, 0x81, 0x00, 0xA0, 0xC8, 0x08, 0x00, 0xFF, 0xBB, 0xCC, 0xDD}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("unexpected clone: expected=%v, got=%v", expected, data)
+	}
+
+	// The frame that has not been decoded.
+	eth = &Ethernet{
+		SrcMAC:  net.HardwareAddr{0, 0, 0, 0, 0, 1},
+		DstMAC:  net.HardwareAddr{0, 0, 0, 0, 0, 2},
+		Type:    0x0800,
+		Payload: []byte{0xAA},
+	}
+	clone = eth.Clone()
+	clone.Payload[0] = 0xFF
+	clone.SrcMAC[5] = 0x05
+	if eth.Payload[0] != 0xAA || eth.SrcMAC[5] != 0x01 {
+		t.Fatalf("original is modified: %+v", eth)
+	}
+}
@@ -26,10 +26,65 @@ import (
 	"errors"
 )
 
+const (
+	ICMPTypeEchoReply   = 0
+	ICMPTypeEchoRequest = 8
+)
+
+var (
+	ErrICMPChecksum = errors.New("invalid ICMP checksum")
+)
+
+// ICMP is a generic ICMP message. ICMPEcho ignores Rest and Data of its embedded ICMP.
 type ICMP struct {
 	Type     uint8
 	Code     uint8
 	Checksum uint16
+	// Rest of the header whose format depends on the type and code.
+	Rest uint32
+	Data []byte
+}
+
+func (r ICMP) MarshalBinary() ([]byte, error) {
+	v := make([]byte, 8)
+	v[0] = r.Type
+	v[1] = r.Code
+	// v[2:4] is checksum
+	binary.BigEndian.PutUint32(v[4:8], r.Rest)
+	if r.Data != nil {
+		v = append(v, r.Data...)
+	}
+
+	checksum := calculateChecksum(v)
+	binary.BigEndian.PutUint16(v[2:4], checksum)
+
+	return v, nil
+}
+
+func (r *ICMP) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return errors.New("invalid ICMP packet length")
+	}
+	if !validICMPChecksum(data) {
+		return ErrICMPChecksum
+	}
+
+	r.Type = data[0]
+	r.Code = data[1]
+	r.Checksum = binary.BigEndian.Uint16(data[2:4])
+	r.Rest = binary.BigEndian.Uint32(data[4:8])
+	r.Data = nil
+	if len(data) > 8 {
+		r.Data = data[8:]
+	}
+
+	return nil
+}
+
+// validICMPChecksum returns whether the checksum of the ICMP message is correct. The checksum calculated
+// over the whole message including its checksum field is zero if the message is not corrupted.
+func validICMPChecksum(data []byte) bool {
+	return calculateChecksum(data) == 0
 }
 
 type ICMPEcho struct {
@@ -42,7 +97,7 @@ type ICMPEcho struct {
 func NewICMPEchoRequest(id, seq uint16, payload []byte) *ICMPEcho {
 	return &ICMPEcho{
 		ICMP: ICMP{
-			Type: 8,
+			Type: ICMPTypeEchoRequest,
 		},
 		ID:       id,
 		Sequence: seq,
@@ -52,6 +107,9 @@ func NewICMPEchoRequest(id, seq uint16, payload []byte) *ICMPEcho {
 
 func NewICMPEchoReply(id, seq uint16, payload []byte) *ICMPEcho {
 	return &ICMPEcho{
+		ICMP: ICMP{
+			Type: ICMPTypeEchoReply,
+		},
 		ID:       id,
 		Sequence: seq,
 		Payload:  payload,
@@ -79,9 +137,12 @@ func (r *ICMPEcho) UnmarshalBinary(data []byte) error {
 	if len(data) < 8 {
 		return errors.New("invalid ICMP packet length")
 	}
-	if data[0] != 8 && data[0] != 0 {
+	if data[0] != ICMPTypeEchoRequest && data[0] != ICMPTypeEchoReply {
 		return errors.New("packet is not an ICMP echo message")
 	}
+	if !validICMPChecksum(data) {
+		return ErrICMPChecksum
+	}
 
 	r.Type = data[0]
 	r.Code = data[1]
@@ -0,0 +1,81 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestICMPCodec(t *testing.T) {
+	src := []ICMP{
+		{Type: ICMPTypeEchoRequest, Rest: 0x00010002, Data: []byte("hello")},
+		{Type: 3, Code: 1, Data: []byte{0x45, 0x00}},
+		{Type: ICMPTypeEchoReply},
+	}
+
+	for i, v := range src {
+		data, err := v.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+
+		decoded := new(ICMP)
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if decoded.Type != v.Type || decoded.Code != v.Code || decoded.Rest != v.Rest || !bytes.Equal(decoded.Data, v.Data) {
+			t.Fatalf("#%v: unexpected decoded ICMP: expected=%+v, got=%+v", i, v, decoded)
+		}
+
+		// Corrupted messages should be rejected.
+		data[len(data)-1] ^= 0x01
+		if err := decoded.UnmarshalBinary(data); err != ErrICMPChecksum {
+			t.Fatalf("#%v: expected checksum error for the corrupted message: %v", i, err)
+		}
+	}
+}
+
+func TestIPv4ICMP(t *testing.T) {
+	icmp, err := NewICMPEchoRequest(1, 2, []byte("ping")).MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	packet, err := NewIPv4([]byte{10, 0, 0, 1}, []byte{10, 0, 0, 2}, 1, icmp).MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Ethernet padding after the IPv4 packet should be ignored.
+	packet = append(packet, 0x01, 0x02, 0x03)
+
+	ip := new(IPv4)
+	if err := ip.UnmarshalBinary(packet); err != nil {
+		t.Fatalf("failed to unmarshal IPv4: %v", err)
+	}
+	v, err := ip.ICMP()
+	if err != nil {
+		t.Fatalf("failed to parse ICMP: %v", err)
+	}
+	if v.Type != ICMPTypeEchoRequest || v.Rest != 0x00010002 || string(v.Data) != "ping" {
+		t.Fatalf("unexpected ICMP: %+v", v)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+const (
+	IGMPMembershipQuery    = 0x11
+	IGMPv1MembershipReport = 0x12
+	IGMPv2MembershipReport = 0x16
+	IGMPv2LeaveGroup       = 0x17
+	IGMPv3MembershipReport = 0x22
+)
+
+// Group record types of IGMPv3. See RFC 3376.
+const (
+	IGMPModeIsInclude = iota + 1
+	IGMPModeIsExclude
+	IGMPChangeToInclude
+	IGMPChangeToExclude
+	IGMPAllowNewSources
+	IGMPBlockOldSources
+)
+
+type IGMP struct {
+	Type        uint8
+	MaxRespTime uint8
+	Checksum    uint16
+	// Group address of IGMPv1 and v2 messages, and IGMPv3 queries.
+	Group net.IP
+	// Group records of IGMPv3 membership reports.
+	Records []IGMPGroupRecord
+}
+
+type IGMPGroupRecord struct {
+	Type    uint8
+	Group   net.IP
+	Sources []net.IP
+}
+
+// IsJoin returns whether this record means that the host wants to receive the group traffic.
+func (r IGMPGroupRecord) IsJoin() bool {
+	switch r.Type {
+	case IGMPModeIsExclude, IGMPChangeToExclude:
+		return true
+	case IGMPModeIsInclude, IGMPChangeToInclude, IGMPAllowNewSources:
+		return len(r.Sources) > 0
+	default:
+		return false
+	}
+}
+
+// IsLeave returns whether this record means that the host does not want to receive the group traffic anymore.
+func (r IGMPGroupRecord) IsLeave() bool {
+	return (r.Type == IGMPModeIsInclude || r.Type == IGMPChangeToInclude) && len(r.Sources) == 0
+}
+
+func (r *IGMP) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return errors.New("invalid IGMP packet length")
+	}
+
+	r.Type = data[0]
+	r.MaxRespTime = data[1]
+	r.Checksum = binary.BigEndian.Uint16(data[2:4])
+	r.Group = nil
+	r.Records = nil
+
+	if r.Type != IGMPv3MembershipReport {
+		r.Group = net.IP(data[4:8])
+		return nil
+	}
+
+	n := int(binary.BigEndian.Uint16(data[6:8]))
+	buf := data[8:]
+	for i := 0; i < n; i++ {
+		if len(buf) < 8 {
+			return errors.New("invalid IGMPv3 group record length")
+		}
+		auxLen := int(buf[1]) * 4
+		numSrc := int(binary.BigEndian.Uint16(buf[2:4]))
+		length := 8 + numSrc*4 + auxLen
+		if len(buf) < length {
+			return errors.New("invalid IGMPv3 group record length")
+		}
+
+		record := IGMPGroupRecord{
+			Type:  buf[0],
+			Group: net.IP(buf[4:8]),
+		}
+		for j := 0; j < numSrc; j++ {
+			record.Sources = append(record.Sources, net.IP(buf[8+j*4:12+j*4]))
+		}
+		r.Records = append(r.Records, record)
+		buf = buf[length:]
+	}
+
+	return nil
+}
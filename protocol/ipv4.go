@@ -27,8 +27,14 @@ import (
 	"net"
 )
 
-// TODO: Test this module!!
+var (
+	ErrIPv4Checksum = errors.New("invalid IPv4 header checksum")
+	// ErrTruncated is returned when a header has been truncated, e.g., by miss_send_len of PACKET_IN.
+	ErrTruncated = errors.New("truncated packet header")
+)
 
+// IPv4 is an IPv4 packet. IHL is the header length in 4-byte words including Options, which is calculated
+// from Options when the packet is marshaled.
 type IPv4 struct {
 	Version  uint8
 	IHL      uint8
@@ -43,7 +49,9 @@ type IPv4 struct {
 	Checksum uint16
 	SrcIP    net.IP
 	DstIP    net.IP
-	Payload  []byte
+	// Options are padded to a multiple of 4 bytes when the packet is marshaled.
+	Options []byte
+	Payload []byte
 }
 
 func NewIPv4(src, dst net.IP, protocol uint8, payload []byte) *IPv4 {
@@ -77,14 +85,48 @@ func NewIPv4(src, dst net.IP, protocol uint8, payload []byte) *IPv4 {
 	}
 }
 
+// TOS returns the type of service byte, i.e., DSCP and ECN.
+func (r IPv4) TOS() uint8 {
+	return (r.DSCP&0x3F)<<2 | r.ECN&0x3
+}
+
 func (r IPv4) MarshalBinary() ([]byte, error) {
+	header, err := r.header()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Payload == nil {
+		return header, nil
+	}
+	return append(header, r.Payload...), nil
+}
+
+// UpdateChecksum recomputes the header checksum of this packet, e.g., after its TTL is decreased.
+func (r *IPv4) UpdateChecksum() error {
+	header, err := r.header()
+	if err != nil {
+		return err
+	}
+	r.Checksum = binary.BigEndian.Uint16(header[10:12])
+
+	return nil
+}
+
+// header returns the encoded header of this packet including the options and the checksum.
+func (r IPv4) header() ([]byte, error) {
 	if r.SrcIP == nil || r.DstIP == nil {
 		return nil, errors.New("nil IP address")
 	}
+	// Pad the options to a multiple of 4 bytes.
+	optLen := (len(r.Options) + 3) / 4 * 4
+	if optLen > 40 {
+		return nil, errors.New("too long IPv4 options")
+	}
 
-	header := make([]byte, 20)
-	header[0] = (r.Version&0xF)<<4 | r.IHL&0xF
-	header[1] = (r.DSCP&0x3F)<<2 | r.ECN&0x3
+	header := make([]byte, 20+optLen)
+	header[0] = (r.Version&0xF)<<4 | uint8(len(header)/4)
+	header[1] = r.TOS()
 	binary.BigEndian.PutUint16(header[2:4], r.Length)
 	binary.BigEndian.PutUint16(header[4:6], r.ID)
 	binary.BigEndian.PutUint16(header[6:8], (uint16(r.Flags)&0x7)<<13|r.Offset&0x1FFF)
@@ -101,23 +143,30 @@ func (r IPv4) MarshalBinary() ([]byte, error) {
 		return nil, errors.New("destination IP address is not an IPv4 address")
 	}
 	copy(header[16:20], dstIP)
+	copy(header[20:], r.Options)
 
 	checksum := calculateChecksum(header)
 	binary.BigEndian.PutUint16(header[10:12], checksum)
 
-	if r.Payload == nil {
-		return header, nil
-	}
-	return append(header, r.Payload...), nil
+	return header, nil
 }
 
 func (r *IPv4) UnmarshalBinary(data []byte) error {
 	if len(data) < 20 {
 		return errors.New("invalid IPv4 packet length")
 	}
+	ihl := data[0] & 0xF
+	headerLen := int(ihl) * 4
+	if headerLen < 20 || headerLen > len(data) {
+		return errors.New("invalid IPv4 header length")
+	}
+	// The checksum over the header including the checksum field itself should be zero.
+	if calculateChecksum(data[:headerLen]) != 0 {
+		return ErrIPv4Checksum
+	}
 
 	r.Version = (data[0] >> 4) & 0xF
-	r.IHL = data[0] & 0xF
+	r.IHL = ihl
 	r.DSCP = (data[1] >> 2) & 0x3F
 	r.ECN = data[1] & 0x3
 	r.Length = binary.BigEndian.Uint16(data[2:4])
@@ -130,11 +179,77 @@ func (r *IPv4) UnmarshalBinary(data []byte) error {
 	r.Checksum = binary.BigEndian.Uint16(data[10:12])
 	r.SrcIP = data[12:16]
 	r.DstIP = data[16:20]
+	r.Options = nil
+	if headerLen > 20 {
+		r.Options = data[20:headerLen]
+	}
+	r.Payload = nil
 
-	headerLen := int(r.IHL) * 4
+	// Ignore the trailing bytes, such as Ethernet padding, beyond the total length.
+	if total := int(r.Length); total >= headerLen && total < len(data) {
+		data = data[:total]
+	}
 	if len(data) > headerLen {
 		r.Payload = data[headerLen:]
 	}
 
 	return nil
 }
+
+// Truncated returns whether the payload is shorter than the total length in the header, which means the packet
+// has been truncated, e.g., by miss_send_len of PACKET_IN.
+func (r IPv4) Truncated() bool {
+	return int(r.IHL)*4+len(r.Payload) < int(r.Length)
+}
+
+// TCP parses the payload of this IPv4 packet as a TCP segment. The non-first fragments do not have the TCP
+// header, so they are rejected.
+func (r *IPv4) TCP() (*TCP, error) {
+	if r.Protocol != 6 {
+		return nil, errors.New("packet is not a TCP segment")
+	}
+	if r.Offset != 0 {
+		return nil, errors.New("non-first fragment of a TCP segment")
+	}
+
+	v := new(TCP)
+	if err := v.UnmarshalBinary(r.Payload); err != nil {
+		return nil, err
+	}
+	v.SetPseudoHeader(r.SrcIP, r.DstIP)
+
+	return v, nil
+}
+
+// UDP parses the payload of this IPv4 packet as a UDP datagram. The non-first fragments do not have the UDP
+// header, so they are rejected.
+func (r *IPv4) UDP() (*UDP, error) {
+	if r.Protocol != 17 {
+		return nil, errors.New("packet is not a UDP datagram")
+	}
+	if r.Offset != 0 {
+		return nil, errors.New("non-first fragment of a UDP datagram")
+	}
+
+	v := new(UDP)
+	if err := v.UnmarshalBinary(r.Payload); err != nil {
+		return nil, err
+	}
+	v.SetPseudoHeader(r.SrcIP, r.DstIP)
+
+	return v, nil
+}
+
+// ICMP parses the payload of this IPv4 packet as an ICMP message.
+func (r *IPv4) ICMP() (*ICMP, error) {
+	if r.Protocol != 1 {
+		return nil, errors.New("packet is not an ICMP message")
+	}
+
+	v := new(ICMP)
+	if err := v.UnmarshalBinary(r.Payload); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
@@ -0,0 +1,138 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestIPv4Codec(t *testing.T) {
+	src := []struct {
+		Options   []byte
+		HeaderLen int
+	}{
+		{nil, 20},
+		// Router alert.
+		{[]byte{0x94, 0x04, 0x00, 0x00}, 24},
+		// Padded to a multiple of 4 bytes.
+		{[]byte{0x01, 0x01, 0x07}, 24},
+		{bytes.Repeat([]byte{0x01}, 40), 60},
+	}
+
+	for i, v := range src {
+		payload := []byte("hello")
+		ip := NewIPv4(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2), 17, payload)
+		ip.DSCP = 46
+		ip.Options = v.Options
+		ip.Length = uint16(v.HeaderLen + len(payload))
+
+		data, err := ip.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if len(data) != v.HeaderLen+len(payload) {
+			t.Fatalf("#%v: unexpected packet length: expected=%v, got=%v", i, v.HeaderLen+len(payload), len(data))
+		}
+
+		decoded := new(IPv4)
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if int(decoded.IHL)*4 != v.HeaderLen || decoded.Version != 4 || decoded.TOS() != 46<<2 || decoded.TTL != 64 || decoded.Protocol != 17 {
+			t.Fatalf("#%v: unexpected decoded header: %+v", i, decoded)
+		}
+		if !decoded.SrcIP.Equal(ip.SrcIP) || !decoded.DstIP.Equal(ip.DstIP) {
+			t.Fatalf("#%v: unexpected decoded addresses: src=%v, dst=%v", i, decoded.SrcIP, decoded.DstIP)
+		}
+		if len(decoded.Options) != v.HeaderLen-20 || !bytes.HasPrefix(decoded.Options, v.Options) {
+			t.Fatalf("#%v: unexpected decoded options: expected=%x, got=%x", i, v.Options, decoded.Options)
+		}
+		if !bytes.Equal(decoded.Payload, payload) {
+			t.Fatalf("#%v: unexpected decoded payload: %x", i, decoded.Payload)
+		}
+		if decoded.Checksum != binary.BigEndian.Uint16(data[10:12]) {
+			t.Fatalf("#%v: unexpected decoded checksum: %x", i, decoded.Checksum)
+		}
+
+		// Corrupted headers should be rejected.
+		data[v.HeaderLen-1] ^= 0x01
+		if err := decoded.UnmarshalBinary(data); err != ErrIPv4Checksum {
+			t.Fatalf("#%v: expected checksum error for the corrupted header: %v", i, err)
+		}
+	}
+}
+
+func TestIPv4UpdateChecksum(t *testing.T) {
+	data, err := NewIPv4(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2), 1, []byte("ping")).MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ip := new(IPv4)
+	if err := ip.UnmarshalBinary(data); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	ip.TTL--
+	old := ip.Checksum
+	if err := ip.UpdateChecksum(); err != nil {
+		t.Fatalf("failed to update the checksum: %v", err)
+	}
+	if ip.Checksum == old {
+		t.Fatalf("checksum is not updated: %x", ip.Checksum)
+	}
+	data, err = ip.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if binary.BigEndian.Uint16(data[10:12]) != ip.Checksum {
+		t.Fatalf("unexpected checksum: expected=%x, got=%x", ip.Checksum, data[10:12])
+	}
+}
+
+func TestInvalidIPv4(t *testing.T) {
+	valid, err := NewIPv4(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2), 17, nil).MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src := [][]byte{
+		valid[:19],
+		// IHL shorter than the minimum header.
+		append([]byte{0x44}, valid[1:]...),
+		// IHL longer than the packet.
+		append([]byte{0x46}, valid[1:]...),
+	}
+	for i, v := range src {
+		if err := new(IPv4).UnmarshalBinary(v); err == nil {
+			t.Fatalf("#%v: expected an error for the invalid packet", i)
+		}
+	}
+
+	ip := NewIPv4(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2), 17, nil)
+	ip.Options = make([]byte, 41)
+	if _, err := ip.MarshalBinary(); err == nil {
+		t.Fatal("expected an error for the too long options")
+	}
+}
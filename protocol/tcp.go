@@ -28,6 +28,19 @@ import (
 	"net"
 )
 
+// TCP flags.
+const (
+	TCPFlagFIN uint16 = 1 << iota
+	TCPFlagSYN
+	TCPFlagRST
+	TCPFlagPSH
+	TCPFlagACK
+	TCPFlagURG
+	TCPFlagECE
+	TCPFlagCWR
+	TCPFlagNS
+)
+
 type TCP struct {
 	srcIP          net.IP
 	dstIP          net.IP
@@ -40,7 +53,16 @@ type TCP struct {
 	WindowSize uint16
 	Checksum   uint16
 	Urgent     uint16
-	Payload    []byte
+	// Options are padded to a multiple of 4 bytes when the packet is marshaled.
+	Options []byte
+	// Payload may be shorter than the original one if the packet has been truncated, e.g., by miss_send_len
+	// of PACKET_IN. See IPv4.Truncated.
+	Payload []byte
+}
+
+// HasFlags returns whether all of flags, e.g., TCPFlagSYN|TCPFlagACK, are set.
+func (r TCP) HasFlags(flags uint16) bool {
+	return r.Flags&flags == flags
 }
 
 // TCP checksum needs a pseudo header that has src and dst IPv4 addresses.
@@ -50,7 +72,12 @@ func (r *TCP) SetPseudoHeader(src, dst net.IP) {
 }
 
 func (r TCP) MarshalBinary() ([]byte, error) {
-	length := 20
+	// Pad the options to a multiple of 4 bytes.
+	headerLen := 20 + (len(r.Options)+3)/4*4
+	if headerLen > 60 {
+		return nil, errors.New("too long TCP options")
+	}
+	length := headerLen
 	if r.Payload != nil {
 		length += len(r.Payload)
 	}
@@ -63,13 +90,14 @@ func (r TCP) MarshalBinary() ([]byte, error) {
 	binary.BigEndian.PutUint16(v[2:4], r.DstPort)
 	binary.BigEndian.PutUint32(v[4:8], r.Sequence)
 	binary.BigEndian.PutUint32(v[8:12], r.Acknowledgment)
-	v[12] = uint8(0x5<<4 | (r.Flags >> 8 & 0x1))
+	v[12] = uint8(headerLen/4)<<4 | uint8(r.Flags>>8&0x1)
 	v[13] = uint8(r.Flags & 0xFF)
 	binary.BigEndian.PutUint16(v[14:16], r.WindowSize)
 	// v[16:18] is checksum
 	binary.BigEndian.PutUint16(v[18:20], r.Urgent)
+	copy(v[20:], r.Options)
 	if r.Payload != nil {
-		copy(v[20:], r.Payload)
+		copy(v[headerLen:], r.Payload)
 	}
 
 	if r.srcIP == nil || r.dstIP == nil {
@@ -95,20 +123,33 @@ func (r TCP) MarshalBinary() ([]byte, error) {
 	return v, nil
 }
 
+// UnmarshalBinary decodes a TCP segment. It returns ErrTruncated if the header including the options has been
+// truncated, and the payload is what remains after the header.
 func (r *TCP) UnmarshalBinary(data []byte) error {
 	if len(data) < 20 {
-		return errors.New("invalid TCP packet length")
+		return ErrTruncated
+	}
+	offset := int(data[12]>>4) * 4
+	if offset < 20 {
+		return errors.New("invalid TCP data offset")
+	}
+	if offset > len(data) {
+		return ErrTruncated
 	}
 
 	r.SrcPort = binary.BigEndian.Uint16(data[0:2])
 	r.DstPort = binary.BigEndian.Uint16(data[2:4])
 	r.Sequence = binary.BigEndian.Uint32(data[4:8])
 	r.Acknowledgment = binary.BigEndian.Uint32(data[8:12])
-	offset := int((data[12] >> 4)) * 4
-	r.Flags = uint16((data[12]&0x1)<<8 | data[13])
+	r.Flags = uint16(data[12]&0x1)<<8 | uint16(data[13])
 	r.WindowSize = binary.BigEndian.Uint16(data[14:16])
 	r.Checksum = binary.BigEndian.Uint16(data[16:18])
 	r.Urgent = binary.BigEndian.Uint16(data[18:20])
+	r.Options = nil
+	if offset > 20 {
+		r.Options = data[20:offset]
+	}
+	r.Payload = nil
 	if len(data) > offset {
 		r.Payload = data[offset:]
 	}
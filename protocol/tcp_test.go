@@ -0,0 +1,172 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestTCPCodec(t *testing.T) {
+	src := []struct {
+		Flags     uint16
+		Options   []byte
+		HeaderLen int
+	}{
+		{TCPFlagSYN, nil, 20},
+		{TCPFlagSYN | TCPFlagACK | TCPFlagECE | TCPFlagCWR | TCPFlagNS, nil, 20},
+		// MSS.
+		{TCPFlagSYN, []byte{0x02, 0x04, 0x05, 0xb4}, 24},
+		// Padded to a multiple of 4 bytes.
+		{TCPFlagPSH | TCPFlagACK, []byte{0x01, 0x01, 0x01, 0x01, 0x01}, 28},
+	}
+
+	for i, v := range src {
+		payload := []byte("hello")
+		tcp := TCP{
+			SrcPort:        12345,
+			DstPort:        80,
+			Sequence:       0x01020304,
+			Acknowledgment: 0x05060708,
+			Flags:          v.Flags,
+			WindowSize:     29200,
+			Urgent:         7,
+			Options:        v.Options,
+			Payload:        payload,
+		}
+		tcp.SetPseudoHeader(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2))
+		data, err := tcp.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+		if len(data) != v.HeaderLen+len(payload) {
+			t.Fatalf("#%v: unexpected segment length: expected=%v, got=%v", i, v.HeaderLen+len(payload), len(data))
+		}
+
+		decoded := new(TCP)
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if decoded.SrcPort != 12345 || decoded.DstPort != 80 || decoded.Sequence != 0x01020304 || decoded.Acknowledgment != 0x05060708 {
+			t.Fatalf("#%v: unexpected decoded header: %+v", i, decoded)
+		}
+		if decoded.Flags != v.Flags || !decoded.HasFlags(v.Flags) || decoded.WindowSize != 29200 || decoded.Urgent != 7 {
+			t.Fatalf("#%v: unexpected decoded header: %+v", i, decoded)
+		}
+		if len(decoded.Options) != v.HeaderLen-20 || !bytes.HasPrefix(decoded.Options, v.Options) {
+			t.Fatalf("#%v: unexpected decoded options: expected=%x, got=%x", i, v.Options, decoded.Options)
+		}
+		if !bytes.Equal(decoded.Payload, payload) {
+			t.Fatalf("#%v: unexpected decoded payload: %x", i, decoded.Payload)
+		}
+	}
+}
+
+func TestInvalidTCP(t *testing.T) {
+	tcp := TCP{SrcPort: 1, DstPort: 2, Options: []byte{0x02, 0x04, 0x05, 0xb4}}
+	tcp.SetPseudoHeader(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2))
+	valid, err := tcp.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src := []struct {
+		Data      []byte
+		Truncated bool
+	}{
+		{valid[:19], true},
+		// Options cut off by the truncation.
+		{valid[:22], true},
+		// Data offset shorter than the minimum header.
+		{append(append([]byte{}, valid[:12]...), append([]byte{0x40}, valid[13:]...)...), false},
+	}
+	for i, v := range src {
+		err := new(TCP).UnmarshalBinary(v.Data)
+		if err == nil {
+			t.Fatalf("#%v: expected an error for the invalid segment", i)
+		}
+		if v.Truncated != (err == ErrTruncated) {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+	}
+
+	if _, err := (TCP{Options: make([]byte, 41)}).MarshalBinary(); err == nil {
+		t.Fatal("expected an error for the too long options")
+	}
+}
+
+func TestIPv4TCP(t *testing.T) {
+	tcp := TCP{SrcPort: 40000, DstPort: 443, Flags: TCPFlagPSH | TCPFlagACK, Payload: bytes.Repeat([]byte{0xAB}, 100)}
+	tcp.SetPseudoHeader(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2))
+	segment, err := tcp.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	packet, err := NewIPv4(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2), 6, segment).MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src := []struct {
+		Data       []byte
+		PayloadLen int
+		Truncated  bool
+	}{
+		{packet, 100, false},
+		// Truncated by miss_send_len of PACKET_IN.
+		{packet[:64], 64 - 40, true},
+	}
+	for i, v := range src {
+		ip := new(IPv4)
+		if err := ip.UnmarshalBinary(v.Data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal IPv4: %v", i, err)
+		}
+		if ip.Truncated() != v.Truncated {
+			t.Fatalf("#%v: unexpected truncated flag: expected=%v, got=%v", i, v.Truncated, ip.Truncated())
+		}
+		decoded, err := ip.TCP()
+		if err != nil {
+			t.Fatalf("#%v: failed to parse TCP: %v", i, err)
+		}
+		if decoded.SrcPort != 40000 || decoded.DstPort != 443 || !decoded.HasFlags(TCPFlagACK) || len(decoded.Payload) != v.PayloadLen {
+			t.Fatalf("#%v: unexpected TCP: %+v", i, decoded)
+		}
+	}
+
+	// The header itself has been truncated.
+	ip := new(IPv4)
+	if err := ip.UnmarshalBinary(packet[:30]); err != nil {
+		t.Fatalf("failed to unmarshal IPv4: %v", err)
+	}
+	if _, err := ip.TCP(); err != ErrTruncated {
+		t.Fatalf("expected the truncated error: %v", err)
+	}
+	// Non-first fragments do not have the TCP header.
+	ip.Offset = 1
+	if _, err := ip.TCP(); err == nil {
+		t.Fatal("expected an error for the non-first fragment")
+	}
+	if _, err := ip.UDP(); err == nil {
+		t.Fatal("expected an error for the non-UDP packet")
+	}
+}
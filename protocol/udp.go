@@ -35,7 +35,15 @@ type UDP struct {
 	DstPort  uint16
 	Length   uint16
 	Checksum uint16
-	Payload  []byte
+	// Payload may be shorter than Length implies if the packet has been truncated, e.g., by miss_send_len of
+	// PACKET_IN. See Truncated.
+	Payload []byte
+}
+
+// Truncated returns whether the payload is shorter than the length in the header, which means the packet has
+// been truncated.
+func (r UDP) Truncated() bool {
+	return int(r.Length) > 8+len(r.Payload)
 }
 
 // UDP checksum needs a pseudo header that has src and dst IPv4 addresses.
@@ -85,15 +93,27 @@ func (r UDP) MarshalBinary() ([]byte, error) {
 	return v, nil
 }
 
+// UnmarshalBinary decodes a UDP datagram. It returns ErrTruncated if the header has been truncated. The payload
+// is limited by the length in the header, and it is what remains after the header if the datagram has been
+// truncated.
 func (r *UDP) UnmarshalBinary(data []byte) error {
 	if len(data) < 8 {
-		return errors.New("invalid UDP packet length")
+		return ErrTruncated
+	}
+	length := int(binary.BigEndian.Uint16(data[4:6]))
+	if length < 8 {
+		return errors.New("invalid UDP length")
 	}
 
 	r.SrcPort = binary.BigEndian.Uint16(data[0:2])
 	r.DstPort = binary.BigEndian.Uint16(data[2:4])
-	r.Length = binary.BigEndian.Uint16(data[4:6])
+	r.Length = uint16(length)
 	r.Checksum = binary.BigEndian.Uint16(data[6:8])
+	// Ignore the trailing bytes, such as Ethernet padding, beyond the length.
+	if length < len(data) {
+		data = data[:length]
+	}
+	r.Payload = nil
 	if len(data) > 8 {
 		r.Payload = data[8:]
 	}
@@ -0,0 +1,112 @@
+/*
+ * Cherry - An OpenFlow Controller
+ *
+ * Copyright (C) 2015-2019 Samjung Data Service, Inc. All rights reserved.
+ *  Kitae Kim <superkkt@sds.co.kr>
+ *
+ * This program is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; either version 2 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with this program; if not, write to the Free Software Foundation, Inc.,
+ * 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestUDPCodec(t *testing.T) {
+	udp := UDP{SrcPort: 68, DstPort: 67, Payload: []byte("hello")}
+	udp.SetPseudoHeader(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2))
+	data, err := udp.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src := []struct {
+		Data      []byte
+		Payload   []byte
+		Truncated bool
+	}{
+		{data, []byte("hello"), false},
+		// Ethernet padding after the datagram should be ignored.
+		{append(append([]byte{}, data...), 0x00, 0x00, 0x00), []byte("hello"), false},
+		// Truncated by miss_send_len of PACKET_IN.
+		{data[:10], []byte("he"), true},
+	}
+	for i, v := range src {
+		decoded := new(UDP)
+		if err := decoded.UnmarshalBinary(v.Data); err != nil {
+			t.Fatalf("#%v: failed to unmarshal: %v", i, err)
+		}
+		if decoded.SrcPort != 68 || decoded.DstPort != 67 || decoded.Length != 13 {
+			t.Fatalf("#%v: unexpected decoded header: %+v", i, decoded)
+		}
+		if !bytes.Equal(decoded.Payload, v.Payload) {
+			t.Fatalf("#%v: unexpected decoded payload: %x", i, decoded.Payload)
+		}
+		if decoded.Truncated() != v.Truncated {
+			t.Fatalf("#%v: unexpected truncated flag: expected=%v, got=%v", i, v.Truncated, decoded.Truncated())
+		}
+	}
+}
+
+func TestInvalidUDP(t *testing.T) {
+	src := []struct {
+		Data      []byte
+		Truncated bool
+	}{
+		{[]byte{0x00, 0x44, 0x00, 0x43, 0x00, 0x08, 0x00}, true},
+		// Length shorter than the header.
+		{[]byte{0x00, 0x44, 0x00, 0x43, 0x00, 0x07, 0x00, 0x00}, false},
+	}
+	for i, v := range src {
+		err := new(UDP).UnmarshalBinary(v.Data)
+		if err == nil {
+			t.Fatalf("#%v: expected an error for the invalid datagram", i)
+		}
+		if v.Truncated != (err == ErrTruncated) {
+			t.Fatalf("#%v: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestIPv4UDP(t *testing.T) {
+	udp := UDP{SrcPort: 5353, DstPort: 5353, Payload: []byte("query")}
+	udp.SetPseudoHeader(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2))
+	datagram, err := udp.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	packet, err := NewIPv4(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2), 17, datagram).MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ip := new(IPv4)
+	if err := ip.UnmarshalBinary(packet); err != nil {
+		t.Fatalf("failed to unmarshal IPv4: %v", err)
+	}
+	v, err := ip.UDP()
+	if err != nil {
+		t.Fatalf("failed to parse UDP: %v", err)
+	}
+	if v.SrcPort != 5353 || v.DstPort != 5353 || string(v.Payload) != "query" {
+		t.Fatalf("unexpected UDP: %+v", v)
+	}
+	if _, err := ip.TCP(); err == nil {
+		t.Fatal("expected an error for the non-TCP packet")
+	}
+}
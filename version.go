@@ -26,3 +26,7 @@
 package cherry
 
 const Version = "0.14.2"
+
+// Build is the VCS revision of this build. It is set at link time, e.g.,
+// go build -ldflags "-X github.com/superkkt/cherry.Build=$(git rev-parse --short HEAD)"
+var Build = "unknown"